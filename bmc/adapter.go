@@ -2,6 +2,7 @@ package bmc
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -82,9 +83,23 @@ type BMC interface {
 	// Reset performs a hard reset on a specific node
 	Reset(ctx context.Context, nodeID int) error
 
+	// HardReset power-cycles a node for a true cold boot: it powers the node off,
+	// waits until the power status confirms it is off, then powers it on and
+	// waits until the power status confirms it is on
+	HardReset(ctx context.Context, nodeID int) error
+
 	// Reset performs a hard reset on all nodes
 	ResetAll(ctx context.Context) error
 
+	// SetBootOrder makes a node boot from the given devices, in order, from
+	// now on. The node is power-cycled, the order saved in its U-Boot
+	// environment over the UART, then the node boots with it.
+	SetBootOrder(ctx context.Context, nodeID int, order []BootDevice) error
+
+	// NetBoot power-cycles a node and boots it over the network once, with
+	// PXE then DHCP, without changing its saved boot order
+	NetBoot(ctx context.Context, nodeID int) error
+
 	// BMC Operations
 
 	// GetInfo retrieves information about the BMC
@@ -96,6 +111,10 @@ type BMC interface {
 	// UpdateFirmware updates the BMC firmware
 	UpdateFirmware(ctx context.Context, firmwarePath string) error
 
+	// GetCapabilities discovers which subcommands the BMC firmware supports
+	// by parsing the output of "tpi help"
+	GetCapabilities(ctx context.Context) (Capabilities, error)
+
 	// USB Operations
 
 	// GetUSBConfig retrieves the current USB routing configuration
@@ -106,6 +125,11 @@ type BMC interface {
 	// host: true for host mode, false for device mode
 	SetUSBConfig(ctx context.Context, nodeID int, host bool) error
 
+	// EnsureUSBOwnership routes the USB bus to a node in the given mode unless
+	// another node holds it, in which case a *USBConflictError matching
+	// ErrUSBInUse is returned. With force, the bus is taken over anyway.
+	EnsureUSBOwnership(ctx context.Context, nodeID int, host bool, force bool) error
+
 	// Ethernet Operations
 
 	// ResetEthSwitch resets the on-board Ethernet switch
@@ -125,6 +149,24 @@ type BMC interface {
 	// imagePath: path to the image file on the BMC filesystem
 	FlashNode(ctx context.Context, nodeID int, imagePath string) error
 
+	// GetStorageTargets lists the storage a node in MSD mode exposes to the BMC,
+	// with the device of each. The node must be the one the USB bus is routed
+	// to. Returns ErrStorageTargetAmbiguous when several devices could be the
+	// same storage.
+	GetStorageTargets(ctx context.Context, nodeID int) (map[StorageTarget]string, error)
+
+	// FlashNodeTarget flashes an image to the eMMC or the SD card of a node in
+	// MSD mode. Returns ErrStorageTargetNotFound when the node does not expose
+	// the target.
+	FlashNodeTarget(ctx context.Context, nodeID int, imagePath string, target StorageTarget) error
+
+	// DeployImage flashes a node and boots it: it powers the node off, switches
+	// it to MSD mode, flashes the image, switches back to normal mode, power
+	// cycles the node and waits for it to boot. Failures are reported as a
+	// *DeployError naming the failed step. A node already deployed with the same
+	// image is left untouched unless opts.Force is set.
+	DeployImage(ctx context.Context, nodeID int, imagePath string, opts DeployOptions) error
+
 	// UART Operations
 
 	// GetUARTOutput retrieves the UART output from a specific node
@@ -139,6 +181,17 @@ type BMC interface {
 	// timeout is the maximum time to wait for each expected string
 	ExpectAndSend(ctx context.Context, nodeID int, steps []InteractionStep, timeout time.Duration) (string, error)
 
+	// MonitorUART watches the UART output of several nodes at once and calls
+	// onLine for every complete line, tagged with the node it came from.
+	// It runs until ctx is done and returns the context error.
+	MonitorUART(ctx context.Context, nodeIDs []int, onLine func(nodeID int, line string)) error
+
+	// AttachConsole bridges in and out to the UART of a node for an interactive
+	// session: lines read from in are sent to the node and its output is written
+	// to out. It returns nil when in reaches EOF or ConsoleEscape is read, and the
+	// context error when ctx is done.
+	AttachConsole(ctx context.Context, nodeID int, in io.Reader, out io.Writer) error
+
 	// File Operations
 
 	// UploadFile uploads a file from the local filesystem to the BMC
@@ -146,8 +199,50 @@ type BMC interface {
 	// remotePath is the destination path on the BMC
 	UploadFile(ctx context.Context, localPath, remotePath string) error
 
+	// Node Metrics
+
+	// SetNodeExecutor registers the executor used to run commands on a booted node,
+	// typically an SSHExecutor connected to the node's address
+	SetNodeExecutor(nodeID int, executor CommandExecutor)
+
+	// GetNodeUptime retrieves how long a booted node has been running
+	GetNodeUptime(ctx context.Context, nodeID int) (time.Duration, error)
+
+	// GetResetReason reads why a booted node last reset, as one of the
+	// ResetReason categories (watchdog, power, software or unknown).
+	// Returns ErrResetReasonNotAvailable when the node cannot be reached.
+	GetResetReason(ctx context.Context, nodeID int) (string, error)
+
+	// IsNodeReady probes whether a node is powered on, answers on the network
+	// and accepts SSH connections, each probe running only when the previous
+	// one passed. The node is reached at sshConfig.Host, or at the IP address
+	// recorded in its state when empty, on sshConfig.Port, 22 when zero.
+	// Logging in is tried only when sshConfig holds credentials.
+	IsNodeReady(ctx context.Context, nodeID int, sshConfig SSHConfig) (Readiness, error)
+
+	// GetNodePowerDraw retrieves the current power draw of a node in watts.
+	// Returns ErrMetricNotAvailable when the BMC does not expose a sensor for
+	// the node, which the Turing Pi BMC does for none of them.
+	GetNodePowerDraw(ctx context.Context, nodeID int) (watts float64, err error)
+
+	// Fan Control
+
+	// GetFanSpeed retrieves the current speed of the board fan as a percentage.
+	// Returns ErrFanControlNotSupported when the firmware cannot control the fan.
+	GetFanSpeed(ctx context.Context) (percent int, err error)
+
+	// SetFanSpeed sets the speed of the board fan to a percentage between 0 and 100,
+	// rounded to the nearest level the fan supports.
+	// Returns ErrFanControlNotSupported when the firmware cannot control the fan.
+	SetFanSpeed(ctx context.Context, percent int) error
+
 	// Generic Command Execution
 
 	// ExecuteCommand executes a BMC-specific command
 	ExecuteCommand(ctx context.Context, command string) (stdout string, stderr string, err error)
+
+	// ExecuteCommandStatus executes a BMC-specific command and returns its exit
+	// code. The error is nil when the command ran, whatever its exit code, and
+	// set when it could not be run, the exit code then being -1.
+	ExecuteCommandStatus(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error)
 }
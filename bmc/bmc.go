@@ -3,17 +3,40 @@ package bmc
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	tperrors "github.com/davidroman0O/turingpi/errors"
+	"github.com/davidroman0O/turingpi/state"
 )
 
 // bmcImpl implements the BMC interface
 type bmcImpl struct {
 	executor CommandExecutor
+
+	// Optional access to the nodes themselves and to persisted node state
+	mu            sync.RWMutex
+	nodeExecutors map[int]CommandExecutor
+	stateManager  state.Manager
+
+	// How power state changes are awaited
+	powerPollInterval time.Duration
+	powerStateTimeout time.Duration
+
+	// How often MonitorUART polls each node
+	uartPollInterval time.Duration
+
+	// How long each U-Boot prompt is awaited when changing the boot order
+	ubootTimeout time.Duration
+
+	// How long each network probe of IsNodeReady waits for the node
+	readinessTimeout time.Duration
 }
 
 // CommandExecutor defines the interface for executing commands
@@ -21,6 +44,12 @@ type CommandExecutor interface {
 	ExecuteCommand(command string) (stdout string, stderr string, err error)
 }
 
+// StatusExecutor is implemented by executors reporting the exit code of the
+// commands they run separately from the errors running them
+type StatusExecutor interface {
+	ExecuteCommandStatus(command string) (stdout string, stderr string, exitCode int, err error)
+}
+
 // FileUploader defines an interface for uploading files
 type FileUploader interface {
 	UploadFile(localPath, remotePath string) error
@@ -28,8 +57,33 @@ type FileUploader interface {
 
 // New creates a new BMC instance
 func New(executor CommandExecutor) BMC {
+	return newBMC(executor)
+}
+
+// NewWithState creates a new BMC instance that records node metric samples
+// into the given state manager
+func NewWithState(executor CommandExecutor, manager state.Manager) BMC {
+	b := newBMC(executor)
+	b.stateManager = manager
+	return b
+}
+
+// invalidNodeIDError reports a node outside 1-4. Retrying cannot fix it, so
+// the error is marked permanent.
+func invalidNodeIDError(nodeID int) error {
+	return tperrors.Permanent(fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID))
+}
+
+// newBMC creates a bmcImpl with default settings
+func newBMC(executor CommandExecutor) *bmcImpl {
 	return &bmcImpl{
-		executor: executor,
+		executor:          executor,
+		nodeExecutors:     make(map[int]CommandExecutor),
+		powerPollInterval: time.Second,
+		powerStateTimeout: 30 * time.Second,
+		uartPollInterval:  100 * time.Millisecond,
+		ubootTimeout:      time.Minute,
+		readinessTimeout:  5 * time.Second,
 	}
 }
 
@@ -80,6 +134,10 @@ func (b *bmcImpl) GetPowerStatus(ctx context.Context, nodeID int) (*PowerStatus,
 
 // PowerOn implements BMC interface
 func (b *bmcImpl) PowerOn(ctx context.Context, nodeID int) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
 	_, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("tpi power on --node %d", nodeID))
 	if err != nil {
 		return fmt.Errorf("failed to power on node %d: %w (stderr: %s)", nodeID, err, stderr)
@@ -89,6 +147,10 @@ func (b *bmcImpl) PowerOn(ctx context.Context, nodeID int) error {
 
 // PowerOff implements BMC interface
 func (b *bmcImpl) PowerOff(ctx context.Context, nodeID int) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
 	_, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("tpi power off --node %d", nodeID))
 	if err != nil {
 		return fmt.Errorf("failed to power off node %d: %w (stderr: %s)", nodeID, err, stderr)
@@ -98,6 +160,10 @@ func (b *bmcImpl) PowerOff(ctx context.Context, nodeID int) error {
 
 // Reset implements BMC interface
 func (b *bmcImpl) Reset(ctx context.Context, nodeID int) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
 	_, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("tpi power reset --node %d", nodeID))
 	if err != nil {
 		return fmt.Errorf("failed to reset node %d: %w (stderr: %s)", nodeID, err, stderr)
@@ -171,10 +237,46 @@ func (b *bmcImpl) ExecuteCommand(ctx context.Context, command string) (string, s
 	return b.executor.ExecuteCommand(command)
 }
 
+// ExecuteCommandStatus implements BMC interface
+func (b *bmcImpl) ExecuteCommandStatus(ctx context.Context, command string) (string, string, int, error) {
+	return commandStatus(b.executor, command)
+}
+
+// commandStatus runs a command and returns its exit code, read from the error
+// of executors not implementing StatusExecutor
+func commandStatus(executor CommandExecutor, command string) (string, string, int, error) {
+	if executor, ok := executor.(StatusExecutor); ok {
+		return executor.ExecuteCommandStatus(command)
+	}
+
+	stdout, stderr, err := executor.ExecuteCommand(command)
+	if err == nil {
+		return stdout, stderr, 0, nil
+	}
+	if code, ok := exitCodeOf(err); ok {
+		return stdout, stderr, code, nil
+	}
+	return stdout, stderr, -1, err
+}
+
+// exitCodeOf returns the exit code carried by the error of a command that
+// ran, such as an *exec.ExitError or an *ssh.ExitError
+func exitCodeOf(err error) (int, bool) {
+	var exitCoder interface{ ExitCode() int }
+	if errors.As(err, &exitCoder) && exitCoder.ExitCode() >= 0 {
+		return exitCoder.ExitCode(), true
+	}
+	var exitStatuser interface{ ExitStatus() int }
+	if errors.As(err, &exitStatuser) {
+		return exitStatuser.ExitStatus(), true
+	}
+	return 0, false
+}
+
 // ExpectAndSend implements BMC interface
 func (b *bmcImpl) ExpectAndSend(ctx context.Context, nodeID int, steps []InteractionStep, timeout time.Duration) (string, error) {
 	if nodeID < 1 || nodeID > 4 {
-		return "", fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID)
+		return "", invalidNodeIDError(nodeID)
 	}
 
 	// Create a buffer to capture all output
@@ -285,8 +387,7 @@ func (b *bmcImpl) waitForUARTOutput(ctx context.Context, nodeID int, buffer *byt
 
 // sendUARTData sends data to the node via UART
 func (b *bmcImpl) sendUARTData(ctx context.Context, nodeID int, data string) error {
-	escapedData := strings.ReplaceAll(data, "\"", "\\\"")
-	cmd := fmt.Sprintf("tpi uart --node %d set -c \"%s\"", nodeID, escapedData)
+	cmd := fmt.Sprintf("tpi uart --node %d set -c \"%s\"", nodeID, escapeUARTInput(data))
 	_, stderr, err := b.executor.ExecuteCommand(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to send UART data: %w (stderr: %s)", err, stderr)
@@ -294,6 +395,15 @@ func (b *bmcImpl) sendUARTData(ctx context.Context, nodeID int, data string) err
 	return nil
 }
 
+// uartInputEscaper escapes the characters the BMC shell interprets within
+// double quotes, so input reaches the node as typed
+var uartInputEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+// escapeUARTInput escapes input to be sent within double quotes by tpi uart
+func escapeUARTInput(input string) string {
+	return uartInputEscaper.Replace(input)
+}
+
 // captureRemainingOutput captures any remaining output from UART
 func (b *bmcImpl) captureRemainingOutput(ctx context.Context, nodeID int, buffer *bytes.Buffer, duration time.Duration) error {
 	endTime := time.Now().Add(duration)
@@ -398,7 +508,7 @@ func (b *bmcImpl) GetUSBConfig(ctx context.Context) (*USBConfig, error) {
 // SetUSBConfig implements BMC interface
 func (b *bmcImpl) SetUSBConfig(ctx context.Context, nodeID int, host bool) error {
 	if nodeID < 0 || nodeID > 4 {
-		return fmt.Errorf("invalid node ID: %d (must be 0-4)", nodeID)
+		return tperrors.Permanent(fmt.Errorf("invalid node ID: %d (must be 0-4)", nodeID))
 	}
 
 	var cmd string
@@ -433,7 +543,7 @@ func (b *bmcImpl) ResetEthSwitch(ctx context.Context) error {
 // SetNodeMode implements BMC interface
 func (b *bmcImpl) SetNodeMode(ctx context.Context, nodeID int, mode NodeMode) error {
 	if nodeID < 1 || nodeID > 4 {
-		return fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID)
+		return invalidNodeIDError(nodeID)
 	}
 
 	if mode != NodeModeNormal && mode != NodeModeMSD {
@@ -451,7 +561,7 @@ func (b *bmcImpl) SetNodeMode(ctx context.Context, nodeID int, mode NodeMode) er
 // FlashNode implements BMC interface
 func (b *bmcImpl) FlashNode(ctx context.Context, nodeID int, imagePath string) error {
 	if nodeID < 1 || nodeID > 4 {
-		return fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID)
+		return invalidNodeIDError(nodeID)
 	}
 
 	if imagePath == "" {
@@ -470,7 +580,7 @@ func (b *bmcImpl) FlashNode(ctx context.Context, nodeID int, imagePath string) e
 // GetUARTOutput implements BMC interface
 func (b *bmcImpl) GetUARTOutput(ctx context.Context, nodeID int) (string, error) {
 	if nodeID < 1 || nodeID > 4 {
-		return "", fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID)
+		return "", invalidNodeIDError(nodeID)
 	}
 
 	stdout, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("tpi uart --node %d get", nodeID))
@@ -483,12 +593,10 @@ func (b *bmcImpl) GetUARTOutput(ctx context.Context, nodeID int) (string, error)
 // SendUARTInput implements BMC interface
 func (b *bmcImpl) SendUARTInput(ctx context.Context, nodeID int, input string) error {
 	if nodeID < 1 || nodeID > 4 {
-		return fmt.Errorf("invalid node ID: %d (must be 1-4)", nodeID)
+		return invalidNodeIDError(nodeID)
 	}
 
-	// Escape quotes in the input
-	escapedInput := strings.ReplaceAll(input, "\"", "\\\"")
-	cmd := fmt.Sprintf("tpi uart --node %d set --cmd \"%s\"", nodeID, escapedInput)
+	cmd := fmt.Sprintf("tpi uart --node %d set --cmd \"%s\"", nodeID, escapeUARTInput(input))
 	_, stderr, err := b.executor.ExecuteCommand(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to send UART input to node %d: %w (stderr: %s)", nodeID, err, stderr)
@@ -157,6 +157,15 @@ func TestBMC_ExecuteCommand(t *testing.T) {
 		}
 
 		t.Logf("Help command output sample: %s", getFirstLines(stdout, 5))
+
+		caps, err := bmc.GetCapabilities(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get capabilities: %v", err)
+		}
+		if !caps.Has("power") {
+			t.Errorf("Capabilities missing the power command: %v", caps.Commands)
+		}
+		t.Logf("BMC capabilities: %+v", caps)
 	})
 }
 
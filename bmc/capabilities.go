@@ -0,0 +1,92 @@
+package bmc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Capabilities describes which tpi subcommands the BMC firmware supports,
+// so workflows can degrade gracefully on older firmware
+type Capabilities struct {
+	// Commands lists every subcommand found in the help output
+	Commands []string
+	// MSD reports whether nodes can be switched to mass storage device mode
+	MSD bool
+	// Flash reports whether nodes can be flashed
+	Flash bool
+	// UART reports whether UART output can be read and written
+	UART bool
+	// USB reports whether the USB routing can be changed
+	USB bool
+	// Firmware reports whether the BMC firmware can be upgraded
+	Firmware bool
+}
+
+// Has reports whether the given subcommand is supported
+func (c Capabilities) Has(command string) bool {
+	for _, available := range c.Commands {
+		if available == command {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCapabilities implements BMC interface
+func (b *bmcImpl) GetCapabilities(ctx context.Context) (Capabilities, error) {
+	stdout, stderr, err := b.executor.ExecuteCommand("tpi help")
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to get BMC help: %w (stderr: %s)", err, stderr)
+	}
+	return parseCapabilities(stdout)
+}
+
+// parseCapabilities reads the "Commands:" section of the tpi help output
+func parseCapabilities(help string) (Capabilities, error) {
+	var caps Capabilities
+	inCommands := false
+	found := false
+
+	scanner := bufio.NewScanner(strings.NewReader(help))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "Commands:" {
+			inCommands = true
+			found = true
+			continue
+		}
+		if !inCommands {
+			continue
+		}
+		// The section ends at a blank line or at the next unindented header
+		if trimmed == "" || !strings.HasPrefix(line, " ") {
+			inCommands = false
+			continue
+		}
+
+		command := strings.Fields(trimmed)[0]
+		if command == "help" {
+			continue
+		}
+		caps.Commands = append(caps.Commands, command)
+	}
+	if err := scanner.Err(); err != nil {
+		return Capabilities{}, fmt.Errorf("failed to read BMC help: %w", err)
+	}
+	if !found {
+		return Capabilities{}, fmt.Errorf("no commands found in BMC help output")
+	}
+
+	// Newer firmware moved the mass storage mode under "advanced"
+	caps.MSD = caps.Has("msd") || caps.Has("advanced")
+	caps.Flash = caps.Has("flash")
+	caps.UART = caps.Has("uart")
+	caps.USB = caps.Has("usb")
+	caps.Firmware = caps.Has("firmware")
+
+	return caps, nil
+}
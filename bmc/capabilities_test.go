@@ -0,0 +1,120 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// helpV2 is the "tpi help" output of firmware 2.x
+const helpV2 = `Official Turing-Pi2 CLI tool
+
+Usage: tpi [OPTIONS] [COMMAND]
+
+Commands:
+  power     Power on/off or reset specific nodes
+  usb       Change the USB device/host configuration. The USB-bus can only be routed to one node simultaneously
+  firmware  Upgrade the firmware of the BMC
+  flash     Flash a given node
+  eth       Configure the on-board Ethernet switch
+  uart      Read or write over UART
+  advanced  Advanced node modes
+  info      Print turing-pi info
+  reboot    Reboot the BMC chip. Nodes will lose power until booted!
+  help      Print this message or the help of the given subcommand(s)
+
+Options:
+      --host <HOST>        Specify the Turing-pi host to connect to
+      --json               Print results formatted as JSON
+  -h, --help               Print help
+  -V, --version            Print version
+`
+
+// helpV1 is the "tpi help" output of an early firmware without UART,
+// firmware upgrade or mass storage support
+const helpV1 = `Official Turing-Pi2 CLI tool
+
+Usage: tpi [OPTIONS] [COMMAND]
+
+Commands:
+  power   Power on/off or reset specific nodes
+  usb     Change the USB device/host configuration
+  flash   Flash a given node
+  info    Print turing-pi info
+  reboot  Reboot the BMC chip
+  help    Print this message or the help of the given subcommand(s)
+
+Options:
+      --host <HOST>  Specify the Turing-pi host to connect to
+  -h, --help         Print help
+`
+
+func TestGetCapabilities(t *testing.T) {
+	t.Run("Firmware versions", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			help     string
+			commands []string
+			want     Capabilities
+		}{
+			{
+				name:     "v2",
+				help:     helpV2,
+				commands: []string{"power", "usb", "firmware", "flash", "eth", "uart", "advanced", "info", "reboot"},
+				want:     Capabilities{MSD: true, Flash: true, UART: true, USB: true, Firmware: true},
+			},
+			{
+				name:     "v1",
+				help:     helpV1,
+				commands: []string{"power", "usb", "flash", "info", "reboot"},
+				want:     Capabilities{Flash: true, USB: true},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				executor := newMockExecutor()
+				executor.ResponseMap["tpi help"] = mockResponse{Stdout: tt.help}
+
+				caps, err := newBMC(executor).GetCapabilities(context.Background())
+				if err != nil {
+					t.Fatalf("GetCapabilities() error = %v", err)
+				}
+				if !reflect.DeepEqual(caps.Commands, tt.commands) {
+					t.Errorf("Commands = %v, want %v", caps.Commands, tt.commands)
+				}
+
+				tt.want.Commands = caps.Commands
+				if !reflect.DeepEqual(caps, tt.want) {
+					t.Errorf("GetCapabilities() = %+v, want %+v", caps, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("Has", func(t *testing.T) {
+		caps, err := parseCapabilities(helpV1)
+		if err != nil {
+			t.Fatalf("parseCapabilities() error = %v", err)
+		}
+		if !caps.Has("power") || caps.Has("eth") || caps.Has("help") {
+			t.Errorf("Has() gives wrong answers for %v", caps.Commands)
+		}
+	})
+
+	t.Run("Unrecognized output", func(t *testing.T) {
+		if _, err := parseCapabilities("tpi: command not found"); err == nil {
+			t.Error("parseCapabilities() should fail without a Commands section")
+		}
+	})
+
+	t.Run("Command failure", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi help"] = mockResponse{Stderr: "connection refused", Err: errors.New("exit status 1")}
+
+		if _, err := newBMC(executor).GetCapabilities(context.Background()); err == nil {
+			t.Error("GetCapabilities() should fail when the help command fails")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package bmc
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultReadOnlyCommands matches BMC commands whose output only changes when
+// the cluster is reconfigured, so it is safe to reuse for a short while.
+// UART reads are deliberately excluded: each read consumes the buffered output.
+var DefaultReadOnlyCommands = []*regexp.Regexp{
+	regexp.MustCompile(`^tpi info$`),
+	regexp.MustCompile(`^tpi about$`),
+}
+
+// CachingExecutor wraps a CommandExecutor and memoizes the results of read-only
+// commands for a short TTL to avoid repeating identical SSH round-trips.
+// Any other command is always executed and, since it may change the cluster,
+// invalidates every cached result.
+type CachingExecutor struct {
+	executor CommandExecutor
+	ttl      time.Duration
+	readOnly []*regexp.Regexp
+
+	mu      sync.Mutex
+	results map[string]cachedResult
+	// generation changes on every invalidation, so a read that overlapped with
+	// a write does not store its possibly stale result
+	generation uint64
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// cachedResult is the memoized output of a successful command
+type cachedResult struct {
+	stdout    string
+	stderr    string
+	expiresAt time.Time
+}
+
+// NewCachingExecutor creates a CachingExecutor caching the commands matching
+// readOnly for ttl. DefaultReadOnlyCommands is used when no pattern is given.
+func NewCachingExecutor(executor CommandExecutor, ttl time.Duration, readOnly ...*regexp.Regexp) *CachingExecutor {
+	if len(readOnly) == 0 {
+		readOnly = DefaultReadOnlyCommands
+	}
+	return &CachingExecutor{
+		executor: executor,
+		ttl:      ttl,
+		readOnly: readOnly,
+		results:  make(map[string]cachedResult),
+		now:      time.Now,
+	}
+}
+
+// ExecuteCommand implements CommandExecutor
+func (e *CachingExecutor) ExecuteCommand(command string) (string, string, error) {
+	if !e.isReadOnly(command) {
+		stdout, stderr, err := e.executor.ExecuteCommand(command)
+		e.Invalidate()
+		return stdout, stderr, err
+	}
+
+	e.mu.Lock()
+	result, ok := e.results[command]
+	generation := e.generation
+	e.mu.Unlock()
+	if ok && e.now().Before(result.expiresAt) {
+		return result.stdout, result.stderr, nil
+	}
+
+	stdout, stderr, err := e.executor.ExecuteCommand(command)
+	if err != nil {
+		// Failures are never cached so the next call tries again
+		return stdout, stderr, err
+	}
+
+	e.mu.Lock()
+	if generation == e.generation {
+		e.results[command] = cachedResult{stdout: stdout, stderr: stderr, expiresAt: e.now().Add(e.ttl)}
+	}
+	e.mu.Unlock()
+
+	return stdout, stderr, nil
+}
+
+// UploadFile implements FileUploader when the wrapped executor supports uploads
+func (e *CachingExecutor) UploadFile(localPath, remotePath string) error {
+	uploader, ok := e.executor.(FileUploader)
+	if !ok {
+		return fmt.Errorf("file upload not supported by the current executor")
+	}
+
+	defer e.Invalidate()
+	return uploader.UploadFile(localPath, remotePath)
+}
+
+// Invalidate drops every cached result
+func (e *CachingExecutor) Invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results = make(map[string]cachedResult)
+	e.generation++
+}
+
+// isReadOnly reports whether a command may be served from the cache
+func (e *CachingExecutor) isReadOnly(command string) bool {
+	for _, pattern := range e.readOnly {
+		if pattern.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package bmc
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func countCommands(executor *mockExecutor, command string) int {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+
+	count := 0
+	for _, c := range executor.Commands {
+		if c == command {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCachingExecutor(t *testing.T) {
+	newExecutors := func() (*mockExecutor, *CachingExecutor, *time.Time) {
+		inner := newMockExecutor()
+		inner.ResponseMap["tpi info"] = mockResponse{Stdout: "api: 1.1\nversion: 2.0.5\n"}
+		inner.ResponseMap["tpi power on --node 1"] = mockResponse{}
+		inner.ResponseMap["tpi power status"] = mockResponse{Stdout: "node1: On\n"}
+
+		clock := time.Now()
+		cached := NewCachingExecutor(inner, 5*time.Second)
+		cached.now = func() time.Time { return clock }
+		return inner, cached, &clock
+	}
+
+	t.Run("Repeated read-only command hits the cache", func(t *testing.T) {
+		inner, cached, _ := newExecutors()
+
+		for i := 0; i < 3; i++ {
+			stdout, _, err := cached.ExecuteCommand("tpi info")
+			if err != nil || stdout != "api: 1.1\nversion: 2.0.5\n" {
+				t.Fatalf("ExecuteCommand() = %q, %v", stdout, err)
+			}
+		}
+		if n := countCommands(inner, "tpi info"); n != 1 {
+			t.Errorf("tpi info ran %d times, want 1", n)
+		}
+	})
+
+	t.Run("Results expire after the TTL", func(t *testing.T) {
+		inner, cached, clock := newExecutors()
+
+		cached.ExecuteCommand("tpi info")
+		*clock = clock.Add(6 * time.Second)
+		cached.ExecuteCommand("tpi info")
+
+		if n := countCommands(inner, "tpi info"); n != 2 {
+			t.Errorf("tpi info ran %d times, want 2", n)
+		}
+	})
+
+	t.Run("Power commands are never cached and invalidate results", func(t *testing.T) {
+		inner, cached, _ := newExecutors()
+
+		cached.ExecuteCommand("tpi info")
+		cached.ExecuteCommand("tpi power on --node 1")
+		cached.ExecuteCommand("tpi power on --node 1")
+		cached.ExecuteCommand("tpi info")
+
+		if n := countCommands(inner, "tpi power on --node 1"); n != 2 {
+			t.Errorf("tpi power on ran %d times, want 2", n)
+		}
+		if n := countCommands(inner, "tpi info"); n != 2 {
+			t.Errorf("tpi info ran %d times, want the cache invalidated by power on", n)
+		}
+	})
+
+	t.Run("Commands outside the patterns are not cached", func(t *testing.T) {
+		inner, cached, _ := newExecutors()
+
+		cached.ExecuteCommand("tpi power status")
+		cached.ExecuteCommand("tpi power status")
+		if n := countCommands(inner, "tpi power status"); n != 2 {
+			t.Errorf("tpi power status ran %d times, want 2", n)
+		}
+	})
+
+	t.Run("Failures are not cached", func(t *testing.T) {
+		inner, cached, _ := newExecutors()
+		inner.ResponseMap["tpi info"] = mockResponse{Stderr: "timeout", Err: errors.New("exit status 1")}
+
+		if _, _, err := cached.ExecuteCommand("tpi info"); err == nil {
+			t.Fatal("ExecuteCommand() expected an error")
+		}
+		cached.ExecuteCommand("tpi info")
+		if n := countCommands(inner, "tpi info"); n != 2 {
+			t.Errorf("tpi info ran %d times, want 2", n)
+		}
+	})
+
+	t.Run("Custom read-only patterns", func(t *testing.T) {
+		inner := newMockExecutor()
+		inner.ResponseMap["tpi power status"] = mockResponse{Stdout: "node1: On\n"}
+		cached := NewCachingExecutor(inner, time.Minute, regexp.MustCompile(`^tpi power status$`))
+
+		cached.ExecuteCommand("tpi power status")
+		cached.ExecuteCommand("tpi power status")
+		if n := countCommands(inner, "tpi power status"); n != 1 {
+			t.Errorf("tpi power status ran %d times, want 1", n)
+		}
+	})
+
+	t.Run("BMC uses the cache transparently", func(t *testing.T) {
+		inner, cached, _ := newExecutors()
+		b := newBMC(cached)
+
+		for i := 0; i < 2; i++ {
+			if _, _, err := b.executor.ExecuteCommand("tpi info"); err != nil {
+				t.Fatalf("ExecuteCommand() error = %v", err)
+			}
+		}
+		if n := countCommands(inner, "tpi info"); n != 1 {
+			t.Errorf("tpi info ran %d times, want 1", n)
+		}
+	})
+}
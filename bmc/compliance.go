@@ -0,0 +1,87 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// InfoReader reads the information of a BMC, implemented by BMC and by the
+// BMC tools of the TuringPi providers
+type InfoReader interface {
+	GetInfo(ctx context.Context) (*BMCInfo, error)
+}
+
+// ComplianceResult reports whether a BMC runs the expected firmware version
+type ComplianceResult struct {
+	// Version is the firmware version the BMC reports, empty when it could not be read
+	Version string
+	// Expected is the version the BMC was checked against
+	Expected string
+	// Compliant is true when Version matches Expected
+	Compliant bool
+	// Err is the error reading the BMC information, if any
+	Err error
+}
+
+// CheckFirmwareCompliance reads the firmware version of every BMC, keyed by
+// name such as the cluster they belong to, and reports which ones run
+// expectedVersion. Versions are compared ignoring a leading "v". The BMCs are
+// queried concurrently. The result holds an entry for every BMC, those that
+// could not be read are not compliant, and their errors are returned joined.
+func CheckFirmwareCompliance(ctx context.Context, bmcs map[string]InfoReader, expectedVersion string) (map[string]ComplianceResult, error) {
+	expected := normalizeVersion(expectedVersion)
+	if expected == "" {
+		return nil, errors.New("expected firmware version cannot be empty")
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]ComplianceResult, len(bmcs))
+	)
+	for name, reader := range bmcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := ComplianceResult{Expected: expectedVersion}
+			info, err := reader.GetInfo(ctx)
+			if err != nil {
+				result.Err = err
+			} else {
+				result.Version = info.Version
+				result.Compliant = normalizeVersion(info.Version) == expected
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := results[name].Err; err != nil {
+			errs = append(errs, fmt.Errorf("BMC %s: %w", name, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// normalizeVersion returns a firmware version without surrounding spaces and
+// leading "v", so "v2.0.5" and "2.0.5" compare equal
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	return strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+}
@@ -0,0 +1,63 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckFirmwareCompliance(t *testing.T) {
+	ctx := context.Background()
+
+	// newVersionedBMC returns a BMC whose "tpi info" reports version
+	newVersionedBMC := func(version string) BMC {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi info"] = mockResponse{
+			Stdout: "|---------------|\n|  tpi info     |\n|---------------|\napi: 1.1\nversion: " + version + "\nbuildroot: \"Buildroot 2022.11.1\"\n",
+		}
+		return newBMC(executor)
+	}
+
+	unreachable := newMockExecutor()
+	unreachable.ResponseMap["tpi info"] = mockResponse{Stderr: "connection refused", Err: errors.New("exit status 255")}
+
+	bmcs := map[string]InfoReader{
+		"rack-a": newVersionedBMC("2.0.5"),
+		"rack-b": newVersionedBMC("v2.0.5"),
+		"rack-c": newVersionedBMC("2.0.3"),
+		"rack-d": newBMC(unreachable),
+	}
+
+	results, err := CheckFirmwareCompliance(ctx, bmcs, "2.0.5")
+	if err == nil || !strings.Contains(err.Error(), "rack-d") {
+		t.Errorf("CheckFirmwareCompliance() error = %v, want rack-d reported", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("CheckFirmwareCompliance() returned %d results, want 4", len(results))
+	}
+
+	want := map[string]ComplianceResult{
+		"rack-a": {Version: "2.0.5", Expected: "2.0.5", Compliant: true},
+		"rack-b": {Version: "v2.0.5", Expected: "2.0.5", Compliant: true},
+		"rack-c": {Version: "2.0.3", Expected: "2.0.5", Compliant: false},
+	}
+	for name, expected := range want {
+		if got := results[name]; got != expected {
+			t.Errorf("Result of %s = %+v, want %+v", name, got, expected)
+		}
+	}
+	if got := results["rack-d"]; got.Compliant || got.Version != "" || got.Err == nil {
+		t.Errorf("Result of the unreachable BMC = %+v", got)
+	}
+
+	// A fleet running the expected version
+	results, err = CheckFirmwareCompliance(ctx, map[string]InfoReader{"rack-c": bmcs["rack-c"]}, "2.0.3")
+	if err != nil || !results["rack-c"].Compliant {
+		t.Errorf("CheckFirmwareCompliance() = %+v, %v", results, err)
+	}
+
+	if _, err := CheckFirmwareCompliance(ctx, bmcs, " "); err == nil {
+		t.Error("CheckFirmwareCompliance() expected an error for an empty version")
+	}
+}
@@ -0,0 +1,129 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// ConsoleEscape ends an AttachConsole session when read from the input.
+// It is Ctrl-], as in telnet.
+const ConsoleEscape = "\x1d"
+
+// consoleInput is a chunk read from the console input
+type consoleInput struct {
+	data string
+	err  error
+}
+
+// readConsoleInput forwards chunks read from in until it fails or ctx is done
+func readConsoleInput(ctx context.Context, in io.Reader, chunks chan<- consoleInput) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := in.Read(buf)
+		select {
+		case chunks <- consoleInput{data: string(buf[:n]), err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// AttachConsole implements BMC interface
+func (b *bmcImpl) AttachConsole(ctx context.Context, nodeID int, in io.Reader, out io.Writer) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Reads from in block, so they happen in their own goroutine
+	chunks := make(chan consoleInput)
+	go readConsoleInput(ctx, in, chunks)
+
+	// Remember a failing UART so it is logged once rather than on every poll
+	failing := false
+	display := func() error {
+		output, err := b.GetUARTOutput(ctx, nodeID)
+		if err != nil {
+			if !failing {
+				log.Printf("[BMC UART] Node %d UART unavailable, will keep retrying: %v", nodeID, err)
+				failing = true
+			}
+			return nil
+		}
+		failing = false
+		if output == "" {
+			return nil
+		}
+		if _, err := io.WriteString(out, output); err != nil {
+			return fmt.Errorf("failed to write console output: %w", err)
+		}
+		return nil
+	}
+
+	// Input is sent to the node a line at a time, as the BMC sends commands
+	var pending string
+	send := func(line string) error {
+		return b.SendUARTInput(ctx, nodeID, strings.TrimSuffix(line, "\r"))
+	}
+
+	ticker := time.NewTicker(b.uartPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := display(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case chunk := <-chunks:
+			data := chunk.data
+			escaped := false
+			if i := strings.Index(data, ConsoleEscape); i >= 0 {
+				data = data[:i]
+				escaped = true
+			}
+
+			pending += data
+			for {
+				end := strings.IndexByte(pending, '\n')
+				if end < 0 {
+					break
+				}
+				line := pending[:end]
+				pending = pending[end+1:]
+				if err := send(line); err != nil {
+					return err
+				}
+			}
+
+			if escaped {
+				// An unfinished line is dropped rather than sent
+				return display()
+			}
+			if chunk.err != nil {
+				if !errors.Is(chunk.err, io.EOF) {
+					return fmt.Errorf("failed to read console input: %w", chunk.err)
+				}
+				if pending != "" {
+					if err := send(pending); err != nil {
+						return err
+					}
+				}
+				return display()
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package bmc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tperrors "github.com/davidroman0O/turingpi/errors"
+)
+
+// echoUART simulates the UART of node 1 echoing every line sent to it
+type echoUART struct {
+	output string
+	sent   []string
+}
+
+func newConsoleBMC(uart *echoUART) *bmcImpl {
+	const setPrefix = "tpi uart --node 1 set --cmd \""
+	executor := newMockExecutor()
+	executor.Handler = func(command string) (mockResponse, bool) {
+		switch {
+		case command == "tpi uart --node 1 get":
+			output := uart.output
+			uart.output = ""
+			return mockResponse{Stdout: output}, true
+		case strings.HasPrefix(command, setPrefix):
+			line := strings.TrimSuffix(strings.TrimPrefix(command, setPrefix), "\"")
+			line = strings.ReplaceAll(line, "\\\"", "\"")
+			uart.sent = append(uart.sent, line)
+			uart.output += line + "\r\n"
+			return mockResponse{}, true
+		}
+		return mockResponse{}, false
+	}
+
+	b := newBMC(executor)
+	b.uartPollInterval = time.Millisecond
+	return b
+}
+
+func TestAttachConsole(t *testing.T) {
+	t.Run("Input is sent line by line and echoed to the output", func(t *testing.T) {
+		uart := &echoUART{output: "node1 login: "}
+		b := newConsoleBMC(uart)
+
+		var out bytes.Buffer
+		in := strings.NewReader("root\r\necho \"hi\"\nuname")
+		if err := b.AttachConsole(context.Background(), 1, in, &out); err != nil {
+			t.Fatalf("AttachConsole() error = %v", err)
+		}
+
+		if want := []string{"root", "echo \"hi\"", "uname"}; !reflect.DeepEqual(uart.sent, want) {
+			t.Errorf("Sent lines = %q, want %q", uart.sent, want)
+		}
+		if want := "node1 login: root\r\necho \"hi\"\r\nuname\r\n"; out.String() != want {
+			t.Errorf("Console output = %q, want %q", out.String(), want)
+		}
+	})
+
+	t.Run("Escape sequence ends the session", func(t *testing.T) {
+		uart := &echoUART{}
+		b := newConsoleBMC(uart)
+
+		var out bytes.Buffer
+		in := strings.NewReader("ls\npartial" + ConsoleEscape + "reboot\n")
+		if err := b.AttachConsole(context.Background(), 1, in, &out); err != nil {
+			t.Fatalf("AttachConsole() error = %v", err)
+		}
+
+		if want := []string{"ls"}; !reflect.DeepEqual(uart.sent, want) {
+			t.Errorf("Sent lines = %q, want %q", uart.sent, want)
+		}
+		if out.String() != "ls\r\n" {
+			t.Errorf("Console output = %q", out.String())
+		}
+	})
+
+	t.Run("Cancelled context stops the session", func(t *testing.T) {
+		uart := &echoUART{output: "Ubuntu 22.04 LTS\r\n"}
+		b := newConsoleBMC(uart)
+
+		// Nothing is ever written to the pipe
+		in, writer := io.Pipe()
+		defer writer.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+
+		var out bytes.Buffer
+		if err := b.AttachConsole(ctx, 1, in, &out); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("AttachConsole() error = %v, want context.DeadlineExceeded", err)
+		}
+		if out.String() != "Ubuntu 22.04 LTS\r\n" {
+			t.Errorf("Console output = %q", out.String())
+		}
+	})
+
+	t.Run("Input errors are reported", func(t *testing.T) {
+		b := newConsoleBMC(&echoUART{})
+		in := io.MultiReader(strings.NewReader("ls\n"), &failingReader{err: errors.New("tty closed")})
+
+		err := b.AttachConsole(context.Background(), 1, in, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "tty closed") {
+			t.Errorf("AttachConsole() error = %v, want the input error", err)
+		}
+	})
+
+	t.Run("Invalid node", func(t *testing.T) {
+		b := newBMC(newMockExecutor())
+		if err := b.AttachConsole(context.Background(), 5, strings.NewReader(""), io.Discard); !tperrors.IsPermanent(err) {
+			t.Errorf("AttachConsole() error = %v, want a permanent error for an invalid node ID", err)
+		}
+	})
+}
+
+// failingReader fails every read
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
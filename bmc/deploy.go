@@ -0,0 +1,147 @@
+package bmc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultDeployMarkerDir is where DeployImage records which image each node
+// was deployed with, on the BMC filesystem
+const DefaultDeployMarkerDir = "/root/.turingpi/deployed"
+
+// DeployStep names a step of DeployImage
+type DeployStep string
+
+const (
+	DeployStepCheckImage DeployStep = "check-image"
+	DeployStepPowerOff   DeployStep = "power-off"
+	DeployStepMSDMode    DeployStep = "msd-mode"
+	DeployStepFlash      DeployStep = "flash"
+	DeployStepNormalMode DeployStep = "normal-mode"
+	DeployStepPowerCycle DeployStep = "power-cycle"
+	DeployStepWaitBoot   DeployStep = "wait-boot"
+	DeployStepMarker     DeployStep = "write-marker"
+)
+
+// DeployOptions configures DeployImage
+type DeployOptions struct {
+	// BootPattern is the UART output that shows the node has booted, such as
+	// "login:". The boot is not awaited when empty.
+	BootPattern string
+	// BootTimeout bounds the wait for BootPattern (default 5 minutes)
+	BootTimeout time.Duration
+	// MarkerDir overrides DefaultDeployMarkerDir
+	MarkerDir string
+	// Force deploys the image even if the marker shows it is already deployed
+	Force bool
+	// Target is the storage the image is flashed to. When empty, the image is
+	// flashed with tpi flash without checking which storage the node has.
+	Target StorageTarget
+}
+
+// DeployError reports the step at which a deployment failed
+type DeployError struct {
+	NodeID int
+	Step   DeployStep
+	Err    error
+}
+
+// Error implements the error interface
+func (e *DeployError) Error() string {
+	return fmt.Sprintf("deploying to node %d failed at step %s: %v", e.NodeID, e.Step, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DeployError) Unwrap() error {
+	return e.Err
+}
+
+// DeployImage implements BMC interface
+func (b *bmcImpl) DeployImage(ctx context.Context, nodeID int, imagePath string, opts DeployOptions) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+	if imagePath == "" {
+		return fmt.Errorf("image path cannot be empty")
+	}
+	if opts.BootTimeout == 0 {
+		opts.BootTimeout = 5 * time.Minute
+	}
+	if opts.MarkerDir == "" {
+		opts.MarkerDir = DefaultDeployMarkerDir
+	}
+
+	fail := func(step DeployStep, err error) error {
+		return &DeployError{NodeID: nodeID, Step: step, Err: err}
+	}
+
+	// The image is identified by its path, size and modification time, which
+	// is cheap to compare unlike a checksum of a multi-gigabyte file
+	stdout, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("stat -c '%%s %%Y' %s", quoteArg(imagePath)))
+	if err != nil {
+		return fail(DeployStepCheckImage, fmt.Errorf("image %s not found on the BMC: %w (stderr: %s)", imagePath, err, stderr))
+	}
+	marker := fmt.Sprintf("%s %s", imagePath, strings.TrimSpace(stdout))
+	markerPath := path.Join(opts.MarkerDir, fmt.Sprintf("node%d", nodeID))
+
+	if !opts.Force {
+		current, _, err := b.executor.ExecuteCommand(fmt.Sprintf("cat %s", quoteArg(markerPath)))
+		if err == nil && strings.TrimSpace(current) == marker {
+			return nil
+		}
+	}
+
+	if err := b.PowerOff(ctx, nodeID); err != nil {
+		return fail(DeployStepPowerOff, err)
+	}
+	if err := b.waitForPowerState(ctx, nodeID, PowerStateOff); err != nil {
+		return fail(DeployStepPowerOff, err)
+	}
+
+	if err := b.SetNodeMode(ctx, nodeID, NodeModeMSD); err != nil {
+		return fail(DeployStepMSDMode, err)
+	}
+
+	flash := func() error {
+		if opts.Target == "" {
+			return b.FlashNode(ctx, nodeID, imagePath)
+		}
+		return b.FlashNodeTarget(ctx, nodeID, imagePath, opts.Target)
+	}
+	if err := flash(); err != nil {
+		// Leave the node bootable from its previous system if possible
+		_ = b.SetNodeMode(ctx, nodeID, NodeModeNormal)
+		return fail(DeployStepFlash, err)
+	}
+
+	if err := b.SetNodeMode(ctx, nodeID, NodeModeNormal); err != nil {
+		return fail(DeployStepNormalMode, err)
+	}
+
+	if err := b.HardReset(ctx, nodeID); err != nil {
+		return fail(DeployStepPowerCycle, err)
+	}
+
+	if opts.BootPattern != "" {
+		var output bytes.Buffer
+		if err := b.waitForUARTOutput(ctx, nodeID, &output, opts.BootPattern, opts.BootTimeout); err != nil {
+			return fail(DeployStepWaitBoot, err)
+		}
+	}
+
+	cmd := fmt.Sprintf("mkdir -p %s && echo %s > %s", quoteArg(opts.MarkerDir), quoteArg(marker), quoteArg(markerPath))
+	if _, stderr, err := b.executor.ExecuteCommand(cmd); err != nil {
+		return fail(DeployStepMarker, fmt.Errorf("%w (stderr: %s)", err, stderr))
+	}
+
+	return nil
+}
+
+// quoteArg quotes an argument for a shell command line on the BMC
+func quoteArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", "'\\''") + "'"
+}
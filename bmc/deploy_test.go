@@ -0,0 +1,176 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDeployBMC creates a BMC whose node 1 changes power state on request,
+// holding /images/node.img and printing a login prompt on its UART
+func newDeployBMC(sim *powerSimulator) (*bmcImpl, *mockExecutor) {
+	executor := newMockExecutor()
+	executor.Handler = sim.handle
+	executor.ResponseMap["stat -c '%s %Y' '/images/node.img'"] = mockResponse{Stdout: "1073741824 1700000000\n"}
+	executor.ResponseMap["tpi advanced --node 1 msd"] = mockResponse{}
+	executor.ResponseMap["tpi flash --node 1 -i /images/node.img"] = mockResponse{}
+	executor.ResponseMap["tpi advanced --node 1 normal"] = mockResponse{}
+	executor.ResponseMap["tpi uart --node 1 get"] = mockResponse{Stdout: "Ubuntu 22.04 LTS\nnode1 login: "}
+	executor.ResponseMap["mkdir -p '/markers' && echo '/images/node.img 1073741824 1700000000' > '/markers/node1'"] = mockResponse{}
+
+	b := newBMC(executor)
+	b.powerPollInterval = time.Millisecond
+	b.powerStateTimeout = 50 * time.Millisecond
+	return b, executor
+}
+
+// deployCommands returns the commands sent to the BMC, without power status polls
+func deployCommands(executor *mockExecutor) []string {
+	var commands []string
+	for _, command := range executor.Commands {
+		if command != "tpi power status" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+func TestDeployImage(t *testing.T) {
+	ctx := context.Background()
+	opts := DeployOptions{BootPattern: "login:", BootTimeout: time.Second, MarkerDir: "/markers"}
+
+	t.Run("Step sequence", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+
+		if err := b.DeployImage(ctx, 1, "/images/node.img", opts); err != nil {
+			t.Fatalf("DeployImage() error = %v", err)
+		}
+
+		want := []string{
+			"stat -c '%s %Y' '/images/node.img'",
+			"cat '/markers/node1'",
+			"tpi power off --node 1",
+			"tpi advanced --node 1 msd",
+			"tpi flash --node 1 -i /images/node.img",
+			"tpi advanced --node 1 normal",
+			"tpi power off --node 1",
+			"tpi power on --node 1",
+			"tpi uart --node 1 get",
+			"mkdir -p '/markers' && echo '/images/node.img 1073741824 1700000000' > '/markers/node1'",
+		}
+		if got := deployCommands(executor); !reflect.DeepEqual(got, want) {
+			t.Errorf("Commands =\n%v\nwant\n%v", got, want)
+		}
+	})
+
+	t.Run("Already deployed", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+		executor.ResponseMap["cat '/markers/node1'"] = mockResponse{Stdout: "/images/node.img 1073741824 1700000000\n"}
+
+		if err := b.DeployImage(ctx, 1, "/images/node.img", opts); err != nil {
+			t.Fatalf("DeployImage() error = %v", err)
+		}
+		if len(sim.events) != 0 {
+			t.Errorf("Node was power cycled although already deployed: %v", sim.events)
+		}
+
+		forced := opts
+		forced.Force = true
+		if err := b.DeployImage(ctx, 1, "/images/node.img", forced); err != nil {
+			t.Fatalf("DeployImage(Force) error = %v", err)
+		}
+		if len(sim.events) == 0 {
+			t.Error("Forced deployment did not run")
+		}
+	})
+
+	t.Run("Flash failure", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+		executor.ResponseMap["tpi flash --node 1 -i /images/node.img"] = mockResponse{Stderr: "write error", Err: errors.New("exit status 1")}
+
+		err := b.DeployImage(ctx, 1, "/images/node.img", opts)
+		var deployErr *DeployError
+		if !errors.As(err, &deployErr) || deployErr.Step != DeployStepFlash || deployErr.NodeID != 1 {
+			t.Fatalf("DeployImage() error = %v, want a DeployError at the flash step", err)
+		}
+
+		commands := deployCommands(executor)
+		if last := commands[len(commands)-1]; last != "tpi advanced --node 1 normal" {
+			t.Errorf("Last command = %s, want the node switched back to normal mode", last)
+		}
+		for _, command := range commands {
+			if command == "tpi power on --node 1" {
+				t.Error("Node was powered on after a failed flash")
+			}
+		}
+	})
+
+	t.Run("SD card target", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+		executor.ResponseMap["tpi usb get"] = usbRoutedTo(1, "device")
+		executor.ResponseMap[lsblkCommand] = mockResponse{Stdout: lsblkOutput}
+		executor.ResponseMap["dd if=/images/node.img of=/dev/sdb bs=4M conv=fsync"] = mockResponse{}
+
+		sd := opts
+		sd.Target = StorageSD
+		if err := b.DeployImage(ctx, 1, "/images/node.img", sd); err != nil {
+			t.Fatalf("DeployImage() error = %v", err)
+		}
+		for _, command := range executor.Commands {
+			if strings.HasPrefix(command, "tpi flash") {
+				t.Errorf("The eMMC was flashed for an SD card target: %s", command)
+			}
+		}
+	})
+
+	t.Run("Boot timeout", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+		executor.ResponseMap["tpi uart --node 1 get"] = mockResponse{Stdout: "Kernel panic\n"}
+
+		timeout := opts
+		timeout.BootTimeout = 10 * time.Millisecond
+		err := b.DeployImage(ctx, 1, "/images/node.img", timeout)
+		var deployErr *DeployError
+		if !errors.As(err, &deployErr) || deployErr.Step != DeployStepWaitBoot {
+			t.Fatalf("DeployImage() error = %v, want a DeployError at the wait-boot step", err)
+		}
+	})
+
+	t.Run("Missing image", func(t *testing.T) {
+		b, executor := newDeployBMC(&powerSimulator{state: PowerStateOn})
+
+		err := b.DeployImage(ctx, 1, "/images/missing.img", opts)
+		var deployErr *DeployError
+		if !errors.As(err, &deployErr) || deployErr.Step != DeployStepCheckImage {
+			t.Fatalf("DeployImage() error = %v, want a DeployError at the check-image step", err)
+		}
+		if len(executor.Commands) != 1 {
+			t.Errorf("Commands after a missing image = %v", executor.Commands)
+		}
+	})
+	t.Run("Paths are quoted", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b, executor := newDeployBMC(sim)
+		executor.ResponseMap["stat -c '%s %Y' '/images/my node.img'"] = mockResponse{Stdout: "1073741824 1700000000\n"}
+		executor.ResponseMap["tpi flash --node 1 -i /images/my node.img"] = mockResponse{}
+		executor.ResponseMap[`mkdir -p '/markers/it'\''s here' && echo '/images/my node.img 1073741824 1700000000' > '/markers/it'\''s here/node1'`] = mockResponse{}
+
+		quoted := opts
+		quoted.MarkerDir = "/markers/it's here"
+		if err := b.DeployImage(ctx, 1, "/images/my node.img", quoted); err != nil {
+			t.Fatalf("DeployImage() error = %v", err)
+		}
+		commands := deployCommands(executor)
+		if cat := commands[1]; cat != `cat '/markers/it'\''s here/node1'` {
+			t.Errorf("Marker read with %s", cat)
+		}
+	})
+}
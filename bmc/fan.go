@@ -0,0 +1,121 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrFanControlNotSupported is returned when the BMC firmware has no cooling
+// command or the board exposes no controllable fan
+var ErrFanControlNotSupported = errors.New("fan control not supported")
+
+var (
+	coolingDeviceRegex   = regexp.MustCompile(`(?i)device:?\s*([^\s,|]+)`)
+	coolingSpeedRegex    = regexp.MustCompile(`(?i)(?:^|[\s,|])speed:?\s*(\d+)`)
+	coolingMaxSpeedRegex = regexp.MustCompile(`(?i)max(?:[ _]speed)?:?\s*(\d+)`)
+)
+
+// coolingDevice is a fan reported by "tpi cooling status". Its speed is a
+// cooling level between 0 and maxSpeed.
+type coolingDevice struct {
+	name     string
+	speed    int
+	maxSpeed int
+}
+
+// GetFanSpeed implements BMC interface
+func (b *bmcImpl) GetFanSpeed(ctx context.Context) (int, error) {
+	device, err := b.getCoolingDevice()
+	if err != nil {
+		return 0, err
+	}
+	return levelToPercent(device.speed, device.maxSpeed), nil
+}
+
+// SetFanSpeed implements BMC interface
+func (b *bmcImpl) SetFanSpeed(ctx context.Context, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid fan speed %d%%: must be between 0 and 100", percent)
+	}
+
+	device, err := b.getCoolingDevice()
+	if err != nil {
+		return err
+	}
+
+	level := percentToLevel(percent, device.maxSpeed)
+	_, stderr, err := b.executor.ExecuteCommand(fmt.Sprintf("tpi cooling set --device %s --speed %d", device.name, level))
+	if err != nil {
+		if isUnsupportedCommand(stderr) {
+			return ErrFanControlNotSupported
+		}
+		return fmt.Errorf("failed to set fan speed to %d%%: %w (stderr: %s)", percent, err, stderr)
+	}
+	return nil
+}
+
+// getCoolingDevice returns the first fan reported by the BMC
+func (b *bmcImpl) getCoolingDevice() (coolingDevice, error) {
+	stdout, stderr, err := b.executor.ExecuteCommand("tpi cooling status")
+	if err != nil {
+		if isUnsupportedCommand(stderr) {
+			return coolingDevice{}, ErrFanControlNotSupported
+		}
+		return coolingDevice{}, fmt.Errorf("failed to get cooling status: %w (stderr: %s)", err, stderr)
+	}
+
+	devices := parseCoolingStatus(stdout)
+	if len(devices) == 0 {
+		return coolingDevice{}, fmt.Errorf("no cooling device reported: %w", ErrFanControlNotSupported)
+	}
+	return devices[0], nil
+}
+
+// isUnsupportedCommand reports whether tpi rejected the cooling subcommand,
+// as firmware older than the cooling support does
+func isUnsupportedCommand(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	return strings.Contains(stderr, "unrecognized subcommand") ||
+		strings.Contains(stderr, "unknown command") ||
+		strings.Contains(stderr, "not supported")
+}
+
+// parseCoolingStatus parses the output of "tpi cooling status", one device
+// per line such as "device: fan0, speed: 2, max speed: 3"
+func parseCoolingStatus(output string) []coolingDevice {
+	var devices []coolingDevice
+	for _, line := range strings.Split(output, "\n") {
+		deviceMatch := coolingDeviceRegex.FindStringSubmatch(line)
+		speedMatch := coolingSpeedRegex.FindStringSubmatch(line)
+		maxMatch := coolingMaxSpeedRegex.FindStringSubmatch(line)
+		if deviceMatch == nil || speedMatch == nil || maxMatch == nil {
+			continue
+		}
+
+		speed, _ := strconv.Atoi(speedMatch[1])
+		maxSpeed, _ := strconv.Atoi(maxMatch[1])
+		if maxSpeed <= 0 {
+			continue
+		}
+		devices = append(devices, coolingDevice{name: deviceMatch[1], speed: speed, maxSpeed: maxSpeed})
+	}
+	return devices
+}
+
+// percentToLevel converts a speed percentage to the nearest cooling level
+func percentToLevel(percent, maxSpeed int) int {
+	return int(math.Round(float64(percent) * float64(maxSpeed) / 100))
+}
+
+// levelToPercent converts a cooling level to a speed percentage
+func levelToPercent(level, maxSpeed int) int {
+	if level > maxSpeed {
+		level = maxSpeed
+	}
+	return int(math.Round(float64(level) * 100 / float64(maxSpeed)))
+}
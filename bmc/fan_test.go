@@ -0,0 +1,101 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const coolingStatusOutput = "device: fan0, speed: 1, max speed: 4\n"
+
+func TestSetFanSpeed(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		percent int
+		want    string
+	}{
+		{0, "tpi cooling set --device fan0 --speed 0"},
+		{30, "tpi cooling set --device fan0 --speed 1"},
+		{50, "tpi cooling set --device fan0 --speed 2"},
+		{65, "tpi cooling set --device fan0 --speed 3"},
+		{100, "tpi cooling set --device fan0 --speed 4"},
+	}
+	for _, tt := range tests {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi cooling status"] = mockResponse{Stdout: coolingStatusOutput}
+		executor.ResponseMap[tt.want] = mockResponse{}
+
+		if err := newBMC(executor).SetFanSpeed(ctx, tt.percent); err != nil {
+			t.Errorf("SetFanSpeed(%d) error = %v", tt.percent, err)
+			continue
+		}
+		if got := executor.Commands[len(executor.Commands)-1]; got != tt.want {
+			t.Errorf("SetFanSpeed(%d) ran %q, want %q", tt.percent, got, tt.want)
+		}
+	}
+
+	t.Run("Invalid percent", func(t *testing.T) {
+		executor := newMockExecutor()
+		b := newBMC(executor)
+		for _, percent := range []int{-1, 101} {
+			if err := b.SetFanSpeed(ctx, percent); err == nil {
+				t.Errorf("SetFanSpeed(%d) expected an error", percent)
+			}
+		}
+		if len(executor.Commands) != 0 {
+			t.Errorf("Commands run for an invalid percent: %v", executor.Commands)
+		}
+	})
+}
+
+func TestGetFanSpeed(t *testing.T) {
+	executor := newMockExecutor()
+	executor.ResponseMap["tpi cooling status"] = mockResponse{Stdout: "device: fan0, speed: 3, max speed: 4\n"}
+
+	percent, err := newBMC(executor).GetFanSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("GetFanSpeed() error = %v", err)
+	}
+	if percent != 75 {
+		t.Errorf("GetFanSpeed() = %d, want 75", percent)
+	}
+}
+
+func TestFanControlNotSupported(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Firmware without cooling command", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi cooling status"] = mockResponse{
+			Stderr: "error: unrecognized subcommand 'cooling'",
+			Err:    errors.New("exit status 2"),
+		}
+		b := newBMC(executor)
+
+		if _, err := b.GetFanSpeed(ctx); !errors.Is(err, ErrFanControlNotSupported) {
+			t.Errorf("GetFanSpeed() error = %v, want ErrFanControlNotSupported", err)
+		}
+		if err := b.SetFanSpeed(ctx, 50); !errors.Is(err, ErrFanControlNotSupported) {
+			t.Errorf("SetFanSpeed() error = %v, want ErrFanControlNotSupported", err)
+		}
+	})
+
+	t.Run("No cooling device", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi cooling status"] = mockResponse{Stdout: "\n"}
+
+		if _, err := newBMC(executor).GetFanSpeed(ctx); !errors.Is(err, ErrFanControlNotSupported) {
+			t.Errorf("GetFanSpeed() error = %v, want ErrFanControlNotSupported", err)
+		}
+	})
+
+	t.Run("Other failures are not mistaken for missing support", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi cooling status"] = mockResponse{Stderr: "connection refused", Err: errors.New("exit status 1")}
+
+		if _, err := newBMC(executor).GetFanSpeed(ctx); err == nil || errors.Is(err, ErrFanControlNotSupported) {
+			t.Errorf("GetFanSpeed() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package bmc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy controls how the SSH host key of the BMC is verified
+type HostKeyPolicy string
+
+const (
+	// HostKeyVerify only accepts hosts whose key is in the known hosts file (default)
+	HostKeyVerify HostKeyPolicy = "verify"
+	// HostKeyTrustOnFirstUse adds the key of unknown hosts to the known hosts
+	// file on first connection, and verifies it on later connections
+	HostKeyTrustOnFirstUse HostKeyPolicy = "tofu"
+	// HostKeyInsecure accepts any host key. It is open to man-in-the-middle
+	// attacks and only meant for isolated lab networks.
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+var (
+	// ErrHostKeyMismatch is returned when a host presents a key different from
+	// the one recorded in the known hosts file, which may mean the connection
+	// is being intercepted
+	ErrHostKeyMismatch = errors.New("SSH host key does not match known hosts")
+	// ErrHostKeyUnknown is returned when a host is not in the known hosts file
+	// and the policy does not trust it on first use
+	ErrHostKeyUnknown = errors.New("SSH host key is not in known hosts")
+)
+
+// DefaultKnownHostsFile returns the known hosts file of the current user
+func DefaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// NewHostKeyCallback returns an SSH host key callback enforcing the policy
+// against the known hosts file. An empty policy means HostKeyVerify and an
+// empty file means DefaultKnownHostsFile.
+func NewHostKeyCallback(policy HostKeyPolicy, knownHostsFile string) (ssh.HostKeyCallback, error) {
+	switch policy {
+	case HostKeyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case "", HostKeyVerify, HostKeyTrustOnFirstUse:
+	default:
+		return nil, fmt.Errorf("unsupported host key policy: %s", policy)
+	}
+
+	if knownHostsFile == "" {
+		knownHostsFile = DefaultKnownHostsFile()
+		if knownHostsFile == "" {
+			return nil, fmt.Errorf("no known hosts file configured and no home directory to default to")
+		}
+	}
+
+	// A missing file knows no host, TOFU creates it on first use
+	if _, err := os.Stat(knownHostsFile); os.IsNotExist(err) {
+		if policy != HostKeyTrustOnFirstUse {
+			return nil, fmt.Errorf("%w: known hosts file %s does not exist", ErrHostKeyUnknown, knownHostsFile)
+		}
+		if err := os.MkdirAll(filepath.Dir(knownHostsFile), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create known hosts directory: %w", err)
+		}
+		if err := os.WriteFile(knownHostsFile, nil, 0600); err != nil {
+			return nil, fmt.Errorf("failed to create known hosts file: %w", err)
+		}
+	}
+
+	known, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts file %s: %w", knownHostsFile, err)
+	}
+
+	// Keys trusted on first use since the file was loaded
+	var mu sync.Mutex
+	trusted := make(map[string]ssh.PublicKey)
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			var want []string
+			for _, k := range keyErr.Want {
+				want = append(want, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(k.Key), k.Filename, k.Line))
+			}
+			return fmt.Errorf("%w: %s presented %s key %s, known hosts expect %s",
+				ErrHostKeyMismatch, hostname, key.Type(), ssh.FingerprintSHA256(key), strings.Join(want, ", "))
+		}
+
+		if policy != HostKeyTrustOnFirstUse {
+			return fmt.Errorf("%w: %s presented %s key %s, add it to %s or trust it on first use",
+				ErrHostKeyUnknown, hostname, key.Type(), ssh.FingerprintSHA256(key), knownHostsFile)
+		}
+
+		address := knownhosts.Normalize(hostname)
+		mu.Lock()
+		defer mu.Unlock()
+		if previous, ok := trusted[address]; ok {
+			if string(previous.Marshal()) != string(key.Marshal()) {
+				return fmt.Errorf("%w: %s presented %s key %s, trusted on first use as %s",
+					ErrHostKeyMismatch, hostname, key.Type(), ssh.FingerprintSHA256(key), ssh.FingerprintSHA256(previous))
+			}
+			return nil
+		}
+
+		f, err := os.OpenFile(knownHostsFile, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open known hosts file: %w", err)
+		}
+		defer f.Close()
+		if _, err := fmt.Fprintln(f, knownhosts.Line([]string{address}, key)); err != nil {
+			return fmt.Errorf("failed to record host key in %s: %w", knownHostsFile, err)
+		}
+		trusted[address] = key
+		return nil
+	}, nil
+}
+
+// sshHostKeyOptions returns the ssh command line options applying the policy
+func sshHostKeyOptions(policy HostKeyPolicy, knownHostsFile string) []string {
+	var options []string
+	switch policy {
+	case HostKeyInsecure:
+		return []string{"-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null"}
+	case HostKeyTrustOnFirstUse:
+		options = []string{"-o", "StrictHostKeyChecking=accept-new"}
+	default:
+		options = []string{"-o", "StrictHostKeyChecking=yes"}
+	}
+	if knownHostsFile != "" {
+		options = append(options, "-o", "UserKnownHostsFile="+knownHostsFile)
+	}
+	return options
+}
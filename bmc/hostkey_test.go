@@ -0,0 +1,185 @@
+package bmc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newHostKey generates an ed25519 SSH host key
+func newHostKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+// startFakeSSHServer accepts SSH connections authenticated with the password
+// "secret", presenting hostKey, and returns its address
+func startFakeSSHServer(t *testing.T, hostKey ssh.Signer) (host string, port int) {
+	t.Helper()
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for channel := range channels {
+					channel.Reject(ssh.Prohibited, "no sessions")
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// writeKnownHosts writes a known hosts file trusting key for host:port
+func writeKnownHosts(t *testing.T, host string, port int, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(host, strconv.Itoa(port)))}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write known hosts: %v", err)
+	}
+	return path
+}
+
+func TestSSHExecutorHostKeyVerification(t *testing.T) {
+	hostKey := newHostKey(t)
+	host, port := startFakeSSHServer(t, hostKey)
+
+	dial := func(policy HostKeyPolicy, knownHosts string) error {
+		executor := NewSSHExecutor(host, port, "root", "secret").WithHostKeyPolicy(policy, knownHosts)
+		conn, err := executor.dial(context.Background())
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	t.Run("Known host key is accepted", func(t *testing.T) {
+		knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+		if err := dial("", knownHosts); err != nil {
+			t.Errorf("dial() error = %v", err)
+		}
+	})
+
+	t.Run("Mismatching host key is rejected", func(t *testing.T) {
+		knownHosts := writeKnownHosts(t, host, port, newHostKey(t).PublicKey())
+		err := dial(HostKeyVerify, knownHosts)
+		if !errors.Is(err, ErrHostKeyMismatch) {
+			t.Fatalf("dial() error = %v, want ErrHostKeyMismatch", err)
+		}
+		if !strings.Contains(err.Error(), ssh.FingerprintSHA256(hostKey.PublicKey())) {
+			t.Errorf("dial() error = %v, want the presented key fingerprint", err)
+		}
+
+		// Trusting on first use never overrides a recorded key
+		if err := dial(HostKeyTrustOnFirstUse, knownHosts); !errors.Is(err, ErrHostKeyMismatch) {
+			t.Errorf("dial() with TOFU error = %v, want ErrHostKeyMismatch", err)
+		}
+	})
+
+	t.Run("Unknown host is rejected", func(t *testing.T) {
+		knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+		if err := os.WriteFile(knownHosts, nil, 0600); err != nil {
+			t.Fatalf("Failed to write known hosts: %v", err)
+		}
+		if err := dial(HostKeyVerify, knownHosts); !errors.Is(err, ErrHostKeyUnknown) {
+			t.Errorf("dial() error = %v, want ErrHostKeyUnknown", err)
+		}
+		if err := dial(HostKeyVerify, filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrHostKeyUnknown) {
+			t.Errorf("dial() without known hosts file error = %v, want ErrHostKeyUnknown", err)
+		}
+	})
+
+	t.Run("Trust on first use records the key", func(t *testing.T) {
+		knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+		if err := dial(HostKeyTrustOnFirstUse, knownHosts); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+
+		// The recorded key is verified on later connections
+		if err := dial(HostKeyVerify, knownHosts); err != nil {
+			t.Errorf("dial() with the recorded key error = %v", err)
+		}
+		data, _ := os.ReadFile(knownHosts)
+		if lines := strings.Count(string(data), "\n"); lines != 1 {
+			t.Errorf("Known hosts holds %d lines, want 1:\n%s", lines, data)
+		}
+	})
+
+	t.Run("Insecure policy accepts any key", func(t *testing.T) {
+		knownHosts := writeKnownHosts(t, host, port, newHostKey(t).PublicKey())
+		if err := dial(HostKeyInsecure, knownHosts); err != nil {
+			t.Errorf("dial() error = %v", err)
+		}
+	})
+
+	t.Run("Unsupported policy", func(t *testing.T) {
+		if err := dial("sometimes", ""); err == nil {
+			t.Error("dial() expected an error for an unsupported policy")
+		}
+	})
+}
+
+func TestSSHHostKeyOptions(t *testing.T) {
+	tests := []struct {
+		policy     HostKeyPolicy
+		knownHosts string
+		want       string
+	}{
+		{"", "", "-o StrictHostKeyChecking=yes"},
+		{HostKeyVerify, "/etc/turingpi/known_hosts", "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=/etc/turingpi/known_hosts"},
+		{HostKeyTrustOnFirstUse, "", "-o StrictHostKeyChecking=accept-new"},
+		{HostKeyInsecure, "/etc/turingpi/known_hosts", "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(sshHostKeyOptions(tt.policy, tt.knownHosts), " "); got != tt.want {
+			t.Errorf("sshHostKeyOptions(%q, %q) = %q, want %q", tt.policy, tt.knownHosts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidroman0O/turingpi/state"
+)
+
+// ErrMetricNotAvailable is returned when a node metric cannot be read,
+// either because the node is not reachable or the BMC exposes no sensor for it
+var ErrMetricNotAvailable = errors.New("metric not available")
+
+// MetricUptime is the node property storing uptime samples
+const MetricUptime = "uptimeSeconds"
+
+var (
+	uptimeDaysRegex    = regexp.MustCompile(`^(\d+)\s+days?$`)
+	uptimeClockRegex   = regexp.MustCompile(`^(\d+):(\d{2})$`)
+	uptimeHoursRegex   = regexp.MustCompile(`^(\d+)\s+hours?$`)
+	uptimeMinutesRegex = regexp.MustCompile(`^(\d+)\s+min(ute)?s?$`)
+)
+
+// SetNodeExecutor implements BMC interface
+func (b *bmcImpl) SetNodeExecutor(nodeID int, executor CommandExecutor) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nodeExecutors[nodeID] = executor
+}
+
+// nodeExecutor returns the executor registered for a node
+func (b *bmcImpl) nodeExecutor(nodeID int) (CommandExecutor, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	executor, ok := b.nodeExecutors[nodeID]
+	return executor, ok
+}
+
+// GetNodeUptime implements BMC interface
+func (b *bmcImpl) GetNodeUptime(ctx context.Context, nodeID int) (time.Duration, error) {
+	if nodeID < 1 || nodeID > 4 {
+		return 0, invalidNodeIDError(nodeID)
+	}
+
+	executor, ok := b.nodeExecutor(nodeID)
+	if !ok {
+		return 0, fmt.Errorf("uptime of node %d: no node executor registered: %w", nodeID, ErrMetricNotAvailable)
+	}
+
+	// Uptime can only be read from a booted node
+	status, err := b.GetPowerStatus(ctx, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	if status.State != PowerStateOn {
+		return 0, fmt.Errorf("uptime of node %d: node is powered off: %w", nodeID, ErrMetricNotAvailable)
+	}
+
+	stdout, stderr, err := executor.ExecuteCommand("cat /proc/uptime 2>/dev/null || uptime")
+	if err != nil {
+		return 0, fmt.Errorf("uptime of node %d: %v (stderr: %s): %w", nodeID, err, stderr, ErrMetricNotAvailable)
+	}
+
+	uptime, err := parseUptime(stdout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse uptime of node %d: %w", nodeID, err)
+	}
+
+	b.recordSample(nodeID, MetricUptime, uptime.Seconds())
+	return uptime, nil
+}
+
+// GetNodePowerDraw implements BMC interface. The Turing Pi BMC reports
+// whether each node is powered but exposes no per-node power measurement,
+// neither through tpi nor through its API, so the draw is never available.
+func (b *bmcImpl) GetNodePowerDraw(ctx context.Context, nodeID int) (float64, error) {
+	if nodeID < 1 || nodeID > 4 {
+		return 0, invalidNodeIDError(nodeID)
+	}
+	return 0, fmt.Errorf("power draw of node %d: the BMC exposes no per-node power sensor: %w", nodeID, ErrMetricNotAvailable)
+}
+
+// recordSample stores a metric sample in the node properties when a state manager is configured
+func (b *bmcImpl) recordSample(nodeID int, metric string, value float64) {
+	if b.stateManager == nil {
+		return
+	}
+
+	sample := state.MetricSample{Timestamp: time.Now(), Value: value}
+	if err := state.RecordMetricSample(b.stateManager, state.NodeID(nodeID), metric, sample); err != nil {
+		// Samples are informational, never fail the measurement because of them
+		log.Printf("[BMC METRICS] Warning: failed to record %s sample for node %d: %v", metric, nodeID, err)
+	}
+}
+
+// parseUptime parses either the content of /proc/uptime ("12345.67 4567.89")
+// or the output of the uptime command ("10:15:01 up 3 days,  4:05,  1 user, ...")
+func parseUptime(output string) (time.Duration, error) {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return 0, fmt.Errorf("empty uptime output")
+	}
+
+	fields := strings.Fields(output)
+	if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	idx := strings.Index(output, "up ")
+	if idx < 0 {
+		return 0, fmt.Errorf("unexpected uptime format: %s", output)
+	}
+
+	var uptime time.Duration
+	var matched bool
+	for _, part := range strings.Split(output[idx+len("up "):], ",") {
+		part = strings.TrimSpace(part)
+		if strings.Contains(part, "user") || strings.Contains(part, "load average") {
+			break
+		}
+
+		switch {
+		case uptimeDaysRegex.MatchString(part):
+			days, _ := strconv.Atoi(uptimeDaysRegex.FindStringSubmatch(part)[1])
+			uptime += time.Duration(days) * 24 * time.Hour
+		case uptimeClockRegex.MatchString(part):
+			match := uptimeClockRegex.FindStringSubmatch(part)
+			hours, _ := strconv.Atoi(match[1])
+			minutes, _ := strconv.Atoi(match[2])
+			uptime += time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+		case uptimeHoursRegex.MatchString(part):
+			hours, _ := strconv.Atoi(uptimeHoursRegex.FindStringSubmatch(part)[1])
+			uptime += time.Duration(hours) * time.Hour
+		case uptimeMinutesRegex.MatchString(part):
+			minutes, _ := strconv.Atoi(uptimeMinutesRegex.FindStringSubmatch(part)[1])
+			uptime += time.Duration(minutes) * time.Minute
+		default:
+			return 0, fmt.Errorf("unexpected uptime component %q in: %s", part, output)
+		}
+		matched = true
+	}
+
+	if !matched {
+		return 0, fmt.Errorf("unexpected uptime format: %s", output)
+	}
+	return uptime, nil
+}
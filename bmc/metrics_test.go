@@ -0,0 +1,153 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/state"
+)
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   time.Duration
+	}{
+		{"proc uptime", "350735.47 1234567.89\n", 350735470 * time.Millisecond},
+		{"minutes", " 10:15:01 up 12 min,  1 user,  load average: 0.00, 0.01, 0.05\n", 12 * time.Minute},
+		{"hours and minutes", " 10:15:01 up  4:05,  2 users,  load average: 0.10, 0.05, 0.01", 4*time.Hour + 5*time.Minute},
+		{"days and clock", " 10:15:01 up 3 days,  4:05,  1 user,  load average: 0.00, 0.00, 0.00", 3*24*time.Hour + 4*time.Hour + 5*time.Minute},
+		{"one day and minutes", "10:15:01 up 1 day, 12 min,  load average: 0.00, 0.00, 0.00", 24*time.Hour + 12*time.Minute},
+		{"pretty", "up 2 hours, 3 minutes", 2*time.Hour + 3*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUptime(tt.output)
+			if err != nil {
+				t.Fatalf("parseUptime() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseUptime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, output := range []string{"", "garbage", "10:15:01 up forever, 1 user"} {
+		if _, err := parseUptime(output); err == nil {
+			t.Errorf("parseUptime(%q) expected an error", output)
+		}
+	}
+}
+
+func TestGetNodeUptime(t *testing.T) {
+	ctx := context.Background()
+
+	bmcExecutor := newMockExecutor()
+	bmcExecutor.ResponseMap["tpi power status"] = mockResponse{Stdout: "node1: On\nnode2: Off\nnode3: On\nnode4: Off\n"}
+
+	nodeExecutor := newMockExecutor()
+	nodeExecutor.ResponseMap["cat /proc/uptime 2>/dev/null || uptime"] = mockResponse{Stdout: "3600.00 7000.00\n"}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	manager, err := state.NewFileStateManager(statePath)
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	b := NewWithState(bmcExecutor, manager)
+	b.SetNodeExecutor(1, nodeExecutor)
+
+	t.Run("Booted node", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			uptime, err := b.GetNodeUptime(ctx, 1)
+			if err != nil {
+				t.Fatalf("GetNodeUptime() error = %v", err)
+			}
+			if uptime != time.Hour {
+				t.Errorf("GetNodeUptime() = %v, want 1h", uptime)
+			}
+		}
+
+		nodeState, err := manager.GetNodeState(1)
+		if err != nil {
+			t.Fatalf("GetNodeState() error = %v", err)
+		}
+		samples := nodeState.MetricSamples(MetricUptime)
+		if len(samples) != 2 {
+			t.Fatalf("Recorded %d uptime samples, want 2", len(samples))
+		}
+		if samples[0].Value != 3600 || samples[0].Timestamp.IsZero() {
+			t.Errorf("Unexpected sample %+v", samples[0])
+		}
+		if samples[1].Timestamp.Before(samples[0].Timestamp) {
+			t.Errorf("Samples are not ordered by time: %+v", samples)
+		}
+
+		// Samples survive a reload from the state file
+		reloaded, err := state.NewFileStateManager(statePath)
+		if err != nil {
+			t.Fatalf("Failed to reload state: %v", err)
+		}
+		nodeState, _ = reloaded.GetNodeState(1)
+		if got := nodeState.MetricSamples(MetricUptime); len(got) != 2 || got[1].Value != 3600 {
+			t.Errorf("Reloaded samples = %+v, want 2 samples", got)
+		}
+	})
+
+	t.Run("Concurrent samples are all kept", func(t *testing.T) {
+		before, _ := manager.GetNodeState(1)
+		recorded := len(before.MetricSamples(MetricUptime))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := b.GetNodeUptime(ctx, 1); err != nil {
+					t.Errorf("GetNodeUptime() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		after, _ := manager.GetNodeState(1)
+		if got := len(after.MetricSamples(MetricUptime)); got != recorded+10 {
+			t.Errorf("Recorded %d uptime samples, want %d", got, recorded+10)
+		}
+	})
+
+	t.Run("Powered off node", func(t *testing.T) {
+		b.SetNodeExecutor(2, nodeExecutor)
+		if _, err := b.GetNodeUptime(ctx, 2); !errors.Is(err, ErrMetricNotAvailable) {
+			t.Errorf("GetNodeUptime() error = %v, want ErrMetricNotAvailable", err)
+		}
+	})
+
+	t.Run("No node executor", func(t *testing.T) {
+		if _, err := b.GetNodeUptime(ctx, 3); !errors.Is(err, ErrMetricNotAvailable) {
+			t.Errorf("GetNodeUptime() error = %v, want ErrMetricNotAvailable", err)
+		}
+	})
+}
+
+func TestGetNodePowerDraw(t *testing.T) {
+	executor := newMockExecutor()
+	b := New(executor)
+
+	for node := 1; node <= 4; node++ {
+		if _, err := b.GetNodePowerDraw(context.Background(), node); !errors.Is(err, ErrMetricNotAvailable) {
+			t.Errorf("GetNodePowerDraw(%d) error = %v, want ErrMetricNotAvailable", node, err)
+		}
+	}
+	if _, err := b.GetNodePowerDraw(context.Background(), 5); err == nil || errors.Is(err, ErrMetricNotAvailable) {
+		t.Errorf("GetNodePowerDraw(5) error = %v, want an invalid node error", err)
+	}
+	if len(executor.Commands) != 0 {
+		t.Errorf("GetNodePowerDraw() ran %v on the BMC, want nothing", executor.Commands)
+	}
+}
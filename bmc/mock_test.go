@@ -0,0 +1,47 @@
+package bmc
+
+import (
+	"errors"
+	"sync"
+)
+
+// mockExecutor replays recorded command outputs
+type mockExecutor struct {
+	mu          sync.Mutex
+	Commands    []string
+	ResponseMap map[string]mockResponse
+	// Handler, when set, answers commands before ResponseMap is consulted.
+	// It returns false to fall back to ResponseMap.
+	Handler func(command string) (mockResponse, bool)
+}
+
+// mockResponse is the recorded result of a command
+type mockResponse struct {
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+func newMockExecutor() *mockExecutor {
+	return &mockExecutor{
+		ResponseMap: make(map[string]mockResponse),
+	}
+}
+
+func (m *mockExecutor) ExecuteCommand(command string) (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Commands = append(m.Commands, command)
+	if m.Handler != nil {
+		if response, ok := m.Handler(command); ok {
+			return response.Stdout, response.Stderr, response.Err
+		}
+	}
+
+	response, ok := m.ResponseMap[command]
+	if !ok {
+		return "", "command not found", errors.New("exit status 127")
+	}
+	return response.Stdout, response.Stderr, response.Err
+}
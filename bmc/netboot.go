@@ -0,0 +1,111 @@
+package bmc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BootDevice is a device a node can boot from, named as in U-Boot's
+// boot_targets variable
+type BootDevice string
+
+const (
+	// BootDeviceEMMC is the node's eMMC
+	BootDeviceEMMC BootDevice = "mmc0"
+	// BootDeviceSD is the node's SD card
+	BootDeviceSD BootDevice = "mmc1"
+	// BootDeviceNVMe is the node's NVMe drive
+	BootDeviceNVMe BootDevice = "nvme0"
+	// BootDeviceUSB is a USB mass storage device
+	BootDeviceUSB BootDevice = "usb0"
+	// BootDevicePXE boots over the network with a PXE configuration
+	BootDevicePXE BootDevice = "pxe"
+	// BootDeviceDHCP boots over the network with the boot file given by DHCP
+	BootDeviceDHCP BootDevice = "dhcp"
+)
+
+// netBootOrder is the boot order used by NetBoot
+var netBootOrder = []BootDevice{BootDevicePXE, BootDeviceDHCP}
+
+const (
+	// ubootAutobootPrompt is printed by U-Boot while it counts down to autoboot
+	ubootAutobootPrompt = "Hit any key to stop autoboot"
+	// ubootPrompt is U-Boot's command prompt
+	ubootPrompt = "=> "
+)
+
+// validBootOrder checks a boot order names known devices, each once
+func validBootOrder(order []BootDevice) error {
+	if len(order) == 0 {
+		return fmt.Errorf("boot order cannot be empty")
+	}
+	seen := make(map[BootDevice]bool, len(order))
+	for _, device := range order {
+		switch device {
+		case BootDeviceEMMC, BootDeviceSD, BootDeviceNVMe, BootDeviceUSB, BootDevicePXE, BootDeviceDHCP:
+		default:
+			return fmt.Errorf("unknown boot device: %q", device)
+		}
+		if seen[device] {
+			return fmt.Errorf("boot device %s listed twice", device)
+		}
+		seen[device] = true
+	}
+	return nil
+}
+
+// SetBootOrder implements BMC interface
+func (b *bmcImpl) SetBootOrder(ctx context.Context, nodeID int, order []BootDevice) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+	if err := validBootOrder(order); err != nil {
+		return err
+	}
+
+	if err := b.bootWithOrder(ctx, nodeID, order, true); err != nil {
+		return fmt.Errorf("setting boot order of node %d: %w", nodeID, err)
+	}
+	return nil
+}
+
+// NetBoot implements BMC interface
+func (b *bmcImpl) NetBoot(ctx context.Context, nodeID int) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
+	if err := b.bootWithOrder(ctx, nodeID, netBootOrder, false); err != nil {
+		return fmt.Errorf("network boot of node %d: %w", nodeID, err)
+	}
+	return nil
+}
+
+// bootWithOrder power-cycles a node, stops U-Boot's autoboot over the UART
+// and boots with the given order, saved to the U-Boot environment when
+// persist is set. The BMC firmware has no boot order command of its own.
+func (b *bmcImpl) bootWithOrder(ctx context.Context, nodeID int, order []BootDevice, persist bool) error {
+	targets := make([]string, len(order))
+	for i, device := range order {
+		targets[i] = string(device)
+	}
+
+	steps := []InteractionStep{
+		{Expect: ubootAutobootPrompt, Send: " ", LogMsg: "Stopping autoboot"},
+		// U-Boot's setenv joins its arguments with spaces
+		{Expect: ubootPrompt, Send: "setenv boot_targets " + strings.Join(targets, " "), LogMsg: "Setting boot order"},
+	}
+	if persist {
+		steps = append(steps, InteractionStep{Expect: ubootPrompt, Send: "saveenv", LogMsg: "Saving boot order"})
+	}
+	steps = append(steps, InteractionStep{Expect: ubootPrompt, Send: "boot", LogMsg: "Booting"})
+
+	if err := b.HardReset(ctx, nodeID); err != nil {
+		return err
+	}
+	if _, err := b.ExpectAndSend(ctx, nodeID, steps, b.ubootTimeout); err != nil {
+		return fmt.Errorf("U-Boot over UART: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package bmc
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeUBoot emulates the UART of a node running U-Boot, powered by a
+// powerSimulator. Once powered on, the node counts down to autoboot and
+// answers each command with a prompt.
+type fakeUBoot struct {
+	power powerSimulator
+	// pending is the UART output not read yet
+	pending string
+	// stopped is set once autoboot has been interrupted
+	stopped bool
+	// commands records the commands run at the U-Boot prompt
+	commands []string
+	// silent makes the node never print anything
+	silent bool
+}
+
+func (u *fakeUBoot) handle(command string) (mockResponse, bool) {
+	if command == "tpi power on --node 1" && !u.silent {
+		u.pending += "U-Boot 2017.09 (Sep 12 2024)\nModel: Turing Machines RK1\nHit any key to stop autoboot:  3 "
+	}
+	if response, ok := u.power.handle(command); ok {
+		return response, true
+	}
+
+	switch {
+	case command == "tpi uart --node 1 get":
+		output := u.pending
+		u.pending = ""
+		return mockResponse{Stdout: output}, true
+	case strings.HasPrefix(command, `tpi uart --node 1 set -c "`):
+		input := strings.TrimSuffix(strings.TrimPrefix(command, `tpi uart --node 1 set -c "`), `"`)
+		input = strings.TrimSuffix(input, "\n")
+		if !u.stopped {
+			u.stopped = true
+		} else {
+			u.commands = append(u.commands, input)
+		}
+		u.pending += "\n" + ubootPrompt
+		return mockResponse{}, true
+	}
+	return mockResponse{}, false
+}
+
+func newUBootBMC(uboot *fakeUBoot) *bmcImpl {
+	uboot.power.state = PowerStateOn
+	executor := newMockExecutor()
+	executor.Handler = uboot.handle
+
+	b := newBMC(executor)
+	b.powerPollInterval = time.Millisecond
+	b.powerStateTimeout = 50 * time.Millisecond
+	b.ubootTimeout = time.Second
+	return b
+}
+
+func TestSetBootOrder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Order saved in the U-Boot environment", func(t *testing.T) {
+		uboot := &fakeUBoot{}
+		b := newUBootBMC(uboot)
+
+		if err := b.SetBootOrder(ctx, 1, []BootDevice{BootDeviceNVMe, BootDevicePXE, BootDeviceEMMC}); err != nil {
+			t.Fatalf("SetBootOrder() error = %v", err)
+		}
+		if want := []string{"off", "confirm-Off", "on", "confirm-On"}; !reflect.DeepEqual(uboot.power.events, want) {
+			t.Errorf("Power sequence = %v, want %v", uboot.power.events, want)
+		}
+		want := []string{"setenv boot_targets nvme0 pxe mmc0", "saveenv", "boot"}
+		if !reflect.DeepEqual(uboot.commands, want) {
+			t.Errorf("U-Boot commands = %q, want %q", uboot.commands, want)
+		}
+	})
+
+	t.Run("Invalid orders", func(t *testing.T) {
+		orders := map[string][]BootDevice{
+			"Empty":          nil,
+			"Unknown device": {BootDeviceEMMC, "floppy"},
+			"Duplicate":      {BootDevicePXE, BootDeviceEMMC, BootDevicePXE},
+		}
+		for name, order := range orders {
+			uboot := &fakeUBoot{}
+			if err := newUBootBMC(uboot).SetBootOrder(ctx, 1, order); err == nil {
+				t.Errorf("%s: SetBootOrder() succeeded", name)
+			}
+			if len(uboot.power.events) != 0 {
+				t.Errorf("%s: node was power-cycled: %v", name, uboot.power.events)
+			}
+		}
+
+		if err := newUBootBMC(&fakeUBoot{}).SetBootOrder(ctx, 5, []BootDevice{BootDevicePXE}); err == nil {
+			t.Error("SetBootOrder() of node 5 succeeded")
+		}
+	})
+}
+
+func TestNetBoot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Boots over the network once", func(t *testing.T) {
+		uboot := &fakeUBoot{}
+		if err := newUBootBMC(uboot).NetBoot(ctx, 1); err != nil {
+			t.Fatalf("NetBoot() error = %v", err)
+		}
+		// The order is not saved, the next boot uses the saved one again
+		want := []string{"setenv boot_targets pxe dhcp", "boot"}
+		if !reflect.DeepEqual(uboot.commands, want) {
+			t.Errorf("U-Boot commands = %q, want %q", uboot.commands, want)
+		}
+	})
+
+	t.Run("U-Boot never prompts", func(t *testing.T) {
+		uboot := &fakeUBoot{silent: true}
+		b := newUBootBMC(uboot)
+		b.ubootTimeout = 300 * time.Millisecond
+
+		err := b.NetBoot(ctx, 1)
+		if err == nil || !strings.Contains(err.Error(), ubootAutobootPrompt) {
+			t.Fatalf("NetBoot() error = %v, want a timeout waiting for autoboot", err)
+		}
+		if len(uboot.commands) != 0 {
+			t.Errorf("U-Boot commands sent: %q", uboot.commands)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NodeTransport selects how a NodeExecutor reaches its node
+type NodeTransport string
+
+const (
+	// NodeTransportAuto runs commands over SSH, falling back to the UART when
+	// connecting or logging in to the node fails, so the command did not
+	// start, or no SSH executor is set
+	NodeTransportAuto NodeTransport = "auto"
+	// NodeTransportSSH runs commands over SSH only
+	NodeTransportSSH NodeTransport = "ssh"
+	// NodeTransportUART runs commands over the UART only
+	NodeTransportUART NodeTransport = "uart"
+)
+
+// uartMarkerPrefix starts the lines delimiting the output of a command run
+// over the UART
+const uartMarkerPrefix = "tpi-uart"
+
+// NodeExecutor runs shell commands on a node, over SSH once it is reachable
+// on the network or over its UART before it is, such as while it is being
+// provisioned. It implements CommandExecutor and StatusExecutor, so it can be
+// registered with SetNodeExecutor.
+//
+// Over the UART, the node must be at a shell prompt with a user logged in.
+// The output of a command is delimited by markers echoed before and after
+// it, so stdout and stderr are both returned as stdout. Commands are run one
+// at a time.
+type NodeExecutor struct {
+	bmc       BMC
+	nodeID    int
+	ssh       CommandExecutor
+	transport NodeTransport
+	timeout   time.Duration
+
+	// uartMu serializes the commands sent over the UART
+	uartMu   sync.Mutex
+	sequence atomic.Uint64
+}
+
+// NewNodeExecutor creates an executor running commands on a node through
+// the given BMC. ssh is the executor connected to the node's address, nil
+// when the node is only reachable over the UART.
+func NewNodeExecutor(b BMC, nodeID int, ssh CommandExecutor) *NodeExecutor {
+	return &NodeExecutor{
+		bmc:       b,
+		nodeID:    nodeID,
+		ssh:       ssh,
+		transport: NodeTransportAuto,
+		timeout:   time.Minute,
+	}
+}
+
+// WithTransport selects how commands reach the node, NodeTransportAuto by
+// default
+func (e *NodeExecutor) WithTransport(transport NodeTransport) *NodeExecutor {
+	e.transport = transport
+	return e
+}
+
+// WithUARTTimeout sets how long a command run over the UART may take before
+// its output is given up on, one minute by default
+func (e *NodeExecutor) WithUARTTimeout(timeout time.Duration) *NodeExecutor {
+	e.timeout = timeout
+	return e
+}
+
+// ExecuteCommand implements CommandExecutor. A command exiting with a non-zero
+// code returns an error carrying it.
+func (e *NodeExecutor) ExecuteCommand(command string) (string, string, error) {
+	stdout, stderr, exitCode, err := e.ExecuteCommandStatus(command)
+	if err == nil && exitCode != 0 {
+		err = exitCodeError(exitCode)
+	}
+	return stdout, stderr, err
+}
+
+// ExecuteCommandStatus implements StatusExecutor. The error is set when the
+// command could not be run over any of the allowed transports.
+func (e *NodeExecutor) ExecuteCommandStatus(command string) (string, string, int, error) {
+	switch e.transport {
+	case NodeTransportSSH:
+		if e.ssh == nil {
+			return "", "", -1, fmt.Errorf("node %d: no SSH executor set", e.nodeID)
+		}
+		return commandStatus(e.ssh, command)
+	case NodeTransportUART:
+		return e.executeOverUART(command)
+	case NodeTransportAuto:
+		if e.ssh != nil {
+			stdout, stderr, exitCode, err := commandStatus(e.ssh, command)
+			// A command that started may have had effects, running it again
+			// over the UART could repeat them
+			if !errors.Is(err, ErrSSHConnect) {
+				return stdout, stderr, exitCode, err
+			}
+			log.Printf("[BMC UART] Node %d not reachable over SSH, falling back to the UART: %v", e.nodeID, err)
+		}
+		return e.executeOverUART(command)
+	default:
+		return "", "", -1, fmt.Errorf("unknown node transport %q", e.transport)
+	}
+}
+
+// executeOverUART types a command at the shell prompt of the node, between
+// echoes of a begin and an end marker, and returns the output in between
+func (e *NodeExecutor) executeOverUART(command string) (string, string, int, error) {
+	e.uartMu.Lock()
+	defer e.uartMu.Unlock()
+
+	id := fmt.Sprintf("%x-%d", time.Now().UnixNano(), e.sequence.Add(1))
+	begin := uartMarkerPrefix + "-begin-" + id
+	end := uartMarkerPrefix + "-end-" + id
+
+	// The markers are split by empty quotes, so the node echoing the command
+	// line back does not match them
+	line := fmt.Sprintf(`echo "%s""-begin-%s"; %s; echo "$? %s""-end-%s"`, uartMarkerPrefix, id, command, uartMarkerPrefix, id)
+	steps := []InteractionStep{
+		{Send: line, LogMsg: "Running command"},
+		{Expect: end},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	output, err := e.bmc.ExpectAndSend(ctx, e.nodeID, steps, e.timeout)
+	if err != nil {
+		return "", "", -1, fmt.Errorf("running command on node %d over UART: %w", e.nodeID, err)
+	}
+
+	stdout, exitCode, err := parseUARTCommandOutput(output, begin, end)
+	if err != nil {
+		return "", "", -1, fmt.Errorf("running command on node %d over UART: %w", e.nodeID, err)
+	}
+	return stdout, "", exitCode, nil
+}
+
+// parseUARTCommandOutput extracts the output of a command from the UART
+// output, between the line holding the begin marker and the one holding the
+// exit code followed by the end marker
+func parseUARTCommandOutput(output, begin, end string) (string, int, error) {
+	output = strings.ReplaceAll(output, "\r", "")
+
+	start := strings.Index(output, begin)
+	if start < 0 {
+		return "", 0, errors.New("command output not found")
+	}
+	output = output[start+len(begin):]
+	if newline := strings.IndexByte(output, '\n'); newline >= 0 {
+		output = output[newline+1:]
+	} else {
+		output = ""
+	}
+
+	stop := strings.Index(output, end)
+	if stop < 0 {
+		return "", 0, errors.New("end of command output not found")
+	}
+	body, status := "", output[:stop]
+	if newline := strings.LastIndexByte(status, '\n'); newline >= 0 {
+		body, status = status[:newline], status[newline+1:]
+	}
+
+	exitCode, err := strconv.Atoi(strings.TrimSpace(status))
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid exit code %q", strings.TrimSpace(status))
+	}
+	// Trim trailing newlines as the SSH executor does
+	return strings.TrimSuffix(body, "\n"), exitCode, nil
+}
+
+// exitCodeError reports a command exiting with a non-zero code
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// ExitCode returns the exit code of the command
+func (e exitCodeError) ExitCode() int {
+	return int(e)
+}
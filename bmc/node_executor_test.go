@@ -0,0 +1,213 @@
+package bmc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNodeShell emulates a shell logged in on the UART of node 2. It echoes
+// the lines it receives, as a terminal does, and runs the commands wrapped by
+// NodeExecutor from a table of outputs and exit codes.
+type fakeNodeShell struct {
+	pending string
+	// outputs maps each command to its output, unknown commands fail
+	outputs map[string]string
+	codes   map[string]int
+	// ran records the commands run
+	ran []string
+}
+
+var (
+	uartUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\$`, `$`, "\\`", "`")
+	wrappedRegex  = regexp.MustCompile(`^echo "([^"]*)""(-begin-[^"]*)"; (.*); echo "\$\? ([^"]*)""(-end-[^"]*)"$`)
+)
+
+func (s *fakeNodeShell) handle(command string) (mockResponse, bool) {
+	const setPrefix = `tpi uart --node 2 set -c "`
+	switch {
+	case command == "tpi uart --node 2 get":
+		output := s.pending
+		s.pending = ""
+		return mockResponse{Stdout: output}, true
+	case strings.HasPrefix(command, setPrefix):
+		line := uartUnescaper.Replace(strings.TrimSuffix(strings.TrimPrefix(command, setPrefix), `"`))
+		line = strings.TrimSuffix(line, "\n")
+		s.pending += line + "\r\n"
+
+		match := wrappedRegex.FindStringSubmatch(line)
+		if match == nil {
+			s.pending += "sh: syntax error\r\nroot@node2:~# "
+			return mockResponse{}, true
+		}
+		cmd := match[3]
+		s.ran = append(s.ran, cmd)
+		output, ok := s.outputs[cmd]
+		code := s.codes[cmd]
+		if !ok {
+			output, code = "sh: "+cmd+": not found\n", 127
+		}
+		s.pending += match[1] + match[2] + "\r\n" + strings.ReplaceAll(output, "\n", "\r\n")
+		s.pending += fmt.Sprintf("%d %s%s\r\nroot@node2:~# ", code, match[4], match[5])
+		return mockResponse{}, true
+	}
+	return mockResponse{}, false
+}
+
+func newFakeNodeShellBMC(shell *fakeNodeShell) *bmcImpl {
+	executor := newMockExecutor()
+	executor.Handler = shell.handle
+	return newBMC(executor)
+}
+
+func TestNodeExecutorUART(t *testing.T) {
+	shell := &fakeNodeShell{
+		outputs: map[string]string{
+			"uname -sr":                 "Linux 5.10.160-rockchip\n",
+			`echo "$HOME" ` + "`id -u`": "/root 0\n",
+			"ip -4 addr show eth0":      "",
+			"false":                     "",
+		},
+		codes: map[string]int{"false": 1},
+	}
+	var executor CommandExecutor = NewNodeExecutor(newFakeNodeShellBMC(shell), 2, nil).WithUARTTimeout(time.Second)
+
+	stdout, stderr, err := executor.ExecuteCommand("uname -sr")
+	if err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+	if stdout != "Linux 5.10.160-rockchip" || stderr != "" {
+		t.Errorf("ExecuteCommand() = %q, %q", stdout, stderr)
+	}
+
+	// Quotes, variables and substitutions reach the node shell as typed
+	quoted := `echo "$HOME" ` + "`id -u`"
+	if stdout, _, err := executor.ExecuteCommand(quoted); err != nil || stdout != "/root 0" {
+		t.Errorf("ExecuteCommand(%s) = %q, %v", quoted, stdout, err)
+	}
+
+	if stdout, _, err := executor.ExecuteCommand("ip -4 addr show eth0"); err != nil || stdout != "" {
+		t.Errorf("ExecuteCommand() with no output = %q, %v", stdout, err)
+	}
+
+	_, _, err = executor.ExecuteCommand("false")
+	if code, ok := exitCodeOf(err); !ok || code != 1 {
+		t.Errorf("ExecuteCommand(false) error = %v, want exit status 1", err)
+	}
+
+	stdout, _, code, err := executor.(StatusExecutor).ExecuteCommandStatus("missing")
+	if err != nil || code != 127 || !strings.Contains(stdout, "not found") {
+		t.Errorf("ExecuteCommandStatus(missing) = %q, %d, %v", stdout, code, err)
+	}
+
+	if want := []string{"uname -sr", quoted, "ip -4 addr show eth0", "false", "missing"}; strings.Join(shell.ran, "|") != strings.Join(want, "|") {
+		t.Errorf("Node ran %q, want %q", shell.ran, want)
+	}
+}
+
+func TestNodeExecutorSSH(t *testing.T) {
+	hostKey := newHostKey(t)
+	keyPEM, publicKey := newPrivateKey(t, "")
+	host, port := startFakeExecServer(t, hostKey, publicKey)
+	knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+	ssh := NewSSHExecutor(host, port, "root", "").WithHostKeyPolicy(HostKeyVerify, knownHosts).WithPrivateKey(keyPEM, "")
+
+	// An unreachable address, the listener being closed right away
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	unreachable := NewSSHExecutor("127.0.0.1", closedPort, "root", "").WithHostKeyPolicy(HostKeyVerify, knownHosts).WithPrivateKey(keyPEM, "")
+
+	shell := &fakeNodeShell{outputs: map[string]string{"hostname": "node2\n"}}
+	b := newFakeNodeShellBMC(shell)
+
+	t.Run("Reachable node runs over SSH", func(t *testing.T) {
+		var executor CommandExecutor = NewNodeExecutor(b, 2, ssh)
+		stdout, _, err := executor.ExecuteCommand("hostname")
+		if err != nil || stdout != "ran: hostname" {
+			t.Errorf("ExecuteCommand() = %q, %v", stdout, err)
+		}
+
+		_, _, err = executor.ExecuteCommand("exit 3")
+		if code, ok := exitCodeOf(err); !ok || code != 3 {
+			t.Errorf("ExecuteCommand(exit 3) error = %v, want exit status 3", err)
+		}
+		if len(shell.ran) != 0 {
+			t.Errorf("Node ran %q over the UART", shell.ran)
+		}
+	})
+
+	t.Run("Unreachable node falls back to the UART", func(t *testing.T) {
+		executor := NewNodeExecutor(b, 2, unreachable).WithUARTTimeout(time.Second)
+		stdout, _, err := executor.ExecuteCommand("hostname")
+		if err != nil || stdout != "node2" {
+			t.Errorf("ExecuteCommand() = %q, %v", stdout, err)
+		}
+	})
+
+	t.Run("Command failing over SSH is not run again over the UART", func(t *testing.T) {
+		shell.ran = nil
+		executor := NewNodeExecutor(b, 2, ssh).WithUARTTimeout(time.Second)
+		_, _, code, err := executor.ExecuteCommandStatus("hangup")
+		if err == nil || errors.Is(err, ErrSSHConnect) || code != -1 {
+			t.Errorf("ExecuteCommandStatus(hangup) = %d, %v, want the SSH session error", code, err)
+		}
+		if len(shell.ran) != 0 {
+			t.Errorf("Node ran %q over the UART", shell.ran)
+		}
+	})
+
+	t.Run("SSH transport does not fall back", func(t *testing.T) {
+		executor := NewNodeExecutor(b, 2, unreachable).WithTransport(NodeTransportSSH)
+		_, _, code, err := executor.ExecuteCommandStatus("hostname")
+		if !errors.Is(err, ErrSSHConnect) || code != -1 {
+			t.Errorf("ExecuteCommandStatus() = %d, %v, want a connection error", code, err)
+		}
+
+		_, _, err = NewNodeExecutor(b, 2, nil).WithTransport(NodeTransportSSH).ExecuteCommand("hostname")
+		if err == nil {
+			t.Error("ExecuteCommand() without SSH executor succeeded")
+		}
+	})
+
+	t.Run("UART transport skips SSH", func(t *testing.T) {
+		executor := NewNodeExecutor(b, 2, ssh).WithTransport(NodeTransportUART).WithUARTTimeout(time.Second)
+		if stdout, _, err := executor.ExecuteCommand("hostname"); err != nil || stdout != "node2" {
+			t.Errorf("ExecuteCommand() = %q, %v", stdout, err)
+		}
+	})
+}
+
+func TestParseUARTCommandOutput(t *testing.T) {
+	const begin, end = "tpi-uart-begin-1", "tpi-uart-end-1"
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		code    int
+		wantErr bool
+	}{
+		{"Output", "echo ...\r\ntpi-uart-begin-1\r\na\r\nb\r\n0 tpi-uart-end-1\r\n# ", "a\nb", 0, false},
+		{"No output", "tpi-uart-begin-1\n2 tpi-uart-end-1\n", "", 2, false},
+		{"Missing begin", "0 tpi-uart-end-1\n", "", 0, true},
+		{"Missing end", "tpi-uart-begin-1\nout\n", "", 0, true},
+		{"Invalid code", "tpi-uart-begin-1\nx tpi-uart-end-1\n", "", 0, true},
+	}
+	for _, tt := range tests {
+		got, code, err := parseUARTCommandOutput(tt.output, begin, end)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || code != tt.code {
+			t.Errorf("%s: = %q, %d, want %q, %d", tt.name, got, code, tt.want, tt.code)
+		}
+	}
+}
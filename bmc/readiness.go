@@ -0,0 +1,127 @@
+package bmc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/davidroman0O/turingpi/state"
+)
+
+// Readiness tells how far a node is from being usable. Each probe runs only
+// when the previous one passed, so a node powered off is not probed further.
+type Readiness struct {
+	// PoweredOn reports the BMC powers the node
+	PoweredOn bool
+	// NetworkReachable reports the node answers on its address, even if only
+	// by refusing the connection to its SSH port
+	NetworkReachable bool
+	// SSHAvailable reports the node's SSH server answers, and accepts the
+	// configured credentials if any
+	SSHAvailable bool
+}
+
+// Ready reports whether the node is powered on and usable over SSH
+func (r Readiness) Ready() bool {
+	return r.PoweredOn && r.NetworkReachable && r.SSHAvailable
+}
+
+// IsNodeReady implements BMC interface
+func (b *bmcImpl) IsNodeReady(ctx context.Context, nodeID int, sshConfig SSHConfig) (Readiness, error) {
+	var readiness Readiness
+	if nodeID < 1 || nodeID > 4 {
+		return readiness, invalidNodeIDError(nodeID)
+	}
+
+	status, err := b.GetPowerStatus(ctx, nodeID)
+	if err != nil {
+		return readiness, fmt.Errorf("readiness of node %d: %w", nodeID, err)
+	}
+	if status.State != PowerStateOn {
+		return readiness, nil
+	}
+	readiness.PoweredOn = true
+
+	if sshConfig.Host == "" {
+		sshConfig.Host = b.nodeAddress(nodeID)
+		if sshConfig.Host == "" {
+			return readiness, fmt.Errorf("readiness of node %d: no address to probe", nodeID)
+		}
+	}
+	if sshConfig.Port == 0 {
+		sshConfig.Port = 22
+	}
+
+	dialer := net.Dialer{Timeout: b.readinessTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(sshConfig.Host, strconv.Itoa(sshConfig.Port)))
+	if err != nil {
+		if ctx.Err() != nil {
+			return readiness, ctx.Err()
+		}
+		// Only a host that is up refuses the connection
+		readiness.NetworkReachable = errors.Is(err, syscall.ECONNREFUSED)
+		return readiness, nil
+	}
+	readiness.NetworkReachable = true
+
+	banner := readSSHBanner(conn, b.readinessTimeout)
+	conn.Close()
+	if !banner {
+		return readiness, nil
+	}
+
+	// Logging in is only tried with credentials to log in with
+	if sshConfig.Password == "" && sshConfig.PrivateKey == "" && sshConfig.PrivateKeyPath == "" {
+		readiness.SSHAvailable = true
+		return readiness, nil
+	}
+	// A server stalling the login must not hold the probe longer than the
+	// others
+	sshConfig.ConnectTimeout = b.readinessTimeout
+	loginCtx, cancel := context.WithTimeout(ctx, b.readinessTimeout)
+	defer cancel()
+	executor := &SSHExecutor{config: sshConfig}
+	_, _, _, err = executor.ExecuteCommandStatusContext(loginCtx, "true")
+	if ctx.Err() != nil {
+		return readiness, ctx.Err()
+	}
+	readiness.SSHAvailable = err == nil
+	return readiness, nil
+}
+
+// readSSHBanner reports whether the server on conn identifies as an SSH
+// server within timeout
+func readSSHBanner(conn net.Conn, timeout time.Duration) bool {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(conn)
+	// Servers may send other lines before their identification
+	for i := 0; i < 10; i++ {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "SSH-") {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// nodeAddress returns the IP address recorded in the state of a node, empty
+// when unknown
+func (b *bmcImpl) nodeAddress(nodeID int) string {
+	if b.stateManager == nil {
+		return ""
+	}
+	nodeState, err := b.stateManager.GetNodeState(state.NodeID(nodeID))
+	if err != nil || nodeState == nil {
+		return ""
+	}
+	return nodeState.IPAddress
+}
@@ -0,0 +1,195 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/state"
+)
+
+// newReadinessBMC creates a BMC powering nodes 1 and 3, node 2 being off
+func newReadinessBMC() *bmcImpl {
+	executor := newMockExecutor()
+	executor.ResponseMap["tpi power status"] = mockResponse{Stdout: "node1: On\nnode2: Off\nnode3: On\nnode4: On"}
+	b := newBMC(executor)
+	b.readinessTimeout = time.Second
+	return b
+}
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+// startHTTPServer listens on a local port answering like a web server, not
+// an SSH server
+func startHTTPServer(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// startStallingSSHServer listens on a local port sending an SSH banner and
+// then nothing, like a server stuck before the key exchange
+func startStallingSSHServer(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			go func() {
+				<-done
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestIsNodeReady(t *testing.T) {
+	ctx := context.Background()
+
+	hostKey := newHostKey(t)
+	keyPEM, publicKey := newPrivateKey(t, "")
+	otherPEM, _ := newPrivateKey(t, "")
+	host, port := startFakeExecServer(t, hostKey, publicKey)
+	knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+
+	withKey := func(key []byte) SSHConfig {
+		return SSHConfig{
+			Host:           host,
+			Port:           port,
+			User:           "root",
+			PrivateKey:     string(key),
+			HostKeyPolicy:  HostKeyVerify,
+			KnownHostsFile: knownHosts,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		nodeID int
+		config SSHConfig
+		want   Readiness
+	}{
+		{"All ready", 1, withKey(keyPEM), Readiness{PoweredOn: true, NetworkReachable: true, SSHAvailable: true}},
+		{"SSH server without credentials", 1, SSHConfig{Host: host, Port: port}, Readiness{PoweredOn: true, NetworkReachable: true, SSHAvailable: true}},
+		{"Powered without SSH", 3, SSHConfig{Host: "127.0.0.1", Port: closedPort(t)}, Readiness{PoweredOn: true, NetworkReachable: true}},
+		{"Port open but not SSH", 3, SSHConfig{Host: "127.0.0.1", Port: startHTTPServer(t)}, Readiness{PoweredOn: true, NetworkReachable: true}},
+		{"Credentials refused", 1, withKey(otherPEM), Readiness{PoweredOn: true, NetworkReachable: true}},
+		{"Powered off", 2, withKey(keyPEM), Readiness{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness, err := newReadinessBMC().IsNodeReady(ctx, tt.nodeID, tt.config)
+			if err != nil {
+				t.Fatalf("IsNodeReady() error = %v", err)
+			}
+			if readiness != tt.want {
+				t.Errorf("IsNodeReady() = %+v, want %+v", readiness, tt.want)
+			}
+			if readiness.Ready() != (tt.want == Readiness{PoweredOn: true, NetworkReachable: true, SSHAvailable: true}) {
+				t.Errorf("Ready() = %v for %+v", readiness.Ready(), readiness)
+			}
+		})
+	}
+
+	t.Run("Address recorded in the node state", func(t *testing.T) {
+		manager, err := state.NewFileStateManager(filepath.Join(t.TempDir(), "state.json"))
+		if err != nil {
+			t.Fatalf("NewFileStateManager() error = %v", err)
+		}
+		if err := manager.UpdateNodeState(&state.NodeState{NodeID: 1, IPAddress: host}); err != nil {
+			t.Fatalf("UpdateNodeState() error = %v", err)
+		}
+
+		b := newReadinessBMC()
+		b.stateManager = manager
+		config := withKey(keyPEM)
+		config.Host = ""
+		if readiness, err := b.IsNodeReady(ctx, 1, config); err != nil || !readiness.Ready() {
+			t.Errorf("IsNodeReady() = %+v, %v, want ready", readiness, err)
+		}
+
+		if _, err := b.IsNodeReady(ctx, 3, config); err == nil {
+			t.Error("IsNodeReady() without a known address succeeded")
+		}
+	})
+
+	t.Run("Login stalled by the server", func(t *testing.T) {
+		config := withKey(keyPEM)
+		config.Port = startStallingSSHServer(t)
+
+		start := time.Now()
+		readiness, err := newReadinessBMC().IsNodeReady(ctx, 1, config)
+		if err != nil {
+			t.Fatalf("IsNodeReady() error = %v", err)
+		}
+		if want := (Readiness{PoweredOn: true, NetworkReachable: true}); readiness != want {
+			t.Errorf("IsNodeReady() = %+v, want %+v", readiness, want)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("IsNodeReady() took %v, want the login bounded by the probe timeout", elapsed)
+		}
+
+		// Cancelling the caller's context stops the login
+		b := newReadinessBMC()
+		b.readinessTimeout = time.Minute
+		cancelCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		start = time.Now()
+		if _, err := b.IsNodeReady(cancelCtx, 1, config); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("IsNodeReady() error = %v, want the context error", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("IsNodeReady() took %v after the context was done", elapsed)
+		}
+	})
+
+	t.Run("Power status unavailable", func(t *testing.T) {
+		b := newBMC(newMockExecutor())
+		if _, err := b.IsNodeReady(ctx, 1, withKey(keyPEM)); err == nil {
+			t.Error("IsNodeReady() succeeded without power status")
+		}
+		if _, err := b.IsNodeReady(ctx, 5, withKey(keyPEM)); err == nil {
+			t.Error("IsNodeReady() accepted node 5")
+		}
+	})
+}
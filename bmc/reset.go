@@ -0,0 +1,64 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPowerStateNotReached is returned when a node does not reach the expected
+// power state in time
+var ErrPowerStateNotReached = errors.New("node did not reach the expected power state")
+
+// HardReset implements BMC interface
+func (b *bmcImpl) HardReset(ctx context.Context, nodeID int) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
+	if err := b.PowerOff(ctx, nodeID); err != nil {
+		return fmt.Errorf("hard reset of node %d: %w", nodeID, err)
+	}
+	if err := b.waitForPowerState(ctx, nodeID, PowerStateOff); err != nil {
+		return fmt.Errorf("hard reset of node %d: %w", nodeID, err)
+	}
+
+	if err := b.PowerOn(ctx, nodeID); err != nil {
+		return fmt.Errorf("hard reset of node %d: %w", nodeID, err)
+	}
+	if err := b.waitForPowerState(ctx, nodeID, PowerStateOn); err != nil {
+		return fmt.Errorf("hard reset of node %d: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// waitForPowerState polls the power status of a node until it reports the wanted state
+func (b *bmcImpl) waitForPowerState(ctx context.Context, nodeID int, want PowerState) error {
+	deadline := time.Now().Add(b.powerStateTimeout)
+	ticker := time.NewTicker(b.powerPollInterval)
+	defer ticker.Stop()
+
+	var last PowerState = PowerStateUnknown
+	for {
+		status, err := b.GetPowerStatus(ctx, nodeID)
+		if err == nil {
+			if status.State == want {
+				return nil
+			}
+			last = status.State
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: node %d is %s after %s, want %s",
+				ErrPowerStateNotReached, nodeID, last, b.powerStateTimeout, want)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Categories of the cause of a node's last boot, returned by GetResetReason
+const (
+	// ResetReasonWatchdog means a hardware watchdog reset the node
+	ResetReasonWatchdog = "watchdog"
+	// ResetReasonPower means the node lost power or was power cycled
+	ResetReasonPower = "power"
+	// ResetReasonSoftware means the system rebooted itself
+	ResetReasonSoftware = "software"
+	// ResetReasonUnknown means no source tells the cause
+	ResetReasonUnknown = "unknown"
+)
+
+// ErrResetReasonNotAvailable is returned when the reset reason of a node cannot be read
+var ErrResetReasonNotAvailable = errors.New("reset reason not available")
+
+// resetReasonCommand collects every source of the reset reason in one call:
+// the watchdog boot status, the kernel messages about resets, and the end of
+// the journal of the previous boot
+const resetReasonCommand = "cat /sys/class/watchdog/watchdog0/bootstatus 2>/dev/null; " +
+	"echo '" + resetSectionDmesg + "'; dmesg 2>/dev/null | grep -iE 'reset|watchdog|wdt|reboot|power' | tail -n 50; " +
+	"echo '" + resetSectionJournal + "'; journalctl -b -1 -n 20 --no-pager -q 2>/dev/null"
+
+// Markers separating the sections of the resetReasonCommand output
+const (
+	resetSectionDmesg   = "--- dmesg"
+	resetSectionJournal = "--- previous boot"
+)
+
+// wdiofCardReset is the watchdog boot status flag set when the watchdog
+// caused the last reboot, see linux/watchdog.h
+const wdiofCardReset = 0x20
+
+var (
+	watchdogResetRegex = regexp.MustCompile(`(?i)reset cause:.*(watchdog|wdt)|(watchdog|wdt).*(caused|triggered).*(reset|reboot)|last reset.*(watchdog|wdt)`)
+	powerResetRegex    = regexp.MustCompile(`(?i)power[- ]on reset|reset cause:.*(por|power)|brown-?out|under-?voltage|power (loss|failure)`)
+	softwareResetRegex = regexp.MustCompile(`(?i)reset cause:.*(software|soft|sys)|reboot reason:.*(reboot|software|shell)`)
+	// Messages of an orderly reboot at the end of the previous boot journal
+	shutdownRegex = regexp.MustCompile(`(?i)reboot: restarting system|systemd-shutdown|reached target (system )?reboot|shutting down`)
+)
+
+// GetResetReason implements BMC interface
+func (b *bmcImpl) GetResetReason(ctx context.Context, nodeID int) (string, error) {
+	if nodeID < 1 || nodeID > 4 {
+		return "", invalidNodeIDError(nodeID)
+	}
+
+	executor, ok := b.nodeExecutor(nodeID)
+	if !ok {
+		return "", fmt.Errorf("reset reason of node %d: no node executor registered: %w", nodeID, ErrResetReasonNotAvailable)
+	}
+
+	// The reason can only be read from a booted node
+	status, err := b.GetPowerStatus(ctx, nodeID)
+	if err != nil {
+		return "", err
+	}
+	if status.State != PowerStateOn {
+		return "", fmt.Errorf("reset reason of node %d: node is powered off: %w", nodeID, ErrResetReasonNotAvailable)
+	}
+
+	stdout, stderr, err := executor.ExecuteCommand(resetReasonCommand)
+	if err != nil {
+		return "", fmt.Errorf("reset reason of node %d: %v (stderr: %s): %w", nodeID, err, stderr, ErrResetReasonNotAvailable)
+	}
+	return categorizeResetReason(stdout), nil
+}
+
+// categorizeResetReason reads the output of resetReasonCommand. The watchdog
+// boot status is the most reliable source, then the reset cause the kernel
+// or SoC drivers log. Without either, a previous boot whose journal ends
+// without an orderly shutdown lost power.
+func categorizeResetReason(output string) string {
+	bootStatus, dmesg, journal := splitResetSections(output)
+
+	if status, err := strconv.ParseInt(strings.TrimSpace(bootStatus), 0, 64); err == nil && status&wdiofCardReset != 0 {
+		return ResetReasonWatchdog
+	}
+
+	switch {
+	case watchdogResetRegex.MatchString(dmesg):
+		return ResetReasonWatchdog
+	case powerResetRegex.MatchString(dmesg):
+		return ResetReasonPower
+	case softwareResetRegex.MatchString(dmesg):
+		return ResetReasonSoftware
+	}
+
+	if strings.TrimSpace(journal) == "" {
+		return ResetReasonUnknown
+	}
+	if shutdownRegex.MatchString(journal) {
+		return ResetReasonSoftware
+	}
+	return ResetReasonPower
+}
+
+// splitResetSections splits the output of resetReasonCommand into its sections
+func splitResetSections(output string) (bootStatus, dmesg, journal string) {
+	bootStatus, rest, _ := strings.Cut(output, resetSectionDmesg)
+	dmesg, journal, _ = strings.Cut(rest, resetSectionJournal)
+	return bootStatus, dmesg, journal
+}
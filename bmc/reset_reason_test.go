@@ -0,0 +1,91 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCategorizeResetReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "Watchdog boot status",
+			output: "0x20\n--- dmesg\n--- previous boot\nkernel: reboot: Restarting system\n",
+			want:   ResetReasonWatchdog,
+		},
+		{
+			name:   "Watchdog reset cause",
+			output: "0\n--- dmesg\n[    0.000000] Reset cause: WDT\n--- previous boot\n",
+			want:   ResetReasonWatchdog,
+		},
+		{
+			name:   "Undervoltage",
+			output: "--- dmesg\n[12034.5] hwmon hwmon1: Undervoltage detected!\n--- previous boot\n",
+			want:   ResetReasonPower,
+		},
+		{
+			name:   "Power-on reset cause",
+			output: "--- dmesg\n[    0.000000] Reset cause: POR\n--- previous boot\nkernel: reboot: Restarting system\n",
+			want:   ResetReasonPower,
+		},
+		{
+			name:   "Software reset cause",
+			output: "--- dmesg\n[    0.000000] reset cause: software reset\n--- previous boot\n",
+			want:   ResetReasonSoftware,
+		},
+		{
+			name: "Orderly reboot in the previous boot",
+			output: "0\n--- dmesg\n[    2.1] dw_wdt fd7e0000.watchdog: watchdog timeout set to 44s\n--- previous boot\n" +
+				"systemd[1]: Reached target System Reboot.\nsystemd-shutdown[1]: Syncing filesystems and block devices.\nkernel: reboot: Restarting system\n",
+			want: ResetReasonSoftware,
+		},
+		{
+			name:   "Previous boot ended abruptly",
+			output: "0\n--- dmesg\n--- previous boot\nk3s[812]: I1016 node is ready\nk3s[812]: I1016 syncing pods\n",
+			want:   ResetReasonPower,
+		},
+		{
+			name:   "No source",
+			output: "--- dmesg\n--- previous boot\n",
+			want:   ResetReasonUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := categorizeResetReason(tt.output); got != tt.want {
+				t.Errorf("categorizeResetReason() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetResetReason(t *testing.T) {
+	ctx := context.Background()
+
+	bmcExecutor := newMockExecutor()
+	bmcExecutor.ResponseMap["tpi power status"] = mockResponse{Stdout: "node1: On\nnode2: Off\nnode3: On\nnode4: Off\n"}
+
+	nodeExecutor := newMockExecutor()
+	nodeExecutor.ResponseMap[resetReasonCommand] = mockResponse{Stdout: "32\n--- dmesg\n--- previous boot\n"}
+
+	b := newBMC(bmcExecutor)
+	b.SetNodeExecutor(1, nodeExecutor)
+	b.SetNodeExecutor(2, nodeExecutor)
+
+	if reason, err := b.GetResetReason(ctx, 1); err != nil || reason != ResetReasonWatchdog {
+		t.Errorf("GetResetReason() = %s, %v, want watchdog", reason, err)
+	}
+	if _, err := b.GetResetReason(ctx, 2); !errors.Is(err, ErrResetReasonNotAvailable) {
+		t.Errorf("GetResetReason() of a powered off node error = %v, want ErrResetReasonNotAvailable", err)
+	}
+	if _, err := b.GetResetReason(ctx, 3); !errors.Is(err, ErrResetReasonNotAvailable) {
+		t.Errorf("GetResetReason() without node executor error = %v, want ErrResetReasonNotAvailable", err)
+	}
+	if _, err := b.GetResetReason(ctx, 5); err == nil {
+		t.Error("GetResetReason() expected an error for node 5")
+	}
+}
@@ -0,0 +1,127 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// powerSimulator emulates a node whose power state changes after a few status polls
+type powerSimulator struct {
+	state PowerState
+	// pending is the state the node moves to once delay status polls have passed
+	pending PowerState
+	delay   int
+	// stuck keeps the node in its current state whatever is requested
+	stuck bool
+	// events records the power commands and confirmed states in order
+	events []string
+}
+
+func (p *powerSimulator) handle(command string) (mockResponse, bool) {
+	switch command {
+	case "tpi power off --node 1":
+		p.events = append(p.events, "off")
+		p.pending, p.delay = PowerStateOff, 2
+	case "tpi power on --node 1":
+		p.events = append(p.events, "on")
+		p.pending, p.delay = PowerStateOn, 2
+	case "tpi power status":
+		if p.pending != "" && !p.stuck {
+			if p.delay == 0 {
+				p.state, p.pending = p.pending, ""
+				p.events = append(p.events, "confirm-"+string(p.state))
+			} else {
+				p.delay--
+			}
+		}
+		return mockResponse{Stdout: fmt.Sprintf("node1: %s\nnode2: Off\nnode3: Off\nnode4: Off\n", p.state)}, true
+	default:
+		return mockResponse{}, false
+	}
+	return mockResponse{}, true
+}
+
+func newHardResetBMC(sim *powerSimulator) *bmcImpl {
+	executor := newMockExecutor()
+	executor.Handler = sim.handle
+
+	b := newBMC(executor)
+	b.powerPollInterval = time.Millisecond
+	b.powerStateTimeout = 50 * time.Millisecond
+	return b
+}
+
+func TestHardReset(t *testing.T) {
+	t.Run("Power cycle sequence", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b := newHardResetBMC(sim)
+
+		if err := b.HardReset(context.Background(), 1); err != nil {
+			t.Fatalf("HardReset() error = %v", err)
+		}
+
+		want := []string{"off", "confirm-Off", "on", "confirm-On"}
+		if !reflect.DeepEqual(sim.events, want) {
+			t.Errorf("Power sequence = %v, want %v", sim.events, want)
+		}
+	})
+
+	t.Run("Node does not power off", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn, stuck: true}
+		b := newHardResetBMC(sim)
+
+		err := b.HardReset(context.Background(), 1)
+		if !errors.Is(err, ErrPowerStateNotReached) {
+			t.Fatalf("HardReset() error = %v, want ErrPowerStateNotReached", err)
+		}
+
+		// The node must not be powered on again if it never confirmed off
+		want := []string{"off"}
+		if !reflect.DeepEqual(sim.events, want) {
+			t.Errorf("Power sequence = %v, want %v", sim.events, want)
+		}
+	})
+
+	t.Run("Node does not power on", func(t *testing.T) {
+		sim := &powerSimulator{state: PowerStateOn}
+		b := newHardResetBMC(sim)
+		executor := b.executor.(*mockExecutor)
+
+		// Let the node power off, then keep it off
+		executor.Handler = func(command string) (mockResponse, bool) {
+			if command == "tpi power on --node 1" {
+				sim.stuck = true
+			}
+			return sim.handle(command)
+		}
+
+		err := b.HardReset(context.Background(), 1)
+		if !errors.Is(err, ErrPowerStateNotReached) {
+			t.Fatalf("HardReset() error = %v, want ErrPowerStateNotReached", err)
+		}
+
+		want := []string{"off", "confirm-Off", "on"}
+		if !reflect.DeepEqual(sim.events, want) {
+			t.Errorf("Power sequence = %v, want %v", sim.events, want)
+		}
+	})
+
+	t.Run("Power command failure", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi power off --node 1"] = mockResponse{Stderr: "busy", Err: errors.New("exit status 1")}
+		b := newBMC(executor)
+
+		if err := b.HardReset(context.Background(), 1); err == nil {
+			t.Fatal("HardReset() expected an error when power off fails")
+		}
+		for _, command := range executor.Commands {
+			if command == "tpi power on --node 1" {
+				t.Error("Node was powered on after a failed power off")
+			}
+		}
+	})
+}
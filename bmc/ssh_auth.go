@@ -0,0 +1,85 @@
+package bmc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrInvalidPrivateKey is returned when the configured SSH private key cannot
+// be read, parsed or decrypted
+var ErrInvalidPrivateKey = errors.New("invalid SSH private key")
+
+// WithPrivateKeyFile authenticates with the private key stored at path,
+// decrypted with passphrase when it is encrypted. The password, if any, is
+// still tried when the key is refused.
+func (s *SSHExecutor) WithPrivateKeyFile(path, passphrase string) *SSHExecutor {
+	s.config.PrivateKeyPath = path
+	s.config.PrivateKeyPassphrase = passphrase
+	return s
+}
+
+// WithPrivateKey authenticates with a PEM encoded private key, decrypted with
+// passphrase when it is encrypted. The password, if any, is still tried when
+// the key is refused.
+func (s *SSHExecutor) WithPrivateKey(pemBytes []byte, passphrase string) *SSHExecutor {
+	s.config.PrivateKey = string(pemBytes)
+	s.config.PrivateKeyPassphrase = passphrase
+	return s
+}
+
+// hasPrivateKey reports whether key authentication is configured
+func (s *SSHExecutor) hasPrivateKey() bool {
+	return s.config.PrivateKey != "" || s.config.PrivateKeyPath != ""
+}
+
+// authMethods returns the configured authentication methods, the private key
+// first and the password as fallback
+func (s *SSHExecutor) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if s.hasPrivateKey() {
+		signer, err := s.privateKeySigner()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if s.config.Password != "" || len(methods) == 0 {
+		methods = append(methods, ssh.Password(s.config.Password))
+	}
+	return methods, nil
+}
+
+// privateKeySigner parses the configured private key, the PEM bytes taking
+// precedence over the key file
+func (s *SSHExecutor) privateKeySigner() (ssh.Signer, error) {
+	pemBytes := []byte(s.config.PrivateKey)
+	source := "configured key"
+	if len(pemBytes) == 0 {
+		source = s.config.PrivateKeyPath
+		data, err := os.ReadFile(s.config.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+		}
+		pemBytes = data
+	}
+
+	var signer ssh.Signer
+	var err error
+	if s.config.PrivateKeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(s.config.PrivateKeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pemBytes)
+	}
+
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing):
+		return nil, fmt.Errorf("%w: %s is encrypted and no passphrase is configured", ErrInvalidPrivateKey, source)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPrivateKey, source, err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,198 @@
+package bmc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newPrivateKey generates an ed25519 private key, PEM encoded and encrypted
+// with passphrase when it is not empty
+func newPrivateKey(t *testing.T, passphrase string) ([]byte, ssh.PublicKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	sshPublic, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("Failed to convert public key: %v", err)
+	}
+	return pem.EncodeToMemory(block), sshPublic
+}
+
+// startFakeExecServer accepts SSH connections authenticated with the
+// authorized key or the password "secret" and answers each command with
+// "ran: <command>". The command "exit <n>" exits with status n, and
+// "hangup" closes the session without an exit status.
+func startFakeExecServer(t *testing.T, hostKey ssh.Signer, authorized ssh.PublicKey) (host string, port int) {
+	t.Helper()
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, fmt.Errorf("wrong password")
+			}
+			return nil, nil
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, fmt.Errorf("unauthorized key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveExec(conn, config)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// serveExec answers the exec requests of a fake SSH server connection
+func serveExec(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		for request := range channelRequests {
+			if request.Type != "exec" {
+				request.Reply(false, nil)
+				continue
+			}
+			var payload struct{ Command string }
+			ssh.Unmarshal(request.Payload, &payload)
+			request.Reply(true, nil)
+
+			fmt.Fprintf(channel, "ran: %s\n", payload.Command)
+			if payload.Command == "hangup" {
+				channel.Close()
+				continue
+			}
+			var status uint32
+			if code, ok := strings.CutPrefix(payload.Command, "exit "); ok {
+				n, _ := strconv.Atoi(code)
+				status = uint32(n)
+				fmt.Fprintf(channel.Stderr(), "exited with %d\n", n)
+			}
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			channel.Close()
+		}
+	}
+}
+
+func TestSSHExecutorKeyAuth(t *testing.T) {
+	hostKey := newHostKey(t)
+	keyPEM, publicKey := newPrivateKey(t, "")
+	host, port := startFakeExecServer(t, hostKey, publicKey)
+	knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+
+	newExecutor := func(password string) *SSHExecutor {
+		return NewSSHExecutor(host, port, "root", password).WithHostKeyPolicy(HostKeyVerify, knownHosts)
+	}
+	run := func(t *testing.T, executor *SSHExecutor) {
+		t.Helper()
+		stdout, _, err := executor.ExecuteCommand("tpi info")
+		if err != nil {
+			t.Fatalf("ExecuteCommand() error = %v", err)
+		}
+		if stdout != "ran: tpi info" {
+			t.Errorf("ExecuteCommand() stdout = %q", stdout)
+		}
+	}
+
+	t.Run("PEM bytes", func(t *testing.T) {
+		run(t, newExecutor("").WithPrivateKey(keyPEM, ""))
+	})
+
+	t.Run("Encrypted key file", func(t *testing.T) {
+		encrypted, encryptedPublic := newPrivateKey(t, "passphrase")
+		host, port := startFakeExecServer(t, hostKey, encryptedPublic)
+		knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+
+		path := filepath.Join(t.TempDir(), "id_ed25519")
+		if err := os.WriteFile(path, encrypted, 0600); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
+		}
+		run(t, NewSSHExecutor(host, port, "root", "").
+			WithHostKeyPolicy(HostKeyVerify, knownHosts).
+			WithPrivateKeyFile(path, "passphrase"))
+	})
+
+	t.Run("Refused key falls back to the password", func(t *testing.T) {
+		otherPEM, _ := newPrivateKey(t, "")
+		run(t, newExecutor("secret").WithPrivateKey(otherPEM, ""))
+	})
+
+	t.Run("Refused key without password", func(t *testing.T) {
+		otherPEM, _ := newPrivateKey(t, "")
+		_, _, err := newExecutor("").WithPrivateKey(otherPEM, "").ExecuteCommand("tpi info")
+		if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+			t.Errorf("ExecuteCommand() error = %v, want an authentication failure", err)
+		}
+	})
+
+	t.Run("Invalid keys", func(t *testing.T) {
+		encrypted, _ := newPrivateKey(t, "passphrase")
+		tests := []struct {
+			name     string
+			executor *SSHExecutor
+			want     string
+		}{
+			{"Garbage", newExecutor("").WithPrivateKey([]byte("not a key"), ""), "configured key"},
+			{"Missing passphrase", newExecutor("").WithPrivateKey(encrypted, ""), "no passphrase"},
+			{"Wrong passphrase", newExecutor("").WithPrivateKey(encrypted, "wrong"), "configured key"},
+			{"Missing file", newExecutor("").WithPrivateKeyFile(filepath.Join(t.TempDir(), "missing"), ""), "missing"},
+		}
+		for _, tt := range tests {
+			_, _, err := tt.executor.ExecuteCommand("tpi info")
+			if !errors.Is(err, ErrInvalidPrivateKey) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: ExecuteCommand() error = %v, want ErrInvalidPrivateKey mentioning %q", tt.name, err, tt.want)
+			}
+		}
+	})
+}
@@ -1,19 +1,29 @@
 package bmc
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
+// ErrSSHConnect is returned when a command could not be started over SSH
+// because connecting to the host or logging in failed
+var ErrSSHConnect = errors.New("SSH connection failed")
+
 // SSHConfig holds the configuration for SSH connections
 type SSHConfig struct {
 	Host      string `json:"host"`
@@ -21,6 +31,31 @@ type SSHConfig struct {
 	User      string `json:"user"`
 	Password  string `json:"password"`
 	RemoteDir string `json:"remote_dir"`
+	// KnownHostsFile holds the trusted host keys, ~/.ssh/known_hosts when empty
+	KnownHostsFile string `json:"known_hosts_file"`
+	// HostKeyPolicy controls host key verification, HostKeyVerify when empty
+	HostKeyPolicy HostKeyPolicy `json:"host_key_policy"`
+	// PrivateKey is a PEM encoded private key to authenticate with. It takes
+	// precedence over PrivateKeyPath. The password is tried when the key is refused.
+	PrivateKey string `json:"private_key,omitempty"`
+	// PrivateKeyPath is the file holding the private key to authenticate with
+	PrivateKeyPath string `json:"private_key_path,omitempty"`
+	// PrivateKeyPassphrase decrypts an encrypted private key
+	PrivateKeyPassphrase string `json:"private_key_passphrase,omitempty"`
+	// ConnectTimeout bounds connecting and logging in, 30 seconds when zero
+	ConnectTimeout time.Duration `json:"connect_timeout,omitempty"`
+}
+
+// defaultSSHConnectTimeout bounds connecting and logging in when the
+// configuration does not
+const defaultSSHConnectTimeout = 30 * time.Second
+
+// connectTimeout returns the time connecting and logging in may take
+func (c SSHConfig) connectTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return defaultSSHConnectTimeout
 }
 
 // SSHExecutor implements CommandExecutor by executing commands over SSH on a remote Turing Pi cluster
@@ -64,11 +99,73 @@ func NewSSHExecutor(host string, port int, user, password string) *SSHExecutor {
 	}
 }
 
+// WithHostKeyPolicy sets how the host key of the BMC is verified and the
+// known hosts file it is verified against, ~/.ssh/known_hosts when empty
+func (s *SSHExecutor) WithHostKeyPolicy(policy HostKeyPolicy, knownHostsFile string) *SSHExecutor {
+	s.config.HostKeyPolicy = policy
+	s.config.KnownHostsFile = knownHostsFile
+	return s
+}
+
 // ExecuteCommand implements CommandExecutor interface by running commands over SSH
 func (s *SSHExecutor) ExecuteCommand(command string) (stdout string, stderr string, err error) {
+	return s.ExecuteCommandContext(context.Background(), command)
+}
+
+// ExecuteCommandContext runs a command over SSH until ctx is done
+func (s *SSHExecutor) ExecuteCommandContext(ctx context.Context, command string) (stdout string, stderr string, err error) {
+	// The ssh CLI cannot use in-memory or passphrase protected keys
+	if s.hasPrivateKey() {
+		return s.executeOverSession(ctx, command)
+	}
+
+	return s.executeWithCLI(ctx, command)
+}
+
+// ExecuteCommandStatus implements StatusExecutor. A command that ran reports
+// its exit code with a nil error, the error is set when the command could not
+// be run over SSH. It wraps ErrSSHConnect when the connection or
+// authentication failed.
+func (s *SSHExecutor) ExecuteCommandStatus(command string) (stdout string, stderr string, exitCode int, err error) {
+	return s.ExecuteCommandStatusContext(context.Background(), command)
+}
+
+// ExecuteCommandStatusContext is ExecuteCommandStatus running until ctx is done
+func (s *SSHExecutor) ExecuteCommandStatusContext(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error) {
+	if s.hasPrivateKey() {
+		return s.sessionStatus(ctx, command)
+	}
+
+	stdout, stderr, err = s.executeWithCLI(ctx, command)
+	if err == nil {
+		return stdout, stderr, 0, nil
+	}
+	// The ssh client exits with 255 when it cannot run the command
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != sshClientFailureStatus && exitErr.ExitCode() >= 0 {
+		return stdout, stderr, exitErr.ExitCode(), nil
+	}
+	return stdout, stderr, -1, err
+}
+
+// sshClientFailureStatus is the exit status of the ssh client when it fails
+// to connect or authenticate
+const sshClientFailureStatus = 255
+
+// executeWithCLI runs a command with the ssh client, through sshpass when
+// authenticating with a password
+func (s *SSHExecutor) executeWithCLI(ctx context.Context, command string) (stdout string, stderr string, err error) {
 	// Build the SSH command
-	// Example: ssh user@host -p port "command"
-	sshCmd := fmt.Sprintf("ssh %s@%s -p %d",
+	// Example: ssh -o StrictHostKeyChecking=yes user@host -p port "command"
+	var hostKeyOptions []string
+	for _, option := range sshHostKeyOptions(s.config.HostKeyPolicy, s.config.KnownHostsFile) {
+		hostKeyOptions = append(hostKeyOptions, "'"+option+"'")
+	}
+	// The ssh client only takes whole seconds
+	connectTimeout := int((s.config.connectTimeout() + time.Second - 1) / time.Second)
+	hostKeyOptions = append(hostKeyOptions, fmt.Sprintf("-o ConnectTimeout=%d", connectTimeout))
+	sshCmd := fmt.Sprintf("ssh %s %s@%s -p %d",
+		strings.Join(hostKeyOptions, " "),
 		s.config.User,
 		s.config.Host,
 		s.config.Port)
@@ -82,8 +179,10 @@ func (s *SSHExecutor) ExecuteCommand(command string) (stdout string, stderr stri
 	// Add the actual command to execute remotely
 	fullCmd := fmt.Sprintf("%s \"%s\"", sshCmd, command)
 
-	// Execute the SSH command
-	cmd := exec.Command("sh", "-c", fullCmd)
+	// Execute the SSH command. Once ctx is done the shell is killed, and the
+	// output of the ssh client it started no longer waited for.
+	cmd := exec.CommandContext(ctx, "sh", "-c", fullCmd)
+	cmd.WaitDelay = time.Second
 	stdoutBytes, err := cmd.Output()
 	stdout = string(stdoutBytes)
 
@@ -100,31 +199,136 @@ func (s *SSHExecutor) ExecuteCommand(command string) (stdout string, stderr stri
 	stdout = strings.TrimSuffix(stdout, "\n")
 	stderr = strings.TrimSuffix(stderr, "\n")
 
+	// Tell connection and host key failures apart from failures of the
+	// command itself
+	var exitErr *exec.ExitError
+	if err != nil {
+		switch {
+		case strings.Contains(stderr, "REMOTE HOST IDENTIFICATION HAS CHANGED"):
+			err = fmt.Errorf("%w: %w: %s: %w", ErrSSHConnect, ErrHostKeyMismatch, s.config.Host, err)
+		case strings.Contains(stderr, "Host key verification failed"):
+			err = fmt.Errorf("%w: %w: %s: %w", ErrSSHConnect, ErrHostKeyUnknown, s.config.Host, err)
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == sshClientFailureStatus:
+			err = fmt.Errorf("%w: %s: %w", ErrSSHConnect, s.config.Host, err)
+		}
+	}
+
 	return stdout, stderr, err
 }
 
 // getSSHClientConfig creates an SSH client config from SSHConfig
-func (s *SSHExecutor) getSSHClientConfig() *ssh.ClientConfig {
+func (s *SSHExecutor) getSSHClientConfig() (*ssh.ClientConfig, error) {
+	hostKeyCallback, err := NewHostKeyCallback(s.config.HostKeyPolicy, s.config.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := s.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ssh.ClientConfig{
-		User: s.config.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(s.config.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            s.config.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         s.config.connectTimeout(),
+	}, nil
+}
+
+// executeOverSession runs a command in an SSH session opened with the Go
+// client, closing the connection once ctx is done
+func (s *SSHExecutor) executeOverSession(ctx context.Context, command string) (stdout string, stderr string, err error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: failed to open SSH session: %w", ErrSSHConnect, err)
+	}
+	defer session.Close()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		err = fmt.Errorf("ssh command interrupted: %w", ctx.Err())
+	}
+
+	// Trim trailing newlines as the ssh CLI path does
+	return strings.TrimSuffix(stdoutBuf.String(), "\n"), strings.TrimSuffix(stderrBuf.String(), "\n"), err
+}
+
+// sessionStatus runs a command in an SSH session and returns its exit status
+// separately from the errors running it
+func (s *SSHExecutor) sessionStatus(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error) {
+	stdout, stderr, err = s.executeOverSession(ctx, command)
+	if err == nil {
+		return stdout, stderr, 0, nil
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout, stderr, exitErr.ExitStatus(), nil
 	}
+	return stdout, stderr, -1, err
+}
+
+// dial opens an SSH connection to the BMC, verifying its host key. Connecting
+// and logging in take at most the connect timeout, and stop once ctx is done.
+func (s *SSHExecutor) dial(ctx context.Context) (*ssh.Client, error) {
+	sshConfig, err := s.getSSHClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	port := s.config.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(port))
+	log.Printf("[BMC SSH] Connecting to %s...", addr)
+
+	dialer := net.Dialer{Timeout: sshConfig.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ssh dial to %s failed: %w", addr, ctx.Err())
+		}
+		return nil, fmt.Errorf("%w: ssh dial to %s failed: %w", ErrSSHConnect, addr, err)
+	}
+
+	// A server may accept the connection and stall the handshake
+	deadline := time.Now().Add(sshConfig.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	netConn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+
+	conn, chans, reqs, err := ssh.NewClientConn(netConn, addr, sshConfig)
+	if !stop() || err != nil {
+		netConn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ssh dial to %s failed: %w", addr, ctx.Err())
+		}
+		return nil, fmt.Errorf("%w: ssh dial to %s failed: %w", ErrSSHConnect, addr, err)
+	}
+	netConn.SetDeadline(time.Time{})
+	return ssh.NewClient(conn, chans, reqs), nil
 }
 
 // UploadFile implements FileUploader interface to upload files via SFTP
 func (s *SSHExecutor) UploadFile(localPath, remotePath string) error {
-	// Create SSH connection configuration
-	sshConfig := s.getSSHClientConfig()
-
 	// Connect to remote server
-	addr := fmt.Sprintf("%s:22", s.config.Host)
-	log.Printf("[BMC SCP UPLOAD] Connecting to %s...", addr)
-	conn, err := ssh.Dial("tcp", addr, sshConfig)
+	conn, err := s.dial(context.Background())
 	if err != nil {
-		return fmt.Errorf("ssh dial for sftp to %s failed: %w", addr, err)
+		return fmt.Errorf("sftp connection failed: %w", err)
 	}
 	defer conn.Close()
 
@@ -0,0 +1,98 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSSHExecutorCommandStatus(t *testing.T) {
+	hostKey := newHostKey(t)
+	keyPEM, publicKey := newPrivateKey(t, "")
+	host, port := startFakeExecServer(t, hostKey, publicKey)
+	knownHosts := writeKnownHosts(t, host, port, hostKey.PublicKey())
+
+	executor := NewSSHExecutor(host, port, "root", "").
+		WithHostKeyPolicy(HostKeyVerify, knownHosts).
+		WithPrivateKey(keyPEM, "")
+
+	tests := []struct {
+		command    string
+		wantCode   int
+		wantStderr string
+	}{
+		{"tpi info", 0, ""},
+		{"exit 1", 1, "exited with 1"},
+		{"exit 2", 2, "exited with 2"},
+		{"exit 127", 127, "exited with 127"},
+	}
+	for _, tt := range tests {
+		stdout, stderr, code, err := executor.ExecuteCommandStatus(tt.command)
+		if err != nil {
+			t.Errorf("ExecuteCommandStatus(%q) error = %v", tt.command, err)
+			continue
+		}
+		if code != tt.wantCode || stderr != tt.wantStderr || stdout != "ran: "+tt.command {
+			t.Errorf("ExecuteCommandStatus(%q) = %q, %q, %d, want exit code %d", tt.command, stdout, stderr, code, tt.wantCode)
+		}
+	}
+
+	t.Run("Session without exit status", func(t *testing.T) {
+		_, _, code, err := executor.ExecuteCommandStatus("hangup")
+		if err == nil || errors.Is(err, ErrSSHConnect) || code != -1 {
+			t.Errorf("ExecuteCommandStatus() = %d, %v, want an error after connecting", code, err)
+		}
+	})
+
+	t.Run("Connection failure", func(t *testing.T) {
+		mismatched := writeKnownHosts(t, host, port, newHostKey(t).PublicKey())
+		executor := NewSSHExecutor(host, port, "root", "").
+			WithHostKeyPolicy(HostKeyVerify, mismatched).
+			WithPrivateKey(keyPEM, "")
+		_, _, code, err := executor.ExecuteCommandStatus("tpi info")
+		if !errors.Is(err, ErrHostKeyMismatch) || !errors.Is(err, ErrSSHConnect) || code != -1 {
+			t.Errorf("ExecuteCommandStatus() = %d, %v, want ErrHostKeyMismatch and ErrSSHConnect", code, err)
+		}
+	})
+
+	t.Run("Through the BMC", func(t *testing.T) {
+		b := New(executor)
+		_, _, code, err := b.ExecuteCommandStatus(context.Background(), "exit 3")
+		if err != nil || code != 3 {
+			t.Errorf("ExecuteCommandStatus() = %d, %v, want exit code 3", code, err)
+		}
+	})
+}
+
+// exitStatusError is the error of a command that exited with a status
+type exitStatusError struct {
+	status int
+}
+
+func (e *exitStatusError) Error() string   { return "command exited" }
+func (e *exitStatusError) ExitStatus() int { return e.status }
+
+func TestBMCExecuteCommandStatus(t *testing.T) {
+	ctx := context.Background()
+
+	// Executors without exit codes have them read from their errors
+	executor := newMockExecutor()
+	executor.ResponseMap["tpi power on --node 9"] = mockResponse{
+		Stderr: "node not found",
+		Err:    &exitStatusError{status: 2},
+	}
+	executor.ResponseMap["tpi info"] = mockResponse{Stdout: "version: 2.0.5"}
+	unreachable := errors.New("connection refused")
+	executor.ResponseMap["tpi reboot"] = mockResponse{Err: unreachable}
+
+	b := newBMC(executor)
+	if stdout, _, code, err := b.ExecuteCommandStatus(ctx, "tpi info"); err != nil || code != 0 || stdout != "version: 2.0.5" {
+		t.Errorf("ExecuteCommandStatus() = %q, %d, %v", stdout, code, err)
+	}
+	if _, stderr, code, err := b.ExecuteCommandStatus(ctx, "tpi power on --node 9"); err != nil || code != 2 || stderr != "node not found" {
+		t.Errorf("ExecuteCommandStatus() = %q, %d, %v, want exit code 2", stderr, code, err)
+	}
+	if _, _, code, err := b.ExecuteCommandStatus(ctx, "tpi reboot"); !errors.Is(err, unreachable) || code != -1 {
+		t.Errorf("ExecuteCommandStatus() = %d, %v, want the connection failure", code, err)
+	}
+}
@@ -0,0 +1,147 @@
+package bmc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// StorageTarget is the storage of a node an image is flashed to
+type StorageTarget string
+
+const (
+	// StorageEMMC is the on-module eMMC, the target of tpi flash
+	StorageEMMC StorageTarget = "emmc"
+	// StorageSD is the SD card of the node
+	StorageSD StorageTarget = "sd"
+)
+
+// ErrStorageTargetNotFound is returned when a node does not expose the requested storage
+var ErrStorageTargetNotFound = errors.New("storage target not found")
+
+// ErrStorageTargetAmbiguous is returned when the BMC sees several devices
+// that could be the requested storage of a node
+var ErrStorageTargetAmbiguous = errors.New("storage target ambiguous")
+
+// GetStorageTargets implements BMC interface. The BMC only sees the storage
+// of the node its USB bus is routed to, so the devices are attributed to
+// nodeID only when the BMC reports the bus routed to it in device mode.
+func (b *bmcImpl) GetStorageTargets(ctx context.Context, nodeID int) (map[StorageTarget]string, error) {
+	if nodeID < 1 || nodeID > 4 {
+		return nil, invalidNodeIDError(nodeID)
+	}
+
+	usb, err := b.GetUSBConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the USB storage of node %d: %w", nodeID, err)
+	}
+	if usb.NodeID != nodeID || usb.Host {
+		return nil, fmt.Errorf("%w: the USB bus of the BMC is not routed to node %d in device mode (%s)",
+			ErrStorageTargetNotFound, nodeID, describeUSBRoute(usb))
+	}
+
+	stdout, stderr, err := b.executor.ExecuteCommand("lsblk -d -n -o NAME,TRAN,MODEL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list block devices: %w (stderr: %s)", err, stderr)
+	}
+
+	targets := make(map[StorageTarget]string)
+	for target, devices := range parseStorageTargets(stdout) {
+		// Disks left over by another node cannot be told apart from the
+		// storage of this one
+		if len(devices) > 1 {
+			return nil, fmt.Errorf("%w: %s storage of node %d could be any of %s",
+				ErrStorageTargetAmbiguous, target, nodeID, strings.Join(devices, ", "))
+		}
+		targets[target] = devices[0]
+	}
+	return targets, nil
+}
+
+// describeUSBRoute describes the routing of the USB bus for error messages
+func describeUSBRoute(usb *USBConfig) string {
+	if usb.NodeID == 0 {
+		return "not routed"
+	}
+	return fmt.Sprintf("routed to node %d in %s mode", usb.NodeID, usbModeName(usb.Host))
+}
+
+// FlashNodeTarget implements BMC interface
+func (b *bmcImpl) FlashNodeTarget(ctx context.Context, nodeID int, imagePath string, target StorageTarget) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+	if imagePath == "" {
+		return fmt.Errorf("image path cannot be empty")
+	}
+	if target != StorageEMMC && target != StorageSD {
+		return fmt.Errorf("invalid storage target: %s (must be emmc or sd)", target)
+	}
+
+	targets, err := b.GetStorageTargets(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+	device, ok := targets[target]
+	if !ok {
+		found := make([]string, 0, len(targets))
+		for t := range targets {
+			found = append(found, string(t))
+		}
+		sort.Strings(found)
+		return fmt.Errorf("%w: node %d exposes no %s storage (found: %s)",
+			ErrStorageTargetNotFound, nodeID, target, strings.Join(found, ", "))
+	}
+
+	var cmd string
+	switch target {
+	case StorageEMMC:
+		cmd = fmt.Sprintf("tpi flash --node %d -i %s", nodeID, imagePath)
+	case StorageSD:
+		// tpi flash only writes the eMMC, the SD card is written through its MSD device
+		cmd = fmt.Sprintf("dd if=%s of=%s bs=4M conv=fsync", imagePath, device)
+	}
+	_, stderr, err := b.executor.ExecuteCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to flash %s of node %d with image %s: %w (stderr: %s)", target, nodeID, imagePath, err, stderr)
+	}
+	return nil
+}
+
+// parseStorageTargets reads the lsblk output listing the block devices of the
+// BMC. The storage of a node in MSD mode is attached over USB and is told
+// apart by its model name, devices of the BMC itself are ignored. Every
+// device matching a target is listed.
+func parseStorageTargets(output string) map[StorageTarget][]string {
+	targets := make(map[StorageTarget][]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "usb" {
+			continue
+		}
+
+		words := strings.FieldsFunc(strings.ToLower(strings.Join(fields[2:], " ")), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			var target StorageTarget
+			switch word {
+			case "emmc":
+				target = StorageEMMC
+			case "sd", "sdcard":
+				target = StorageSD
+			default:
+				continue
+			}
+			targets[target] = append(targets[target], "/dev/"+fields[0])
+			break
+		}
+	}
+	return targets
+}
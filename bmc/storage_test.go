@@ -0,0 +1,160 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lsblkCommand = "lsblk -d -n -o NAME,TRAN,MODEL"
+
+// lsblkOutput lists the BMC eMMC and both storages of a node in MSD mode
+const lsblkOutput = `mmcblk0        BMC internal
+sda     usb    RK1 eMMC
+sdb     usb    RK1 SD/MMC
+`
+
+// twoNodesOutput lists the storage of the node in MSD mode along with the
+// eMMC of a node previously in MSD mode
+const twoNodesOutput = lsblkOutput + `sdc     usb    CM4 eMMC
+`
+
+// usbRoutedTo returns the tpi usb get output of the bus routed to a node
+func usbRoutedTo(nodeID int, mode string) mockResponse {
+	return mockResponse{Stdout: fmt.Sprintf("USB routed to node %d in %s mode\n", nodeID, mode)}
+}
+
+func TestParseStorageTargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   map[StorageTarget][]string
+	}{
+		{"Both targets", lsblkOutput, map[StorageTarget][]string{StorageEMMC: {"/dev/sda"}, StorageSD: {"/dev/sdb"}}},
+		{"SD card only", "sdc usb Generic SDCard Reader\n", map[StorageTarget][]string{StorageSD: {"/dev/sdc"}}},
+		{"Two nodes", twoNodesOutput, map[StorageTarget][]string{StorageEMMC: {"/dev/sda", "/dev/sdc"}, StorageSD: {"/dev/sdb"}}},
+		{"Devices of the BMC are ignored", "mmcblk0 mmc eMMC\nsda sata SD adapter\n", map[StorageTarget][]string{}},
+		{"Unknown models are ignored", "sda usb Mass Storage\n", map[StorageTarget][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseStorageTargets(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStorageTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlashNodeTarget(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		target StorageTarget
+		want   string
+	}{
+		{StorageEMMC, "tpi flash --node 2 -i /images/node.img"},
+		{StorageSD, "dd if=/images/node.img of=/dev/sdb bs=4M conv=fsync"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.target), func(t *testing.T) {
+			executor := newMockExecutor()
+			executor.ResponseMap["tpi usb get"] = usbRoutedTo(2, "device")
+			executor.ResponseMap[lsblkCommand] = mockResponse{Stdout: lsblkOutput}
+			executor.ResponseMap[tt.want] = mockResponse{}
+			b := newBMC(executor)
+
+			if err := b.FlashNodeTarget(ctx, 2, "/images/node.img", tt.target); err != nil {
+				t.Fatalf("FlashNodeTarget() error = %v", err)
+			}
+			if want := []string{"tpi usb get", lsblkCommand, tt.want}; !reflect.DeepEqual(executor.Commands, want) {
+				t.Errorf("Commands = %v, want %v", executor.Commands, want)
+			}
+		})
+	}
+
+	t.Run("Missing target", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi usb get"] = usbRoutedTo(2, "device")
+		executor.ResponseMap[lsblkCommand] = mockResponse{Stdout: "sda usb RK1 eMMC\n"}
+		b := newBMC(executor)
+
+		err := b.FlashNodeTarget(ctx, 2, "/images/node.img", StorageSD)
+		if !errors.Is(err, ErrStorageTargetNotFound) {
+			t.Fatalf("FlashNodeTarget() error = %v, want ErrStorageTargetNotFound", err)
+		}
+		if !strings.Contains(err.Error(), "found: emmc") {
+			t.Errorf("FlashNodeTarget() error = %v, want the detected targets", err)
+		}
+		if len(executor.Commands) != 2 {
+			t.Errorf("Commands = %v, want nothing flashed", executor.Commands)
+		}
+	})
+
+	t.Run("Disks of two nodes", func(t *testing.T) {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi usb get"] = usbRoutedTo(2, "device")
+		executor.ResponseMap[lsblkCommand] = mockResponse{Stdout: twoNodesOutput}
+		b := newBMC(executor)
+
+		for _, target := range []StorageTarget{StorageEMMC, StorageSD} {
+			err := b.FlashNodeTarget(ctx, 2, "/images/node.img", target)
+			if !errors.Is(err, ErrStorageTargetAmbiguous) {
+				t.Errorf("FlashNodeTarget(%s) error = %v, want ErrStorageTargetAmbiguous", target, err)
+			}
+		}
+		for _, command := range executor.Commands {
+			if command != "tpi usb get" && command != lsblkCommand {
+				t.Errorf("Command %s ran, want nothing flashed", command)
+			}
+		}
+	})
+
+	t.Run("USB routed to another node", func(t *testing.T) {
+		for name, response := range map[string]mockResponse{
+			"other node": usbRoutedTo(3, "device"),
+			"host mode":  usbRoutedTo(2, "host"),
+			"not routed": {Stdout: "USB is not routed to any node\n"},
+		} {
+			executor := newMockExecutor()
+			executor.ResponseMap["tpi usb get"] = response
+			executor.ResponseMap[lsblkCommand] = mockResponse{Stdout: lsblkOutput}
+			b := newBMC(executor)
+
+			err := b.FlashNodeTarget(ctx, 2, "/images/node.img", StorageSD)
+			if !errors.Is(err, ErrStorageTargetNotFound) {
+				t.Errorf("%s: FlashNodeTarget() error = %v, want ErrStorageTargetNotFound", name, err)
+			}
+			if len(executor.Commands) != 1 {
+				t.Errorf("%s: Commands = %v, want only the USB routing checked", name, executor.Commands)
+			}
+		}
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		executor := newMockExecutor()
+		b := newBMC(executor)
+
+		if err := b.FlashNodeTarget(ctx, 5, "/images/node.img", StorageEMMC); err == nil {
+			t.Error("FlashNodeTarget() expected an error for node 5")
+		}
+		if err := b.FlashNodeTarget(ctx, 1, "", StorageEMMC); err == nil {
+			t.Error("FlashNodeTarget() expected an error for an empty image path")
+		}
+		if err := b.FlashNodeTarget(ctx, 1, "/images/node.img", "nvme"); err == nil {
+			t.Error("FlashNodeTarget() expected an error for an unknown target")
+		}
+		if len(executor.Commands) != 0 {
+			t.Errorf("Commands = %v, want none", executor.Commands)
+		}
+	})
+
+	t.Run("Detection failure", func(t *testing.T) {
+		b := newBMC(newMockExecutor())
+		if err := b.FlashNodeTarget(ctx, 1, "/images/node.img", StorageEMMC); err == nil || errors.Is(err, ErrStorageTargetNotFound) {
+			t.Errorf("FlashNodeTarget() error = %v, want the detection failure", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package bmc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// uartLineReader turns the chunks returned by successive UART reads into
+// complete lines, keeping a trailing partial line until it is finished
+type uartLineReader struct {
+	partial string
+}
+
+// feed adds a chunk of UART output and returns the lines it completed
+func (r *uartLineReader) feed(chunk string) []string {
+	if chunk == "" {
+		return nil
+	}
+
+	data := r.partial + chunk
+	end := strings.LastIndexByte(data, '\n')
+	if end < 0 {
+		r.partial = data
+		return nil
+	}
+	r.partial = data[end+1:]
+
+	lines := strings.Split(data[:end], "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// flush returns the pending partial line, if any
+func (r *uartLineReader) flush() (string, bool) {
+	line := strings.TrimSuffix(r.partial, "\r")
+	r.partial = ""
+	return line, line != ""
+}
+
+// MonitorUART implements BMC interface
+func (b *bmcImpl) MonitorUART(ctx context.Context, nodeIDs []int, onLine func(nodeID int, line string)) error {
+	if len(nodeIDs) == 0 {
+		return fmt.Errorf("no nodes to monitor")
+	}
+	if onLine == nil {
+		return fmt.Errorf("no line callback provided")
+	}
+
+	var nodes []int
+	readers := make(map[int]*uartLineReader, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if nodeID < 1 || nodeID > 4 {
+			return invalidNodeIDError(nodeID)
+		}
+		if _, ok := readers[nodeID]; !ok {
+			nodes = append(nodes, nodeID)
+			readers[nodeID] = &uartLineReader{}
+		}
+	}
+
+	// Remember which nodes are failing so an unavailable UART is logged once
+	// rather than on every poll
+	failing := make(map[int]bool, len(nodes))
+
+	ticker := time.NewTicker(b.uartPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, nodeID := range nodes {
+			output, err := b.GetUARTOutput(ctx, nodeID)
+			if err != nil {
+				if !failing[nodeID] {
+					log.Printf("[BMC UART] Node %d UART unavailable, will keep retrying: %v", nodeID, err)
+					failing[nodeID] = true
+				}
+				continue
+			}
+			if failing[nodeID] {
+				log.Printf("[BMC UART] Node %d UART available again", nodeID)
+				failing[nodeID] = false
+			}
+
+			for _, line := range readers[nodeID].feed(output) {
+				onLine(nodeID, line)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			// Deliver unfinished lines before stopping
+			for _, nodeID := range nodes {
+				if line, ok := readers[nodeID].flush(); ok {
+					onLine(nodeID, line)
+				}
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
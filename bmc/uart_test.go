@@ -0,0 +1,157 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tperrors "github.com/davidroman0O/turingpi/errors"
+)
+
+func TestUARTLineReader(t *testing.T) {
+	r := &uartLineReader{}
+
+	if lines := r.feed("Ubuntu 22.04 LTS\r\nlog"); !reflect.DeepEqual(lines, []string{"Ubuntu 22.04 LTS"}) {
+		t.Errorf("feed() = %q", lines)
+	}
+	if lines := r.feed("in: "); lines != nil {
+		t.Errorf("feed() of a partial line = %q, want nil", lines)
+	}
+	if lines := r.feed("\n\nroot@node1"); !reflect.DeepEqual(lines, []string{"login: ", ""}) {
+		t.Errorf("feed() = %q", lines)
+	}
+	if line, ok := r.flush(); !ok || line != "root@node1" {
+		t.Errorf("flush() = %q, %v", line, ok)
+	}
+	if _, ok := r.flush(); ok {
+		t.Error("flush() returned a line twice")
+	}
+}
+
+// uartSource emits scripted UART chunks, one per read
+type uartSource struct {
+	chunks []string
+	// failures is the number of reads that fail before any output is returned
+	failures int
+	// unavailable makes every read fail
+	unavailable bool
+}
+
+func newUARTMonitorBMC(sources map[int]*uartSource) *bmcImpl {
+	executor := newMockExecutor()
+	executor.Handler = func(command string) (mockResponse, bool) {
+		for nodeID, source := range sources {
+			if command != fmt.Sprintf("tpi uart --node %d get", nodeID) {
+				continue
+			}
+			if source.unavailable || source.failures > 0 {
+				source.failures--
+				return mockResponse{Stderr: "uart busy", Err: errors.New("exit status 1")}, true
+			}
+			if len(source.chunks) == 0 {
+				return mockResponse{}, true
+			}
+			chunk := source.chunks[0]
+			source.chunks = source.chunks[1:]
+			return mockResponse{Stdout: chunk}, true
+		}
+		return mockResponse{}, false
+	}
+
+	b := newBMC(executor)
+	b.uartPollInterval = time.Millisecond
+	return b
+}
+
+// lineCollector records lines received per node
+type lineCollector struct {
+	mu    sync.Mutex
+	lines map[int][]string
+	order []int
+}
+
+func (c *lineCollector) onLine(nodeID int, line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lines == nil {
+		c.lines = make(map[int][]string)
+	}
+	c.lines[nodeID] = append(c.lines[nodeID], line)
+	c.order = append(c.order, nodeID)
+}
+
+func TestMonitorUART(t *testing.T) {
+	t.Run("Lines are tagged by node and interleaved", func(t *testing.T) {
+		sources := map[int]*uartSource{
+			1: {chunks: []string{"node1 boot\nnode1 ", "kernel\n", "node1 login: "}},
+			2: {chunks: []string{"node2 boot\n", "node2 kernel\n"}},
+		}
+		b := newUARTMonitorBMC(sources)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		collector := &lineCollector{}
+		err := b.MonitorUART(ctx, []int{1, 2}, collector.onLine)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("MonitorUART() error = %v, want context.DeadlineExceeded", err)
+		}
+
+		want := map[int][]string{
+			1: {"node1 boot", "node1 kernel", "node1 login: "},
+			2: {"node2 boot", "node2 kernel"},
+		}
+		if !reflect.DeepEqual(collector.lines, want) {
+			t.Errorf("Received lines = %q, want %q", collector.lines, want)
+		}
+
+		// Both nodes are read in the same polling round
+		if len(collector.order) < 2 || collector.order[0] != 1 || collector.order[1] != 2 {
+			t.Errorf("Lines were not interleaved across nodes: %v", collector.order)
+		}
+	})
+
+	t.Run("Unavailable UART does not stop other nodes", func(t *testing.T) {
+		sources := map[int]*uartSource{
+			1: {unavailable: true},
+			2: {chunks: []string{"node2 ready\n"}},
+			3: {failures: 3, chunks: []string{"node3 recovered\n"}},
+		}
+		b := newUARTMonitorBMC(sources)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		collector := &lineCollector{}
+		if err := b.MonitorUART(ctx, []int{1, 2, 3}, collector.onLine); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("MonitorUART() error = %v, want context.DeadlineExceeded", err)
+		}
+
+		want := map[int][]string{
+			2: {"node2 ready"},
+			3: {"node3 recovered"},
+		}
+		if !reflect.DeepEqual(collector.lines, want) {
+			t.Errorf("Received lines = %q, want %q", collector.lines, want)
+		}
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		b := newBMC(newMockExecutor())
+		ctx := context.Background()
+
+		if err := b.MonitorUART(ctx, nil, func(int, string) {}); err == nil {
+			t.Error("MonitorUART() expected an error without nodes")
+		}
+		if err := b.MonitorUART(ctx, []int{1, 5}, func(int, string) {}); !tperrors.IsPermanent(err) {
+			t.Errorf("MonitorUART() error = %v, want a permanent error for an invalid node ID", err)
+		}
+		if err := b.MonitorUART(ctx, []int{1}, nil); err == nil {
+			t.Error("MonitorUART() expected an error without a callback")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrUSBInUse is returned when the USB bus is routed to another node
+var ErrUSBInUse = errors.New("USB in use by another node")
+
+// USBConflictError reports that the USB bus a node needs is routed to another node
+type USBConflictError struct {
+	// NodeID is the node the USB bus was requested for
+	NodeID int
+	// Owner is the node the USB bus is currently routed to
+	Owner int
+	// OwnerHost reports whether the owner uses the bus in host mode
+	OwnerHost bool
+}
+
+// Error implements the error interface
+func (e *USBConflictError) Error() string {
+	return fmt.Sprintf("cannot route USB to node %d: bus is routed to node %d in %s mode",
+		e.NodeID, e.Owner, usbModeName(e.OwnerHost))
+}
+
+// Is makes errors.Is match ErrUSBInUse
+func (e *USBConflictError) Is(target error) bool {
+	return target == ErrUSBInUse
+}
+
+// EnsureUSBOwnership implements BMC interface
+func (b *bmcImpl) EnsureUSBOwnership(ctx context.Context, nodeID int, host bool, force bool) error {
+	if nodeID < 1 || nodeID > 4 {
+		return invalidNodeIDError(nodeID)
+	}
+
+	current, err := b.GetUSBConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check USB ownership: %w", err)
+	}
+
+	if current.NodeID == nodeID && current.Host == host {
+		return nil
+	}
+	if current.NodeID != 0 && current.NodeID != nodeID {
+		conflict := &USBConflictError{NodeID: nodeID, Owner: current.NodeID, OwnerHost: current.Host}
+		if !force {
+			return conflict
+		}
+		log.Printf("[BMC USB] Warning: taking USB over from node %d: %v", current.NodeID, conflict)
+	}
+
+	return b.SetUSBConfig(ctx, nodeID, host)
+}
+
+// usbModeName returns the tpi name of a USB mode
+func usbModeName(host bool) string {
+	if host {
+		return "host"
+	}
+	return "device"
+}
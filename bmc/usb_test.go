@@ -0,0 +1,91 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tperrors "github.com/davidroman0O/turingpi/errors"
+)
+
+func TestEnsureUSBOwnership(t *testing.T) {
+	ctx := context.Background()
+
+	newUSBExecutor := func(status string) *mockExecutor {
+		executor := newMockExecutor()
+		executor.ResponseMap["tpi usb get"] = mockResponse{Stdout: status}
+		executor.ResponseMap["tpi usb --node 1 device"] = mockResponse{}
+		executor.ResponseMap["tpi usb --node 1 host"] = mockResponse{}
+		return executor
+	}
+	lastCommand := func(executor *mockExecutor) string {
+		return executor.Commands[len(executor.Commands)-1]
+	}
+
+	t.Run("Conflict with another node", func(t *testing.T) {
+		executor := newUSBExecutor("USB routed to node 3 in host mode\n")
+
+		err := newBMC(executor).EnsureUSBOwnership(ctx, 1, false, false)
+		if !errors.Is(err, ErrUSBInUse) {
+			t.Fatalf("EnsureUSBOwnership() error = %v, want ErrUSBInUse", err)
+		}
+		var conflict *USBConflictError
+		if !errors.As(err, &conflict) || conflict.Owner != 3 || !conflict.OwnerHost || conflict.NodeID != 1 {
+			t.Errorf("EnsureUSBOwnership() error = %#v", err)
+		}
+		if err.Error() != "cannot route USB to node 1: bus is routed to node 3 in host mode" {
+			t.Errorf("Error() = %q", err.Error())
+		}
+		if len(executor.Commands) != 1 {
+			t.Errorf("USB routing changed despite the conflict: %v", executor.Commands)
+		}
+	})
+
+	t.Run("Forced takeover", func(t *testing.T) {
+		executor := newUSBExecutor("USB routed to node 3 in host mode\n")
+
+		if err := newBMC(executor).EnsureUSBOwnership(ctx, 1, false, true); err != nil {
+			t.Fatalf("EnsureUSBOwnership() error = %v", err)
+		}
+		if got := lastCommand(executor); got != "tpi usb --node 1 device" {
+			t.Errorf("Last command = %q, want the USB rerouted to node 1", got)
+		}
+	})
+
+	t.Run("Free bus", func(t *testing.T) {
+		executor := newUSBExecutor("USB is not routed to any node\n")
+
+		if err := newBMC(executor).EnsureUSBOwnership(ctx, 1, true, false); err != nil {
+			t.Fatalf("EnsureUSBOwnership() error = %v", err)
+		}
+		if got := lastCommand(executor); got != "tpi usb --node 1 host" {
+			t.Errorf("Last command = %q", got)
+		}
+	})
+
+	t.Run("Already owned", func(t *testing.T) {
+		executor := newUSBExecutor("USB routed to node 1 in device mode\n")
+
+		if err := newBMC(executor).EnsureUSBOwnership(ctx, 1, false, false); err != nil {
+			t.Fatalf("EnsureUSBOwnership() error = %v", err)
+		}
+		if len(executor.Commands) != 1 {
+			t.Errorf("Commands = %v, want the routing left untouched", executor.Commands)
+		}
+
+		// Switching the mode of the owning node is not a conflict
+		if err := newBMC(executor).EnsureUSBOwnership(ctx, 1, true, false); err != nil {
+			t.Fatalf("EnsureUSBOwnership() with another mode error = %v", err)
+		}
+		if got := lastCommand(executor); got != "tpi usb --node 1 host" {
+			t.Errorf("Last command = %q", got)
+		}
+	})
+
+	t.Run("Invalid node", func(t *testing.T) {
+		err := newBMC(newMockExecutor()).EnsureUSBOwnership(ctx, 0, false, false)
+		if err == nil || !tperrors.IsPermanent(err) {
+			t.Errorf("EnsureUSBOwnership() error = %v, want a permanent error", err)
+		}
+	})
+}
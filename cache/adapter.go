@@ -14,6 +14,7 @@ type Metadata struct {
 	Size        int64
 	ModTime     time.Time
 	Hash        string            // Optional SHA256
+	HMAC        string            // Optional HMAC-SHA256 over key and hash, see FSCache.WithHMAC
 	Tags        map[string]string // User-defined tags
 	OSType      string
 	OSVersion   string
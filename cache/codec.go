@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MetadataCodec encodes the metadata of cached items in their .meta files
+type MetadataCodec interface {
+	// Name identifies the codec in the files it writes
+	Name() string
+	Encode(w io.Writer, metadata *Metadata) error
+	Decode(r io.Reader, metadata *Metadata) error
+}
+
+// JSONMetadataCodec stores metadata as JSON, the default
+var JSONMetadataCodec MetadataCodec = jsonCodec{}
+
+// BinaryMetadataCodec stores metadata in a compact binary layout, smaller
+// than JSON as the fields are written in a fixed order without their names
+var BinaryMetadataCodec MetadataCodec = binaryCodec{}
+
+// codecHeader starts the .meta files written by codecs other than JSON and
+// is followed by the codec name and a newline. JSON files have no header,
+// so caches written before codecs were configurable read back unchanged.
+const codecHeader = "#codec:"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Encode(w io.Writer, metadata *Metadata) error {
+	return json.NewEncoder(w).Encode(metadata)
+}
+
+func (jsonCodec) Decode(r io.Reader, metadata *Metadata) error {
+	return json.NewDecoder(r).Decode(metadata)
+}
+
+// binaryCodec writes a version byte, then the fields of Metadata in their
+// declaration order: strings as their uvarint length and bytes, Size as a
+// varint, ModTime in its binary encoding prefixed by its length, and Tags as
+// their uvarint count followed by the pairs sorted by key. Adding a field
+// to Metadata requires a new version.
+type binaryCodec struct{}
+
+// binaryCodecVersion is the version of the layout written by binaryCodec
+const binaryCodecVersion = 1
+
+// maxBinaryField bounds the length of the fields binaryCodec reads, so that a
+// corrupted file cannot make it allocate arbitrary amounts of memory
+const maxBinaryField = 1 << 20
+
+// errBinaryField is returned when a field of a binary metadata file is too long
+var errBinaryField = errors.New("binary metadata field too long")
+
+func (binaryCodec) Name() string { return "binary" }
+
+func (binaryCodec) Encode(w io.Writer, metadata *Metadata) error {
+	var buf bytes.Buffer
+	putUvarint := func(v uint64) {
+		buf.Write(binary.AppendUvarint(nil, v))
+	}
+	putString := func(s string) {
+		putUvarint(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	modTime, err := metadata.ModTime.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode modification time: %w", err)
+	}
+
+	buf.WriteByte(binaryCodecVersion)
+	putString(metadata.Key)
+	putString(metadata.Filename)
+	putString(metadata.ContentType)
+	buf.Write(binary.AppendVarint(nil, metadata.Size))
+	putUvarint(uint64(len(modTime)))
+	buf.Write(modTime)
+	putString(metadata.Hash)
+	putString(metadata.HMAC)
+
+	tags := make([]string, 0, len(metadata.Tags))
+	for tag := range metadata.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	putUvarint(uint64(len(tags)))
+	for _, tag := range tags {
+		putString(tag)
+		putString(metadata.Tags[tag])
+	}
+
+	putString(metadata.OSType)
+	putString(metadata.OSVersion)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (binaryCodec) Decode(r io.Reader, metadata *Metadata) (err error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		buffered := bufio.NewReader(r)
+		br, r = buffered, buffered
+	}
+
+	// Truncated files end in the middle of a field
+	defer func() {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to decode binary metadata: %w", err)
+		}
+	}()
+
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxBinaryField {
+			return nil, fmt.Errorf("%w: %d bytes", errBinaryField, n)
+		}
+		data := make([]byte, n)
+		_, err = io.ReadFull(r, data)
+		return data, err
+	}
+	readString := func(s *string) error {
+		data, err := readBytes()
+		*s = string(data)
+		return err
+	}
+
+	version, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if version != binaryCodecVersion {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+
+	var decoded Metadata
+	for _, field := range []*string{&decoded.Key, &decoded.Filename, &decoded.ContentType} {
+		if err := readString(field); err != nil {
+			return err
+		}
+	}
+	if decoded.Size, err = binary.ReadVarint(br); err != nil {
+		return err
+	}
+	modTime, err := readBytes()
+	if err != nil {
+		return err
+	}
+	if err := decoded.ModTime.UnmarshalBinary(modTime); err != nil {
+		return err
+	}
+	for _, field := range []*string{&decoded.Hash, &decoded.HMAC} {
+		if err := readString(field); err != nil {
+			return err
+		}
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	if count > maxBinaryField {
+		return fmt.Errorf("%w: %d tags", errBinaryField, count)
+	}
+	if count > 0 {
+		decoded.Tags = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		var tag, value string
+		if err := readString(&tag); err != nil {
+			return err
+		}
+		if err := readString(&value); err != nil {
+			return err
+		}
+		decoded.Tags[tag] = value
+	}
+
+	for _, field := range []*string{&decoded.OSType, &decoded.OSVersion} {
+		if err := readString(field); err != nil {
+			return err
+		}
+	}
+
+	*metadata = decoded
+	return nil
+}
+
+// WithMetadataCodec sets the codec the metadata of newly stored items is
+// written with. The codec is recorded in every .meta file, so items written
+// with any built-in codec, or with this one, are read back whatever codec
+// is configured. It must be called before the cache is used.
+func (c *FSCache) WithMetadataCodec(codec MetadataCodec) *FSCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.codec = codec
+	return c
+}
+
+// metadataCodec returns the codec new metadata is written with
+func (c *FSCache) metadataCodec() MetadataCodec {
+	if c.codec == nil {
+		return JSONMetadataCodec
+	}
+	return c.codec
+}
+
+// encodeMetadata writes metadata with the configured codec, preceded by the
+// codec header unless the codec is JSON
+func (c *FSCache) encodeMetadata(w io.Writer, metadata *Metadata) error {
+	codec := c.metadataCodec()
+	if codec.Name() != JSONMetadataCodec.Name() {
+		if _, err := fmt.Fprintf(w, "%s%s\n", codecHeader, codec.Name()); err != nil {
+			return err
+		}
+	}
+	return codec.Encode(w, metadata)
+}
+
+// decodeMetadata reads metadata written by encodeMetadata with any codec
+func (c *FSCache) decodeMetadata(r io.Reader, metadata *Metadata) error {
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(len(codecHeader))
+	if string(prefix) != codecHeader {
+		return JSONMetadataCodec.Decode(br, metadata)
+	}
+
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read metadata codec: %w", err)
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(line, codecHeader), "\n")
+
+	codec := c.metadataCodec()
+	if codec.Name() != name {
+		var ok bool
+		if codec, ok = builtinCodecs[name]; !ok {
+			return fmt.Errorf("unknown metadata codec: %q", name)
+		}
+	}
+	return codec.Decode(br, metadata)
+}
+
+// builtinCodecs are the codecs metadata can always be read with, by name
+var builtinCodecs = map[string]MetadataCodec{
+	JSONMetadataCodec.Name():   JSONMetadataCodec,
+	BinaryMetadataCodec.Name(): BinaryMetadataCodec,
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataCodecs(t *testing.T) {
+	ctx := context.Background()
+
+	metadata := Metadata{
+		Key:         "ubuntu-22.04",
+		Filename:    "ubuntu-22.04.img.xz",
+		ContentType: "application/x-xz",
+		Size:        1 << 30,
+		ModTime:     time.Date(2024, 4, 21, 10, 30, 0, 0, time.UTC),
+		Hash:        "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		Tags:        map[string]string{"board": "rk1", "channel": "stable"},
+		OSType:      "ubuntu",
+		OSVersion:   "22.04",
+	}
+
+	newCache := func(t *testing.T, dir string, codec MetadataCodec) *FSCache {
+		t.Helper()
+		c, err := NewFSCache(dir)
+		if err != nil {
+			t.Fatalf("Failed to create FSCache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if codec != nil {
+			c.WithMetadataCodec(codec)
+		}
+		return c
+	}
+
+	for _, codec := range []MetadataCodec{JSONMetadataCodec, BinaryMetadataCodec} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := codec.Encode(&buf, &metadata); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var decoded Metadata
+			if err := codec.Decode(&buf, &decoded); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(decoded, metadata) {
+				t.Errorf("Decoded %+v, want %+v", decoded, metadata)
+			}
+
+			dir := t.TempDir()
+			c := newCache(t, dir, codec)
+			for _, key := range []string{"ubuntu-22.04", "ubuntu-24.04"} {
+				meta := metadata
+				meta.Key = ""
+				meta.Hash = ""
+				if _, err := c.Put(ctx, key, meta, strings.NewReader(key)); err != nil {
+					t.Fatalf("Put(%s) error = %v", key, err)
+				}
+			}
+
+			stat, err := c.Stat(ctx, "ubuntu-22.04")
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if stat.Filename != metadata.Filename || !stat.ModTime.Equal(metadata.ModTime) || stat.Tags["board"] != "rk1" {
+				t.Errorf("Stat() = %+v", stat)
+			}
+
+			// A cache opened later with the default codec rebuilds its index
+			// and reads every item back
+			reopened := newCache(t, dir, nil)
+			items, err := reopened.List(ctx, map[string]string{"board": "rk1"})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var keys []string
+			for _, item := range items {
+				keys = append(keys, item.Key)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != "ubuntu-22.04,ubuntu-24.04" {
+				t.Errorf("List() keys = %v", keys)
+			}
+			if stat, err := reopened.Stat(ctx, "ubuntu-24.04"); err != nil || stat.OSVersion != "22.04" {
+				t.Errorf("Stat() after reopening = %+v, %v", stat, err)
+			}
+			if issues, err := reopened.VerifyIntegrity(ctx); err != nil || len(issues) != 0 {
+				t.Errorf("VerifyIntegrity() = %v, %v", issues, err)
+			}
+		})
+	}
+
+	t.Run("Binary is smaller than JSON", func(t *testing.T) {
+		var text, binary bytes.Buffer
+		if err := JSONMetadataCodec.Encode(&text, &metadata); err != nil {
+			t.Fatalf("JSON Encode() error = %v", err)
+		}
+		if err := BinaryMetadataCodec.Encode(&binary, &metadata); err != nil {
+			t.Fatalf("Binary Encode() error = %v", err)
+		}
+		if binary.Len() >= text.Len() {
+			t.Errorf("Binary metadata is %d bytes, want less than the %d bytes of JSON", binary.Len(), text.Len())
+		}
+
+		// Truncated files are reported, not read as partial metadata
+		var decoded Metadata
+		truncated := bytes.NewReader(binary.Bytes()[:binary.Len()/2])
+		if err := BinaryMetadataCodec.Decode(truncated, &decoded); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Decode() of a truncated file error = %v, want io.ErrUnexpectedEOF", err)
+		}
+	})
+
+	t.Run("Format is recorded in metadata files", func(t *testing.T) {
+		c := newCache(t, t.TempDir(), BinaryMetadataCodec)
+		if _, err := c.Put(ctx, "binary", Metadata{Filename: "binary.img"}, strings.NewReader("binary")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		data, _ := os.ReadFile(c.getMetadataPath("binary"))
+		if !bytes.HasPrefix(data, []byte("#codec:binary\n")) {
+			t.Errorf("Binary metadata file starts with %q", data[:min(len(data), 16)])
+		}
+
+		// JSON files keep the format caches were always written with
+		c.WithMetadataCodec(JSONMetadataCodec)
+		if _, err := c.Put(ctx, "text", Metadata{Filename: "text.img"}, strings.NewReader("text")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		data, _ = os.ReadFile(c.getMetadataPath("text"))
+		if !bytes.HasPrefix(data, []byte("{")) {
+			t.Errorf("JSON metadata file = %s", data)
+		}
+
+		// Both formats are listed together
+		if items, err := c.List(ctx, nil); err != nil || len(items) != 2 {
+			t.Errorf("List() = %v, %v", items, err)
+		}
+	})
+
+	t.Run("Unknown codec", func(t *testing.T) {
+		c := newCache(t, t.TempDir(), nil)
+		if _, err := c.Put(ctx, "item", Metadata{}, strings.NewReader("item")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if err := os.WriteFile(c.getMetadataPath("item"), []byte("#codec:msgpack\n\x81\xa3key"), 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+		if _, err := c.Stat(ctx, "item"); err == nil || !strings.Contains(err.Error(), "msgpack") {
+			t.Errorf("Stat() error = %v, want the unknown codec reported", err)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChecksumMismatch is returned by FetchURL when the downloaded content does
+// not have the expected checksum
+var ErrChecksumMismatch = errors.New("downloaded content does not match the expected checksum")
+
+// FetchOptions configures FetchURL
+type FetchOptions struct {
+	// SHA256 is the expected hex-encoded SHA-256 of the content, checked
+	// before the item is stored when set
+	SHA256 string
+	// Metadata is stored with the item. Its Hash and Size are set from the
+	// downloaded content and Filename defaults to the last element of the URL.
+	Metadata Metadata
+	// Client performs the requests (default http.DefaultClient)
+	Client *http.Client
+	// MaxResumes is how many times an interrupted download is resumed before
+	// giving up (default 3, negative to never resume within a call)
+	MaxResumes int
+	// ResumeDelay is the wait before resuming an interrupted download (default 1 second)
+	ResumeDelay time.Duration
+}
+
+// withDefaults returns the options with defaults applied
+func (o FetchOptions) withDefaults() FetchOptions {
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.MaxResumes == 0 {
+		o.MaxResumes = 3
+	}
+	if o.MaxResumes < 0 {
+		o.MaxResumes = 0
+	}
+	if o.ResumeDelay == 0 {
+		o.ResumeDelay = time.Second
+	}
+	return o
+}
+
+// getPartialPath returns the path content being downloaded for key is written to
+func (c *FSCache) getPartialPath(key string) string {
+	return c.getContentPath(key) + ".partial"
+}
+
+// validatorPath returns the path of the file holding the validator of the
+// content in a partial file, sent as If-Range when resuming
+func validatorPath(partialPath string) string {
+	return partialPath + ".validator"
+}
+
+// removePartial removes a partial file and its validator
+func removePartial(partialPath string) {
+	os.Remove(partialPath)
+	os.Remove(validatorPath(partialPath))
+}
+
+// FetchURL downloads the content at rawURL and stores it under key, returning
+// the stored metadata. The content is written to a .data.partial file first: an
+// interrupted download is resumed with an HTTP Range request, within the same
+// call up to MaxResumes times, and otherwise by the next call for the key. The
+// range is conditioned with If-Range on the ETag or Last-Modified of the
+// response the partial content comes from, kept in a .validator file, so that
+// content changed on the server, or served without either, is downloaded again
+// from the start. Once complete the content is checked against the expected
+// checksum, if any, and promoted to the key. Content failing the check is
+// discarded and ErrChecksumMismatch is returned. An item already stored under
+// key is returned without downloading, and concurrent calls for a key share a
+// single download.
+func (c *FSCache) FetchURL(ctx context.Context, key, rawURL string, opts FetchOptions) (*Metadata, error) {
+	opts = opts.withDefaults()
+
+	exists, err := c.Exists(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := c.singleFlight(ctx, key, func() error { return c.download(ctx, key, rawURL, opts) }); err != nil {
+			return nil, err
+		}
+	}
+	metadata, err := c.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Key = key
+	return metadata, nil
+}
+
+// download fetches rawURL into the partial file of key, resuming it when
+// interrupted, and promotes it once complete
+func (c *FSCache) download(ctx context.Context, key, rawURL string, opts FetchOptions) error {
+	// The item may have been stored while this call was being registered
+	if exists, err := c.Exists(ctx, key); err != nil || exists {
+		return err
+	}
+
+	partialPath := c.getPartialPath(key)
+	if err := os.MkdirAll(filepath.Dir(partialPath), 0755); err != nil {
+		return fmt.Errorf("failed to create content directory: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.downloadRange(ctx, rawURL, partialPath, opts.Client)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil || attempt >= opts.MaxResumes || !resumable(err) {
+			return fmt.Errorf("failed to download %s: %w", rawURL, err)
+		}
+
+		select {
+		case <-time.After(opts.ResumeDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return c.promotePartial(key, rawURL, opts)
+}
+
+// httpStatusError reports a response status that is not a successful download
+type httpStatusError struct {
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d %s", e.status, http.StatusText(e.status))
+}
+
+// resumable reports whether a download failing with err may succeed when
+// resumed. Server errors may be transient, other statuses are final.
+func resumable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= 500
+	}
+	return true
+}
+
+// downloadRange appends to partialPath the content of rawURL it does not hold
+// yet, or downloads it again from the start when it changed
+func (c *FSCache) downloadRange(ctx context.Context, rawURL, partialPath string, client *http.Client) error {
+	var offset int64
+	if info, err := os.Stat(partialPath); err == nil {
+		offset = info.Size()
+	}
+	validator, _ := os.ReadFile(validatorPath(partialPath))
+	if offset > 0 && len(validator) == 0 {
+		// Nothing tells whether the content is still the same
+		removePartial(partialPath)
+		offset = 0
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", string(validator))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if contentRange := resp.Header.Get("Content-Range"); contentRangeStart(contentRange) != offset {
+			removePartial(partialPath)
+			return fmt.Errorf("server sent range %q instead of resuming at byte %d", contentRange, offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// The server ignored the range, or the content changed, and sends the
+		// whole content
+		flags |= os.O_TRUNC
+		if err := saveValidator(partialPath, resp.Header); err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file already holds the whole content
+		return nil
+	default:
+		return &httpStatusError{status: resp.StatusCode}
+	}
+
+	file, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// saveValidator records the validator of a response sending the whole
+// content: its ETag unless weak, which If-Range does not accept, or else its
+// Last-Modified date. A response without either leaves none, so the download
+// is not resumed.
+func saveValidator(partialPath string, header http.Header) error {
+	validator := header.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		validator = ""
+	}
+	if validator == "" {
+		validator = header.Get("Last-Modified")
+	}
+
+	validatorFile := validatorPath(partialPath)
+	if validator == "" {
+		if err := os.Remove(validatorFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove download validator: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(validatorFile, []byte(validator), 0644); err != nil {
+		return fmt.Errorf("failed to save download validator: %w", err)
+	}
+	return nil
+}
+
+// contentRangeStart returns the first byte of a "bytes start-end/size"
+// Content-Range header, -1 when it cannot be parsed
+func contentRangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// promotePartial verifies the downloaded content of key and stores it as the
+// content of the item
+func (c *FSCache) promotePartial(key, rawURL string, opts FetchOptions) error {
+	partialPath := c.getPartialPath(key)
+
+	file, err := os.Open(partialPath)
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to hash downloaded content: %w", err)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if opts.SHA256 != "" && !strings.EqualFold(sum, opts.SHA256) {
+		// Resuming would only append to corrupt content
+		removePartial(partialPath)
+		return fmt.Errorf("%w: %s has SHA-256 %s, want %s", ErrChecksumMismatch, rawURL, sum, opts.SHA256)
+	}
+
+	metadata := opts.Metadata
+	metadata.Hash = sum
+	metadata.Size = size
+	if metadata.Filename == "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			metadata.Filename = path.Base(u.Path)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	contentPath := c.getContentPath(key)
+	if err := os.Rename(partialPath, contentPath); err != nil {
+		return fmt.Errorf("failed to promote downloaded content: %w", err)
+	}
+	os.Remove(validatorPath(partialPath))
+	if len(c.hmacSecret) > 0 {
+		c.signMetadata(key, &metadata)
+	}
+	if err := c.writeMetadata(key, &metadata); err != nil {
+		os.Remove(contentPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// imageServer serves content at /images/rk1.img, cutting the connection
+// half way through the responses listed in disconnect
+type imageServer struct {
+	*httptest.Server
+
+	content []byte
+	// ignoreRanges makes the server always send the whole content
+	ignoreRanges bool
+
+	mu         sync.Mutex
+	etag       string
+	ranges     []string
+	ifRanges   []string
+	disconnect map[int]bool
+}
+
+func newImageServer(t *testing.T, content []byte, disconnect ...int) *imageServer {
+	t.Helper()
+	s := &imageServer{content: content, etag: `"v1"`, disconnect: make(map[int]bool)}
+	for _, request := range disconnect {
+		s.disconnect[request] = true
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/images/rk1.img" {
+			http.NotFound(w, r)
+			return
+		}
+
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+		cut := s.disconnect[len(s.ranges)]
+		if s.etag != "" {
+			w.Header().Set("ETag", s.etag)
+		}
+		s.mu.Unlock()
+
+		if cut {
+			// Announce the whole content but send only half of it
+			w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(s.content[:len(s.content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if s.ignoreRanges {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "rk1.img", time.Time{}, bytes.NewReader(s.content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// requests returns the Range header of every request received
+func (s *imageServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+// conditions returns the If-Range header of every request received
+func (s *imageServer) conditions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ifRanges...)
+}
+
+// setETag changes the ETag the content is served with
+func (s *imageServer) setETag(etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag = etag
+}
+
+func TestFSCacheFetchURL(t *testing.T) {
+	ctx := context.Background()
+
+	content := make([]byte, 256*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	resumeAt := "bytes=" + strconv.Itoa(len(content)/2) + "-"
+
+	newCache := func(t *testing.T) *FSCache {
+		t.Helper()
+		c, err := NewFSCache(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create FSCache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	// checkStored asserts the cache holds the content under key
+	checkStored := func(t *testing.T, c *FSCache, key string) {
+		t.Helper()
+		metadata, reader, err := c.Get(ctx, key, true)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer reader.Close()
+		stored, _ := io.ReadAll(reader)
+		if !bytes.Equal(stored, content) {
+			t.Errorf("Stored %d bytes differing from the served content", len(stored))
+		}
+		if metadata.Hash != checksum || metadata.Size != int64(len(content)) {
+			t.Errorf("Metadata = %+v", metadata)
+		}
+		if _, err := os.Stat(c.getPartialPath(key)); !os.IsNotExist(err) {
+			t.Errorf("Partial file left behind: %v", err)
+		}
+	}
+
+	opts := FetchOptions{SHA256: checksum, ResumeDelay: time.Millisecond}
+
+	t.Run("Interrupted download is resumed", func(t *testing.T) {
+		server := newImageServer(t, content, 1)
+		c := newCache(t)
+
+		metadata, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", opts)
+		if err != nil {
+			t.Fatalf("FetchURL() error = %v", err)
+		}
+		if metadata.Filename != "rk1.img" || metadata.Key != "rk1" {
+			t.Errorf("FetchURL() metadata = %+v", metadata)
+		}
+		if got := server.requests(); len(got) != 2 || got[0] != "" || got[1] != resumeAt {
+			t.Errorf("Requested ranges %q, want a resume at %s", got, resumeAt)
+		}
+		if got := server.conditions(); got[1] != `"v1"` {
+			t.Errorf("If-Range = %q, want the ETag of the interrupted response", got)
+		}
+		checkStored(t, c, "rk1")
+		if _, err := os.Stat(validatorPath(c.getPartialPath("rk1"))); !os.IsNotExist(err) {
+			t.Errorf("Validator left behind: %v", err)
+		}
+
+		// Stored items are not downloaded again
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", opts); err != nil {
+			t.Fatalf("FetchURL() of a stored item error = %v", err)
+		}
+		if got := server.requests(); len(got) != 2 {
+			t.Errorf("Stored item downloaded again: %q", got)
+		}
+	})
+
+	t.Run("Partial file is resumed by the next call", func(t *testing.T) {
+		server := newImageServer(t, content, 1)
+		c := newCache(t)
+
+		noResume := opts
+		noResume.MaxResumes = -1
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", noResume); err == nil {
+			t.Fatal("FetchURL() expected an error for the interrupted download")
+		}
+		if info, err := os.Stat(c.getPartialPath("rk1")); err != nil || info.Size() != int64(len(content)/2) {
+			t.Fatalf("Partial file = %v, %v", info, err)
+		}
+		if exists, _ := c.Exists(ctx, "rk1"); exists {
+			t.Error("Interrupted download was stored")
+		}
+
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", noResume); err != nil {
+			t.Fatalf("FetchURL() error = %v", err)
+		}
+		if got := server.requests(); len(got) != 2 || got[1] != resumeAt {
+			t.Errorf("Requested ranges %q, want a resume at %s", got, resumeAt)
+		}
+		checkStored(t, c, "rk1")
+	})
+
+	t.Run("Content changed on the server", func(t *testing.T) {
+		server := newImageServer(t, content, 1)
+		c := newCache(t)
+
+		noResume := opts
+		noResume.MaxResumes = -1
+		c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", noResume)
+		server.setETag(`"v2"`)
+
+		// The server answers the stale If-Range with the whole content
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", noResume); err != nil {
+			t.Fatalf("FetchURL() error = %v", err)
+		}
+		if got := server.requests(); len(got) != 2 || got[1] != resumeAt {
+			t.Errorf("Requested ranges %q, want a conditional resume", got)
+		}
+		checkStored(t, c, "rk1")
+	})
+
+	t.Run("Content without validator is not resumed", func(t *testing.T) {
+		server := newImageServer(t, content, 1)
+		server.setETag("")
+		c := newCache(t)
+
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", opts); err != nil {
+			t.Fatalf("FetchURL() error = %v", err)
+		}
+		if got := server.requests(); len(got) != 2 || got[1] != "" {
+			t.Errorf("Requested ranges %q, want the download restarted from zero", got)
+		}
+		checkStored(t, c, "rk1")
+	})
+
+	t.Run("Server ignoring ranges", func(t *testing.T) {
+		server := newImageServer(t, content, 1)
+		server.ignoreRanges = true
+		c := newCache(t)
+
+		if _, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", opts); err != nil {
+			t.Fatalf("FetchURL() error = %v", err)
+		}
+		checkStored(t, c, "rk1")
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		server := newImageServer(t, content)
+		c := newCache(t)
+
+		wrong := opts
+		wrong.SHA256 = strings.Repeat("0", 64)
+		_, err := c.FetchURL(ctx, "rk1", server.URL+"/images/rk1.img", wrong)
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("FetchURL() error = %v, want ErrChecksumMismatch", err)
+		}
+		if exists, _ := c.Exists(ctx, "rk1"); exists {
+			t.Error("Content failing the checksum was stored")
+		}
+		if _, err := os.Stat(c.getPartialPath("rk1")); !os.IsNotExist(err) {
+			t.Errorf("Corrupt partial file kept: %v", err)
+		}
+	})
+
+	t.Run("Missing file is not retried", func(t *testing.T) {
+		server := newImageServer(t, content)
+		c := newCache(t)
+
+		if _, err := c.FetchURL(ctx, "missing", server.URL+"/images/missing.img", opts); err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("FetchURL() error = %v, want the 404 reported", err)
+		}
+	})
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := map[string]int64{
+		"bytes 1024-2047/2048": 1024,
+		"bytes 0-99/*":         0,
+		"bytes */2048":         -1,
+		"":                     -1,
+	}
+	for header, want := range tests {
+		if got := contentRangeStart(header); got != want {
+			t.Errorf("contentRangeStart(%q) = %d, want %d", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// FetchFunc produces the content and metadata of a missing cache item
+type FetchFunc func(ctx context.Context) (io.Reader, Metadata, error)
+
+// fetchCall tracks a fetch shared by every caller waiting on the same key
+type fetchCall struct {
+	done chan struct{}
+	err  error
+}
+
+// GetOrFetch returns the content of a cached item, fetching and storing it
+// first if it is missing. Concurrent callers for the same missing key share a
+// single fetch: the first caller runs it while the others wait for the result.
+// A failed fetch is reported to every waiting caller and is not cached, so the
+// next call fetches again.
+func (c *FSCache) GetOrFetch(ctx context.Context, key string, fetch FetchFunc) (*Metadata, io.ReadCloser, error) {
+	exists, err := c.Exists(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		return c.Get(ctx, key, true)
+	}
+
+	if err := c.singleFlight(ctx, key, func() error { return c.fetchAndPut(ctx, key, fetch) }); err != nil {
+		return nil, nil, err
+	}
+	return c.Get(ctx, key, true)
+}
+
+// singleFlight runs fn unless another call for key is already running it,
+// in which case it waits for that call and returns its result instead
+func (c *FSCache) singleFlight(ctx context.Context, key string, fn func() error) error {
+	c.fetchMu.Lock()
+	if call, ok := c.fetches[key]; ok {
+		c.fetchMu.Unlock()
+
+		// Another caller is already fetching this key
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return call.err
+	}
+
+	call := &fetchCall{done: make(chan struct{})}
+	c.fetches[key] = call
+	c.fetchMu.Unlock()
+
+	call.err = fn()
+
+	c.fetchMu.Lock()
+	delete(c.fetches, key)
+	c.fetchMu.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+// fetchAndPut runs fetch and stores its result under key
+func (c *FSCache) fetchAndPut(ctx context.Context, key string, fetch FetchFunc) error {
+	// The item may have been stored while this call was being registered
+	exists, err := c.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	reader, metadata, err := fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cache item %s: %w", key, err)
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if _, err := c.Put(ctx, key, metadata, reader); err != nil {
+		return fmt.Errorf("failed to store fetched cache item %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFSCacheGetOrFetch(t *testing.T) {
+	cache, err := NewFSCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	t.Run("Concurrent callers share one fetch", func(t *testing.T) {
+		const callers = 50
+		content := "downloaded image content"
+
+		var fetches int32
+		release := make(chan struct{})
+		fetch := func(ctx context.Context) (io.Reader, Metadata, error) {
+			atomic.AddInt32(&fetches, 1)
+			// Hold the fetch until every caller has been started
+			<-release
+			return strings.NewReader(content), Metadata{Filename: "image.img", Size: int64(len(content))}, nil
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, callers)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				metadata, reader, err := cache.GetOrFetch(ctx, "shared", fetch)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer reader.Close()
+
+				data, err := io.ReadAll(reader)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(data) != content || metadata.Filename != "image.img" {
+					errs <- errors.New("unexpected content or metadata: " + string(data))
+				}
+			}()
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+		if n := atomic.LoadInt32(&fetches); n != 1 {
+			t.Errorf("fetch ran %d times, want 1", n)
+		}
+
+		// Later calls are served from the cache
+		_, reader, err := cache.GetOrFetch(ctx, "shared", fetch)
+		if err != nil {
+			t.Fatalf("GetOrFetch() error = %v", err)
+		}
+		reader.Close()
+		if n := atomic.LoadInt32(&fetches); n != 1 {
+			t.Errorf("fetch ran %d times after the item was cached, want 1", n)
+		}
+	})
+
+	t.Run("Failed fetch is shared and not cached", func(t *testing.T) {
+		errFetch := errors.New("network unreachable")
+		var fetches int32
+		failing := func(ctx context.Context) (io.Reader, Metadata, error) {
+			atomic.AddInt32(&fetches, 1)
+			return nil, Metadata{}, errFetch
+		}
+
+		if _, _, err := cache.GetOrFetch(ctx, "failing", failing); !errors.Is(err, errFetch) {
+			t.Fatalf("GetOrFetch() error = %v, want fetch error", err)
+		}
+		if exists, _ := cache.Exists(ctx, "failing"); exists {
+			t.Error("Failed fetch should not create a cache item")
+		}
+
+		if _, _, err := cache.GetOrFetch(ctx, "failing", failing); !errors.Is(err, errFetch) {
+			t.Fatalf("GetOrFetch() error = %v, want fetch error", err)
+		}
+		if n := atomic.LoadInt32(&fetches); n != 2 {
+			t.Errorf("fetch ran %d times, want a retry after the failure", n)
+		}
+	})
+}
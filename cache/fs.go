@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -21,6 +20,28 @@ type FSCache struct {
 	mu       sync.RWMutex
 	index    *Index
 	indexMgr *IndexManager
+
+	// In-flight fetches started by GetOrFetch, by key
+	fetchMu sync.Mutex
+	fetches map[string]*fetchCall
+
+	// Secret used to sign and verify metadata, see WithHMAC
+	hmacSecret []byte
+
+	// Whether files are nested under hashed subdirectories, see WithShardedLayout
+	sharded bool
+
+	// Codec new metadata is written with, JSON when nil, see WithMetadataCodec
+	codec MetadataCodec
+
+	// Keys recently found missing with when the miss expires, see
+	// WithNegativeCache
+	negativeTTL time.Duration
+	missMu      sync.Mutex
+	misses      map[string]time.Time
+
+	// Stats cache files, os.Stat when nil
+	stat func(string) (os.FileInfo, error)
 }
 
 // NewFSCache creates a new filesystem-based cache at the specified directory
@@ -32,6 +53,7 @@ func NewFSCache(baseDir string) (*FSCache, error) {
 	cache := &FSCache{
 		baseDir: baseDir,
 		index:   NewIndex(),
+		fetches: make(map[string]*fetchCall),
 	}
 
 	// Create index manager with 5-minute refresh interval
@@ -45,12 +67,12 @@ func NewFSCache(baseDir string) (*FSCache, error) {
 
 // getMetadataPath returns the path where metadata file should be stored
 func (c *FSCache) getMetadataPath(key string) string {
-	return filepath.Join(c.baseDir, key+".meta")
+	return c.keyPath(key) + ".meta"
 }
 
 // getContentPath returns the path where content file should be stored
 func (c *FSCache) getContentPath(key string) string {
-	return filepath.Join(c.baseDir, key+".data")
+	return c.keyPath(key) + ".data"
 }
 
 func (c *FSCache) Put(ctx context.Context, key string, metadata Metadata, reader io.Reader) (*Metadata, error) {
@@ -86,36 +108,47 @@ func (c *FSCache) Put(ctx context.Context, key string, metadata Metadata, reader
 			return nil, fmt.Errorf("failed to write content: %w", err)
 		}
 
-		if metadata.Hash == "" {
+		// Signed metadata must describe the content actually written
+		if metadata.Hash == "" || len(c.hmacSecret) > 0 {
 			metadata.Hash = hex.EncodeToString(hash.Sum(nil))
 		}
 	}
 
-	// Write metadata
+	if len(c.hmacSecret) > 0 {
+		c.signMetadata(key, &metadata)
+	}
+
+	if err := c.writeMetadata(key, &metadata); err != nil {
+		os.Remove(contentPath)
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// writeMetadata writes the metadata file of key and adds the item to the
+// index. It must be called with c.mu held.
+func (c *FSCache) writeMetadata(key string, metadata *Metadata) error {
 	metadataPath := c.getMetadataPath(key)
 	if err := os.MkdirAll(filepath.Dir(metadataPath), 0755); err != nil {
-		os.Remove(contentPath)
-		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+		return fmt.Errorf("failed to create metadata directory: %w", err)
 	}
 
 	metadataFile, err := os.Create(metadataPath)
 	if err != nil {
-		os.Remove(contentPath)
-		return nil, fmt.Errorf("failed to create metadata file: %w", err)
+		return fmt.Errorf("failed to create metadata file: %w", err)
 	}
 	defer metadataFile.Close()
 
-	if err := json.NewEncoder(metadataFile).Encode(metadata); err != nil {
-		os.Remove(contentPath)
+	if err := c.encodeMetadata(metadataFile, metadata); err != nil {
 		os.Remove(metadataPath)
-		return nil, fmt.Errorf("failed to write metadata: %w", err)
+		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
 	// Update index
 	metadata.Key = key
-	c.index.updateIndex(&metadata)
-
-	return &metadata, nil
+	c.index.updateIndex(metadata)
+	c.forgetMiss(key)
+	return nil
 }
 
 func (c *FSCache) Get(ctx context.Context, key string, getContent bool) (*Metadata, io.ReadCloser, error) {
@@ -133,6 +166,9 @@ func (c *FSCache) Get(ctx context.Context, key string, getContent bool) (*Metada
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := c.verifyMetadata(key, metadata); err != nil {
+		return nil, nil, err
+	}
 
 	if !getContent {
 		return metadata, nil, nil
@@ -158,16 +194,23 @@ func (c *FSCache) Stat(ctx context.Context, key string) (*Metadata, error) {
 	default:
 	}
 
+	if c.knownMissing(key) {
+		return nil, fmt.Errorf("failed to open metadata file: %w", c.missingMetadataError(key))
+	}
+
 	// Read metadata file
 	metadataPath := c.getMetadataPath(key)
 	metadataFile, err := os.Open(metadataPath)
 	if err != nil {
+		if os.IsNotExist(err) {
+			c.recordMiss(key)
+		}
 		return nil, fmt.Errorf("failed to open metadata file: %w", err)
 	}
 	defer metadataFile.Close()
 
 	var metadata Metadata
-	if err := json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
+	if err := c.decodeMetadata(metadataFile, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
 
@@ -184,11 +227,16 @@ func (c *FSCache) Exists(ctx context.Context, key string) (bool, error) {
 	default:
 	}
 
-	_, err := os.Stat(c.getMetadataPath(key))
+	if c.knownMissing(key) {
+		return false, nil
+	}
+
+	_, err := c.statFile(c.getMetadataPath(key))
 	if err == nil {
 		return true, nil
 	}
 	if os.IsNotExist(err) {
+		c.recordMiss(key)
 		return false, nil
 	}
 	return false, err
@@ -267,6 +315,12 @@ func (c *FSCache) Delete(ctx context.Context, key string) error {
 	default:
 	}
 
+	return c.remove(key)
+}
+
+// remove deletes the metadata and content files of key and drops it from
+// the index. It must be called with c.mu held.
+func (c *FSCache) remove(key string) error {
 	// Remove both metadata and content files
 	metadataPath := c.getMetadataPath(key)
 	contentPath := c.getContentPath(key)
@@ -279,6 +333,8 @@ func (c *FSCache) Delete(ctx context.Context, key string) error {
 		return fmt.Errorf("failed to remove content file: %w", err)
 	}
 
+	c.removeShardDirs(key)
+
 	// Update index
 	c.index.removeFromIndex(key)
 
@@ -334,7 +390,10 @@ func (c *FSCache) RebuildIndex(ctx context.Context) error {
 			}
 
 			// Extract key by removing .meta extension and converting to cache key format
-			key := strings.TrimSuffix(relPath, ".meta")
+			key, ok := c.keyFromPath(relPath)
+			if !ok {
+				return nil // Not part of the current layout
+			}
 
 			// Read metadata file directly instead of using Stat to avoid lock contention
 			metadataFile, err := os.Open(path)
@@ -344,7 +403,7 @@ func (c *FSCache) RebuildIndex(ctx context.Context) error {
 			defer metadataFile.Close()
 
 			var metadata Metadata
-			if err := json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
+			if err := c.decodeMetadata(metadataFile, &metadata); err != nil {
 				return nil // Skip invalid entries
 			}
 
@@ -492,7 +551,7 @@ func (c *FSCache) VerifyIntegrity(ctx context.Context) ([]string, error) {
 			defer metadataFile.Close()
 
 			var metadata Metadata
-			if err := json.NewDecoder(metadataFile).Decode(&metadata); err != nil {
+			if err := c.decodeMetadata(metadataFile, &metadata); err != nil {
 				issues = append(issues, fmt.Sprintf("corrupted metadata file: %s: %v", relPath, err))
 				return nil
 			}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrMetadataTampered is returned when the HMAC of an item's metadata does not verify
+var ErrMetadataTampered = errors.New("cache metadata failed HMAC verification")
+
+// WithHMAC makes the cache sign the metadata of items it stores with an
+// HMAC-SHA256 over their key and content hash, and verify it when items are
+// read. Items stored without a signature fail verification once enabled.
+// It must be called before the cache is used.
+func (c *FSCache) WithHMAC(secret []byte) *FSCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hmacSecret = append([]byte(nil), secret...)
+	return c
+}
+
+// signMetadata sets the HMAC of metadata stored under key
+func (c *FSCache) signMetadata(key string, metadata *Metadata) {
+	metadata.HMAC = metadataHMAC(c.hmacSecret, key, metadata.Hash)
+}
+
+// verifyMetadata checks the HMAC of metadata stored under key
+func (c *FSCache) verifyMetadata(key string, metadata *Metadata) error {
+	if len(c.hmacSecret) == 0 {
+		return nil
+	}
+
+	expected, err := hex.DecodeString(metadataHMAC(c.hmacSecret, key, metadata.Hash))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(metadata.HMAC)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return fmt.Errorf("%w: %s", ErrMetadataTampered, key)
+	}
+	return nil
+}
+
+// metadataHMAC computes the hex-encoded HMAC of a key and its content hash
+func metadataHMAC(secret []byte, key, contentHash string) string {
+	mac := hmac.New(sha256.New, secret)
+	// The separator keeps ("ab", "c") and ("a", "bc") from signing the same bytes
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(contentHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFSCacheHMAC(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("ci-secret")
+
+	newCache := func(t *testing.T, dir string) *FSCache {
+		t.Helper()
+		c, err := NewFSCache(dir)
+		if err != nil {
+			t.Fatalf("Failed to create FSCache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+
+	put := func(t *testing.T, c *FSCache, key, content string) {
+		t.Helper()
+		if _, err := c.Put(ctx, key, Metadata{Filename: key + ".img"}, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+
+	rewriteMetadata := func(t *testing.T, c *FSCache, key string, mutate func(*Metadata)) {
+		t.Helper()
+		data, err := os.ReadFile(c.getMetadataPath(key))
+		if err != nil {
+			t.Fatalf("Failed to read metadata: %v", err)
+		}
+		var metadata Metadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			t.Fatalf("Failed to decode metadata: %v", err)
+		}
+		mutate(&metadata)
+		data, _ = json.Marshal(metadata)
+		if err := os.WriteFile(c.getMetadataPath(key), data, 0644); err != nil {
+			t.Fatalf("Failed to write metadata: %v", err)
+		}
+	}
+
+	t.Run("Signed items round-trip", func(t *testing.T) {
+		c := newCache(t, t.TempDir()).WithHMAC(secret)
+		put(t, c, "image", "image content")
+
+		metadata, reader, err := c.Get(ctx, "image", true)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer reader.Close()
+		if metadata.HMAC == "" {
+			t.Error("Metadata was not signed")
+		}
+		if data, _ := io.ReadAll(reader); string(data) != "image content" {
+			t.Errorf("Get() content = %q", data)
+		}
+	})
+
+	t.Run("Mutated metadata fails verification", func(t *testing.T) {
+		c := newCache(t, t.TempDir()).WithHMAC(secret)
+		put(t, c, "image", "image content")
+
+		rewriteMetadata(t, c, "image", func(m *Metadata) {
+			m.Hash = strings.Repeat("0", 64)
+		})
+		if _, _, err := c.Get(ctx, "image", true); !errors.Is(err, ErrMetadataTampered) {
+			t.Errorf("Get() error = %v, want ErrMetadataTampered", err)
+		}
+	})
+
+	t.Run("Swapping data and metadata fails verification", func(t *testing.T) {
+		c := newCache(t, t.TempDir()).WithHMAC(secret)
+		put(t, c, "trusted", "trusted content")
+		put(t, c, "other", "other content")
+
+		// Replace the trusted item with another correctly signed item
+		for _, path := range [][2]string{
+			{c.getMetadataPath("other"), c.getMetadataPath("trusted")},
+			{c.getContentPath("other"), c.getContentPath("trusted")},
+		} {
+			data, err := os.ReadFile(path[0])
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", path[0], err)
+			}
+			if err := os.WriteFile(path[1], data, 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", path[1], err)
+			}
+		}
+
+		if _, _, err := c.Get(ctx, "trusted", false); !errors.Is(err, ErrMetadataTampered) {
+			t.Errorf("Get() error = %v, want ErrMetadataTampered", err)
+		}
+	})
+
+	t.Run("Unsigned or foreign items fail verification", func(t *testing.T) {
+		dir := t.TempDir()
+		unsigned := newCache(t, dir)
+		put(t, unsigned, "unsigned", "content")
+
+		c := newCache(t, dir).WithHMAC(secret)
+		if _, _, err := c.Get(ctx, "unsigned", true); !errors.Is(err, ErrMetadataTampered) {
+			t.Errorf("Get() of an unsigned item error = %v, want ErrMetadataTampered", err)
+		}
+
+		rewriteMetadata(t, c, "unsigned", func(m *Metadata) {
+			m.HMAC = metadataHMAC([]byte("another-secret"), "unsigned", m.Hash)
+		})
+		if _, _, err := c.Get(ctx, "unsigned", true); !errors.Is(err, ErrMetadataTampered) {
+			t.Errorf("Get() of an item signed with another secret error = %v, want ErrMetadataTampered", err)
+		}
+	})
+
+	t.Run("Verification is off without a secret", func(t *testing.T) {
+		c := newCache(t, t.TempDir())
+		put(t, c, "image", "image content")
+
+		rewriteMetadata(t, c, "image", func(m *Metadata) {
+			m.Hash = strings.Repeat("0", 64)
+		})
+		if _, _, err := c.Get(ctx, "image", false); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	})
+}
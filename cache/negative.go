@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// WithNegativeCache makes the cache remember for ttl the keys Exists and Stat
+// found missing, so repeated checks for a missing key do not hit the disk.
+// Storing the key forgets the miss right away. Items written to the
+// directory by another process are only seen once the miss has expired.
+// Zero or less disables it (default). It must be called before the cache is
+// used.
+func (c *FSCache) WithNegativeCache(ttl time.Duration) *FSCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.negativeTTL = ttl
+	c.misses = make(map[string]time.Time)
+	return c
+}
+
+// statFile stats a cache file, with os.Stat unless replaced by tests
+func (c *FSCache) statFile(path string) (os.FileInfo, error) {
+	if c.stat != nil {
+		return c.stat(path)
+	}
+	return os.Stat(path)
+}
+
+// knownMissing reports whether key was found missing less than the negative
+// cache TTL ago
+func (c *FSCache) knownMissing(key string) bool {
+	if c.negativeTTL <= 0 {
+		return false
+	}
+
+	c.missMu.Lock()
+	defer c.missMu.Unlock()
+
+	until, ok := c.misses[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(c.misses, key)
+		return false
+	}
+	return true
+}
+
+// recordMiss remembers key as missing. It must be called with the read or
+// write lock held, so a concurrent Put cannot store the key in between.
+func (c *FSCache) recordMiss(key string) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+
+	c.missMu.Lock()
+	c.misses[key] = time.Now().Add(c.negativeTTL)
+	c.missMu.Unlock()
+}
+
+// forgetMiss drops the negative entry of key once it has been stored
+func (c *FSCache) forgetMiss(key string) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+
+	c.missMu.Lock()
+	delete(c.misses, key)
+	c.missMu.Unlock()
+}
+
+// missingMetadataError is the error Stat returns for a key known missing,
+// matching the one of a failed open
+func (c *FSCache) missingMetadataError(key string) error {
+	return &fs.PathError{Op: "open", Path: c.getMetadataPath(key), Err: fs.ErrNotExist}
+}
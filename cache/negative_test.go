@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCountingCache creates a cache with a negative cache of ttl, counting
+// the files it stats
+func newCountingCache(t *testing.T, ttl time.Duration) (*FSCache, *atomic.Int32) {
+	t.Helper()
+	c, err := NewFSCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	var stats atomic.Int32
+	c.stat = func(path string) (os.FileInfo, error) {
+		stats.Add(1)
+		return os.Stat(path)
+	}
+	return c.WithNegativeCache(ttl), &stats
+}
+
+func TestFSCacheNegativeCache(t *testing.T) {
+	ctx := context.Background()
+
+	exists := func(t *testing.T, c *FSCache, key string) bool {
+		t.Helper()
+		ok, err := c.Exists(ctx, key)
+		if err != nil {
+			t.Fatalf("Exists(%s) error = %v", key, err)
+		}
+		return ok
+	}
+
+	t.Run("Repeated misses do not stat", func(t *testing.T) {
+		c, stats := newCountingCache(t, time.Minute)
+		for i := 0; i < 3; i++ {
+			if exists(t, c, "missing") {
+				t.Fatal("Exists() = true for a missing key")
+			}
+		}
+		if n := stats.Load(); n != 1 {
+			t.Errorf("Exists() stat the filesystem %d times, want 1", n)
+		}
+
+		if _, err := c.Stat(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat() error = %v, want ErrNotExist", err)
+		}
+	})
+
+	t.Run("Put clears the miss", func(t *testing.T) {
+		c, stats := newCountingCache(t, time.Minute)
+		if exists(t, c, "image") {
+			t.Fatal("Exists() = true before Put")
+		}
+		if _, err := c.Put(ctx, "image", Metadata{Filename: "image.img"}, strings.NewReader("data")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if !exists(t, c, "image") {
+			t.Error("Exists() = false after Put")
+		}
+		if n := stats.Load(); n != 2 {
+			t.Errorf("Exists() stat the filesystem %d times, want 2", n)
+		}
+		if _, err := c.Stat(ctx, "image"); err != nil {
+			t.Errorf("Stat() error = %v after Put", err)
+		}
+	})
+
+	t.Run("Stat misses are shared with Exists", func(t *testing.T) {
+		c, stats := newCountingCache(t, time.Minute)
+		if _, err := c.Stat(ctx, "missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Stat() error = %v, want ErrNotExist", err)
+		}
+		if exists(t, c, "missing") {
+			t.Fatal("Exists() = true for a missing key")
+		}
+		if n := stats.Load(); n != 0 {
+			t.Errorf("Exists() stat the filesystem %d times, want 0", n)
+		}
+	})
+
+	t.Run("Misses expire", func(t *testing.T) {
+		c, stats := newCountingCache(t, 10*time.Millisecond)
+		exists(t, c, "missing")
+		time.Sleep(20 * time.Millisecond)
+		exists(t, c, "missing")
+		if n := stats.Load(); n != 2 {
+			t.Errorf("Exists() stat the filesystem %d times, want 2", n)
+		}
+	})
+
+	t.Run("Zero TTL disables it", func(t *testing.T) {
+		c, stats := newCountingCache(t, 0)
+		exists(t, c, "missing")
+		exists(t, c, "missing")
+		if n := stats.Load(); n != 2 {
+			t.Errorf("Exists() stat the filesystem %d times, want 2", n)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithShardedLayout makes the cache store the files of each key under two
+// levels of subdirectories named after the hash of the key, as in
+// ab/cd/<key>.meta, so large caches do not end up with huge flat directories.
+// Keys are unchanged for callers. Items stored with the flat layout are no
+// longer visible once enabled. It must be called before the cache is used.
+func (c *FSCache) WithShardedLayout() *FSCache {
+	c.mu.Lock()
+	c.sharded = true
+	c.mu.Unlock()
+
+	// The index was built from the flat layout when the cache was created,
+	// a failed rebuild is retried by the index manager
+	_ = c.RebuildIndex(context.Background())
+	return c
+}
+
+// shardDir returns the directory, relative to the base directory, holding
+// the files of key in the sharded layout
+func shardDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	h := hex.EncodeToString(sum[:2])
+	return filepath.Join(h[:2], h[2:4])
+}
+
+// keyPath returns the path of the files of key without their extension
+func (c *FSCache) keyPath(key string) string {
+	if c.sharded {
+		return filepath.Join(c.baseDir, shardDir(key), key)
+	}
+	return filepath.Join(c.baseDir, key)
+}
+
+// keyFromPath returns the key of a metadata file from its path relative to
+// the base directory. Files that are not where the layout would put their
+// key are ignored.
+func (c *FSCache) keyFromPath(relPath string) (string, bool) {
+	key := strings.TrimSuffix(relPath, ".meta")
+	if !c.sharded {
+		return key, true
+	}
+
+	parts := strings.SplitN(filepath.ToSlash(key), "/", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	key = parts[2]
+	if filepath.ToSlash(shardDir(key)) != parts[0]+"/"+parts[1] {
+		return "", false
+	}
+	return key, true
+}
+
+// removeShardDirs removes the shard directories of key once they are empty
+func (c *FSCache) removeShardDirs(key string) {
+	if !c.sharded {
+		return
+	}
+	dir := filepath.Dir(c.keyPath(key))
+	for dir != c.baseDir && strings.HasPrefix(dir, c.baseDir) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty, or shared with other keys
+		}
+		dir = filepath.Dir(dir)
+	}
+}
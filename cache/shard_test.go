@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFSCacheShardedLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := NewFSCache(dir)
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	defer c.Close()
+	c.WithShardedLayout()
+
+	const count = 200
+	var keys []string
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("image-%03d", i)
+		if i%10 == 0 {
+			key = fmt.Sprintf("os/ubuntu/image-%03d", i)
+		}
+		keys = append(keys, key)
+		if _, err := c.Put(ctx, key, Metadata{Filename: key, Tags: map[string]string{"batch": "one"}}, strings.NewReader("content of "+key)); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	sort.Strings(keys)
+
+	t.Run("files are nested under shard directories", func(t *testing.T) {
+		for _, key := range keys {
+			want := filepath.Join(dir, shardDir(key), key+".meta")
+			if c.getMetadataPath(key) != want {
+				t.Fatalf("getMetadataPath(%s) = %s, want %s", key, c.getMetadataPath(key), want)
+			}
+			if _, err := os.Stat(want); err != nil {
+				t.Fatalf("metadata of %s not found in shard: %v", key, err)
+			}
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		if len(entries) >= count {
+			t.Errorf("base directory holds %d entries, want fewer than %d", len(entries), count)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || len(entry.Name()) != 2 {
+				t.Errorf("unexpected entry %s in base directory", entry.Name())
+			}
+		}
+	})
+
+	t.Run("get every key", func(t *testing.T) {
+		for _, key := range keys {
+			_, reader, err := c.Get(ctx, key, true)
+			if err != nil {
+				t.Fatalf("Get(%s) error = %v", key, err)
+			}
+			content, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(content) != "content of "+key {
+				t.Errorf("Get(%s) content = %q", key, content)
+			}
+		}
+	})
+
+	listKeys := func(t *testing.T) []string {
+		t.Helper()
+		items, err := c.List(ctx, map[string]string{"batch": "one"})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		var listed []string
+		for _, item := range items {
+			listed = append(listed, item.Key)
+		}
+		sort.Strings(listed)
+		return listed
+	}
+
+	t.Run("list and rebuild index", func(t *testing.T) {
+		if got := listKeys(t); strings.Join(got, ",") != strings.Join(keys, ",") {
+			t.Fatalf("List() returned %d keys, want %d", len(got), len(keys))
+		}
+
+		if err := c.RebuildIndex(ctx); err != nil {
+			t.Fatalf("RebuildIndex() error = %v", err)
+		}
+		if got := listKeys(t); strings.Join(got, ",") != strings.Join(keys, ",") {
+			t.Fatalf("List() after RebuildIndex returned %v, want %v", got, keys)
+		}
+	})
+
+	t.Run("reopened cache finds sharded items", func(t *testing.T) {
+		reopened, err := NewFSCache(dir)
+		if err != nil {
+			t.Fatalf("Failed to reopen FSCache: %v", err)
+		}
+		defer reopened.Close()
+		reopened.WithShardedLayout()
+
+		items, err := reopened.List(ctx, nil)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(items) != count {
+			t.Errorf("List() returned %d items, want %d", len(items), count)
+		}
+		if issues, err := reopened.VerifyIntegrity(ctx); err != nil || len(issues) != 0 {
+			t.Errorf("VerifyIntegrity() = %v, %v", issues, err)
+		}
+	})
+
+	t.Run("delete removes empty shard directories", func(t *testing.T) {
+		for _, key := range keys {
+			if err := c.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete(%s) error = %v", key, err)
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("base directory still holds %d entries after deleting every key", len(entries))
+		}
+		if got := listKeys(t); len(got) != 0 {
+			t.Errorf("List() after delete = %v", got)
+		}
+	})
+}
+
+func TestFSCacheShardedKeyFromPath(t *testing.T) {
+	c := &FSCache{baseDir: "/cache", sharded: true}
+
+	key := "os/ubuntu/image.img"
+	rel := filepath.Join(shardDir(key), key+".meta")
+	if got, ok := c.keyFromPath(rel); !ok || got != key {
+		t.Errorf("keyFromPath(%s) = %q, %v, want %q", rel, got, ok, key)
+	}
+
+	// Hashes never contain 'z', so zz/zz is always the wrong shard
+	for _, rel := range []string{"image.meta", "ab/image.meta", "zz/zz/image.meta"} {
+		if got, ok := c.keyFromPath(rel); ok {
+			t.Errorf("keyFromPath(%s) = %q, want ignored", rel, got)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 
+	"github.com/davidroman0O/turingpi/bmc"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
@@ -35,6 +36,10 @@ type SSHConfig struct {
 	User     string
 	Password string
 	KeyFile  string
+	// KnownHostsFile holds the trusted host keys, ~/.ssh/known_hosts when empty
+	KnownHostsFile string
+	// HostKeyPolicy controls host key verification, bmc.HostKeyVerify when empty
+	HostKeyPolicy bmc.HostKeyPolicy
 }
 
 // NewSSHCache creates a new SSH-based cache
@@ -58,10 +63,16 @@ func NewSSHCache(config SSHConfig, remoteDir string) (*SSHCache, error) {
 		authMethods = append(authMethods, ssh.PublicKeys(signer))
 	}
 
+	// Hosts are verified with the same policy as the BMC
+	hostKeyCallback, err := bmc.NewHostKeyCallback(config.HostKeyPolicy, config.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up host key verification: %w", err)
+	}
+
 	sshConfig := &ssh.ClientConfig{
 		User:            config.User,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: Replace with proper host key verification
+		HostKeyCallback: hostKeyCallback,
 	}
 
 	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port), sshConfig)
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/davidroman0O/turingpi/bmc"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -608,3 +610,22 @@ func TestSSHCacheVerifyIntegrity(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSSHCacheVerifiesHostKey(t *testing.T) {
+	// Without a known hosts file, the default policy trusts no host
+	cfg := SSHConfig{
+		Host:           "127.0.0.1",
+		Port:           22,
+		User:           "test",
+		Password:       "test",
+		KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+	}
+	if _, err := NewSSHCache(cfg, "/tmp/sshcache_test"); !errors.Is(err, bmc.ErrHostKeyUnknown) {
+		t.Errorf("NewSSHCache() error = %v, want ErrHostKeyUnknown", err)
+	}
+
+	cfg.HostKeyPolicy = "accept-all"
+	if _, err := NewSSHCache(cfg, "/tmp/sshcache_test"); err == nil || !strings.Contains(err.Error(), "unsupported host key policy") {
+		t.Errorf("NewSSHCache() error = %v, want the policy rejected", err)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"sort"
+)
+
+// TagKeys returns the distinct tag keys of the cached items, sorted
+func (c *FSCache) TagKeys(ctx context.Context) ([]string, error) {
+	return c.distinctTags(ctx, func(tags map[string]string, add func(string)) {
+		for key := range tags {
+			add(key)
+		}
+	})
+}
+
+// TagValues returns the distinct values the cached items have for the tag
+// key, sorted. An unknown key has no values.
+func (c *FSCache) TagValues(ctx context.Context, key string) ([]string, error) {
+	return c.distinctTags(ctx, func(tags map[string]string, add func(string)) {
+		if value, ok := tags[key]; ok {
+			add(value)
+		}
+	})
+}
+
+// distinctTags scans the tags of every cached item, collecting the strings
+// passed to add by collect, and returns them deduplicated and sorted. The
+// items are scanned rather than the tag index, which keeps the tags an item
+// had before being overwritten.
+func (c *FSCache) distinctTags(ctx context.Context, collect func(tags map[string]string, add func(string))) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	seen := make(map[string]bool)
+	add := func(s string) { seen[s] = true }
+	for _, meta := range c.index.Items {
+		collect(meta.Tags, add)
+	}
+
+	distinct := make([]string, 0, len(seen))
+	for s := range seen {
+		distinct = append(distinct, s)
+	}
+	sort.Strings(distinct)
+	return distinct, nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFSCacheTags(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewFSCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	defer c.Close()
+
+	items := map[string]map[string]string{
+		"ubuntu-rk1":   {"os": "ubuntu", "board": "rk1", "stage": "base"},
+		"ubuntu-cm4":   {"os": "ubuntu", "board": "cm4"},
+		"debian-rk1":   {"os": "debian", "board": "rk1", "stage": "prepared"},
+		"firmware-bmc": {"component": "bmc"},
+		"untagged":     nil,
+	}
+	for key, tags := range items {
+		if _, err := c.Put(ctx, key, Metadata{Filename: key, Tags: tags}, strings.NewReader(key)); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+
+	keys, err := c.TagKeys(ctx)
+	if err != nil {
+		t.Fatalf("TagKeys() error = %v", err)
+	}
+	if want := []string{"board", "component", "os", "stage"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("TagKeys() = %v, want %v", keys, want)
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"os", []string{"debian", "ubuntu"}},
+		{"board", []string{"cm4", "rk1"}},
+		{"component", []string{"bmc"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		values, err := c.TagValues(ctx, tt.key)
+		if err != nil || !reflect.DeepEqual(values, tt.want) {
+			t.Errorf("TagValues(%s) = %v, %v, want %v", tt.key, values, err, tt.want)
+		}
+	}
+
+	// Deleted and retagged items no longer contribute their tags
+	if err := c.Delete(ctx, "firmware-bmc"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := c.Put(ctx, "debian-rk1", Metadata{Filename: "debian-rk1", Tags: map[string]string{"os": "ubuntu"}}, strings.NewReader("x")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if keys, _ := c.TagKeys(ctx); !reflect.DeepEqual(keys, []string{"board", "os", "stage"}) {
+		t.Errorf("TagKeys() after changes = %v", keys)
+	}
+	if values, _ := c.TagValues(ctx, "os"); !reflect.DeepEqual(values, []string{"ubuntu"}) {
+		t.Errorf("TagValues(os) after changes = %v, want [ubuntu]", values)
+	}
+	if values, _ := c.TagValues(ctx, "stage"); !reflect.DeepEqual(values, []string{"base"}) {
+		t.Errorf("TagValues(stage) after changes = %v, want [base]", values)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.TagKeys(cancelled); err == nil {
+		t.Error("TagKeys() with a cancelled context succeeded")
+	}
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TieredCache keeps recently read small items in memory in front of a backing
+// cache. Reads are served from memory when possible and populate it on a miss,
+// writes go through to the backing cache. Operations that are not overridden
+// are delegated to the backing cache.
+type TieredCache struct {
+	Cache
+
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	// order holds *memoryItem values, most recently used first
+	order *list.List
+	items map[string]*list.Element
+	// generation changes whenever items are invalidated, so a read that raced
+	// with a Put or Delete does not bring back stale content
+	generation uint64
+}
+
+// memoryItem is an item held by the in-memory tier
+type memoryItem struct {
+	key      string
+	metadata Metadata
+	content  []byte
+}
+
+// NewTieredCache creates a cache holding up to capacity bytes of content in
+// memory in front of backend. Items larger than capacity are never kept in memory.
+func NewTieredCache(backend Cache, capacity int64) *TieredCache {
+	return &TieredCache{
+		Cache:    backend,
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Put writes the item to the backing cache and drops any stale copy held in memory
+func (c *TieredCache) Put(ctx context.Context, key string, metadata Metadata, reader io.Reader) (*Metadata, error) {
+	// Invalidate again once written, in case a read cached the old content meanwhile
+	c.evict(key)
+	defer c.evict(key)
+	return c.Cache.Put(ctx, key, metadata, reader)
+}
+
+// Get returns the item from memory when present, otherwise reads it from the
+// backing cache and keeps it in memory if it fits
+func (c *TieredCache) Get(ctx context.Context, key string, getContent bool) (*Metadata, io.ReadCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	default:
+	}
+
+	if metadata, content, ok := c.lookup(key); ok {
+		if !getContent {
+			return metadata, nil, nil
+		}
+		return metadata, io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	generation := c.currentGeneration()
+	metadata, reader, err := c.Cache.Get(ctx, key, getContent)
+	if err != nil || !getContent {
+		return metadata, reader, err
+	}
+	if metadata.Size > c.capacity {
+		return metadata, reader, nil
+	}
+
+	// Read at most one byte past the capacity so a wrong size in the metadata
+	// cannot pull a large item into memory
+	content, err := io.ReadAll(io.LimitReader(reader, c.capacity+1))
+	if err != nil {
+		reader.Close()
+		return nil, nil, fmt.Errorf("failed to read cache item %s: %w", key, err)
+	}
+	if int64(len(content)) > c.capacity {
+		return metadata, &prefixedReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(content), reader),
+			Closer: reader,
+		}, nil
+	}
+	reader.Close()
+
+	c.store(key, *metadata, content, generation)
+	return metadata, io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Delete removes the item from both memory and the backing cache
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	c.evict(key)
+	defer c.evict(key)
+	return c.Cache.Delete(ctx, key)
+}
+
+// Close releases the in-memory items and closes the backing cache
+func (c *TieredCache) Close() error {
+	c.mu.Lock()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+	c.generation++
+	c.mu.Unlock()
+
+	return c.Cache.Close()
+}
+
+// MemoryUsage returns the number of content bytes currently held in memory
+func (c *TieredCache) MemoryUsage() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// lookup returns a copy of the metadata and the content of an in-memory item
+func (c *TieredCache) lookup(key string) (*Metadata, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, nil, false
+	}
+	c.order.MoveToFront(element)
+
+	item := element.Value.(*memoryItem)
+	metadata := item.metadata
+	if item.metadata.Tags != nil {
+		metadata.Tags = make(map[string]string, len(item.metadata.Tags))
+		for k, v := range item.metadata.Tags {
+			metadata.Tags[k] = v
+		}
+	}
+	return &metadata, item.content, true
+}
+
+// currentGeneration returns the invalidation generation of the in-memory tier
+func (c *TieredCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// store adds an item to memory, evicting the least recently used items until it
+// fits. The item is dropped if anything was invalidated since generation.
+func (c *TieredCache) store(key string, metadata Metadata, content []byte, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+	for c.size+int64(len(content)) > c.capacity && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+
+	c.items[key] = c.order.PushFront(&memoryItem{key: key, metadata: metadata, content: content})
+	c.size += int64(len(content))
+}
+
+// evict drops an item from memory
+func (c *TieredCache) evict(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// remove unlinks an element from the LRU list; the caller must hold c.mu
+func (c *TieredCache) remove(element *list.Element) {
+	item := c.order.Remove(element).(*memoryItem)
+	delete(c.items, item.key)
+	c.size -= int64(len(item.content))
+}
+
+// prefixedReadCloser reads already buffered content followed by the rest of
+// the underlying reader
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingCache counts the reads that reach the backing cache
+type countingCache struct {
+	Cache
+	gets int32
+}
+
+func (c *countingCache) Get(ctx context.Context, key string, getContent bool) (*Metadata, io.ReadCloser, error) {
+	atomic.AddInt32(&c.gets, 1)
+	return c.Cache.Get(ctx, key, getContent)
+}
+
+func newTieredTestCache(t *testing.T, capacity int64) (*TieredCache, *countingCache) {
+	t.Helper()
+
+	fsCache, err := NewFSCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	backend := &countingCache{Cache: fsCache}
+	tiered := NewTieredCache(backend, capacity)
+	t.Cleanup(func() { tiered.Close() })
+	return tiered, backend
+}
+
+func readTiered(t *testing.T, c *TieredCache, key string) string {
+	t.Helper()
+
+	_, reader, err := c.Get(context.Background(), key, true)
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", key, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return string(data)
+}
+
+func putTiered(t *testing.T, c *TieredCache, key, content string) {
+	t.Helper()
+
+	metadata := Metadata{Filename: key + ".txt", Size: int64(len(content))}
+	if _, err := c.Put(context.Background(), key, metadata, strings.NewReader(content)); err != nil {
+		t.Fatalf("Put(%s) error = %v", key, err)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Second read is served from memory", func(t *testing.T) {
+		c, backend := newTieredTestCache(t, 1024)
+		putTiered(t, c, "config", "hostname: node1")
+
+		if got := readTiered(t, c, "config"); got != "hostname: node1" {
+			t.Errorf("First Get() = %q", got)
+		}
+		if got := readTiered(t, c, "config"); got != "hostname: node1" {
+			t.Errorf("Second Get() = %q", got)
+		}
+		if gets := atomic.LoadInt32(&backend.gets); gets != 1 {
+			t.Errorf("Backend was read %d times, want 1", gets)
+		}
+
+		metadata, reader, err := c.Get(ctx, "config", false)
+		if err != nil || reader != nil || metadata.Filename != "config.txt" {
+			t.Errorf("Get() without content = %+v, %v, %v", metadata, reader, err)
+		}
+		if gets := atomic.LoadInt32(&backend.gets); gets != 1 {
+			t.Errorf("Backend was read %d times for metadata, want 1", gets)
+		}
+	})
+
+	t.Run("Delete clears both tiers", func(t *testing.T) {
+		c, backend := newTieredTestCache(t, 1024)
+		putTiered(t, c, "config", "hostname: node1")
+		readTiered(t, c, "config")
+
+		if err := c.Delete(ctx, "config"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if usage := c.MemoryUsage(); usage != 0 {
+			t.Errorf("MemoryUsage() = %d after Delete, want 0", usage)
+		}
+		if _, _, err := c.Get(ctx, "config", true); err == nil {
+			t.Error("Get() succeeded after Delete")
+		}
+		if gets := atomic.LoadInt32(&backend.gets); gets != 2 {
+			t.Errorf("Backend was read %d times, want the read after Delete to reach it", gets)
+		}
+		if exists, _ := c.Exists(ctx, "config"); exists {
+			t.Error("Item still exists on disk after Delete")
+		}
+	})
+
+	t.Run("Put replaces the in-memory copy", func(t *testing.T) {
+		c, _ := newTieredTestCache(t, 1024)
+		putTiered(t, c, "config", "old")
+		readTiered(t, c, "config")
+
+		putTiered(t, c, "config", "new")
+		if got := readTiered(t, c, "config"); got != "new" {
+			t.Errorf("Get() after Put = %q, want new", got)
+		}
+	})
+
+	t.Run("Least recently used items are evicted", func(t *testing.T) {
+		c, backend := newTieredTestCache(t, 10)
+		putTiered(t, c, "a", "aaaa")
+		putTiered(t, c, "b", "bbbb")
+		putTiered(t, c, "c", "cccc")
+
+		readTiered(t, c, "a")
+		readTiered(t, c, "b")
+		readTiered(t, c, "a")
+		readTiered(t, c, "c") // evicts b, the least recently used
+
+		if usage := c.MemoryUsage(); usage > 10 {
+			t.Errorf("MemoryUsage() = %d, want at most the capacity", usage)
+		}
+
+		before := atomic.LoadInt32(&backend.gets)
+		readTiered(t, c, "a")
+		if atomic.LoadInt32(&backend.gets) != before {
+			t.Error("Recently used item a was evicted")
+		}
+		readTiered(t, c, "b")
+		if atomic.LoadInt32(&backend.gets) != before+1 {
+			t.Error("Least recently used item b was not evicted")
+		}
+	})
+
+	t.Run("Items larger than the capacity stay on disk", func(t *testing.T) {
+		c, backend := newTieredTestCache(t, 8)
+		content := strings.Repeat("x", 64)
+		putTiered(t, c, "large", content)
+
+		// Understate the size so the limit on the read itself is exercised
+		metadata, err := c.Stat(ctx, "large")
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		metadata.Size = 4
+		if _, err := c.Put(ctx, "large", *metadata, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if got := readTiered(t, c, "large"); got != content {
+				t.Fatalf("Get() returned %d bytes, want %d", len(got), len(content))
+			}
+		}
+		if usage := c.MemoryUsage(); usage != 0 {
+			t.Errorf("MemoryUsage() = %d, want 0", usage)
+		}
+		if gets := atomic.LoadInt32(&backend.gets); gets != 2 {
+			t.Errorf("Backend was read %d times, want every read to reach it", gets)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// agedEntry is a cached item with the time it was last modified
+type agedEntry struct {
+	key     string
+	modTime time.Time
+}
+
+// TrimByAge removes the items last modified more than maxAge ago and returns
+// how many were removed. The age of an item is its ModTime, or the time it
+// was stored when no ModTime was set.
+func (c *FSCache) TrimByAge(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge < 0 {
+		return 0, fmt.Errorf("invalid maximum age: %v", maxAge)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	return c.trim(ctx, func(entries []agedEntry) []agedEntry {
+		// Entries are sorted newest first, the old ones are at the end
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].modTime.Before(cutoff)
+		})
+		return entries[i:]
+	})
+}
+
+// TrimByCount removes all but the maxEntries most recently modified items and
+// returns how many were removed. Items are aged as in TrimByAge.
+func (c *FSCache) TrimByCount(ctx context.Context, maxEntries int) (int, error) {
+	if maxEntries < 0 {
+		return 0, fmt.Errorf("invalid maximum number of entries: %d", maxEntries)
+	}
+
+	return c.trim(ctx, func(entries []agedEntry) []agedEntry {
+		if len(entries) <= maxEntries {
+			return nil
+		}
+		return entries[maxEntries:]
+	})
+}
+
+// trim removes the items chosen by selectEntries among every item of the
+// cache sorted newest first. Each item is removed with both its metadata
+// and content files while holding the cache lock.
+func (c *FSCache) trim(ctx context.Context, selectEntries func(entries []agedEntry) []agedEntry) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	entries := make([]agedEntry, 0, len(c.index.Items))
+	for key, meta := range c.index.Items {
+		modTime := meta.ModTime
+		if modTime.IsZero() {
+			info, err := os.Stat(c.getMetadataPath(key))
+			if err != nil {
+				continue // Removed since the index was built
+			}
+			modTime = info.ModTime()
+		}
+		entries = append(entries, agedEntry{key: key, modTime: modTime})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].modTime.Equal(entries[j].modTime) {
+			return entries[i].modTime.After(entries[j].modTime)
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	removed := 0
+	for _, entry := range selectEntries(entries) {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if err := c.remove(entry.key); err != nil {
+			return removed, fmt.Errorf("failed to trim %s: %w", entry.key, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFSCacheTrim(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// newCache stores one item per age, keyed by its age in hours
+	newCache := func(t *testing.T, ages ...int) (*FSCache, string) {
+		t.Helper()
+		dir := t.TempDir()
+		c, err := NewFSCache(dir)
+		if err != nil {
+			t.Fatalf("Failed to create FSCache: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+
+		for _, age := range ages {
+			key := fmt.Sprintf("image-%dh", age)
+			meta := Metadata{Filename: key + ".img", ModTime: now.Add(-time.Duration(age) * time.Hour)}
+			if _, err := c.Put(ctx, key, meta, strings.NewReader(key)); err != nil {
+				t.Fatalf("Put(%s) error = %v", key, err)
+			}
+		}
+		return c, dir
+	}
+
+	remaining := func(t *testing.T, c *FSCache, dir string) []string {
+		t.Helper()
+		items, err := c.List(ctx, nil)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.Key)
+		}
+		sort.Strings(keys)
+
+		// Metadata and content are removed together
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		if len(files) != 2*len(keys) {
+			t.Errorf("Cache directory holds %d files for %d items", len(files), len(keys))
+		}
+		return keys
+	}
+
+	t.Run("By age", func(t *testing.T) {
+		c, dir := newCache(t, 1, 5, 30, 48, 200)
+
+		removed, err := c.TrimByAge(ctx, 24*time.Hour)
+		if err != nil {
+			t.Fatalf("TrimByAge() error = %v", err)
+		}
+		if removed != 3 {
+			t.Errorf("TrimByAge() removed %d items, want 3", removed)
+		}
+		if got, want := remaining(t, c, dir), []string{"image-1h", "image-5h"}; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Remaining items = %v, want %v", got, want)
+		}
+
+		// Nothing left to trim
+		if removed, err := c.TrimByAge(ctx, 24*time.Hour); err != nil || removed != 0 {
+			t.Errorf("Second TrimByAge() = %d, %v", removed, err)
+		}
+	})
+
+	t.Run("By count", func(t *testing.T) {
+		c, dir := newCache(t, 3, 1, 7, 2, 5)
+
+		removed, err := c.TrimByCount(ctx, 2)
+		if err != nil {
+			t.Fatalf("TrimByCount() error = %v", err)
+		}
+		if removed != 3 {
+			t.Errorf("TrimByCount() removed %d items, want 3", removed)
+		}
+		if got, want := remaining(t, c, dir), []string{"image-1h", "image-2h"}; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Remaining items = %v, want %v", got, want)
+		}
+
+		if removed, err := c.TrimByCount(ctx, 10); err != nil || removed != 0 {
+			t.Errorf("TrimByCount() above the item count = %d, %v", removed, err)
+		}
+		if removed, err := c.TrimByCount(ctx, 0); err != nil || removed != 2 {
+			t.Errorf("TrimByCount(0) = %d, %v, want every item removed", removed, err)
+		}
+		if got := remaining(t, c, dir); len(got) != 0 {
+			t.Errorf("Remaining items = %v, want none", got)
+		}
+	})
+
+	t.Run("Items without ModTime use their storage time", func(t *testing.T) {
+		c, dir := newCache(t, 1)
+		if _, err := c.Put(ctx, "undated", Metadata{Filename: "undated.img"}, strings.NewReader("undated")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		old := now.Add(-72 * time.Hour)
+		if err := os.Chtimes(c.getMetadataPath("undated"), old, old); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+
+		if removed, err := c.TrimByAge(ctx, 24*time.Hour); err != nil || removed != 1 {
+			t.Fatalf("TrimByAge() = %d, %v, want the undated item removed", removed, err)
+		}
+		if got := remaining(t, c, dir); len(got) != 1 || got[0] != "image-1h" {
+			t.Errorf("Remaining items = %v", got)
+		}
+	})
+
+	t.Run("Invalid limits", func(t *testing.T) {
+		c, _ := newCache(t)
+		if _, err := c.TrimByAge(ctx, -time.Hour); err == nil {
+			t.Error("TrimByAge() expected an error for a negative age")
+		}
+		if _, err := c.TrimByCount(ctx, -1); err == nil {
+			t.Error("TrimByCount() expected an error for a negative count")
+		}
+	})
+}
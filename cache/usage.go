@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// UsageReport is the disk space used by a cache
+type UsageReport struct {
+	// TotalBytes is the size of every file under the cache directory,
+	// including files no cached item owns, such as leftover temporary files
+	TotalBytes int64
+	// Entries is the number of cached items
+	Entries int
+	// ByTag is the size of the items having each tag, by tag key then value.
+	// An item with several tags counts toward each of them.
+	ByTag map[string]map[string]int64
+}
+
+// DiskUsage walks the cache directory once and reports the space used in
+// total and by the items of each tag value, to help decide what to evict.
+// The size of an item is that of its content and metadata files on disk.
+func (c *FSCache) DiskUsage(ctx context.Context) (*UsageReport, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	report := &UsageReport{ByTag: make(map[string]map[string]int64)}
+	sizes := make(map[string]int64)
+	err := filepath.WalkDir(c.baseDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		report.TotalBytes += info.Size()
+
+		ext := filepath.Ext(path)
+		if ext != ".meta" && ext != ".data" {
+			return nil
+		}
+		relPath, err := filepath.Rel(c.baseDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		if key, ok := c.keyFromPath(strings.TrimSuffix(relPath, ext) + ".meta"); ok {
+			sizes[key] += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk cache directory: %w", err)
+	}
+
+	for key, meta := range c.index.Items {
+		size, ok := sizes[key]
+		if !ok {
+			continue // Removed since the index was built
+		}
+		report.Entries++
+		for tagKey, tagValue := range meta.Tags {
+			if report.ByTag[tagKey] == nil {
+				report.ByTag[tagKey] = make(map[string]int64)
+			}
+			report.ByTag[tagKey][tagValue] += size
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFSCacheDiskUsage(t *testing.T) {
+	ctx := context.Background()
+	for _, sharded := range []bool{false, true} {
+		name := "Flat layout"
+		if sharded {
+			name = "Sharded layout"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := NewFSCache(dir)
+			if err != nil {
+				t.Fatalf("Failed to create FSCache: %v", err)
+			}
+			defer c.Close()
+			if sharded {
+				c.WithShardedLayout()
+			}
+
+			items := []struct {
+				key  string
+				size int
+				tags map[string]string
+			}{
+				{"playground-1", 100, map[string]string{"purpose": "playground", "os": "ubuntu"}},
+				{"playground-2", 250, map[string]string{"purpose": "playground"}},
+				{"ci-1", 1000, map[string]string{"purpose": "ci", "os": "ubuntu"}},
+				{"untagged", 40, nil},
+			}
+			sizes := make(map[string]int64)
+			for _, item := range items {
+				if _, err := c.Put(ctx, item.key, Metadata{Filename: item.key, Tags: item.tags}, strings.NewReader(strings.Repeat("x", item.size))); err != nil {
+					t.Fatalf("Put(%s) error = %v", item.key, err)
+				}
+				info, err := os.Stat(c.getMetadataPath(item.key))
+				if err != nil {
+					t.Fatalf("Failed to stat metadata of %s: %v", item.key, err)
+				}
+				sizes[item.key] = int64(item.size) + info.Size()
+			}
+
+			// A leftover file counts toward the total only
+			if err := os.WriteFile(filepath.Join(dir, "download.tmp"), make([]byte, 7), 0644); err != nil {
+				t.Fatalf("Failed to write leftover file: %v", err)
+			}
+
+			report, err := c.DiskUsage(ctx)
+			if err != nil {
+				t.Fatalf("DiskUsage() error = %v", err)
+			}
+
+			total := int64(7)
+			for _, size := range sizes {
+				total += size
+			}
+			if report.TotalBytes != total {
+				t.Errorf("TotalBytes = %d, want %d", report.TotalBytes, total)
+			}
+			if report.Entries != len(items) {
+				t.Errorf("Entries = %d, want %d", report.Entries, len(items))
+			}
+			want := map[string]map[string]int64{
+				"purpose": {
+					"playground": sizes["playground-1"] + sizes["playground-2"],
+					"ci":         sizes["ci-1"],
+				},
+				"os": {"ubuntu": sizes["playground-1"] + sizes["ci-1"]},
+			}
+			if !reflect.DeepEqual(report.ByTag, want) {
+				t.Errorf("ByTag = %v, want %v", report.ByTag, want)
+			}
+		})
+	}
+
+	t.Run("Empty cache", func(t *testing.T) {
+		c, err := NewFSCache(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create FSCache: %v", err)
+		}
+		defer c.Close()
+
+		report, err := c.DiskUsage(ctx)
+		if err != nil {
+			t.Fatalf("DiskUsage() error = %v", err)
+		}
+		if report.TotalBytes != 0 || report.Entries != 0 || len(report.ByTag) != 0 {
+			t.Errorf("DiskUsage() = %+v, want nothing used", report)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
 )
 
 // NodeID represents a compute node identifier
@@ -118,14 +119,22 @@ func (c *Config) prefixKey(key string) string {
 	return key
 }
 
-// Get retrieves a typed configuration value
+// Get retrieves a copy of a typed configuration value
 func Get[T any](c *Config, key string) (T, error) {
-	return store.Get[T](c.store, c.prefixKey(key))
+	value, err := store.Get[T](c.store, c.prefixKey(key))
+	if err != nil {
+		return value, err
+	}
+	return deepcopy.Of(value), nil
 }
 
 // GetOrDefault retrieves a configuration value with a default
 func GetOrDefault[T any](c *Config, key string, defaultValue T) (T, error) {
-	return store.GetOrDefault[T](c.store, c.prefixKey(key), defaultValue)
+	value, err := store.GetOrDefault[T](c.store, c.prefixKey(key), defaultValue)
+	if err != nil {
+		return value, err
+	}
+	return deepcopy.Of(value), nil
 }
 
 // Set stores a configuration value
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestGetReturnsCopy(t *testing.T) {
+	cfg, err := New(WithNamespace("cluster"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := cfg.Set("nodes", []int{1, 2}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	nodes, err := Get[[]int](cfg, "nodes")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	nodes[0] = 4
+
+	stored, err := GetOrDefault[[]int](cfg, "nodes", nil)
+	if err != nil {
+		t.Fatalf("GetOrDefault() error = %v", err)
+	}
+	if stored[0] != 1 {
+		t.Errorf("Stored nodes = %v, mutating a read value changed the config", stored)
+	}
+}
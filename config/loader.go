@@ -33,6 +33,15 @@ func LoadConfigFile(path string) (*ConfigFile, error) {
 		return nil, fmt.Errorf("unsupported config file format: %s", ext)
 	}
 
+	for _, cluster := range config.Clusters {
+		if cluster.Topology == nil {
+			continue
+		}
+		if err := cluster.Topology.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid topology for cluster %s: %w", cluster.Name, err)
+		}
+	}
+
 	return config, nil
 }
 
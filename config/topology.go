@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrDuplicateTopologyEntry is matched by validation errors reporting two
+// nodes sharing a node ID, slot, MAC or IP
+var ErrDuplicateTopologyEntry = errors.New("duplicate topology entry")
+
+// NodeTopology describes how the nodes of a board map to its slots and to
+// the network, the single reference for workflows and discovery
+type NodeTopology struct {
+	Nodes []TopologyNode `yaml:"nodes" json:"nodes"`
+}
+
+// TopologyNode describes one node of the topology
+type TopologyNode struct {
+	NodeID NodeID `yaml:"nodeId" json:"nodeId"`
+	// Slot is the physical slot of the board holding the module (1-4)
+	Slot  int       `yaml:"slot" json:"slot"`
+	Board BoardType `yaml:"board" json:"board"`
+	// MAC is the address of the node's network interface
+	MAC string `yaml:"mac" json:"mac"`
+	// ExpectedIP is the address the node should obtain, optional
+	ExpectedIP string `yaml:"expectedIp,omitempty" json:"expectedIp,omitempty"`
+}
+
+// LoadTopology loads a topology from a YAML or JSON file and validates it
+func LoadTopology(path string) (*NodeTopology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file: %w", err)
+	}
+
+	topology := &NodeTopology{}
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, topology); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML topology: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, topology); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON topology: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported topology file format: %s", ext)
+	}
+
+	if err := topology.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology %s: %w", path, err)
+	}
+	return topology, nil
+}
+
+// Validate checks that node IDs and slots are between 1 and 4, that boards,
+// MACs and IPs are well formed, and that no two nodes share a node ID, slot,
+// MAC or IP. All problems found are returned joined.
+func (t *NodeTopology) Validate() error {
+	var errs []error
+	nodeIDs := make(map[NodeID]bool)
+	slots := make(map[int]NodeID)
+	macs := make(map[string]NodeID)
+	ips := make(map[string]NodeID)
+
+	for _, node := range t.Nodes {
+		if node.NodeID < Node1 || node.NodeID > Node4 {
+			errs = append(errs, fmt.Errorf("invalid node ID %d (must be 1-4)", node.NodeID))
+		} else if nodeIDs[node.NodeID] {
+			errs = append(errs, fmt.Errorf("%w: node %d is listed more than once", ErrDuplicateTopologyEntry, node.NodeID))
+		}
+		nodeIDs[node.NodeID] = true
+
+		if node.Slot < 1 || node.Slot > 4 {
+			errs = append(errs, fmt.Errorf("node %d: invalid slot %d (must be 1-4)", node.NodeID, node.Slot))
+		} else if other, ok := slots[node.Slot]; ok {
+			errs = append(errs, fmt.Errorf("%w: nodes %d and %d are both in slot %d", ErrDuplicateTopologyEntry, other, node.NodeID, node.Slot))
+		} else {
+			slots[node.Slot] = node.NodeID
+		}
+
+		if node.Board != RK1 && node.Board != CM4 {
+			errs = append(errs, fmt.Errorf("node %d: unknown board type %q (must be %s or %s)", node.NodeID, node.Board, RK1, CM4))
+		}
+
+		mac, err := net.ParseMAC(node.MAC)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %d: invalid MAC %q", node.NodeID, node.MAC))
+		} else if other, ok := macs[mac.String()]; ok {
+			errs = append(errs, fmt.Errorf("%w: nodes %d and %d both have MAC %s", ErrDuplicateTopologyEntry, other, node.NodeID, mac))
+		} else {
+			macs[mac.String()] = node.NodeID
+		}
+
+		if node.ExpectedIP == "" {
+			continue
+		}
+		ip := net.ParseIP(node.ExpectedIP)
+		if ip == nil {
+			errs = append(errs, fmt.Errorf("node %d: invalid IP %q", node.NodeID, node.ExpectedIP))
+		} else if other, ok := ips[ip.String()]; ok {
+			errs = append(errs, fmt.Errorf("%w: nodes %d and %d both expect IP %s", ErrDuplicateTopologyEntry, other, node.NodeID, ip))
+		} else {
+			ips[ip.String()] = node.NodeID
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ByNodeID returns the node with the given ID
+func (t *NodeTopology) ByNodeID(id NodeID) (TopologyNode, bool) {
+	return t.find(func(node TopologyNode) bool { return node.NodeID == id })
+}
+
+// BySlot returns the node in the given slot
+func (t *NodeTopology) BySlot(slot int) (TopologyNode, bool) {
+	return t.find(func(node TopologyNode) bool { return node.Slot == slot })
+}
+
+// ByMAC returns the node with the given MAC, in any notation net.ParseMAC accepts
+func (t *NodeTopology) ByMAC(mac string) (TopologyNode, bool) {
+	wanted, err := net.ParseMAC(mac)
+	if err != nil {
+		return TopologyNode{}, false
+	}
+	return t.find(func(node TopologyNode) bool {
+		hw, err := net.ParseMAC(node.MAC)
+		return err == nil && hw.String() == wanted.String()
+	})
+}
+
+// ByIP returns the node expected to have the given IP
+func (t *NodeTopology) ByIP(ip string) (TopologyNode, bool) {
+	wanted := net.ParseIP(ip)
+	if wanted == nil {
+		return TopologyNode{}, false
+	}
+	return t.find(func(node TopologyNode) bool {
+		return wanted.Equal(net.ParseIP(node.ExpectedIP))
+	})
+}
+
+// find returns the first node matching
+func (t *NodeTopology) find(match func(node TopologyNode) bool) (TopologyNode, bool) {
+	for _, node := range t.Nodes {
+		if match(node) {
+			return node, true
+		}
+	}
+	return TopologyNode{}, false
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const topologyYAML = `nodes:
+  - nodeId: 1
+    slot: 1
+    board: rk1
+    mac: 02:00:4a:ea:dd:01
+    expectedIp: 192.168.1.101
+  - nodeId: 2
+    slot: 2
+    board: rk1
+    mac: 02:00:4A:EA:DD:02
+    expectedIp: 192.168.1.102
+  - nodeId: 3
+    slot: 4
+    board: cm4
+    mac: 02-00-4a-ea-dd-03
+`
+
+// writeTopology writes a topology file and returns its path
+func writeTopology(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write topology: %v", err)
+	}
+	return path
+}
+
+func TestLoadTopology(t *testing.T) {
+	topology, err := LoadTopology(writeTopology(t, "topology.yaml", topologyYAML))
+	if err != nil {
+		t.Fatalf("LoadTopology() error = %v", err)
+	}
+	if len(topology.Nodes) != 3 {
+		t.Fatalf("LoadTopology() loaded %d nodes, want 3", len(topology.Nodes))
+	}
+
+	if node, ok := topology.ByNodeID(Node2); !ok || node.Slot != 2 || node.ExpectedIP != "192.168.1.102" {
+		t.Errorf("ByNodeID(2) = %+v, %v", node, ok)
+	}
+	if node, ok := topology.BySlot(4); !ok || node.NodeID != Node3 || node.Board != CM4 {
+		t.Errorf("BySlot(4) = %+v, %v", node, ok)
+	}
+	// MACs match whatever their case and notation
+	if node, ok := topology.ByMAC("02:00:4a:ea:dd:02"); !ok || node.NodeID != Node2 {
+		t.Errorf("ByMAC() = %+v, %v", node, ok)
+	}
+	if node, ok := topology.ByMAC("02:00:4A:EA:DD:03"); !ok || node.NodeID != Node3 {
+		t.Errorf("ByMAC() in another notation = %+v, %v", node, ok)
+	}
+	if node, ok := topology.ByIP("192.168.1.101"); !ok || node.NodeID != Node1 {
+		t.Errorf("ByIP() = %+v, %v", node, ok)
+	}
+
+	for name, found := range map[string]bool{
+		"node 4":      lookupOK(topology.ByNodeID(Node4)),
+		"slot 3":      lookupOK(topology.BySlot(3)),
+		"unknown MAC": lookupOK(topology.ByMAC("02:00:4a:ea:dd:ff")),
+		"invalid MAC": lookupOK(topology.ByMAC("not a mac")),
+		"unknown IP":  lookupOK(topology.ByIP("192.168.1.250")),
+	} {
+		if found {
+			t.Errorf("Lookup of %s found a node", name)
+		}
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		path := writeTopology(t, "topology.json", `{"nodes": [{"nodeId": 1, "slot": 1, "board": "rk1", "mac": "02:00:4a:ea:dd:01"}]}`)
+		if topology, err := LoadTopology(path); err != nil || len(topology.Nodes) != 1 {
+			t.Errorf("LoadTopology() = %+v, %v", topology, err)
+		}
+	})
+
+	t.Run("Invalid topology is rejected", func(t *testing.T) {
+		path := writeTopology(t, "topology.yaml", topologyYAML+`  - nodeId: 3
+    slot: 3
+    board: rk1
+    mac: 02:00:4a:ea:dd:04
+`)
+		if _, err := LoadTopology(path); !errors.Is(err, ErrDuplicateTopologyEntry) {
+			t.Errorf("LoadTopology() error = %v, want ErrDuplicateTopologyEntry", err)
+		}
+	})
+}
+
+// lookupOK returns whether a lookup found a node
+func lookupOK(_ TopologyNode, ok bool) bool {
+	return ok
+}
+
+func TestNodeTopologyValidate(t *testing.T) {
+	valid := func() TopologyNode {
+		return TopologyNode{NodeID: Node1, Slot: 1, Board: RK1, MAC: "02:00:4a:ea:dd:01", ExpectedIP: "192.168.1.101"}
+	}
+
+	tests := []struct {
+		name      string
+		second    func(node *TopologyNode)
+		duplicate bool
+		message   string
+	}{
+		{"Duplicate node ID", func(n *TopologyNode) { n.Slot = 2; n.MAC = "02:00:4a:ea:dd:02"; n.ExpectedIP = "" }, true, "node 1 is listed more than once"},
+		{"Duplicate slot", func(n *TopologyNode) { n.NodeID = Node2; n.MAC = "02:00:4a:ea:dd:02"; n.ExpectedIP = "" }, true, "both in slot 1"},
+		{"Duplicate MAC", func(n *TopologyNode) { n.NodeID = Node2; n.Slot = 2; n.MAC = "02:00:4A:EA:DD:01"; n.ExpectedIP = "" }, true, "both have MAC 02:00:4a:ea:dd:01"},
+		{"Duplicate IP", func(n *TopologyNode) { n.NodeID = Node2; n.Slot = 2; n.MAC = "02:00:4a:ea:dd:02" }, true, "both expect IP 192.168.1.101"},
+		{"Node ID out of range", func(n *TopologyNode) { n.NodeID = 5; n.Slot = 2; n.MAC = "02:00:4a:ea:dd:02"; n.ExpectedIP = "" }, false, "invalid node ID 5"},
+		{"Slot out of range", func(n *TopologyNode) { n.NodeID = Node2; n.Slot = 0; n.MAC = "02:00:4a:ea:dd:02"; n.ExpectedIP = "" }, false, "invalid slot 0"},
+		{"Unknown board", func(n *TopologyNode) {
+			n.NodeID = Node2
+			n.Slot = 2
+			n.Board = "pi5"
+			n.MAC = "02:00:4a:ea:dd:02"
+			n.ExpectedIP = ""
+		}, false, `unknown board type "pi5"`},
+		{"Invalid MAC", func(n *TopologyNode) { n.NodeID = Node2; n.Slot = 2; n.MAC = "02:00"; n.ExpectedIP = "" }, false, `invalid MAC "02:00"`},
+		{"Invalid IP", func(n *TopologyNode) {
+			n.NodeID = Node2
+			n.Slot = 2
+			n.MAC = "02:00:4a:ea:dd:02"
+			n.ExpectedIP = "192.168.1"
+		}, false, `invalid IP "192.168.1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := valid()
+			tt.second(&second)
+			topology := &NodeTopology{Nodes: []TopologyNode{valid(), second}}
+
+			err := topology.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.message)
+			}
+			if errors.Is(err, ErrDuplicateTopologyEntry) != tt.duplicate {
+				t.Errorf("errors.Is(ErrDuplicateTopologyEntry) = %v, want %v", !tt.duplicate, tt.duplicate)
+			}
+		})
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		second := TopologyNode{NodeID: Node2, Slot: 2, Board: CM4, MAC: "02:00:4a:ea:dd:02"}
+		if err := (&NodeTopology{Nodes: []TopologyNode{valid(), second}}).Validate(); err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	})
+}
+
+func TestLoadConfigFileValidatesTopology(t *testing.T) {
+	path := writeTopology(t, "cluster.yaml", `clusters:
+  - name: lab
+    topology:
+      nodes:
+        - {nodeId: 1, slot: 1, board: rk1, mac: "02:00:4a:ea:dd:01"}
+        - {nodeId: 1, slot: 2, board: rk1, mac: "02:00:4a:ea:dd:02"}
+`)
+	if _, err := LoadConfigFile(path); !errors.Is(err, ErrDuplicateTopologyEntry) {
+		t.Errorf("LoadConfigFile() error = %v, want ErrDuplicateTopologyEntry", err)
+	}
+}
@@ -8,6 +8,8 @@ type ClusterConfig struct {
 	Nodes []ClusterNodeConfig `yaml:"nodes" json:"nodes"`
 	// Optional cluster-specific cache settings
 	Cache *CacheConfig `yaml:"cache,omitempty" json:"cache,omitempty"`
+	// Optional mapping of the nodes to their slots and network addresses
+	Topology *NodeTopology `yaml:"topology,omitempty" json:"topology,omitempty"`
 }
 
 // BMCConfig contains BMC connection details
@@ -15,6 +17,14 @@ type BMCConfig struct {
 	IP       string `yaml:"ip" json:"ip"`
 	Username string `yaml:"username" json:"username"`
 	Password string `yaml:"password" json:"password"`
+	// HostKeyPolicy is "verify" (default), "tofu" or "insecure", see bmc.HostKeyPolicy
+	HostKeyPolicy string `yaml:"hostKeyPolicy,omitempty" json:"hostKeyPolicy,omitempty"`
+	// KnownHostsFile holds the trusted BMC host keys, ~/.ssh/known_hosts when empty
+	KnownHostsFile string `yaml:"knownHostsFile,omitempty" json:"knownHostsFile,omitempty"`
+	// KeyFile is the private key to authenticate with instead of the password
+	KeyFile string `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	// KeyPassphrase decrypts an encrypted KeyFile
+	KeyPassphrase string `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
 }
 
 // ClusterNodeConfig contains node-specific configuration in a cluster
@@ -84,6 +84,14 @@ type Container interface {
 	// Exec executes a command in the container
 	Exec(ctx context.Context, cmd []string) (string, error)
 
+	// ExecWithInput executes a command in the container with stdin attached,
+	// writing input to it
+	ExecWithInput(ctx context.Context, cmd []string, stdin io.Reader) (string, error)
+
+	// ExecStream executes a command in the container, streaming its output to the
+	// given writers as it is produced, and returns the command's exit code
+	ExecStream(ctx context.Context, cmd []string, stdout, stderr io.Writer) (int, error)
+
 	// ExecDetached executes a command in the container without waiting for output
 	ExecDetached(ctx context.Context, cmd []string) error
 
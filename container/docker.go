@@ -54,9 +54,15 @@ func (c *DockerContainer) Unpause(ctx context.Context) error {
 
 // Exec implements Container.Exec
 func (c *DockerContainer) Exec(ctx context.Context, cmd []string) (string, error) {
+	return c.ExecWithInput(ctx, cmd, nil)
+}
+
+// ExecWithInput implements Container.ExecWithInput
+func (c *DockerContainer) ExecWithInput(ctx context.Context, cmd []string, stdin io.Reader) (string, error) {
 	// Create exec
 	execConfig := container.ExecOptions{
 		Cmd:          cmd,
+		AttachStdin:  stdin != nil,
 		AttachStdout: true,
 		AttachStderr: true,
 	}
@@ -75,6 +81,16 @@ func (c *DockerContainer) Exec(ctx context.Context, cmd []string) (string, error
 	}
 	defer resp.Close()
 
+	if stdin != nil {
+		// Feed the input while the output is read, then close the write side
+		// so the command sees the end of its input. Closing the connection
+		// once the command exited stops a copy it did not read to the end.
+		go func() {
+			_, _ = io.Copy(resp.Conn, stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
 	// Read output
 	var outBuf, errBuf strings.Builder
 	_, err = stdcopy.StdCopy(&outBuf, &errBuf, resp.Reader)
@@ -95,6 +111,52 @@ func (c *DockerContainer) Exec(ctx context.Context, cmd []string) (string, error
 	return outBuf.String(), nil
 }
 
+// ExecStream implements Container.ExecStream
+func (c *DockerContainer) ExecStream(ctx context.Context, cmd []string, stdout, stderr io.Writer) (int, error) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	// Create exec
+	execConfig := container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	execResp, err := c.client.ContainerExecCreate(ctx, c.id, execConfig)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := c.client.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{
+		Tty: false,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer resp.Close()
+
+	// Demultiplex directly into the caller's writers so output is delivered incrementally
+	if _, err := stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		return -1, fmt.Errorf("failed to stream exec output: %w", err)
+	}
+
+	// Check exit code
+	inspectResp, err := c.client.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return -1, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+
+	if inspectResp.ExitCode != 0 {
+		return inspectResp.ExitCode, fmt.Errorf("command failed with exit code %d", inspectResp.ExitCode)
+	}
+
+	return 0, nil
+}
+
 // ExecDetached implements Container.ExecDetached
 func (c *DockerContainer) ExecDetached(ctx context.Context, cmd []string) error {
 	// Create exec
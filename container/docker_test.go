@@ -91,6 +91,27 @@ func TestDockerContainer(t *testing.T) {
 		assert.Contains(t, string(output), "test")
 	})
 
+	t.Run("exec with input", func(t *testing.T) {
+		config := ContainerConfig{
+			Image:   "alpine:latest",
+			Command: []string{"sleep", "10"},
+		}
+
+		container, err := registry.Create(ctx, config)
+		require.NoError(t, err)
+		defer func() {
+			err := registry.Remove(ctx, container.ID())
+			require.NoError(t, err)
+		}()
+
+		err = container.Start(ctx)
+		require.NoError(t, err)
+
+		output, err := container.ExecWithInput(ctx, []string{"cat"}, strings.NewReader("from stdin"))
+		require.NoError(t, err)
+		assert.Equal(t, "from stdin", output)
+	})
+
 	t.Run("file operations", func(t *testing.T) {
 		config := ContainerConfig{
 			Image:   "alpine:latest",
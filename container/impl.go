@@ -3,6 +3,7 @@ package container
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -81,6 +82,13 @@ func (a *DockerAdapter) ExecuteCommand(cmd []string) (string, error) {
 	return a.container.Exec(ctx, cmd)
 }
 
+// ExecuteCommandStream executes a command in the container, streaming its output
+// to the given writers, and returns the command's exit code
+func (a *DockerAdapter) ExecuteCommandStream(cmd []string, stdout, stderr io.Writer) (int, error) {
+	ctx := context.Background()
+	return a.container.ExecStream(ctx, cmd, stdout, stderr)
+}
+
 // CopyFileToContainer copies a file from the host to the container
 func (a *DockerAdapter) CopyFileToContainer(srcPath, destPath string) error {
 	ctx := context.Background()
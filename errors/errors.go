@@ -219,12 +219,75 @@ func IsTemporary(err error) bool {
 		code == ErrNetworkTimeout
 }
 
-// IsRetryable returns true if the error can be retried
+// RetryableError marks an error as transient: the operation may succeed if retried
+type RetryableError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError marks an error that retrying cannot fix, such as an invalid
+// node or a configuration error
+type PermanentError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap implements the errors.Unwrap interface
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable marks an error as retryable
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+// Permanent marks an error as permanent so it is never retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent returns true if the error was marked permanent
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// IsRetryable returns true if the error can be retried. Errors marked with
+// Permanent are never retryable and errors marked with Retryable always are,
+// otherwise the error code decides.
 func IsRetryable(err error) bool {
 	if err == nil {
 		return false
 	}
 
+	if IsPermanent(err) {
+		return false
+	}
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		return true
+	}
+
 	code := GetCode(err)
 	return code == ErrTimeout ||
 		code == ErrConnection ||
@@ -3,6 +3,7 @@ module github.com/davidroman0O/turingpi
 go 1.23.5
 
 require (
+	cuelang.org/go v0.6.0
 	github.com/davidroman0O/firm-go v0.0.0-20250330211138-85cf230270c5
 	github.com/davidroman0O/gostage v0.0.0-20250422161325-8ddd82a5f88c
 	github.com/docker/docker v28.0.4+incompatible
@@ -16,7 +17,6 @@ require (
 )
 
 require (
-	cuelang.org/go v0.6.0 // indirect
 	github.com/Microsoft/go-winio v0.4.14 // indirect
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
 	github.com/buger/jsonparser v1.1.1 // indirect
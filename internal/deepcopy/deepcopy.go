@@ -0,0 +1,145 @@
+// Package deepcopy copies values reflectively for the packages that hand out
+// copies of the data they store, such as workflows/kvstore and config
+package deepcopy
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Copy returns a copy of value that shares no pointers, maps or slices with
+// it, with two exceptions. Unexported struct fields cannot be set through
+// reflection and are copied shallowly, so the data they reference is still
+// shared. Handles, pointers to values holding a lock such as a state
+// manager, are shared rather than copied, see isHandle. Pointers, maps and
+// slices referenced several times are copied once, so cyclic data is copied
+// with the same cycles.
+func Copy(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(value), make(map[visit]reflect.Value)).Interface()
+}
+
+// Of returns a copy of value, see Copy
+func Of[T any](value T) T {
+	if copied, ok := Copy(value).(T); ok {
+		return copied
+	}
+	return value
+}
+
+// visit identifies a pointer, map or slice already copied. Slices of the
+// same array with different lengths are distinct copies.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// copyValue recursively copies a reflected value, reusing the copies of
+// the pointers, maps and slices already visited
+func copyValue(v reflect.Value, visited map[visit]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || isHandle(v.Type()) {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if copied, ok := visited[key]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Type().Elem())
+		visited[key] = copied
+		copied.Elem().Set(copyValue(v.Elem(), visited))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(copyValue(v.Elem(), visited))
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if copied, ok := visited[key]; ok {
+			return copied
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		visited[key] = copied
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), copyValue(iter.Value(), visited))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type(), len: v.Len()}
+		if copied, ok := visited[key]; ok {
+			return copied
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		visited[key] = copied
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyValue(v.Index(i), visited))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyValue(v.Index(i), visited))
+		}
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(copyValue(v.Field(i), visited))
+			}
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// handles caches whether pointer types are handles
+var handles sync.Map
+
+// isHandle reports whether a pointer type points to a value holding a lock,
+// such as a state manager or a tool provider. These are handles on a shared
+// resource rather than data: a copy would hold a lock of its own while still
+// sharing the data it guards, so they are shared instead of copied.
+func isHandle(t reflect.Type) bool {
+	if cached, ok := handles.Load(t); ok {
+		return cached.(bool)
+	}
+	handle := holdsLock(t.Elem())
+	handles.Store(t, handle)
+	return handle
+}
+
+// holdsLock reports whether values of a type hold a synchronization
+// primitive of the sync or sync/atomic packages, by value
+func holdsLock(t reflect.Type) bool {
+	if pkg := t.PkgPath(); pkg == "sync" || pkg == "sync/atomic" {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if holdsLock(t.Field(i).Type) {
+				return true
+			}
+		}
+	case reflect.Array:
+		return holdsLock(t.Elem())
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package deepcopy
+
+import (
+	"sync"
+	"testing"
+)
+
+// node is a linked list element, possibly cyclic
+type node struct {
+	Name string
+	Next *node
+}
+
+// registry is a handle, it holds a lock
+type registry struct {
+	mu    sync.Mutex
+	items map[string]int
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("nested values are copied", func(t *testing.T) {
+		original := map[string][]int{"a": {1, 2}}
+		copied := Of(original)
+		copied["a"][0] = 10
+		copied["b"] = []int{3}
+
+		if original["a"][0] != 1 || len(original) != 1 {
+			t.Errorf("original = %v, changed through its copy", original)
+		}
+	})
+
+	t.Run("cycles terminate", func(t *testing.T) {
+		a := &node{Name: "a"}
+		b := &node{Name: "b", Next: a}
+		a.Next = b
+
+		copied := Of(a)
+		if copied == a || copied.Next == b {
+			t.Fatalf("Of() shares pointers with the original")
+		}
+		if copied.Next.Next != copied {
+			t.Errorf("Of() did not keep the cycle")
+		}
+		if copied.Name != "a" || copied.Next.Name != "b" {
+			t.Errorf("Of() = %s -> %s, want a -> b", copied.Name, copied.Next.Name)
+		}
+	})
+
+	t.Run("self referencing containers terminate", func(t *testing.T) {
+		m := map[string]interface{}{}
+		m["self"] = m
+		copied := Of(m)
+		if _, ok := copied["self"].(map[string]interface{})["self"]; !ok {
+			t.Errorf("Of() = %v, want the map to reference itself", copied)
+		}
+		copied["other"] = 1
+		if _, ok := m["other"]; ok {
+			t.Errorf("original changed through its copy")
+		}
+	})
+
+	t.Run("shared pointers stay shared", func(t *testing.T) {
+		shared := &node{Name: "shared"}
+		pair := [2]*node{shared, shared}
+
+		copied := Of(pair)
+		if copied[0] == shared {
+			t.Fatalf("Of() shares pointers with the original")
+		}
+		if copied[0] != copied[1] {
+			t.Errorf("Of() copied a pointer referenced twice into two values")
+		}
+	})
+
+	t.Run("handles are shared", func(t *testing.T) {
+		handle := &registry{items: map[string]int{}}
+		holder := struct{ Registry *registry }{handle}
+
+		if copied := Of(holder); copied.Registry != handle {
+			t.Errorf("Of() copied the handle")
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		if copied := Copy(nil); copied != nil {
+			t.Errorf("Copy(nil) = %v, want nil", copied)
+		}
+		var m map[string]int
+		if copied := Of(m); copied != nil {
+			t.Errorf("Of(nil map) = %v, want nil", copied)
+		}
+	})
+}
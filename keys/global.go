@@ -39,12 +39,17 @@ const (
 	CurrentNodeID = "turingpi.workflow.current_node" // Currently targeted node ID
 	TargetNodes   = "turingpi.workflow.target_nodes" // List of nodes to operate on
 	WorkflowState = "turingpi.workflow.state"        // Overall workflow state
+	TargetCluster = "turingpi.targetCluster"         // Name of the cluster the workflow targets
+	ClusterIndex  = "turingpi.clusterIndex"          // 1-based index of the target cluster
 
 	// Tool access keys
 	ToolsProvider = "turingpi.tools"       // Main tool provider
 	CacheTool     = "turingpi.tools.cache" // Cache tool for content caching
 	FSTool        = "turingpi.tools.fs"    // Filesystem operations tool
 
+	// State access keys
+	StateManager = "turingpi.state" // Node state manager
+
 	//
 )
 
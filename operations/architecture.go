@@ -0,0 +1,151 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownArchitecture is returned by DetectArchitecture when no binary of
+// the image tells its architecture
+var ErrUnknownArchitecture = errors.New("unable to determine image architecture")
+
+// architectureProbes are binaries present in the root filesystem of any
+// Linux image, in the order they are inspected. /bin is a symlink to usr/bin
+// on merged-usr distributions, usr/bin is therefore tried first. Symlinks on
+// their path are resolved within the image, see resolveInRoot.
+var architectureProbes = []string{
+	"usr/bin/true",
+	"bin/true",
+	"bin/busybox",
+	"bin/sh",
+}
+
+// DetectArchitecture returns the architecture an image is built for, using Go
+// architecture names such as "arm64" or "amd64". The root partition of the
+// image is mounted and the ELF header of a well known binary is inspected, so
+// a deployment can refuse an image built for another architecture than the node.
+func (i *ImageOperations) DetectArchitecture(ctx context.Context, imgPath string) (string, error) {
+	partitions, err := i.fs.MapAllPartitions(ctx, imgPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = i.fs.UnmapPartitions(ctx, imgPath)
+	}()
+
+	root, ok := FindPartition(partitions, PartitionRoleRoot)
+	if !ok {
+		return "", NewOperationError("architecture detection", imgPath, errors.New("no root partition found"))
+	}
+
+	var arch string
+	err = i.fs.withMountedFilesystem(ctx, root.Device, root.FSType, func(mountPoint string) error {
+		arch, err = i.rootArchitecture(ctx, mountPoint)
+		return err
+	})
+	if err != nil {
+		return "", NewOperationError("architecture detection", imgPath, err)
+	}
+	return arch, nil
+}
+
+// rootArchitecture returns the architecture of the first probe binary found
+// in a mounted root filesystem
+func (i *ImageOperations) rootArchitecture(ctx context.Context, mountDir string) (string, error) {
+	for _, probe := range architectureProbes {
+		resolved, err := i.resolveInRoot(ctx, mountDir, probe)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", probe, err)
+		}
+		if !i.fs.FileExists(mountDir, resolved) {
+			continue
+		}
+		content, err := i.fs.ReadFile(mountDir, resolved)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", probe, err)
+		}
+		arch, err := elfArchitecture(content)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", probe, err)
+		}
+		return arch, nil
+	}
+	return "", fmt.Errorf("%w: none of %v found", ErrUnknownArchitecture, architectureProbes)
+}
+
+// maxSymlinkHops bounds the symlinks followed resolving a path, as the
+// kernel does, so symlink loops fail
+const maxSymlinkHops = 40
+
+// resolveInRoot resolves the symlinks on path, relative to the root
+// filesystem mounted at mountDir, and returns the path they lead to relative
+// to mountDir. Absolute targets, such as /bin/sh pointing to /bin/dash, are
+// taken relative to the mount root rather than the host's, and ".." does not
+// climb above it, so a probe never reads a binary of the host.
+func (i *ImageOperations) resolveInRoot(ctx context.Context, mountDir, path string) (string, error) {
+	pending := strings.Split(path, "/")
+	var resolved []string
+	hops := 0
+	for len(pending) > 0 {
+		component := pending[0]
+		pending = pending[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+
+		candidate := append(resolved, component)
+		output, err := i.executor.Execute(ctx, "readlink", filepath.Join(mountDir, filepath.Join(candidate...)))
+		target := strings.TrimRight(string(output), "\n")
+		if err != nil || target == "" {
+			// Not a symlink, or missing
+			resolved = candidate
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", path)
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = resolved[:0]
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return strings.Join(resolved, "/"), nil
+}
+
+// elfArchitecture returns the Go name of the architecture an ELF binary targets
+func elfArchitecture(content []byte) (string, error) {
+	file, err := elf.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("%w: not an ELF binary: %v", ErrUnknownArchitecture, err)
+	}
+	defer file.Close()
+
+	switch file.Machine {
+	case elf.EM_AARCH64:
+		return "arm64", nil
+	case elf.EM_X86_64:
+		return "amd64", nil
+	case elf.EM_ARM:
+		return "arm", nil
+	case elf.EM_386:
+		return "386", nil
+	case elf.EM_RISCV:
+		if file.Class == elf.ELFCLASS64 {
+			return "riscv64", nil
+		}
+	}
+	return "", fmt.Errorf("%w: unsupported machine %v", ErrUnknownArchitecture, file.Machine)
+}
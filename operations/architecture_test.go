@@ -0,0 +1,243 @@
+package operations
+
+import (
+	"context"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+// elfHeader returns the 64-bit little endian ELF header of an executable
+// built for machine, enough for the architecture to be read from it
+func elfHeader(machine elf.Machine) []byte {
+	header := make([]byte, 64)
+	copy(header, elf.ELFMAG)
+	header[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.LittleEndian.PutUint16(header[16:], uint16(elf.ET_EXEC))
+	binary.LittleEndian.PutUint16(header[18:], uint16(machine))
+	binary.LittleEndian.PutUint32(header[20:], uint32(elf.EV_CURRENT))
+	binary.LittleEndian.PutUint16(header[52:], 64) // Header size
+	return header
+}
+
+func TestElfArchitecture(t *testing.T) {
+	tests := []struct {
+		machine elf.Machine
+		want    string
+	}{
+		{elf.EM_AARCH64, "arm64"},
+		{elf.EM_X86_64, "amd64"},
+		{elf.EM_ARM, "arm"},
+		{elf.EM_386, "386"},
+		{elf.EM_RISCV, "riscv64"},
+	}
+	for _, tt := range tests {
+		got, err := elfArchitecture(elfHeader(tt.machine))
+		if err != nil || got != tt.want {
+			t.Errorf("elfArchitecture(%v) = %s, %v, want %s", tt.machine, got, err, tt.want)
+		}
+	}
+
+	if _, err := elfArchitecture(elfHeader(elf.EM_SPARCV9)); !errors.Is(err, ErrUnknownArchitecture) {
+		t.Errorf("elfArchitecture() of an unsupported machine error = %v, want ErrUnknownArchitecture", err)
+	}
+	if _, err := elfArchitecture([]byte("#!/bin/sh\nexit 0\n")); !errors.Is(err, ErrUnknownArchitecture) {
+		t.Errorf("elfArchitecture() of a script error = %v, want ErrUnknownArchitecture", err)
+	}
+}
+
+func TestRootArchitectureMock(t *testing.T) {
+	mockExec := NewMockExecutor()
+	// A root filesystem without usr/bin/true, bin/true is probed next
+	mockExec.MockResponses["test -e /mnt/root/usr/bin/true"] = struct {
+		Output []byte
+		Err    error
+	}{Err: errors.New("exit status 1")}
+	mockExec.MockResponses["bash -c cat '/mnt/root/bin/true' | base64"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte(base64.StdEncoding.EncodeToString(elfHeader(elf.EM_AARCH64)))}
+
+	arch, err := NewImageOperations(mockExec).rootArchitecture(context.Background(), "/mnt/root")
+	if err != nil || arch != "arm64" {
+		t.Fatalf("rootArchitecture() = %s, %v, want arm64", arch, err)
+	}
+
+	// No probe binary at all
+	for _, probe := range architectureProbes {
+		mockExec.MockResponses["test -e /mnt/empty/"+probe] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+	}
+	if _, err := NewImageOperations(mockExec).rootArchitecture(context.Background(), "/mnt/empty"); !errors.Is(err, ErrUnknownArchitecture) {
+		t.Errorf("rootArchitecture() error = %v, want ErrUnknownArchitecture", err)
+	}
+}
+
+// TestRootArchitectureSymlinks checks that symlinks in the image are
+// resolved within it, never reaching the binaries of the host
+func TestRootArchitectureSymlinks(t *testing.T) {
+	respond := func(mock *MockExecutor, key, output string, err error) {
+		mock.MockResponses[key] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(output), Err: err}
+	}
+	missing := errors.New("exit status 1")
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"Absolute target", "/bin/dash\n"},
+		{"Target climbing above the root", "../../../../bin/dash\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExec := NewMockExecutor()
+			// Only bin/sh exists, a symlink to the dash binary of the image
+			for _, probe := range []string{"usr/bin/true", "bin/true", "bin/busybox"} {
+				respond(mockExec, "test -e /mnt/root/"+probe, "", missing)
+			}
+			respond(mockExec, "readlink /mnt/root/bin/sh", tt.target, nil)
+			respond(mockExec, "bash -c cat '/mnt/root/bin/dash' | base64",
+				base64.StdEncoding.EncodeToString(elfHeader(elf.EM_AARCH64)), nil)
+
+			arch, err := NewImageOperations(mockExec).rootArchitecture(context.Background(), "/mnt/root")
+			if err != nil || arch != "arm64" {
+				t.Fatalf("rootArchitecture() = %s, %v, want arm64", arch, err)
+			}
+			for _, call := range mockExec.Calls {
+				if args := strings.Join(call.Args, " "); strings.Contains(args, "'/bin/dash'") || strings.Contains(args, "/mnt/root/bin/sh'") {
+					t.Errorf("Read outside the resolved path: %s %s", call.Name, args)
+				}
+			}
+		})
+	}
+
+	t.Run("Symlink loop", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		respond(mockExec, "readlink /mnt/root/usr", "usr\n", nil)
+		if _, err := NewImageOperations(mockExec).rootArchitecture(context.Background(), "/mnt/root"); err == nil {
+			t.Error("rootArchitecture() followed a symlink loop without error")
+		}
+	})
+}
+
+// TestDetectArchitectureDocker builds images whose root filesystem holds
+// arm64 or amd64 binaries and detects their architecture
+func TestDetectArchitectureDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:      "ubuntu:latest",
+			Name:       fmt.Sprintf("turingpi-test-arch-%d", time.Now().Unix()),
+			Command:    []string{"sleep", "infinity"},
+			Privileged: true,
+			Mounts:     map[string]string{"/dev": "/dev"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y kpartx fdisk dosfstools e2fsprogs"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	imageOps := NewImageOperations(executor)
+
+	// buildImage creates an image with a boot and a root partition whose
+	// usr/bin/true is the given binary
+	buildImage := func(t *testing.T, name string, binary []byte) string {
+		t.Helper()
+		img := "/tmp/" + name + ".img"
+		setup := strings.Join([]string{
+			"dd if=/dev/zero of=" + img + " bs=1M count=64",
+			"printf 'label: dos\\n,16M,c\\n,,83\\n' | sfdisk " + img,
+		}, " && ")
+		if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+			t.Fatalf("Failed to create image: %v", err)
+		}
+
+		partitions, err := fsOps.MapAllPartitions(ctx, img)
+		if err != nil {
+			t.Fatalf("MapAllPartitions() error = %v", err)
+		}
+		defer fsOps.UnmapPartitions(ctx, img)
+
+		if _, err := executor.Execute(ctx, "mkfs.vfat", "-n", "BOOT", partitions[0].Device); err != nil {
+			t.Fatalf("Failed to format boot partition: %v", err)
+		}
+		if _, err := executor.Execute(ctx, "mkfs.ext4", "-F", "-L", "rootfs", partitions[1].Device); err != nil {
+			t.Fatalf("Failed to format root partition: %v", err)
+		}
+		mountPoint := "/mnt/" + name
+		if err := fsOps.Mount(ctx, partitions[1].Device, mountPoint, "ext4", nil); err != nil {
+			t.Fatalf("Failed to mount root partition: %v", err)
+		}
+		defer fsOps.Unmount(ctx, mountPoint)
+
+		if err := fsOps.WriteFile(mountPoint, "usr/bin/true", binary, 0755); err != nil {
+			t.Fatalf("Failed to write usr/bin/true: %v", err)
+		}
+		return img
+	}
+
+	// The container's own true binary matches the architecture of the host
+	hostTrue, err := fsOps.ReadFile("/", "usr/bin/true")
+	if err != nil {
+		t.Fatalf("Failed to read the container's true binary: %v", err)
+	}
+	hostArch, err := elfArchitecture(hostTrue)
+	if err != nil {
+		t.Fatalf("elfArchitecture() of the container's true binary error = %v", err)
+	}
+
+	otherArch, otherMachine := "arm64", elf.EM_AARCH64
+	if hostArch == "arm64" {
+		otherArch, otherMachine = "amd64", elf.EM_X86_64
+	}
+
+	tests := []struct {
+		name   string
+		binary []byte
+		want   string
+	}{
+		{"host-" + hostArch, hostTrue, hostArch},
+		{"foreign-" + otherArch, elfHeader(otherMachine), otherArch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := buildImage(t, tt.name, tt.binary)
+			arch, err := imageOps.DetectArchitecture(ctx, img)
+			if err != nil {
+				t.Fatalf("DetectArchitecture() error = %v", err)
+			}
+			if arch != tt.want {
+				t.Errorf("DetectArchitecture() = %s, want %s", arch, tt.want)
+			}
+		})
+	}
+}
@@ -97,3 +97,34 @@ func NewOperationError(operation string, context string, err error) *OperationEr
 		Err:       err,
 	}
 }
+
+// IncompleteCopyError reports the files of a directory copy that did not
+// reach the destination, or not entirely, and the source directories that
+// could not be listed to tell
+type IncompleteCopyError struct {
+	Source      string
+	Destination string
+	Files       []string // Paths relative to Source
+	Unreadable  []string // Directories relative to Source, "." for Source itself
+	Err         error    // The error of the copy command, if it failed
+}
+
+// Error implements the error interface
+func (e *IncompleteCopyError) Error() string {
+	msg := fmt.Sprintf("copy of %s to %s is incomplete", e.Source, e.Destination)
+	if len(e.Files) > 0 {
+		msg += fmt.Sprintf(", %d file(s) not transferred: %s", len(e.Files), strings.Join(e.Files, ", "))
+	}
+	if len(e.Unreadable) > 0 {
+		msg += fmt.Sprintf(", %d source directory(ies) not verified: %s", len(e.Unreadable), strings.Join(e.Unreadable, ", "))
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error of the copy command
+func (e *IncompleteCopyError) Unwrap() error {
+	return e.Err
+}
@@ -3,8 +3,11 @@
 package operations
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -23,6 +26,10 @@ type CommandExecutor interface {
 
 	// ExecuteInPath runs a command in a specific directory and returns its output
 	ExecuteInPath(ctx context.Context, dir string, name string, args ...string) ([]byte, error)
+
+	// ExecuteStream runs a command, writing its stdout and stderr to the given writers
+	// as output is produced, and returns the command's exit code
+	ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error)
 }
 
 // ExecuteCommand is a helper that executes a command and returns a formatted error if it fails
@@ -56,6 +63,22 @@ func ExecuteCommandInPath(executor CommandExecutor, ctx context.Context, dir str
 	return output, nil
 }
 
+// ExecuteCommandStream is a helper that streams a command's output and returns a formatted error if it fails.
+// Stderr is captured alongside the stderr writer so the error carries the command's diagnostics.
+func ExecuteCommandStream(executor CommandExecutor, ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	var errBuf bytes.Buffer
+	var errWriter io.Writer = &errBuf
+	if stderr != nil {
+		errWriter = io.MultiWriter(stderr, &errBuf)
+	}
+
+	exitCode, err := executor.ExecuteStream(ctx, stdout, errWriter, name, args...)
+	if err != nil {
+		return exitCode, NewCommandError(name, args, errBuf.String(), err)
+	}
+	return exitCode, nil
+}
+
 // NativeExecutor implements CommandExecutor by directly executing commands on the host OS
 type NativeExecutor struct{}
 
@@ -79,6 +102,22 @@ func (e *NativeExecutor) ExecuteInPath(ctx context.Context, dir string, name str
 	return cmd.CombinedOutput()
 }
 
+// ExecuteStream implements CommandExecutor.ExecuteStream for native OS execution
+func (e *NativeExecutor) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), err
+		}
+		return -1, err
+	}
+	return 0, nil
+}
+
 // ContainerExecutor implements CommandExecutor by executing commands inside a container
 type ContainerExecutor struct {
 	container container.Container
@@ -98,28 +137,12 @@ func (e *ContainerExecutor) Execute(ctx context.Context, name string, args ...st
 	return []byte(output), err
 }
 
-// ExecuteWithInput implements CommandExecutor.ExecuteWithInput for container execution
+// ExecuteWithInput implements CommandExecutor.ExecuteWithInput for container execution.
+// The input is streamed to the command's stdin through the exec session, so it
+// is never written to a file or passed as an argument.
 func (e *ContainerExecutor) ExecuteWithInput(ctx context.Context, input string, name string, args ...string) ([]byte, error) {
-	// For container execution with input, we need to create a temporary file with the input
-	// and pipe it to the command inside the container
-	inputFile := "/tmp/cmd_input"
-
-	// Write input to a file in the container
-	if err := e.container.ExecDetached(ctx, []string{"sh", "-c", fmt.Sprintf("cat > %s", inputFile)}); err != nil {
-		return nil, fmt.Errorf("failed to create input file in container: %w", err)
-	}
-
-	// Execute command with input from the file
-	cmd := append([]string{"sh", "-c", fmt.Sprintf("cat %s | %s %s",
-		inputFile,
-		name,
-		strings.Join(args, " "))})
-
-	output, err := e.container.Exec(ctx, cmd)
-
-	// Clean up temporary file
-	_ = e.container.ExecDetached(ctx, []string{"rm", "-f", inputFile})
-
+	cmd := append([]string{name}, args...)
+	output, err := e.container.ExecWithInput(ctx, cmd, strings.NewReader(input))
 	return []byte(output), err
 }
 
@@ -131,15 +154,21 @@ func (e *ContainerExecutor) ExecuteInPath(ctx context.Context, dir string, name
 	}
 
 	// Execute the command in the specified directory
-	cmd := append([]string{"sh", "-c", fmt.Sprintf("cd %s && %s %s",
+	cmd := []string{"sh", "-c", fmt.Sprintf("cd %s && %s %s",
 		dir,
 		name,
-		strings.Join(args, " "))})
+		strings.Join(args, " "))}
 
 	output, err := e.container.Exec(ctx, cmd)
 	return []byte(output), err
 }
 
+// ExecuteStream implements CommandExecutor.ExecuteStream for container execution
+func (e *ContainerExecutor) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	cmd := append([]string{name}, args...)
+	return e.container.ExecStream(ctx, cmd, stdout, stderr)
+}
+
 // NewExecutor creates a CommandExecutor based on the current runtime environment
 func NewExecutor(containerClient container.Container) CommandExecutor {
 	// If we're on Linux, use the native executor
@@ -0,0 +1,95 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timedWriter records when each chunk of output arrives
+type timedWriter struct {
+	mu     sync.Mutex
+	chunks []string
+	times  []time.Time
+}
+
+func (w *timedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks = append(w.chunks, string(p))
+	w.times = append(w.times, time.Now())
+	return len(p), nil
+}
+
+func TestNativeExecutorExecuteStream(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	ctx := context.Background()
+	executor := &NativeExecutor{}
+
+	t.Run("incremental delivery", func(t *testing.T) {
+		stdout := &timedWriter{}
+		var stderr bytes.Buffer
+
+		start := time.Now()
+		exitCode, err := executor.ExecuteStream(ctx, stdout, &stderr, "sh", "-c", "echo first; sleep 0.5; echo second")
+		end := time.Now()
+		if err != nil {
+			t.Fatalf("ExecuteStream() error = %v", err)
+		}
+		if exitCode != 0 {
+			t.Errorf("ExecuteStream() exitCode = %d, want 0", exitCode)
+		}
+
+		if len(stdout.chunks) < 2 {
+			t.Fatalf("Expected output in at least 2 chunks, got %d: %q", len(stdout.chunks), stdout.chunks)
+		}
+		if !strings.HasPrefix(stdout.chunks[0], "first") {
+			t.Errorf("First chunk = %q, want it to start with %q", stdout.chunks[0], "first")
+		}
+
+		// The first line must arrive well before the command completes
+		if stdout.times[0].Sub(start) > end.Sub(start)-300*time.Millisecond {
+			t.Errorf("First chunk arrived at %v of %v total, output was not streamed",
+				stdout.times[0].Sub(start), end.Sub(start))
+		}
+	})
+
+	t.Run("separate stderr and exit code", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		exitCode, err := executor.ExecuteStream(ctx, &stdout, &stderr, "sh", "-c", "echo out; echo err >&2; exit 3")
+		if err == nil {
+			t.Fatal("ExecuteStream() expected error for non-zero exit")
+		}
+		if exitCode != 3 {
+			t.Errorf("ExecuteStream() exitCode = %d, want 3", exitCode)
+		}
+		if strings.TrimSpace(stdout.String()) != "out" {
+			t.Errorf("stdout = %q, want %q", stdout.String(), "out")
+		}
+		if strings.TrimSpace(stderr.String()) != "err" {
+			t.Errorf("stderr = %q, want %q", stderr.String(), "err")
+		}
+	})
+
+	t.Run("command error carries stderr", func(t *testing.T) {
+		var stdout bytes.Buffer
+
+		_, err := ExecuteCommandStream(executor, ctx, &stdout, nil, "sh", "-c", "echo broken >&2; exit 1")
+		var cmdErr *CommandError
+		if !errors.As(err, &cmdErr) {
+			t.Fatalf("ExecuteCommandStream() error = %v, want *CommandError", err)
+		}
+		if !strings.Contains(cmdErr.Output, "broken") {
+			t.Errorf("CommandError output = %q, want it to contain stderr", cmdErr.Output)
+		}
+	})
+}
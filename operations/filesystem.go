@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/fs"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,7 +17,9 @@ import (
 // FilesystemOperations provides filesystem operations that can be executed
 // either directly on a Linux host or inside a container on non-Linux systems
 type FilesystemOperations struct {
-	executor CommandExecutor
+	executor     CommandExecutor
+	output       io.Writer
+	verifyCopies bool
 }
 
 // NewFilesystemOperations creates a new FilesystemOperations instance
@@ -25,6 +29,19 @@ func NewFilesystemOperations(executor CommandExecutor) *FilesystemOperations {
 	}
 }
 
+// SetOutput sets a writer that receives the live output of long-running commands.
+// When nil (the default), output is buffered and only reported on failure.
+func (f *FilesystemOperations) SetOutput(w io.Writer) {
+	f.output = w
+}
+
+// SetVerifyCopies makes CopyDirectory check that every regular file of the
+// source reached the destination with the same size, failing with an
+// *IncompleteCopyError naming those that did not. Disabled by default.
+func (f *FilesystemOperations) SetVerifyCopies(verify bool) {
+	f.verifyCopies = verify
+}
+
 // IsPartitionMounted checks if a partition is mounted
 func (f *FilesystemOperations) IsPartitionMounted(ctx context.Context, partition string) (bool, string, error) {
 	output, err := f.executor.Execute(ctx, "findmnt", "-n", "-o", "TARGET", partition)
@@ -53,84 +70,19 @@ func (f *FilesystemOperations) GetFilesystemType(ctx context.Context, partition
 	return strings.TrimSpace(string(output)), nil
 }
 
-// MapPartitions maps partitions in a disk image using kpartx
+// MapPartitions maps partitions in a disk image using kpartx and returns the root partition device.
+// Use MapAllPartitions to also access the boot and data partitions.
 func (f *FilesystemOperations) MapPartitions(ctx context.Context, imgPathAbs string) (string, error) {
-	// Ensure the image file exists
-	if _, err := ExecuteCommand(f.executor, ctx, "test", "-f", imgPathAbs); err != nil {
-		return "", NewOperationError("image validation", imgPathAbs, err)
-	}
-
-	// Execute kpartx to map partitions
-	output, err := ExecuteCommand(f.executor, ctx, "kpartx", "-av", imgPathAbs)
+	partitions, err := f.MapAllPartitions(ctx, imgPathAbs)
 	if err != nil {
-		// Check if kpartx is installed
-		_, checkErr := ExecuteCommand(f.executor, ctx, "which", "kpartx")
-		if checkErr != nil {
-			return "", fmt.Errorf("kpartx command not found. Please install kpartx: %v", checkErr)
-		}
-
-		// If kpartx is installed but failed, provide more context
-		return "", NewOperationError("partition mapping", imgPathAbs, err)
+		return "", err
 	}
 
-	// Parse kpartx output to get the root partition device
-	rootDevice, err := f.parseKpartxOutput(string(output))
-	if err != nil {
-		return "", NewOperationError("parsing kpartx output", string(output), err)
+	root, ok := FindPartition(partitions, PartitionRoleRoot)
+	if !ok {
+		return "", NewOperationError("partition mapping", imgPathAbs, fmt.Errorf("no root partition found"))
 	}
-
-	rootDevPath := fmt.Sprintf("/dev/mapper/%s", rootDevice)
-
-	// Wait for the device to become available
-	if err := f.waitForDevice(ctx, rootDevPath, 10); err != nil {
-		// Try to get more info about the device
-		deviceListOutput, _ := ExecuteCommand(f.executor, ctx, "ls", "-la", "/dev/mapper")
-		return "", fmt.Errorf("device not available after mapping: %w (ls -la /dev/mapper: %s)",
-			err, string(deviceListOutput))
-	}
-
-	return rootDevPath, nil
-}
-
-// parseKpartxOutput parses kpartx output to extract root partition device path
-func (f *FilesystemOperations) parseKpartxOutput(output string) (string, error) {
-	// Example output:
-	// add map loop1p1 (253:1): 0 524288 linear 7:1 8192
-	// add map loop1p2 (253:2): 0 32768000 linear 7:1 532480
-	//
-	// For simplicity, we assume the first line is boot and second is root
-	// This could be improved to detect partitions by examining sizes or file systems
-
-	lines := strings.Split(output, "\n")
-
-	// Filter out empty lines and log what we found
-	var validLines []string
-	for _, line := range lines {
-		if strings.TrimSpace(line) != "" && strings.HasPrefix(line, "add") {
-			validLines = append(validLines, line)
-		}
-	}
-
-	if len(validLines) == 0 {
-		return "", fmt.Errorf("no valid partition maps found in kpartx output")
-	}
-
-	// For single partition images, use the first line
-	// For multi-partition images, assume second partition is root (common practice)
-	var rootLine string
-	if len(validLines) == 1 {
-		rootLine = validLines[0]
-	} else {
-		rootLine = validLines[1] // Second partition is typically root
-	}
-
-	parts := strings.Fields(rootLine)
-	if len(parts) < 3 || !strings.HasPrefix(parts[0], "add") {
-		return "", fmt.Errorf("unexpected kpartx output format: %s", rootLine)
-	}
-
-	// Extract the device name (3rd field)
-	return parts[2], nil
+	return root.Device, nil
 }
 
 // waitForDevice waits for a device to become available, with a specified timeout in seconds
@@ -154,8 +106,12 @@ func (f *FilesystemOperations) waitForDevice(ctx context.Context, devicePath str
 	return fmt.Errorf("timeout waiting for device to become available: %s", devicePath)
 }
 
-// UnmapPartitions unmaps partitions that were mapped with kpartx
+// UnmapPartitions unmaps partitions that were mapped with kpartx. Only the
+// loop devices backing the image are detached, the loop devices of other
+// images are never touched.
 func (f *FilesystemOperations) UnmapPartitions(ctx context.Context, imgPathAbs string) error {
+	defer imageLocks.lock(imgPathAbs)()
+
 	// Ensure the image file exists
 	if _, err := ExecuteCommand(f.executor, ctx, "test", "-f", imgPathAbs); err != nil {
 		return NewOperationError("image validation", imgPathAbs, err)
@@ -164,28 +120,6 @@ func (f *FilesystemOperations) UnmapPartitions(ctx context.Context, imgPathAbs s
 	// Execute kpartx with -d flag to unmap partitions
 	fmt.Printf("Unmapping partitions for image: %s\n", imgPathAbs)
 
-	// First, get a list of mapped devices for this image to verify cleanup
-	// We use losetup to find which loop device is associated with our image
-	loopDevices := []string{}
-	losetupOutput, err := ExecuteCommand(f.executor, ctx, "losetup", "-j", imgPathAbs)
-	if err == nil && len(losetupOutput) > 0 {
-		// Parse out the loop device name from output like "/dev/loop0: []: (/path/to/image)"
-		loopLines := strings.Split(string(losetupOutput), "\n")
-		for _, line := range loopLines {
-			if strings.TrimSpace(line) == "" {
-				continue
-			}
-
-			loopParts := strings.Split(line, ":")
-			if len(loopParts) > 0 {
-				loopDev := strings.TrimSpace(loopParts[0])
-				fmt.Printf("Found mapped loop device: %s\n", loopDev)
-				loopDevices = append(loopDevices, loopDev)
-			}
-		}
-	}
-
-	// Now run kpartx -d
 	output, err := ExecuteCommand(f.executor, ctx, "kpartx", "-d", imgPathAbs)
 	if err != nil {
 		// Check if kpartx is installed
@@ -202,47 +136,66 @@ func (f *FilesystemOperations) UnmapPartitions(ctx context.Context, imgPathAbs s
 		fmt.Printf("Unmap output: %s\n", string(output))
 	}
 
-	// Verify that the image is no longer mapped to any loop devices
-	verifyOutput, err := ExecuteCommand(f.executor, ctx, "losetup", "-j", imgPathAbs)
-	if err == nil && len(verifyOutput) > 0 && strings.TrimSpace(string(verifyOutput)) != "" {
-		// Still mapped, try a more aggressive approach
-		fmt.Printf("Image still has loop mappings, attempting forceful cleanup\n")
-
-		// Try the -dv (delete with verbose) option for more forceful unmapping
-		forceOutput, forceErr := ExecuteCommand(f.executor, ctx, "kpartx", "-dv", imgPathAbs)
-		if forceErr != nil {
-			fmt.Printf("Warning: forceful unmap attempt failed: %v\nOutput: %s\n",
-				forceErr, string(forceOutput))
-		}
+	// Verify that the image is no longer backed by any loop device
+	loopDevices := f.imageLoopDevices(ctx, imgPathAbs)
+	if len(loopDevices) == 0 {
+		fmt.Printf("Partition unmapping completed for: %s\n", imgPathAbs)
+		return nil
+	}
 
-		// If we found specific loop devices earlier, try to detach them directly
-		for _, loopDev := range loopDevices {
-			fmt.Printf("Attempting to detach loop device: %s\n", loopDev)
-			detachOutput, detachErr := ExecuteCommand(f.executor, ctx, "losetup", "-d", loopDev)
-			if detachErr != nil {
-				fmt.Printf("Warning: Failed to detach loop device %s: %v\nOutput: %s\n",
-					loopDev, detachErr, string(detachOutput))
-			} else {
-				fmt.Printf("Successfully detached loop device: %s\n", loopDev)
-			}
-		}
+	// Still mapped, try a more aggressive approach
+	fmt.Printf("Image still has loop mappings, attempting forceful cleanup\n")
 
-		// As a last resort, try to force ALL loop devices to rescan
-		fmt.Printf("Requesting all loop devices to rescan...\n")
-		_, _ = ExecuteCommand(f.executor, ctx, "losetup", "-D")
+	// Try the -dv (delete with verbose) option for more forceful unmapping
+	forceOutput, forceErr := ExecuteCommand(f.executor, ctx, "kpartx", "-dv", imgPathAbs)
+	if forceErr != nil {
+		fmt.Printf("Warning: forceful unmap attempt failed: %v\nOutput: %s\n",
+			forceErr, string(forceOutput))
+	}
 
-		// Final verification
-		finalVerifyOutput, _ := ExecuteCommand(f.executor, ctx, "losetup", "-j", imgPathAbs)
-		if len(finalVerifyOutput) > 0 && strings.TrimSpace(string(finalVerifyOutput)) != "" {
-			fmt.Printf("Warning: Image still has loop mappings after forceful cleanup: %s\n",
-				string(finalVerifyOutput))
+	// Detach the loop devices still backing this image, and only those
+	for _, loopDev := range f.imageLoopDevices(ctx, imgPathAbs) {
+		fmt.Printf("Attempting to detach loop device: %s\n", loopDev)
+		detachOutput, detachErr := ExecuteCommand(f.executor, ctx, "losetup", "-d", loopDev)
+		if detachErr != nil {
+			fmt.Printf("Warning: Failed to detach loop device %s: %v\nOutput: %s\n",
+				loopDev, detachErr, string(detachOutput))
+		} else {
+			fmt.Printf("Successfully detached loop device: %s\n", loopDev)
 		}
 	}
 
+	// Final verification
+	if remaining := f.imageLoopDevices(ctx, imgPathAbs); len(remaining) > 0 {
+		fmt.Printf("Warning: Image still has loop mappings after forceful cleanup: %v\n", remaining)
+	}
+
 	fmt.Printf("Partition unmapping completed for: %s\n", imgPathAbs)
 	return nil
 }
 
+// imageLoopDevices returns the loop devices backing an image, as listed by losetup -j
+func (f *FilesystemOperations) imageLoopDevices(ctx context.Context, imgPathAbs string) []string {
+	output, err := ExecuteCommand(f.executor, ctx, "losetup", "-j", imgPathAbs)
+	if err != nil {
+		return nil
+	}
+	return parseLosetupDevices(string(output))
+}
+
+// parseLosetupDevices returns the loop devices of losetup -j output such as
+// "/dev/loop0: [2049]:1234 (/path/to/image)"
+func parseLosetupDevices(output string) []string {
+	var devices []string
+	for _, line := range strings.Split(output, "\n") {
+		device, _, found := strings.Cut(strings.TrimSpace(line), ":")
+		if found && strings.HasPrefix(device, "/dev/loop") {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
 // Mount mounts a filesystem to a specified directory
 func (f *FilesystemOperations) Mount(ctx context.Context, device, mountPoint, fsType string, options []string) error {
 	// Create mount point directory
@@ -386,19 +339,36 @@ func (f *FilesystemOperations) Format(ctx context.Context, device, fsType string
 		if label != "" {
 			args = append(args, "-n", label)
 		}
+	case "xfs":
+		cmdName = "mkfs.xfs"
+		args = []string{"-f", device}
+		if label != "" {
+			args = append(args, "-L", label)
+		}
+	case "btrfs":
+		cmdName = "mkfs.btrfs"
+		args = []string{"-f", device}
+		if label != "" {
+			args = append(args, "-L", label)
+		}
 	default:
 		return fmt.Errorf("unsupported filesystem type: %s", fsType)
 	}
 
 	output, err := f.executor.Execute(ctx, cmdName, args...)
 	if err != nil {
+		if toolErr := f.checkFilesystemTool(ctx, cmdName, fsType); toolErr != nil {
+			return toolErr
+		}
 		return fmt.Errorf("format failed: %s: %w", string(output), err)
 	}
 
 	return nil
 }
 
-// ResizeFilesystem resizes a filesystem to fill its partition
+// ResizeFilesystem resizes a filesystem to fill its partition.
+// xfs and btrfs can only grow while mounted, so the device is mounted on a
+// temporary directory for the resize if it is not mounted already.
 func (f *FilesystemOperations) ResizeFilesystem(ctx context.Context, device string) error {
 	// Get filesystem type
 	output, err := f.executor.Execute(ctx, "blkid", "-o", "value", "-s", "TYPE", device)
@@ -415,6 +385,22 @@ func (f *FilesystemOperations) ResizeFilesystem(ctx context.Context, device stri
 	case "vfat", "fat32":
 		// FAT filesystems generally don't need explicit resizing after partition table update
 		return nil
+	case "xfs", "btrfs":
+		return f.withMountedFilesystem(ctx, device, fsType, func(mountPoint string) error {
+			cmdName, args := "xfs_growfs", []string{mountPoint}
+			if fsType == "btrfs" {
+				cmdName, args = "btrfs", []string{"filesystem", "resize", "max", mountPoint}
+			}
+
+			output, err := f.executor.Execute(ctx, cmdName, args...)
+			if err != nil {
+				if toolErr := f.checkFilesystemTool(ctx, cmdName, fsType); toolErr != nil {
+					return toolErr
+				}
+				return fmt.Errorf("resize failed: %s: %w", string(output), err)
+			}
+			return nil
+		})
 	default:
 		return fmt.Errorf("unsupported filesystem type for resize: %s", fsType)
 	}
@@ -426,20 +412,168 @@ func (f *FilesystemOperations) ResizeFilesystem(ctx context.Context, device stri
 	return nil
 }
 
-// CopyDirectory recursively copies a directory to another location
+// filesystemPackages names the package providing the tools of each filesystem
+var filesystemPackages = map[string]string{
+	"ext4":  "e2fsprogs",
+	"fat32": "dosfstools",
+	"vfat":  "dosfstools",
+	"xfs":   "xfsprogs",
+	"btrfs": "btrfs-progs",
+}
+
+// checkFilesystemTool returns an error explaining how to install a missing
+// filesystem tool, or nil if the tool is installed
+func (f *FilesystemOperations) checkFilesystemTool(ctx context.Context, tool, fsType string) error {
+	if _, err := ExecuteCommand(f.executor, ctx, "which", tool); err != nil {
+		return fmt.Errorf("%s command not found. Please install %s: %v", tool, filesystemPackages[fsType], err)
+	}
+	return nil
+}
+
+// withMountedFilesystem calls fn with the mount point of a device, mounting
+// it on a temporary directory for the duration of the call if needed
+func (f *FilesystemOperations) withMountedFilesystem(ctx context.Context, device, fsType string, fn func(mountPoint string) error) error {
+	if mounted, mountPoint, err := f.IsPartitionMounted(ctx, device); err == nil && mounted {
+		return fn(mountPoint)
+	}
+
+	output, err := ExecuteCommand(f.executor, ctx, "mktemp", "-d")
+	if err != nil {
+		return NewOperationError("creating temporary mount point", device, err)
+	}
+	mountPoint := strings.TrimSpace(string(output))
+	defer func() {
+		_, _ = f.executor.Execute(ctx, "rmdir", mountPoint)
+	}()
+
+	if err := f.Mount(ctx, device, mountPoint, fsType, nil); err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Unmount(ctx, mountPoint)
+	}()
+
+	return fn(mountPoint)
+}
+
+// CopyDirectory recursively copies a directory to another location. With
+// SetVerifyCopies, files that did not transfer, such as unreadable ones, and
+// source directories that could not be listed are reported as an
+// *IncompleteCopyError.
 func (f *FilesystemOperations) CopyDirectory(ctx context.Context, src, dst string) error {
 	// Create dst directory if it doesn't exist
 	if _, err := f.executor.Execute(ctx, "mkdir", "-p", dst); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Use rsync for efficient directory copying
-	output, err := f.executor.Execute(ctx, "rsync", "-av", src+"/", dst+"/")
+	var copyErr error
+	if f.output != nil {
+		// Stream rsync progress when an output writer is configured
+		if _, err := ExecuteCommandStream(f.executor, ctx, f.output, f.output, "rsync", "-av", src+"/", dst+"/"); err != nil {
+			copyErr = fmt.Errorf("rsync failed: %w", err)
+		}
+	} else {
+		// Use rsync for efficient directory copying
+		if output, err := f.executor.Execute(ctx, "rsync", "-av", src+"/", dst+"/"); err != nil {
+			copyErr = fmt.Errorf("rsync failed: %s: %w", string(output), err)
+		}
+	}
+	if !f.verifyCopies {
+		return copyErr
+	}
+
+	files, unreadable, err := f.untransferredFiles(ctx, src, dst)
 	if err != nil {
-		return fmt.Errorf("rsync failed: %s: %w", string(output), err)
+		if copyErr != nil {
+			return copyErr
+		}
+		return fmt.Errorf("failed to verify copy: %w", err)
+	}
+	if len(files) > 0 || len(unreadable) > 0 {
+		return &IncompleteCopyError{Source: src, Destination: dst, Files: files, Unreadable: unreadable, Err: copyErr}
 	}
+	return copyErr
+}
 
-	return nil
+// untransferredFiles returns the regular files of src, relative to it and
+// sorted, that are missing from dst or differ in size, and the directories of
+// src that could not be listed, whose files are unknown. Unreadable
+// directories of dst need no report, their files count as missing.
+func (f *FilesystemOperations) untransferredFiles(ctx context.Context, src, dst string) ([]string, []string, error) {
+	srcSizes, unreadable, err := f.fileSizes(ctx, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstSizes, _, err := f.fileSizes(ctx, dst)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+	for path, size := range srcSizes {
+		if copied, ok := dstSizes[path]; !ok || copied != size {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, unreadable, nil
+}
+
+// fileSizes lists the regular files under dir with their size, by path
+// relative to dir, and the sorted subdirectories find reported errors for,
+// relative to dir. Their files are left out. When find fails without naming
+// a directory, dir itself is reported as ".".
+func (f *FilesystemOperations) fileSizes(ctx context.Context, dir string) (map[string]int64, []string, error) {
+	args := []string{dir, "-type", "f", "-printf", `%P\t%s\n`}
+	output, err := f.executor.Execute(ctx, "find", args...)
+
+	sizes := make(map[string]int64)
+	var unreadable []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if path, ok := findErrorPath(line); ok {
+			unreadable = append(unreadable, relativeTo(dir, path))
+			continue
+		}
+		tab := strings.LastIndexByte(line, '\t')
+		if tab < 0 {
+			continue
+		}
+		size, parseErr := strconv.ParseInt(line[tab+1:], 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		sizes[line[:tab]] = size
+	}
+	if err != nil && len(sizes) == 0 && len(unreadable) == 0 {
+		return nil, nil, NewCommandError("find", args, string(output), err)
+	}
+	if err != nil && len(unreadable) == 0 {
+		unreadable = []string{"."}
+	}
+	sort.Strings(unreadable)
+	return sizes, unreadable, nil
+}
+
+// findErrorPath returns the path of a find error line, such as
+// "find: '/mnt/root/secret': Permission denied"
+func findErrorPath(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "find: ")
+	if !ok {
+		return "", false
+	}
+	colon := strings.LastIndex(rest, ": ")
+	if colon < 0 {
+		return "", false
+	}
+	return strings.Trim(rest[:colon], "'‘’\""), true
+}
+
+// relativeTo returns path relative to dir, "." for dir itself
+func relativeTo(dir, path string) string {
+	if path == dir {
+		return "."
+	}
+	return strings.TrimPrefix(path, strings.TrimSuffix(dir, "/")+"/")
 }
 
 // WriteFile writes content to a file
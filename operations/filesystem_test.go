@@ -2,10 +2,16 @@ package operations
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
 )
 
 // MockExecutor implements CommandExecutor for testing
@@ -61,6 +67,18 @@ func (m *MockExecutor) ExecuteInPath(ctx context.Context, dir string, name strin
 	return m.Execute(ctx, name, append([]string{"cd", dir, "&&"}, args...)...)
 }
 
+// ExecuteStream implements CommandExecutor.ExecuteStream for testing
+func (m *MockExecutor) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	output, err := m.Execute(ctx, name, args...)
+	if stdout != nil {
+		stdout.Write(output)
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
 // NewMockExecutor creates a new MockExecutor
 func NewMockExecutor() *MockExecutor {
 	return &MockExecutor{
@@ -502,3 +520,337 @@ link.txt
 		}
 	}
 }
+
+func TestFormatMock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Unsupported filesystem", func(t *testing.T) {
+		err := NewFilesystemOperations(NewMockExecutor()).Format(ctx, "/dev/loop0", "zfs", "data")
+		if err == nil || !strings.Contains(err.Error(), "unsupported filesystem type") {
+			t.Errorf("Format() error = %v, want unsupported filesystem type", err)
+		}
+	})
+
+	for _, tt := range []struct{ fsType, command, pkg string }{
+		{"xfs", "mkfs.xfs -f /dev/loop0 -L data", "xfsprogs"},
+		{"btrfs", "mkfs.btrfs -f /dev/loop0 -L data", "btrfs-progs"},
+	} {
+		t.Run(tt.fsType, func(t *testing.T) {
+			mockExec := NewMockExecutor()
+			if err := NewFilesystemOperations(mockExec).Format(ctx, "/dev/loop0", tt.fsType, "data"); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if call := mockExec.Calls[0]; call.Name+" "+strings.Join(call.Args, " ") != tt.command {
+				t.Errorf("Format() ran %s %v, want %s", call.Name, call.Args, tt.command)
+			}
+
+			// A missing tool is reported with the package to install
+			missing := NewMockExecutor()
+			missing.MockResponses[tt.command] = struct {
+				Output []byte
+				Err    error
+			}{Err: errors.New("executable file not found")}
+			missing.MockResponses["which "+strings.Fields(tt.command)[0]] = struct {
+				Output []byte
+				Err    error
+			}{Err: errors.New("exit status 1")}
+
+			err := NewFilesystemOperations(missing).Format(ctx, "/dev/loop0", tt.fsType, "data")
+			if err == nil || !strings.Contains(err.Error(), tt.pkg) {
+				t.Errorf("Format() error = %v, want a hint to install %s", err, tt.pkg)
+			}
+		})
+	}
+}
+
+// TestFormatAndGrowDocker formats xfs and btrfs loop devices, grows the
+// backing files and checks the filesystems grow with them
+func TestFormatAndGrowDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:      "ubuntu:latest",
+			Name:       fmt.Sprintf("turingpi-test-fsgrow-%d", time.Now().Unix()),
+			Command:    []string{"sleep", "infinity"},
+			Privileged: true,
+			Mounts:     map[string]string{"/dev": "/dev"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y xfsprogs btrfs-progs util-linux"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+
+	// totalSize mounts a device and returns the size of its filesystem
+	totalSize := func(t *testing.T, device, fsType string) int64 {
+		mountPoint := "/mnt/" + fsType
+		if err := fsOps.Mount(ctx, device, mountPoint, fsType, nil); err != nil {
+			t.Fatalf("Mount() error = %v", err)
+		}
+		defer fsOps.Unmount(ctx, mountPoint)
+
+		_, total, err := fsOps.DiskFree(ctx, mountPoint)
+		if err != nil {
+			t.Fatalf("DiskFree() error = %v", err)
+		}
+		return total
+	}
+
+	for _, fsType := range []string{"xfs", "btrfs"} {
+		t.Run(fsType, func(t *testing.T) {
+			img := "/tmp/" + fsType + ".img"
+			// xfs needs at least 300MB
+			if _, err := executor.Execute(ctx, "truncate", "-s", "400M", img); err != nil {
+				t.Fatalf("Failed to create image: %v", err)
+			}
+			output, err := executor.Execute(ctx, "losetup", "-f", "--show", img)
+			if err != nil {
+				t.Fatalf("Failed to attach loop device: %v", err)
+			}
+			device := strings.TrimSpace(string(output))
+			defer executor.Execute(ctx, "losetup", "-d", device)
+
+			if err := fsOps.Format(ctx, device, fsType, "data"); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if detected, err := fsOps.GetFilesystemType(ctx, device); err != nil || detected != fsType {
+				t.Fatalf("Filesystem type = %q, %v, want %s", detected, err, fsType)
+			}
+			before := totalSize(t, device, fsType)
+
+			// Grow the device the way a partition is grown on a larger card
+			if _, err := executor.Execute(ctx, "bash", "-c", "truncate -s 800M "+img+" && losetup -c "+device); err != nil {
+				t.Fatalf("Failed to grow device: %v", err)
+			}
+			if err := fsOps.ResizeFilesystem(ctx, device); err != nil {
+				t.Fatalf("ResizeFilesystem() error = %v", err)
+			}
+
+			if after := totalSize(t, device, fsType); after <= before {
+				t.Errorf("Filesystem size = %d after growing, was %d", after, before)
+			}
+		})
+	}
+}
+
+// TestUnmapPartitionsScopedToImage unmaps an image whose loop devices stay
+// attached while another image is mapped, and checks only the devices of the
+// unmapped image are detached
+func TestUnmapPartitionsScopedToImage(t *testing.T) {
+	ctx := context.Background()
+	imageA, imageB := "/tmp/a.img", "/tmp/b.img"
+
+	mockExec := NewMockExecutor()
+	// kpartx -d leaves image A attached, forcing the cleanup of its loop devices
+	mockExec.MockResponses["losetup -j "+imageA] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("/dev/loop0: [2049]:131 (/tmp/a.img)\n/dev/loop2: [2049]:131 (/tmp/a.img)\n")}
+	mockExec.MockResponses["losetup -j "+imageB] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("/dev/loop1: [2049]:132 (/tmp/b.img)\n")}
+
+	if err := NewFilesystemOperations(mockExec).UnmapPartitions(ctx, imageA); err != nil {
+		t.Fatalf("UnmapPartitions() error = %v", err)
+	}
+
+	var detached []string
+	for _, call := range mockExec.Calls {
+		command := call.Name + " " + strings.Join(call.Args, " ")
+		if strings.Contains(command, imageB) || strings.Contains(command, "/dev/loop1") {
+			t.Errorf("Unmapping image A touched image B: %s", command)
+		}
+		if call.Name == "losetup" && len(call.Args) > 0 && call.Args[0] == "-D" {
+			t.Errorf("Unmapping image A detached every loop device: %s", command)
+		}
+		if call.Name == "losetup" && len(call.Args) == 2 && call.Args[0] == "-d" {
+			detached = append(detached, call.Args[1])
+		}
+	}
+	if want := []string{"/dev/loop0", "/dev/loop2"}; strings.Join(detached, " ") != strings.Join(want, " ") {
+		t.Errorf("Detached loop devices = %v, want %v", detached, want)
+	}
+}
+
+func TestParseLosetupDevices(t *testing.T) {
+	output := "/dev/loop0: [2049]:131 (/tmp/a.img)\n\n/dev/loop12: []: (/tmp/a.img)\nlosetup: warning\n"
+	got := parseLosetupDevices(output)
+	if strings.Join(got, " ") != "/dev/loop0 /dev/loop12" {
+		t.Errorf("parseLosetupDevices() = %v", got)
+	}
+}
+
+// TestCopyDirectoryVerifyMock checks that a verified copy reports the files
+// missing from the destination or smaller there
+func TestCopyDirectoryVerifyMock(t *testing.T) {
+	ctx := context.Background()
+	listing := func(dir string) string {
+		return "find " + dir + ` -type f -printf %P\t%s\n`
+	}
+	respond := func(mock *MockExecutor, key, output string) {
+		mock.MockResponses[key] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(output)}
+	}
+
+	t.Run("Untransferred files are named", func(t *testing.T) {
+		mock := NewMockExecutor()
+		respond(mock, listing("/src"), "a.txt\t10\nsub/b.bin\t2048\nsub/secret.key\t64\n")
+		respond(mock, listing("/dst"), "a.txt\t10\nsub/b.bin\t1024\n")
+		fsOps := NewFilesystemOperations(mock)
+		fsOps.SetVerifyCopies(true)
+
+		err := fsOps.CopyDirectory(ctx, "/src", "/dst")
+		var incomplete *IncompleteCopyError
+		if !errors.As(err, &incomplete) {
+			t.Fatalf("CopyDirectory() error = %v, want an IncompleteCopyError", err)
+		}
+		if want := []string{"sub/b.bin", "sub/secret.key"}; strings.Join(incomplete.Files, ",") != strings.Join(want, ",") {
+			t.Errorf("Files = %v, want %v", incomplete.Files, want)
+		}
+		if !strings.Contains(err.Error(), "sub/secret.key") {
+			t.Errorf("Error %q does not name the missing file", err)
+		}
+	})
+
+	t.Run("Complete copy", func(t *testing.T) {
+		mock := NewMockExecutor()
+		respond(mock, listing("/src"), "a.txt\t10\n")
+		respond(mock, listing("/dst"), "a.txt\t10\nextra.txt\t5\n")
+		fsOps := NewFilesystemOperations(mock)
+		fsOps.SetVerifyCopies(true)
+
+		if err := fsOps.CopyDirectory(ctx, "/src", "/dst"); err != nil {
+			t.Errorf("CopyDirectory() error = %v", err)
+		}
+	})
+
+	t.Run("Unreadable source directories are named", func(t *testing.T) {
+		mock := NewMockExecutor()
+		mock.MockResponses[listing("/src")] = struct {
+			Output []byte
+			Err    error
+		}{
+			Output: []byte("a.txt\t10\nfind: '/src/private': Permission denied\n"),
+			Err:    errors.New("exit status 1"),
+		}
+		respond(mock, listing("/dst"), "a.txt\t10\n")
+		fsOps := NewFilesystemOperations(mock)
+		fsOps.SetVerifyCopies(true)
+
+		err := fsOps.CopyDirectory(ctx, "/src", "/dst")
+		var incomplete *IncompleteCopyError
+		if !errors.As(err, &incomplete) {
+			t.Fatalf("CopyDirectory() error = %v, want an IncompleteCopyError", err)
+		}
+		if len(incomplete.Files) != 0 || strings.Join(incomplete.Unreadable, ",") != "private" {
+			t.Errorf("Files = %v, Unreadable = %v, want only the private directory", incomplete.Files, incomplete.Unreadable)
+		}
+		if !strings.Contains(err.Error(), "private") {
+			t.Errorf("Error %q does not name the unreadable directory", err)
+		}
+
+		// A failure not naming a directory leaves the whole source unverified
+		mock.MockResponses[listing("/src")] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("a.txt\t10\n"), Err: errors.New("exit status 1")}
+		err = fsOps.CopyDirectory(ctx, "/src", "/dst")
+		if !errors.As(err, &incomplete) || strings.Join(incomplete.Unreadable, ",") != "." {
+			t.Errorf("CopyDirectory() error = %v, want the source unverified", err)
+		}
+	})
+
+	t.Run("Not verified by default", func(t *testing.T) {
+		mock := NewMockExecutor()
+		if err := NewFilesystemOperations(mock).CopyDirectory(ctx, "/src", "/dst"); err != nil {
+			t.Fatalf("CopyDirectory() error = %v", err)
+		}
+		for _, call := range mock.Calls {
+			if call.Name == "find" {
+				t.Errorf("Copy was verified: %v", call.Args)
+			}
+		}
+	})
+}
+
+// unprivilegedRsync runs rsync as nobody, so files only root may read cannot
+// be copied although the container runs as root
+type unprivilegedRsync struct {
+	CommandExecutor
+}
+
+func (e unprivilegedRsync) Execute(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if name == "rsync" {
+		args = append([]string{"--reuid=nobody", "--regid=nogroup", "--clear-groups", "rsync"}, args...)
+		name = "setpriv"
+	}
+	return e.CommandExecutor.Execute(ctx, name, args...)
+}
+
+// TestCopyDirectoryVerifyDocker copies a tree holding an unreadable file and
+// checks the copy fails naming it
+func TestCopyDirectoryVerifyDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-copydir-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	setup := "apt-get update && apt-get install -y rsync" +
+		" && mkdir -p /tmp/src/sub /tmp/dst" +
+		" && echo config > /tmp/src/config.txt && echo data > /tmp/src/sub/data.txt" +
+		" && echo secret > /tmp/src/sub/secret.key && chmod 000 /tmp/src/sub/secret.key" +
+		" && chown nobody /tmp/dst"
+	if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+		t.Fatalf("Failed to set up the tree: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(unprivilegedRsync{executor})
+	fsOps.SetVerifyCopies(true)
+
+	err = fsOps.CopyDirectory(ctx, "/tmp/src", "/tmp/dst")
+	var incomplete *IncompleteCopyError
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("CopyDirectory() error = %v, want an IncompleteCopyError", err)
+	}
+	if len(incomplete.Files) != 1 || incomplete.Files[0] != "sub/secret.key" {
+		t.Errorf("Files = %v, want only the unreadable file", incomplete.Files)
+	}
+	if _, err := executor.Execute(ctx, "test", "-f", "/tmp/dst/sub/data.txt"); err != nil {
+		t.Errorf("Readable files were not copied: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// FstabEntry describes a filesystem to mount at boot
+type FstabEntry struct {
+	// Device is the partition holding the filesystem. Its UUID is written to
+	// fstab; sources already given as UUID=, PARTUUID= or LABEL=, and
+	// pseudo filesystems such as tmpfs, are written as is.
+	Device string
+	// MountPoint is where the filesystem is mounted, "none" for swap
+	MountPoint string
+	// FSType is the filesystem type, detected with blkid when empty
+	FSType string
+	// Options are the mount options, "defaults" when empty
+	Options []string
+	// Dump enables backups of the filesystem by dump, usually 0
+	Dump int
+	// Pass is the fsck order: 1 for the root filesystem, 2 for the others,
+	// 0 to skip checking
+	Pass int
+}
+
+// fstabHeader documents the columns at the top of a generated fstab
+const fstabHeader = "# <file system>\t<mount point>\t<type>\t<options>\t<dump>\t<pass>\n"
+
+// GenerateFstab renders the /etc/fstab content mounting the given entries.
+// Partitions are referenced by filesystem UUID, resolved with blkid, since
+// device names change between the build host and the booted node.
+func (f *FilesystemOperations) GenerateFstab(ctx context.Context, mounts []FstabEntry) (string, error) {
+	var b strings.Builder
+	b.WriteString(fstabHeader)
+
+	for _, mount := range mounts {
+		if mount.Device == "" || mount.MountPoint == "" {
+			return "", fmt.Errorf("fstab entry requires a device and a mount point: %+v", mount)
+		}
+
+		source := mount.Device
+		if strings.HasPrefix(source, "/dev/") {
+			uuid, err := f.filesystemUUID(ctx, source)
+			if err != nil {
+				return "", err
+			}
+			source = "UUID=" + uuid
+		}
+
+		fsType := mount.FSType
+		if fsType == "" {
+			detected, err := f.GetFilesystemType(ctx, mount.Device)
+			if err != nil {
+				return "", fmt.Errorf("failed to detect filesystem type of %s: %w", mount.Device, err)
+			}
+			fsType = detected
+		}
+
+		options := "defaults"
+		if len(mount.Options) > 0 {
+			options = strings.Join(mount.Options, ",")
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%d\n",
+			source, escapeFstabField(mount.MountPoint), fsType, options, mount.Dump, mount.Pass)
+	}
+	return b.String(), nil
+}
+
+// filesystemUUID returns the UUID of the filesystem on a device
+func (f *FilesystemOperations) filesystemUUID(ctx context.Context, device string) (string, error) {
+	output, err := ExecuteCommand(f.executor, ctx, "blkid", "-o", "value", "-s", "UUID", device)
+	if err != nil {
+		return "", NewOperationError("reading filesystem UUID", device, err)
+	}
+
+	uuid := strings.TrimSpace(string(output))
+	if uuid == "" {
+		return "", fmt.Errorf("no filesystem UUID found on %s", device)
+	}
+	return uuid, nil
+}
+
+// escapeFstabField escapes the whitespace fstab uses as column separator
+func escapeFstabField(field string) string {
+	return strings.NewReplacer(" ", `\040`, "\t", `\011`).Replace(field)
+}
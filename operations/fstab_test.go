@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFstabMock(t *testing.T) {
+	ctx := context.Background()
+
+	mockResponse := func(output string, err error) struct {
+		Output []byte
+		Err    error
+	} {
+		return struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(output), Err: err}
+	}
+
+	t.Run("Uses filesystem UUIDs", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["blkid -o value -s UUID /dev/mapper/loop0p1"] = mockResponse("4A1B-2C3D\n", nil)
+		mockExec.MockResponses["blkid -o value -s UUID /dev/mapper/loop0p2"] = mockResponse("0f3e8c2a-7b1d-4e5f-9a6b-1c2d3e4f5a6b\n", nil)
+		mockExec.MockResponses["blkid -o value -s TYPE /dev/mapper/loop0p2"] = mockResponse("ext4\n", nil)
+
+		fsOps := NewFilesystemOperations(mockExec)
+		fstab, err := fsOps.GenerateFstab(ctx, []FstabEntry{
+			{Device: "/dev/mapper/loop0p2", MountPoint: "/", Options: []string{"defaults", "noatime"}, Pass: 1},
+			{Device: "/dev/mapper/loop0p1", MountPoint: "/boot/firmware", FSType: "vfat", Pass: 2},
+			{Device: "tmpfs", MountPoint: "/var/log", FSType: "tmpfs", Options: []string{"size=64m"}},
+			{Device: "LABEL=data", MountPoint: "/mnt/shared data", FSType: "ext4", Options: []string{"nofail"}, Dump: 1, Pass: 2},
+		})
+		if err != nil {
+			t.Fatalf("GenerateFstab() error = %v", err)
+		}
+
+		want := []string{
+			fstabHeader[:len(fstabHeader)-1],
+			"UUID=0f3e8c2a-7b1d-4e5f-9a6b-1c2d3e4f5a6b\t/\text4\tdefaults,noatime\t0\t1",
+			"UUID=4A1B-2C3D\t/boot/firmware\tvfat\tdefaults\t0\t2",
+			"tmpfs\t/var/log\ttmpfs\tsize=64m\t0\t0",
+			`LABEL=data	/mnt/shared\040data	ext4	nofail	1	2`,
+		}
+		if got := strings.Split(strings.TrimSuffix(fstab, "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("GenerateFstab() =\n%s\nwant\n%s", fstab, strings.Join(want, "\n"))
+		}
+		if strings.Contains(fstab, "/dev/mapper") {
+			t.Errorf("GenerateFstab() references device names:\n%s", fstab)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(fstab), "\n")[1:] {
+			if fields := strings.Fields(line); len(fields) != 6 {
+				t.Errorf("Line %q has %d columns, want 6", line, len(fields))
+			}
+		}
+	})
+
+	t.Run("Device without UUID", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["blkid -o value -s UUID /dev/sdX1"] = mockResponse("", errors.New("exit status 2"))
+
+		fsOps := NewFilesystemOperations(mockExec)
+		if _, err := fsOps.GenerateFstab(ctx, []FstabEntry{{Device: "/dev/sdX1", MountPoint: "/", FSType: "ext4"}}); err == nil {
+			t.Error("GenerateFstab() expected an error for a device without UUID")
+		}
+	})
+
+	t.Run("Incomplete entry", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(NewMockExecutor())
+		if _, err := fsOps.GenerateFstab(ctx, []FstabEntry{{Device: "/dev/sdX1"}}); err == nil {
+			t.Error("GenerateFstab() expected an error for an entry without mount point")
+		}
+	})
+}
@@ -3,6 +3,7 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
 )
@@ -12,6 +13,7 @@ import (
 type ImageOperations struct {
 	executor CommandExecutor
 	fs       *FilesystemOperations
+	output   io.Writer
 }
 
 // NewImageOperations creates a new ImageOperations instance
@@ -22,6 +24,13 @@ func NewImageOperations(executor CommandExecutor) *ImageOperations {
 	}
 }
 
+// SetOutput sets a writer that receives the live output of long-running commands
+// such as dd. When nil (the default), output is buffered and only reported on failure.
+func (i *ImageOperations) SetOutput(w io.Writer) {
+	i.output = w
+	i.fs.SetOutput(w)
+}
+
 // CopyToDevice copies an image to a device
 func (i *ImageOperations) CopyToDevice(ctx context.Context, imagePath, device string) error {
 	// Check if image file exists first
@@ -30,10 +39,16 @@ func (i *ImageOperations) CopyToDevice(ctx context.Context, imagePath, device st
 		return fmt.Errorf("image file does not exist: %s", imagePath)
 	}
 
-	// Use dd to copy the image to the device
-	output, err := i.executor.Execute(ctx, "dd", "if="+imagePath, "of="+device, "bs=4M", "status=progress")
-	if err != nil {
-		return fmt.Errorf("failed to copy image to device: %s: %w", string(output), err)
+	// Use dd to copy the image to the device, streaming its progress when requested
+	if i.output != nil {
+		if _, err := ExecuteCommandStream(i.executor, ctx, i.output, i.output, "dd", "if="+imagePath, "of="+device, "bs=4M", "status=progress"); err != nil {
+			return fmt.Errorf("failed to copy image to device: %w", err)
+		}
+	} else {
+		output, err := i.executor.Execute(ctx, "dd", "if="+imagePath, "of="+device, "bs=4M", "status=progress")
+		if err != nil {
+			return fmt.Errorf("failed to copy image to device: %s: %w", string(output), err)
+		}
 	}
 
 	// Sync to ensure all data is written
@@ -0,0 +1,50 @@
+package operations
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// imageLocks serializes partition mapping and unmapping per image, so two
+// workflows working on the same image never interleave kpartx and losetup
+// calls, while different images are still handled concurrently
+var imageLocks = &imageLockSet{locks: make(map[string]*imageLock)}
+
+// imageLockSet holds the lock of every image being mapped or unmapped
+type imageLockSet struct {
+	mu    sync.Mutex
+	locks map[string]*imageLock
+}
+
+// imageLock is the lock of an image, dropped once no caller holds or waits for it
+type imageLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks an image and returns the function unlocking it, meant to be
+// deferred as in: defer imageLocks.lock(imgPath)()
+func (s *imageLockSet) lock(imgPath string) (unlock func()) {
+	key := filepath.Clean(imgPath)
+
+	s.mu.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &imageLock{}
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, key)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kpartxTracker is an executor recording how many kpartx calls run at once
+// for each image, each call taking a while
+type kpartxTracker struct {
+	mu      sync.Mutex
+	running map[string]int
+	max     map[string]int
+	overall int
+	peak    int
+}
+
+func newKpartxTracker() *kpartxTracker {
+	return &kpartxTracker{running: make(map[string]int), max: make(map[string]int)}
+}
+
+func (k *kpartxTracker) Execute(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if name != "kpartx" {
+		return nil, nil
+	}
+	image := args[len(args)-1]
+
+	k.mu.Lock()
+	k.running[image]++
+	k.overall++
+	if k.running[image] > k.max[image] {
+		k.max[image] = k.running[image]
+	}
+	if k.overall > k.peak {
+		k.peak = k.overall
+	}
+	k.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	k.mu.Lock()
+	k.running[image]--
+	k.overall--
+	k.mu.Unlock()
+
+	if strings.HasPrefix(args[0], "-a") {
+		return []byte("add map loop0p1 (253:1): 0 524288 linear 7:0 8192\n"), nil
+	}
+	return nil, nil
+}
+
+func (k *kpartxTracker) ExecuteWithInput(ctx context.Context, input string, name string, args ...string) ([]byte, error) {
+	return k.Execute(ctx, name, args...)
+}
+
+func (k *kpartxTracker) ExecuteInPath(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	return k.Execute(ctx, name, args...)
+}
+
+func (k *kpartxTracker) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	_, err := k.Execute(ctx, name, args...)
+	return 0, err
+}
+
+func TestImageLock(t *testing.T) {
+	ctx := context.Background()
+	tracker := newKpartxTracker()
+	fsOps := NewFilesystemOperations(tracker)
+
+	var wg sync.WaitGroup
+	for _, image := range []string{"/tmp/a.img", "/tmp/b.img"} {
+		for i := 0; i < 3; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if _, err := fsOps.MapAllPartitions(ctx, image); err != nil {
+					t.Errorf("MapAllPartitions() error = %v", err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if err := fsOps.UnmapPartitions(ctx, image); err != nil {
+					t.Errorf("UnmapPartitions() error = %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for image, max := range tracker.max {
+		if max != 1 {
+			t.Errorf("%d kpartx calls ran at once for %s, want 1", max, image)
+		}
+	}
+	if tracker.peak < 2 {
+		t.Errorf("Different images were not mapped concurrently")
+	}
+	if len(imageLocks.locks) != 0 {
+		t.Errorf("Image locks left behind: %v", imageLocks.locks)
+	}
+}
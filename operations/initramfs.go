@@ -0,0 +1,133 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownInitramfsFormat is returned by ModifyInitramfs when an initramfs
+// is neither a cpio archive nor one of the supported compressed formats
+var ErrUnknownInitramfsFormat = errors.New("unknown initramfs format")
+
+// initramfsFormat describes how an initramfs is compressed
+type initramfsFormat struct {
+	name  string
+	magic []byte
+	// tool decompresses with decompress and compresses with compress, both
+	// streaming from stdin to stdout
+	tool       string
+	decompress string
+	compress   string
+}
+
+// initramfsFormats are the formats recognized by their leading magic bytes.
+// The kernel only understands the legacy lz4 format, lz4 frames are repacked
+// as such too.
+var initramfsFormats = []initramfsFormat{
+	{name: "gzip", magic: []byte{0x1f, 0x8b}, tool: "gzip", decompress: "gzip -dc", compress: "gzip -9c"},
+	{name: "zstd", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, tool: "zstd", decompress: "zstd -dcq", compress: "zstd -19 -cq"},
+	{name: "lz4", magic: []byte{0x02, 0x21, 0x4c, 0x18}, tool: "lz4", decompress: "lz4 -dc", compress: "lz4 -l -9 -c"},
+	{name: "lz4", magic: []byte{0x04, 0x22, 0x4d, 0x18}, tool: "lz4", decompress: "lz4 -dc", compress: "lz4 -9 -c"},
+	{name: "cpio", magic: []byte("070701"), tool: "cat", decompress: "cat", compress: "cat"},
+	{name: "cpio", magic: []byte("070702"), tool: "cat", decompress: "cat", compress: "cat"},
+}
+
+// ModifyInitramfs unpacks an initramfs into a temporary directory, calls fn
+// with that directory to add or modify files, typically a kernel module, then
+// repacks it in place in its original format. Gzip, zstd and lz4 compressed
+// initramfs and plain cpio archives are supported. extractDir is a path where
+// the executor runs commands, fn should go through the executor or
+// FilesystemOperations to modify it. The initramfs is left untouched when fn
+// fails. Initramfs made of several concatenated archives, like an early
+// microcode archive followed by the main one, are not supported.
+func (i *ImageOperations) ModifyInitramfs(ctx context.Context, initrdPath string, fn func(extractDir string) error) error {
+	if _, err := i.executor.Execute(ctx, "test", "-f", initrdPath); err != nil {
+		return fmt.Errorf("initramfs file does not exist: %s", initrdPath)
+	}
+
+	format, err := i.initramfsFormat(ctx, initrdPath)
+	if err != nil {
+		return NewOperationError("detecting initramfs format", initrdPath, err)
+	}
+
+	output, err := ExecuteCommand(i.executor, ctx, "mktemp", "-d")
+	if err != nil {
+		return NewOperationError("creating temporary directory", initrdPath, err)
+	}
+	workDir := strings.TrimSpace(string(output))
+	defer func() {
+		_, _ = i.executor.Execute(ctx, "rm", "-rf", workDir)
+	}()
+
+	extractDir := filepath.Join(workDir, "root")
+	if _, err := i.executor.Execute(ctx, "mkdir", "-p", extractDir); err != nil {
+		return NewOperationError("creating extraction directory", initrdPath, err)
+	}
+
+	unpack := fmt.Sprintf("%s < %s | (cd %s && cpio -idm --quiet)",
+		format.decompress, quoteArg(initrdPath), quoteArg(extractDir))
+	if _, err := ExecuteCommand(i.executor, ctx, "bash", "-c", "set -o pipefail; "+unpack); err != nil {
+		if toolErr := i.checkInitramfsTools(ctx, format); toolErr != nil {
+			return toolErr
+		}
+		return NewOperationError(fmt.Sprintf("unpacking %s initramfs", format.name), initrdPath, err)
+	}
+
+	if err := fn(extractDir); err != nil {
+		return err
+	}
+
+	// Files are sorted so repacking the same tree gives the same archive
+	repacked := filepath.Join(workDir, "initramfs.new")
+	repack := fmt.Sprintf("cd %s && find . -print0 | LC_ALL=C sort -z | cpio --null -o -H newc --quiet | %s > %s",
+		quoteArg(extractDir), format.compress, quoteArg(repacked))
+	if _, err := ExecuteCommand(i.executor, ctx, "bash", "-c", "set -o pipefail; "+repack); err != nil {
+		return NewOperationError(fmt.Sprintf("repacking %s initramfs", format.name), initrdPath, err)
+	}
+
+	// Copied over the original so it keeps its ownership and permissions
+	if _, err := ExecuteCommand(i.executor, ctx, "cp", repacked, initrdPath); err != nil {
+		return NewOperationError("replacing initramfs", initrdPath, err)
+	}
+	return nil
+}
+
+// initramfsFormat detects the format of an initramfs from its magic bytes
+func (i *ImageOperations) initramfsFormat(ctx context.Context, initrdPath string) (initramfsFormat, error) {
+	output, err := ExecuteCommand(i.executor, ctx, "od", "-An", "-tx1", "-N6", initrdPath)
+	if err != nil {
+		return initramfsFormat{}, fmt.Errorf("failed to read magic bytes: %w", err)
+	}
+	magic, err := hex.DecodeString(strings.Join(strings.Fields(string(output)), ""))
+	if err != nil {
+		return initramfsFormat{}, fmt.Errorf("failed to parse magic bytes %q: %w", output, err)
+	}
+
+	for _, format := range initramfsFormats {
+		if bytes.HasPrefix(magic, format.magic) {
+			return format, nil
+		}
+	}
+	return initramfsFormat{}, fmt.Errorf("%w: magic bytes %x", ErrUnknownInitramfsFormat, magic)
+}
+
+// checkInitramfsTools returns an error naming the tool missing to unpack an
+// initramfs, or nil if they are installed
+func (i *ImageOperations) checkInitramfsTools(ctx context.Context, format initramfsFormat) error {
+	for _, tool := range []string{format.tool, "cpio"} {
+		if _, err := ExecuteCommand(i.executor, ctx, "which", tool); err != nil {
+			return fmt.Errorf("%s command not found. Please install %s: %v", tool, tool, err)
+		}
+	}
+	return nil
+}
+
+// quoteArg quotes an argument for a bash command line
+func quoteArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", "'\\''") + "'"
+}
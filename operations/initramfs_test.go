@@ -0,0 +1,180 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestInitramfsFormatMock(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		magic string
+		want  string
+	}{
+		{" 1f 8b 08 00 00 00\n", "gzip"},
+		{" 28 b5 2f fd 04 58\n", "zstd"},
+		{" 02 21 4c 18 f1 1d\n", "lz4"},
+		{" 04 22 4d 18 64 40\n", "lz4"},
+		{" 30 37 30 37 30 31\n", "cpio"},
+	}
+	for _, tt := range tests {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["od -An -tx1 -N6 /boot/initrd.img"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(tt.magic)}
+
+		format, err := NewImageOperations(mockExec).initramfsFormat(ctx, "/boot/initrd.img")
+		if err != nil || format.name != tt.want {
+			t.Errorf("initramfsFormat(%q) = %s, %v, want %s", tt.magic, format.name, err, tt.want)
+		}
+	}
+
+	mockExec := NewMockExecutor()
+	mockExec.MockResponses["od -An -tx1 -N6 /boot/initrd.img"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte(" fd 37 7a 58 5a 00\n")}
+	if _, err := NewImageOperations(mockExec).initramfsFormat(ctx, "/boot/initrd.img"); !errors.Is(err, ErrUnknownInitramfsFormat) {
+		t.Errorf("initramfsFormat() of an xz initramfs error = %v, want ErrUnknownInitramfsFormat", err)
+	}
+}
+
+func TestModifyInitramfsMock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Missing initramfs", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["test -f /boot/missing.img"] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+
+		err := NewImageOperations(mockExec).ModifyInitramfs(ctx, "/boot/missing.img", func(string) error {
+			t.Error("Callback called for a missing initramfs")
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("ModifyInitramfs() error = %v", err)
+		}
+	})
+
+	t.Run("Callback failure leaves the initramfs untouched", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["od -An -tx1 -N6 /boot/initrd.img"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(" 1f 8b 08 00 00 00\n")}
+		mockExec.MockResponses["mktemp -d"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("/tmp/tmp.initrd\n")}
+
+		errInject := errors.New("module not found")
+		var extracted string
+		err := NewImageOperations(mockExec).ModifyInitramfs(ctx, "/boot/initrd.img", func(extractDir string) error {
+			extracted = extractDir
+			return errInject
+		})
+		if !errors.Is(err, errInject) {
+			t.Fatalf("ModifyInitramfs() error = %v, want the callback error", err)
+		}
+		if extracted != "/tmp/tmp.initrd/root" {
+			t.Errorf("extractDir = %s, want /tmp/tmp.initrd/root", extracted)
+		}
+		for _, call := range mockExec.Calls {
+			if call.Name == "cp" {
+				t.Errorf("Initramfs replaced after a callback failure: cp %v", call.Args)
+			}
+		}
+	})
+}
+
+// TestModifyInitramfsDocker packs a small initramfs in every supported
+// format, injects a module into it and checks the module is present once
+// the repacked initramfs is extracted again
+func TestModifyInitramfsDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-initramfs-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y cpio zstd lz4"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	imageOps := NewImageOperations(executor)
+
+	module := []byte("not really a kernel module")
+	formats := map[string]string{
+		"gzip": "gzip -9c",
+		"zstd": "zstd -cq",
+		"lz4":  "lz4 -l -c",
+		"cpio": "cat",
+	}
+	for name, compress := range formats {
+		t.Run(name, func(t *testing.T) {
+			initrd := "/tmp/initrd-" + name + ".img"
+			build := fmt.Sprintf("rm -rf /tmp/src && mkdir -p /tmp/src/bin && echo '#!/bin/sh' > /tmp/src/init && "+
+				"cd /tmp/src && find . | cpio -o -H newc --quiet | %s > %s", compress, initrd)
+			if _, err := executor.Execute(ctx, "bash", "-c", build); err != nil {
+				t.Fatalf("Failed to build initramfs: %v", err)
+			}
+
+			err := imageOps.ModifyInitramfs(ctx, initrd, func(extractDir string) error {
+				if !fsOps.FileExists(extractDir, "init") {
+					return errors.New("init missing from the extracted initramfs")
+				}
+				return fsOps.WriteFile(extractDir, "lib/modules/extra/driver.ko", module, 0644)
+			})
+			if err != nil {
+				t.Fatalf("ModifyInitramfs() error = %v", err)
+			}
+
+			format, err := imageOps.initramfsFormat(ctx, initrd)
+			if err != nil || format.name != name {
+				t.Errorf("Repacked initramfs format = %s, %v, want %s", format.name, err, name)
+			}
+
+			var got []byte
+			err = imageOps.ModifyInitramfs(ctx, initrd, func(extractDir string) error {
+				if !fsOps.FileExists(extractDir, "init") {
+					return errors.New("init lost when repacking")
+				}
+				got, err = fsOps.ReadFile(extractDir, "lib/modules/extra/driver.ko")
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Extracting the repacked initramfs failed: %v", err)
+			}
+			if string(got) != string(module) {
+				t.Errorf("driver.ko = %q, want %q", got, module)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -86,6 +87,12 @@ func (d *DockerExecutorAdapter) ExecuteInPath(ctx context.Context, dir string, n
 	return []byte(output), err
 }
 
+// ExecuteStream implements CommandExecutor.ExecuteStream
+func (d *DockerExecutorAdapter) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	cmdArgs := append([]string{name}, args...)
+	return d.adapter.ExecuteCommandStream(cmdArgs, stdout, stderr)
+}
+
 // setupExecutor creates an appropriate CommandExecutor
 // - On Linux, uses native commands
 // - On non-Linux, uses Docker
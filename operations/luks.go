@@ -0,0 +1,83 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// luksMapperDir is where cryptsetup exposes opened LUKS devices
+const luksMapperDir = "/dev/mapper"
+
+// SetupLUKS encrypts a device with LUKS using the given passphrase and opens
+// it. It returns the /dev/mapper device holding the decrypted view, which is
+// the device to format and mount. The passphrase is written to cryptsetup's
+// stdin, through the exec session when the executor runs in a container, so it
+// never shows up in the process list or in a file.
+func (f *FilesystemOperations) SetupLUKS(ctx context.Context, device, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("LUKS passphrase cannot be empty")
+	}
+	if _, err := ExecuteCommand(f.executor, ctx, "test", "-b", device); err != nil {
+		return "", NewOperationError("LUKS device validation", device, err)
+	}
+
+	name := luksMapperName(device)
+	mappedDevice := filepath.Join(luksMapperDir, name)
+	// cryptsetup status only succeeds for an active mapping
+	if _, err := f.executor.Execute(ctx, "cryptsetup", "status", name); err == nil {
+		return "", fmt.Errorf("LUKS mapping %s already exists", mappedDevice)
+	}
+
+	if _, err := ExecuteCommandWithInput(f.executor, ctx, passphrase, "cryptsetup", "luksFormat", "--batch-mode", "--key-file=-", device); err != nil {
+		if _, checkErr := ExecuteCommand(f.executor, ctx, "which", "cryptsetup"); checkErr != nil {
+			return "", fmt.Errorf("cryptsetup command not found. Please install cryptsetup: %v", checkErr)
+		}
+		return "", NewOperationError("LUKS format", device, err)
+	}
+
+	if _, err := ExecuteCommandWithInput(f.executor, ctx, passphrase, "cryptsetup", "luksOpen", "--key-file=-", device, name); err != nil {
+		return "", NewOperationError("LUKS open", device, err)
+	}
+
+	if err := f.waitForDevice(ctx, mappedDevice, 10); err != nil {
+		_, _ = f.executor.Execute(ctx, "cryptsetup", "luksClose", name)
+		return "", err
+	}
+
+	return mappedDevice, nil
+}
+
+// CloseLUKS closes a LUKS device opened by SetupLUKS. The mapped device must
+// not be mounted.
+func (f *FilesystemOperations) CloseLUKS(ctx context.Context, mappedDevice string) error {
+	if filepath.Dir(mappedDevice) != luksMapperDir {
+		return fmt.Errorf("not a LUKS mapped device: %s", mappedDevice)
+	}
+
+	if _, err := ExecuteCommand(f.executor, ctx, "cryptsetup", "luksClose", filepath.Base(mappedDevice)); err != nil {
+		return NewOperationError("LUKS close", mappedDevice, err)
+	}
+	return nil
+}
+
+// LUKSUUID returns the UUID of the LUKS header on a device, as used in crypttab
+func (f *FilesystemOperations) LUKSUUID(ctx context.Context, device string) (string, error) {
+	output, err := ExecuteCommand(f.executor, ctx, "cryptsetup", "luksUUID", device)
+	if err != nil {
+		return "", NewOperationError("reading LUKS UUID", device, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CrypttabEntry returns the /etc/crypttab line unlocking a LUKS device by UUID
+// at boot under the given mapper name, prompting for the passphrase
+func CrypttabEntry(name, uuid string) string {
+	return fmt.Sprintf("%s UUID=%s none luks,discard\n", name, uuid)
+}
+
+// luksMapperName derives the mapper name used for a device, e.g. luks-loop0p2
+func luksMapperName(device string) string {
+	return "luks-" + filepath.Base(device)
+}
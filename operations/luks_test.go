@@ -0,0 +1,212 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestSetupLUKSMock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Formats and opens the device", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["cryptsetup status luks-loop0p2"] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 4")}
+
+		fsOps := NewFilesystemOperations(mockExec)
+		mapped, err := fsOps.SetupLUKS(ctx, "/dev/mapper/loop0p2", "secret")
+		if err != nil {
+			t.Fatalf("SetupLUKS() error = %v", err)
+		}
+		if mapped != "/dev/mapper/luks-loop0p2" {
+			t.Errorf("SetupLUKS() = %s, want /dev/mapper/luks-loop0p2", mapped)
+		}
+
+		var commands []string
+		for _, call := range mockExec.Calls {
+			if call.Name == "cryptsetup" {
+				commands = append(commands, strings.Join(call.Args, " "))
+			}
+		}
+		want := []string{
+			"status luks-loop0p2",
+			"luksFormat --batch-mode --key-file=- /dev/mapper/loop0p2",
+			"luksOpen --key-file=- /dev/mapper/loop0p2 luks-loop0p2",
+		}
+		if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+			t.Errorf("cryptsetup calls = %q, want %q", commands, want)
+		}
+
+		for _, call := range mockExec.Calls {
+			for _, arg := range call.Args {
+				if strings.Contains(arg, "secret") {
+					t.Errorf("Passphrase passed as an argument to %s", call.Name)
+				}
+			}
+		}
+	})
+
+	t.Run("Refuses an existing mapping", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(NewMockExecutor())
+		if _, err := fsOps.SetupLUKS(ctx, "/dev/mapper/loop0p2", "secret"); err == nil {
+			t.Error("SetupLUKS() expected an error when the mapping is already open")
+		}
+	})
+
+	t.Run("Rejects an empty passphrase", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(NewMockExecutor())
+		if _, err := fsOps.SetupLUKS(ctx, "/dev/mapper/loop0p2", ""); err == nil {
+			t.Error("SetupLUKS() expected an error for an empty passphrase")
+		}
+	})
+
+	t.Run("CloseLUKS only accepts mapped devices", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(NewMockExecutor())
+		if err := fsOps.CloseLUKS(ctx, "/dev/sda2"); err == nil {
+			t.Error("CloseLUKS() expected an error for a raw device")
+		}
+		if err := fsOps.CloseLUKS(ctx, "/dev/mapper/luks-loop0p2"); err != nil {
+			t.Errorf("CloseLUKS() error = %v", err)
+		}
+	})
+}
+
+// stdinContainer is a container recording the commands run in it and the
+// input written to their stdin
+type stdinContainer struct {
+	container.Container
+	commands []string
+	inputs   map[string]string
+}
+
+func (c *stdinContainer) Exec(ctx context.Context, cmd []string) (string, error) {
+	command := strings.Join(cmd, " ")
+	c.commands = append(c.commands, command)
+	if strings.HasPrefix(command, "cryptsetup status") {
+		return "", errors.New("command failed with exit code 4")
+	}
+	return "", nil
+}
+
+func (c *stdinContainer) ExecWithInput(ctx context.Context, cmd []string, stdin io.Reader) (string, error) {
+	command := strings.Join(cmd, " ")
+	c.commands = append(c.commands, command)
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	c.inputs[command] = string(input)
+	return "", nil
+}
+
+func TestSetupLUKSContainer(t *testing.T) {
+	ctr := &stdinContainer{inputs: make(map[string]string)}
+	fsOps := NewFilesystemOperations(NewContainerExecutor(ctr))
+
+	if _, err := fsOps.SetupLUKS(context.Background(), "/dev/mapper/loop0p2", "secret"); err != nil {
+		t.Fatalf("SetupLUKS() error = %v", err)
+	}
+
+	for _, command := range []string{
+		"cryptsetup luksFormat --batch-mode --key-file=- /dev/mapper/loop0p2",
+		"cryptsetup luksOpen --key-file=- /dev/mapper/loop0p2 luks-loop0p2",
+	} {
+		if input, ok := ctr.inputs[command]; !ok || input != "secret" {
+			t.Errorf("Input of %q = %q, want the passphrase on stdin", command, input)
+		}
+	}
+	for _, command := range ctr.commands {
+		if strings.Contains(command, "secret") {
+			t.Errorf("Passphrase passed in the command %q", command)
+		}
+	}
+}
+
+func TestCrypttabEntry(t *testing.T) {
+	got := CrypttabEntry("cryptroot", "0b1c2d3e-1111-2222-3333-444455556666")
+	want := "cryptroot UUID=0b1c2d3e-1111-2222-3333-444455556666 none luks,discard\n"
+	if got != want {
+		t.Errorf("CrypttabEntry() = %q, want %q", got, want)
+	}
+}
+
+// TestSetupLUKSDocker encrypts a partition of a real image inside a privileged container
+func TestSetupLUKSDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:      "ubuntu:latest",
+			Name:       fmt.Sprintf("turingpi-test-luks-%d", time.Now().Unix()),
+			Command:    []string{"sleep", "infinity"},
+			Privileged: true,
+			Mounts:     map[string]string{"/dev": "/dev"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y kpartx fdisk cryptsetup e2fsprogs"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	img := "/tmp/luks.img"
+	setup := strings.Join([]string{
+		"dd if=/dev/zero of=" + img + " bs=1M count=64",
+		"printf 'label: dos\\n,,83\\n' | sfdisk " + img,
+	}, " && ")
+	if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	partitions, err := fsOps.MapAllPartitions(ctx, img)
+	if err != nil {
+		t.Fatalf("MapAllPartitions() error = %v", err)
+	}
+	defer fsOps.UnmapPartitions(ctx, img)
+
+	mapped, err := fsOps.SetupLUKS(ctx, partitions[0].Device, "turingpi-test")
+	if err != nil {
+		t.Fatalf("SetupLUKS() error = %v", err)
+	}
+
+	if err := fsOps.Format(ctx, mapped, "ext4", "rootfs"); err != nil {
+		t.Errorf("Format() error = %v", err)
+	}
+	if fsType, err := fsOps.GetFilesystemType(ctx, mapped); err != nil || fsType != "ext4" {
+		t.Errorf("Mapped device filesystem = %q, %v, want ext4", fsType, err)
+	}
+	if fsType, err := fsOps.GetFilesystemType(ctx, partitions[0].Device); err != nil || fsType != "crypto_LUKS" {
+		t.Errorf("Partition filesystem = %q, %v, want crypto_LUKS", fsType, err)
+	}
+	if uuid, err := fsOps.LUKSUUID(ctx, partitions[0].Device); err != nil || uuid == "" {
+		t.Errorf("LUKSUUID() = %q, %v", uuid, err)
+	}
+
+	if err := fsOps.CloseLUKS(ctx, mapped); err != nil {
+		t.Fatalf("CloseLUKS() error = %v", err)
+	}
+	if _, err := executor.Execute(ctx, "test", "-e", mapped); err == nil {
+		t.Errorf("Mapped device %s still exists after CloseLUKS", mapped)
+	}
+}
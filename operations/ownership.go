@@ -0,0 +1,128 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileOwner is the owner given to a file written into a mounted image.
+// Names are resolved in the image, not on the host, since the same name
+// usually maps to different IDs on both.
+type FileOwner struct {
+	// User is a user name resolved in the image's /etc/passwd, taking
+	// precedence over UID. Its primary group is used unless a group is given.
+	User string
+	// Group is a group name resolved in the image's /etc/group, taking
+	// precedence over GID
+	Group string
+	// UID and GID are used when no user or group name is given
+	UID int
+	GID int
+}
+
+// OwnerID returns the owner with the given numeric IDs
+func OwnerID(uid, gid int) FileOwner {
+	return FileOwner{UID: uid, GID: gid}
+}
+
+// OwnerName returns the owner with the given user name and its primary group
+func OwnerName(user string) FileOwner {
+	return FileOwner{User: user}
+}
+
+// WriteFileAs writes a file like WriteFile and gives it to owner
+func (f *FilesystemOperations) WriteFileAs(mountDir, path string, content []byte, perm fs.FileMode, owner FileOwner) error {
+	if err := f.WriteFile(mountDir, path, content, perm); err != nil {
+		return err
+	}
+	return f.ChangeOwner(mountDir, path, owner)
+}
+
+// MakeDirectoryAs creates a directory like MakeDirectory and gives it to owner.
+// Parent directories it creates are left owned by root.
+func (f *FilesystemOperations) MakeDirectoryAs(mountDir, path string, perm fs.FileMode, owner FileOwner) error {
+	if err := f.MakeDirectory(mountDir, path, perm); err != nil {
+		return err
+	}
+	return f.ChangeOwner(mountDir, path, owner)
+}
+
+// ChangeOwner changes the owner of a file or directory of the mounted filesystem
+func (f *FilesystemOperations) ChangeOwner(mountDir, path string, owner FileOwner) error {
+	uid, gid, err := f.resolveOwner(mountDir, owner)
+	if err != nil {
+		return err
+	}
+
+	fullPath := filepath.Join(mountDir, path)
+	if _, err := ExecuteCommand(f.executor, context.Background(), "chown", fmt.Sprintf("%d:%d", uid, gid), fullPath); err != nil {
+		return NewOperationError("changing owner", fullPath, err)
+	}
+	return nil
+}
+
+// resolveOwner returns the numeric IDs of an owner, looking names up in the
+// account files of the mounted filesystem
+func (f *FilesystemOperations) resolveOwner(mountDir string, owner FileOwner) (uid, gid int, err error) {
+	uid, gid = owner.UID, owner.GID
+	if uid < 0 || gid < 0 {
+		return 0, 0, fmt.Errorf("invalid owner %d:%d", uid, gid)
+	}
+
+	if owner.User != "" {
+		fields, err := f.lookupAccount(mountDir, "etc/passwd", owner.User)
+		if err != nil {
+			return 0, 0, err
+		}
+		// name:password:uid:gid:...
+		if len(fields) < 4 {
+			return 0, 0, fmt.Errorf("malformed /etc/passwd entry for user %s", owner.User)
+		}
+		if uid, err = strconv.Atoi(fields[2]); err != nil {
+			return 0, 0, fmt.Errorf("malformed uid for user %s: %q", owner.User, fields[2])
+		}
+		if gid, err = strconv.Atoi(fields[3]); err != nil {
+			return 0, 0, fmt.Errorf("malformed gid for user %s: %q", owner.User, fields[3])
+		}
+	}
+
+	if owner.Group != "" {
+		fields, err := f.lookupAccount(mountDir, "etc/group", owner.Group)
+		if err != nil {
+			return 0, 0, err
+		}
+		// name:password:gid:members
+		if len(fields) < 3 {
+			return 0, 0, fmt.Errorf("malformed /etc/group entry for group %s", owner.Group)
+		}
+		if gid, err = strconv.Atoi(fields[2]); err != nil {
+			return 0, 0, fmt.Errorf("malformed gid for group %s: %q", owner.Group, fields[2])
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// lookupAccount returns the colon separated fields of the entry for name in
+// an account file of the mounted filesystem, such as etc/passwd
+func (f *FilesystemOperations) lookupAccount(mountDir, file, name string) ([]string, error) {
+	content, err := f.ReadFile(mountDir, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /%s of the image: %w", file, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if fields[0] == name {
+			return fields, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found in /%s of the image", name, file)
+}
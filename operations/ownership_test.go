@@ -0,0 +1,150 @@
+package operations
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+ubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash
+k3s:x:1001:1001::/var/lib/k3s:/usr/sbin/nologin
+`
+
+const testGroup = `root:x:0:
+docker:x:998:ubuntu
+ubuntu:x:1000:
+`
+
+// newOwnershipMock returns a mock executor serving the account files of an
+// image mounted at /mnt/root
+func newOwnershipMock() *MockExecutor {
+	mockExec := NewMockExecutor()
+	for file, content := range map[string]string{"passwd": testPasswd, "group": testGroup} {
+		mockExec.MockResponses["bash -c cat '/mnt/root/etc/"+file+"' | base64"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(base64.StdEncoding.EncodeToString([]byte(content)))}
+	}
+	return mockExec
+}
+
+func TestChangeOwnerMock(t *testing.T) {
+	tests := []struct {
+		name  string
+		owner FileOwner
+		want  string
+	}{
+		{"Numeric IDs", OwnerID(1001, 998), "1001:998"},
+		{"User and primary group", OwnerName("ubuntu"), "1000:1000"},
+		{"User and group", FileOwner{User: "ubuntu", Group: "docker"}, "1000:998"},
+		{"Group only", FileOwner{UID: 0, Group: "docker"}, "0:998"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExec := newOwnershipMock()
+			fsOps := NewFilesystemOperations(mockExec)
+			if err := fsOps.WriteFileAs("/mnt/root", "home/ubuntu/welcome.txt", []byte("Welcome\n"), 0644, tt.owner); err != nil {
+				t.Fatalf("WriteFileAs() error = %v", err)
+			}
+
+			last := mockExec.Calls[len(mockExec.Calls)-1]
+			if got := last.Name + " " + strings.Join(last.Args, " "); got != "chown "+tt.want+" /mnt/root/home/ubuntu/welcome.txt" {
+				t.Errorf("Last command = %s, want chown %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Directory", func(t *testing.T) {
+		mockExec := newOwnershipMock()
+		if err := NewFilesystemOperations(mockExec).MakeDirectoryAs("/mnt/root", "home/ubuntu/.ssh", 0700, OwnerName("ubuntu")); err != nil {
+			t.Fatalf("MakeDirectoryAs() error = %v", err)
+		}
+		last := mockExec.Calls[len(mockExec.Calls)-1]
+		if strings.Join(last.Args, " ") != "1000:1000 /mnt/root/home/ubuntu/.ssh" {
+			t.Errorf("Last command = %s %v", last.Name, last.Args)
+		}
+	})
+
+	t.Run("Unknown names", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(newOwnershipMock())
+		if err := fsOps.ChangeOwner("/mnt/root", "file", OwnerName("pi")); err == nil || !strings.Contains(err.Error(), "pi not found") {
+			t.Errorf("ChangeOwner() error = %v, want the unknown user reported", err)
+		}
+		if err := fsOps.ChangeOwner("/mnt/root", "file", FileOwner{Group: "wheel"}); err == nil {
+			t.Error("ChangeOwner() expected an error for an unknown group")
+		}
+		if err := fsOps.ChangeOwner("/mnt/root", "file", OwnerID(-1, 0)); err == nil {
+			t.Error("ChangeOwner() expected an error for a negative uid")
+		}
+	})
+}
+
+// TestWriteFileAsDocker writes files with explicit owners into a directory
+// laid out like an image and checks their ownership
+func TestWriteFileAsDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-owner-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	mountDir := "/tmp/image-root"
+	fsOps := NewFilesystemOperations(executor)
+	if err := fsOps.WriteFile(mountDir, "etc/passwd", []byte(testPasswd), 0644); err != nil {
+		t.Fatalf("Failed to write passwd: %v", err)
+	}
+	if err := fsOps.WriteFile(mountDir, "etc/group", []byte(testGroup), 0644); err != nil {
+		t.Fatalf("Failed to write group: %v", err)
+	}
+
+	owner := func(t *testing.T, path string) string {
+		t.Helper()
+		output, err := executor.Execute(ctx, "stat", "-c", "%u %g", mountDir+"/"+path)
+		if err != nil {
+			t.Fatalf("stat %s error = %v", path, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	if err := fsOps.WriteFileAs(mountDir, "srv/data.bin", []byte{1, 2, 3}, 0600, OwnerID(1234, 5678)); err != nil {
+		t.Fatalf("WriteFileAs() error = %v", err)
+	}
+	if got := owner(t, "srv/data.bin"); got != "1234 5678" {
+		t.Errorf("Owner of srv/data.bin = %s, want 1234 5678", got)
+	}
+
+	if err := fsOps.MakeDirectoryAs(mountDir, "home/ubuntu", 0755, OwnerName("ubuntu")); err != nil {
+		t.Fatalf("MakeDirectoryAs() error = %v", err)
+	}
+	if err := fsOps.WriteFileAs(mountDir, "home/ubuntu/welcome.txt", []byte("Welcome\n"), 0644, FileOwner{User: "ubuntu", Group: "docker"}); err != nil {
+		t.Fatalf("WriteFileAs() error = %v", err)
+	}
+	if got := owner(t, "home/ubuntu"); got != "1000 1000" {
+		t.Errorf("Owner of home/ubuntu = %s, want 1000 1000", got)
+	}
+	if got := owner(t, "home/ubuntu/welcome.txt"); got != "1000 998" {
+		t.Errorf("Owner of welcome.txt = %s, want 1000 998", got)
+	}
+}
@@ -0,0 +1,227 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TableType is the partitioning scheme of a disk image
+type TableType string
+
+const (
+	// TableMBR is the legacy DOS partition table
+	TableMBR TableType = "mbr"
+	// TableGPT is the GUID partition table, expected by the RK1 boot flow
+	TableGPT TableType = "gpt"
+)
+
+// ErrUnsafeConversion is returned when converting a partition table would
+// lose or move partitions. The image is left untouched.
+var ErrUnsafeConversion = errors.New("partition table conversion would lose data")
+
+const (
+	// gptReservedSectors is the space used by a GPT header and its partition
+	// entries, at both the start and the end of the disk
+	gptReservedSectors = 34
+	// maxMBRSectors is the highest sector an MBR partition entry can address
+	maxMBRSectors = 1<<32 - 1
+	// maxMBRPartitions is the number of primary partitions an MBR can hold
+	maxMBRPartitions = 4
+)
+
+// partitionTable is the partition table of an image as listed by sfdisk --json
+type partitionTable struct {
+	Label      string           `json:"label"`
+	Device     string           `json:"device"`
+	SectorSize int64            `json:"sectorsize"`
+	Partitions []tablePartition `json:"partitions"`
+}
+
+// tablePartition is a partition entry as listed by sfdisk --json
+type tablePartition struct {
+	Node  string `json:"node"`
+	Start int64  `json:"start"`
+	Size  int64  `json:"size"`
+	Type  string `json:"type"`
+}
+
+// tableType returns the table type matching the sfdisk label
+func (t *partitionTable) tableType() TableType {
+	switch t.Label {
+	case "dos":
+		return TableMBR
+	case "gpt":
+		return TableGPT
+	default:
+		return TableType(t.Label)
+	}
+}
+
+// dataPartitions returns the partitions holding data, without MBR extended
+// partitions which only contain logical ones, sorted by start sector
+func (t *partitionTable) dataPartitions() []tablePartition {
+	var partitions []tablePartition
+	for _, p := range t.Partitions {
+		if t.Label == "dos" && isExtendedPartitionType(p.Type) {
+			continue
+		}
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Start < partitions[j].Start
+	})
+	return partitions
+}
+
+// number returns the partition number from the partition node name
+func (p tablePartition) number() (int, error) {
+	end := len(p.Node)
+	for end > 0 && p.Node[end-1] >= '0' && p.Node[end-1] <= '9' {
+		end--
+	}
+	return strconv.Atoi(p.Node[end:])
+}
+
+// isExtendedPartitionType reports whether an MBR type is an extended partition
+func isExtendedPartitionType(partType string) bool {
+	switch strings.ToLower(strings.TrimPrefix(partType, "0x")) {
+	case "5", "f", "85":
+		return true
+	default:
+		return false
+	}
+}
+
+// parsePartitionTable parses the output of sfdisk --json
+func parsePartitionTable(output []byte) (*partitionTable, error) {
+	var dump struct {
+		PartitionTable *partitionTable `json:"partitiontable"`
+	}
+	if err := json.Unmarshal(output, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse sfdisk output: %w", err)
+	}
+	if dump.PartitionTable == nil {
+		return nil, fmt.Errorf("no partition table in sfdisk output")
+	}
+	if dump.PartitionTable.SectorSize == 0 {
+		dump.PartitionTable.SectorSize = sectorSize
+	}
+	return dump.PartitionTable, nil
+}
+
+// ConvertPartitionTable converts the partition table of a disk image between
+// MBR and GPT with sgdisk, keeping every partition at the same place. Images
+// already using the requested table are left as they are. A conversion that
+// cannot keep all partitions, such as a GPT with more than four partitions or
+// an MBR without room for the GPT headers, fails with ErrUnsafeConversion
+// before the image is modified.
+func (i *ImageOperations) ConvertPartitionTable(ctx context.Context, imgPath string, to TableType) error {
+	if to != TableMBR && to != TableGPT {
+		return fmt.Errorf("unsupported partition table type: %s", to)
+	}
+	if _, err := ExecuteCommand(i.executor, ctx, "test", "-f", imgPath); err != nil {
+		return NewOperationError("image validation", imgPath, err)
+	}
+
+	table, err := i.readPartitionTable(ctx, imgPath)
+	if err != nil {
+		return err
+	}
+	from := table.tableType()
+	if from == to {
+		return nil
+	}
+
+	partitions := table.dataPartitions()
+	var args []string
+	switch {
+	case from == TableMBR && to == TableGPT:
+		output, err := ExecuteCommand(i.executor, ctx, "stat", "-c", "%s", imgPath)
+		if err != nil {
+			return NewOperationError("getting image size", imgPath, err)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse image size %q: %w", strings.TrimSpace(string(output)), err)
+		}
+
+		// The GPT headers take the first and last sectors of the image
+		lastUsable := size/table.SectorSize - gptReservedSectors - 1
+		for _, p := range partitions {
+			if p.Start < gptReservedSectors || p.Start+p.Size-1 > lastUsable {
+				return fmt.Errorf("%w: partition %s (sectors %d-%d) overlaps the space needed by the GPT headers",
+					ErrUnsafeConversion, p.Node, p.Start, p.Start+p.Size-1)
+			}
+		}
+		args = []string{"--mbrtogpt", imgPath}
+
+	case from == TableGPT && to == TableMBR:
+		if len(partitions) > maxMBRPartitions {
+			return fmt.Errorf("%w: %d partitions do not fit in an MBR, which holds %d",
+				ErrUnsafeConversion, len(partitions), maxMBRPartitions)
+		}
+		var numbers []string
+		for _, p := range partitions {
+			if p.Start+p.Size-1 > maxMBRSectors {
+				return fmt.Errorf("%w: partition %s ends beyond the 2 TiB addressable by an MBR",
+					ErrUnsafeConversion, p.Node)
+			}
+			number, err := p.number()
+			if err != nil {
+				return fmt.Errorf("failed to get the number of partition %s: %w", p.Node, err)
+			}
+			numbers = append(numbers, strconv.Itoa(number))
+		}
+		if len(numbers) == 0 {
+			return fmt.Errorf("%w: no partitions to convert", ErrUnsafeConversion)
+		}
+		args = []string{"--gpttombr=" + strings.Join(numbers, ":"), imgPath}
+
+	default:
+		return fmt.Errorf("cannot convert a %s partition table to %s", from, to)
+	}
+
+	if _, err := ExecuteCommand(i.executor, ctx, "sgdisk", args...); err != nil {
+		if _, checkErr := ExecuteCommand(i.executor, ctx, "which", "sgdisk"); checkErr != nil {
+			return fmt.Errorf("sgdisk command not found. Please install gdisk: %v", checkErr)
+		}
+		return NewOperationError(fmt.Sprintf("converting partition table to %s", to), imgPath, err)
+	}
+
+	// Check the partitions survived where they were
+	converted, err := i.readPartitionTable(ctx, imgPath)
+	if err != nil {
+		return err
+	}
+	if converted.tableType() != to {
+		return fmt.Errorf("partition table of %s is %s after conversion, expected %s", imgPath, converted.tableType(), to)
+	}
+	after := converted.dataPartitions()
+	if len(after) != len(partitions) {
+		return fmt.Errorf("partition table of %s has %d partitions after conversion, expected %d", imgPath, len(after), len(partitions))
+	}
+	for n, p := range partitions {
+		if after[n].Start != p.Start || after[n].Size != p.Size {
+			return fmt.Errorf("partition %s moved from sectors %d+%d to %d+%d during conversion",
+				p.Node, p.Start, p.Size, after[n].Start, after[n].Size)
+		}
+	}
+	return nil
+}
+
+// readPartitionTable lists the partition table of an image with sfdisk
+func (i *ImageOperations) readPartitionTable(ctx context.Context, imgPath string) (*partitionTable, error) {
+	output, err := ExecuteCommand(i.executor, ctx, "sfdisk", "--json", imgPath)
+	if err != nil {
+		if _, checkErr := ExecuteCommand(i.executor, ctx, "which", "sfdisk"); checkErr != nil {
+			return nil, fmt.Errorf("sfdisk command not found. Please install fdisk: %v", checkErr)
+		}
+		return nil, NewOperationError("reading partition table", imgPath, err)
+	}
+	return parsePartitionTable(output)
+}
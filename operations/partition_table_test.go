@@ -0,0 +1,268 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+const sfdiskMBRJSON = `{
+   "partitiontable": {
+      "label": "dos",
+      "id": "0x8d3c1f1e",
+      "device": "/tmp/disk.img",
+      "unit": "sectors",
+      "sectorsize": 512,
+      "partitions": [
+         {"node": "/tmp/disk.img1", "start": 2048, "size": 65536, "type": "c", "bootable": true},
+         {"node": "/tmp/disk.img2", "start": 67584, "size": 32768, "type": "5"},
+         {"node": "/tmp/disk.img5", "start": 69632, "size": 30720, "type": "83"}
+      ]
+   }
+}`
+
+const sfdiskGPTJSON = `{
+   "partitiontable": {
+      "label": "gpt",
+      "id": "5B2A4C8E-1D9F-4E2B-9B51-0C7E6A3F2D10",
+      "device": "/tmp/disk.img",
+      "unit": "sectors",
+      "firstlba": 34,
+      "lastlba": 131038,
+      "sectorsize": 512,
+      "partitions": [
+         {"node": "/tmp/disk.img1", "start": 2048, "size": 65536, "type": "EBD0A0A2-B9E5-4433-87C0-68B6B72699C7"},
+         {"node": "/tmp/disk.img2", "start": 67584, "size": 16384, "type": "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+         {"node": "/tmp/disk.img3", "start": 83968, "size": 16384, "type": "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+         {"node": "/tmp/disk.img4", "start": 100352, "size": 8192, "type": "0FC63DAF-8483-4772-8E79-3D69D8477DE4"},
+         {"node": "/tmp/disk.img5", "start": 108544, "size": 8192, "type": "0FC63DAF-8483-4772-8E79-3D69D8477DE4"}
+      ]
+   }
+}`
+
+func TestParsePartitionTable(t *testing.T) {
+	table, err := parsePartitionTable([]byte(sfdiskMBRJSON))
+	if err != nil {
+		t.Fatalf("parsePartitionTable() error = %v", err)
+	}
+	if table.tableType() != TableMBR || table.SectorSize != 512 {
+		t.Errorf("parsePartitionTable() = %s table with %d byte sectors", table.tableType(), table.SectorSize)
+	}
+
+	// The extended partition only contains the logical one
+	var numbers []int
+	for _, p := range table.dataPartitions() {
+		number, err := p.number()
+		if err != nil {
+			t.Fatalf("number() error = %v", err)
+		}
+		numbers = append(numbers, number)
+	}
+	if want := []int{1, 5}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("dataPartitions() = %v, want %v", numbers, want)
+	}
+
+	if _, err := parsePartitionTable([]byte(`{}`)); err == nil {
+		t.Error("parsePartitionTable() expected an error without a partition table")
+	}
+}
+
+func TestConvertPartitionTableMock(t *testing.T) {
+	ctx := context.Background()
+	const img = "/tmp/disk.img"
+
+	newMock := func(table string, imageSize int64) *MockExecutor {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["sfdisk --json "+img] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(table)}
+		mockExec.MockResponses["stat -c %s "+img] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte(fmt.Sprintf("%d\n", imageSize))}
+		return mockExec
+	}
+
+	ranSgdisk := func(mockExec *MockExecutor) bool {
+		for _, call := range mockExec.Calls {
+			if call.Name == "sgdisk" {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("Same table type is left alone", func(t *testing.T) {
+		mockExec := newMock(sfdiskMBRJSON, 64<<20)
+		if err := NewImageOperations(mockExec).ConvertPartitionTable(ctx, img, TableMBR); err != nil {
+			t.Fatalf("ConvertPartitionTable() error = %v", err)
+		}
+		if ranSgdisk(mockExec) {
+			t.Error("ConvertPartitionTable() ran sgdisk on an image already using MBR")
+		}
+	})
+
+	t.Run("MBR without room for the backup GPT header", func(t *testing.T) {
+		// The logical partition ends on the last sector of a 50MiB image
+		mockExec := newMock(sfdiskMBRJSON, 100352*512)
+		err := NewImageOperations(mockExec).ConvertPartitionTable(ctx, img, TableGPT)
+		if !errors.Is(err, ErrUnsafeConversion) {
+			t.Fatalf("ConvertPartitionTable() error = %v, want ErrUnsafeConversion", err)
+		}
+		if ranSgdisk(mockExec) {
+			t.Error("ConvertPartitionTable() ran sgdisk for an unsafe conversion")
+		}
+	})
+
+	t.Run("GPT with more than four partitions", func(t *testing.T) {
+		mockExec := newMock(sfdiskGPTJSON, 64<<20)
+		err := NewImageOperations(mockExec).ConvertPartitionTable(ctx, img, TableMBR)
+		if !errors.Is(err, ErrUnsafeConversion) {
+			t.Fatalf("ConvertPartitionTable() error = %v, want ErrUnsafeConversion", err)
+		}
+		if ranSgdisk(mockExec) {
+			t.Error("ConvertPartitionTable() ran sgdisk for an unsafe conversion")
+		}
+	})
+
+	t.Run("Converted table is verified", func(t *testing.T) {
+		// The mock keeps listing an MBR after sgdisk ran
+		mockExec := newMock(sfdiskMBRJSON, 64<<20)
+		err := NewImageOperations(mockExec).ConvertPartitionTable(ctx, img, TableGPT)
+		if err == nil || !strings.Contains(err.Error(), "after conversion") {
+			t.Fatalf("ConvertPartitionTable() error = %v, want a verification failure", err)
+		}
+
+		var sgdisk []string
+		for _, call := range mockExec.Calls {
+			if call.Name == "sgdisk" {
+				sgdisk = call.Args
+			}
+		}
+		if want := []string{"--mbrtogpt", img}; !reflect.DeepEqual(sgdisk, want) {
+			t.Errorf("sgdisk args = %v, want %v", sgdisk, want)
+		}
+	})
+
+	t.Run("Missing sgdisk", func(t *testing.T) {
+		mockExec := newMock(sfdiskMBRJSON, 64<<20)
+		mockExec.MockResponses["sgdisk --mbrtogpt "+img] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("executable file not found")}
+		mockExec.MockResponses["which sgdisk"] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+
+		err := NewImageOperations(mockExec).ConvertPartitionTable(ctx, img, TableGPT)
+		if err == nil || !strings.Contains(err.Error(), "gdisk") {
+			t.Errorf("ConvertPartitionTable() error = %v, want a hint to install gdisk", err)
+		}
+	})
+
+	t.Run("Unsupported table type", func(t *testing.T) {
+		if err := NewImageOperations(NewMockExecutor()).ConvertPartitionTable(ctx, img, "apm"); err == nil {
+			t.Error("ConvertPartitionTable() expected an error for an unsupported table type")
+		}
+	})
+}
+
+// TestConvertPartitionTableDocker creates an MBR image, converts it to GPT
+// and back, and checks the partitions keep their place
+func TestConvertPartitionTableDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-ptable-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y fdisk gdisk"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	// sgdiskPartitions lists the start and end sectors printed by sgdisk -p
+	sgdiskPartitions := func(t *testing.T, img string) []string {
+		output, err := executor.Execute(ctx, "sgdisk", "-p", img)
+		if err != nil {
+			t.Fatalf("sgdisk -p error = %v: %s", err, output)
+		}
+		var partitions []string
+		inTable := false
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 0 && fields[0] == "Number" {
+				inTable = true
+				continue
+			}
+			if inTable && len(fields) >= 3 {
+				partitions = append(partitions, fields[0]+":"+fields[1]+"-"+fields[2])
+			}
+		}
+		return partitions
+	}
+
+	imageOps := NewImageOperations(executor)
+	img := "/tmp/mbr.img"
+	if _, err := executor.Execute(ctx, "truncate", "-s", "64M", img); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if output, err := executor.ExecuteWithInput(ctx, "label: dos\n,32M,c,*\n,16M,83\n", "sfdisk", img); err != nil {
+		t.Fatalf("Failed to partition image: %v: %s", err, output)
+	}
+
+	if err := imageOps.ConvertPartitionTable(ctx, img, TableGPT); err != nil {
+		t.Fatalf("ConvertPartitionTable(gpt) error = %v", err)
+	}
+	want := []string{"1:2048-67583", "2:67584-100351"}
+	if got := sgdiskPartitions(t, img); !reflect.DeepEqual(got, want) {
+		t.Errorf("Partitions after GPT conversion = %v, want %v", got, want)
+	}
+	if output, _ := executor.Execute(ctx, "blkid", "-o", "value", "-s", "PTTYPE", img); strings.TrimSpace(string(output)) != "gpt" {
+		t.Errorf("Partition table type = %q, want gpt", strings.TrimSpace(string(output)))
+	}
+
+	if err := imageOps.ConvertPartitionTable(ctx, img, TableMBR); err != nil {
+		t.Fatalf("ConvertPartitionTable(mbr) error = %v", err)
+	}
+	if output, _ := executor.Execute(ctx, "blkid", "-o", "value", "-s", "PTTYPE", img); strings.TrimSpace(string(output)) != "dos" {
+		t.Errorf("Partition table type = %q, want dos", strings.TrimSpace(string(output)))
+	}
+
+	t.Run("Partition filling the image", func(t *testing.T) {
+		full := "/tmp/full.img"
+		if _, err := executor.Execute(ctx, "truncate", "-s", "64M", full); err != nil {
+			t.Fatalf("Failed to create image: %v", err)
+		}
+		if output, err := executor.ExecuteWithInput(ctx, "label: dos\n,,83\n", "sfdisk", full); err != nil {
+			t.Fatalf("Failed to partition image: %v: %s", err, output)
+		}
+
+		if err := imageOps.ConvertPartitionTable(ctx, full, TableGPT); !errors.Is(err, ErrUnsafeConversion) {
+			t.Errorf("ConvertPartitionTable() error = %v, want ErrUnsafeConversion", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PartitionRole classifies what a partition of a disk image is used for
+type PartitionRole string
+
+const (
+	// PartitionRoleBoot holds the firmware, kernel and config.txt/overlays
+	PartitionRoleBoot PartitionRole = "boot"
+	// PartitionRoleRoot holds the root filesystem
+	PartitionRoleRoot PartitionRole = "root"
+	// PartitionRoleData is any other partition
+	PartitionRoleData PartitionRole = "data"
+)
+
+// sectorSize is the sector size reported by kpartx
+const sectorSize = 512
+
+var (
+	bootLabels = map[string]bool{"boot": true, "bootfs": true, "system-boot": true, "efi": true}
+	rootLabels = map[string]bool{"root": true, "rootfs": true, "writable": true, "cloudimg-rootfs": true}
+)
+
+// MappedPartition describes a partition of a disk image mapped with kpartx
+type MappedPartition struct {
+	// Number is the 1-based partition number in the image
+	Number int
+	// Device is the mapped device path (e.g. /dev/mapper/loop0p1)
+	Device string
+	// Size is the partition size in bytes
+	Size int64
+	// FSType is the filesystem type reported by blkid, empty if unknown
+	FSType string
+	// Label is the filesystem label reported by blkid, empty if unset
+	Label string
+	// Role is the detected role of the partition
+	Role PartitionRole
+}
+
+// FindPartition returns the first partition with the given role
+func FindPartition(partitions []MappedPartition, role PartitionRole) (MappedPartition, bool) {
+	for _, partition := range partitions {
+		if partition.Role == role {
+			return partition, true
+		}
+	}
+	return MappedPartition{}, false
+}
+
+// MapAllPartitions maps every partition of a disk image using kpartx and
+// classifies each one as boot, root or data. Mapping and unmapping the same
+// image are serialized, different images are mapped concurrently.
+func (f *FilesystemOperations) MapAllPartitions(ctx context.Context, imgPathAbs string) ([]MappedPartition, error) {
+	defer imageLocks.lock(imgPathAbs)()
+
+	// Ensure the image file exists
+	if _, err := ExecuteCommand(f.executor, ctx, "test", "-f", imgPathAbs); err != nil {
+		return nil, NewOperationError("image validation", imgPathAbs, err)
+	}
+
+	// Execute kpartx to map partitions
+	output, err := ExecuteCommand(f.executor, ctx, "kpartx", "-av", imgPathAbs)
+	if err != nil {
+		// Check if kpartx is installed
+		_, checkErr := ExecuteCommand(f.executor, ctx, "which", "kpartx")
+		if checkErr != nil {
+			return nil, fmt.Errorf("kpartx command not found. Please install kpartx: %v", checkErr)
+		}
+
+		// If kpartx is installed but failed, provide more context
+		return nil, NewOperationError("partition mapping", imgPathAbs, err)
+	}
+
+	partitions, err := parseKpartxPartitions(string(output))
+	if err != nil {
+		return nil, NewOperationError("parsing kpartx output", string(output), err)
+	}
+
+	for i := range partitions {
+		// Wait for the device to become available
+		if err := f.waitForDevice(ctx, partitions[i].Device, 10); err != nil {
+			// Try to get more info about the device
+			deviceListOutput, _ := ExecuteCommand(f.executor, ctx, "ls", "-la", "/dev/mapper")
+			return nil, fmt.Errorf("device not available after mapping: %w (ls -la /dev/mapper: %s)",
+				err, string(deviceListOutput))
+		}
+
+		// Unformatted partitions have no type or label, blkid then fails
+		if fsType, err := f.GetFilesystemType(ctx, partitions[i].Device); err == nil {
+			partitions[i].FSType = fsType
+		}
+		if label, err := f.executor.Execute(ctx, "blkid", "-o", "value", "-s", "LABEL", partitions[i].Device); err == nil {
+			partitions[i].Label = strings.TrimSpace(string(label))
+		}
+	}
+
+	classifyPartitions(partitions)
+	return partitions, nil
+}
+
+// parseKpartxPartitions parses kpartx output into the list of mapped partitions
+func parseKpartxPartitions(output string) ([]MappedPartition, error) {
+	// Example output:
+	// add map loop1p1 (253:1): 0 524288 linear 7:1 8192
+	// add map loop1p2 (253:2): 0 32768000 linear 7:1 532480
+	var partitions []MappedPartition
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "add") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("unexpected kpartx output format: %s", line)
+		}
+
+		partition := MappedPartition{
+			Number: len(partitions) + 1,
+			Device: fmt.Sprintf("/dev/mapper/%s", fields[2]),
+			Role:   PartitionRoleData,
+		}
+
+		// The partition number is the suffix after the last 'p' of the map name
+		if idx := strings.LastIndex(fields[2], "p"); idx >= 0 {
+			if number, err := strconv.Atoi(fields[2][idx+1:]); err == nil {
+				partition.Number = number
+			}
+		}
+
+		// The size in sectors follows the start sector
+		if len(fields) >= 6 {
+			if sectors, err := strconv.ParseInt(fields[5], 10, 64); err == nil {
+				partition.Size = sectors * sectorSize
+			}
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("no valid partition maps found in kpartx output")
+	}
+	return partitions, nil
+}
+
+// classifyPartitions assigns a role to each partition.
+// Filesystem labels are trusted first, then filesystem types (the first FAT
+// partition boots, the largest Linux filesystem is root), and finally the
+// common layout where the first partition is boot and the second is root.
+func classifyPartitions(partitions []MappedPartition) {
+	bootIdx, rootIdx := -1, -1
+
+	for i, partition := range partitions {
+		label := strings.ToLower(partition.Label)
+		if bootIdx < 0 && bootLabels[label] {
+			bootIdx = i
+		} else if rootIdx < 0 && rootLabels[label] {
+			rootIdx = i
+		}
+	}
+
+	if bootIdx < 0 {
+		for i, partition := range partitions {
+			if i != rootIdx && isFATFilesystem(partition.FSType) {
+				bootIdx = i
+				break
+			}
+		}
+	}
+
+	if rootIdx < 0 {
+		for i, partition := range partitions {
+			if i == bootIdx || !isLinuxFilesystem(partition.FSType) {
+				continue
+			}
+			if rootIdx < 0 || partition.Size > partitions[rootIdx].Size {
+				rootIdx = i
+			}
+		}
+	}
+
+	// Fall back to the partition layout when filesystems are unknown
+	if rootIdx < 0 {
+		if len(partitions) == 1 {
+			rootIdx = 0
+		} else {
+			if bootIdx < 0 {
+				bootIdx = 0
+			}
+			for i := range partitions {
+				if i != bootIdx {
+					rootIdx = i
+					break
+				}
+			}
+		}
+	}
+
+	for i := range partitions {
+		switch i {
+		case bootIdx:
+			partitions[i].Role = PartitionRoleBoot
+		case rootIdx:
+			partitions[i].Role = PartitionRoleRoot
+		default:
+			partitions[i].Role = PartitionRoleData
+		}
+	}
+}
+
+// isFATFilesystem reports whether a blkid filesystem type is a FAT variant
+func isFATFilesystem(fsType string) bool {
+	switch strings.ToLower(fsType) {
+	case "vfat", "fat", "fat12", "fat16", "fat32", "msdos":
+		return true
+	}
+	return false
+}
+
+// isLinuxFilesystem reports whether a blkid filesystem type can hold a Linux root
+func isLinuxFilesystem(fsType string) bool {
+	switch strings.ToLower(fsType) {
+	case "ext2", "ext3", "ext4", "btrfs", "xfs", "f2fs":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestParseKpartxPartitions(t *testing.T) {
+	output := "add map loop1p1 (253:1): 0 524288 linear 7:1 8192\n" +
+		"add map loop1p2 (253:2): 0 32768000 linear 7:1 532480\n"
+
+	partitions, err := parseKpartxPartitions(output)
+	if err != nil {
+		t.Fatalf("parseKpartxPartitions() error = %v", err)
+	}
+	if len(partitions) != 2 {
+		t.Fatalf("Got %d partitions, want 2", len(partitions))
+	}
+
+	if partitions[0].Device != "/dev/mapper/loop1p1" || partitions[0].Number != 1 || partitions[0].Size != 524288*512 {
+		t.Errorf("Unexpected first partition: %+v", partitions[0])
+	}
+	if partitions[1].Device != "/dev/mapper/loop1p2" || partitions[1].Number != 2 || partitions[1].Size != 32768000*512 {
+		t.Errorf("Unexpected second partition: %+v", partitions[1])
+	}
+
+	if _, err := parseKpartxPartitions("garbage\n"); err == nil {
+		t.Error("parseKpartxPartitions() expected an error for output without maps")
+	}
+}
+
+func TestClassifyPartitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions []MappedPartition
+		want       []PartitionRole
+	}{
+		{
+			name: "Raspberry Pi style by filesystem",
+			partitions: []MappedPartition{
+				{FSType: "vfat", Size: 256 << 20},
+				{FSType: "ext4", Size: 4 << 30},
+			},
+			want: []PartitionRole{PartitionRoleBoot, PartitionRoleRoot},
+		},
+		{
+			name: "Labels take precedence over layout",
+			partitions: []MappedPartition{
+				{FSType: "ext4", Label: "writable", Size: 4 << 30},
+				{FSType: "vfat", Label: "system-boot", Size: 256 << 20},
+			},
+			want: []PartitionRole{PartitionRoleRoot, PartitionRoleBoot},
+		},
+		{
+			name: "Largest Linux filesystem is root",
+			partitions: []MappedPartition{
+				{FSType: "vfat", Size: 256 << 20},
+				{FSType: "ext4", Size: 1 << 30},
+				{FSType: "ext4", Size: 8 << 30},
+			},
+			want: []PartitionRole{PartitionRoleBoot, PartitionRoleData, PartitionRoleRoot},
+		},
+		{
+			name:       "Single unformatted partition",
+			partitions: []MappedPartition{{}},
+			want:       []PartitionRole{PartitionRoleRoot},
+		},
+		{
+			name:       "Unformatted partitions fall back to layout",
+			partitions: []MappedPartition{{}, {}, {}},
+			want:       []PartitionRole{PartitionRoleBoot, PartitionRoleRoot, PartitionRoleData},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifyPartitions(tt.partitions)
+			for i, partition := range tt.partitions {
+				if partition.Role != tt.want[i] {
+					t.Errorf("Partition %d role = %s, want %s", i, partition.Role, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMapAllPartitionsMock(t *testing.T) {
+	ctx := context.Background()
+	mockExec := NewMockExecutor()
+	mockExec.MockResponses["kpartx -av /tmp/test.img"] = struct {
+		Output []byte
+		Err    error
+	}{
+		Output: []byte("add map loop0p1 (253:0): 0 524288 linear 7:0 2048\nadd map loop0p2 (253:1): 0 1048576 linear 7:0 526336\n"),
+	}
+	mockExec.MockResponses["blkid -o value -s TYPE /dev/mapper/loop0p1"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("vfat\n")}
+	mockExec.MockResponses["blkid -o value -s TYPE /dev/mapper/loop0p2"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("ext4\n")}
+
+	fsOps := NewFilesystemOperations(mockExec)
+	partitions, err := fsOps.MapAllPartitions(ctx, "/tmp/test.img")
+	if err != nil {
+		t.Fatalf("MapAllPartitions() error = %v", err)
+	}
+
+	boot, ok := FindPartition(partitions, PartitionRoleBoot)
+	if !ok || boot.Device != "/dev/mapper/loop0p1" || boot.FSType != "vfat" {
+		t.Errorf("Boot partition = %+v, found = %v", boot, ok)
+	}
+
+	root, err := fsOps.MapPartitions(ctx, "/tmp/test.img")
+	if err != nil {
+		t.Fatalf("MapPartitions() error = %v", err)
+	}
+	if root != "/dev/mapper/loop0p2" {
+		t.Errorf("MapPartitions() = %s, want /dev/mapper/loop0p2", root)
+	}
+}
+
+// TestMapAllPartitionsDocker maps a real two-partition image inside a privileged container
+func TestMapAllPartitionsDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:      "ubuntu:latest",
+			Name:       fmt.Sprintf("turingpi-test-partitions-%d", time.Now().Unix()),
+			Command:    []string{"sleep", "infinity"},
+			Privileged: true,
+			Mounts:     map[string]string{"/dev": "/dev"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y kpartx fdisk dosfstools e2fsprogs"); err != nil {
+		t.Fatalf("Failed to install tools: %v", err)
+	}
+
+	// Build an image with a FAT boot partition followed by an ext4 root partition
+	img := "/tmp/two-partitions.img"
+	setup := strings.Join([]string{
+		"dd if=/dev/zero of=" + img + " bs=1M count=64",
+		"printf 'label: dos\\n,16M,c\\n,,83\\n' | sfdisk " + img,
+	}, " && ")
+	if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	partitions, err := fsOps.MapAllPartitions(ctx, img)
+	if err != nil {
+		t.Fatalf("MapAllPartitions() error = %v", err)
+	}
+	defer fsOps.UnmapPartitions(ctx, img)
+
+	if len(partitions) != 2 {
+		t.Fatalf("Got %d partitions, want 2", len(partitions))
+	}
+	if _, err := executor.Execute(ctx, "mkfs.vfat", "-n", "BOOT", partitions[0].Device); err != nil {
+		t.Fatalf("Failed to format boot partition: %v", err)
+	}
+	if _, err := executor.Execute(ctx, "mkfs.ext4", "-F", "-L", "rootfs", partitions[1].Device); err != nil {
+		t.Fatalf("Failed to format root partition: %v", err)
+	}
+	if err := fsOps.UnmapPartitions(ctx, img); err != nil {
+		t.Fatalf("UnmapPartitions() error = %v", err)
+	}
+
+	// Map again now that the partitions carry filesystems
+	partitions, err = fsOps.MapAllPartitions(ctx, img)
+	if err != nil {
+		t.Fatalf("MapAllPartitions() error = %v", err)
+	}
+
+	boot, ok := FindPartition(partitions, PartitionRoleBoot)
+	if !ok || boot.Number != 1 || boot.FSType != "vfat" {
+		t.Fatalf("Boot partition = %+v, found = %v", boot, ok)
+	}
+	root, ok := FindPartition(partitions, PartitionRoleRoot)
+	if !ok || root.Number != 2 || root.FSType != "ext4" {
+		t.Fatalf("Root partition = %+v, found = %v", root, ok)
+	}
+
+	for _, partition := range []MappedPartition{boot, root} {
+		mountPoint := "/mnt/" + string(partition.Role)
+		if err := fsOps.Mount(ctx, partition.Device, mountPoint, partition.FSType, nil); err != nil {
+			t.Errorf("Failed to mount %s partition: %v", partition.Role, err)
+			continue
+		}
+		if err := fsOps.Unmount(ctx, mountPoint); err != nil {
+			t.Errorf("Failed to unmount %s partition: %v", partition.Role, err)
+		}
+	}
+}
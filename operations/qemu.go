@@ -0,0 +1,180 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQEMUNotInstalled is returned by BootTest when qemu-system-aarch64 is not available
+var ErrQEMUNotInstalled = errors.New("qemu-system-aarch64 is not installed")
+
+// ErrQEMUNoFirmware is returned by BootTest when neither a BIOS nor a kernel
+// is set, the virt machine having no firmware of its own to boot an image with
+var ErrQEMUNoFirmware = errors.New("qemu boot test needs a BIOS or a kernel")
+
+// qemuBinary is the emulator used to boot test images
+const qemuBinary = "qemu-system-aarch64"
+
+// QEMUBootOptions configures BootTest
+type QEMUBootOptions struct {
+	// Marker is the serial console output showing the image booted (default "login:")
+	Marker string
+	// Timeout bounds the wait for Marker (default 5 minutes)
+	Timeout time.Duration
+	// Machine is the emulated machine (default "virt")
+	Machine string
+	// CPU is the emulated CPU (default "cortex-a72")
+	CPU string
+	// MemoryMB is the memory of the emulated machine (default 1024)
+	MemoryMB int
+	// BIOS is the firmware to boot the image with, such as QEMU_EFI.fd.
+	// Either BIOS or Kernel must be set.
+	BIOS string
+	// Kernel, Initrd and Append boot a kernel directly instead of through firmware
+	Kernel string
+	Initrd string
+	Append string
+	// ExtraArgs are appended to the qemu command line
+	ExtraArgs []string
+}
+
+// withDefaults returns the options with defaults applied
+func (o QEMUBootOptions) withDefaults() QEMUBootOptions {
+	if o.Marker == "" {
+		o.Marker = "login:"
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Minute
+	}
+	if o.Machine == "" {
+		o.Machine = "virt"
+	}
+	if o.CPU == "" {
+		o.CPU = "cortex-a72"
+	}
+	if o.MemoryMB == 0 {
+		o.MemoryMB = 1024
+	}
+	return o
+}
+
+// args returns the qemu command line booting imgPath headless, with its
+// serial console on stdout. The image is opened in snapshot mode, so the
+// boot never modifies it.
+func (o QEMUBootOptions) args(imgPath string) []string {
+	args := []string{
+		"-machine", o.Machine,
+		"-cpu", o.CPU,
+		"-m", strconv.Itoa(o.MemoryMB),
+		"-nographic",
+		"-no-reboot",
+		"-snapshot",
+		"-drive", "file=" + strings.ReplaceAll(imgPath, ",", ",,") + ",format=raw,if=virtio",
+	}
+	if o.BIOS != "" {
+		args = append(args, "-bios", o.BIOS)
+	}
+	if o.Kernel != "" {
+		args = append(args, "-kernel", o.Kernel)
+	}
+	if o.Initrd != "" {
+		args = append(args, "-initrd", o.Initrd)
+	}
+	if o.Append != "" {
+		args = append(args, "-append", o.Append)
+	}
+	return append(args, o.ExtraArgs...)
+}
+
+// BootTest boots an image under qemu-system-aarch64 without display and
+// reports whether opts.Marker appeared on its serial console before
+// opts.Timeout, stopping the emulator as soon as it does. An image that does
+// not reach the marker in time is reported as not booted, without error.
+// ErrQEMUNotInstalled is returned when qemu is missing, so callers such as CI
+// gates can skip the check, and ErrQEMUNoFirmware when opts set neither a
+// BIOS nor a kernel.
+func (i *ImageOperations) BootTest(ctx context.Context, imgPath string, opts QEMUBootOptions) (bool, error) {
+	opts = opts.withDefaults()
+	if opts.BIOS == "" && opts.Kernel == "" {
+		return false, ErrQEMUNoFirmware
+	}
+
+	if _, err := i.executor.Execute(ctx, "test", "-f", imgPath); err != nil {
+		return false, fmt.Errorf("image file does not exist: %s", imgPath)
+	}
+	if _, err := i.executor.Execute(ctx, "which", qemuBinary); err != nil {
+		return false, fmt.Errorf("%w: please install qemu-system-arm: %v", ErrQEMUNotInstalled, err)
+	}
+
+	bootCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	console := &markerWriter{marker: []byte(opts.Marker), onMatch: cancel}
+	var stdout io.Writer = console
+	if i.output != nil {
+		stdout = io.MultiWriter(console, i.output)
+	}
+
+	_, err := ExecuteCommandStream(i.executor, bootCtx, stdout, i.output, qemuBinary, opts.args(imgPath)...)
+	switch {
+	case console.matched():
+		return true, nil
+	case ctx.Err() != nil:
+		return false, ctx.Err()
+	case bootCtx.Err() != nil:
+		// Timed out before the marker
+		return false, nil
+	case err != nil:
+		return false, NewOperationError("boot testing image", imgPath, err)
+	default:
+		// The emulator stopped before the marker, such as on a kernel panic
+		return false, nil
+	}
+}
+
+// markerWriter watches a stream for a marker and calls onMatch once it is seen
+type markerWriter struct {
+	marker  []byte
+	onMatch func()
+
+	mu    sync.Mutex
+	tail  []byte
+	found bool
+}
+
+// Write implements io.Writer
+func (w *markerWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.found {
+		return len(p), nil
+	}
+
+	// Keep enough of the previous writes to match a marker split across them
+	w.tail = append(w.tail, p...)
+	if bytes.Contains(w.tail, w.marker) {
+		w.found = true
+		w.tail = nil
+		w.onMatch()
+		return len(p), nil
+	}
+	if keep := len(w.marker) - 1; len(w.tail) > keep {
+		w.tail = append(w.tail[:0], w.tail[len(w.tail)-keep:]...)
+	}
+	return len(p), nil
+}
+
+// matched reports whether the marker was seen
+func (w *markerWriter) matched() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.found
+}
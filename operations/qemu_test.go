@@ -0,0 +1,162 @@
+package operations
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBootTestMock(t *testing.T) {
+	ctx := context.Background()
+	opts := QEMUBootOptions{Kernel: "/boot/Image", Append: "console=ttyAMA0 root=/dev/vda2"}
+	qemuKey := qemuBinary + " " + strings.Join(opts.withDefaults().args("/tmp/node.img"), " ")
+
+	t.Run("Marker detected", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses[qemuKey] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("[    1.234] Run /sbin/init\n\nUbuntu 22.04 LTS node ttyAMA0\n\nnode login: ")}
+
+		booted, err := NewImageOperations(mockExec).BootTest(ctx, "/tmp/node.img", opts)
+		if err != nil || !booted {
+			t.Fatalf("BootTest() = %v, %v, want booted", booted, err)
+		}
+
+		last := mockExec.Calls[len(mockExec.Calls)-1]
+		args := strings.Join(last.Args, " ")
+		for _, want := range []string{"-nographic", "-snapshot", "file=/tmp/node.img,format=raw,if=virtio", "-kernel /boot/Image"} {
+			if !strings.Contains(args, want) {
+				t.Errorf("qemu arguments %q lack %q", args, want)
+			}
+		}
+	})
+
+	t.Run("Kernel panic", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses[qemuKey] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("Kernel panic - not syncing: VFS: Unable to mount root fs\n")}
+
+		booted, err := NewImageOperations(mockExec).BootTest(ctx, "/tmp/node.img", opts)
+		if err != nil || booted {
+			t.Errorf("BootTest() = %v, %v, want not booted without error", booted, err)
+		}
+	})
+
+	t.Run("Emulator failure", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses[qemuKey] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+
+		if booted, err := NewImageOperations(mockExec).BootTest(ctx, "/tmp/node.img", opts); err == nil || booted {
+			t.Errorf("BootTest() = %v, %v, want an error", booted, err)
+		}
+	})
+
+	t.Run("Neither BIOS nor kernel", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		_, err := NewImageOperations(mockExec).BootTest(ctx, "/tmp/node.img", QEMUBootOptions{})
+		if !errors.Is(err, ErrQEMUNoFirmware) {
+			t.Errorf("BootTest() error = %v, want ErrQEMUNoFirmware", err)
+		}
+		if len(mockExec.Calls) != 0 {
+			t.Errorf("BootTest() ran %d commands without firmware", len(mockExec.Calls))
+		}
+	})
+
+	t.Run("QEMU not installed", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["which "+qemuBinary] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+
+		_, err := NewImageOperations(mockExec).BootTest(ctx, "/tmp/node.img", opts)
+		if !errors.Is(err, ErrQEMUNotInstalled) {
+			t.Errorf("BootTest() error = %v, want ErrQEMUNotInstalled", err)
+		}
+	})
+}
+
+func TestMarkerWriter(t *testing.T) {
+	matches := 0
+	w := &markerWriter{marker: []byte("login:"), onMatch: func() { matches++ }}
+
+	for _, chunk := range []string{"Ubuntu 22.04\nnode lo", "g", "in: ", "login: again"} {
+		w.Write([]byte(chunk))
+	}
+	if !w.matched() || matches != 1 {
+		t.Errorf("matched() = %v after %d matches, want a single match across writes", w.matched(), matches)
+	}
+}
+
+// bareMetalKernel is an aarch64 program printing "login: " on the PL011 UART
+// of the qemu virt machine, then idling. It is position independent, so it
+// runs wherever qemu loads it.
+func bareMetalKernel() []byte {
+	instructions := []uint32{
+		0xD2A12001, // movz x1, #0x0900, lsl #16 (UART data register)
+		0x100000E2, // adr  x2, message
+		0x38401443, // loop: ldrb w3, [x2], #1
+		0x34000063, // cbz  w3, done
+		0x39000023, // strb w3, [x1]
+		0x17FFFFFD, // b    loop
+		0xD503207F, // done: wfi
+		0x17FFFFFF, // b    done
+	}
+	kernel := make([]byte, 0, 4*len(instructions)+8)
+	for _, instruction := range instructions {
+		kernel = binary.LittleEndian.AppendUint32(kernel, instruction)
+	}
+	return append(kernel, "login: \x00"...)
+}
+
+func TestBootTestQEMU(t *testing.T) {
+	if _, err := exec.LookPath(qemuBinary); err != nil {
+		t.Skipf("Skipping test as %s is not installed", qemuBinary)
+	}
+
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "kernel.bin")
+	image := filepath.Join(dir, "disk.img")
+	if err := os.WriteFile(kernel, bareMetalKernel(), 0644); err != nil {
+		t.Fatalf("Failed to write kernel: %v", err)
+	}
+	if err := os.WriteFile(image, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	imageOps := NewImageOperations(&NativeExecutor{})
+	booted, err := imageOps.BootTest(context.Background(), image, QEMUBootOptions{
+		Kernel:   kernel,
+		MemoryMB: 128,
+		Timeout:  time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("BootTest() error = %v", err)
+	}
+	if !booted {
+		t.Error("BootTest() did not detect the login marker")
+	}
+
+	// An image never printing the marker times out as not booted
+	booted, err = imageOps.BootTest(context.Background(), image, QEMUBootOptions{
+		Kernel:   kernel,
+		MemoryMB: 128,
+		Marker:   "never printed",
+		Timeout:  2 * time.Second,
+	})
+	if err != nil || booted {
+		t.Errorf("BootTest() with an unknown marker = %v, %v, want not booted", booted, err)
+	}
+}
@@ -0,0 +1,107 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiskFree returns the free and total size in bytes of the filesystem holding path,
+// so operations such as decompression can check there is room before starting
+func (f *FilesystemOperations) DiskFree(ctx context.Context, path string) (freeBytes, totalBytes int64, err error) {
+	// -P keeps each filesystem on one line, BusyBox supports it too
+	output, err := ExecuteCommand(f.executor, ctx, "df", "-P", "-B1", path)
+	if err != nil {
+		return 0, 0, NewOperationError("checking disk space", path, err)
+	}
+
+	freeBytes, totalBytes, err = parseDF(string(output))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse disk space of %s: %w", path, err)
+	}
+	return freeBytes, totalBytes, nil
+}
+
+// SystemMemory returns the available and total memory of the system in bytes.
+// Available memory includes the caches the kernel can reclaim.
+func (f *FilesystemOperations) SystemMemory(ctx context.Context) (freeBytes, totalBytes int64, err error) {
+	output, err := ExecuteCommand(f.executor, ctx, "free", "-b")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to check system memory: %w", err)
+	}
+
+	freeBytes, totalBytes, err = parseFree(string(output))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse system memory: %w", err)
+	}
+	return freeBytes, totalBytes, nil
+}
+
+// parseDF reads the available and total bytes from "df -B1" output.
+// A long filesystem name may push the numbers onto the next line, so the
+// fields after the header are read as one sequence:
+// Filesystem, 1B-blocks, Used, Available, Use%, Mounted on.
+func parseDF(output string) (available, total int64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected df output: %q", output)
+	}
+
+	fields := strings.Fields(strings.Join(lines[1:], " "))
+	if len(fields) < 6 {
+		return 0, 0, fmt.Errorf("unexpected df output: %q", output)
+	}
+
+	if total, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid total size %q: %w", fields[1], err)
+	}
+	if available, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid available size %q: %w", fields[3], err)
+	}
+	return available, total, nil
+}
+
+// parseFree reads the available and total bytes from "free -b" output.
+// Recent procps and BusyBox report an "available" column. Older versions
+// do not, the available memory is then free plus buffers and cache.
+func parseFree(output string) (available, total int64, err error) {
+	var header []string
+	var values map[string]int64
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if header == nil {
+			header = fields
+			continue
+		}
+		if fields[0] != "Mem:" {
+			continue
+		}
+
+		values = make(map[string]int64, len(header))
+		for i, name := range header {
+			if i+1 >= len(fields) {
+				break
+			}
+			value, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s memory %q: %w", name, fields[i+1], err)
+			}
+			values[name] = value
+		}
+		break
+	}
+
+	total, ok := values["total"]
+	if !ok {
+		return 0, 0, fmt.Errorf("unexpected free output: %q", output)
+	}
+	if available, ok := values["available"]; ok {
+		return available, total, nil
+	}
+	available = values["free"] + values["buffers"] + values["cached"] + values["buff/cache"]
+	return available, total, nil
+}
@@ -0,0 +1,203 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestParseDF(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantAvailable int64
+		wantTotal     int64
+		wantErr       bool
+	}{
+		{
+			name: "coreutils",
+			output: `Filesystem        1B-blocks        Used   Available Capacity Mounted on
+/dev/nvme0n1p2 502468108288 91236564992 385604681728      20% /
+`,
+			wantAvailable: 385604681728,
+			wantTotal:     502468108288,
+		},
+		{
+			name: "BusyBox",
+			output: `Filesystem           1B-blocks      Used Available Use% Mounted on
+/dev/mmcblk0p1       7522213888 1236836352 5941420032  17% /mnt/sdcard
+`,
+			wantAvailable: 5941420032,
+			wantTotal:     7522213888,
+		},
+		{
+			name: "Wrapped filesystem name",
+			output: `Filesystem     1B-blocks       Used  Available Use% Mounted on
+/dev/mapper/luks-aa1b2c3d4e5f6a7b8c9d0e1f
+               62725623808 4318740480 55189635072   8% /mnt/root
+`,
+			wantAvailable: 55189635072,
+			wantTotal:     62725623808,
+		},
+		{
+			name:    "Header only",
+			output:  "Filesystem 1B-blocks Used Available Use% Mounted on\n",
+			wantErr: true,
+		},
+		{
+			name: "Not a number",
+			output: `Filesystem 1B-blocks Used Available Use% Mounted on
+/dev/sda1 1.5G 1G 500M 66% /
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available, total, err := parseDF(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if available != tt.wantAvailable || total != tt.wantTotal {
+				t.Errorf("parseDF() = %d, %d, want %d, %d", available, total, tt.wantAvailable, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestParseFree(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantAvailable int64
+		wantTotal     int64
+		wantErr       bool
+	}{
+		{
+			name: "procps",
+			output: `               total        used        free      shared  buff/cache   available
+Mem:     16481181696  5347905536  6612656128   412528640  4520620032 10743476224
+Swap:     2147479552           0  2147479552
+`,
+			wantAvailable: 10743476224,
+			wantTotal:     16481181696,
+		},
+		{
+			name: "Old procps",
+			output: `             total       used       free     shared    buffers     cached
+Mem:    8254418944 7901335552  353083392   86327296  312549376 4885446656
+-/+ buffers/cache: 2703339520 5551079424
+Swap:   2147479552          0 2147479552
+`,
+			wantAvailable: 353083392 + 312549376 + 4885446656,
+			wantTotal:     8254418944,
+		},
+		{
+			name: "BusyBox",
+			output: `              total        used        free      shared  buff/cache   available
+Mem:       513331200    47157248   403382272      114688    62791680   452661248
+Swap:              0           0           0
+`,
+			wantAvailable: 452661248,
+			wantTotal:     513331200,
+		},
+		{
+			name: "Old BusyBox",
+			output: `             total       used       free     shared    buffers
+Mem:     513331200  109948928  403382272          0    6492160
+-/+ buffers:         103456768  409874432
+Swap:            0          0          0
+`,
+			wantAvailable: 403382272 + 6492160,
+			wantTotal:     513331200,
+		},
+		{
+			name:    "No memory line",
+			output:  "free: unrecognized option '-b'\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available, total, err := parseFree(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFree() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if available != tt.wantAvailable || total != tt.wantTotal {
+				t.Errorf("parseFree() = %d, %d, want %d, %d", available, total, tt.wantAvailable, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestResourceChecksMock(t *testing.T) {
+	ctx := context.Background()
+	mockExec := NewMockExecutor()
+	mockExec.MockResponses["df -P -B1 /tmp"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("Filesystem 1B-blocks Used Available Capacity Mounted on\ntmpfs 1000 400 600 40% /tmp\n")}
+	mockExec.MockResponses["free -b"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("       total  used  free  shared  buff/cache  available\nMem:    2000   500  1000       0         500       1400\n")}
+
+	fsOps := NewFilesystemOperations(mockExec)
+
+	free, total, err := fsOps.DiskFree(ctx, "/tmp")
+	if err != nil || free != 600 || total != 1000 {
+		t.Errorf("DiskFree() = %d, %d, %v, want 600, 1000", free, total, err)
+	}
+
+	free, total, err = fsOps.SystemMemory(ctx)
+	if err != nil || free != 1400 || total != 2000 {
+		t.Errorf("SystemMemory() = %d, %d, %v, want 1400, 2000", free, total, err)
+	}
+}
+
+// TestResourceChecksDocker reads the disk space and memory of a real container
+func TestResourceChecksDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-resources-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	fsOps := NewFilesystemOperations(executor)
+
+	free, total, err := fsOps.DiskFree(ctx, "/")
+	if err != nil {
+		t.Fatalf("DiskFree() error = %v", err)
+	}
+	if total <= 0 || free <= 0 || free > total {
+		t.Errorf("DiskFree() = %d free of %d", free, total)
+	}
+
+	free, total, err = fsOps.SystemMemory(ctx)
+	if err != nil {
+		t.Fatalf("SystemMemory() error = %v", err)
+	}
+	if total <= 0 || free <= 0 || free > total {
+		t.Errorf("SystemMemory() = %d free of %d", free, total)
+	}
+}
@@ -0,0 +1,101 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// syncStats describes the outcome of an image sync
+type syncStats struct {
+	// TotalSize is the size of the source image in bytes
+	TotalSize int64
+	// Transferred is the number of bytes actually copied to the destination
+	Transferred int64
+	// Incremental reports whether rsync delta transfer was used
+	Incremental bool
+}
+
+var (
+	rsyncTotalSizeRe   = regexp.MustCompile(`(?m)^Total file size:\s*([\d,]+)`)
+	rsyncLiteralDataRe = regexp.MustCompile(`(?m)^Literal data:\s*([\d,]+)`)
+)
+
+// SyncImage copies an image into the cache, transferring only the blocks that
+// changed since the previous sync. It relies on rsync delta transfer and falls
+// back to a full copy when rsync is not available.
+func (i *ImageOperations) SyncImage(ctx context.Context, src, dstInCache string) error {
+	_, err := i.syncImage(ctx, src, dstInCache)
+	return err
+}
+
+// syncImage implements SyncImage and reports how much data was transferred
+func (i *ImageOperations) syncImage(ctx context.Context, src, dst string) (*syncStats, error) {
+	if _, err := i.executor.Execute(ctx, "test", "-f", src); err != nil {
+		return nil, fmt.Errorf("image file does not exist: %s", src)
+	}
+
+	if _, err := i.executor.Execute(ctx, "mkdir", "-p", filepath.Dir(dst)); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if _, err := i.executor.Execute(ctx, "rsync", "--version"); err != nil {
+		return i.copyImage(ctx, src, dst)
+	}
+
+	// Local rsync copies whole files by default, so delta transfer must be
+	// forced, and updating in place keeps unchanged blocks untouched
+	output, err := ExecuteCommand(i.executor, ctx, "rsync", "--inplace", "--no-whole-file", "--stats", src, dst)
+	if err != nil {
+		return nil, NewOperationError("syncing image", src, err)
+	}
+	if i.output != nil {
+		i.output.Write(output)
+	}
+
+	return parseRsyncStats(string(output))
+}
+
+// copyImage performs a full copy of an image when rsync is not available
+func (i *ImageOperations) copyImage(ctx context.Context, src, dst string) (*syncStats, error) {
+	output, err := i.executor.Execute(ctx, "stat", "-c", "%s", src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image size: %s: %w", string(output), err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image size %q: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	if _, err := ExecuteCommand(i.executor, ctx, "cp", "-f", src, dst); err != nil {
+		return nil, NewOperationError("copying image", src, err)
+	}
+
+	return &syncStats{TotalSize: size, Transferred: size}, nil
+}
+
+// parseRsyncStats extracts the total and transferred sizes from rsync --stats output
+func parseRsyncStats(output string) (*syncStats, error) {
+	total, err := parseRsyncNumber(rsyncTotalSizeRe, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rsync total file size: %w", err)
+	}
+	literal, err := parseRsyncNumber(rsyncLiteralDataRe, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rsync literal data: %w", err)
+	}
+
+	return &syncStats{TotalSize: total, Transferred: literal, Incremental: true}, nil
+}
+
+// parseRsyncNumber returns the number captured by re, ignoring thousands separators
+func parseRsyncNumber(re *regexp.Regexp, output string) (int64, error) {
+	match := re.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("value not found in rsync output")
+	}
+	return strconv.ParseInt(strings.ReplaceAll(match[1], ",", ""), 10, 64)
+}
@@ -0,0 +1,135 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestParseRsyncStats(t *testing.T) {
+	output := `
+Number of files: 1 (reg: 1)
+Number of created files: 0
+Number of regular files transferred: 1
+Total file size: 33,554,432 bytes
+Total transferred file size: 33,554,432 bytes
+Literal data: 1,048,576 bytes
+Matched data: 32,505,856 bytes
+File list size: 0
+Total bytes sent: 1,184,941
+Total bytes received: 3,125
+`
+	stats, err := parseRsyncStats(output)
+	if err != nil {
+		t.Fatalf("parseRsyncStats() error = %v", err)
+	}
+	if stats.TotalSize != 33554432 || stats.Transferred != 1048576 || !stats.Incremental {
+		t.Errorf("parseRsyncStats() = %+v", stats)
+	}
+
+	if _, err := parseRsyncStats("sending incremental file list\n"); err == nil {
+		t.Error("parseRsyncStats() expected an error without statistics")
+	}
+}
+
+func TestSyncImageFallback(t *testing.T) {
+	ctx := context.Background()
+	mockExec := NewMockExecutor()
+	mockExec.MockResponses["rsync --version"] = struct {
+		Output []byte
+		Err    error
+	}{Err: errors.New("executable file not found in $PATH")}
+	mockExec.MockResponses["stat -c %s /tmp/build.img"] = struct {
+		Output []byte
+		Err    error
+	}{Output: []byte("4096\n")}
+
+	imageOps := NewImageOperations(mockExec)
+	stats, err := imageOps.syncImage(ctx, "/tmp/build.img", "/cache/images/build.img")
+	if err != nil {
+		t.Fatalf("syncImage() error = %v", err)
+	}
+	if stats.Incremental || stats.Transferred != 4096 {
+		t.Errorf("syncImage() = %+v, want a full copy of 4096 bytes", stats)
+	}
+
+	var copied bool
+	for _, call := range mockExec.Calls {
+		if call.Name == "rsync" && len(call.Args) > 0 && call.Args[0] != "--version" {
+			t.Errorf("rsync was run although it is unavailable: %v", call.Args)
+		}
+		if call.Name == "cp" && strings.Join(call.Args, " ") == "-f /tmp/build.img /cache/images/build.img" {
+			copied = true
+		}
+	}
+	if !copied {
+		t.Error("Image was not copied with cp")
+	}
+}
+
+// TestSyncImageDocker syncs an image twice and checks the second sync only sends the changed blocks
+func TestSyncImageDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-sync-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	if _, err := executor.Execute(ctx, "bash", "-c", "apt-get update && apt-get install -y rsync"); err != nil {
+		t.Fatalf("Failed to install rsync: %v", err)
+	}
+
+	src := "/tmp/build.img"
+	dst := "/cache/images/build.img"
+	if _, err := executor.Execute(ctx, "dd", "if=/dev/urandom", "of="+src, "bs=1M", "count=32"); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	imageOps := NewImageOperations(executor)
+	first, err := imageOps.syncImage(ctx, src, dst)
+	if err != nil {
+		t.Fatalf("First syncImage() error = %v", err)
+	}
+	if !first.Incremental || first.Transferred != first.TotalSize {
+		t.Errorf("First sync = %+v, want the whole image transferred", first)
+	}
+
+	// Rewrite one megabyte in the middle of the image
+	if _, err := executor.Execute(ctx, "dd", "if=/dev/urandom", "of="+src, "bs=1M", "count=1", "seek=16", "conv=notrunc"); err != nil {
+		t.Fatalf("Failed to modify image: %v", err)
+	}
+
+	second, err := imageOps.syncImage(ctx, src, dst)
+	if err != nil {
+		t.Fatalf("Second syncImage() error = %v", err)
+	}
+	if second.Transferred >= second.TotalSize/2 {
+		t.Errorf("Second sync transferred %d of %d bytes, want only the changed blocks", second.Transferred, second.TotalSize)
+	}
+
+	output, err := executor.Execute(ctx, "cmp", src, dst)
+	if err != nil {
+		t.Errorf("Synced image differs from the source: %s: %v", string(output), err)
+	}
+}
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExtractTarInto extracts a tarball into a mounted filesystem, overlaying
+// the files already there. Permissions and numeric ownership are preserved
+// so a rootfs tarball built elsewhere keeps its owners. Gzip, xz and bzip2
+// compressed tarballs are recognized by their extension. stripComponents
+// leading path components are removed from each file name, as with
+// tar --strip-components.
+func (f *FilesystemOperations) ExtractTarInto(ctx context.Context, mountDir, tarPath string, stripComponents int) error {
+	if stripComponents < 0 {
+		return fmt.Errorf("invalid number of path components to strip: %d", stripComponents)
+	}
+	if _, err := f.executor.Execute(ctx, "test", "-f", tarPath); err != nil {
+		return fmt.Errorf("tarball does not exist: %s", tarPath)
+	}
+	if _, err := f.executor.Execute(ctx, "test", "-d", mountDir); err != nil {
+		return fmt.Errorf("mount directory does not exist: %s", mountDir)
+	}
+
+	args := []string{"-x", "-p", "--numeric-owner"}
+	if flag := tarCompressionFlag(tarPath); flag != "" {
+		args = append(args, flag)
+	}
+	if stripComponents > 0 {
+		args = append(args, "--strip-components="+strconv.Itoa(stripComponents))
+	}
+	args = append(args, "-f", tarPath, "-C", mountDir)
+
+	if _, err := ExecuteCommand(f.executor, ctx, "tar", args...); err != nil {
+		if _, checkErr := ExecuteCommand(f.executor, ctx, "which", "tar"); checkErr != nil {
+			return fmt.Errorf("tar command not found. Please install tar: %v", checkErr)
+		}
+		return NewOperationError("extracting tarball", tarPath, err)
+	}
+	return nil
+}
+
+// tarCompressionFlag returns the tar flag decompressing a tarball, based on
+// its extension. BusyBox tar does not detect the compression by itself.
+func tarCompressionFlag(tarPath string) string {
+	name := strings.ToLower(tarPath)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "-z"
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return "-J"
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		return "-j"
+	default:
+		return ""
+	}
+}
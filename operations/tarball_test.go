@@ -0,0 +1,138 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestExtractTarIntoMock(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		tarPath string
+		strip   int
+		want    string
+	}{
+		{"/tmp/rootfs.tar", 0, "-x -p --numeric-owner -f /tmp/rootfs.tar -C /mnt/root"},
+		{"/tmp/rootfs.tar.gz", 1, "-x -p --numeric-owner -z --strip-components=1 -f /tmp/rootfs.tar.gz -C /mnt/root"},
+		{"/tmp/rootfs.TXZ", 2, "-x -p --numeric-owner -J --strip-components=2 -f /tmp/rootfs.TXZ -C /mnt/root"},
+	}
+	for _, tt := range tests {
+		mockExec := NewMockExecutor()
+		fsOps := NewFilesystemOperations(mockExec)
+		if err := fsOps.ExtractTarInto(ctx, "/mnt/root", tt.tarPath, tt.strip); err != nil {
+			t.Errorf("ExtractTarInto(%s) error = %v", tt.tarPath, err)
+			continue
+		}
+
+		last := mockExec.Calls[len(mockExec.Calls)-1]
+		if last.Name != "tar" || strings.Join(last.Args, " ") != tt.want {
+			t.Errorf("ExtractTarInto(%s) ran %s %v, want tar %s", tt.tarPath, last.Name, last.Args, tt.want)
+		}
+	}
+
+	t.Run("Missing tarball", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["test -f /tmp/missing.tar"] = struct {
+			Output []byte
+			Err    error
+		}{Err: errors.New("exit status 1")}
+
+		fsOps := NewFilesystemOperations(mockExec)
+		if err := fsOps.ExtractTarInto(ctx, "/mnt/root", "/tmp/missing.tar", 0); err == nil {
+			t.Error("ExtractTarInto() expected an error for a missing tarball")
+		}
+	})
+
+	t.Run("Extraction failure", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["tar -x -p --numeric-owner -f /tmp/broken.tar -C /mnt/root"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("tar: This does not look like a tar archive"), Err: errors.New("exit status 2")}
+
+		fsOps := NewFilesystemOperations(mockExec)
+		err := fsOps.ExtractTarInto(ctx, "/mnt/root", "/tmp/broken.tar", 0)
+		if err == nil || !strings.Contains(err.Error(), "does not look like a tar archive") {
+			t.Errorf("ExtractTarInto() error = %v, want the tar output", err)
+		}
+	})
+
+	t.Run("Negative strip", func(t *testing.T) {
+		fsOps := NewFilesystemOperations(NewMockExecutor())
+		if err := fsOps.ExtractTarInto(ctx, "/mnt/root", "/tmp/rootfs.tar", -1); err == nil {
+			t.Error("ExtractTarInto() expected an error for a negative strip count")
+		}
+	})
+}
+
+// TestExtractTarIntoDocker extracts a tarball with nested files, permissions
+// and owners inside a container
+func TestExtractTarIntoDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-tar-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	setup := strings.Join([]string{
+		"mkdir -p /tmp/build/rootfs/etc/ssh /tmp/build/rootfs/home/pi /mnt/target/etc",
+		"echo existing > /mnt/target/etc/hostname.orig",
+		"echo turingpi > /tmp/build/rootfs/etc/hostname",
+		"echo 'PermitRootLogin no' > /tmp/build/rootfs/etc/ssh/sshd_config",
+		"chmod 0600 /tmp/build/rootfs/etc/ssh/sshd_config",
+		"chmod 0750 /tmp/build/rootfs/home/pi",
+		"chown 1000:1000 /tmp/build/rootfs/home/pi",
+		"tar -czf /tmp/rootfs.tar.gz -C /tmp/build rootfs",
+	}, " && ")
+	if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+		t.Fatalf("Failed to create tarball: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	if err := fsOps.ExtractTarInto(ctx, "/mnt/target", "/tmp/rootfs.tar.gz", 1); err != nil {
+		t.Fatalf("ExtractTarInto() error = %v", err)
+	}
+
+	checks := map[string]string{
+		"etc/hostname":        "644 0:0",
+		"etc/ssh/sshd_config": "600 0:0",
+		"home/pi":             "750 1000:1000",
+		"etc/hostname.orig":   "644 0:0",
+	}
+	for path, want := range checks {
+		output, err := executor.Execute(ctx, "stat", "-c", "%a %u:%g", "/mnt/target/"+path)
+		if err != nil {
+			t.Errorf("%s is missing after extraction: %v", path, err)
+			continue
+		}
+		if got := strings.TrimSpace(string(output)); got != want {
+			t.Errorf("%s mode and owner = %s, want %s", path, got, want)
+		}
+	}
+	if fsOps.FileExists("/mnt/target", "rootfs") {
+		t.Error("Leading path component was not stripped")
+	}
+}
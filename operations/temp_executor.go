@@ -3,6 +3,7 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 
@@ -124,3 +125,21 @@ func (e *TemporaryContainerExecutor) ExecuteInPath(ctx context.Context, dir stri
 	// Execute the command using the container executor
 	return executor.ExecuteInPath(ctx, dir, name, args...)
 }
+
+// ExecuteStream implements CommandExecutor.ExecuteStream for temporary container execution
+func (e *TemporaryContainerExecutor) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	// Create a temporary container
+	containerInstance, err := e.createTemporaryContainer(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	// Ensure cleanup
+	defer e.containerRegistry.Remove(ctx, containerInstance.ID())
+
+	// Create a container executor for this container
+	executor := NewContainerExecutor(containerInstance)
+
+	// Execute the command using the container executor
+	return executor.ExecuteStream(ctx, stdout, stderr, name, args...)
+}
@@ -3,6 +3,7 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io"
 	"runtime"
 	"sync"
 
@@ -249,6 +250,20 @@ func (e *UnifiedExecutor) ExecuteInPath(ctx context.Context, dir string, name st
 	return executor.ExecuteInPath(ctx, dir, name, args...)
 }
 
+// ExecuteStream implements CommandExecutor.ExecuteStream
+func (e *UnifiedExecutor) ExecuteStream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (int, error) {
+	executor, cleanup, err := e.getExecutor(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	return executor.ExecuteStream(ctx, stdout, stderr, name, args...)
+}
+
 // Close cleans up resources associated with the executor
 func (e *UnifiedExecutor) Close() error {
 	if !e.initialized {
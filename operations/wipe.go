@@ -0,0 +1,130 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WipeMode selects how much of a device WipeDevice overwrites
+type WipeMode int
+
+const (
+	// WipeZeroFirstMB zeroes the first megabyte, which destroys the MBR
+	// partition table, the primary GPT header and most filesystem signatures.
+	// The backup GPT header at the end of the device is left in place.
+	WipeZeroFirstMB WipeMode = iota
+	// WipeFull zeroes the whole device
+	WipeFull
+)
+
+// String returns the name of the wipe mode
+func (m WipeMode) String() string {
+	switch m {
+	case WipeZeroFirstMB:
+		return "zero-first-mb"
+	case WipeFull:
+		return "full"
+	default:
+		return fmt.Sprintf("WipeMode(%d)", int(m))
+	}
+}
+
+var (
+	// ErrWipeNotConfirmed is returned when WipeDevice is called without confirmation
+	ErrWipeNotConfirmed = errors.New("wiping a device requires explicit confirmation")
+	// ErrDeviceMounted is returned when a device or one of its partitions is mounted
+	ErrDeviceMounted = errors.New("device is mounted")
+)
+
+// WipeDevice overwrites a device, or a disk image file, with zeros before it
+// is provisioned again. As this destroys all data, confirm must be true, and
+// the device is refused if it or any of its partitions is mounted.
+func (f *FilesystemOperations) WipeDevice(ctx context.Context, device string, mode WipeMode, confirm bool) error {
+	if !confirm {
+		return fmt.Errorf("%w: %s", ErrWipeNotConfirmed, device)
+	}
+	if mode != WipeZeroFirstMB && mode != WipeFull {
+		return fmt.Errorf("unsupported wipe mode: %s", mode)
+	}
+
+	isBlockDevice := true
+	if _, err := f.executor.Execute(ctx, "test", "-b", device); err != nil {
+		if _, err := ExecuteCommand(f.executor, ctx, "test", "-f", device); err != nil {
+			return NewOperationError("wipe device validation", device, err)
+		}
+		isBlockDevice = false
+	}
+
+	if isBlockDevice {
+		if err := f.ensureNotMounted(ctx, device); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"if=/dev/zero", "of=" + device, "conv=notrunc,fsync"}
+	switch mode {
+	case WipeZeroFirstMB:
+		args = append(args, "bs=1M", "count=1")
+	case WipeFull:
+		size, err := f.deviceSize(ctx, device, isBlockDevice)
+		if err != nil {
+			return err
+		}
+		// Counting bytes stops dd exactly at the end instead of failing on a
+		// full block device or growing an image file
+		args = append(args, "bs=4M", "iflag=count_bytes", "count="+strconv.FormatInt(size, 10))
+	}
+
+	if f.output != nil {
+		args = append(args, "status=progress")
+		if _, err := ExecuteCommandStream(f.executor, ctx, f.output, f.output, "dd", args...); err != nil {
+			return NewOperationError("wiping device", device, err)
+		}
+	} else if _, err := ExecuteCommand(f.executor, ctx, "dd", args...); err != nil {
+		return NewOperationError("wiping device", device, err)
+	}
+
+	if isBlockDevice {
+		// Make the kernel forget the partitions that were just erased
+		_, _ = f.executor.Execute(ctx, "blockdev", "--rereadpt", device)
+	}
+	return nil
+}
+
+// ensureNotMounted fails if a block device or any of its partitions is mounted
+func (f *FilesystemOperations) ensureNotMounted(ctx context.Context, device string) error {
+	output, err := ExecuteCommand(f.executor, ctx, "lsblk", "-nro", "MOUNTPOINT", device)
+	if err != nil {
+		return NewOperationError("checking device mounts", device, err)
+	}
+
+	for _, mountPoint := range strings.Split(string(output), "\n") {
+		if mountPoint = strings.TrimSpace(mountPoint); mountPoint != "" {
+			return fmt.Errorf("%w: %s is mounted on %s", ErrDeviceMounted, device, mountPoint)
+		}
+	}
+	return nil
+}
+
+// deviceSize returns the size in bytes of a block device or image file
+func (f *FilesystemOperations) deviceSize(ctx context.Context, device string, isBlockDevice bool) (int64, error) {
+	var output []byte
+	var err error
+	if isBlockDevice {
+		output, err = ExecuteCommand(f.executor, ctx, "blockdev", "--getsize64", device)
+	} else {
+		output, err = ExecuteCommand(f.executor, ctx, "stat", "-c", "%s", device)
+	}
+	if err != nil {
+		return 0, NewOperationError("getting device size", device, err)
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse size of %s: %w", device, err)
+	}
+	return size, nil
+}
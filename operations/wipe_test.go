@@ -0,0 +1,132 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/turingpi/container"
+)
+
+func TestWipeDeviceMock(t *testing.T) {
+	ctx := context.Background()
+
+	ddArgs := func(mockExec *MockExecutor) string {
+		for _, call := range mockExec.Calls {
+			if call.Name == "dd" {
+				return strings.Join(call.Args, " ")
+			}
+		}
+		return ""
+	}
+
+	t.Run("Requires confirmation", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		err := NewFilesystemOperations(mockExec).WipeDevice(ctx, "/dev/mmcblk0", WipeFull, false)
+		if !errors.Is(err, ErrWipeNotConfirmed) {
+			t.Fatalf("WipeDevice() error = %v, want ErrWipeNotConfirmed", err)
+		}
+		if len(mockExec.Calls) != 0 {
+			t.Errorf("Commands ran without confirmation: %v", mockExec.Calls)
+		}
+	})
+
+	t.Run("Refuses a mounted device", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["lsblk -nro MOUNTPOINT /dev/mmcblk0"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("\n/mnt/root\n")}
+
+		err := NewFilesystemOperations(mockExec).WipeDevice(ctx, "/dev/mmcblk0", WipeZeroFirstMB, true)
+		if !errors.Is(err, ErrDeviceMounted) {
+			t.Fatalf("WipeDevice() error = %v, want ErrDeviceMounted", err)
+		}
+		if args := ddArgs(mockExec); args != "" {
+			t.Errorf("dd ran on a mounted device: %s", args)
+		}
+	})
+
+	t.Run("Zeroes the first megabyte", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		if err := NewFilesystemOperations(mockExec).WipeDevice(ctx, "/dev/mmcblk0", WipeZeroFirstMB, true); err != nil {
+			t.Fatalf("WipeDevice() error = %v", err)
+		}
+		if args := ddArgs(mockExec); args != "if=/dev/zero of=/dev/mmcblk0 conv=notrunc,fsync bs=1M count=1" {
+			t.Errorf("dd args = %s", args)
+		}
+	})
+
+	t.Run("Zeroes the whole device", func(t *testing.T) {
+		mockExec := NewMockExecutor()
+		mockExec.MockResponses["blockdev --getsize64 /dev/mmcblk0"] = struct {
+			Output []byte
+			Err    error
+		}{Output: []byte("31268536320\n")}
+
+		if err := NewFilesystemOperations(mockExec).WipeDevice(ctx, "/dev/mmcblk0", WipeFull, true); err != nil {
+			t.Fatalf("WipeDevice() error = %v", err)
+		}
+		if args := ddArgs(mockExec); args != "if=/dev/zero of=/dev/mmcblk0 conv=notrunc,fsync bs=4M iflag=count_bytes count=31268536320" {
+			t.Errorf("dd args = %s", args)
+		}
+	})
+}
+
+// TestWipeDeviceDocker wipes the partition table of a real image
+func TestWipeDeviceDocker(t *testing.T) {
+	skipIfNoDocker(t)
+
+	ctx := context.Background()
+
+	registry, err := container.NewDockerRegistry()
+	if err != nil {
+		t.Fatalf("Failed to create Docker registry: %v", err)
+	}
+	defer registry.Close()
+
+	executor := NewUnifiedExecutor(UnifiedExecutorOptions{
+		Mode:     ExecuteContainer,
+		Registry: registry,
+		ContainerConfig: container.ContainerConfig{
+			Image:   "ubuntu:latest",
+			Name:    fmt.Sprintf("turingpi-test-wipe-%d", time.Now().Unix()),
+			Command: []string{"sleep", "infinity"},
+		},
+		UsePersistentContainer: true,
+	})
+	defer executor.Close()
+
+	img := "/tmp/wipe.img"
+	setup := strings.Join([]string{
+		"dd if=/dev/urandom of=" + img + " bs=1M count=16",
+		"printf 'label: dos\\n,8M,c\\n,,83\\n' | sfdisk " + img,
+	}, " && ")
+	if _, err := executor.Execute(ctx, "bash", "-c", setup); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if _, err := executor.Execute(ctx, "sfdisk", "-d", img); err != nil {
+		t.Fatalf("Image has no partition table before wiping: %v", err)
+	}
+
+	fsOps := NewFilesystemOperations(executor)
+	if err := fsOps.WipeDevice(ctx, img, WipeZeroFirstMB, true); err != nil {
+		t.Fatalf("WipeDevice(WipeZeroFirstMB) error = %v", err)
+	}
+	if output, err := executor.Execute(ctx, "sfdisk", "-d", img); err == nil {
+		t.Errorf("Partition table still present after wiping:\n%s", output)
+	}
+	if output, _ := executor.Execute(ctx, "stat", "-c", "%s", img); strings.TrimSpace(string(output)) != "16777216" {
+		t.Errorf("Image size changed to %s", output)
+	}
+
+	if err := fsOps.WipeDevice(ctx, img, WipeFull, true); err != nil {
+		t.Fatalf("WipeDevice(WipeFull) error = %v", err)
+	}
+	if output, err := executor.Execute(ctx, "cmp", "-n", "16777216", img, "/dev/zero"); err != nil {
+		t.Errorf("Image is not fully zeroed: %s", output)
+	}
+}
@@ -27,6 +27,17 @@ func DockerAvailable() bool {
 	return cmd.Run() == nil
 }
 
+// ToolAvailable checks if a command line tool is installed on the host, where
+// workflows may not run their commands. Docker must also be running to be
+// reported as available.
+func ToolAvailable(tool string) bool {
+	if tool == "docker" {
+		return DockerAvailable()
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
 // GetHomeDir returns the user's home directory
 func GetHomeDir() (string, error) {
 	return os.UserHomeDir()
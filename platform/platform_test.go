@@ -30,6 +30,18 @@ func TestDockerAvailable(t *testing.T) {
 	t.Logf("Docker available: %v", available)
 }
 
+func TestToolAvailable(t *testing.T) {
+	if !ToolAvailable("go") {
+		t.Error("ToolAvailable(go) = false while running go test")
+	}
+	if ToolAvailable("turingpi-missing-tool") {
+		t.Error("ToolAvailable() = true for a missing tool")
+	}
+	if ToolAvailable("docker") != DockerAvailable() {
+		t.Error("ToolAvailable(docker) disagrees with DockerAvailable()")
+	}
+}
+
 func TestGetHomeDir(t *testing.T) {
 	homeDir, err := GetHomeDir()
 	if err != nil {
@@ -0,0 +1,81 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxMetricSamples bounds the number of samples kept per metric and node
+const MaxMetricSamples = 100
+
+// metricsMu serializes sample recording, which reads then rewrites the history
+var metricsMu sync.Mutex
+
+// MetricSample is a single timestamped measurement of a node metric
+type MetricSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// MetricSamples returns the samples recorded for a metric, oldest first.
+// It handles both in-memory samples and samples decoded from a state file.
+func (s *NodeState) MetricSamples(metric string) []MetricSample {
+	if s == nil || s.Properties == nil {
+		return nil
+	}
+
+	switch raw := s.Properties[metric].(type) {
+	case []MetricSample:
+		samples := make([]MetricSample, len(raw))
+		copy(samples, raw)
+		return samples
+	case []interface{}:
+		// Samples loaded from JSON are decoded as generic maps
+		samples := make([]MetricSample, 0, len(raw))
+		for _, item := range raw {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			value, ok := entry["value"].(float64)
+			if !ok {
+				continue
+			}
+			timestamp, _ := entry["timestamp"].(string)
+			parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+			if err != nil {
+				continue
+			}
+			samples = append(samples, MetricSample{Timestamp: parsed, Value: value})
+		}
+		return samples
+	default:
+		return nil
+	}
+}
+
+// RecordMetricSample appends a sample to a node metric stored in the node properties.
+// Only the most recent MaxMetricSamples samples are kept.
+func RecordMetricSample(m Manager, nodeID NodeID, metric string, sample MetricSample) error {
+	if m == nil {
+		return fmt.Errorf("no state manager configured")
+	}
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	nodeState, err := m.GetNodeState(nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to get state of node %d: %w", nodeID, err)
+	}
+
+	samples := append(nodeState.MetricSamples(metric), sample)
+	if len(samples) > MaxMetricSamples {
+		samples = samples[len(samples)-MaxMetricSamples:]
+	}
+
+	return m.UpdateNodeProperties(nodeID, map[string]interface{}{
+		metric: samples,
+	})
+}
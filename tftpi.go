@@ -14,7 +14,9 @@ import (
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/operations"
 	"github.com/davidroman0O/turingpi/platform"
+	"github.com/davidroman0O/turingpi/state"
 	"github.com/davidroman0O/turingpi/tools"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // TuringPiProvider is the main entry point for the Turing Pi toolkit
@@ -29,6 +31,9 @@ type TuringPiProvider struct {
 	// Tool providers for each cluster
 	toolProviders map[string]*tools.TuringPiToolProvider
 
+	// Node state managers for each cluster
+	stateManagers map[string]state.Manager
+
 	// Workflow runner
 	*gostage.Runner
 }
@@ -76,6 +81,7 @@ func New(opts ...Option) (*TuringPiProvider, error) {
 		config:        cfg,
 		configStore:   store.NewKVStore(),
 		toolProviders: make(map[string]*tools.TuringPiToolProvider),
+		stateManagers: make(map[string]state.Manager),
 		Runner:        gostage.NewRunner(),
 	}
 
@@ -105,7 +111,7 @@ func New(opts ...Option) (*TuringPiProvider, error) {
 		return func(ctx context.Context, w *gostage.Workflow, logger gostage.Logger) error {
 			logger.Info("Starting workflow: %s", w.Name)
 
-			clusterName, err := store.Get[string](w.Store, "turingpi.targetCluster")
+			clusterName, err := kvstore.Get[string](w.Store, keys.TargetCluster)
 			if err != nil {
 				return fmt.Errorf("failed to get target cluster: %w", err)
 			}
@@ -134,8 +140,9 @@ func New(opts ...Option) (*TuringPiProvider, error) {
 			w.Store.Put("workflow.cache.dir", absLocalCacheDir)
 			w.Store.Put("workflow.tmp.dir", absTmpDir)
 
-			// Store the provider in the workflow store
+			// Store the provider and the node state in the workflow store
 			w.Store.Put(keys.ToolsProvider, localProvider)
+			w.Store.Put(keys.StateManager, provider.stateManagers[clusterName])
 
 			// Skip container creation for Linux systems
 			// We only create containers when we're on non-Linux systems or when Docker is forced
@@ -273,11 +280,15 @@ func (t *TuringPiProvider) initializeToolProviders() error {
 			return fmt.Errorf("cluster %s has no BMC username", cluster.Name)
 		}
 
-		if cluster.BMC.Password == "" {
-			return fmt.Errorf("cluster %s has no BMC password", cluster.Name)
+		if cluster.BMC.Password == "" && cluster.BMC.KeyFile == "" {
+			return fmt.Errorf("cluster %s has no BMC password or key file", cluster.Name)
 		}
 		// Create BMC executor for this cluster
-		bmcExecutor := bmc.NewSSHExecutor(cluster.BMC.IP, 22, cluster.BMC.Username, cluster.BMC.Password)
+		bmcExecutor := bmc.NewSSHExecutor(cluster.BMC.IP, 22, cluster.BMC.Username, cluster.BMC.Password).
+			WithHostKeyPolicy(bmc.HostKeyPolicy(cluster.BMC.HostKeyPolicy), cluster.BMC.KnownHostsFile)
+		if cluster.BMC.KeyFile != "" {
+			bmcExecutor.WithPrivateKeyFile(cluster.BMC.KeyFile, cluster.BMC.KeyPassphrase)
+		}
 
 		// Determine cache directory - cluster override or global
 		cacheDir := globalCacheDir
@@ -319,6 +330,14 @@ func (t *TuringPiProvider) initializeToolProviders() error {
 		// Store the tool provider
 		t.toolProviders[cluster.Name] = toolProvider
 
+		// Node state is kept next to the cache, one file per cluster as node
+		// IDs repeat across clusters
+		stateManager, err := state.NewFileStateManager(filepath.Join(cacheDir, "state", cluster.Name+".json"))
+		if err != nil {
+			return fmt.Errorf("failed to create state manager for cluster %s: %w", cluster.Name, err)
+		}
+		t.stateManagers[cluster.Name] = stateManager
+
 		// Add to store for later access in workflows
 		clusterPrefix := fmt.Sprintf("turingpi.cluster.%d", i+1)
 		t.configStore.Put(fmt.Sprintf("%s.toolProvider", clusterPrefix), toolProvider)
@@ -381,8 +400,8 @@ func (t *TuringPiProvider) Execute(ctx context.Context, workflow *gostage.Workfl
 	}
 
 	// Store the active cluster for easy access by the middleware
-	workflow.Store.Put("turingpi.targetCluster", clusterName)
-	workflow.Store.Put("turingpi.clusterIndex", clusterIndex)
+	workflow.Store.Put(keys.TargetCluster, clusterName)
+	workflow.Store.Put(keys.ClusterIndex, clusterIndex)
 	workflow.Store.Put(keys.CurrentNodeID, nodeID)
 
 	// Add cluster details to the store
@@ -442,9 +461,22 @@ func (t *TuringPiProvider) GetToolProvider(clusterName string) *tools.TuringPiTo
 
 // GetClusterNodes returns the list of node IDs in the targeted cluster
 func GetClusterNodes(ctx *gostage.ActionContext) ([]int, error) {
-	nodeIDs, err := store.Get[[]int](ctx.Store(), "turingpi.clusterNodes")
+	nodeIDs, err := kvstore.Get[[]int](ctx.Store(), "turingpi.clusterNodes")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster nodes: %w", err)
 	}
 	return nodeIDs, nil
 }
+
+// CheckFirmwareCompliance reads the firmware version of the BMC of every
+// configured cluster and reports, keyed by cluster name, which ones run
+// expectedVersion. See bmc.CheckFirmwareCompliance.
+func (t *TuringPiProvider) CheckFirmwareCompliance(ctx context.Context, expectedVersion string) (map[string]bmc.ComplianceResult, error) {
+	bmcs := make(map[string]bmc.InfoReader, len(t.toolProviders))
+	for clusterName, provider := range t.toolProviders {
+		if bmcTool := provider.GetBMCTool(); bmcTool != nil {
+			bmcs[clusterName] = bmcTool
+		}
+	}
+	return bmc.CheckFirmwareCompliance(ctx, bmcs, expectedVersion)
+}
@@ -93,11 +93,46 @@ func (t *OperationsToolImpl) MapPartitions(ctx context.Context, imgPath string)
 	return t.filesystemOps.MapPartitions(ctx, imgPath)
 }
 
+// MapAllPartitions maps all partitions in a disk image, classified by role
+func (t *OperationsToolImpl) MapAllPartitions(ctx context.Context, imgPath string) ([]operations.MappedPartition, error) {
+	return t.filesystemOps.MapAllPartitions(ctx, imgPath)
+}
+
 // UnmapPartitions unmaps partitions in a disk image
 func (t *OperationsToolImpl) UnmapPartitions(ctx context.Context, imgPath string) error {
 	return t.filesystemOps.UnmapPartitions(ctx, imgPath)
 }
 
+// SetupLUKS encrypts a device with LUKS and opens it, returning the mapped device
+func (t *OperationsToolImpl) SetupLUKS(ctx context.Context, device, passphrase string) (string, error) {
+	return t.filesystemOps.SetupLUKS(ctx, device, passphrase)
+}
+
+// CloseLUKS closes a LUKS device opened by SetupLUKS
+func (t *OperationsToolImpl) CloseLUKS(ctx context.Context, mappedDevice string) error {
+	return t.filesystemOps.CloseLUKS(ctx, mappedDevice)
+}
+
+// WipeDevice overwrites a device with zeros; confirm must be true
+func (t *OperationsToolImpl) WipeDevice(ctx context.Context, device string, mode operations.WipeMode, confirm bool) error {
+	return t.filesystemOps.WipeDevice(ctx, device, mode, confirm)
+}
+
+// DiskFree returns the free and total bytes of the filesystem holding path
+func (t *OperationsToolImpl) DiskFree(ctx context.Context, path string) (int64, int64, error) {
+	return t.filesystemOps.DiskFree(ctx, path)
+}
+
+// GenerateFstab renders an fstab mounting the given entries by filesystem UUID
+func (t *OperationsToolImpl) GenerateFstab(ctx context.Context, mounts []operations.FstabEntry) (string, error) {
+	return t.filesystemOps.GenerateFstab(ctx, mounts)
+}
+
+// SystemMemory returns the available and total bytes of memory
+func (t *OperationsToolImpl) SystemMemory(ctx context.Context) (int64, int64, error) {
+	return t.filesystemOps.SystemMemory(ctx)
+}
+
 // MountFilesystem mounts a filesystem
 func (t *OperationsToolImpl) MountFilesystem(ctx context.Context, device, mountDir string) error {
 	return t.filesystemOps.Mount(ctx, device, mountDir, "", nil)
@@ -123,6 +158,11 @@ func (t *OperationsToolImpl) WriteFile(ctx context.Context, mountDir, relativePa
 	return t.filesystemOps.WriteFile(mountDir, relativePath, content, perm)
 }
 
+// ExtractTarInto extracts a tarball into the mounted image, preserving permissions and owners
+func (t *OperationsToolImpl) ExtractTarInto(ctx context.Context, mountDir, tarPath string, stripComponents int) error {
+	return t.filesystemOps.ExtractTarInto(ctx, mountDir, tarPath, stripComponents)
+}
+
 // CopyFile copies a file to the mounted image
 func (t *OperationsToolImpl) CopyFile(ctx context.Context, mountDir, sourcePath, destPath string) error {
 	return t.filesystemOps.CopyFile(ctx, mountDir, sourcePath, destPath)
@@ -148,6 +188,31 @@ func (t *OperationsToolImpl) ValidateImage(ctx context.Context, imagePath string
 	return t.imageOps.ValidateImage(ctx, imagePath)
 }
 
+// ConvertPartitionTable converts the partition table of an image between MBR and GPT
+func (t *OperationsToolImpl) ConvertPartitionTable(ctx context.Context, imgPath string, to operations.TableType) error {
+	return t.imageOps.ConvertPartitionTable(ctx, imgPath, to)
+}
+
+// SyncImage incrementally copies an image into the cache
+func (t *OperationsToolImpl) SyncImage(ctx context.Context, src, dstInCache string) error {
+	return t.imageOps.SyncImage(ctx, src, dstInCache)
+}
+
+// BootTest boots an image under qemu and reports whether it reached its login prompt
+func (t *OperationsToolImpl) BootTest(ctx context.Context, imgPath string, opts operations.QEMUBootOptions) (bool, error) {
+	return t.imageOps.BootTest(ctx, imgPath, opts)
+}
+
+// DetectArchitecture returns the architecture an image is built for, such as arm64
+func (t *OperationsToolImpl) DetectArchitecture(ctx context.Context, imgPath string) (string, error) {
+	return t.imageOps.DetectArchitecture(ctx, imgPath)
+}
+
+// ModifyInitramfs unpacks an initramfs, calls fn to modify its files and repacks it in its original format
+func (t *OperationsToolImpl) ModifyInitramfs(ctx context.Context, initrdPath string, fn func(extractDir string) error) error {
+	return t.imageOps.ModifyInitramfs(ctx, initrdPath, fn)
+}
+
 // ExtractBootFiles extracts kernel and initrd files from a mounted boot partition
 func (t *OperationsToolImpl) ExtractBootFiles(ctx context.Context, bootMountPoint, outputDir string) (string, string, error) {
 	return t.imageOps.ExtractBootFiles(ctx, bootMountPoint, outputDir)
@@ -238,6 +303,21 @@ func (t *OperationsToolImpl) ChangePermissions(ctx context.Context, mountDir, pa
 	return t.filesystemOps.ChangePermissions(mountDir, path, perm)
 }
 
+// ChangeOwner changes the owner of a file or directory, resolving names in the image
+func (t *OperationsToolImpl) ChangeOwner(ctx context.Context, mountDir, path string, owner operations.FileOwner) error {
+	return t.filesystemOps.ChangeOwner(mountDir, path, owner)
+}
+
+// WriteFileAs writes a file to the mounted image owned by the given owner
+func (t *OperationsToolImpl) WriteFileAs(ctx context.Context, mountDir, relativePath string, content []byte, perm fs.FileMode, owner operations.FileOwner) error {
+	return t.filesystemOps.WriteFileAs(mountDir, relativePath, content, perm, owner)
+}
+
+// MakeDirectoryAs creates a directory owned by the given owner
+func (t *OperationsToolImpl) MakeDirectoryAs(ctx context.Context, mountDir, path string, perm fs.FileMode, owner operations.FileOwner) error {
+	return t.filesystemOps.MakeDirectoryAs(mountDir, path, perm, owner)
+}
+
 // ListFiles lists files at a given location with detailed information
 func (t *OperationsToolImpl) ListFiles(ctx context.Context, dir string) ([]operations.FileInfo, error) {
 	return t.filesystemOps.ListFiles(ctx, dir)
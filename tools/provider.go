@@ -40,6 +40,26 @@ func (a *BMCToolAdapter) Reset(ctx context.Context, nodeID int) error {
 	return a.bmc.Reset(ctx, nodeID)
 }
 
+// HardReset power-cycles a node, confirming it went off then on again
+func (a *BMCToolAdapter) HardReset(ctx context.Context, nodeID int) error {
+	return a.bmc.HardReset(ctx, nodeID)
+}
+
+// SetBootOrder makes a node boot from the given devices, in order, from now on
+func (a *BMCToolAdapter) SetBootOrder(ctx context.Context, nodeID int, order []bmc.BootDevice) error {
+	return a.bmc.SetBootOrder(ctx, nodeID, order)
+}
+
+// NetBoot power-cycles a node and boots it over the network once
+func (a *BMCToolAdapter) NetBoot(ctx context.Context, nodeID int) error {
+	return a.bmc.NetBoot(ctx, nodeID)
+}
+
+// IsNodeReady probes whether a node is powered on, reachable and accepts SSH
+func (a *BMCToolAdapter) IsNodeReady(ctx context.Context, nodeID int, sshConfig bmc.SSHConfig) (bmc.Readiness, error) {
+	return a.bmc.IsNodeReady(ctx, nodeID, sshConfig)
+}
+
 // GetInfo retrieves information about the BMC
 func (a *BMCToolAdapter) GetInfo(ctx context.Context) (*bmc.BMCInfo, error) {
 	return a.bmc.GetInfo(ctx)
@@ -60,6 +80,11 @@ func (a *BMCToolAdapter) ExecuteCommand(ctx context.Context, command string) (st
 	return a.bmc.ExecuteCommand(ctx, command)
 }
 
+// ExecuteCommandStatus executes a BMC-specific command and returns its exit code
+func (a *BMCToolAdapter) ExecuteCommandStatus(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error) {
+	return a.bmc.ExecuteCommandStatus(ctx, command)
+}
+
 // GetNodeUSBMode gets the USB mode for a specific node
 func (a *BMCToolAdapter) GetNodeUSBMode(ctx context.Context, nodeID int) (string, error) {
 	// Not implemented in the base BMC, return a placeholder
@@ -221,10 +246,12 @@ func NewTuringPiToolProvider(config *TuringPiToolConfig) (*TuringPiToolProvider,
 	// Initialize remote cache if remote config is provided
 	if config.RemoteCache != nil && config.RemoteCache.Host != "" {
 		sshConfig := cache.SSHConfig{
-			Host:     config.RemoteCache.Host,
-			Port:     config.RemoteCache.Port,
-			User:     config.RemoteCache.User,
-			Password: config.RemoteCache.Password,
+			Host:           config.RemoteCache.Host,
+			Port:           config.RemoteCache.Port,
+			User:           config.RemoteCache.User,
+			Password:       config.RemoteCache.Password,
+			KnownHostsFile: config.RemoteCache.KnownHostsFile,
+			HostKeyPolicy:  config.RemoteCache.HostKeyPolicy,
 		}
 
 		sshCache, err := cache.NewSSHCache(sshConfig, config.RemoteCache.RemotePath)
@@ -380,6 +407,12 @@ type RemoteCacheConfig struct {
 
 	// Port is the SSH port (default: 22)
 	Port int
+
+	// KnownHostsFile holds the trusted host keys, ~/.ssh/known_hosts when empty
+	KnownHostsFile string
+
+	// HostKeyPolicy controls host key verification, bmc.HostKeyVerify when empty
+	HostKeyPolicy bmc.HostKeyPolicy
 }
 
 // TuringPiToolConfig holds configuration for the TuringPiToolProvider
@@ -529,10 +562,12 @@ func NewTuringPiToolProviderForTesting(config *TuringPiToolConfig, skipChecks bo
 	// Initialize remote cache if remote config is provided
 	if config.RemoteCache != nil && config.RemoteCache.Host != "" && !skipChecks {
 		sshConfig := cache.SSHConfig{
-			Host:     config.RemoteCache.Host,
-			Port:     config.RemoteCache.Port,
-			User:     config.RemoteCache.User,
-			Password: config.RemoteCache.Password,
+			Host:           config.RemoteCache.Host,
+			Port:           config.RemoteCache.Port,
+			User:           config.RemoteCache.User,
+			Password:       config.RemoteCache.Password,
+			KnownHostsFile: config.RemoteCache.KnownHostsFile,
+			HostKeyPolicy:  config.RemoteCache.HostKeyPolicy,
 		}
 
 		sshCache, err := cache.NewSSHCache(sshConfig, config.RemoteCache.RemotePath)
@@ -21,6 +21,14 @@ type BMCTool interface {
 	PowerOff(ctx context.Context, nodeID int) error
 	// Reset performs a hard reset on a specific node
 	Reset(ctx context.Context, nodeID int) error
+	// HardReset power-cycles a node, confirming it went off then on again
+	HardReset(ctx context.Context, nodeID int) error
+	// SetBootOrder makes a node boot from the given devices, in order, from now on
+	SetBootOrder(ctx context.Context, nodeID int, order []bmc.BootDevice) error
+	// NetBoot power-cycles a node and boots it over the network once
+	NetBoot(ctx context.Context, nodeID int) error
+	// IsNodeReady probes whether a node is powered on, reachable and accepts SSH
+	IsNodeReady(ctx context.Context, nodeID int, sshConfig bmc.SSHConfig) (bmc.Readiness, error)
 	// GetInfo retrieves information about the BMC
 	GetInfo(ctx context.Context) (*bmc.BMCInfo, error)
 	// Reboot reboots the BMC chip
@@ -29,6 +37,8 @@ type BMCTool interface {
 	UpdateFirmware(ctx context.Context, firmwarePath string) error
 	// ExecuteCommand executes a BMC-specific command
 	ExecuteCommand(ctx context.Context, command string) (stdout string, stderr string, err error)
+	// ExecuteCommandStatus executes a BMC-specific command and returns its exit code
+	ExecuteCommandStatus(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error)
 	// GetNodeUSBMode gets the USB mode for a specific node
 	GetNodeUSBMode(ctx context.Context, nodeID int) (string, error)
 	// SetNodeUSBMode sets the USB mode for a specific node
@@ -93,8 +103,22 @@ type ContainerTool interface {
 type OperationsTool interface {
 	// MapPartitions maps partitions in a disk image
 	MapPartitions(ctx context.Context, imgPath string) (string, error)
+	// MapAllPartitions maps all partitions in a disk image, classified by role
+	MapAllPartitions(ctx context.Context, imgPath string) ([]operations.MappedPartition, error)
 	// UnmapPartitions unmaps partitions in a disk image
 	UnmapPartitions(ctx context.Context, imgPath string) error
+	// SetupLUKS encrypts a device with LUKS and opens it, returning the mapped device
+	SetupLUKS(ctx context.Context, device, passphrase string) (string, error)
+	// CloseLUKS closes a LUKS device opened by SetupLUKS
+	CloseLUKS(ctx context.Context, mappedDevice string) error
+	// WipeDevice overwrites a device with zeros; confirm must be true
+	WipeDevice(ctx context.Context, device string, mode operations.WipeMode, confirm bool) error
+	// DiskFree returns the free and total bytes of the filesystem holding path
+	DiskFree(ctx context.Context, path string) (freeBytes, totalBytes int64, err error)
+	// GenerateFstab renders an fstab mounting the given entries by filesystem UUID
+	GenerateFstab(ctx context.Context, mounts []operations.FstabEntry) (string, error)
+	// SystemMemory returns the available and total bytes of memory
+	SystemMemory(ctx context.Context) (freeBytes, totalBytes int64, err error)
 	// MountFilesystem mounts a filesystem
 	MountFilesystem(ctx context.Context, device, mountDir string) error
 	// UnmountFilesystem unmounts a filesystem
@@ -105,6 +129,8 @@ type OperationsTool interface {
 	CompressXZ(ctx context.Context, sourceImg, targetXZ string) error
 	// WriteFile writes content to a file in the mounted image
 	WriteFile(ctx context.Context, mountDir, relativePath string, content []byte, perm fs.FileMode) error
+	// ExtractTarInto extracts a tarball into the mounted image, preserving permissions and owners
+	ExtractTarInto(ctx context.Context, mountDir, tarPath string, stripComponents int) error
 	// CopyFile copies a file to the mounted image
 	CopyFile(ctx context.Context, mountDir, sourcePath, destPath string) error
 	// ReadFile reads a file from the mounted image
@@ -115,6 +141,16 @@ type OperationsTool interface {
 	ResizePartition(ctx context.Context, device string) error
 	// ValidateImage validates that an image file exists and is a valid disk image
 	ValidateImage(ctx context.Context, imagePath string) error
+	// ConvertPartitionTable converts the partition table of an image between MBR and GPT
+	ConvertPartitionTable(ctx context.Context, imgPath string, to operations.TableType) error
+	// SyncImage incrementally copies an image into the cache
+	SyncImage(ctx context.Context, src, dstInCache string) error
+	// BootTest boots an image under qemu and reports whether it reached its login prompt
+	BootTest(ctx context.Context, imgPath string, opts operations.QEMUBootOptions) (bool, error)
+	// DetectArchitecture returns the architecture an image is built for, such as arm64
+	DetectArchitecture(ctx context.Context, imgPath string) (string, error)
+	// ModifyInitramfs unpacks an initramfs, calls fn to modify its files and repacks it in its original format
+	ModifyInitramfs(ctx context.Context, initrdPath string, fn func(extractDir string) error) error
 	// ExtractBootFiles extracts kernel and initrd files from a mounted boot partition
 	ExtractBootFiles(ctx context.Context, bootMountPoint, outputDir string) (string, string, error)
 	// ApplyDTBOverlay applies a device tree overlay to a mounted boot partition
@@ -151,6 +187,12 @@ type OperationsTool interface {
 	MakeDirectory(ctx context.Context, mountDir, path string, perm fs.FileMode) error
 	// ChangePermissions changes the permissions of a file or directory
 	ChangePermissions(ctx context.Context, mountDir, path string, perm fs.FileMode) error
+	// ChangeOwner changes the owner of a file or directory, resolving names in the image
+	ChangeOwner(ctx context.Context, mountDir, path string, owner operations.FileOwner) error
+	// WriteFileAs writes a file to the mounted image owned by the given owner
+	WriteFileAs(ctx context.Context, mountDir, relativePath string, content []byte, perm fs.FileMode, owner operations.FileOwner) error
+	// MakeDirectoryAs creates a directory owned by the given owner
+	MakeDirectoryAs(ctx context.Context, mountDir, path string, perm fs.FileMode, owner operations.FileOwner) error
 	// ListFiles lists files at a given location with detailed information
 	ListFiles(ctx context.Context, dir string) ([]operations.FileInfo, error)
 	// ListFilesBasic lists files at a given location and returns just the filenames
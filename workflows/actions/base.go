@@ -10,6 +10,7 @@ import (
 	"github.com/davidroman0O/turingpi/cache"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // TuringPiAction is the base action type for all TuringPi-specific actions
@@ -78,7 +79,7 @@ func (a *TuringPiAction) Execute(ctx *gostage.ActionContext) error {
 	}
 
 	// Check if we have a container ID in the workflow store
-	containerID, err := store.Get[string](ctx.Store(), "workflow.container.id")
+	containerID, err := kvstore.Get[string](ctx.Store(), "workflow.container.id")
 
 	// If we have a container ID, use Docker execution
 	if err == nil && containerID != "" {
@@ -6,11 +6,11 @@ import (
 	"fmt"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/bmc"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // PowerOnNodeAction turns on a node
@@ -41,7 +41,7 @@ func (a *PowerOnNodeAction) ExecuteDocker(ctx *gostage.ActionContext, tools tool
 // executeImpl is the shared implementation
 func (a *PowerOnNodeAction) executeImpl(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
 	// Get current node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return err
 	}
@@ -94,7 +94,7 @@ func (a *PowerOffNodeAction) ExecuteDocker(ctx *gostage.ActionContext, tools too
 // executeImpl is the shared implementation
 func (a *PowerOffNodeAction) executeImpl(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
 	// Get current node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return err
 	}
@@ -143,7 +143,7 @@ func (a *ResetNodeAction) ExecuteDocker(ctx *gostage.ActionContext, tools tools.
 // executeImpl is the shared implementation
 func (a *ResetNodeAction) executeImpl(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
 	// Get current node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return err
 	}
@@ -164,6 +164,55 @@ func (a *ResetNodeAction) executeImpl(ctx *gostage.ActionContext, tools tools.To
 	return nil
 }
 
+// HardResetNodeAction power-cycles a node for a true cold boot
+type HardResetNodeAction struct {
+	actions.PlatformActionBase
+}
+
+// NewHardResetNodeAction creates a new action to power-cycle a node
+func NewHardResetNodeAction() *HardResetNodeAction {
+	return &HardResetNodeAction{
+		PlatformActionBase: actions.NewPlatformActionBase(
+			"hard-reset-node",
+			"Power-cycles the current target node and confirms it is back on",
+		),
+	}
+}
+
+// ExecuteNative implements execution on native platforms
+func (a *HardResetNodeAction) ExecuteNative(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
+	return a.executeImpl(ctx, tools)
+}
+
+// ExecuteDocker implements execution via Docker
+func (a *HardResetNodeAction) ExecuteDocker(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
+	return a.executeImpl(ctx, tools)
+}
+
+// executeImpl is the shared implementation
+func (a *HardResetNodeAction) executeImpl(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
+	// Get current node ID from store
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	if err != nil {
+		return err
+	}
+
+	bmcTool := tools.GetBMCTool()
+	if bmcTool == nil {
+		ctx.Logger.Info("BMC tool not available")
+		ctx.Logger.Info("Skipping hard reset for node %d", nodeID)
+		return nil
+	}
+
+	ctx.Logger.Info("Power-cycling node %d", nodeID)
+	if err := bmcTool.HardReset(ctx.GoContext, nodeID); err != nil {
+		return err
+	}
+
+	ctx.Logger.Info("Node %d power-cycled and confirmed on", nodeID)
+	return nil
+}
+
 // GetPowerStatusAction gets the power status of a node
 type GetPowerStatusAction struct {
 	actions.PlatformActionBase
@@ -192,7 +241,7 @@ func (a *GetPowerStatusAction) ExecuteDocker(ctx *gostage.ActionContext, tools t
 // executeImpl is the shared implementation
 func (a *GetPowerStatusAction) executeImpl(ctx *gostage.ActionContext, tools tools.ToolProvider) error {
 	// Get current node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return err
 	}
@@ -3,9 +3,9 @@ package common
 
 import (
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // SetCurrentNodeAction sets the current node ID in the workflow
@@ -50,7 +50,7 @@ func NewAddTargetNodeAction(nodeID int) *AddTargetNodeAction {
 // Execute implements the Action interface
 func (a *AddTargetNodeAction) Execute(ctx *gostage.ActionContext) error {
 	// Get current list or create new one
-	targetNodes, err := store.GetOrDefault[[]int](ctx.Store(), keys.TargetNodes, []int{})
+	targetNodes, err := kvstore.GetSliceOrEmpty[int](ctx.Store(), keys.TargetNodes)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+)
+
+func TestWaitActionCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	action := NewWaitAction(60)
+	actionCtx := &gostage.ActionContext{
+		GoContext: ctx,
+		Logger:    gostage.NewDefaultLogger(),
+	}
+
+	start := time.Now()
+	err := action.Execute(actionCtx)
+	// A cancelled wait ends the action without failing it
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute() returned after %v, want a prompt return", elapsed)
+	}
+}
@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	tperrors "github.com/davidroman0O/turingpi/errors"
+)
+
+// RetryAction runs a wrapped action again when it fails with an error worth
+// retrying, waiting between attempts
+type RetryAction struct {
+	gostage.Action
+	maxAttempts int
+	delay       time.Duration
+	retryIf     func(error) bool
+}
+
+// AttemptError is returned by RetryAction when its action failed on an
+// attempt after the first one
+type AttemptError struct {
+	// Action is the name of the retried action
+	Action string
+	// Attempt is the attempt the action last failed on
+	Attempt int
+	// Exhausted is set when every attempt failed, rather than the last one
+	// failing with an error not worth retrying
+	Exhausted bool
+	// Err is the error of the last attempt
+	Err error
+}
+
+// Error implements the error interface
+func (e *AttemptError) Error() string {
+	if e.Exhausted {
+		return fmt.Sprintf("action %s failed after %d attempts: %v", e.Action, e.Attempt, e.Err)
+	}
+	return fmt.Sprintf("action %s failed on attempt %d: %v", e.Action, e.Attempt, e.Err)
+}
+
+// Unwrap exposes the error of the last attempt to errors.Is and errors.As
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// FailedAttempt returns the attempt the action last failed on, it lets the
+// workflow engine report it
+func (e *AttemptError) FailedAttempt() int {
+	return e.Attempt
+}
+
+// NewRetryAction wraps an action so it is attempted up to maxAttempts times
+// with delay between attempts. By default only errors reported retryable by
+// errors.IsRetryable are retried.
+func NewRetryAction(action gostage.Action, maxAttempts int, delay time.Duration) *RetryAction {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &RetryAction{
+		Action:      action,
+		maxAttempts: maxAttempts,
+		delay:       delay,
+		retryIf:     tperrors.IsRetryable,
+	}
+}
+
+// WithRetryIf replaces the function deciding whether a failure is retried
+func (a *RetryAction) WithRetryIf(retryIf func(error) bool) *RetryAction {
+	a.retryIf = retryIf
+	return a
+}
+
+// Execute implements the Action interface
+func (a *RetryAction) Execute(ctx *gostage.ActionContext) error {
+	// Expose the real action to code that type-asserts ctx.Action
+	ctx.Action = a.Action
+
+	var err error
+	for attempt := 1; attempt <= a.maxAttempts; attempt++ {
+		err = a.Action.Execute(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if !a.retryIf(err) {
+			if attempt == 1 {
+				return err
+			}
+			return &AttemptError{Action: a.Name(), Attempt: attempt, Err: err}
+		}
+		if attempt == a.maxAttempts {
+			break
+		}
+
+		ctx.Logger.Warn("Action %s attempt %d of %d failed, retrying in %v: %v",
+			a.Name(), attempt, a.maxAttempts, a.delay, err)
+		if sleepErr := Sleep(ctx.GoContext, a.delay); sleepErr != nil {
+			return sleepErr
+		}
+	}
+
+	return &AttemptError{Action: a.Name(), Attempt: a.maxAttempts, Exhausted: true, Err: err}
+}
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	tperrors "github.com/davidroman0O/turingpi/errors"
+)
+
+// failingAction fails with err on every attempt until succeedAfter attempts have run
+type failingAction struct {
+	gostage.BaseAction
+	err          error
+	succeedAfter int
+	attempts     int
+}
+
+func (a *failingAction) Execute(ctx *gostage.ActionContext) error {
+	a.attempts++
+	if a.succeedAfter > 0 && a.attempts >= a.succeedAfter {
+		return nil
+	}
+	return a.err
+}
+
+func newFailingAction(err error, succeedAfter int) *failingAction {
+	return &failingAction{
+		BaseAction:   gostage.NewBaseAction("flaky", "Fails on purpose"),
+		err:          err,
+		succeedAfter: succeedAfter,
+	}
+}
+
+func newTestActionContext(ctx context.Context) *gostage.ActionContext {
+	return &gostage.ActionContext{
+		GoContext: ctx,
+		Logger:    gostage.NewDefaultLogger(),
+	}
+}
+
+func TestRetryAction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Permanent error stops immediately", func(t *testing.T) {
+		errNoNode := tperrors.Permanent(errors.New("invalid node ID: 7"))
+		action := newFailingAction(errNoNode, 0)
+
+		err := NewRetryAction(action, 5, time.Millisecond).Execute(newTestActionContext(ctx))
+		if !errors.Is(err, errNoNode) {
+			t.Fatalf("Execute() error = %v, want the permanent error", err)
+		}
+		if action.attempts != 1 {
+			t.Errorf("Action ran %d times, want 1", action.attempts)
+		}
+	})
+
+	t.Run("Retryable error is retried up to the max", func(t *testing.T) {
+		errBusy := tperrors.Retryable(errors.New("BMC busy"))
+		action := newFailingAction(errBusy, 0)
+
+		err := NewRetryAction(action, 3, time.Millisecond).Execute(newTestActionContext(ctx))
+		if !errors.Is(err, errBusy) {
+			t.Fatalf("Execute() error = %v, want the retryable error", err)
+		}
+		if action.attempts != 3 {
+			t.Errorf("Action ran %d times, want 3", action.attempts)
+		}
+		var attemptErr *AttemptError
+		if !errors.As(err, &attemptErr) || attemptErr.FailedAttempt() != 3 || !attemptErr.Exhausted {
+			t.Errorf("Execute() error = %#v, want an exhausted AttemptError on attempt 3", err)
+		}
+	})
+
+	t.Run("Transient error codes are retried", func(t *testing.T) {
+		action := newFailingAction(tperrors.New(tperrors.ErrBMCTimeout, "no answer"), 2)
+
+		if err := NewRetryAction(action, 3, time.Millisecond).Execute(newTestActionContext(ctx)); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if action.attempts != 2 {
+			t.Errorf("Action ran %d times, want 2", action.attempts)
+		}
+	})
+
+	t.Run("Unclassified errors are not retried by default", func(t *testing.T) {
+		action := newFailingAction(errors.New("bad configuration"), 0)
+
+		if err := NewRetryAction(action, 3, time.Millisecond).Execute(newTestActionContext(ctx)); err == nil {
+			t.Fatal("Execute() expected an error")
+		}
+		if action.attempts != 1 {
+			t.Errorf("Action ran %d times, want 1", action.attempts)
+		}
+	})
+
+	t.Run("Custom RetryIf", func(t *testing.T) {
+		action := newFailingAction(errors.New("flaky"), 0)
+		retry := NewRetryAction(action, 4, time.Millisecond).WithRetryIf(func(error) bool { return true })
+
+		if err := retry.Execute(newTestActionContext(ctx)); err == nil {
+			t.Fatal("Execute() expected an error")
+		}
+		if action.attempts != 4 {
+			t.Errorf("Action ran %d times, want 4", action.attempts)
+		}
+	})
+
+	t.Run("Cancellation during the delay", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(20*time.Millisecond, cancel)
+		action := newFailingAction(tperrors.Retryable(errors.New("BMC busy")), 0)
+
+		start := time.Now()
+		err := NewRetryAction(action, 3, time.Minute).Execute(newTestActionContext(cancelCtx))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Execute() error = %v, want context.Canceled", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Execute() returned after %v, want a prompt return", elapsed)
+		}
+	})
+}
+
+func TestIsRetryableMarkers(t *testing.T) {
+	base := tperrors.New(tperrors.ErrTimeout, "timed out")
+
+	if !tperrors.IsRetryable(base) {
+		t.Error("Timeout error should be retryable")
+	}
+	if tperrors.IsRetryable(tperrors.Permanent(base)) {
+		t.Error("Permanent marker should override a transient code")
+	}
+	if !tperrors.IsRetryable(tperrors.Retryable(errors.New("flaky"))) {
+		t.Error("Retryable marker should make any error retryable")
+	}
+	if !tperrors.IsPermanent(tperrors.Retryable(tperrors.Permanent(base))) {
+		t.Error("IsPermanent should find a wrapped permanent marker")
+	}
+}
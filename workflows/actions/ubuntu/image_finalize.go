@@ -7,11 +7,11 @@ import (
 	"strings"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/operations"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // getExecutor is a helper to get the command executor from the tools provider
@@ -59,13 +59,13 @@ func printFileContents(ctx *gostage.ActionContext, toolsProvider tools.ToolProvi
 // executeImpl is the shared implementation
 func (a *ImageFinalizeAction) executeImpl(ctx *gostage.ActionContext, toolsProvider tools.ToolProvider) error {
 	// Get node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
 
 	// Get the decompressed image file path
-	ubuntuImageDecompressedFile, err := store.Get[string](ctx.Store(), "ubuntu.image.decompressed.file")
+	ubuntuImageDecompressedFile, err := kvstore.Get[string](ctx.Store(), "ubuntu.image.decompressed.file")
 	if err != nil {
 		return fmt.Errorf("failed to get ubuntu image decompressed path: %w", err)
 	}
@@ -74,9 +74,9 @@ func (a *ImageFinalizeAction) executeImpl(ctx *gostage.ActionContext, toolsProvi
 	ctx.Logger.Info("Decompressed image file: %s", ubuntuImageDecompressedFile)
 
 	// Network configuration parameters (directly from store)
-	ipCIDR, _ := store.GetOrDefault[string](ctx.Store(), "IPCIDR", "")
-	hostname, _ := store.GetOrDefault[string](ctx.Store(), "Hostname", "")
-	gateway, _ := store.GetOrDefault[string](ctx.Store(), "Gateway", "")
+	ipCIDR, _ := kvstore.GetOrDefault[string](ctx.Store(), "IPCIDR", "")
+	hostname, _ := kvstore.GetOrDefault[string](ctx.Store(), "Hostname", "")
+	gateway, _ := kvstore.GetOrDefault[string](ctx.Store(), "Gateway", "")
 
 	// Critical validation: Force the IP to what you actually want
 	if ipCIDR != "192.168.1.101/24" {
@@ -97,12 +97,12 @@ func (a *ImageFinalizeAction) executeImpl(ctx *gostage.ActionContext, toolsProvi
 
 	// Get DNS servers (either as string or directly as slice)
 	var dnsServers []string
-	dnsSlice, err := store.GetOrDefault[[]string](ctx.Store(), "DNSServers", []string{})
+	dnsSlice, err := kvstore.GetOrDefault[[]string](ctx.Store(), "DNSServers", []string{})
 	if err == nil && len(dnsSlice) > 0 {
 		dnsServers = dnsSlice
 	} else {
 		// Try as string
-		dnsStr, _ := store.GetOrDefault[string](ctx.Store(), "DNSServers", "")
+		dnsStr, _ := kvstore.GetOrDefault[string](ctx.Store(), "DNSServers", "")
 		if dnsStr != "" {
 			dnsServers = parseDNSServers(dnsStr)
 		}
@@ -336,7 +336,7 @@ echo "========================== CONFIGURATION COMPLETE ========================
 	}
 
 	// Get the workflow temp directory - we'll need this for path mapping to host
-	tempDir, err := store.Get[string](ctx.Store(), "workflow.tmp.dir")
+	tempDir, err := kvstore.Get[string](ctx.Store(), "workflow.tmp.dir")
 	if err != nil {
 		ctx.Logger.Warn("Failed to get workflow temp directory: %v", err)
 	} else {
@@ -7,11 +7,11 @@ import (
 	"time"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/bmc"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // ImageFlashAction flashes the image to the node using the BMC
@@ -42,13 +42,13 @@ func (a *ImageFlashAction) ExecuteDocker(ctx *gostage.ActionContext, tools tools
 // executeImpl is the shared implementation
 func (a *ImageFlashAction) executeImpl(ctx *gostage.ActionContext, toolsProvider tools.ToolProvider) error {
 	// Get node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
 
 	// Get the remote image path
-	remoteImagePath, err := store.Get[string](ctx.Store(), "RemoteImagePath")
+	remoteImagePath, err := kvstore.Get[string](ctx.Store(), "RemoteImagePath")
 	if err != nil || remoteImagePath == "" {
 		return fmt.Errorf("remote image path not found or empty: %w", err)
 	}
@@ -75,7 +75,9 @@ func (a *ImageFlashAction) executeImpl(ctx *gostage.ActionContext, toolsProvider
 
 		// Wait for node to power off
 		ctx.Logger.Info("Waiting for node %d to power off...", nodeID)
-		time.Sleep(5 * time.Second)
+		if err := actions.Sleep(ctx.GoContext, 5*time.Second); err != nil {
+			return err
+		}
 
 		// Verify node is off
 		powerStatus, err = bmcTool.GetPowerStatus(context.Background(), nodeID)
@@ -95,7 +97,7 @@ func (a *ImageFlashAction) executeImpl(ctx *gostage.ActionContext, toolsProvider
 	ctx.Logger.Info("Image path: %s", remoteImagePath)
 
 	// Get the configured IP address for debugging
-	ipCIDR, err := store.GetOrDefault[string](ctx.Store(), "IPCIDR", "")
+	ipCIDR, err := kvstore.GetOrDefault[string](ctx.Store(), "IPCIDR", "")
 	if err == nil && ipCIDR != "" {
 		ctx.Logger.Info("Configured IP CIDR for this node: %s", ipCIDR)
 	} else {
@@ -104,13 +106,13 @@ func (a *ImageFlashAction) executeImpl(ctx *gostage.ActionContext, toolsProvider
 
 	// Explicitly log all relevant store keys
 	ctx.Logger.Info("Critical store values for network configuration:")
-	if val, err := store.GetOrDefault[string](ctx.Store(), "IPCIDR", ""); err == nil {
+	if val, err := kvstore.GetOrDefault[string](ctx.Store(), "IPCIDR", ""); err == nil {
 		ctx.Logger.Info("  IPCIDR: %s", val)
 	}
-	if val, err := store.GetOrDefault[string](ctx.Store(), "Hostname", ""); err == nil {
+	if val, err := kvstore.GetOrDefault[string](ctx.Store(), "Hostname", ""); err == nil {
 		ctx.Logger.Info("  Hostname: %s", val)
 	}
-	if val, err := store.GetOrDefault[string](ctx.Store(), "Gateway", ""); err == nil {
+	if val, err := kvstore.GetOrDefault[string](ctx.Store(), "Gateway", ""); err == nil {
 		ctx.Logger.Info("  Gateway: %s", val)
 	}
 
@@ -10,11 +10,11 @@ import (
 	"strings"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/platform"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // ImagePrepareAction prepares a Ubuntu image with customized network settings
@@ -45,17 +45,17 @@ func (a *ImagePrepareAction) ExecuteDocker(ctx *gostage.ActionContext, tools too
 	/////// TODO: with all the changes i didnt we might have just one implementation on this action
 
 	// Get required parameters from the store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
 
-	tempDir, err := store.Get[string](ctx.Store(), "workflow.tmp.dir")
+	tempDir, err := kvstore.Get[string](ctx.Store(), "workflow.tmp.dir")
 	if err != nil {
 		return fmt.Errorf("failed to get temp directory: %w", err)
 	}
 
-	cacheDir, err := store.Get[string](ctx.Store(), "workflow.cache.dir")
+	cacheDir, err := kvstore.Get[string](ctx.Store(), "workflow.cache.dir")
 	if err != nil {
 		return fmt.Errorf("failed to get cache directory: %w", err)
 	}
@@ -65,7 +65,7 @@ func (a *ImagePrepareAction) ExecuteDocker(ctx *gostage.ActionContext, tools too
 	ctx.Logger.Info("Cache directory: %s", cacheDir)
 
 	// Get the source image path from the store
-	sourceImagePath, err := store.Get[string](ctx.Store(), "SourceImagePath")
+	sourceImagePath, err := kvstore.Get[string](ctx.Store(), "SourceImagePath")
 	if err != nil {
 		return fmt.Errorf("failed to get source image path: %w", err)
 	}
@@ -126,7 +126,7 @@ func (a *ImagePrepareAction) ExecuteDocker(ctx *gostage.ActionContext, tools too
 		// 	ctx.Logger.Info("Target directory in container: %s", targetDir)
 		// 	ctx.Logger.Info("Target image name: %s", targetImagePath)
 
-		containerID, err := store.Get[string](ctx.Workflow.Store, "workflow.container.id")
+		containerID, err := kvstore.Get[string](ctx.Workflow.Store, "workflow.container.id")
 		if err != nil {
 			return fmt.Errorf("failed to get container ID: %w", err)
 		}
@@ -10,11 +10,11 @@ import (
 	"time"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/cache"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 const (
@@ -54,13 +54,13 @@ func (a *ImageUploadAction) ExecuteDocker(ctx *gostage.ActionContext, tools tool
 // executeImpl is the shared implementation
 func (a *ImageUploadAction) executeImpl(ctx *gostage.ActionContext, toolsProvider tools.ToolProvider) error {
 	// Get node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
 
 	// Get the compressed image path and extract just the filename
-	compressedImagePath, err := store.Get[string](ctx.Store(), "ubuntu.image.compressed.file")
+	compressedImagePath, err := kvstore.Get[string](ctx.Store(), "ubuntu.image.compressed.file")
 	if err != nil {
 		return fmt.Errorf("failed to get compressed image path: %w", err)
 	}
@@ -69,7 +69,7 @@ func (a *ImageUploadAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 
 	// We need to map from container path to host path
 	// The container's /tmp directory is mapped to the workflow temp directory
-	tempDir, err := store.Get[string](ctx.Store(), "workflow.tmp.dir")
+	tempDir, err := kvstore.Get[string](ctx.Store(), "workflow.tmp.dir")
 	if err != nil {
 		return fmt.Errorf("failed to get workflow temp directory: %w", err)
 	}
@@ -149,7 +149,9 @@ func (a *ImageUploadAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 
 			if attempt < MaxRetries {
 				ctx.Logger.Info("Retrying in %d seconds...", RetryDelay)
-				time.Sleep(RetryDelay * time.Second)
+				if err := actions.Sleep(ctx.GoContext, RetryDelay*time.Second); err != nil {
+					return err
+				}
 
 				// Clean up any partial file from the failed attempt
 				_, _, _ = bmcTool.ExecuteCommand(ctx.GoContext, fmt.Sprintf("rm -f %s", remoteXZPath))
@@ -213,7 +215,9 @@ func (a *ImageUploadAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 
 				if attempt < MaxRetries {
 					ctx.Logger.Info("Retrying in %d seconds...", RetryDelay)
-					time.Sleep(RetryDelay * time.Second)
+					if err := actions.Sleep(ctx.GoContext, RetryDelay*time.Second); err != nil {
+						return err
+					}
 
 					// Check if remote file exists but is incomplete (possible from failed upload)
 					checkStdout, _, checkErr := bmcTool.ExecuteCommand(ctx.GoContext, fmt.Sprintf("test -f %s && echo 'exists' || echo 'not_exists'", remoteCache.Location()+"/"+cacheKey+".data"))
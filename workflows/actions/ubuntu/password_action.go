@@ -8,10 +8,10 @@ import (
 	"time"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // PasswordChangeAction changes the default Ubuntu user password
@@ -42,13 +42,13 @@ func (a *PasswordChangeAction) ExecuteDocker(ctx *gostage.ActionContext, tools t
 // executeImpl is the shared implementation
 func (a *PasswordChangeAction) executeImpl(ctx *gostage.ActionContext, toolsProvider tools.ToolProvider) error {
 	// Get required parameters from the store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
 
 	// Get the node IP address - required for SSH
-	ipCIDR, err := store.Get[string](ctx.Store(), "IPCIDR")
+	ipCIDR, err := kvstore.Get[string](ctx.Store(), "IPCIDR")
 	if err != nil {
 		return fmt.Errorf("failed to get node IP address: %w", err)
 	}
@@ -60,7 +60,7 @@ func (a *PasswordChangeAction) executeImpl(ctx *gostage.ActionContext, toolsProv
 	}
 
 	// Get the new password from the store
-	newPassword, err := store.GetOrDefault[string](ctx.Store(), "NewPassword", "turingpi123!")
+	newPassword, err := kvstore.GetOrDefault[string](ctx.Store(), "NewPassword", "turingpi123!")
 	if err != nil {
 		return fmt.Errorf("failed to get new password: %w", err)
 	}
@@ -77,7 +77,7 @@ func (a *PasswordChangeAction) executeImpl(ctx *gostage.ActionContext, toolsProv
 	ctx.Logger.Info("Starting password change for user '%s' on node %d (%s)", username, nodeID, ipAddress)
 
 	// Check if boot has completed (UART monitor should have set this)
-	bootCompleted, err := store.GetOrDefault[bool](ctx.Store(), "BootCompleted", false)
+	bootCompleted, err := kvstore.GetOrDefault[bool](ctx.Store(), "BootCompleted", false)
 	if err != nil {
 		ctx.Logger.Warn("Failed to check boot status: %v", err)
 		// Continue anyway as we're explicitly waiting before this action
@@ -118,7 +118,10 @@ func (a *PasswordChangeAction) executeImpl(ctx *gostage.ActionContext, toolsProv
 			lastOutput = stdout + "\n" + stderr
 			ctx.Logger.Warn("Password change attempt %d failed: %v", attempt, err)
 			ctx.Logger.Debug("Command output: %s", lastOutput)
-			time.Sleep(timeout) // Wait before retry
+			// Wait before retry
+			if err := actions.Sleep(ctx.GoContext, timeout); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -131,7 +134,10 @@ func (a *PasswordChangeAction) executeImpl(ctx *gostage.ActionContext, toolsProv
 			lastOutput = stdout + "\n" + stderr
 			ctx.Logger.Warn("Password change attempt %d did not report success", attempt)
 			ctx.Logger.Debug("Command output: %s", lastOutput)
-			time.Sleep(timeout) // Wait before retry
+			// Wait before retry
+			if err := actions.Sleep(ctx.GoContext, timeout); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -8,11 +8,11 @@ import (
 	"time"
 
 	"github.com/davidroman0O/gostage"
-	"github.com/davidroman0O/gostage/store"
 	"github.com/davidroman0O/turingpi/bmc"
 	"github.com/davidroman0O/turingpi/keys"
 	"github.com/davidroman0O/turingpi/tools"
 	"github.com/davidroman0O/turingpi/workflows/actions"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
 )
 
 // UARTMonitorAction monitors the UART console output during boot
@@ -43,7 +43,7 @@ func (a *UARTMonitorAction) ExecuteDocker(ctx *gostage.ActionContext, tools tool
 // executeImpl is the shared implementation
 func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvider tools.ToolProvider) error {
 	// Get node ID from store
-	nodeID, err := store.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
+	nodeID, err := kvstore.GetOrDefault[int](ctx.Store(), keys.CurrentNodeID, 1)
 	if err != nil {
 		return fmt.Errorf("failed to get node ID: %w", err)
 	}
@@ -55,7 +55,7 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 	}
 
 	// Check if we need to monitor (e.g., if flash was completed)
-	flashCompleted, err := store.GetOrDefault[bool](ctx.Store(), "FlashCompleted", false)
+	flashCompleted, err := kvstore.GetOrDefault[bool](ctx.Store(), "FlashCompleted", false)
 	if err != nil {
 		return fmt.Errorf("failed to check flash completion status: %w", err)
 	}
@@ -68,7 +68,7 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 	ctx.Logger.Info("Starting UART monitoring for node %d", nodeID)
 
 	// Create a timeout context for the boot monitoring
-	monitorCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	monitorCtx, cancel := context.WithTimeout(ctx.GoContext, 5*time.Minute)
 	defer cancel()
 
 	// Track boot progress indicators
@@ -96,6 +96,11 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 	for {
 		select {
 		case <-monitorCtx.Done():
+			// The workflow was cancelled
+			if err := ctx.GoContext.Err(); err != nil {
+				return err
+			}
+
 			// Timeout reached
 			ctx.Logger.Warn("Boot monitoring timed out after %v", time.Since(startTime))
 			return fmt.Errorf("boot monitoring timed out")
@@ -106,7 +111,8 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 			output, stderr, err := bmcTool.ExecuteCommand(context.Background(), uartCmd)
 			if err != nil {
 				ctx.Logger.Warn("Error getting UART output: %v (stderr: %s)", err, stderr)
-				time.Sleep(pollInterval)
+				// A cancelled wait is reported on the next iteration
+				_ = actions.Sleep(monitorCtx, pollInterval)
 				continue
 			}
 
@@ -116,7 +122,7 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 			}
 
 			// Log any new output (avoid duplication)
-			lastOutput, _ := store.GetOrDefault[string](ctx.Store(), "LastProcessedUARTOutput", "")
+			lastOutput, _ := kvstore.GetOrDefault[string](ctx.Store(), "LastProcessedUARTOutput", "")
 			if output != lastOutput {
 				// Look for new network-related information
 				newOutput := strings.TrimPrefix(output, lastOutput)
@@ -177,7 +183,7 @@ func (a *UARTMonitorAction) executeImpl(ctx *gostage.ActionContext, toolsProvide
 			}
 
 			// Wait before polling again
-			time.Sleep(pollInterval)
+			_ = actions.Sleep(monitorCtx, pollInterval)
 		}
 
 		if bootCompleted {
@@ -0,0 +1,21 @@
+package actions
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for the given duration or until the context is done, whichever
+// comes first. It returns the context error when the wait was cut short, so
+// retry loops can stop as soon as their workflow is cancelled.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	t.Run("Waits for the full duration", func(t *testing.T) {
+		start := time.Now()
+		if err := Sleep(context.Background(), 20*time.Millisecond); err != nil {
+			t.Fatalf("Sleep() error = %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("Sleep() returned after %v, want at least 20ms", elapsed)
+		}
+	})
+
+	t.Run("Returns promptly when cancelled during the delay", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		start := time.Now()
+		err := Sleep(ctx, time.Minute)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Sleep() error = %v, want context.Canceled", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Sleep() returned after %v, want a prompt return", elapsed)
+		}
+	})
+
+	t.Run("Reports an expired deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := Sleep(ctx, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Sleep() error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
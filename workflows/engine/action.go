@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// trackedAction wraps an action while its stage runs to record its outcome
+// and apply the workflow's error policy
+type trackedAction struct {
+	gostage.Action
+
+	workflow *Workflow
+	stage    *Stage
+	report   *StageReport
+	// resumed marks actions that ran before the checkpoint being resumed from
+	resumed bool
+}
+
+// Execute runs the wrapped action and records its result
+func (a *trackedAction) Execute(ctx *gostage.ActionContext) error {
+	// Expose the real action to code that type-asserts ctx.Action
+	ctx.Action = a.Action
+
+	// Keep the stages generated by the previous action before the runner
+	// replaces them with those of this one
+	a.workflow.queueDynamicStages(ctx.Workflow)
+
+	if a.resumed {
+		a.report.skipAction(a.Action)
+		return nil
+	}
+
+	entry := a.report.startAction(a.Action)
+
+	// The context is shared by the actions of the stage, restore its logger
+	logger := ctx.Logger
+	var capture *captureLogger
+	if a.workflow.CaptureOutput() {
+		capture = newCaptureLogger(ctx.Logger)
+		ctx.Logger = capture
+	}
+	// Lines below the level are neither captured nor logged
+	if level := a.workflow.stageLogLevel(a.stage); level != LogDefault && ctx.Logger != nil {
+		ctx.Logger = &levelLogger{next: ctx.Logger, level: level}
+	}
+	// The runner inserts the actions added by this one after it returns, the
+	// marker inserted first makes them run under the same policies
+	ctx.AddDynamicAction(&dynamicActionsMarker{
+		BaseAction: gostage.NewBaseAction(dynamicActionsMarkerName, "Track the actions added by the previous action"),
+		creator:    a,
+	})
+	err := a.executeLocked(ctx)
+	ctx.Logger = logger
+	// The writes made directly on the store are only checked now
+	if verifyErr := kvstore.Verify(ctx.Workflow.Store); verifyErr != nil {
+		err = errors.Join(err, verifyErr)
+	}
+	if capture != nil {
+		entry.Output = capture.String()
+	}
+	entry.finish(err)
+
+	// Pausing is not a failure, no error policy may swallow it
+	if errors.Is(err, ErrPausedForInput) {
+		return a.workflow.pause(ctx, a.Action, err)
+	}
+	if err != nil {
+		return a.workflow.handleFailure(a.stage, a.Action, err, ctx.Logger)
+	}
+	return nil
+}
+
+// executeLocked runs the wrapped action holding the resource it declares, see
+// ResourceLocker, once its preconditions hold, see PreconditionedAction,
+// within its timeout, see TimedAction
+func (a *trackedAction) executeLocked(ctx *gostage.ActionContext) error {
+	unlock, err := lockResource(ctx, a.Action)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkPreconditions(ctx, a.Action); err != nil {
+		return err
+	}
+	return executeWithTimeout(ctx, a.Action, a.stage.ActionTimeout())
+}
+
+// track wraps an action of the same stage as a
+func (a *trackedAction) track(action gostage.Action) gostage.Action {
+	switch action.(type) {
+	case *trackedAction, *dynamicActionsMarker:
+		return action
+	}
+	return &trackedAction{Action: action, workflow: a.workflow, stage: a.stage, report: a.report}
+}
+
+// dynamicActionsMarkerName names every dynamicActionsMarker
+const dynamicActionsMarkerName = "engine-track-dynamic-actions"
+
+// dynamicActionsMarker is added by each tracked action before it runs. The
+// runner inserts it right after the action, ahead of the actions the action
+// adds, so it runs first and wraps them like their creator. It is removed
+// once the stage has run.
+type dynamicActionsMarker struct {
+	gostage.BaseAction
+
+	creator *trackedAction
+}
+
+// Execute wraps the actions following the marker that are not tracked yet
+func (m *dynamicActionsMarker) Execute(ctx *gostage.ActionContext) error {
+	for i := ctx.ActionIndex + 1; i < len(ctx.Stage.Actions); i++ {
+		ctx.Stage.Actions[i] = m.creator.track(ctx.Stage.Actions[i])
+	}
+	return nil
+}
+
+// removeMarkers removes the markers the tracked actions of a stage added,
+// and the keys the runner stored for them
+func removeMarkers(stage *gostage.Stage, workflow *gostage.Workflow) {
+	actions := stage.Actions[:0]
+	removed := false
+	for _, action := range stage.Actions {
+		if _, ok := action.(*dynamicActionsMarker); ok {
+			removed = true
+			continue
+		}
+		actions = append(actions, action)
+	}
+	stage.Actions = actions
+
+	if removed && workflow.Store != nil {
+		workflow.Store.Delete(gostage.PrefixAction + stage.ID + ":" + dynamicActionsMarkerName)
+	}
+}
+
+// actionPosition returns the position the action at index would have in the
+// actions of a stage without the markers added to it
+func actionPosition(stage *gostage.Stage, index int) int {
+	position := index
+	for _, action := range stage.Actions[:index] {
+		if _, ok := action.(*dynamicActionsMarker); ok {
+			position--
+		}
+	}
+	return position
+}
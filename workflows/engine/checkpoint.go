@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// StatusPaused marks a workflow, stage or action stopped by a PauseAction
+const StatusPaused = "paused"
+
+// ErrPausedForInput is returned by actions that need an external condition,
+// such as media being inserted by hand, before the workflow can go on.
+// The workflow stops and records a Checkpoint it can be resumed from.
+var ErrPausedForInput = errors.New("workflow paused waiting for external input")
+
+// PauseAction stops the workflow until an operator resumes it
+type PauseAction struct {
+	gostage.BaseAction
+
+	reason string
+}
+
+// NewPauseAction creates an action pausing the workflow.
+// The reason tells the operator what to do before resuming.
+func NewPauseAction(name, reason string) *PauseAction {
+	return &PauseAction{
+		BaseAction: gostage.NewBaseAction(name, "Pause: "+reason),
+		reason:     reason,
+	}
+}
+
+// Execute implements gostage.Action
+func (a *PauseAction) Execute(ctx *gostage.ActionContext) error {
+	return fmt.Errorf("%w: %s", ErrPausedForInput, a.reason)
+}
+
+// Checkpoint records where a paused workflow stopped and the state of its
+// store, so the workflow can be resumed later, possibly by another process
+type Checkpoint struct {
+	WorkflowID  string            `json:"workflowId"`
+	StageID     string            `json:"stageId"`
+	ActionName  string            `json:"actionName"`
+	ActionIndex int               `json:"actionIndex"`
+	Reason      string            `json:"reason"`
+	PausedAt    time.Time         `json:"pausedAt"`
+	Store       *kvstore.Snapshot `json:"store"`
+
+	// Cleanups describes the cleanups deferred before the pause, which run
+	// once the resumed workflow finishes
+	Cleanups []string `json:"cleanups,omitempty"`
+
+	// deferred holds the cleanups themselves, only known to the process
+	// that paused
+	deferred []deferredCleanup
+}
+
+// Save writes the checkpoint to a JSON file
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint reads a checkpoint written by Save
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// Checkpoint returns the checkpoint recorded when the last execution paused,
+// or nil if it did not pause
+func (w *Workflow) Checkpoint() *Checkpoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checkpoint
+}
+
+// Resume continues a paused workflow with a default gostage runner
+func (w *Workflow) Resume(ctx context.Context, checkpoint *Checkpoint, logger gostage.Logger) error {
+	return w.ResumeWith(ctx, gostage.NewRunner(), checkpoint, logger)
+}
+
+// ResumeWith restores the store saved in the checkpoint and runs the workflow
+// again from the action following the pause. Stages and actions that ran
+// before the pause are reported as skipped. The workflow must be built the
+// same way as the one that paused.
+func (w *Workflow) ResumeWith(ctx context.Context, runner *gostage.Runner, checkpoint *Checkpoint, logger gostage.Logger) error {
+	if checkpoint.WorkflowID != w.ID {
+		return fmt.Errorf("checkpoint belongs to workflow '%s', not '%s'", checkpoint.WorkflowID, w.ID)
+	}
+
+	var stage *gostage.Stage
+	for _, s := range w.Stages {
+		if s.ID == checkpoint.StageID {
+			stage = s
+			break
+		}
+	}
+	if stage == nil {
+		return fmt.Errorf("checkpoint stage '%s' not found in workflow '%s'", checkpoint.StageID, w.ID)
+	}
+	if checkpoint.ActionIndex < 0 || checkpoint.ActionIndex >= len(stage.Actions) ||
+		stage.Actions[checkpoint.ActionIndex].Name() != checkpoint.ActionName {
+		return fmt.Errorf("checkpoint action '%s' not found at position %d of stage '%s'",
+			checkpoint.ActionName, checkpoint.ActionIndex, checkpoint.StageID)
+	}
+
+	if checkpoint.Store != nil {
+		if err := kvstore.Restore(w.Store, checkpoint.Store); err != nil {
+			return fmt.Errorf("failed to restore checkpoint store: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.resumeFrom = checkpoint
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.resumeFrom = nil
+		w.mu.Unlock()
+	}()
+
+	return w.ExecuteWith(ctx, runner, logger)
+}
+
+// pause records a checkpoint for the action that paused the workflow
+func (w *Workflow) pause(ctx *gostage.ActionContext, action gostage.Action, err error) error {
+	snapshot, snapErr := kvstore.TakeSnapshot(ctx.Workflow.Store)
+	if snapErr != nil {
+		return fmt.Errorf("failed to checkpoint paused workflow: %w", snapErr)
+	}
+
+	// Execution bookkeeping is rebuilt by the runner when resuming
+	for _, key := range snapshot.Keys() {
+		for _, prefix := range []string{gostage.PrefixWorkflow, gostage.PrefixStage, gostage.PrefixAction, gostage.PrefixTemp} {
+			if strings.HasPrefix(key, prefix) {
+				delete(snapshot.Entries, key)
+			}
+		}
+	}
+
+	checkpoint := &Checkpoint{
+		WorkflowID:  ctx.Workflow.ID,
+		StageID:     ctx.Stage.ID,
+		ActionName:  action.Name(),
+		ActionIndex: actionPosition(ctx.Stage, ctx.ActionIndex),
+		Reason:      err.Error(),
+		PausedAt:    time.Now(),
+		Store:       snapshot,
+	}
+
+	w.mu.Lock()
+	w.checkpoint = checkpoint
+	w.mu.Unlock()
+
+	ctx.Logger.Info("Workflow %s paused at action %s in stage %s: %v", checkpoint.WorkflowID, checkpoint.ActionName, checkpoint.StageID, err)
+	return err
+}
+
+// resumePosition tells how a stage relates to the checkpoint being resumed
+// from: -1 before it, 0 the stage that paused, 1 after it or not resuming
+func (w *Workflow) resumePosition(stage *gostage.Stage, workflow *gostage.Workflow) (int, *Checkpoint) {
+	w.mu.Lock()
+	checkpoint := w.resumeFrom
+	w.mu.Unlock()
+
+	if checkpoint == nil {
+		return 1, nil
+	}
+	if stage.ID == checkpoint.StageID {
+		return 0, checkpoint
+	}
+	for _, s := range workflow.Stages {
+		if s.ID == checkpoint.StageID {
+			return 1, nil
+		}
+		if s.ID == stage.ID {
+			return -1, checkpoint
+		}
+	}
+	return 1, nil
+}
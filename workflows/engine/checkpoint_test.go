@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildPausingWorkflow creates a workflow waiting for media to be inserted
+// between preparing and flashing a node, and records which actions ran
+func buildPausingWorkflow(ran *[]string) *Workflow {
+	record := func(name string, fn func(ctx *gostage.ActionContext) error) *workflows.FuncAction {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			*ran = append(*ran, name)
+			return fn(ctx)
+		})
+	}
+
+	wf := NewWorkflow("provision", "Provision", "Workflow waiting for an operator")
+
+	prepare := NewStage("prepare", "Prepare", "Preparation stage")
+	prepare.AddAction(record("select-node", func(ctx *gostage.ActionContext) error {
+		return ctx.Workflow.Store.Put("data:node", 2)
+	}))
+	wf.AddStage(prepare)
+
+	flash := NewStage("flash", "Flash", "Flashing stage")
+	flash.AddAction(record("power-off", func(ctx *gostage.ActionContext) error {
+		return ctx.Workflow.Store.Put("data:powered", false)
+	}))
+	flash.AddAction(NewPauseAction("insert-media", "insert the SD card"))
+	flash.AddAction(record("flash-node", func(ctx *gostage.ActionContext) error {
+		node, err := store.Get[int](ctx.Workflow.Store, "data:node")
+		if err != nil {
+			return err
+		}
+		return ctx.Workflow.Store.Put("data:flashed", node)
+	}))
+	wf.AddStage(flash)
+
+	verify := NewStage("verify", "Verify", "Verification stage")
+	verify.AddAction(record("check-boot", func(ctx *gostage.ActionContext) error {
+		return nil
+	}))
+	wf.AddStage(verify)
+
+	return wf
+}
+
+func TestWorkflowPauseAndResume(t *testing.T) {
+	var ran []string
+	wf := buildPausingWorkflow(&ran)
+	wf.SetErrorMode(CollectAll)
+
+	err := wf.Execute(context.Background(), nil)
+	if !errors.Is(err, ErrPausedForInput) {
+		t.Fatalf("Execute() error = %v, want ErrPausedForInput", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"select-node", "power-off"}) {
+		t.Errorf("Executed actions = %v, want execution to stop at the pause", ran)
+	}
+	if status := wf.Report().Status; status != StatusPaused {
+		t.Errorf("Report status = %s, want %s", status, StatusPaused)
+	}
+
+	checkpoint := wf.Checkpoint()
+	if checkpoint == nil {
+		t.Fatal("Checkpoint() = nil after pausing")
+	}
+	if checkpoint.StageID != "flash" || checkpoint.ActionName != "insert-media" || checkpoint.ActionIndex != 1 {
+		t.Errorf("Checkpoint = %+v, want insert-media in flash", checkpoint)
+	}
+	for _, key := range checkpoint.Store.Keys() {
+		if key != "data:node" && key != "data:powered" {
+			t.Errorf("Checkpoint store holds unexpected key %s", key)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := checkpoint.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Resume in a fresh workflow, as a new process would
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+
+	ran = nil
+	resumed := buildPausingWorkflow(&ran)
+	if err := resumed.Resume(context.Background(), loaded, nil); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"flash-node", "check-boot"}) {
+		t.Errorf("Resumed actions = %v, want the actions after the pause", ran)
+	}
+	if flashed, err := store.Get[int](resumed.Store, "data:flashed"); err != nil || flashed != 2 {
+		t.Errorf("Flashed node = %v, %v, want node 2 from the restored store", flashed, err)
+	}
+	if resumed.Checkpoint() != nil {
+		t.Error("Checkpoint() should be nil after completing")
+	}
+
+	report := resumed.Report()
+	if !report.Success() {
+		t.Errorf("Resumed report status = %s, want completed", report.Status)
+	}
+	if len(report.Stages) != 3 || report.Stages[0].Status != gostage.StatusSkipped {
+		t.Fatalf("Resumed report stages = %+v, want prepare skipped", report.Stages)
+	}
+	for _, action := range report.Stages[1].Actions {
+		want := gostage.StatusSkipped
+		if action.Name == "flash-node" {
+			want = gostage.StatusCompleted
+		}
+		if action.Status != want {
+			t.Errorf("Action %s status = %s, want %s", action.Name, action.Status, want)
+		}
+	}
+}
+
+func TestWorkflowResumeRejectsForeignCheckpoint(t *testing.T) {
+	var ran []string
+	wf := buildPausingWorkflow(&ran)
+
+	tests := []struct {
+		name       string
+		checkpoint *Checkpoint
+	}{
+		{"Other workflow", &Checkpoint{WorkflowID: "other", StageID: "flash", ActionName: "insert-media", ActionIndex: 1}},
+		{"Unknown stage", &Checkpoint{WorkflowID: "provision", StageID: "missing", ActionName: "insert-media", ActionIndex: 1}},
+		{"Moved action", &Checkpoint{WorkflowID: "provision", StageID: "flash", ActionName: "insert-media", ActionIndex: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := wf.Resume(context.Background(), tt.checkpoint, nil); err == nil {
+				t.Error("Resume() should fail")
+			}
+		})
+	}
+	if len(ran) != 0 {
+		t.Errorf("Executed actions = %v, want none", ran)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// IOSpec declares a store key an action reads or writes and the Go type of its value
+type IOSpec struct {
+	Key  string
+	Type reflect.Type
+}
+
+// IO declares a store key holding a value of type T
+func IO[T any](key string) IOSpec {
+	return IOSpec{Key: key, Type: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// String returns the key and type of the spec
+func (s IOSpec) String() string {
+	return fmt.Sprintf("%s (%v)", s.Key, s.Type)
+}
+
+// InputDeclarer is implemented by actions declaring the store keys they read
+type InputDeclarer interface {
+	Inputs() []IOSpec
+}
+
+// OutputDeclarer is implemented by actions declaring the store keys they write
+type OutputDeclarer interface {
+	Outputs() []IOSpec
+}
+
+// DataFlowError reports an input an action declares that is not produced as
+// declared before it runs
+type DataFlowError struct {
+	StageID string
+	Action  string
+	Input   IOSpec
+	// Produced is the type the key is produced with, nil when no prior action
+	// produces it
+	Produced reflect.Type
+	// Producer is the action producing the key, empty when the key was
+	// already in the store
+	Producer string
+}
+
+// Error implements the error interface
+func (e *DataFlowError) Error() string {
+	if e.Produced == nil {
+		return fmt.Sprintf("stage '%s': action '%s' reads '%s' which no prior action produces",
+			e.StageID, e.Action, e.Input)
+	}
+	if e.Producer == "" {
+		return fmt.Sprintf("stage '%s': action '%s' reads '%s' but the store holds it as %v",
+			e.StageID, e.Action, e.Input, e.Produced)
+	}
+	return fmt.Sprintf("stage '%s': action '%s' reads '%s' but action '%s' produces it as %v",
+		e.StageID, e.Action, e.Input, e.Producer, e.Produced)
+}
+
+// ValidateDataFlow checks, before executing the workflow, that every input
+// declared by an action is produced with the same type by the declared
+// outputs of an action running before it, or is already in the store with
+// that type. Disabled stages and actions are ignored, see
+// DisableActionsMatching, as are actions and stages added dynamically at run
+// time. All problems found are returned joined, each as a
+// *DataFlowError.
+func (w *Workflow) ValidateDataFlow() error {
+	type producer struct {
+		action string
+		typ    reflect.Type
+	}
+	produced := make(map[string]producer)
+	disabledActions, _ := w.Context["disabledActions"].(map[string]bool)
+
+	// Keys already in the store are available to every action
+	for _, key := range w.Store.ListKeys() {
+		if typ, err := kvstore.TypeOf(w.Store, key); err == nil {
+			produced[key] = producer{typ: typ}
+		}
+	}
+
+	var errs []error
+	for _, stage := range w.Stages {
+		if !w.IsStageEnabled(stage.ID) {
+			continue
+		}
+
+		for _, action := range stage.Actions {
+			if tracked, ok := action.(*trackedAction); ok {
+				action = tracked.Action
+			}
+			if disabledActions[action.Name()] {
+				continue
+			}
+
+			if declarer, ok := action.(InputDeclarer); ok {
+				for _, input := range declarer.Inputs() {
+					p, ok := produced[input.Key]
+					switch {
+					case !ok:
+						errs = append(errs, &DataFlowError{StageID: stage.ID, Action: action.Name(), Input: input})
+					case !compatibleIO(p.typ, input.Type):
+						errs = append(errs, &DataFlowError{
+							StageID:  stage.ID,
+							Action:   action.Name(),
+							Input:    input,
+							Producer: p.action,
+							Produced: p.typ,
+						})
+					}
+				}
+			}
+
+			if declarer, ok := action.(OutputDeclarer); ok {
+				for _, output := range declarer.Outputs() {
+					produced[output.Key] = producer{action: action.Name(), typ: output.Type}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compatibleIO reports whether a value produced as one type can be read as
+// another, following the rules of store.Get: interfaces accept the types
+// implementing them, other types must match exactly. Undeclared types match
+// anything.
+func compatibleIO(produced, wanted reflect.Type) bool {
+	if produced == nil || wanted == nil {
+		return true
+	}
+	if wanted.Kind() == reflect.Interface {
+		return produced.Implements(wanted)
+	}
+	return produced == wanted
+}
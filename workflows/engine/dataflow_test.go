@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// ioAction is a test action declaring its inputs and outputs
+type ioAction struct {
+	workflows.FuncAction
+	inputs  []IOSpec
+	outputs []IOSpec
+}
+
+func newIOAction(name string, inputs, outputs []IOSpec) *ioAction {
+	return &ioAction{
+		FuncAction: *workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error { return nil }),
+		inputs:     inputs,
+		outputs:    outputs,
+	}
+}
+
+func (a *ioAction) Inputs() []IOSpec  { return a.inputs }
+func (a *ioAction) Outputs() []IOSpec { return a.outputs }
+
+// buildDataFlowWorkflow creates a discovery workflow whose configure stage
+// reads the key given to it
+func buildDataFlowWorkflow(configureInput IOSpec) *Workflow {
+	wf := NewWorkflow("dataflow", "Data flow", "Workflow passing discovered resources")
+
+	discover := NewStage("discover", "Discover", "Discover resources")
+	discover.AddAction(newIOAction("discover", nil, []IOSpec{IO[[]string]("discovered.resources")}))
+	discover.AddAction(newIOAction("count", []IOSpec{IO[[]string]("discovered.resources")}, []IOSpec{IO[int]("discovered.count")}))
+	wf.AddStage(discover)
+
+	configure := NewStage("configure", "Configure", "Configure resources")
+	configure.AddAction(workflows.NewFuncAction("log", "", func(ctx *gostage.ActionContext) error { return nil }))
+	configure.AddAction(newIOAction("configure", []IOSpec{configureInput, IO[string]("cluster.name")}, nil))
+	wf.AddStage(configure)
+
+	wf.Store.Put("cluster.name", "lab")
+	return wf
+}
+
+func TestValidateDataFlow(t *testing.T) {
+	t.Run("Every input is produced", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[[]string]("discovered.resources"))
+		if err := wf.ValidateDataFlow(); err != nil {
+			t.Errorf("ValidateDataFlow() error = %v", err)
+		}
+	})
+
+	t.Run("Missing producer", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[[]string]("discovered.resource"))
+		err := wf.ValidateDataFlow()
+
+		var flowErr *DataFlowError
+		if !errors.As(err, &flowErr) {
+			t.Fatalf("ValidateDataFlow() error = %v, want a *DataFlowError", err)
+		}
+		if flowErr.Input.Key != "discovered.resource" || flowErr.StageID != "configure" || flowErr.Action != "configure" {
+			t.Errorf("DataFlowError = %+v", flowErr)
+		}
+		if !strings.Contains(err.Error(), "discovered.resource") {
+			t.Errorf("ValidateDataFlow() error = %v, want the offending key", err)
+		}
+	})
+
+	t.Run("Type mismatch", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[map[string]bool]("discovered.resources"))
+		err := wf.ValidateDataFlow()
+
+		var flowErr *DataFlowError
+		if !errors.As(err, &flowErr) {
+			t.Fatalf("ValidateDataFlow() error = %v, want a *DataFlowError", err)
+		}
+		if flowErr.Producer != "discover" || flowErr.Produced.String() != "[]string" {
+			t.Errorf("DataFlowError = %+v", flowErr)
+		}
+	})
+
+	t.Run("Input read before it is produced", func(t *testing.T) {
+		wf := NewWorkflow("order", "Order", "Workflow reading too early")
+		stage := NewStage("main", "Main", "Main stage")
+		stage.AddAction(newIOAction("consume", []IOSpec{IO[int]("result")}, nil))
+		stage.AddAction(newIOAction("produce", nil, []IOSpec{IO[int]("result")}))
+		wf.AddStage(stage)
+
+		if err := wf.ValidateDataFlow(); err == nil {
+			t.Error("ValidateDataFlow() expected an error for an input produced later")
+		}
+	})
+
+	t.Run("All problems are reported", func(t *testing.T) {
+		wf := NewWorkflow("many", "Many", "Workflow with several wiring errors")
+		stage := NewStage("main", "Main", "Main stage")
+		stage.AddAction(newIOAction("first", []IOSpec{IO[int]("a"), IO[int]("b")}, nil))
+		wf.AddStage(stage)
+
+		err := wf.ValidateDataFlow()
+		for _, key := range []string{"'a (int)'", "'b (int)'"} {
+			if err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("ValidateDataFlow() error = %v, want %s reported", err, key)
+			}
+		}
+	})
+
+	t.Run("Store values and interfaces", func(t *testing.T) {
+		wf := NewWorkflow("store", "Store", "Workflow reading initial data")
+		stage := NewStage("main", "Main", "Main stage")
+		stage.AddAction(newIOAction("wait", []IOSpec{IO[fmt.Stringer]("timeout")}, nil))
+		stage.AddAction(newIOAction("retries", []IOSpec{IO[string]("retries")}, nil))
+		wf.AddStage(stage)
+		wf.Store.Put("timeout", 5*time.Second)
+		wf.Store.Put("retries", 3)
+
+		var flowErr *DataFlowError
+		err := wf.ValidateDataFlow()
+		if !errors.As(err, &flowErr) || flowErr.Input.Key != "retries" {
+			t.Fatalf("ValidateDataFlow() error = %v, want only retries reported", err)
+		}
+		if !strings.Contains(err.Error(), "store holds it as int") || strings.Contains(err.Error(), "timeout") {
+			t.Errorf("ValidateDataFlow() error = %v", err)
+		}
+	})
+
+	t.Run("Disabled stages are ignored", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[[]string]("missing"))
+		wf.DisableStage("configure")
+		if err := wf.ValidateDataFlow(); err != nil {
+			t.Errorf("ValidateDataFlow() error = %v", err)
+		}
+	})
+	t.Run("Disabled actions are ignored", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[[]string]("missing"))
+		wf.DisableActionsMatching("conf*")
+		if err := wf.ValidateDataFlow(); err != nil {
+			t.Errorf("ValidateDataFlow() error = %v", err)
+		}
+	})
+
+	t.Run("Outputs of disabled actions are not produced", func(t *testing.T) {
+		wf := buildDataFlowWorkflow(IO[[]string]("discovered.resources"))
+		wf.DisableActionsMatching("discover")
+
+		var flowErr *DataFlowError
+		err := wf.ValidateDataFlow()
+		if !errors.As(err, &flowErr) || flowErr.Input.Key != "discovered.resources" {
+			t.Fatalf("ValidateDataFlow() error = %v, want discovered.resources reported", err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ErrNotEngineWorkflow is returned by Defer when the action does not run in a
+// workflow wrapped by the engine
+var ErrNotEngineWorkflow = errors.New("action is not running in an engine workflow")
+
+// engineWorkflowKey is the gostage workflow context key holding its engine workflow
+const engineWorkflowKey = "engineWorkflow"
+
+// CleanupError aggregates the failures of the deferred cleanups of an execution
+type CleanupError struct {
+	Errors []error
+}
+
+// Error implements the error interface
+func (e *CleanupError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d deferred cleanup(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap exposes the cleanup errors to errors.Is and errors.As
+func (e *CleanupError) Unwrap() []error {
+	return e.Errors
+}
+
+// Defer queues a cleanup run once the workflow the action runs in has
+// finished, whether it succeeded, failed or an action panicked. A workflow
+// that pauses keeps the queue in its checkpoint and runs it once resumed to
+// the end; the cleanups are functions and cannot be saved with the checkpoint
+// though, one loaded from a file only lists them in Cleanups. Cleanups run in
+// the reverse order they were queued, every one of them runs even when others
+// fail, and their failures are returned by Execute as a *CleanupError joined
+// with the workflow error. This suits releasing resources acquired by an
+// action, such as a mounted image or a temporary container.
+func Defer(ctx *gostage.ActionContext, cleanup func() error) error {
+	w, ok := ctx.Workflow.Context[engineWorkflowKey].(*Workflow)
+	if !ok {
+		return ErrNotEngineWorkflow
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deferred = append(w.deferred, deferredCleanup{
+		stageID: ctx.Stage.ID,
+		action:  ctx.Action.Name(),
+		run:     cleanup,
+	})
+	return nil
+}
+
+// deferredCleanup is a cleanup queued by an action with Defer
+type deferredCleanup struct {
+	stageID string
+	action  string
+	run     func() error
+}
+
+// String describes the cleanup for checkpoints
+func (c deferredCleanup) String() string {
+	return fmt.Sprintf("stage '%s', action '%s'", c.stageID, c.action)
+}
+
+// keepDeferred moves the queued cleanups to the checkpoint when the execution
+// paused, and reports whether it did
+func (w *Workflow) keepDeferred() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.checkpoint == nil {
+		return false
+	}
+	w.checkpoint.deferred = w.deferred
+	w.checkpoint.Cleanups = make([]string, len(w.deferred))
+	for i, cleanup := range w.deferred {
+		w.checkpoint.Cleanups[i] = cleanup.String()
+	}
+	w.deferred = nil
+	return true
+}
+
+// runDeferred runs the queued cleanups last in, first out and returns their
+// failures as a *CleanupError, nil when all of them succeed
+func (w *Workflow) runDeferred() error {
+	w.mu.Lock()
+	deferred := w.deferred
+	w.deferred = nil
+	w.mu.Unlock()
+
+	var errs []error
+	for i := len(deferred) - 1; i >= 0; i-- {
+		cleanup := deferred[i]
+		if err := cleanup.run(); err != nil {
+			errs = append(errs, fmt.Errorf("stage '%s': cleanup deferred by action '%s' failed: %w",
+				cleanup.stageID, cleanup.action, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &CleanupError{Errors: errs}
+	}
+	return nil
+}
+
+// withCleanupError joins the failures of the deferred cleanups to the error
+// of an execution
+func withCleanupError(err, cleanupErr error) error {
+	if cleanupErr == nil {
+		return err
+	}
+	if err == nil {
+		return cleanupErr
+	}
+	return errors.Join(err, cleanupErr)
+}
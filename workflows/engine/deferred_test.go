@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildDeferringWorkflow creates a workflow whose actions each defer two
+// cleanups recording their order in ran, the cleanups of cleanupFailures
+// fail and the action named failing fails
+func buildDeferringWorkflow(ran *[]string, failing string, cleanupFailures map[string]error) *Workflow {
+	wf := NewWorkflow("deferred", "Deferred", "Workflow deferring cleanups")
+
+	deferring := func(name string) gostage.Action {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			for _, resource := range []string{"mount", "container"} {
+				cleanup := name + "/" + resource
+				if err := Defer(ctx, func() error {
+					*ran = append(*ran, cleanup)
+					return cleanupFailures[cleanup]
+				}); err != nil {
+					return err
+				}
+			}
+			if name == failing {
+				return fmt.Errorf("%s failed", name)
+			}
+			return nil
+		})
+	}
+
+	prepare := NewStage("prepare", "Prepare", "Acquire resources")
+	prepare.AddAction(deferring("first"))
+	prepare.AddAction(deferring("second"))
+	wf.AddStage(prepare)
+
+	use := NewStage("use", "Use", "Use resources")
+	use.AddAction(deferring("third"))
+	wf.AddStage(use)
+	return wf
+}
+
+func TestDefer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Cleanups run last in, first out", func(t *testing.T) {
+		var ran []string
+		wf := buildDeferringWorkflow(&ran, "", nil)
+		if err := wf.Execute(ctx, nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		want := "third/container,third/mount,second/container,second/mount,first/container,first/mount"
+		if got := strings.Join(ran, ","); got != want {
+			t.Errorf("Cleanups ran %s, want %s", got, want)
+		}
+
+		// Cleanups run once, another execution queues its own
+		ran = nil
+		if err := wf.Execute(ctx, nil); err != nil {
+			t.Fatalf("Second Execute() error = %v", err)
+		}
+		if len(ran) != 6 {
+			t.Errorf("Second execution ran %d cleanups, want 6", len(ran))
+		}
+	})
+
+	t.Run("Cleanups run when the workflow fails", func(t *testing.T) {
+		var ran []string
+		errUnmount := errors.New("device busy")
+		errRemove := errors.New("no such container")
+		wf := buildDeferringWorkflow(&ran, "second", map[string]error{
+			"first/mount":      errUnmount,
+			"second/container": errRemove,
+		})
+
+		err := wf.Execute(ctx, nil)
+		if got, want := strings.Join(ran, ","), "second/container,second/mount,first/container,first/mount"; got != want {
+			t.Errorf("Cleanups ran %s, want %s", got, want)
+		}
+
+		var cleanupErr *CleanupError
+		if !errors.As(err, &cleanupErr) {
+			t.Fatalf("Execute() error = %v, want a *CleanupError", err)
+		}
+		if len(cleanupErr.Errors) != 2 || !errors.Is(cleanupErr.Errors[0], errRemove) || !errors.Is(cleanupErr.Errors[1], errUnmount) {
+			t.Errorf("CleanupError = %v", cleanupErr)
+		}
+		if !strings.Contains(err.Error(), "second failed") {
+			t.Errorf("Execute() error = %v, want the action failure kept", err)
+		}
+		if report := wf.Report(); report.Status != gostage.StatusFailed || !errors.Is(report.Error, errUnmount) {
+			t.Errorf("Report status = %s, error = %v", report.Status, report.Error)
+		}
+	})
+
+	t.Run("Cleanup failures join collected failures", func(t *testing.T) {
+		var ran []string
+		errUnmount := errors.New("device busy")
+		wf := buildDeferringWorkflow(&ran, "first", map[string]error{"third/mount": errUnmount})
+		wf.SetErrorMode(CollectAll)
+
+		err := wf.Execute(ctx, nil)
+		if len(ran) != 6 {
+			t.Errorf("Ran %d cleanups, want 6", len(ran))
+		}
+
+		var multi *MultiError
+		if !errors.As(err, &multi) || !errors.Is(err, errUnmount) {
+			t.Errorf("Execute() error = %v, want the collected and cleanup failures", err)
+		}
+	})
+
+	t.Run("Cleanups wait for a paused workflow to finish", func(t *testing.T) {
+		var ran []string
+		wf := buildDeferringWorkflow(&ran, "", nil)
+		wf.Stages[1].Actions = append([]gostage.Action{NewPauseAction("insert-media", "insert the SD card")},
+			wf.Stages[1].Actions...)
+
+		if err := wf.Execute(ctx, nil); !errors.Is(err, ErrPausedForInput) {
+			t.Fatalf("Execute() error = %v, want ErrPausedForInput", err)
+		}
+		if len(ran) != 0 {
+			t.Fatalf("Cleanups %v ran on pause", ran)
+		}
+		checkpoint := wf.Checkpoint()
+		if len(checkpoint.Cleanups) != 4 || !strings.Contains(checkpoint.Cleanups[0], "first") {
+			t.Errorf("Checkpoint cleanups = %v, want the four queued before the pause", checkpoint.Cleanups)
+		}
+
+		if err := wf.Resume(ctx, checkpoint, nil); err != nil {
+			t.Fatalf("Resume() error = %v", err)
+		}
+		want := "third/container,third/mount,second/container,second/mount,first/container,first/mount"
+		if got := strings.Join(ran, ","); got != want {
+			t.Errorf("Cleanups ran %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Cleanups run when an action panics", func(t *testing.T) {
+		var ran []string
+		wf := buildDeferringWorkflow(&ran, "", nil)
+		wf.Stages[1].AddAction(workflows.NewFuncAction("crash", "", func(ctx *gostage.ActionContext) error {
+			panic("nil map")
+		}))
+
+		func() {
+			defer func() {
+				if r := recover(); r != "nil map" {
+					t.Errorf("recover() = %v, want the action panic", r)
+				}
+			}()
+			wf.Execute(ctx, nil)
+		}()
+		if len(ran) != 6 {
+			t.Errorf("Ran %d cleanups, want 6", len(ran))
+		}
+	})
+
+	t.Run("Action outside an engine workflow", func(t *testing.T) {
+		wf := gostage.NewWorkflow("plain", "Plain", "Workflow without engine")
+		stage := gostage.NewStage("main", "Main", "Main stage")
+		var deferErr error
+		stage.AddAction(workflows.NewFuncAction("defer", "", func(ctx *gostage.ActionContext) error {
+			deferErr = Defer(ctx, func() error { return nil })
+			return nil
+		}))
+		wf.AddStage(stage)
+
+		if err := gostage.NewRunner().Execute(ctx, wf, nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !errors.Is(deferErr, ErrNotEngineWorkflow) {
+			t.Errorf("Defer() error = %v, want ErrNotEngineWorkflow", deferErr)
+		}
+	})
+}
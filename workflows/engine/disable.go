@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"path"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// DisableActionsMatching disables every action whose name matches the glob
+// pattern, as understood by path.Match, and returns how many actions were
+// disabled. It scans all stages of the workflow, including the dynamic stages
+// already generated, and may be called while the workflow runs. A malformed
+// pattern matches nothing.
+func (w *Workflow) DisableActionsMatching(pattern string) int {
+	disabled := w.disabledActions()
+	matching := w.actionsMatching(pattern)
+
+	count := 0
+	for _, action := range matching {
+		if !disabled[action.Name()] {
+			count++
+		}
+	}
+	for _, action := range matching {
+		disabled[action.Name()] = true
+	}
+	return count
+}
+
+// EnableActionsMatching enables every disabled action whose name matches the
+// glob pattern and returns how many actions were enabled, see
+// DisableActionsMatching
+func (w *Workflow) EnableActionsMatching(pattern string) int {
+	disabled := w.disabledActions()
+	matching := w.actionsMatching(pattern)
+
+	count := 0
+	for _, action := range matching {
+		if disabled[action.Name()] {
+			count++
+		}
+	}
+	for _, action := range matching {
+		delete(disabled, action.Name())
+	}
+	return count
+}
+
+// disabledActions returns the map of disabled action names the runner reads
+// from the workflow context, creating it if needed. The runner shares the map
+// with running actions only when it exists before the stage starts, which is
+// why Wrap creates it.
+func (w *Workflow) disabledActions() map[string]bool {
+	disabled, ok := w.Context["disabledActions"].(map[string]bool)
+	if !ok {
+		disabled = make(map[string]bool)
+		w.Context["disabledActions"] = disabled
+	}
+	return disabled
+}
+
+// actionsMatching returns the actions of every stage, and of the dynamic
+// stages not yet inserted, whose name matches the glob pattern
+func (w *Workflow) actionsMatching(pattern string) []gostage.Action {
+	w.mu.Lock()
+	stages := append([]*gostage.Stage(nil), w.Stages...)
+	stages = append(stages, w.queuedStages...)
+	w.mu.Unlock()
+	if generated, ok := w.Context["dynamicStages"].([]*gostage.Stage); ok {
+		stages = append(stages, generated...)
+	}
+
+	var matching []gostage.Action
+	for _, stage := range stages {
+		for _, action := range stage.Actions {
+			if matched, err := path.Match(pattern, action.Name()); err == nil && matched {
+				matching = append(matching, action)
+			}
+		}
+	}
+	return matching
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildBackupWorkflow creates a workflow with backup actions spread over
+// several stages, running the first stages given before them. Executed
+// actions are recorded in ran.
+func buildBackupWorkflow(ran *[]string, first ...*Stage) *Workflow {
+	record := func(name string) *workflows.FuncAction {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			*ran = append(*ran, name)
+			return nil
+		})
+	}
+
+	wf := NewWorkflow("backups", "Backups", "Workflow with backup actions")
+	for _, stage := range first {
+		wf.AddStage(stage)
+	}
+
+	prepare := NewStage("prepare", "Prepare", "Prepare the nodes")
+	prepare.AddAction(record("backup-config"))
+	prepare.AddAction(record("prepare-nodes"))
+	wf.AddStage(prepare)
+
+	flash := NewStage("flash", "Flash", "Flash the nodes")
+	flash.AddAction(record("backup-image"))
+	flash.AddAction(record("flash-nodes"))
+	flash.AddAction(record("backup-logs"))
+	wf.AddStage(flash)
+	return wf
+}
+
+func TestDisableActionsMatching(t *testing.T) {
+	t.Run("Across stages", func(t *testing.T) {
+		var ran []string
+		wf := buildBackupWorkflow(&ran)
+
+		if count := wf.DisableActionsMatching("backup-*"); count != 3 {
+			t.Errorf("DisableActionsMatching() = %d, want 3", count)
+		}
+		if count := wf.DisableActionsMatching("backup-*"); count != 0 {
+			t.Errorf("DisableActionsMatching() on disabled actions = %d, want 0", count)
+		}
+
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if want := []string{"prepare-nodes", "flash-nodes"}; !reflect.DeepEqual(ran, want) {
+			t.Errorf("Executed actions = %v, want %v", ran, want)
+		}
+	})
+
+	t.Run("Enable counterpart", func(t *testing.T) {
+		var ran []string
+		wf := buildBackupWorkflow(&ran)
+
+		wf.DisableActionsMatching("backup-*")
+		if count := wf.EnableActionsMatching("backup-[il]*"); count != 2 {
+			t.Errorf("EnableActionsMatching() = %d, want 2", count)
+		}
+		if count := wf.EnableActionsMatching("flash-*"); count != 0 {
+			t.Errorf("EnableActionsMatching() on enabled actions = %d, want 0", count)
+		}
+
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if want := []string{"prepare-nodes", "backup-image", "flash-nodes", "backup-logs"}; !reflect.DeepEqual(ran, want) {
+			t.Errorf("Executed actions = %v, want %v", ran, want)
+		}
+	})
+
+	t.Run("While running, including dynamic stages", func(t *testing.T) {
+		var ran []string
+		var wf *Workflow
+
+		generate := NewStage("generate", "Generate", "Generate a verification stage")
+		generate.AddAction(workflows.NewFuncAction("generate", "", func(ctx *gostage.ActionContext) error {
+			verify := gostage.NewStage("verify", "Verify", "Generated stage")
+			verify.AddAction(workflows.NewFuncAction("backup-verify", "", func(ctx *gostage.ActionContext) error {
+				ran = append(ran, "backup-verify")
+				return nil
+			}))
+			ctx.AddDynamicStage(verify)
+			return nil
+		}))
+		generate.AddAction(workflows.NewFuncAction("disable-backups", "", func(ctx *gostage.ActionContext) error {
+			if count := wf.DisableActionsMatching("backup-*"); count != 4 {
+				t.Errorf("DisableActionsMatching() = %d, want 4", count)
+			}
+			return nil
+		}))
+		wf = buildBackupWorkflow(&ran, generate)
+
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if want := []string{"prepare-nodes", "flash-nodes"}; !reflect.DeepEqual(ran, want) {
+			t.Errorf("Executed actions = %v, want %v", ran, want)
+		}
+	})
+
+	t.Run("Malformed pattern matches nothing", func(t *testing.T) {
+		var ran []string
+		wf := buildBackupWorkflow(&ran)
+		if count := wf.DisableActionsMatching("backup-["); count != 0 {
+			t.Errorf("DisableActionsMatching() = %d, want 0", count)
+		}
+	})
+}
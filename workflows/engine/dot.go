@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ToDOT renders the workflow as a Graphviz DOT graph.
+// Stages are drawn as clusters and actions as nodes. Dynamic stages and actions
+// are dashed, disabled ones are greyed out. Stages run one after the other unless
+// a stage declares dependencies (gostage.PropDependencies), which are drawn instead.
+func (w *Workflow) ToDOT() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", dotID(w.ID))
+	fmt.Fprintf(&b, "  label=%s;\n", dotID(w.Name))
+	b.WriteString("  compound=true;\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	disabledActions, _ := w.Context["disabledActions"].(map[string]bool)
+
+	// Anchor node of each stage, used as the end point of edges between clusters
+	anchors := make(map[string]string, len(w.Stages))
+
+	for _, stage := range w.Stages {
+		cluster := "cluster_" + stage.ID
+		fmt.Fprintf(&b, "\n  subgraph %s {\n", dotID(cluster))
+		fmt.Fprintf(&b, "    label=%s;\n", dotID(stage.Name))
+
+		var styles []string
+		if stage.HasTag(gostage.TagDynamic) {
+			styles = append(styles, "dashed")
+		}
+		if !w.IsStageEnabled(stage.ID) {
+			styles = append(styles, "filled")
+			b.WriteString("    fillcolor=lightgrey;\n    fontcolor=grey;\n")
+		}
+		if len(styles) > 0 {
+			fmt.Fprintf(&b, "    style=%s;\n", dotID(strings.Join(styles, ",")))
+		}
+
+		if len(stage.Actions) == 0 {
+			// Clusters need at least one node to be drawn and linked
+			anchor := stage.ID + "/"
+			fmt.Fprintf(&b, "    %s [label=\"\", shape=point, style=invis];\n", dotID(anchor))
+			anchors[stage.ID] = anchor
+		}
+
+		var previous string
+		for _, action := range stage.Actions {
+			node := stage.ID + "/" + action.Name()
+			if previous == "" {
+				anchors[stage.ID] = node
+			}
+
+			attrs := []string{"label=" + dotID(action.Name())}
+			nodeStyles := []string{"rounded"}
+			if w.isDynamicAction(stage, action) {
+				nodeStyles = append(nodeStyles, "dashed")
+			}
+			if disabledActions[action.Name()] {
+				nodeStyles = append(nodeStyles, "filled")
+				attrs = append(attrs, "fillcolor=lightgrey", "fontcolor=grey")
+			}
+			attrs = append(attrs, "style="+dotID(strings.Join(nodeStyles, ",")))
+
+			fmt.Fprintf(&b, "    %s [%s];\n", dotID(node), strings.Join(attrs, ", "))
+			if previous != "" {
+				fmt.Fprintf(&b, "    %s -> %s;\n", dotID(previous), dotID(node))
+			}
+			previous = node
+		}
+
+		b.WriteString("  }\n")
+	}
+
+	// Edges between stages
+	b.WriteString("\n")
+	for i, stage := range w.Stages {
+		dependencies := w.stageDependencies(stage)
+		if dependencies == nil && i > 0 {
+			dependencies = []string{w.Stages[i-1].ID}
+		}
+
+		for _, dependency := range dependencies {
+			from, ok := anchors[dependency]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&b, "  %s -> %s [ltail=%s, lhead=%s];\n",
+				dotID(from), dotID(anchors[stage.ID]),
+				dotID("cluster_"+dependency), dotID("cluster_"+stage.ID))
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// isDynamicAction reports whether an action was generated at runtime
+func (w *Workflow) isDynamicAction(stage *gostage.Stage, action gostage.Action) bool {
+	for _, tag := range action.Tags() {
+		if tag == gostage.TagDynamic {
+			return true
+		}
+	}
+
+	// The runner tags the stored metadata of generated actions, not the actions themselves
+	has, err := w.Store.HasTag(gostage.PrefixAction+stage.ID+":"+action.Name(), gostage.TagDynamic)
+	return err == nil && has
+}
+
+// stageDependencies returns the IDs of the stages a stage declares it depends on,
+// or nil when it declares none
+func (w *Workflow) stageDependencies(stage *gostage.Stage) []string {
+	value, err := w.Store.GetProperty(gostage.PrefixStage+stage.ID, gostage.PropDependencies)
+	if err != nil {
+		return nil
+	}
+
+	switch deps := value.(type) {
+	case []string:
+		return deps
+	case []interface{}:
+		ids := make([]string, 0, len(deps))
+		for _, dep := range deps {
+			if id, ok := dep.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	default:
+		return nil
+	}
+}
+
+// dotID quotes a string as a DOT identifier
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+func TestWorkflowToDOT(t *testing.T) {
+	noop := func(ctx *gostage.ActionContext) error { return nil }
+
+	wf := NewWorkflow("deploy", "Deploy", "Deployment workflow")
+
+	prepare := NewStage("prepare", "Prepare", "Prepare the image")
+	prepare.AddAction(workflows.NewFuncAction("download", "", noop))
+	prepare.AddAction(workflows.NewFuncAction("generate", "", func(ctx *gostage.ActionContext) error {
+		dynamic := gostage.NewStage("node-2", "Node 2", "Generated stage")
+		dynamic.AddAction(workflows.NewFuncAction("flash-node-2", "", noop))
+		ctx.AddDynamicStage(dynamic)
+		ctx.DisableAction("verify")
+		return nil
+	}))
+	wf.AddStage(prepare)
+
+	flash := NewStage("flash", "Flash", "Flash the nodes")
+	flash.AddAction(workflows.NewFuncAction("flash-node-1", "", noop))
+	flash.AddAction(workflows.NewFuncAction("verify", "", noop))
+	wf.AddStage(flash)
+
+	cleanup := NewStage("cleanup", "Cleanup", "Remove temporary files")
+	cleanup.AddAction(workflows.NewFuncAction("remove-temp", "", noop))
+	wf.AddStage(cleanup)
+	wf.DisableStage("cleanup")
+
+	if err := wf.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	dot := wf.ToDOT()
+
+	expected := []string{
+		`digraph "deploy" {`,
+		`subgraph "cluster_prepare" {`,
+		`subgraph "cluster_node-2" {`,
+		`subgraph "cluster_flash" {`,
+		`subgraph "cluster_cleanup" {`,
+		`"prepare/download" [label="download", style="rounded"];`,
+		`"prepare/download" -> "prepare/generate";`,
+		`"node-2/flash-node-2"`,
+		// Dynamic stage is dashed, disabled elements are greyed out
+		`style="dashed";`,
+		`"flash/verify" [label="verify", fillcolor=lightgrey, fontcolor=grey, style="rounded,filled"];`,
+		`style="filled";`,
+		// Stages are chained in execution order, including the generated one
+		`"prepare/download" -> "node-2/flash-node-2" [ltail="cluster_prepare", lhead="cluster_node-2"];`,
+		`"node-2/flash-node-2" -> "flash/flash-node-1" [ltail="cluster_node-2", lhead="cluster_flash"];`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output is missing %q\n%s", want, dot)
+		}
+	}
+}
+
+func TestWorkflowToDOTDependencies(t *testing.T) {
+	noop := func(ctx *gostage.ActionContext) error { return nil }
+
+	wf := NewWorkflow("dag", "DAG", "Workflow with stage dependencies")
+	for _, id := range []string{"a", "b", "c"} {
+		stage := NewStage(id, strings.ToUpper(id), "")
+		stage.AddAction(workflows.NewFuncAction("run-"+id, "", noop))
+		wf.AddStage(stage)
+	}
+
+	// c only depends on a, so there is no b -> c edge
+	if err := wf.Store.SetProperty(gostage.PrefixStage+"c", gostage.PropDependencies, []string{"a"}); err != nil {
+		t.Fatalf("SetProperty() error = %v", err)
+	}
+
+	dot := wf.ToDOT()
+	if !strings.Contains(dot, `"a/run-a" -> "c/run-c"`) {
+		t.Errorf("DOT output is missing the a -> c dependency\n%s", dot)
+	}
+	if strings.Contains(dot, `"b/run-b" -> "c/run-c"`) {
+		t.Errorf("DOT output should not chain b -> c\n%s", dot)
+	}
+}
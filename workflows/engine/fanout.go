@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/keys"
+)
+
+// NodeResult is the outcome of the workflow a fan-out ran for one node
+type NodeResult struct {
+	NodeID int
+	Report *Report
+	Err    error
+}
+
+// FanOut is a workflow running a workflow per node in parallel, such as
+// deploying the same image to every node of the board
+type FanOut struct {
+	*Workflow
+
+	nodeIDs []int
+
+	mu      sync.Mutex
+	results map[int]NodeResult
+	runner  *gostage.Runner
+}
+
+// seededKeys are copied from the fan-out store to the store of each node
+// workflow, as the provider injects them into the workflows it runs. Its
+// runner needs them to set up the node workflows. Each key maps to the
+// function reading its value; the tools and the state manager are handles,
+// shared with the node workflows rather than copied.
+var seededKeys = map[string]func(s *store.KVStore, key string) (interface{}, error){
+	keys.TargetCluster: getAs[string],
+	keys.ClusterIndex:  getAs[int],
+	keys.ToolsProvider: getAs[interface{}],
+	keys.StateManager:  getAs[interface{}],
+}
+
+// getAs reads the value of type T stored under key
+func getAs[T any](s *store.KVStore, key string) (interface{}, error) {
+	return store.Get[T](s, key)
+}
+
+// FanOutWorkflow creates a workflow running the workflow built by base for
+// each node, at most maxConcurrency at a time (all at once when zero or
+// less). Node workflows are built anew on each execution and run with their
+// own store, seeded with the target cluster, the tools and the state manager
+// of the fan-out store and with their node under keys.CurrentNodeID, unless
+// the node workflow set them. The execution fails if any node workflow fails,
+// once all of them have completed.
+func FanOutWorkflow(base func(nodeID int) *Workflow, nodeIDs []int, maxConcurrency int) *FanOut {
+	f := &FanOut{
+		Workflow: NewWorkflow("fan-out", "Fan-out", fmt.Sprintf("Run a workflow on nodes %v", nodeIDs)),
+		nodeIDs:  append([]int(nil), nodeIDs...),
+		results:  make(map[int]NodeResult),
+	}
+
+	actions := make([]gostage.Action, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		actions[i] = &nodeWorkflowAction{
+			BaseAction: gostage.NewBaseAction(fmt.Sprintf("node-%d", nodeID), fmt.Sprintf("Run the workflow of node %d", nodeID)),
+			nodeID:     nodeID,
+			base:       base,
+			fanOut:     f,
+		}
+	}
+
+	stage := NewStage("nodes", "Nodes", "Run the node workflows")
+	stage.AddAction(NewParallelAction("run-nodes", "Run the node workflows in parallel", actions...).
+		WithMaxConcurrency(maxConcurrency))
+	f.AddStage(stage)
+	return f
+}
+
+// Execute runs the node workflows with a default gostage runner
+func (f *FanOut) Execute(ctx context.Context, logger gostage.Logger) error {
+	return f.ExecuteWith(ctx, gostage.NewRunner(), logger)
+}
+
+// ExecuteWith runs the fan-out and each node workflow with the given runner,
+// so that its middleware sets up the node workflows too
+func (f *FanOut) ExecuteWith(ctx context.Context, runner *gostage.Runner, logger gostage.Logger) error {
+	f.mu.Lock()
+	f.results = make(map[int]NodeResult)
+	f.runner = runner
+	f.mu.Unlock()
+
+	return f.Workflow.ExecuteWith(ctx, runner, logger)
+}
+
+// Results returns the outcome of each node of the last execution, in the
+// order the nodes were given. Nodes whose workflow did not run are omitted.
+func (f *FanOut) Results() []NodeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	results := make([]NodeResult, 0, len(f.results))
+	for _, nodeID := range f.nodeIDs {
+		if result, ok := f.results[nodeID]; ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Report returns the report of the last execution aggregating the stages of
+// every node workflow, each stage ID prefixed with its node as in "node1.flash"
+func (f *FanOut) Report() *Report {
+	outer := f.Workflow.Report()
+	outer.mu.Lock()
+	aggregate := &Report{
+		WorkflowID: outer.WorkflowID,
+		Name:       outer.Name,
+		Status:     outer.Status,
+		Error:      outer.Error,
+		Started:    outer.Started,
+		Duration:   outer.Duration,
+	}
+	outer.mu.Unlock()
+
+	for _, result := range f.Results() {
+		result.Report.mu.Lock()
+		for _, stage := range result.Report.Stages {
+			stage.mu.Lock()
+			aggregate.Stages = append(aggregate.Stages, &StageReport{
+				ID:       fmt.Sprintf("node%d.%s", result.NodeID, stage.ID),
+				Name:     fmt.Sprintf("Node %d: %s", result.NodeID, stage.Name),
+				Status:   stage.Status,
+				Error:    stage.Error,
+				Started:  stage.Started,
+				Duration: stage.Duration,
+				Actions:  append([]*ActionReport(nil), stage.Actions...),
+			})
+			stage.mu.Unlock()
+		}
+		result.Report.mu.Unlock()
+	}
+	return aggregate
+}
+
+// nodeRunner returns the runner of the current execution
+func (f *FanOut) nodeRunner() *gostage.Runner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.runner == nil {
+		return gostage.NewRunner()
+	}
+	return f.runner
+}
+
+// record stores the outcome of a node workflow
+func (f *FanOut) record(result NodeResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[result.NodeID] = result
+}
+
+// nodeWorkflowAction runs the workflow of one node of a fan-out
+type nodeWorkflowAction struct {
+	gostage.BaseAction
+
+	nodeID int
+	base   func(nodeID int) *Workflow
+	fanOut *FanOut
+}
+
+// Execute builds, seeds and runs the node workflow
+func (a *nodeWorkflowAction) Execute(ctx *gostage.ActionContext) error {
+	workflow := a.base(a.nodeID)
+	if err := seedNodeStore(ctx.Workflow.Store, workflow.Store, a.nodeID); err != nil {
+		return fmt.Errorf("failed to seed the store of node %d: %w", a.nodeID, err)
+	}
+	err := workflow.ExecuteWith(ctx.GoContext, a.fanOut.nodeRunner(), ctx.Logger)
+	a.fanOut.record(NodeResult{NodeID: a.nodeID, Report: workflow.Report(), Err: err})
+	return err
+}
+
+// seedNodeStore copies the seeded keys of the fan-out store to the store of a
+// node workflow and stores its node, keeping the keys it already has
+func seedNodeStore(outer, node *store.KVStore, nodeID int) error {
+	values := map[string]interface{}{keys.CurrentNodeID: nodeID}
+	if outer != nil {
+		for key, get := range seededKeys {
+			if value, err := get(outer, key); err == nil {
+				values[key] = value
+			}
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, key := range node.ListKeys() {
+		existing[key] = true
+	}
+	for key, value := range values {
+		if existing[key] {
+			continue
+		}
+		if err := node.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
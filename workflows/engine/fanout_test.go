@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/keys"
+	"github.com/davidroman0O/turingpi/workflows"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+func TestFanOutWorkflow(t *testing.T) {
+	var mu sync.Mutex
+	ran := make(map[int]bool)
+	running, maxRunning := 0, 0
+
+	// The per-node workflow flashes its node, failing on node 3
+	deploy := func(nodeID int) *Workflow {
+		wf := NewWorkflow(fmt.Sprintf("deploy-node%d", nodeID), "Deploy", "Deploy a node")
+		stage := NewStage("flash", "Flash", "Flash the node")
+		stage.AddAction(workflows.NewFuncAction("write-image", "", func(ctx *gostage.ActionContext) error {
+			mu.Lock()
+			ran[nodeID] = true
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			if nodeID == 3 {
+				return errors.New("eMMC not detected")
+			}
+			return nil
+		}))
+		wf.AddStage(stage)
+		return wf
+	}
+
+	fanOut := FanOutWorkflow(deploy, []int{1, 2, 3, 4}, 2)
+	err := fanOut.Execute(context.Background(), nil)
+	if err == nil {
+		t.Fatal("Execute() expected the failure of node 3")
+	}
+
+	if len(ran) != 4 {
+		t.Errorf("Nodes run = %v, want all four", ran)
+	}
+	if maxRunning > 2 {
+		t.Errorf("%d node workflows ran at once, want at most 2", maxRunning)
+	}
+
+	results := fanOut.Results()
+	if len(results) != 4 {
+		t.Fatalf("Results() = %d entries, want 4", len(results))
+	}
+	for i, result := range results {
+		if result.NodeID != i+1 {
+			t.Errorf("Result %d is for node %d", i, result.NodeID)
+		}
+		if failed := result.Err != nil; failed != (result.NodeID == 3) || result.Report.Success() == failed {
+			t.Errorf("Node %d result = %v, success %v", result.NodeID, result.Err, result.Report.Success())
+		}
+	}
+
+	report := fanOut.Report()
+	if report.Status != gostage.StatusFailed {
+		t.Errorf("Aggregate status = %s, want failed", report.Status)
+	}
+	statuses := make(map[string]string)
+	for _, stage := range report.Stages {
+		statuses[stage.ID] = stage.Status
+	}
+	want := map[string]string{
+		"node1.flash": gostage.StatusCompleted,
+		"node2.flash": gostage.StatusCompleted,
+		"node3.flash": gostage.StatusFailed,
+		"node4.flash": gostage.StatusCompleted,
+	}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Errorf("Aggregate stages = %v, want %v", statuses, want)
+	}
+	if failures := report.Failures(); len(failures) != 1 || failures[0].Name != "write-image" {
+		t.Errorf("Failures() = %v", failures)
+	}
+
+	// A new execution starts with fresh node workflows and results
+	ran = make(map[int]bool)
+	fanOut.Execute(context.Background(), nil)
+	if len(ran) != 4 || len(fanOut.Results()) != 4 || len(fanOut.Report().Stages) != 4 {
+		t.Errorf("Second execution ran %v with %d results", ran, len(fanOut.Results()))
+	}
+}
+
+func TestFanOutRunnerAndSeeding(t *testing.T) {
+	var mu sync.Mutex
+	nodes := make(map[int]string)
+
+	// Like the provider's, the middleware needs the target cluster of every
+	// workflow it runs
+	runner := gostage.NewRunner()
+	runner.Use(func(next gostage.RunnerFunc) gostage.RunnerFunc {
+		return func(ctx context.Context, w *gostage.Workflow, logger gostage.Logger) error {
+			cluster, err := kvstore.Get[string](w.Store, keys.TargetCluster)
+			if err != nil {
+				return fmt.Errorf("workflow %s has no target cluster: %w", w.ID, err)
+			}
+			if nodeID, err := kvstore.Get[int](w.Store, keys.CurrentNodeID); err == nil {
+				mu.Lock()
+				nodes[nodeID] = cluster
+				mu.Unlock()
+			}
+			return next(ctx, w, logger)
+		}
+	})
+
+	// The tools are a handle the node workflows share
+	type toolsHandle struct{ name string }
+	handle := &toolsHandle{name: "tools"}
+
+	deploy := func(nodeID int) *Workflow {
+		wf := NewWorkflow(fmt.Sprintf("deploy-node%d", nodeID), "Deploy", "Deploy a node")
+		stage := NewStage("flash", "Flash", "Flash the node")
+		stage.AddAction(workflows.NewFuncAction("check-tools", "", func(ctx *gostage.ActionContext) error {
+			seeded, err := store.Get[*toolsHandle](ctx.Store(), keys.ToolsProvider)
+			if err == nil && seeded != handle {
+				return errors.New("the tools were copied")
+			}
+			return err
+		}))
+		wf.AddStage(stage)
+		return wf
+	}
+
+	fanOut := FanOutWorkflow(deploy, []int{1, 2}, 0)
+	fanOut.Store.Put(keys.TargetCluster, "home")
+	fanOut.Store.Put(keys.ToolsProvider, handle)
+	fanOut.Store.Put(keys.CurrentNodeID, 4)
+
+	if err := fanOut.ExecuteWith(context.Background(), runner, nil); err != nil {
+		t.Fatalf("ExecuteWith() error = %v", err)
+	}
+	if want := map[int]string{1: "home", 2: "home", 4: "home"}; fmt.Sprint(nodes) != fmt.Sprint(want) {
+		t.Errorf("Workflows run by the runner = %v, want %v", nodes, want)
+	}
+}
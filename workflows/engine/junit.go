@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the test cases of one stage
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	ID        string          `xml:"id,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemErr string          `xml:"system-err,omitempty"`
+}
+
+// junitTestCase is the outcome of one action
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitFailure describes why an action failed
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ToJUnitXML renders the report in the JUnit XML format understood by CI
+// systems. Each stage becomes a testsuite and each action a testcase, so
+// failed actions show up as failed tests with their error message.
+func (r *Report) ToJUnitXML() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	root := junitTestSuites{
+		Name: r.Name,
+		Time: junitSeconds(r.Duration),
+	}
+
+	for _, stage := range r.Stages {
+		suite := r.junitSuite(stage)
+		root.Tests += suite.Tests
+		root.Failures += suite.Failures
+		root.Skipped += suite.Skipped
+		root.Suites = append(root.Suites, suite)
+	}
+
+	output, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(output, '\n')...), nil
+}
+
+// junitSuite converts a stage report into a testsuite
+func (r *Report) junitSuite(stage *StageReport) junitTestSuite {
+	stage.mu.Lock()
+	defer stage.mu.Unlock()
+
+	suite := junitTestSuite{
+		Name:      stage.Name,
+		ID:        stage.ID,
+		Time:      junitSeconds(stage.Duration),
+		Timestamp: stage.Started.UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	actionFailed := false
+	for _, action := range stage.Actions {
+		testCase := junitTestCase{
+			Name:      action.Name,
+			ClassName: r.WorkflowID + "." + stage.ID,
+			Time:      junitSeconds(action.Duration),
+			SystemOut: action.Output,
+		}
+
+		switch action.Status {
+		case gostage.StatusFailed:
+			message := "action failed"
+			if action.Error != nil {
+				message = action.Error.Error()
+			}
+			testCase.Failure = &junitFailure{
+				Message: message,
+				Type:    fmt.Sprintf("%T", action.Error),
+				Text:    message,
+			}
+			suite.Failures++
+			actionFailed = true
+		case gostage.StatusSkipped, StatusPaused:
+			testCase.Skipped = &struct{}{}
+			suite.Skipped++
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+	}
+
+	// A stage can fail outside of its actions, keep that error visible
+	if stage.Error != nil && !actionFailed {
+		suite.SystemErr = stage.Error.Error()
+	}
+
+	return suite
+}
+
+// junitSeconds formats a duration as JUnit expects, in seconds
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+func TestReportToJUnitXML(t *testing.T) {
+	wf := NewWorkflow("provision", "Provision", "Workflow with mixed results")
+
+	prepare := NewStage("prepare", "Prepare", "Preparation stage")
+	prepare.AddAction(workflows.NewFuncAction("check-power", "", func(ctx *gostage.ActionContext) error {
+		ctx.DisableAction("reset-node")
+		return nil
+	}))
+	prepare.AddAction(workflows.NewFuncAction("reset-node", "", func(ctx *gostage.ActionContext) error {
+		return errors.New("should not run")
+	}))
+	prepare.AddAction(workflows.NewFuncAction("upload-image", "", func(ctx *gostage.ActionContext) error {
+		return errors.New("BMC disk full")
+	}))
+	prepare.SetContinueOnError(true)
+	wf.AddStage(prepare)
+
+	flash := NewStage("flash", "Flash", "Flashing stage")
+	flash.AddAction(workflows.NewFuncAction("flash-node", "", func(ctx *gostage.ActionContext) error {
+		return nil
+	}))
+	wf.AddStage(flash)
+
+	cleanup := NewStage("cleanup", "Cleanup", "Disabled stage")
+	cleanup.AddAction(workflows.NewFuncAction("remove-image", "", func(ctx *gostage.ActionContext) error {
+		return errors.New("should not run")
+	}))
+	wf.AddStage(cleanup)
+	wf.DisableStage("cleanup")
+
+	if err := wf.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	output, err := wf.Report().ToJUnitXML()
+	if err != nil {
+		t.Fatalf("ToJUnitXML() error = %v", err)
+	}
+	if !strings.HasPrefix(string(output), xml.Header) {
+		t.Error("JUnit report does not start with an XML header")
+	}
+
+	var parsed struct {
+		XMLName  xml.Name `xml:"testsuites"`
+		Name     string   `xml:"name,attr"`
+		Tests    int      `xml:"tests,attr"`
+		Failures int      `xml:"failures,attr"`
+		Skipped  int      `xml:"skipped,attr"`
+		Suites   []struct {
+			Name     string `xml:"name,attr"`
+			Tests    int    `xml:"tests,attr"`
+			Failures int    `xml:"failures,attr"`
+			Skipped  int    `xml:"skipped,attr"`
+			Cases    []struct {
+				Name      string  `xml:"name,attr"`
+				ClassName string  `xml:"classname,attr"`
+				Time      *string `xml:"time,attr"`
+				Failure   *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+				Skipped *struct{} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(output, &parsed); err != nil {
+		t.Fatalf("JUnit report is not valid XML: %v\n%s", err, output)
+	}
+
+	if parsed.Name != "Provision" || parsed.Tests != 5 || parsed.Failures != 1 || parsed.Skipped != 2 {
+		t.Errorf("testsuites = name %q, tests %d, failures %d, skipped %d, want Provision, 5, 1, 2",
+			parsed.Name, parsed.Tests, parsed.Failures, parsed.Skipped)
+	}
+	if len(parsed.Suites) != 3 {
+		t.Fatalf("Got %d testsuites, want 3", len(parsed.Suites))
+	}
+
+	prepareSuite := parsed.Suites[0]
+	if prepareSuite.Name != "Prepare" || prepareSuite.Tests != 3 || prepareSuite.Failures != 1 || prepareSuite.Skipped != 1 {
+		t.Errorf("Prepare testsuite = %+v", prepareSuite)
+	}
+
+	cases := make(map[string]int)
+	for i, testCase := range prepareSuite.Cases {
+		cases[testCase.Name] = i
+		if testCase.ClassName != "provision.prepare" || testCase.Time == nil {
+			t.Errorf("Testcase %s has classname %q and time %v", testCase.Name, testCase.ClassName, testCase.Time)
+		}
+	}
+	if c := prepareSuite.Cases[cases["check-power"]]; c.Failure != nil || c.Skipped != nil {
+		t.Errorf("check-power should pass: %+v", c)
+	}
+	if c := prepareSuite.Cases[cases["reset-node"]]; c.Skipped == nil {
+		t.Errorf("reset-node should be skipped: %+v", c)
+	}
+	if c := prepareSuite.Cases[cases["upload-image"]]; c.Failure == nil || c.Failure.Message != "BMC disk full" {
+		t.Errorf("upload-image should fail with its error message: %+v", c)
+	}
+
+	if suite := parsed.Suites[1]; suite.Tests != 1 || suite.Failures != 0 || suite.Cases[0].Name != "flash-node" {
+		t.Errorf("Flash testsuite = %+v", suite)
+	}
+	if suite := parsed.Suites[2]; suite.Tests != 1 || suite.Skipped != 1 || suite.Cases[0].Skipped == nil {
+		t.Errorf("Disabled Cleanup testsuite = %+v", suite)
+	}
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ActionGenerator produces the actions of a lazy stage when it is reached,
+// reading what earlier stages discovered from the workflow store
+type ActionGenerator func(ctx *gostage.ActionContext) ([]gostage.Action, error)
+
+// NewLazyStage creates a stage whose actions are produced by generate each
+// time the stage is reached, such as one action per node an earlier stage
+// discovered. They run after the actions added with AddAction, and are
+// dropped once the stage has run. A failing generator fails the stage before
+// any of its actions runs, and is handled by the error policies like a
+// failing action named "generate-actions": with ContinueOnError or CollectAll
+// the workflow goes on with the next stage. Resuming a paused lazy stage generates its actions
+// again, so the generator must produce the same actions for the same store.
+func NewLazyStage(id, name, description string, generate ActionGenerator) *Stage {
+	stage := NewStage(id, name, description)
+	stage.generate = generate
+	return stage
+}
+
+// generateActions appends the actions produced by the generator of a lazy
+// stage to its actions and returns the function restoring those it had
+func generateActions(ctx context.Context, opts *Stage, workflow *gostage.Workflow, logger gostage.Logger) (restore func(), err error) {
+	stage := opts.Stage
+	if opts.generate == nil {
+		return func() {}, nil
+	}
+
+	actionCtx := &gostage.ActionContext{
+		GoContext: ctx,
+		Workflow:  workflow,
+		Stage:     stage,
+		Logger:    logger,
+	}
+	generated, err := opts.generate(actionCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	static := stage.Actions
+	stage.Actions = append(append([]gostage.Action(nil), static...), generated...)
+	return func() { stage.Actions = static }, nil
+}
+
+// generatorAction stands for the generator of a lazy stage in the failures
+// the error policies handle
+type generatorAction struct {
+	gostage.BaseAction
+}
+
+func newGeneratorAction(stage *Stage) *generatorAction {
+	return &generatorAction{
+		BaseAction: gostage.NewBaseAction("generate-actions", fmt.Sprintf("Generate the actions of stage '%s'", stage.ID)),
+	}
+}
+
+// Execute implements gostage.Action. The generator runs before the stage's
+// actions, see generateActions, never as one of them.
+func (a *generatorAction) Execute(ctx *gostage.ActionContext) error {
+	return nil
+}
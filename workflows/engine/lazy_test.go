@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildLazyWorkflow creates a workflow whose first stage discovers nodes
+// and whose lazy second stage configures each of them
+func buildLazyWorkflow(nodes []int, configured *[]string) (*Workflow, *Stage) {
+	var mu sync.Mutex
+
+	discover := NewStage("discover", "Discover", "Discover the nodes")
+	discover.AddAction(workflows.NewFuncAction("scan", "", func(ctx *gostage.ActionContext) error {
+		return ctx.Store().Put("nodes", nodes)
+	}))
+
+	configure := NewLazyStage("configure", "Configure", "Configure each node", func(ctx *gostage.ActionContext) ([]gostage.Action, error) {
+		nodes, err := store.Get[[]int](ctx.Store(), "nodes")
+		if err != nil {
+			return nil, err
+		}
+		actions := make([]gostage.Action, 0, len(nodes))
+		for _, node := range nodes {
+			name := fmt.Sprintf("configure-node%d", node)
+			actions = append(actions, workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+				mu.Lock()
+				defer mu.Unlock()
+				*configured = append(*configured, name)
+				return nil
+			}))
+		}
+		return actions, nil
+	})
+	configure.AddAction(workflows.NewFuncAction("prepare", "", func(ctx *gostage.ActionContext) error {
+		*configured = append(*configured, "prepare")
+		return nil
+	}))
+
+	wf := NewWorkflow("provision", "Provision", "Provision discovered nodes")
+	wf.AddStage(discover)
+	wf.AddStage(configure)
+	return wf, configure
+}
+
+func TestLazyStage(t *testing.T) {
+	t.Run("Generated actions run", func(t *testing.T) {
+		var configured []string
+		wf, configure := buildLazyWorkflow([]int{1, 2, 4}, &configured)
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		want := []string{"prepare", "configure-node1", "configure-node2", "configure-node4"}
+		if !reflect.DeepEqual(configured, want) {
+			t.Errorf("Ran %v, want %v", configured, want)
+		}
+
+		var reported []string
+		for _, stage := range wf.Report().Stages {
+			if stage.ID != "configure" {
+				continue
+			}
+			for _, action := range stage.Actions {
+				reported = append(reported, action.Name)
+			}
+		}
+		sort.Strings(reported)
+		sortedWant := append([]string(nil), want...)
+		sort.Strings(sortedWant)
+		if !reflect.DeepEqual(reported, sortedWant) {
+			t.Errorf("Report lists %v, want %v", reported, sortedWant)
+		}
+
+		if len(configure.Actions) != 1 {
+			t.Errorf("Stage keeps %d actions after running, want the static one", len(configure.Actions))
+		}
+	})
+
+	t.Run("Generated again on each execution", func(t *testing.T) {
+		var configured []string
+		wf, _ := buildLazyWorkflow([]int{3}, &configured)
+		for i := 0; i < 2; i++ {
+			if err := wf.Execute(context.Background(), nil); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+		}
+		want := []string{"prepare", "configure-node3", "prepare", "configure-node3"}
+		if !reflect.DeepEqual(configured, want) {
+			t.Errorf("Ran %v, want %v", configured, want)
+		}
+	})
+
+	t.Run("Generator failure fails the stage", func(t *testing.T) {
+		var configured []string
+		wf, _ := buildLazyWorkflow(nil, &configured)
+		wf.Stages[0].Actions = nil // Nothing discovers the nodes
+
+		err := wf.Execute(context.Background(), nil)
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Execute() error = %v, want the generator failure", err)
+		}
+		var wfErr *WorkflowError
+		if !errors.As(err, &wfErr) || wfErr.StageID != "configure" || wfErr.Action != "generate-actions" {
+			t.Errorf("Execute() error = %v, want a WorkflowError of the generator", err)
+		}
+		if len(configured) != 0 {
+			t.Errorf("Ran %v although the generator failed", configured)
+		}
+	})
+
+	t.Run("Generator failure follows the error policies", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			configure func(wf *Workflow, lazy *Stage)
+			wantErr   bool
+		}{
+			{"Continue on error", func(wf *Workflow, lazy *Stage) { lazy.SetContinueOnError(true) }, false},
+			{"Collect all", func(wf *Workflow, lazy *Stage) { wf.SetErrorMode(CollectAll) }, true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var configured []string
+				wf, lazy := buildLazyWorkflow(nil, &configured)
+				wf.Stages[0].Actions = nil
+				report := NewStage("report", "Report", "Report the configuration")
+				report.AddAction(workflows.NewFuncAction("summary", "", func(ctx *gostage.ActionContext) error {
+					configured = append(configured, "summary")
+					return nil
+				}))
+				wf.AddStage(report)
+				tt.configure(wf, lazy)
+
+				err := wf.Execute(context.Background(), nil)
+				var multi *MultiError
+				if tt.wantErr && (!errors.As(err, &multi) || !errors.Is(err, store.ErrNotFound)) {
+					t.Errorf("Execute() error = %v, want the generator failure collected", err)
+				}
+				if !tt.wantErr && err != nil {
+					t.Errorf("Execute() error = %v, want the failure tolerated", err)
+				}
+				if !reflect.DeepEqual(configured, []string{"summary"}) {
+					t.Errorf("Ran %v, want only the next stage", configured)
+				}
+				if stage := wf.Report().Stages[1]; stage.Status != gostage.StatusFailed {
+					t.Errorf("Lazy stage status = %s, want failed", stage.Status)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ErrLimitExceeded is returned when a workflow grows beyond its Limits
+var ErrLimitExceeded = errors.New("workflow limit exceeded")
+
+// Limits bounds how much a workflow may grow while running, so a buggy action
+// generating dynamic stages in a loop aborts the workflow instead of running forever.
+// A zero value disables the corresponding limit.
+type Limits struct {
+	// MaxDynamicStages is the total number of dynamic stages an execution may generate
+	MaxDynamicStages int
+	// MaxDepth is how deeply dynamic stages may be nested: stages of the workflow
+	// are at depth 0 and a dynamic stage is one level deeper than its parent
+	MaxDepth int
+}
+
+// DefaultLimits are applied to every workflow unless SetLimits is called
+var DefaultLimits = Limits{
+	MaxDynamicStages: 1000,
+	MaxDepth:         50,
+}
+
+// SetLimits sets the limits enforced on the workflow's dynamic growth
+func (w *Workflow) SetLimits(limits Limits) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.limits = limits
+}
+
+// Limits returns the configured limits
+func (w *Workflow) Limits() Limits {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.limits
+}
+
+// checkDynamicStages accounts for the dynamic stages a stage generated, which
+// the runner inserts once the stage returns, and fails if they exceed the limits
+func (w *Workflow) checkDynamicStages(stage *gostage.Stage, workflow *gostage.Workflow) error {
+	generated, _ := workflow.Context["dynamicStages"].([]*gostage.Stage)
+	if len(generated) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	depth := w.stageDepth[stage.ID] + 1
+	if w.limits.MaxDepth > 0 && depth > w.limits.MaxDepth {
+		return fmt.Errorf("%w: stage '%s' generated stages at depth %d, the maximum is %d",
+			ErrLimitExceeded, stage.ID, depth, w.limits.MaxDepth)
+	}
+
+	w.dynamicStages += len(generated)
+	if w.limits.MaxDynamicStages > 0 && w.dynamicStages > w.limits.MaxDynamicStages {
+		return fmt.Errorf("%w: %d dynamic stages generated, the maximum is %d",
+			ErrLimitExceeded, w.dynamicStages, w.limits.MaxDynamicStages)
+	}
+
+	for _, s := range generated {
+		w.stageDepth[s.ID] = depth
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// newRunawayStage creates a stage whose action generates perStage new stages
+// like itself every time it runs, and counts the stages that ran
+func newRunawayStage(id string, perStage int, ran *int) *Stage {
+	stage := NewStage(id, "Runaway "+id, "Stage generating more stages")
+	stage.AddAction(workflows.NewFuncAction("expand-"+id, "", func(ctx *gostage.ActionContext) error {
+		*ran++
+		for i := 0; i < perStage; i++ {
+			ctx.AddDynamicStage(newRunawayStage(fmt.Sprintf("%s.%d", id, i), perStage, ran).Stage)
+		}
+		return nil
+	}))
+	return stage
+}
+
+func TestWorkflowLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   Limits
+		perStage int
+		wantRan  int
+	}{
+		{"Total dynamic stages", Limits{MaxDynamicStages: 5}, 1, 6},
+		{"Many stages at once", Limits{MaxDynamicStages: 5}, 10, 1},
+		{"Depth", Limits{MaxDepth: 3}, 1, 4},
+		{"Default limits", DefaultLimits, 1, DefaultLimits.MaxDepth + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran int
+			wf := NewWorkflow("runaway", "Runaway", "Workflow expanding forever")
+			wf.AddStage(newRunawayStage("root", tt.perStage, &ran))
+			wf.SetLimits(tt.limits)
+
+			err := wf.Execute(context.Background(), nil)
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("Execute() error = %v, want ErrLimitExceeded", err)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("Ran %d stages, want %d", ran, tt.wantRan)
+			}
+		})
+	}
+
+	t.Run("Within limits", func(t *testing.T) {
+		var ran int
+		wf := NewWorkflow("bounded", "Bounded", "Workflow expanding once")
+		stage := NewStage("root", "Root", "Stage generating two stages")
+		stage.AddAction(workflows.NewFuncAction("expand", "", func(ctx *gostage.ActionContext) error {
+			for i := 0; i < 2; i++ {
+				leaf := NewStage(fmt.Sprintf("leaf-%d", i), "Leaf", "Generated stage")
+				leaf.AddAction(workflows.NewFuncAction(fmt.Sprintf("leaf-%d", i), "", func(ctx *gostage.ActionContext) error {
+					ran++
+					return nil
+				}))
+				ctx.AddDynamicStage(leaf.Stage)
+			}
+			return nil
+		}))
+		wf.AddStage(stage)
+		wf.SetLimits(Limits{MaxDynamicStages: 2, MaxDepth: 1})
+
+		// Limits are counted per execution
+		for i := 0; i < 2; i++ {
+			wf.Stages = wf.Stages[:1]
+			if err := wf.Execute(context.Background(), nil); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+		}
+		if ran != 4 {
+			t.Errorf("Ran %d generated stages, want 4", ran)
+		}
+	})
+}
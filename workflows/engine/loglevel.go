@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// LogLevel is the minimum severity of the lines an action logs that reach
+// the workflow logger
+type LogLevel int
+
+const (
+	// LogDefault leaves the level to the enclosing scope: a stage inherits the
+	// workflow level, and a workflow passes every line on
+	LogDefault LogLevel = iota
+	// LogDebug passes every line on
+	LogDebug
+	// LogInfo drops debug lines
+	LogInfo
+	// LogWarn passes warnings and errors only
+	LogWarn
+	// LogError passes errors only
+	LogError
+)
+
+// String returns the name of the log level
+func (l LogLevel) String() string {
+	switch l {
+	case LogDefault:
+		return "default"
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+// ParseLogLevel returns the log level named by s, ignoring case.
+// "warning" is accepted for LogWarn and an empty name is LogDefault.
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "default":
+		return LogDefault, nil
+	case "debug":
+		return LogDebug, nil
+	case "info":
+		return LogInfo, nil
+	case "warn", "warning":
+		return LogWarn, nil
+	case "error":
+		return LogError, nil
+	default:
+		return LogDefault, fmt.Errorf("unknown log level: %q", s)
+	}
+}
+
+// levelLogger passes on to next the lines at or above its level
+type levelLogger struct {
+	next  gostage.Logger
+	level LogLevel
+}
+
+// Debug implements gostage.Logger
+func (l *levelLogger) Debug(format string, args ...interface{}) {
+	if l.level <= LogDebug {
+		l.next.Debug(format, args...)
+	}
+}
+
+// Info implements gostage.Logger
+func (l *levelLogger) Info(format string, args ...interface{}) {
+	if l.level <= LogInfo {
+		l.next.Info(format, args...)
+	}
+}
+
+// Warn implements gostage.Logger
+func (l *levelLogger) Warn(format string, args ...interface{}) {
+	if l.level <= LogWarn {
+		l.next.Warn(format, args...)
+	}
+}
+
+// Error implements gostage.Logger
+func (l *levelLogger) Error(format string, args ...interface{}) {
+	l.next.Error(format, args...)
+}
+
+// SetLogLevel sets the default level of the lines actions log, which stages
+// may override with Stage.SetLogLevel
+func (w *Workflow) SetLogLevel(level LogLevel) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.logLevel = level
+}
+
+// LogLevel returns the default log level of the workflow's actions
+func (w *Workflow) LogLevel() LogLevel {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.logLevel
+}
+
+// stageLogLevel returns the level applied to the actions of a stage
+func (w *Workflow) stageLogLevel(stage *Stage) LogLevel {
+	if level := stage.LogLevel(); level != LogDefault {
+		return level
+	}
+	return w.LogLevel()
+}
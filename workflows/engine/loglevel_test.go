@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildLevelWorkflow creates a workflow with a copy and a configure stage
+// whose actions log at every level
+func buildLevelWorkflow() (*Workflow, *Stage, *Stage) {
+	wf := NewWorkflow("levels", "Levels", "Workflow with stages of different verbosity")
+
+	chatty := func(name string) gostage.Action {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			ctx.Logger.Debug("%s debug", name)
+			ctx.Logger.Info("%s info", name)
+			ctx.Logger.Warn("%s warn", name)
+			ctx.Logger.Error("%s error", name)
+			return nil
+		})
+	}
+
+	files := NewStage("files", "Files", "Noisy file operations")
+	files.AddAction(chatty("copy"))
+	wf.AddStage(files)
+
+	configure := NewStage("configure", "Configure", "Stage worth following closely")
+	configure.AddAction(chatty("configure"))
+	wf.AddStage(configure)
+	return wf, files, configure
+}
+
+func TestStageLogLevel(t *testing.T) {
+	run := func(t *testing.T, wf *Workflow) string {
+		t.Helper()
+		logger := &recordingLogger{}
+		if err := wf.Execute(context.Background(), logger); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		var lines []string
+		for _, line := range logger.lines {
+			// Keep the lines of the test actions only
+			if fields := strings.Fields(line); len(fields) == 3 && (fields[1] == "copy" || fields[1] == "configure") {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, ",")
+	}
+
+	t.Run("Debug stage logs debug lines, info stage drops them", func(t *testing.T) {
+		wf, files, configure := buildLevelWorkflow()
+		files.SetLogLevel(LogInfo)
+		configure.SetLogLevel(LogDebug)
+
+		want := "INFO copy info,WARN copy warn,ERROR copy error," +
+			"DEBUG configure debug,INFO configure info,WARN configure warn,ERROR configure error"
+		if got := run(t, wf); got != want {
+			t.Errorf("Logged %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Stages override the workflow level", func(t *testing.T) {
+		wf, files, _ := buildLevelWorkflow()
+		wf.SetLogLevel(LogDebug)
+		files.SetLogLevel(LogError)
+
+		want := "ERROR copy error," +
+			"DEBUG configure debug,INFO configure info,WARN configure warn,ERROR configure error"
+		if got := run(t, wf); got != want {
+			t.Errorf("Logged %s, want %s", got, want)
+		}
+
+		// Back to the workflow level
+		files.SetLogLevel(LogDefault)
+		wf.SetLogLevel(LogWarn)
+		want = "WARN copy warn,ERROR copy error,WARN configure warn,ERROR configure error"
+		if got := run(t, wf); got != want {
+			t.Errorf("Logged %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Captured output follows the level", func(t *testing.T) {
+		wf, files, _ := buildLevelWorkflow()
+		files.SetLogLevel(LogWarn)
+		wf.SetCaptureOutput(true)
+		run(t, wf)
+
+		output := wf.Report().Stages[0].Actions[0].Output
+		if output != "[WARN] copy warn\n[ERROR] copy error\n" {
+			t.Errorf("Captured output = %q", output)
+		}
+	})
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, level := range []LogLevel{LogDefault, LogDebug, LogInfo, LogWarn, LogError} {
+		if parsed, err := ParseLogLevel(strings.ToUpper(level.String())); err != nil || parsed != level {
+			t.Errorf("ParseLogLevel(%s) = %v, %v", level, parsed, err)
+		}
+	}
+	if level, err := ParseLogLevel("warning"); err != nil || level != LogWarn {
+		t.Errorf("ParseLogLevel(warning) = %v, %v", level, err)
+	}
+	if _, err := ParseLogLevel("TRACE"); err == nil {
+		t.Error("ParseLogLevel() expected an error for an unknown level")
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// anchorTagPrefix tags a dynamic stage with the stage it must follow, see InsertAfter
+const anchorTagPrefix = "after:"
+
+// InsertAfter anchors a dynamic stage right after another stage generated by
+// the same stage. Dynamic stages are otherwise run in the order of their IDs.
+func InsertAfter(stage *gostage.Stage, anchorID string) *gostage.Stage {
+	stage.AddTag(anchorTagPrefix + anchorID)
+	return stage
+}
+
+// anchorOf returns the ID of the stage a dynamic stage is anchored after
+func anchorOf(stage *gostage.Stage) (string, bool) {
+	for _, tag := range stage.Tags {
+		if strings.HasPrefix(tag, anchorTagPrefix) {
+			return strings.TrimPrefix(tag, anchorTagPrefix), true
+		}
+	}
+	return "", false
+}
+
+// queueDynamicStages moves the stages generated by the last action out of the
+// workflow context, where the runner only keeps those of the latest action
+func (w *Workflow) queueDynamicStages(workflow *gostage.Workflow) {
+	generated, _ := workflow.Context["dynamicStages"].([]*gostage.Stage)
+	delete(workflow.Context, "dynamicStages")
+	if len(generated) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.queuedStages = append(w.queuedStages, generated...)
+}
+
+// releaseDynamicStages hands every stage generated while the stage ran back
+// to the runner, in a deterministic order, so it inserts them after the stage
+func (w *Workflow) releaseDynamicStages(workflow *gostage.Workflow) {
+	w.queueDynamicStages(workflow)
+
+	w.mu.Lock()
+	queued := w.queuedStages
+	w.queuedStages = nil
+	w.mu.Unlock()
+
+	if len(queued) > 0 {
+		workflow.Context["dynamicStages"] = orderDynamicStages(queued)
+	}
+}
+
+// orderDynamicStages sorts dynamic stages by ID, so actions generating them
+// from maps or concurrent discovery still run them in a reproducible order.
+// A stage anchored with InsertAfter follows its anchor instead.
+func orderDynamicStages(stages []*gostage.Stage) []*gostage.Stage {
+	sorted := append([]*gostage.Stage(nil), stages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	ids := make(map[string]bool, len(sorted))
+	for _, stage := range sorted {
+		ids[stage.ID] = true
+	}
+
+	var roots []*gostage.Stage
+	anchored := make(map[string][]*gostage.Stage)
+	for _, stage := range sorted {
+		if anchor, ok := anchorOf(stage); ok && anchor != stage.ID && ids[anchor] {
+			anchored[anchor] = append(anchored[anchor], stage)
+		} else {
+			roots = append(roots, stage)
+		}
+	}
+
+	ordered := make([]*gostage.Stage, 0, len(sorted))
+	placed := make(map[*gostage.Stage]bool, len(sorted))
+	var place func(stage *gostage.Stage)
+	place = func(stage *gostage.Stage) {
+		if placed[stage] {
+			return
+		}
+		placed[stage] = true
+		ordered = append(ordered, stage)
+		for _, next := range anchored[stage.ID] {
+			place(next)
+		}
+	}
+
+	for _, stage := range roots {
+		place(stage)
+	}
+	// Stages anchored to each other in a cycle are never reached from a root
+	for _, stage := range sorted {
+		place(stage)
+	}
+	return ordered
+}
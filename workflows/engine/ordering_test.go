@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildDiscoveryWorkflow creates a workflow whose discovery stage generates
+// one stage per resource by ranging over maps, like resource discovery does.
+// Each generated stage records its ID in ran when it executes.
+func buildDiscoveryWorkflow(ran *[]string, anchors map[string]string) *Workflow {
+	newResourceStage := func(id string) *gostage.Stage {
+		stage := gostage.NewStage(id, "Configure "+id, "Generated stage")
+		stage.AddAction(workflows.NewFuncAction("configure-"+id, "", func(ctx *gostage.ActionContext) error {
+			*ran = append(*ran, id)
+			return nil
+		}))
+		if anchor, ok := anchors[id]; ok {
+			InsertAfter(stage, anchor)
+		}
+		return stage
+	}
+
+	wf := NewWorkflow("discovery", "Discovery", "Workflow generating stages from discovered resources")
+	discover := NewStage("discover", "Discover", "Discover resources")
+	discover.AddAction(workflows.NewFuncAction("discover-storage", "", func(ctx *gostage.ActionContext) error {
+		for resource := range map[string]bool{"storage-nvme": true, "storage-emmc": true, "storage-sd": true} {
+			ctx.AddDynamicStage(newResourceStage(resource))
+		}
+		return nil
+	}))
+	discover.AddAction(workflows.NewFuncAction("discover-network", "", func(ctx *gostage.ActionContext) error {
+		for resource := range map[string]bool{"network-eth0": true, "network-wlan0": true, "network-usb0": true} {
+			ctx.AddDynamicStage(newResourceStage(resource))
+		}
+		return nil
+	}))
+	wf.AddStage(discover)
+
+	final := NewStage("report", "Report", "Report configured resources")
+	final.AddAction(workflows.NewFuncAction("report", "", func(ctx *gostage.ActionContext) error {
+		*ran = append(*ran, "report")
+		return nil
+	}))
+	wf.AddStage(final)
+	return wf
+}
+
+func TestDynamicStageOrdering(t *testing.T) {
+	t.Run("Same resources run in the same order", func(t *testing.T) {
+		want := []string{
+			"network-eth0", "network-usb0", "network-wlan0",
+			"storage-emmc", "storage-nvme", "storage-sd",
+			"report",
+		}
+		for run := 0; run < 20; run++ {
+			var ran []string
+			wf := buildDiscoveryWorkflow(&ran, nil)
+			if err := wf.Execute(context.Background(), nil); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(ran, want) {
+				t.Fatalf("Run %d executed %v, want %v", run, ran, want)
+			}
+		}
+	})
+
+	t.Run("Anchored stages follow their anchor", func(t *testing.T) {
+		anchors := map[string]string{
+			"network-eth0": "storage-sd",
+			"network-usb0": "network-eth0",
+			"storage-emmc": "missing-stage",
+		}
+		want := []string{
+			"network-wlan0", "storage-emmc", "storage-nvme",
+			"storage-sd", "network-eth0", "network-usb0",
+			"report",
+		}
+		for run := 0; run < 20; run++ {
+			var ran []string
+			wf := buildDiscoveryWorkflow(&ran, anchors)
+			if err := wf.Execute(context.Background(), nil); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(ran, want) {
+				t.Fatalf("Run %d executed %v, want %v", run, ran, want)
+			}
+		}
+	})
+}
+
+func TestOrderDynamicStagesCycle(t *testing.T) {
+	a := InsertAfter(gostage.NewStage("a", "A", ""), "b")
+	b := InsertAfter(gostage.NewStage("b", "B", ""), "a")
+	c := gostage.NewStage("c", "C", "")
+
+	var ids []string
+	for _, stage := range orderDynamicStages([]*gostage.Stage{c, b, a}) {
+		ids = append(ids, stage.ID)
+	}
+	if want := []string{"c", "a", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("orderDynamicStages() = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/cache"
+)
+
+// captureLogger records the lines an action logs while passing them on to
+// the workflow logger
+type captureLogger struct {
+	next gostage.Logger
+
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+// newCaptureLogger creates a logger recording lines before forwarding them to next
+func newCaptureLogger(next gostage.Logger) *captureLogger {
+	return &captureLogger{next: next}
+}
+
+// Debug implements gostage.Logger
+func (l *captureLogger) Debug(format string, args ...interface{}) {
+	l.record("DEBUG", format, args)
+	if l.next != nil {
+		l.next.Debug(format, args...)
+	}
+}
+
+// Info implements gostage.Logger
+func (l *captureLogger) Info(format string, args ...interface{}) {
+	l.record("INFO", format, args)
+	if l.next != nil {
+		l.next.Info(format, args...)
+	}
+}
+
+// Warn implements gostage.Logger
+func (l *captureLogger) Warn(format string, args ...interface{}) {
+	l.record("WARN", format, args)
+	if l.next != nil {
+		l.next.Warn(format, args...)
+	}
+}
+
+// Error implements gostage.Logger
+func (l *captureLogger) Error(format string, args ...interface{}) {
+	l.record("ERROR", format, args)
+	if l.next != nil {
+		l.next.Error(format, args...)
+	}
+}
+
+// record appends a formatted line to the captured output
+func (l *captureLogger) record(level, format string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(&l.buf, "[%s] %s\n", level, strings.TrimRight(fmt.Sprintf(format, args...), "\n"))
+}
+
+// String returns the captured output
+func (l *captureLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// SetCaptureOutput makes each action's logger output recorded in its
+// ActionReport, in addition to being passed to the workflow logger
+func (w *Workflow) SetCaptureOutput(capture bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.captureOutput = capture
+}
+
+// CaptureOutput reports whether action output is recorded in the report
+func (w *Workflow) CaptureOutput() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.captureOutput
+}
+
+// OutputKey returns the cache key ArchiveOutput stores the output of an action under
+func OutputKey(workflowID, stageID, action string) string {
+	return fmt.Sprintf("%s.%s.%s.log", workflowID, stageID, action)
+}
+
+// ArchiveOutput stores the captured output of every action in the cache,
+// under OutputKey and tagged with the workflow, stage and action, and
+// returns how many outputs were stored. Actions without output are skipped.
+func (r *Report) ArchiveOutput(ctx context.Context, c cache.Cache) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archived := 0
+	for _, stage := range r.Stages {
+		stage.mu.Lock()
+		actions := append([]*ActionReport(nil), stage.Actions...)
+		stage.mu.Unlock()
+
+		for _, action := range actions {
+			if action.Output == "" {
+				continue
+			}
+
+			key := OutputKey(r.WorkflowID, stage.ID, action.Name)
+			meta := cache.Metadata{
+				Filename:    key,
+				ContentType: "text/plain",
+				ModTime:     action.Started,
+				Tags: map[string]string{
+					"workflow": r.WorkflowID,
+					"stage":    stage.ID,
+					"action":   action.Name,
+				},
+			}
+			if _, err := c.Put(ctx, key, meta, strings.NewReader(action.Output)); err != nil {
+				return archived, fmt.Errorf("failed to archive output of action '%s': %w", action.Name, err)
+			}
+			archived++
+		}
+	}
+	return archived, nil
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/cache"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// recordingLogger keeps every line logged to it
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debug(format string, args ...interface{}) { l.log("DEBUG", format, args...) }
+func (l *recordingLogger) Info(format string, args ...interface{})  { l.log("INFO", format, args...) }
+func (l *recordingLogger) Warn(format string, args ...interface{})  { l.log("WARN", format, args...) }
+func (l *recordingLogger) Error(format string, args ...interface{}) { l.log("ERROR", format, args...) }
+
+// buildLoggingWorkflow creates a workflow whose actions each log lines
+func buildLoggingWorkflow() *Workflow {
+	wf := NewWorkflow("logging", "Logging", "Workflow with chatty actions")
+
+	flash := NewStage("flash", "Flash", "Flashing stage")
+	flash.AddAction(workflows.NewFuncAction("upload-image", "", func(ctx *gostage.ActionContext) error {
+		ctx.Logger.Info("uploading %s", "rk1.img")
+		ctx.Logger.Debug("sent %d bytes", 1024)
+		return nil
+	}))
+	flash.AddAction(workflows.NewFuncAction("quiet", "", func(ctx *gostage.ActionContext) error {
+		return nil
+	}))
+	flash.AddAction(workflows.NewFuncAction("flash-node", "", func(ctx *gostage.ActionContext) error {
+		ctx.Logger.Warn("node %d is powered on", 2)
+		return nil
+	}))
+	wf.AddStage(flash)
+	return wf
+}
+
+func TestCaptureOutput(t *testing.T) {
+	outputs := func(t *testing.T, wf *Workflow) map[string]string {
+		t.Helper()
+		got := make(map[string]string)
+		for _, stage := range wf.Report().Stages {
+			for _, action := range stage.Actions {
+				got[action.Name] = action.Output
+			}
+		}
+		return got
+	}
+
+	t.Run("Lines are captured under their action", func(t *testing.T) {
+		wf := buildLoggingWorkflow()
+		wf.SetCaptureOutput(true)
+		logger := &recordingLogger{}
+		if err := wf.Execute(context.Background(), logger); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+
+		got := outputs(t, wf)
+		if want := "[INFO] uploading rk1.img\n[DEBUG] sent 1024 bytes\n"; got["upload-image"] != want {
+			t.Errorf("upload-image output = %q, want %q", got["upload-image"], want)
+		}
+		if got["quiet"] != "" {
+			t.Errorf("quiet output = %q, want none", got["quiet"])
+		}
+		if want := "[WARN] node 2 is powered on\n"; got["flash-node"] != want {
+			t.Errorf("flash-node output = %q, want %q", got["flash-node"], want)
+		}
+
+		// Lines still reach the workflow logger
+		logged := strings.Join(logger.lines, "\n")
+		for _, line := range []string{"INFO uploading rk1.img", "WARN node 2 is powered on"} {
+			if !strings.Contains(logged, line) {
+				t.Errorf("Logger did not receive %q:\n%s", line, logged)
+			}
+		}
+	})
+
+	t.Run("Without a workflow logger", func(t *testing.T) {
+		wf := buildLoggingWorkflow()
+		wf.SetCaptureOutput(true)
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if got := outputs(t, wf)["flash-node"]; got != "[WARN] node 2 is powered on\n" {
+			t.Errorf("flash-node output = %q", got)
+		}
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		wf := buildLoggingWorkflow()
+		if wf.CaptureOutput() {
+			t.Error("CaptureOutput() = true, want false by default")
+		}
+		if err := wf.Execute(context.Background(), &recordingLogger{}); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		for name, output := range outputs(t, wf) {
+			if output != "" {
+				t.Errorf("%s output = %q, want none", name, output)
+			}
+		}
+	})
+
+	t.Run("Output is written to the JUnit report", func(t *testing.T) {
+		wf := buildLoggingWorkflow()
+		wf.SetCaptureOutput(true)
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		data, err := wf.Report().ToJUnitXML()
+		if err != nil {
+			t.Fatalf("ToJUnitXML() error = %v", err)
+		}
+		if !strings.Contains(string(data), "<system-out>[WARN] node 2 is powered on&#xA;</system-out>") {
+			t.Errorf("JUnit report lacks the captured output:\n%s", data)
+		}
+	})
+}
+
+func TestReportArchiveOutput(t *testing.T) {
+	ctx := context.Background()
+	wf := buildLoggingWorkflow()
+	wf.SetCaptureOutput(true)
+	if err := wf.Execute(ctx, nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	c, err := cache.NewFSCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create FSCache: %v", err)
+	}
+	defer c.Close()
+
+	archived, err := wf.Report().ArchiveOutput(ctx, c)
+	if err != nil {
+		t.Fatalf("ArchiveOutput() error = %v", err)
+	}
+	if archived != 2 {
+		t.Errorf("ArchiveOutput() archived %d outputs, want 2", archived)
+	}
+
+	key := OutputKey("logging", "flash", "upload-image")
+	meta, reader, err := c.Get(ctx, key, true)
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", key, err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	if string(data) != "[INFO] uploading rk1.img\n[DEBUG] sent 1024 bytes\n" {
+		t.Errorf("Archived output = %q", data)
+	}
+	if meta.Tags["action"] != "upload-image" || meta.Tags["stage"] != "flash" || meta.ContentType != "text/plain" {
+		t.Errorf("Archived metadata = %+v", meta)
+	}
+
+	if exists, _ := c.Exists(ctx, OutputKey("logging", "flash", "quiet")); exists {
+		t.Error("Action without output was archived")
+	}
+}
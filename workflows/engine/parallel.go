@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// ParallelAction runs several actions concurrently and completes once all of
+// them have. By default the actions share the workflow store and see each
+// other's writes as they happen. Actions declaring the same resource, see
+// ResourceLocker, run one after the other. Each action is bounded by its own
+// timeout only, see TimedAction. Actions run in parallel cannot add dynamic
+// actions or stages, nor disable actions or stages.
+type ParallelAction struct {
+	gostage.BaseAction
+
+	actions        []gostage.Action
+	isolated       bool
+	strategy       store.MergeStrategy
+	maxConcurrency int
+}
+
+// NewParallelAction creates an action running the given actions concurrently
+func NewParallelAction(name, description string, actions ...gostage.Action) *ParallelAction {
+	return &ParallelAction{
+		BaseAction: gostage.NewBaseAction(name, description),
+		actions:    actions,
+	}
+}
+
+// WithSnapshotIsolation makes each action work on its own snapshot of the
+// store taken when the parallel action starts, so no action sees partial
+// writes of another. Once all actions have completed, the writes of those
+// that succeeded are merged back in the order the actions were given, keys
+// written by several actions being resolved with the strategy, see
+// kvstore.MergeFork. The writes of failed actions are discarded.
+func (p *ParallelAction) WithSnapshotIsolation(strategy store.MergeStrategy) *ParallelAction {
+	p.isolated = true
+	p.strategy = strategy
+	return p
+}
+
+// WithMaxConcurrency bounds how many actions run at the same time, the
+// others waiting for a running action to complete. Zero or less runs all
+// actions at once (default).
+func (p *ParallelAction) WithMaxConcurrency(n int) *ParallelAction {
+	p.maxConcurrency = n
+	return p
+}
+
+// Actions returns the actions run in parallel
+func (p *ParallelAction) Actions() []gostage.Action {
+	return p.actions
+}
+
+// Execute runs the actions concurrently and returns their errors joined
+func (p *ParallelAction) Execute(ctx *gostage.ActionContext) error {
+	errs := make([]error, len(p.actions))
+	snapshots := make([]*store.KVStore, len(p.actions))
+
+	var base *store.KVStore
+	if p.isolated {
+		var err error
+		if base, err = kvstore.Fork(ctx.Workflow.Store); err != nil {
+			return err
+		}
+		for i := range snapshots {
+			if snapshots[i], err = kvstore.Fork(base); err != nil {
+				return err
+			}
+		}
+	}
+
+	var slots chan struct{}
+	if p.maxConcurrency > 0 {
+		slots = make(chan struct{}, p.maxConcurrency)
+	}
+
+	var wg sync.WaitGroup
+	for i, action := range p.actions {
+		actionCtx := &gostage.ActionContext{
+			GoContext:    ctx.GoContext,
+			Workflow:     ctx.Workflow,
+			Stage:        ctx.Stage,
+			Action:       action,
+			Logger:       ctx.Logger,
+			ActionIndex:  ctx.ActionIndex,
+			IsLastAction: ctx.IsLastAction,
+		}
+		if p.isolated {
+			workflow := *ctx.Workflow
+			workflow.Store = snapshots[i]
+			actionCtx.Workflow = &workflow
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Actions waiting for a resource do not hold a concurrency slot
+			unlock, err := lockResource(actionCtx, action)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer unlock()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			if err := checkPreconditions(actionCtx, action); err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = executeWithTimeout(actionCtx, action, 0)
+		}()
+	}
+	wg.Wait()
+
+	for i, action := range p.actions {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("action '%s': %w", action.Name(), errs[i])
+			continue
+		}
+		if p.isolated {
+			if _, err := kvstore.MergeFork(ctx.Workflow.Store, base, snapshots[i], p.strategy); err != nil {
+				errs[i] = fmt.Errorf("merging the writes of action '%s': %w", action.Name(), err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/keys"
+	"github.com/davidroman0O/turingpi/state"
+	"github.com/davidroman0O/turingpi/workflows"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// buildParallelWorkflow creates a workflow running two actions in parallel,
+// both writing the "status" key and a key of their own. Each action checks it
+// still reads its own status once both have written theirs.
+func buildParallelWorkflow(t *testing.T, configure func(p *ParallelAction)) *Workflow {
+	var written sync.WaitGroup
+	written.Add(2)
+
+	write := func(name string) *workflows.FuncAction {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			ctx.Store().Put("status", name)
+			ctx.Store().Put(name+".done", true)
+			written.Done()
+			written.Wait()
+
+			if status, _ := store.Get[string](ctx.Store(), "status"); status != name {
+				t.Errorf("Action %s reads status %q written by another action", name, status)
+			}
+			return nil
+		})
+	}
+
+	parallel := NewParallelAction("flash-all", "Flash nodes in parallel", write("node1"), write("node2"))
+	configure(parallel)
+
+	wf := NewWorkflow("parallel", "Parallel", "Workflow running actions in parallel")
+	stage := NewStage("flash", "Flash", "Flash the nodes")
+	stage.AddAction(parallel)
+	wf.AddStage(stage)
+	wf.Store.Put("status", "pending")
+	return wf
+}
+
+func TestParallelActionSnapshotIsolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy store.MergeStrategy
+		want     string
+	}{
+		{"Overwrite keeps the last action's write", store.Overwrite, "node2"},
+		{"Skip keeps the first action's write", store.Skip, "node1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := buildParallelWorkflow(t, func(p *ParallelAction) { p.WithSnapshotIsolation(tt.strategy) })
+			if err := wf.Execute(context.Background(), nil); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if status, _ := store.Get[string](wf.Store, "status"); status != tt.want {
+				t.Errorf("status = %q, want %q", status, tt.want)
+			}
+			for _, key := range []string{"node1.done", "node2.done"} {
+				if done, _ := store.Get[bool](wf.Store, key); !done {
+					t.Errorf("%s was not merged", key)
+				}
+			}
+		})
+	}
+
+	t.Run("Error fails on overlapping writes", func(t *testing.T) {
+		wf := buildParallelWorkflow(t, func(p *ParallelAction) { p.WithSnapshotIsolation(store.Error) })
+		err := wf.Execute(context.Background(), nil)
+		if !errors.Is(err, kvstore.ErrMergeConflict) {
+			t.Fatalf("Execute() error = %v, want ErrMergeConflict", err)
+		}
+		if status, _ := store.Get[string](wf.Store, "status"); status != "node1" {
+			t.Errorf("status = %q, want the first action's write", status)
+		}
+	})
+
+	t.Run("Writes of failed actions are discarded", func(t *testing.T) {
+		errFlash := errors.New("flash failed")
+		fail := workflows.NewFuncAction("node3", "", func(ctx *gostage.ActionContext) error {
+			ctx.Store().Put("node3.done", true)
+			return errFlash
+		})
+
+		wf := NewWorkflow("parallel", "Parallel", "Workflow with a failing parallel action")
+		stage := NewStage("flash", "Flash", "Flash the nodes")
+		stage.AddAction(NewParallelAction("flash-all", "Flash nodes in parallel", fail).WithSnapshotIsolation(store.Overwrite))
+		wf.AddStage(stage)
+
+		if err := wf.Execute(context.Background(), nil); !errors.Is(err, errFlash) {
+			t.Fatalf("Execute() error = %v, want the action failure", err)
+		}
+		if _, err := store.Get[bool](wf.Store, "node3.done"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Write of the failed action was merged: %v", err)
+		}
+	})
+
+	t.Run("Handles are shared", func(t *testing.T) {
+		manager, err := state.NewFileStateManager(filepath.Join(t.TempDir(), "state.json"))
+		if err != nil {
+			t.Fatalf("NewFileStateManager() error = %v", err)
+		}
+
+		update := func(nodeID state.NodeID) *workflows.FuncAction {
+			return workflows.NewFuncAction(fmt.Sprintf("node%d", nodeID), "", func(ctx *gostage.ActionContext) error {
+				nodes, err := store.Get[state.Manager](ctx.Store(), keys.StateManager)
+				if err != nil {
+					return err
+				}
+				return nodes.UpdateNodeState(&state.NodeState{NodeID: nodeID, LastOperation: "flash"})
+			})
+		}
+
+		wf := NewWorkflow("parallel", "Parallel", "Workflow updating node state in parallel")
+		stage := NewStage("flash", "Flash", "Flash the nodes")
+		stage.AddAction(NewParallelAction("flash-all", "Flash nodes in parallel", update(1), update(2)).
+			WithSnapshotIsolation(store.Error))
+		wf.AddStage(stage)
+		wf.Store.Put(keys.StateManager, manager)
+
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		for _, nodeID := range []state.NodeID{1, 2} {
+			if node, err := manager.GetNodeState(nodeID); err != nil || node == nil || node.LastOperation != "flash" {
+				t.Errorf("Node %d state = %+v, %v, want the update of its action", nodeID, node, err)
+			}
+		}
+		if stored, _ := store.Get[state.Manager](wf.Store, keys.StateManager); stored != manager {
+			t.Error("The state manager was replaced by a copy")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/keys"
+	"github.com/davidroman0O/turingpi/state"
+)
+
+// ErrPreconditionFailed is matched by errors reporting an action precondition
+// that does not hold
+var ErrPreconditionFailed = errors.New("precondition not met")
+
+// Precondition is a condition that must hold for an action to run, such as a
+// node being powered off or an image having been prepared for it
+type Precondition struct {
+	// Name describes the condition in errors, such as "image prepared"
+	Name string
+	// Check returns nil when the condition holds, or why it does not
+	Check func(ctx *gostage.ActionContext) error
+}
+
+// PreconditionedAction is implemented by actions requiring conditions on the
+// node state or the workflow store to hold before they run. They are checked
+// in order right before the action executes, holding its resource lock, see
+// ResourceLocker. The action does not run when one does not hold, failing
+// with a *PreconditionError naming it.
+type PreconditionedAction interface {
+	Preconditions() []Precondition
+}
+
+// PreconditionError reports the precondition of an action that does not hold
+type PreconditionError struct {
+	Action       string
+	Precondition string
+	Err          error
+}
+
+// Error implements the error interface
+func (e *PreconditionError) Error() string {
+	return fmt.Sprintf("action '%s' requires '%s': %v", e.Action, e.Precondition, e.Err)
+}
+
+// Unwrap returns why the precondition does not hold
+func (e *PreconditionError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match ErrPreconditionFailed
+func (e *PreconditionError) Is(target error) bool {
+	return target == ErrPreconditionFailed
+}
+
+// checkPreconditions returns a *PreconditionError for the first precondition
+// of an action that does not hold, nil when all of them do
+func checkPreconditions(ctx *gostage.ActionContext, action gostage.Action) error {
+	conditioned, ok := action.(PreconditionedAction)
+	if !ok {
+		return nil
+	}
+	for _, precondition := range conditioned.Preconditions() {
+		if err := precondition.Check(ctx); err != nil {
+			return &PreconditionError{Action: action.Name(), Precondition: precondition.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// StateManager returns the node state manager stored in the workflow store
+// under keys.StateManager
+func StateManager(ctx *gostage.ActionContext) (state.Manager, error) {
+	manager, err := store.Get[state.Manager](ctx.Store(), keys.StateManager)
+	if err != nil {
+		return nil, fmt.Errorf("no state manager in the workflow store: %w", err)
+	}
+	return manager, nil
+}
+
+// RequireStoreKey is a precondition holding when key is set in the workflow
+// store and not expired
+func RequireStoreKey(name, key string) Precondition {
+	return Precondition{
+		Name: name,
+		Check: func(ctx *gostage.ActionContext) error {
+			if _, err := ctx.Store().GetMetadata(key); err != nil {
+				return fmt.Errorf("'%s' is not set: %w", key, err)
+			}
+			return nil
+		},
+	}
+}
+
+// RequireNodeProperty is a precondition holding when the state of a node
+// records property, with the value want unless it is nil. The state is read
+// from the state manager in the workflow store, see StateManager.
+func RequireNodeProperty(name string, nodeID state.NodeID, property string, want interface{}) Precondition {
+	return Precondition{
+		Name: name,
+		Check: func(ctx *gostage.ActionContext) error {
+			manager, err := StateManager(ctx)
+			if err != nil {
+				return err
+			}
+			nodeState, err := manager.GetNodeState(nodeID)
+			if err != nil {
+				return fmt.Errorf("reading the state of node %d: %w", nodeID, err)
+			}
+			if nodeState == nil {
+				return fmt.Errorf("node %d has no state recorded", nodeID)
+			}
+
+			value, ok := nodeState.Properties[property]
+			if !ok {
+				return fmt.Errorf("node %d has no '%s' recorded", nodeID, property)
+			}
+			if want != nil && !reflect.DeepEqual(value, want) {
+				return fmt.Errorf("node %d has '%s' %v, not %v", nodeID, property, value, want)
+			}
+			return nil
+		},
+	}
+}
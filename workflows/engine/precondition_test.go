@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/keys"
+	"github.com/davidroman0O/turingpi/state"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// conditionedAction is a test action declaring preconditions
+type conditionedAction struct {
+	workflows.FuncAction
+	preconditions []Precondition
+}
+
+func (a *conditionedAction) Preconditions() []Precondition { return a.preconditions }
+
+// buildInstallWorkflow creates a workflow installing an OS on node 1, which
+// requires the node to be powered off and an image prepared for it
+func buildInstallWorkflow(t *testing.T, manager state.Manager, ran *bool) *Workflow {
+	t.Helper()
+	install := &conditionedAction{
+		FuncAction: *workflows.NewFuncAction("install-os", "", func(ctx *gostage.ActionContext) error {
+			*ran = true
+			return nil
+		}),
+		preconditions: []Precondition{
+			RequireStoreKey("node powered off", keys.NodeKey(keys.NodePower, 1)),
+			RequireNodeProperty("image prepared", 1, "preparedImage", nil),
+		},
+	}
+
+	wf := NewWorkflow("install", "Install", "Install an OS on node 1")
+	stage := NewStage("os", "OS", "Install the OS")
+	stage.AddAction(install)
+	wf.AddStage(stage)
+	wf.Store.Put(keys.NodeKey(keys.NodePower, 1), "off")
+	wf.Store.Put(keys.StateManager, manager)
+	return wf
+}
+
+func TestActionPreconditions(t *testing.T) {
+	newManager := func(t *testing.T) state.Manager {
+		t.Helper()
+		manager, err := state.NewFileStateManager(filepath.Join(t.TempDir(), "state.json"))
+		if err != nil {
+			t.Fatalf("NewFileStateManager() error = %v", err)
+		}
+		return manager
+	}
+
+	t.Run("Fails without a prepared image", func(t *testing.T) {
+		manager := newManager(t)
+		manager.UpdateNodeProperties(1, map[string]interface{}{"hostname": "node1"})
+
+		var ran bool
+		err := buildInstallWorkflow(t, manager, &ran).Execute(context.Background(), nil)
+		if !errors.Is(err, ErrPreconditionFailed) {
+			t.Fatalf("Execute() error = %v, want ErrPreconditionFailed", err)
+		}
+		var precondition *PreconditionError
+		if !errors.As(err, &precondition) || precondition.Precondition != "image prepared" || precondition.Action != "install-os" {
+			t.Errorf("Execute() error = %v, want the unmet 'image prepared' precondition", err)
+		}
+		if !strings.Contains(err.Error(), "'image prepared'") {
+			t.Errorf("Error %q does not name the precondition", err)
+		}
+		if ran {
+			t.Error("Action ran although its precondition does not hold")
+		}
+	})
+
+	t.Run("Runs with a prepared image", func(t *testing.T) {
+		manager := newManager(t)
+		manager.UpdateNodeProperties(1, map[string]interface{}{"preparedImage": "/cache/ubuntu-rk1.img.xz"})
+
+		var ran bool
+		if err := buildInstallWorkflow(t, manager, &ran).Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !ran {
+			t.Error("Action did not run")
+		}
+	})
+
+	t.Run("Checked in order", func(t *testing.T) {
+		var ran bool
+		wf := buildInstallWorkflow(t, newManager(t), &ran)
+		wf.Store.Delete(keys.NodeKey(keys.NodePower, 1))
+
+		var precondition *PreconditionError
+		err := wf.Execute(context.Background(), nil)
+		if !errors.As(err, &precondition) || precondition.Precondition != "node powered off" {
+			t.Errorf("Execute() error = %v, want the unmet 'node powered off' precondition", err)
+		}
+	})
+
+	t.Run("Property values", func(t *testing.T) {
+		manager := newManager(t)
+		manager.UpdateNodeProperties(1, map[string]interface{}{"preparedImage": "debian"})
+
+		tests := []struct {
+			name      string
+			condition Precondition
+			wantErr   bool
+		}{
+			{"Matching value", RequireNodeProperty("ubuntu prepared", 1, "preparedImage", "debian"), false},
+			{"Other value", RequireNodeProperty("ubuntu prepared", 1, "preparedImage", "ubuntu"), true},
+			{"Unknown node", RequireNodeProperty("image prepared", 2, "preparedImage", nil), true},
+		}
+		for _, tt := range tests {
+			wf := NewWorkflow("check", "Check", "Check a precondition")
+			wf.Store.Put(keys.StateManager, manager)
+			ctx := &gostage.ActionContext{Workflow: wf.Workflow}
+			if err := tt.condition.Check(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("%s: Check() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		}
+
+		ctx := &gostage.ActionContext{Workflow: NewWorkflow("check", "Check", "No state manager").Workflow}
+		if err := RequireNodeProperty("image prepared", 1, "preparedImage", nil).Check(ctx); err == nil {
+			t.Error("Check() without state manager succeeded")
+		}
+	})
+}
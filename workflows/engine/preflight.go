@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/keys"
+	"github.com/davidroman0O/turingpi/operations"
+	"github.com/davidroman0O/turingpi/tools"
+)
+
+// ErrMissingTool is matched by errors reporting a tool a stage requires is not installed
+var ErrMissingTool = errors.New("required tool not installed")
+
+// MissingToolError reports the tools a stage requires that are not installed
+type MissingToolError struct {
+	StageID string
+	Tools   []string
+}
+
+// Error implements the error interface
+func (e *MissingToolError) Error() string {
+	return fmt.Sprintf("stage '%s' requires tools that are not installed: %s",
+		e.StageID, strings.Join(e.Tools, ", "))
+}
+
+// Is makes errors.Is match ErrMissingTool
+func (e *MissingToolError) Is(target error) bool {
+	return target == ErrMissingTool
+}
+
+// SetToolExecutor sets the executor the tools required by stages are looked
+// up with. By default they are looked up with the executor of the operations
+// tool of the provider in the workflow store, which runs in a container on
+// hosts other than Linux, and on the host when there is none.
+func (w *Workflow) SetToolExecutor(executor operations.CommandExecutor) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.toolExecutor = executor
+}
+
+// executorFor returns the executor the tools required by stages are looked up with
+func (w *Workflow) executorFor(s *store.KVStore) operations.CommandExecutor {
+	w.mu.Lock()
+	executor := w.toolExecutor
+	w.mu.Unlock()
+	if executor != nil {
+		return executor
+	}
+
+	if provider, err := store.Get[*tools.TuringPiToolProvider](s, keys.ToolsProvider); err == nil && provider != nil {
+		if ops, ok := provider.GetOperationsTool().(interface {
+			GetExecutor() operations.CommandExecutor
+		}); ok && ops.GetExecutor() != nil {
+			return ops.GetExecutor()
+		}
+	}
+	return &operations.NativeExecutor{}
+}
+
+// checkTools returns a *MissingToolError naming the tools of a stage that
+// executor cannot find, nil when all of them are installed
+func checkTools(ctx context.Context, executor operations.CommandExecutor, stage *Stage) error {
+	var missing []string
+	checked := make(map[string]bool)
+	for _, tool := range stage.RequiredTools() {
+		if checked[tool] {
+			continue
+		}
+		checked[tool] = true
+		if _, err := executor.Execute(ctx, "which", tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingToolError{StageID: stage.ID, Tools: missing}
+	}
+	return nil
+}
+
+// Preflight checks, before executing the workflow, that the tools required by
+// every enabled stage are installed where its commands run, see
+// SetToolExecutor. All stages missing tools are returned
+// joined, each as a *MissingToolError. The same check runs before each stage
+// during execution, failing the workflow before any action of the stage runs,
+// which also covers stages added dynamically.
+func (w *Workflow) Preflight() error {
+	executor := w.executorFor(w.Store)
+	var errs []error
+	for _, stage := range w.Stages {
+		if !w.IsStageEnabled(stage.ID) {
+			continue
+		}
+		if err := checkTools(context.Background(), executor, w.stageOptions(stage)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/operations"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// containerExecutor stands for the executor of a container, finding only the
+// tools installed in it
+type containerExecutor struct {
+	operations.CommandExecutor
+	installed map[string]bool
+	commands  []string
+}
+
+// Execute answers which for the installed tools
+func (e *containerExecutor) Execute(ctx context.Context, name string, args ...string) ([]byte, error) {
+	e.commands = append(e.commands, strings.Join(append([]string{name}, args...), " "))
+	if name == "which" && len(args) == 1 && e.installed[args[0]] {
+		return []byte("/usr/sbin/" + args[0] + "\n"), nil
+	}
+	return nil, errors.New("exit status 1")
+}
+
+// buildToolsWorkflow creates a workflow whose flash stage requires tools
+func buildToolsWorkflow(ran *[]string, tools ...string) *Workflow {
+	record := func(name string) *workflows.FuncAction {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			*ran = append(*ran, name)
+			return nil
+		})
+	}
+
+	wf := NewWorkflow("tools", "Tools", "Workflow requiring tools")
+
+	prepare := NewStage("prepare", "Prepare", "Preparation stage")
+	prepare.AddAction(record("download"))
+	wf.AddStage(prepare)
+
+	flash := NewStage("flash", "Flash", "Flashing stage").RequiresTools(tools...)
+	flash.AddAction(record("map-partitions"))
+	flash.AddAction(record("write-image"))
+	wf.AddStage(flash)
+	return wf
+}
+
+func TestStageRequiresTools(t *testing.T) {
+	t.Run("Missing tool fails before the stage runs", func(t *testing.T) {
+		var ran []string
+		wf := buildToolsWorkflow(&ran, "go", "turingpi-missing-tool")
+		wf.SetErrorMode(CollectAll)
+
+		err := wf.Execute(context.Background(), nil)
+		if !errors.Is(err, ErrMissingTool) {
+			t.Fatalf("Execute() error = %v, want ErrMissingTool", err)
+		}
+		var toolErr *MissingToolError
+		if !errors.As(err, &toolErr) || toolErr.StageID != "flash" || strings.Join(toolErr.Tools, ",") != "turingpi-missing-tool" {
+			t.Errorf("MissingToolError = %+v", toolErr)
+		}
+		if !strings.Contains(err.Error(), "turingpi-missing-tool") {
+			t.Errorf("Execute() error = %v, want the missing tool named", err)
+		}
+
+		if strings.Join(ran, ",") != "download" {
+			t.Errorf("Actions run = %v, want only the stage before the preflight", ran)
+		}
+		stages := wf.Report().Stages
+		if last := stages[len(stages)-1]; last.ID != "flash" || last.Status != gostage.StatusFailed || len(last.Actions) != 0 {
+			t.Errorf("Flash stage report = %+v", last)
+		}
+	})
+
+	t.Run("Installed tools", func(t *testing.T) {
+		var ran []string
+		wf := buildToolsWorkflow(&ran, "go")
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if len(ran) != 3 {
+			t.Errorf("Actions run = %v", ran)
+		}
+	})
+
+	t.Run("Preflight before execution", func(t *testing.T) {
+		var ran []string
+		wf := buildToolsWorkflow(&ran, "turingpi-missing-tool", "turingpi-missing-tool")
+		err := wf.Preflight()
+		var toolErr *MissingToolError
+		if !errors.As(err, &toolErr) || len(toolErr.Tools) != 1 {
+			t.Fatalf("Preflight() error = %v, want the missing tool reported once", err)
+		}
+
+		wf.DisableStage("flash")
+		if err := wf.Preflight(); err != nil {
+			t.Errorf("Preflight() with the stage disabled error = %v", err)
+		}
+		if len(ran) != 0 {
+			t.Errorf("Preflight() ran actions: %v", ran)
+		}
+	})
+	t.Run("Tools looked up where commands run", func(t *testing.T) {
+		var ran []string
+		// go is installed on the host but not in the container
+		wf := buildToolsWorkflow(&ran, "kpartx", "go")
+		executor := &containerExecutor{installed: map[string]bool{"kpartx": true}}
+		wf.SetToolExecutor(executor)
+
+		var toolErr *MissingToolError
+		if err := wf.Preflight(); !errors.As(err, &toolErr) || strings.Join(toolErr.Tools, ",") != "go" {
+			t.Fatalf("Preflight() error = %v, want go missing from the container", err)
+		}
+		if got := strings.Join(executor.commands, ","); got != "which kpartx,which go" {
+			t.Errorf("Commands = %s, want the tools looked up with the executor", got)
+		}
+
+		executor.installed["go"] = true
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ActionReport records the outcome of a single action execution
+type ActionReport struct {
+	Name     string
+	Status   string // One of the gostage Status* values
+	Error    error
+	Started  time.Time
+	Duration time.Duration
+	// Output holds the lines the action logged, one "[LEVEL] message" per
+	// line, when the workflow captures output
+	Output string
+}
+
+// StageReport records the outcome of a stage and its actions
+type StageReport struct {
+	ID       string
+	Name     string
+	Status   string // One of the gostage Status* values
+	Error    error
+	Started  time.Time
+	Duration time.Duration
+	Actions  []*ActionReport
+
+	mu sync.Mutex
+}
+
+// Report records the outcome of a workflow execution
+type Report struct {
+	WorkflowID string
+	Name       string
+	Status     string // One of the gostage Status* values
+	Error      error
+	Started    time.Time
+	Duration   time.Duration
+	Stages     []*StageReport
+
+	mu sync.Mutex
+}
+
+// newReport creates an empty report for a workflow
+func newReport(workflow *gostage.Workflow) *Report {
+	return &Report{
+		WorkflowID: workflow.ID,
+		Name:       workflow.Name,
+		Status:     gostage.StatusPending,
+		Started:    time.Now(),
+	}
+}
+
+// startStage adds a running stage entry to the report
+func (r *Report) startStage(stage *gostage.Stage) *StageReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &StageReport{
+		ID:      stage.ID,
+		Name:    stage.Name,
+		Status:  gostage.StatusRunning,
+		Started: time.Now(),
+	}
+	r.Stages = append(r.Stages, entry)
+	return entry
+}
+
+// skipStage adds a skipped stage entry to the report, with its actions skipped too
+func (r *Report) skipStage(stage *gostage.Stage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := &StageReport{
+		ID:      stage.ID,
+		Name:    stage.Name,
+		Status:  gostage.StatusSkipped,
+		Started: time.Now(),
+	}
+	for _, action := range stage.Actions {
+		entry.Actions = append(entry.Actions, &ActionReport{
+			Name:    action.Name(),
+			Status:  gostage.StatusSkipped,
+			Started: entry.Started,
+		})
+	}
+	r.Stages = append(r.Stages, entry)
+}
+
+// finish completes the report with the workflow result
+func (r *Report) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Duration = time.Since(r.Started)
+	r.Error = err
+	r.Status = gostage.StatusCompleted
+	if err != nil {
+		r.Status = failureStatus(err)
+		return
+	}
+
+	for _, stage := range r.Stages {
+		if stage.Status == gostage.StatusFailed {
+			r.Status = gostage.StatusFailed
+			return
+		}
+	}
+}
+
+// Failures returns every failed action across all stages, in execution order
+func (r *Report) Failures() []*ActionReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var failures []*ActionReport
+	for _, stage := range r.Stages {
+		stage.mu.Lock()
+		for _, action := range stage.Actions {
+			if action.Status == gostage.StatusFailed {
+				failures = append(failures, action)
+			}
+		}
+		stage.mu.Unlock()
+	}
+	return failures
+}
+
+// Success reports whether the workflow completed without any recorded failure
+func (r *Report) Success() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Status == gostage.StatusCompleted
+}
+
+// startAction adds a running action entry to the stage report
+func (s *StageReport) startAction(action gostage.Action) *ActionReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &ActionReport{
+		Name:    action.Name(),
+		Status:  gostage.StatusRunning,
+		Started: time.Now(),
+	}
+	s.Actions = append(s.Actions, entry)
+	return entry
+}
+
+// finish completes the stage report, recording actions the runner skipped
+func (s *StageReport) finish(stage *gostage.Stage, workflow *gostage.Workflow, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Duration = time.Since(s.Started)
+	s.Error = err
+
+	// Disabled actions never reach the wrapper, the runner skips them silently
+	recorded := make(map[string]bool, len(s.Actions))
+	for _, action := range s.Actions {
+		recorded[action.Name] = true
+	}
+	disabled, _ := workflow.Context["disabledActions"].(map[string]bool)
+	for _, action := range stage.Actions {
+		if !recorded[action.Name()] && disabled[action.Name()] {
+			s.Actions = append(s.Actions, &ActionReport{
+				Name:    action.Name(),
+				Status:  gostage.StatusSkipped,
+				Started: s.Started,
+			})
+		}
+	}
+
+	s.Status = gostage.StatusCompleted
+	if err != nil {
+		s.Status = failureStatus(err)
+		return
+	}
+	for _, action := range s.Actions {
+		if action.Status == gostage.StatusFailed {
+			s.Status = gostage.StatusFailed
+			return
+		}
+	}
+}
+
+// skipAction adds a skipped action entry to the stage report
+func (s *StageReport) skipAction(action gostage.Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Actions = append(s.Actions, &ActionReport{
+		Name:    action.Name(),
+		Status:  gostage.StatusSkipped,
+		Started: time.Now(),
+	})
+}
+
+// finish completes the action report with its result
+func (a *ActionReport) finish(err error) {
+	a.Duration = time.Since(a.Started)
+	a.Error = err
+	a.Status = gostage.StatusCompleted
+	if err != nil {
+		a.Status = failureStatus(err)
+	}
+}
+
+// failureStatus returns the status matching an execution error
+func failureStatus(err error) string {
+	if errors.Is(err, ErrPausedForInput) {
+		return StatusPaused
+	}
+	return gostage.StatusFailed
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ResourceLocker is implemented by actions driving hardware that must not be
+// driven by several actions at once, such as the power of a node. Actions
+// returning the same key, for example "node:1", never run at the same time,
+// even in a ParallelAction or in workflows running side by side, while
+// actions with different keys still run in parallel. An empty key locks
+// nothing. Locks are not reentrant: an action must not run another action
+// locking the same key.
+type ResourceLocker interface {
+	ResourceLock() string
+}
+
+// resourceLocks serializes the actions of every workflow by resource key
+var resourceLocks = newKeyedMutex()
+
+// keyedMutex is a set of mutexes created on demand for each key and dropped
+// once no caller holds or waits for them
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the mutex of a key, a channel holding a token while locked so
+// waiting can be abandoned when a context is done
+type keyLock struct {
+	held chan struct{}
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyLock)}
+}
+
+// lock waits until key is free and locks it, returning the function
+// releasing it. It fails with the context error if ctx is done first.
+func (m *keyedMutex) lock(ctx context.Context, key string) (unlock func(), err error) {
+	m.mu.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyLock{held: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	select {
+	case l.held <- struct{}{}:
+		return func() {
+			<-l.held
+			m.release(key, l)
+		}, nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return nil, ctx.Err()
+	}
+}
+
+// release drops a reference to the lock of key
+func (m *keyedMutex) release(key string, l *keyLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// lockResource locks the resource an action declares, if any, for the
+// duration of its execution and returns the function releasing it
+func lockResource(ctx *gostage.ActionContext, action gostage.Action) (unlock func(), err error) {
+	locker, ok := action.(ResourceLocker)
+	if !ok || locker.ResourceLock() == "" {
+		return func() {}, nil
+	}
+
+	goCtx := ctx.GoContext
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	return resourceLocks.lock(goCtx, locker.ResourceLock())
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// lockingAction is a test action declaring the resource it drives
+type lockingAction struct {
+	workflows.FuncAction
+	resource string
+}
+
+func newLockingAction(name, resource string, fn func(ctx *gostage.ActionContext) error) *lockingAction {
+	return &lockingAction{FuncAction: *workflows.NewFuncAction(name, "", fn), resource: resource}
+}
+
+func (a *lockingAction) ResourceLock() string { return a.resource }
+
+// occupancy tracks how many actions drive each resource at once
+type occupancy struct {
+	mu      sync.Mutex
+	current map[string]int
+	max     map[string]int
+}
+
+func newOccupancy() *occupancy {
+	return &occupancy{current: make(map[string]int), max: make(map[string]int)}
+}
+
+// hold occupies a resource for a while
+func (o *occupancy) hold(resource string) {
+	o.mu.Lock()
+	o.current[resource]++
+	if o.current[resource] > o.max[resource] {
+		o.max[resource] = o.current[resource]
+	}
+	o.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	o.mu.Lock()
+	o.current[resource]--
+	o.mu.Unlock()
+}
+
+func TestResourceLock(t *testing.T) {
+	t.Run("Actions locking the same node do not overlap", func(t *testing.T) {
+		used := newOccupancy()
+		power := func(name, node string) gostage.Action {
+			return newLockingAction(name, node, func(ctx *gostage.ActionContext) error {
+				used.hold(node)
+				return nil
+			})
+		}
+
+		// Actions on node 2 overlap with those on node 1
+		var started sync.WaitGroup
+		started.Add(2)
+		overlap := func(name, node string) gostage.Action {
+			return newLockingAction(name, node, func(ctx *gostage.ActionContext) error {
+				started.Done()
+				done := make(chan struct{})
+				go func() { started.Wait(); close(done) }()
+				select {
+				case <-done:
+					return nil
+				case <-time.After(5 * time.Second):
+					return errors.New("actions on different nodes did not run in parallel")
+				}
+			})
+		}
+
+		parallel := NewParallelAction("power-all", "Power nodes",
+			power("power-on-1", "node:1"),
+			power("reset-1", "node:1"),
+			power("usb-1", "node:1"),
+			overlap("wait-1", "node:1"),
+			overlap("wait-2", "node:2"),
+		)
+		wf := NewWorkflow("locks", "Locks", "Workflow driving node power in parallel")
+		stage := NewStage("power", "Power", "Power the nodes")
+		stage.AddAction(parallel)
+		wf.AddStage(stage)
+
+		if err := wf.Execute(context.Background(), nil); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if used.max["node:1"] != 1 {
+			t.Errorf("%d actions drove node:1 at once, want 1", used.max["node:1"])
+		}
+	})
+
+	t.Run("Workflows running side by side share the locks", func(t *testing.T) {
+		used := newOccupancy()
+		build := func(id string) *Workflow {
+			wf := NewWorkflow(id, id, "Workflow resetting node 3")
+			stage := NewStage("reset", "Reset", "Reset the node")
+			stage.AddAction(newLockingAction("reset", "node:3", func(ctx *gostage.ActionContext) error {
+				used.hold("node:3")
+				return nil
+			}))
+			wf.AddStage(stage)
+			return wf
+		}
+
+		var wg sync.WaitGroup
+		for _, id := range []string{"first", "second", "third"} {
+			wf := build(id)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := wf.Execute(context.Background(), nil); err != nil {
+					t.Errorf("Execute() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if used.max["node:3"] != 1 {
+			t.Errorf("%d actions drove node:3 at once, want 1", used.max["node:3"])
+		}
+	})
+
+	t.Run("Waiting stops with the context", func(t *testing.T) {
+		unlock, err := resourceLocks.lock(context.Background(), "node:4")
+		if err != nil {
+			t.Fatalf("lock() error = %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := resourceLocks.lock(ctx, "node:4"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("lock() of a held resource error = %v, want DeadlineExceeded", err)
+		}
+
+		unlock()
+		resourceLocks.mu.Lock()
+		defer resourceLocks.mu.Unlock()
+		if _, ok := resourceLocks.locks["node:4"]; ok {
+			t.Error("Released lock kept in the keyed mutex")
+		}
+	})
+}
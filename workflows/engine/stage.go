@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// Stage wraps a gostage stage with engine execution options
+type Stage struct {
+	*gostage.Stage
+
+	continueOnError bool
+	requiredTools   []string
+	logLevel        LogLevel
+	actionTimeout   time.Duration
+
+	// generate produces the actions of a lazy stage, see NewLazyStage
+	generate ActionGenerator
+}
+
+// NewStage creates a new stage with engine options
+func NewStage(id, name, description string) *Stage {
+	return WrapStage(gostage.NewStage(id, name, description))
+}
+
+// WrapStage adds engine options to an existing gostage stage
+func WrapStage(stage *gostage.Stage) *Stage {
+	return &Stage{
+		Stage: stage,
+	}
+}
+
+// SetContinueOnError makes failures in this stage non-fatal.
+// Failed actions are still recorded in the report but never fail the
+// workflow, whatever the workflow's error mode is.
+func (s *Stage) SetContinueOnError(continueOnError bool) *Stage {
+	s.continueOnError = continueOnError
+	return s
+}
+
+// ContinueOnError reports whether failures in this stage are tolerated
+func (s *Stage) ContinueOnError() bool {
+	return s.continueOnError
+}
+
+// RequiresTools declares command line tools the stage needs, such as kpartx
+// or docker. They are checked before the stage runs, see Workflow.Preflight.
+func (s *Stage) RequiresTools(tools ...string) *Stage {
+	s.requiredTools = append(s.requiredTools, tools...)
+	return s
+}
+
+// RequiredTools returns the tools the stage needs
+func (s *Stage) RequiredTools() []string {
+	return s.requiredTools
+}
+
+// SetLogLevel sets the level of the lines the stage's actions log, overriding
+// the level of the workflow. LogDefault inherits it again.
+func (s *Stage) SetLogLevel(level LogLevel) *Stage {
+	s.logLevel = level
+	return s
+}
+
+// LogLevel returns the log level of the stage, LogDefault when it inherits
+// the level of the workflow
+func (s *Stage) LogLevel() LogLevel {
+	return s.logLevel
+}
@@ -0,0 +1,46 @@
+package engine
+
+import "github.com/davidroman0O/turingpi/workflows/kvstore"
+
+// SetKeyScheme enforces a key scheme on the workflow store, so that writes of
+// keys not conforming to it fail. Keys written with the store's own methods
+// are checked once the action returns, they are deleted and fail the action.
+// It replaces the scheme set before.
+func (w *Workflow) SetKeyScheme(scheme kvstore.KeyScheme) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.removeKeyScheme != nil {
+		w.removeKeyScheme()
+	}
+	w.removeKeyScheme = scheme.Enforce(w.Store)
+}
+
+// BoundStore returns the BoundedStore enforcing limits on every write to the
+// workflow store, installing it on first use. Writes made with the store's own
+// methods are checked once the action returns, they are undone and fail the
+// action. The limits are set on it, as in wf.BoundStore().WithMaxValueBytes(1 << 20).
+func (w *Workflow) BoundStore() *kvstore.BoundedStore {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.bounded == nil {
+		w.bounded = kvstore.NewBoundedStore(w.Store)
+	}
+	return w.bounded
+}
+
+// FreezeStore returns the FreezableStore protecting the frozen keys of the
+// workflow store from every write, installing it on first use. Writes made
+// with the store's own methods are checked once the action returns, they are
+// undone and fail the action. Keys are frozen on it, as in
+// wf.FreezeStore().Freeze("baseImagePath").
+func (w *Workflow) FreezeStore() *kvstore.FreezableStore {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.frozen == nil {
+		w.frozen = kvstore.NewFreezableStore(w.Store)
+	}
+	return w.frozen
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+func TestWorkflowKeyScheme(t *testing.T) {
+	wf := NewWorkflow("keys", "Keys", "Workflow validating its keys")
+	wf.SetKeyScheme(kvstore.KeyScheme{Validate: true})
+
+	stage := NewStage("main", "Main", "Main stage")
+	stage.AddAction(workflows.NewFuncAction("write", "", func(ctx *gostage.ActionContext) error {
+		if err := ctx.Workflow.Store.Put("node.1.ip", "10.0.0.1"); err != nil {
+			return err
+		}
+		return ctx.Workflow.Store.PutWithMetadata("node..ip", "10.0.0.2", nil)
+	}))
+	wf.AddStage(stage)
+
+	err := wf.Execute(context.Background(), nil)
+	if !errors.Is(err, kvstore.ErrInvalidKey) {
+		t.Fatalf("Execute() error = %v, want ErrInvalidKey", err)
+	}
+	if _, err := kvstore.Get[string](wf.Store, "node.1.ip"); err != nil {
+		t.Errorf("Get() error = %v, want the valid key stored", err)
+	}
+
+	// Replacing the scheme stops enforcing the previous one
+	wf.SetKeyScheme(kvstore.DefaultKeyScheme)
+	if err := wf.Store.Put("node..ip", "10.0.0.2"); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+}
+
+func TestWorkflowBoundStore(t *testing.T) {
+	wf := NewWorkflow("bounded", "Bounded", "Workflow with a bounded store")
+	wf.BoundStore().WithMaxValueBytes(64)
+	if wf.BoundStore() != wf.BoundStore() {
+		t.Fatal("BoundStore() installed a second BoundedStore")
+	}
+
+	stage := NewStage("main", "Main", "Main stage")
+	stage.AddAction(workflows.NewFuncAction("write", "", func(ctx *gostage.ActionContext) error {
+		if err := ctx.Workflow.Store.Put("small", "ok"); err != nil {
+			return err
+		}
+		return ctx.Workflow.Store.Put("blob", strings.Repeat("a", 100))
+	}))
+	wf.AddStage(stage)
+
+	if err := wf.Execute(context.Background(), nil); !errors.Is(err, kvstore.ErrValueTooLarge) {
+		t.Fatalf("Execute() error = %v, want ErrValueTooLarge", err)
+	}
+	if _, err := kvstore.Get[string](wf.Store, "blob"); err == nil {
+		t.Error("Oversized value was stored")
+	}
+}
+
+func TestWorkflowFreezeStore(t *testing.T) {
+	wf := NewWorkflow("frozen", "Frozen", "Workflow with frozen configuration")
+	wf.Store.Put("baseImagePath", "/images/base.img")
+	wf.FreezeStore().Freeze("baseImagePath")
+	if wf.FreezeStore() != wf.FreezeStore() {
+		t.Fatal("FreezeStore() installed a second FreezableStore")
+	}
+
+	stage := NewStage("main", "Main", "Main stage")
+	stage.AddAction(workflows.NewFuncAction("write", "", func(ctx *gostage.ActionContext) error {
+		if err := ctx.Workflow.Store.Put("result", "flashed"); err != nil {
+			return err
+		}
+		return ctx.Workflow.Store.Put("baseImagePath", "/tmp/other.img")
+	}))
+	wf.AddStage(stage)
+
+	if err := wf.Execute(context.Background(), nil); !errors.Is(err, kvstore.ErrKeyFrozen) {
+		t.Fatalf("Execute() error = %v, want ErrKeyFrozen", err)
+	}
+	if path, _ := kvstore.Get[string](wf.Store, "baseImagePath"); path != "/images/base.img" {
+		t.Errorf("baseImagePath = %q, want it unchanged", path)
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+)
+
+// ErrActionTimeout is returned when an action runs longer than its timeout
+var ErrActionTimeout = errors.New("action timed out")
+
+// TimedAction is implemented by actions bounding how long they may run, such
+// as waiting for a node to boot. The action's context is cancelled once the
+// timeout elapses, or at the deadline of the context the workflow executes
+// with if that comes first, so a late action never exceeds the workflow's
+// budget. Actions must watch ctx.GoContext to be cut off. A timeout of zero
+// or less falls back to the timeout of the stage, see Stage.SetActionTimeout.
+type TimedAction interface {
+	Timeout() time.Duration
+}
+
+// SetActionTimeout bounds how long each action of the stage may run, unless
+// the action declares its own timeout, see TimedAction. Zero or less
+// disables the timeout (default).
+func (s *Stage) SetActionTimeout(timeout time.Duration) *Stage {
+	s.actionTimeout = timeout
+	return s
+}
+
+// ActionTimeout returns how long each action of the stage may run, zero when unbounded
+func (s *Stage) ActionTimeout() time.Duration {
+	return s.actionTimeout
+}
+
+// executeWithTimeout runs an action with a context whose deadline is the
+// earlier of the current deadline and the action's timeout, or fallback when
+// the action declares none
+func executeWithTimeout(ctx *gostage.ActionContext, action gostage.Action, fallback time.Duration) error {
+	timeout := fallback
+	if timed, ok := action.(TimedAction); ok && timed.Timeout() > 0 {
+		timeout = timed.Timeout()
+	}
+	if timeout <= 0 {
+		return action.Execute(ctx)
+	}
+
+	original := ctx.GoContext
+	parent := original
+	if parent == nil {
+		parent = context.Background()
+	}
+	goCtx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// The context is shared by the actions of the stage, restore it even
+	// when the action panics
+	ctx.GoContext = goCtx
+	defer func() { ctx.GoContext = original }()
+	err := action.Execute(ctx)
+
+	// Cut off by the action's own timeout, the workflow's deadline not being
+	// reached yet
+	if err != nil && parent.Err() == nil && errors.Is(goCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: '%s' ran longer than %s: %w", ErrActionTimeout, action.Name(), timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// timedAction is a test action declaring its own timeout
+type timedAction struct {
+	workflows.FuncAction
+	timeout time.Duration
+}
+
+func (a *timedAction) Timeout() time.Duration { return a.timeout }
+
+// waitForNode is an action waiting for a node that boots after boot, or
+// until its context is done. It records the deadline it was given.
+func waitForNode(name string, boot time.Duration, deadline *time.Time) *workflows.FuncAction {
+	return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+		*deadline, _ = ctx.GoContext.Deadline()
+		select {
+		case <-time.After(boot):
+			return nil
+		case <-ctx.GoContext.Done():
+			return ctx.GoContext.Err()
+		}
+	})
+}
+
+// runTimedWorkflow runs a stage holding action within a workflow deadline
+func runTimedWorkflow(t *testing.T, stage *Stage, action gostage.Action, budget time.Duration) (time.Time, time.Duration, error) {
+	t.Helper()
+	wf := NewWorkflow("timeouts", "Timeouts", "Workflow with a deadline")
+	stage.AddAction(action)
+	wf.AddStage(stage)
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	start := time.Now()
+	err := wf.Execute(ctx, nil)
+	return deadline, time.Since(start), err
+}
+
+func TestActionTimeout(t *testing.T) {
+	t.Run("Workflow deadline cuts off a longer action timeout", func(t *testing.T) {
+		var got time.Time
+		action := &timedAction{FuncAction: *waitForNode("wait-boot", time.Second, &got), timeout: time.Minute}
+
+		deadline, elapsed, err := runTimedWorkflow(t, NewStage("boot", "Boot", "Boot the node"), action, 50*time.Millisecond)
+		if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrActionTimeout) {
+			t.Fatalf("Execute() error = %v, want the workflow deadline", err)
+		}
+		if !got.Equal(deadline) {
+			t.Errorf("Action deadline = %v, want the workflow deadline %v", got, deadline)
+		}
+		if elapsed > 500*time.Millisecond {
+			t.Errorf("Action ran %v, past the workflow deadline", elapsed)
+		}
+	})
+
+	t.Run("Action timeout earlier than the workflow deadline", func(t *testing.T) {
+		var got time.Time
+		action := &timedAction{FuncAction: *waitForNode("wait-boot", time.Second, &got), timeout: 30 * time.Millisecond}
+
+		deadline, _, err := runTimedWorkflow(t, NewStage("boot", "Boot", "Boot the node"), action, time.Minute)
+		if !errors.Is(err, ErrActionTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Execute() error = %v, want ErrActionTimeout", err)
+		}
+		if !got.Before(deadline) {
+			t.Errorf("Action deadline = %v, want the earlier action timeout", got)
+		}
+	})
+
+	t.Run("Stage timeout applies to actions without their own", func(t *testing.T) {
+		var got time.Time
+		stage := NewStage("boot", "Boot", "Boot the node").SetActionTimeout(30 * time.Millisecond)
+		_, _, err := runTimedWorkflow(t, stage, waitForNode("wait-boot", time.Second, &got), time.Minute)
+		if !errors.Is(err, ErrActionTimeout) {
+			t.Fatalf("Execute() error = %v, want ErrActionTimeout", err)
+		}
+	})
+
+	t.Run("Action completing in time", func(t *testing.T) {
+		var got time.Time
+		action := &timedAction{FuncAction: *waitForNode("wait-boot", time.Millisecond, &got), timeout: time.Second}
+		if _, _, err := runTimedWorkflow(t, NewStage("boot", "Boot", "Boot the node"), action, time.Minute); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+
+	t.Run("Context restored when the action panics", func(t *testing.T) {
+		parent := context.Background()
+		actionCtx := &gostage.ActionContext{GoContext: parent}
+		crash := workflows.NewFuncAction("crash", "", func(ctx *gostage.ActionContext) error {
+			panic("nil map")
+		})
+
+		func() {
+			defer func() { recover() }()
+			executeWithTimeout(actionCtx, crash, time.Minute)
+		}()
+		if actionCtx.GoContext != parent {
+			t.Error("GoContext left with the timeout of the panicking action")
+		}
+	})
+
+	t.Run("Parallel actions get their own timeout", func(t *testing.T) {
+		var slow, fast time.Time
+		parallel := NewParallelAction("boot-all", "Boot nodes in parallel",
+			&timedAction{FuncAction: *waitForNode("node1", time.Second, &slow), timeout: 30 * time.Millisecond},
+			waitForNode("node2", time.Millisecond, &fast))
+
+		deadline, _, err := runTimedWorkflow(t, NewStage("boot", "Boot", "Boot the nodes"), parallel, time.Minute)
+		if !errors.Is(err, ErrActionTimeout) {
+			t.Fatalf("Execute() error = %v, want ErrActionTimeout", err)
+		}
+		if !slow.Before(deadline) || !fast.Equal(deadline) {
+			t.Errorf("Deadlines = %v and %v, want node1's timeout and the workflow deadline", slow, fast)
+		}
+	})
+}
@@ -0,0 +1,365 @@
+// Package engine extends gostage workflows with execution policies and reporting
+// used by the TuringPi workflows
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/operations"
+	"github.com/davidroman0O/turingpi/workflows/kvstore"
+)
+
+// ErrorMode controls how the workflow reacts to a failing action
+type ErrorMode int
+
+const (
+	// FailFast aborts the workflow on the first action error (default)
+	FailFast ErrorMode = iota
+
+	// CollectAll keeps running every stage, recording each failure, and
+	// returns an aggregate error once the workflow has finished
+	CollectAll
+)
+
+// String returns the name of the error mode
+func (m ErrorMode) String() string {
+	switch m {
+	case FailFast:
+		return "fail-fast"
+	case CollectAll:
+		return "collect-all"
+	default:
+		return fmt.Sprintf("ErrorMode(%d)", int(m))
+	}
+}
+
+// MultiError aggregates the failures collected while running in CollectAll mode
+type MultiError struct {
+	Errors []error
+}
+
+// Error implements the error interface
+func (e *MultiError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d action(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap exposes the collected errors to errors.Is and errors.As
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// WorkflowError is the failure of an action, returned by Execute when it
+// aborts the workflow and collected in a *MultiError in CollectAll mode
+type WorkflowError struct {
+	// StageID is the ID of the stage the action ran in
+	StageID string
+	// Action is the name of the failed action
+	Action string
+	// Attempt is the attempt the action failed on, 1 unless the action was
+	// retried, see actions.RetryAction
+	Attempt int
+	// Err is the error the action returned
+	Err error
+}
+
+// Error implements the error interface
+func (e *WorkflowError) Error() string {
+	return fmt.Sprintf("stage '%s': action '%s' failed: %v", e.StageID, e.Action, e.Err)
+}
+
+// Unwrap exposes the action error to errors.Is and errors.As
+func (e *WorkflowError) Unwrap() error {
+	return e.Err
+}
+
+// newWorkflowError describes the failure of an action, reading the attempt it
+// failed on from errors implementing FailedAttempt() int
+func newWorkflowError(stageID string, action gostage.Action, err error) *WorkflowError {
+	attempt := 1
+	var attempted interface{ FailedAttempt() int }
+	if errors.As(err, &attempted) && attempted.FailedAttempt() > 0 {
+		attempt = attempted.FailedAttempt()
+	}
+	return &WorkflowError{StageID: stageID, Action: action.Name(), Attempt: attempt, Err: err}
+}
+
+// Workflow wraps a gostage workflow with execution policies and a run report
+type Workflow struct {
+	*gostage.Workflow
+
+	mu        sync.Mutex
+	errorMode ErrorMode
+	stages    map[string]*Stage
+	report    *Report
+	collected []error
+
+	// checkpoint is recorded when an execution pauses, resumeFrom is the
+	// checkpoint the current execution resumes from
+	checkpoint *Checkpoint
+	resumeFrom *Checkpoint
+
+	// limits bounds dynamic stage generation, counted per execution
+	limits        Limits
+	dynamicStages int
+	stageDepth    map[string]int
+
+	// captureOutput records the logger output of each action in the report
+	captureOutput bool
+
+	// logLevel filters the lines actions log, unless their stage sets its own
+	logLevel LogLevel
+
+	// queuedStages holds the dynamic stages generated by the running stage
+	// until it returns, see releaseDynamicStages
+	queuedStages []*gostage.Stage
+
+	// deferred holds the cleanups queued by actions with Defer, run once the
+	// execution has finished, or moved to the checkpoint when it paused
+	deferred []deferredCleanup
+
+	// removeKeyScheme stops enforcing the scheme set with SetKeyScheme
+	removeKeyScheme func()
+
+	// bounded enforces the limits of the workflow store, see BoundStore
+	bounded *kvstore.BoundedStore
+
+	// frozen protects the frozen keys of the workflow store, see FreezeStore
+	frozen *kvstore.FreezableStore
+
+	// toolExecutor looks up the tools required by stages, see SetToolExecutor
+	toolExecutor operations.CommandExecutor
+}
+
+// NewWorkflow creates a new workflow with engine support
+func NewWorkflow(id, name, description string) *Workflow {
+	return Wrap(gostage.NewWorkflow(id, name, description))
+}
+
+// Wrap adds engine support to an existing gostage workflow.
+// Stages already present in the workflow run with default stage options.
+func Wrap(workflow *gostage.Workflow) *Workflow {
+	w := &Workflow{
+		Workflow:   workflow,
+		errorMode:  FailFast,
+		stages:     make(map[string]*Stage),
+		report:     newReport(workflow),
+		limits:     DefaultLimits,
+		stageDepth: make(map[string]int),
+	}
+
+	for _, stage := range workflow.Stages {
+		w.stages[stage.ID] = WrapStage(stage)
+	}
+	w.disabledActions()
+
+	// Lets actions reach the engine workflow from their context, see Defer
+	workflow.Context[engineWorkflowKey] = w
+
+	workflow.Use(w.stageMiddleware())
+	return w
+}
+
+// SetErrorMode sets how the workflow reacts to failing actions
+func (w *Workflow) SetErrorMode(mode ErrorMode) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errorMode = mode
+}
+
+// ErrorMode returns the configured error mode
+func (w *Workflow) ErrorMode() ErrorMode {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.errorMode
+}
+
+// AddStage adds a stage and its options to the workflow
+func (w *Workflow) AddStage(stage *Stage) {
+	w.mu.Lock()
+	w.stages[stage.ID] = stage
+	w.mu.Unlock()
+
+	w.Workflow.AddStage(stage.Stage)
+}
+
+// Report returns the report of the last execution
+func (w *Workflow) Report() *Report {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.report
+}
+
+// Execute runs the workflow with a default gostage runner
+func (w *Workflow) Execute(ctx context.Context, logger gostage.Logger) error {
+	return w.ExecuteWith(ctx, gostage.NewRunner(), logger)
+}
+
+// ExecuteWith runs the workflow with the given runner, so runner middleware
+// (such as the TuringPi provider's) still applies.
+// A failed action is returned as a *WorkflowError. In CollectAll mode,
+// failures are returned together as a *MultiError of them.
+// Cleanups deferred by actions run once the workflow has finished, and their
+// failures are joined to the returned error as a *CleanupError. A paused
+// workflow keeps them in its checkpoint until it is resumed and finishes.
+func (w *Workflow) ExecuteWith(ctx context.Context, runner *gostage.Runner, logger gostage.Logger) error {
+	w.mu.Lock()
+	w.report = newReport(w.Workflow)
+	w.collected = nil
+	w.checkpoint = nil
+	w.dynamicStages = 0
+	w.stageDepth = make(map[string]int)
+	w.deferred = nil
+	if w.resumeFrom != nil {
+		w.deferred, w.resumeFrom.deferred = w.resumeFrom.deferred, nil
+	}
+	report := w.report
+	w.mu.Unlock()
+
+	// A panicking action still releases the resources acquired before it
+	defer func() {
+		if r := recover(); r != nil {
+			_ = w.runDeferred()
+			panic(r)
+		}
+	}()
+
+	err := runner.Execute(ctx, w.Workflow, logger)
+	// Drop the runner's wrapping, the failure tells its stage and action
+	var failure *WorkflowError
+	if errors.As(err, &failure) {
+		err = failure
+	}
+	var cleanupErr error
+	if !w.keepDeferred() {
+		cleanupErr = w.runDeferred()
+	}
+	report.finish(withCleanupError(err, cleanupErr))
+
+	if err == nil {
+		w.mu.Lock()
+		collected := w.collected
+		w.mu.Unlock()
+
+		if len(collected) > 0 {
+			err = &MultiError{Errors: collected}
+		}
+	}
+	return withCleanupError(err, cleanupErr)
+}
+
+// stageOptions returns the engine options for a stage, defaulting for
+// stages that were added dynamically or directly on the gostage workflow
+func (w *Workflow) stageOptions(stage *gostage.Stage) *Stage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	opts, ok := w.stages[stage.ID]
+	if !ok || opts.Stage != stage {
+		opts = WrapStage(stage)
+		w.stages[stage.ID] = opts
+	}
+	return opts
+}
+
+// handleFailure records an action failure and decides whether it aborts the workflow.
+// It returns nil when the failure is tolerated by the stage or the error mode.
+func (w *Workflow) handleFailure(stage *Stage, action gostage.Action, err error, logger gostage.Logger) error {
+	if stage.ContinueOnError() {
+		logger.Warn("Action %s failed in stage %s (continuing): %v", action.Name(), stage.ID, err)
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.errorMode == CollectAll {
+		logger.Error("Action %s failed in stage %s (collecting): %v", action.Name(), stage.ID, err)
+		w.collected = append(w.collected, newWorkflowError(stage.ID, action, err))
+		return nil
+	}
+
+	return newWorkflowError(stage.ID, action, err)
+}
+
+// stageMiddleware instruments each stage's actions for the duration of the stage
+func (w *Workflow) stageMiddleware() gostage.WorkflowMiddleware {
+	return func(next gostage.WorkflowStageRunnerFunc) gostage.WorkflowStageRunnerFunc {
+		return func(ctx context.Context, stage *gostage.Stage, workflow *gostage.Workflow, logger gostage.Logger) error {
+			report := w.Report()
+
+			if !workflow.IsStageEnabled(stage.ID) {
+				report.skipStage(stage)
+				return next(ctx, stage, workflow, logger)
+			}
+
+			// Stages that completed before the checkpoint do not run again
+			position, checkpoint := w.resumePosition(stage, workflow)
+			if position < 0 {
+				report.skipStage(stage)
+				return nil
+			}
+
+			opts := w.stageOptions(stage)
+			stageReport := report.startStage(stage)
+
+			// A missing tool fails the stage before any of its actions runs,
+			// whatever the error policies are
+			if err := checkTools(ctx, w.executorFor(workflow.Store), opts); err != nil {
+				stageReport.finish(stage, workflow, err)
+				return err
+			}
+
+			restoreActions, err := generateActions(ctx, opts, workflow, logger)
+			if err != nil {
+				// The stage does not run without its actions, whether the
+				// workflow goes on is decided as for a failing action
+				stageReport.finish(stage, workflow, err)
+				return w.handleFailure(opts, newGeneratorAction(opts), err, logger)
+			}
+			defer restoreActions()
+
+			for i, action := range stage.Actions {
+				if _, ok := action.(*trackedAction); !ok {
+					stage.Actions[i] = &trackedAction{
+						Action:   action,
+						workflow: w,
+						stage:    opts,
+						report:   stageReport,
+						// The pause itself was satisfied before resuming
+						resumed: position == 0 && i <= checkpoint.ActionIndex,
+					}
+				}
+			}
+
+			w.mu.Lock()
+			w.queuedStages = nil
+			w.mu.Unlock()
+
+			err = next(ctx, stage, workflow, logger)
+			if err == nil {
+				w.releaseDynamicStages(workflow)
+				err = w.checkDynamicStages(stage, workflow)
+			}
+
+			// Restore the original actions so lookups by type keep working
+			removeMarkers(stage, workflow)
+			for i, action := range stage.Actions {
+				if tracked, ok := action.(*trackedAction); ok {
+					stage.Actions[i] = tracked.Action
+				}
+			}
+
+			stageReport.finish(stage, workflow, err)
+			return err
+		}
+	}
+}
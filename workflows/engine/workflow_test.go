@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/turingpi/workflows"
+)
+
+// buildFailingWorkflow creates a workflow with two failing actions in
+// different stages and records which actions ran
+func buildFailingWorkflow(ran *[]string) (*Workflow, error, error) {
+	errFirst := errors.New("first failure")
+	errSecond := errors.New("second failure")
+
+	record := func(name string, err error) *workflows.FuncAction {
+		return workflows.NewFuncAction(name, "", func(ctx *gostage.ActionContext) error {
+			*ran = append(*ran, name)
+			return err
+		})
+	}
+
+	wf := NewWorkflow("errors", "Errors", "Workflow with failing actions")
+
+	validate := NewStage("validate", "Validate", "First validation stage")
+	validate.AddAction(record("check-a", nil))
+	validate.AddAction(record("check-b", errFirst))
+	validate.AddAction(record("check-c", nil))
+	wf.AddStage(validate)
+
+	lint := NewStage("lint", "Lint", "Second validation stage")
+	lint.AddAction(record("lint-a", errSecond))
+	lint.AddAction(record("lint-b", nil))
+	wf.AddStage(lint)
+
+	return wf, errFirst, errSecond
+}
+
+func TestWorkflowErrorModeFailFast(t *testing.T) {
+	var ran []string
+	wf, errFirst, errSecond := buildFailingWorkflow(&ran)
+
+	err := wf.Execute(context.Background(), nil)
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("Execute() error = %v, want first failure", err)
+	}
+	if errors.Is(err, errSecond) {
+		t.Errorf("Execute() error should not contain the second failure in FailFast mode")
+	}
+
+	if len(ran) != 2 || ran[1] != "check-b" {
+		t.Errorf("Executed actions = %v, want execution to stop at check-b", ran)
+	}
+
+	failures := wf.Report().Failures()
+	if len(failures) != 1 || failures[0].Name != "check-b" {
+		t.Errorf("Report failures = %v, want only check-b", failures)
+	}
+}
+
+// attemptFailure is an action error telling the attempt it failed on
+type attemptFailure struct {
+	attempt int
+	err     error
+}
+
+func (e *attemptFailure) Error() string      { return e.err.Error() }
+func (e *attemptFailure) Unwrap() error      { return e.err }
+func (e *attemptFailure) FailedAttempt() int { return e.attempt }
+
+func TestWorkflowError(t *testing.T) {
+	t.Run("Failing action", func(t *testing.T) {
+		var ran []string
+		wf, errFirst, _ := buildFailingWorkflow(&ran)
+
+		err := wf.Execute(context.Background(), nil)
+		wfErr, ok := err.(*WorkflowError)
+		if !ok {
+			t.Fatalf("Execute() error = %T %v, want a *WorkflowError", err, err)
+		}
+		if wfErr.StageID != "validate" || wfErr.Action != "check-b" || wfErr.Attempt != 1 {
+			t.Errorf("WorkflowError = %+v, want check-b of stage validate on attempt 1", wfErr)
+		}
+		if wfErr.Err != errFirst || !errors.Is(err, errFirst) {
+			t.Errorf("WorkflowError does not unwrap to the action error: %v", err)
+		}
+		if want := "stage 'validate': action 'check-b' failed: first failure"; err.Error() != want {
+			t.Errorf("Error() = %q, want %q", err.Error(), want)
+		}
+	})
+
+	t.Run("Retried action", func(t *testing.T) {
+		errFlash := errors.New("flash failed")
+		wf := NewWorkflow("retry", "Retry", "Workflow with a retried action")
+		stage := NewStage("flash", "Flash", "Flash the node")
+		stage.AddAction(workflows.NewFuncAction("flash-node", "", func(ctx *gostage.ActionContext) error {
+			return fmt.Errorf("after retries: %w", &attemptFailure{attempt: 3, err: errFlash})
+		}))
+		wf.AddStage(stage)
+
+		var wfErr *WorkflowError
+		err := wf.Execute(context.Background(), nil)
+		if !errors.As(err, &wfErr) || wfErr.Attempt != 3 || wfErr.Action != "flash-node" {
+			t.Fatalf("Execute() error = %v, want a WorkflowError on attempt 3", err)
+		}
+		if !errors.Is(err, errFlash) {
+			t.Errorf("Execute() error does not unwrap to the root cause: %v", err)
+		}
+	})
+
+	t.Run("Collected failures", func(t *testing.T) {
+		var ran []string
+		wf, _, errSecond := buildFailingWorkflow(&ran)
+		wf.SetErrorMode(CollectAll)
+
+		var multi *MultiError
+		if err := wf.Execute(context.Background(), nil); !errors.As(err, &multi) || len(multi.Errors) != 2 {
+			t.Fatalf("Execute() error = %v, want two collected failures", err)
+		}
+		wfErr, ok := multi.Errors[1].(*WorkflowError)
+		if !ok || wfErr.StageID != "lint" || wfErr.Action != "lint-a" || !errors.Is(wfErr, errSecond) {
+			t.Errorf("Second failure = %#v, want lint-a of stage lint", multi.Errors[1])
+		}
+	})
+}
+
+func TestWorkflowErrorModeCollectAll(t *testing.T) {
+	var ran []string
+	wf, errFirst, errSecond := buildFailingWorkflow(&ran)
+	wf.SetErrorMode(CollectAll)
+
+	err := wf.Execute(context.Background(), nil)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("Execute() error = %v, want *MultiError", err)
+	}
+	if len(multi.Errors) != 2 {
+		t.Errorf("Collected %d errors, want 2", len(multi.Errors))
+	}
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Errorf("Execute() error = %v, want both failures", err)
+	}
+
+	if len(ran) != 5 {
+		t.Errorf("Executed actions = %v, want all 5 actions to run", ran)
+	}
+
+	report := wf.Report()
+	if report.Success() {
+		t.Error("Report should not be successful")
+	}
+	failures := report.Failures()
+	if len(failures) != 2 || failures[0].Name != "check-b" || failures[1].Name != "lint-a" {
+		t.Errorf("Report failures = %v, want check-b and lint-a", failures)
+	}
+	for _, stage := range report.Stages {
+		if stage.Status != gostage.StatusFailed {
+			t.Errorf("Stage %s status = %s, want %s", stage.ID, stage.Status, gostage.StatusFailed)
+		}
+	}
+}
+
+func TestWorkflowDynamicActionFailureCollected(t *testing.T) {
+	errDynamic := errors.New("dynamic failure")
+	var ran []string
+
+	wf := NewWorkflow("dynamic", "Dynamic", "Workflow adding a failing action")
+	wf.SetErrorMode(CollectAll)
+	stage := NewStage("main", "Main", "Main stage")
+	stage.AddAction(workflows.NewFuncAction("generate", "", func(ctx *gostage.ActionContext) error {
+		ctx.AddDynamicAction(workflows.NewFuncAction("dynamic", "", func(ctx *gostage.ActionContext) error {
+			ran = append(ran, "dynamic")
+			return errDynamic
+		}))
+		return nil
+	}))
+	stage.AddAction(workflows.NewFuncAction("last", "", func(ctx *gostage.ActionContext) error {
+		ran = append(ran, "last")
+		return nil
+	}))
+	wf.AddStage(stage)
+
+	err := wf.Execute(context.Background(), nil)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || !errors.Is(err, errDynamic) {
+		t.Fatalf("Execute() error = %v, want the dynamic failure collected", err)
+	}
+	var wfErr *WorkflowError
+	if !errors.As(multi.Errors[0], &wfErr) || wfErr.StageID != "main" || wfErr.Action != "dynamic" {
+		t.Errorf("Collected error = %#v, want action dynamic of stage main", multi.Errors[0])
+	}
+	if fmt.Sprint(ran) != "[dynamic last]" {
+		t.Errorf("Executed actions = %v, want the stage to go on after the dynamic failure", ran)
+	}
+
+	failures := wf.Report().Failures()
+	if len(failures) != 1 || failures[0].Name != "dynamic" {
+		t.Errorf("Report failures = %v, want the dynamic action", failures)
+	}
+	if actions := wf.Report().Stages[0].Actions; len(actions) != 3 {
+		t.Errorf("Report has %d actions, want 3", len(actions))
+	}
+}
+
+func TestWorkflowContinueOnErrorStage(t *testing.T) {
+	for _, mode := range []ErrorMode{FailFast, CollectAll} {
+		t.Run(mode.String(), func(t *testing.T) {
+			var ran []string
+			wf, _, errSecond := buildFailingWorkflow(&ran)
+			wf.SetErrorMode(mode)
+
+			// Tolerate failures in the first stage only
+			wf.stages["validate"].SetContinueOnError(true)
+
+			err := wf.Execute(context.Background(), nil)
+			if !errors.Is(err, errSecond) {
+				t.Fatalf("Execute() error = %v, want second failure", err)
+			}
+
+			var multi *MultiError
+			if mode == CollectAll {
+				if !errors.As(err, &multi) || len(multi.Errors) != 1 {
+					t.Errorf("Execute() error = %v, want exactly one collected error", err)
+				}
+			}
+
+			// Tolerated failures are still reported
+			failures := wf.Report().Failures()
+			if len(failures) != 2 {
+				t.Errorf("Report failures = %d, want 2", len(failures))
+			}
+		})
+	}
+}
+
+func TestWorkflowReportSkipsDisabled(t *testing.T) {
+	wf := NewWorkflow("skip", "Skip", "Workflow with disabled elements")
+
+	stage := NewStage("main", "Main", "Main stage")
+	stage.AddAction(workflows.NewFuncAction("disable-next", "", func(ctx *gostage.ActionContext) error {
+		ctx.DisableAction("skipped")
+		return nil
+	}))
+	stage.AddAction(workflows.NewFuncAction("skipped", "", func(ctx *gostage.ActionContext) error {
+		return errors.New("should not run")
+	}))
+	wf.AddStage(stage)
+
+	other := NewStage("other", "Other", "Disabled stage")
+	other.AddAction(workflows.NewFuncAction("never", "", func(ctx *gostage.ActionContext) error {
+		return errors.New("should not run")
+	}))
+	wf.AddStage(other)
+	wf.DisableStage("other")
+
+	if err := wf.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	report := wf.Report()
+	if len(report.Stages) != 2 {
+		t.Fatalf("Report has %d stages, want 2", len(report.Stages))
+	}
+	if report.Stages[1].Status != gostage.StatusSkipped {
+		t.Errorf("Disabled stage status = %s, want %s", report.Stages[1].Status, gostage.StatusSkipped)
+	}
+
+	actions := report.Stages[0].Actions
+	if len(actions) != 2 || actions[1].Name != "skipped" || actions[1].Status != gostage.StatusSkipped {
+		t.Errorf("Stage actions = %+v, want skipped action recorded", actions)
+	}
+}
@@ -0,0 +1,42 @@
+package workflows
+
+import (
+	"github.com/davidroman0O/gostage"
+)
+
+// FuncAction is an action running a function, for steps too small to deserve
+// their own action type
+type FuncAction struct {
+	gostage.BaseAction
+	fn func(ctx *gostage.ActionContext) error
+}
+
+// NewFuncAction creates an action running fn when executed
+func NewFuncAction(name, description string, fn func(ctx *gostage.ActionContext) error) *FuncAction {
+	return &FuncAction{
+		BaseAction: gostage.NewBaseAction(name, description),
+		fn:         fn,
+	}
+}
+
+// NewNoOpAction creates an action doing nothing, useful as a placeholder
+// while building a workflow or as a marker in tests
+func NewNoOpAction(name string) *FuncAction {
+	return NewFuncAction(name, "No-op action "+name, nil)
+}
+
+// WithTags adds tags to the action
+func (a *FuncAction) WithTags(tags ...string) *FuncAction {
+	for _, tag := range tags {
+		a.AddTag(tag)
+	}
+	return a
+}
+
+// Execute runs the action's function
+func (a *FuncAction) Execute(ctx *gostage.ActionContext) error {
+	if a.fn == nil {
+		return nil
+	}
+	return a.fn(ctx)
+}
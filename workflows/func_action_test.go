@@ -0,0 +1,52 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/davidroman0O/gostage"
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestFuncAction(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	var ran []string
+	record := NewFuncAction("record", "Record the node", func(ctx *gostage.ActionContext) error {
+		ran = append(ran, ctx.Action.Name())
+		return ctx.Store().Put("node", 2)
+	}).WithTags("bmc", "node")
+
+	workflow := gostage.NewWorkflow("func", "Func", "Workflow of function actions")
+	stage := gostage.NewStage("main", "Main", "Main stage")
+	stage.AddAction(NewNoOpAction("placeholder"))
+	stage.AddAction(record)
+	workflow.AddStage(stage)
+
+	if err := gostage.NewRunner().Execute(context.Background(), workflow, nil); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"record"}) {
+		t.Errorf("Executed actions = %v, want [record]", ran)
+	}
+	if node, err := store.Get[int](workflow.Store, "node"); err != nil || node != 2 {
+		t.Errorf("node = %v, %v, want 2", node, err)
+	}
+
+	if record.Name() != "record" || record.Description() != "Record the node" {
+		t.Errorf("Action = %s (%s)", record.Name(), record.Description())
+	}
+	if !reflect.DeepEqual(record.Tags(), []string{"bmc", "node"}) {
+		t.Errorf("Tags() = %v, want [bmc node]", record.Tags())
+	}
+
+	failing := NewFuncAction("fail", "Fail", func(ctx *gostage.ActionContext) error { return errFailed })
+	if err := failing.Execute(&gostage.ActionContext{}); !errors.Is(err, errFailed) {
+		t.Errorf("Execute() error = %v, want the function's error", err)
+	}
+	if err := NewNoOpAction("noop").Execute(&gostage.ActionContext{}); err != nil {
+		t.Errorf("No-op Execute() error = %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+var (
+	// ErrValueTooLarge is returned when a value exceeds the maximum value size
+	ErrValueTooLarge = errors.New("value too large")
+	// ErrStoreFull is returned when a value would make the store exceed its
+	// maximum total size
+	ErrStoreFull = errors.New("store full")
+)
+
+// EvictionPolicy chooses the key a BoundedStore evicts once it holds too many keys
+type EvictionPolicy int
+
+const (
+	// EvictLRU evicts the key least recently written or read
+	EvictLRU EvictionPolicy = iota
+	// EvictFIFO evicts the key inserted first, overwrites keep their position
+	EvictFIFO
+)
+
+// String returns the name of the policy
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictFIFO:
+		return "fifo"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// BoundedStore enforces size limits on the writes to a store, protecting
+// long running workflows from actions storing huge blobs. Writes through the
+// wrapper or the helpers of this package that break a limit fail; those made
+// directly on the wrapped store are undone by Verify, which the workflow
+// engine runs after each action. The size of a value is the length of its
+// JSON encoding, or its estimated memory size when it cannot be encoded.
+type BoundedStore struct {
+	guardedStore
+	remove func()
+
+	mu            sync.Mutex
+	maxValueBytes int
+	maxTotalBytes int
+	maxKeys       int
+	policy        EvictionPolicy
+
+	// total is the size of all values in the store, sizes the size of each
+	// and values the value it was computed for, kept up to date as entries
+	// are written through this package and as Verify finds the others
+	total  int64
+	sizes  map[string]int64
+	values map[string]interface{}
+
+	// uses orders the keys written since the BoundedStore was installed by
+	// insertion or last use, depending on the policy. Only these keys count
+	// towards the maximum number of keys and may be evicted.
+	uses  map[string]uint64
+	clock uint64
+}
+
+// NewBoundedStore installs a BoundedStore on s, without limits until they
+// are set, see WithMaxValueBytes, WithMaxTotalBytes and WithMaxKeys
+func NewBoundedStore(s *store.KVStore) *BoundedStore {
+	b := &BoundedStore{
+		guardedStore: guardedStore{s},
+		sizes:        make(map[string]int64),
+		values:       make(map[string]interface{}),
+		uses:         make(map[string]uint64),
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	// Values already stored count towards the total
+	for _, key := range s.ListKeys() {
+		if value, err := readValue(s, key); err == nil {
+			b.record(key, value)
+		}
+	}
+	b.remove = install(s, b)
+	return b
+}
+
+// Close stops enforcing the limits on the wrapped store
+func (b *BoundedStore) Close() {
+	b.remove()
+}
+
+// WithMaxValueBytes rejects values larger than n bytes, 0 disables the limit
+func (b *BoundedStore) WithMaxValueBytes(n int) *BoundedStore {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxValueBytes = n
+	return b
+}
+
+// WithMaxTotalBytes rejects values that would bring the size of all values
+// in the store above n bytes, 0 disables the limit. Deleting keys frees space.
+func (b *BoundedStore) WithMaxTotalBytes(n int) *BoundedStore {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxTotalBytes = n
+	return b
+}
+
+// WithMaxKeys evicts keys once a write brings the store above n keys that
+// have not expired, choosing them with the policy. Only the keys written since
+// the BoundedStore was installed count and may be evicted, so the entries the
+// workflow stored beforehand are kept. The key just written is never evicted,
+// nor are keys another guard refuses to delete, like frozen keys. 0 disables
+// the limit.
+func (b *BoundedStore) WithMaxKeys(n int, policy EvictionPolicy) *BoundedStore {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxKeys = n
+	b.policy = policy
+	return b
+}
+
+// TotalBytes returns the size of all values in the store, expired or not
+func (b *BoundedStore) TotalBytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total
+}
+
+// Touch marks key as used, delaying its eviction under EvictLRU. Reading a
+// key with Get touches it, gostage's store.Get cannot.
+func (b *BoundedStore) Touch(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.policy != EvictLRU {
+		return
+	}
+	if _, ok := b.uses[key]; ok {
+		b.use(key)
+	}
+}
+
+// use records key as the most recent one. It must be called with mu held.
+func (b *BoundedStore) use(key string) {
+	b.clock++
+	b.uses[key] = b.clock
+}
+
+// record sizes the value now stored under key
+func (b *BoundedStore) record(key string, value interface{}) {
+	size := valueSize(value)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += size - b.sizes[key]
+	b.sizes[key] = size
+	b.values[key] = value
+}
+
+// forget stops sizing key once it was deleted or expired
+func (b *BoundedStore) forget(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total -= b.sizes[key]
+	delete(b.sizes, key)
+	delete(b.values, key)
+	delete(b.uses, key)
+}
+
+// forgetRemoved stops sizing the keys the store no longer holds, and reports
+// whether there were any. Expired entries count towards the total until
+// then, which happens when they are in the way of a write and on Verify.
+func (b *BoundedStore) forgetRemoved(s *store.KVStore) bool {
+	live := make(map[string]bool)
+	for _, key := range s.ListKeys() {
+		live[key] = true
+	}
+
+	b.mu.Lock()
+	var removed []string
+	for key := range b.sizes {
+		if !live[key] {
+			removed = append(removed, key)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, key := range removed {
+		b.forget(key)
+	}
+	return len(removed) > 0
+}
+
+// beforeSet rejects values too large for the limits
+func (b *BoundedStore) beforeSet(s *store.KVStore, key string, value interface{}) error {
+	size := valueSize(value)
+	err := b.check(key, size)
+	if errors.Is(err, ErrStoreFull) && b.forgetRemoved(s) {
+		return b.check(key, size)
+	}
+	return err
+}
+
+// beforeDelete lets every key be deleted
+func (b *BoundedStore) beforeDelete(*store.KVStore, string) error {
+	return nil
+}
+
+// read touches the keys read with Get
+func (b *BoundedStore) read(key string) {
+	b.Touch(key)
+}
+
+// check returns an error when storing size bytes under key breaks a limit
+func (b *BoundedStore) check(key string, size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.maxValueBytes > 0 && size > int64(b.maxValueBytes) {
+		return fmt.Errorf("%w: '%s' is %d bytes, the limit is %d", ErrValueTooLarge, key, size, b.maxValueBytes)
+	}
+	projected := b.total - b.sizes[key] + size
+	if b.maxTotalBytes > 0 && projected > int64(b.maxTotalBytes) {
+		return fmt.Errorf("%w: storing %d bytes under '%s' would bring the store to %d bytes, the limit is %d",
+			ErrStoreFull, size, key, projected, b.maxTotalBytes)
+	}
+	return nil
+}
+
+// changed keeps the total and the order of the keys up to date, and evicts
+// keys once a new key brings the store above its maximum number of keys
+func (b *BoundedStore) changed(s *store.KVStore, key string, value interface{}, deleted bool) {
+	if deleted {
+		b.forget(key)
+		return
+	}
+	b.record(key, value)
+
+	b.mu.Lock()
+	// Overwrites keep their position under FIFO
+	if _, tracked := b.uses[key]; !tracked || b.policy == EvictLRU {
+		b.use(key)
+	}
+	maxKeys := b.maxKeys
+	b.mu.Unlock()
+
+	if maxKeys > 0 {
+		b.evict(s, key, maxKeys)
+	}
+}
+
+// verify checks the values written directly to the store since the last
+// call, restoring the previous value of those breaking a limit, or deleting
+// them when they are new
+func (b *BoundedStore) verify(s *store.KVStore) error {
+	b.forgetRemoved(s)
+
+	keys := s.ListKeys()
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value, err := readValue(s, key)
+		if err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		previous, known := b.values[key]
+		b.mu.Unlock()
+		if known && reflect.TypeOf(previous) == reflect.TypeOf(value) && reflect.DeepEqual(previous, value) {
+			continue
+		}
+
+		if err := b.check(key, valueSize(value)); err != nil {
+			if known {
+				_ = s.Put(key, previous)
+			} else {
+				s.Delete(key)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		b.changed(s, key, value, false)
+	}
+	return errors.Join(errs...)
+}
+
+// evict removes the keys chosen by the policy until the store holds no more
+// than maxKeys live keys written through it, key excluded
+func (b *BoundedStore) evict(s *store.KVStore, key string, maxKeys int) {
+	live := make(map[string]bool)
+	for _, existing := range s.ListKeys() {
+		live[existing] = true
+	}
+
+	b.mu.Lock()
+	candidates := make([]string, 0, len(b.uses))
+	for tracked := range b.uses {
+		if tracked != key && live[tracked] {
+			candidates = append(candidates, tracked)
+		}
+	}
+	excess := len(candidates) + 1 - maxKeys
+	if excess <= 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	// Oldest first
+	sort.Slice(candidates, func(i, j int) bool {
+		return b.uses[candidates[i]] < b.uses[candidates[j]]
+	})
+	b.mu.Unlock()
+
+	// Deleting tells the guards again, so the lock must be released. Another
+	// guard may refuse to delete a key, the next oldest is evicted instead.
+	for _, victim := range candidates {
+		if excess == 0 {
+			return
+		}
+		if deleted, _ := remove(s, victim); deleted {
+			excess--
+		}
+	}
+}
+
+// valueSize returns the length of the JSON encoding of a value, or its
+// estimated memory size when it cannot be encoded
+func valueSize(value any) int64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return estimateSize(value)
+	}
+	return int64(len(data))
+}
@@ -0,0 +1,229 @@
+package kvstore
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestBoundedStore(t *testing.T) {
+	t.Run("Oversized value", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxValueBytes(64)
+
+		if err := s.Put("small", strings.Repeat("a", 10)); err != nil {
+			t.Fatalf("Put() of a small value error = %v", err)
+		}
+		err := s.Put("blob", strings.Repeat("a", 100))
+		if !errors.Is(err, ErrValueTooLarge) {
+			t.Fatalf("Put() error = %v, want ErrValueTooLarge", err)
+		}
+		if _, err := store.Get[string](s.KVStore, "blob"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Oversized value was stored: %v", err)
+		}
+		if err := s.PutWithTTL("blob", []byte(strings.Repeat("a", 100)), time.Hour); !errors.Is(err, ErrValueTooLarge) {
+			t.Errorf("PutWithTTL() error = %v, want ErrValueTooLarge", err)
+		}
+	})
+
+	t.Run("Total cap", func(t *testing.T) {
+		// Each value encodes to 22 bytes: 20 characters and the quotes
+		value := strings.Repeat("a", 20)
+		s := NewBoundedStore(store.NewKVStore()).WithMaxTotalBytes(50)
+
+		if err := s.Put("first", value); err != nil {
+			t.Fatalf("Put(first) error = %v", err)
+		}
+		meta := store.NewMetadata()
+		meta.AddTag("node")
+		if err := s.PutWithMetadata("second", value, meta); err != nil {
+			t.Fatalf("PutWithMetadata(second) error = %v", err)
+		}
+		if err := s.Put("third", value); !errors.Is(err, ErrStoreFull) {
+			t.Fatalf("Put(third) error = %v, want ErrStoreFull", err)
+		}
+
+		// Replacing a value only counts the new one
+		if err := s.Put("second", strings.Repeat("b", 20)); err != nil {
+			t.Errorf("Put() replacing a value error = %v", err)
+		}
+
+		// Deleting frees space
+		s.Delete("first")
+		if err := s.Put("third", value); err != nil {
+			t.Errorf("Put(third) after a delete error = %v", err)
+		}
+		if got, err := store.Get[string](s.KVStore, "third"); err != nil || got != value {
+			t.Errorf("third = %q, %v", got, err)
+		}
+		if tagged, _ := s.HasTag("second", "node"); !tagged {
+			t.Error("Metadata of a bounded put was lost")
+		}
+	})
+
+	t.Run("Writes bypassing the wrapper", func(t *testing.T) {
+		raw := store.NewKVStore()
+		raw.Put("existing", strings.Repeat("a", 20))
+		s := NewBoundedStore(raw).WithMaxValueBytes(64).WithMaxTotalBytes(50)
+
+		// Direct writes are undone once verified, helpers fail right away
+		if err := raw.PutWithMetadata("blob", strings.Repeat("a", 100), nil); err != nil {
+			t.Fatalf("PutWithMetadata() on the wrapped store error = %v", err)
+		}
+		if err := Verify(raw); !errors.Is(err, ErrValueTooLarge) {
+			t.Errorf("Verify() error = %v, want ErrValueTooLarge", err)
+		}
+		if _, err := store.Get[string](raw, "blob"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Oversized value was kept: %v", err)
+		}
+		if err := AppendTo(raw, "list", strings.Repeat("a", 30)); !errors.Is(err, ErrStoreFull) {
+			t.Errorf("AppendTo() error = %v, want ErrStoreFull", err)
+		}
+
+		// The total follows every change of the store once verified
+		if got := s.TotalBytes(); got != 22 {
+			t.Errorf("TotalBytes() = %d, want the 22 bytes stored before", got)
+		}
+		raw.Put("small", 1)
+		raw.Delete("existing")
+		if err := Verify(raw); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if got := s.TotalBytes(); got != 1 {
+			t.Errorf("TotalBytes() = %d, want 1", got)
+		}
+
+		s.Close()
+		raw.Put("blob", strings.Repeat("a", 100))
+		if err := Verify(raw); err != nil {
+			t.Errorf("Verify() once closed error = %v", err)
+		}
+	})
+
+	t.Run("Expired entries do not count", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxTotalBytes(30)
+		if err := s.PutWithTTL("stale", strings.Repeat("a", 20), time.Nanosecond); err != nil {
+			t.Fatalf("PutWithTTL() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+		if err := s.Put("fresh", strings.Repeat("a", 20)); err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+	})
+}
+
+func TestBoundedStoreEviction(t *testing.T) {
+	keys := func(s *BoundedStore) []string {
+		list := s.ListKeys()
+		sort.Strings(list)
+		return list
+	}
+
+	t.Run("FIFO evicts the oldest insertion", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxKeys(3, EvictFIFO)
+		for _, key := range []string{"node1", "node2", "node3"} {
+			s.Put(key, "booted")
+		}
+		// Overwrites and reads do not move a key under FIFO
+		s.Put("node1", "flashed")
+		Get[string](s.KVStore, "node1")
+
+		if err := s.Put("node4", "booted"); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if got, want := keys(s), []string{"node2", "node3", "node4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("LRU evicts the least recently used", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxKeys(3, EvictLRU)
+		for _, key := range []string{"node1", "node2", "node3"} {
+			s.Put(key, "booted")
+		}
+		// node1 is read and node2 rewritten, node3 becomes the least recent
+		if _, err := Get[string](s.KVStore, "node1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		s.Put("node2", "flashed")
+
+		s.Put("node4", "booted")
+		if got, want := keys(s), []string{"node1", "node2", "node4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+		s.Put("node5", "booted")
+		if got, want := keys(s), []string{"node2", "node4", "node5"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Just inserted key is never evicted", func(t *testing.T) {
+		for _, policy := range []EvictionPolicy{EvictLRU, EvictFIFO} {
+			s := NewBoundedStore(store.NewKVStore()).WithMaxKeys(1, policy)
+			for _, key := range []string{"a", "b", "c"} {
+				if err := s.Put(key, key); err != nil {
+					t.Fatalf("%s: Put(%s) error = %v", policy, key, err)
+				}
+				if got := keys(s); !reflect.DeepEqual(got, []string{key}) {
+					t.Errorf("%s: keys after Put(%s) = %v", policy, key, got)
+				}
+			}
+		}
+	})
+
+	t.Run("Direct writes and expired keys", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxKeys(2, EvictLRU)
+		s.PutWithTTL("stale", "x", time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		s.Put("first", 1)
+		// Written directly to the wrapped store, tracked once verified
+		s.KVStore.Put("direct", 2)
+		if err := Verify(s.KVStore); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		// The expired key does not count, evicting the oldest one is enough
+		s.Put("second", 3)
+		if got, want := keys(s), []string{"direct", "second"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+	})
+	t.Run("Keys stored before and guarded keys", func(t *testing.T) {
+		raw := store.NewKVStore()
+		raw.Put("config", "cluster")
+		s := NewBoundedStore(raw).WithMaxKeys(2, EvictFIFO)
+		frozen := NewFreezableStore(raw)
+		defer frozen.Close()
+
+		// The key stored before the wrapper is neither counted nor evicted,
+		// and the frozen key is skipped for the next oldest
+		s.Put("pinned", 1)
+		if err := frozen.Freeze("pinned"); err != nil {
+			t.Fatalf("Freeze() error = %v", err)
+		}
+		s.Put("first", 2)
+		s.Put("second", 3)
+		if got, want := keys(s), []string{"config", "pinned", "second"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Keys = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Removed keys are forgotten", func(t *testing.T) {
+		s := NewBoundedStore(store.NewKVStore()).WithMaxKeys(10, EvictLRU)
+		s.Put("deleted", 1)
+		s.PutWithTTL("stale", 2, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		s.Delete("deleted")
+		Verify(s.KVStore)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.uses) != 0 {
+			t.Errorf("uses = %v, want no entry left", s.uses)
+		}
+	})
+}
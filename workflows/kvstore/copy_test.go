@@ -0,0 +1,134 @@
+package kvstore
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type nodeInventory struct {
+	Name   string
+	Nodes  []int
+	Labels map[string]string
+	Disks  map[string][]string
+	Owner  *inventoryOwner
+}
+
+type inventoryOwner struct {
+	Team string
+	Tags []string
+}
+
+// inventoryManager is a handle, guarding its inventory with a lock
+type inventoryManager struct {
+	mu        sync.Mutex
+	inventory nodeInventory
+}
+
+func newInventory() nodeInventory {
+	return nodeInventory{
+		Name:   "cluster",
+		Nodes:  []int{1, 2, 3},
+		Labels: map[string]string{"role": "worker"},
+		Disks:  map[string][]string{"node1": {"emmc", "nvme"}},
+		Owner:  &inventoryOwner{Team: "infra", Tags: []string{"prod"}},
+	}
+}
+
+func TestGetReturnsDeepCopies(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("inventory", newInventory())
+	s.Put("inventory-ptr", func() *nodeInventory { inv := newInventory(); return &inv }())
+	s.Put("nodes", []int{1, 2, 3})
+	s.Put("labels", map[string][]string{"node1": {"a", "b"}})
+
+	t.Run("struct with nested maps and slices", func(t *testing.T) {
+		got, err := Get[nodeInventory](s, "inventory")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		got.Nodes[0] = 99
+		got.Labels["role"] = "changed"
+		got.Disks["node1"][0] = "changed"
+		got.Owner.Team = "changed"
+		got.Owner.Tags[0] = "changed"
+
+		again, _ := Get[nodeInventory](s, "inventory")
+		if !reflect.DeepEqual(again, newInventory()) {
+			t.Errorf("Stored value was modified through a returned copy: %+v", again)
+		}
+	})
+
+	t.Run("pointer to struct", func(t *testing.T) {
+		got, err := Get[*nodeInventory](s, "inventory-ptr")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		got.Name = "changed"
+		got.Nodes = append(got.Nodes[:1], 42)
+		got.Labels["new"] = "label"
+
+		again, _ := Get[*nodeInventory](s, "inventory-ptr")
+		if !reflect.DeepEqual(*again, newInventory()) {
+			t.Errorf("Stored value was modified through a returned pointer: %+v", again)
+		}
+	})
+
+	t.Run("GetOrDefault", func(t *testing.T) {
+		got, err := GetOrDefault[[]int](s, "nodes", nil)
+		if err != nil {
+			t.Fatalf("GetOrDefault() error = %v", err)
+		}
+		got[0] = 99
+
+		again, _ := GetOrDefault[[]int](s, "nodes", nil)
+		if !reflect.DeepEqual(again, []int{1, 2, 3}) {
+			t.Errorf("Stored slice was modified: %v", again)
+		}
+
+		fallback, err := GetOrDefault[[]int](s, "missing", []int{7})
+		if err != nil || !reflect.DeepEqual(fallback, []int{7}) {
+			t.Errorf("GetOrDefault() = %v, %v, want default", fallback, err)
+		}
+	})
+
+	t.Run("GetSliceOrEmpty", func(t *testing.T) {
+		got, _ := GetSliceOrEmpty[int](s, "nodes")
+		got[1] = 99
+
+		again, _ := GetSliceOrEmpty[int](s, "nodes")
+		if !reflect.DeepEqual(again, []int{1, 2, 3}) {
+			t.Errorf("Stored slice was modified: %v", again)
+		}
+	})
+
+	t.Run("GetMapOrEmpty", func(t *testing.T) {
+		got, _ := GetMapOrEmpty[string, []string](s, "labels")
+		got["node1"][0] = "changed"
+		got["node2"] = []string{"new"}
+
+		again, _ := GetMapOrEmpty[string, []string](s, "labels")
+		want := map[string][]string{"node1": {"a", "b"}}
+		if !reflect.DeepEqual(again, want) {
+			t.Errorf("Stored map was modified: %v", again)
+		}
+	})
+
+	t.Run("handles are shared", func(t *testing.T) {
+		manager := &inventoryManager{inventory: newInventory()}
+		s.Put("manager", manager)
+		s.Put("managed", struct{ Manager *inventoryManager }{manager})
+
+		if got, err := Get[*inventoryManager](s, "manager"); err != nil || got != manager {
+			t.Errorf("Get() = %p, %v, want the stored handle %p", got, err, manager)
+		}
+		got, err := Get[struct{ Manager *inventoryManager }](s, "managed")
+		if err != nil || got.Manager != manager {
+			t.Errorf("Get() = %+v, %v, want the stored handle", got, err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package kvstore
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
+)
+
+// StoreDiff describes how the live keys of two stores differ, keys being sorted
+type StoreDiff struct {
+	// OnlyInA lists the keys only the first store holds
+	OnlyInA []string
+	// OnlyInB lists the keys only the second store holds
+	OnlyInB []string
+	// Changed lists the keys both stores hold with different values or types
+	Changed []KeyChange
+}
+
+// KeyChange describes a key held by both stores with different values
+type KeyChange struct {
+	Key    string
+	TypeA  reflect.Type
+	TypeB  reflect.Type
+	ValueA interface{}
+	ValueB interface{}
+}
+
+// TypeChanged reports whether the key holds values of different types
+func (c KeyChange) TypeChanged() bool {
+	return c.TypeA != c.TypeB
+}
+
+// Empty reports whether the stores hold the same keys and values
+func (d StoreDiff) Empty() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the differences, one key per line
+func (d StoreDiff) String() string {
+	var b strings.Builder
+	for _, key := range d.OnlyInA {
+		fmt.Fprintf(&b, "- %s\n", key)
+	}
+	for _, key := range d.OnlyInB {
+		fmt.Fprintf(&b, "+ %s\n", key)
+	}
+	for _, change := range d.Changed {
+		if change.TypeChanged() {
+			fmt.Fprintf(&b, "~ %s: %v -> %v\n", change.Key, change.TypeA, change.TypeB)
+		} else {
+			fmt.Fprintf(&b, "~ %s: %v -> %v\n", change.Key, change.ValueA, change.ValueB)
+		}
+	}
+	return b.String()
+}
+
+// Diff compares the live keys of two stores, ignoring expired keys. Keys hold
+// the same value when their types match and their values are deeply equal;
+// metadata is not compared. Keys whose value cannot be read, see
+// RegisterType, are left out. Each store is read in turn, so the two are not
+// read at the same instant. Changed values are deep copies.
+func Diff(a, b *store.KVStore) StoreDiff {
+	var diff StoreDiff
+	if a == b {
+		return diff
+	}
+
+	entriesA := readableEntries(a)
+	entriesB := readableEntries(b)
+
+	for key, ea := range entriesA {
+		eb, ok := entriesB[key]
+		if !ok {
+			diff.OnlyInA = append(diff.OnlyInA, key)
+			continue
+		}
+		if !sameEntry(ea, true, eb, true) {
+			diff.Changed = append(diff.Changed, KeyChange{
+				Key:    key,
+				TypeA:  ea.Type(),
+				TypeB:  eb.Type(),
+				ValueA: deepcopy.Copy(ea.Value),
+				ValueB: deepcopy.Copy(eb.Value),
+			})
+		}
+	}
+	for key := range entriesB {
+		if _, ok := entriesA[key]; !ok {
+			diff.OnlyInB = append(diff.OnlyInB, key)
+		}
+	}
+
+	sort.Strings(diff.OnlyInA)
+	sort.Strings(diff.OnlyInB)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Key < diff.Changed[j].Key })
+	return diff
+}
+
+// readableEntries returns the live entries of a store whose value can be read
+func readableEntries(s *store.KVStore) map[string]entry {
+	unlock := lockStores(s)
+	defer unlock()
+
+	entries := make(map[string]entry)
+	for _, key := range s.ListKeys() {
+		if e, err := lookup(s, key); err == nil {
+			entries[key] = e
+		}
+	}
+	return entries
+}
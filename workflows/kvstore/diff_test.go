@@ -0,0 +1,62 @@
+package kvstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestDiff(t *testing.T) {
+	before := store.NewKVStore()
+	before.Put("cluster.name", "lab")
+	before.Put("node.1.ip", "192.168.1.101")
+	before.Put("node.2.ip", "192.168.1.102")
+	before.Put("node.1.ready", "yes")
+	before.Put("results", []renameResult{{Node: 1, Items: []string{"flash"}}})
+	before.PutWithTTL("lease", "expired", time.Nanosecond)
+
+	after := store.NewKVStore()
+	after.Put("cluster.name", "lab")
+	after.Put("node.1.ip", "192.168.1.111")
+	after.Put("node.1.ready", true)
+	after.Put("node.3.ip", "192.168.1.103")
+	after.Put("results", []renameResult{{Node: 1, Items: []string{"flash"}}})
+	after.PutWithTTL("token", "expired", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	diff := Diff(before, after)
+	if strings.Join(diff.OnlyInA, ",") != "node.2.ip" {
+		t.Errorf("OnlyInA = %v, want the removed key without expired ones", diff.OnlyInA)
+	}
+	if strings.Join(diff.OnlyInB, ",") != "node.3.ip" {
+		t.Errorf("OnlyInB = %v, want the added key without expired ones", diff.OnlyInB)
+	}
+	if len(diff.Changed) != 2 {
+		t.Fatalf("Changed = %+v, want 2 keys", diff.Changed)
+	}
+
+	ip := diff.Changed[0]
+	if ip.Key != "node.1.ip" || ip.TypeChanged() || ip.ValueA != "192.168.1.101" || ip.ValueB != "192.168.1.111" {
+		t.Errorf("Value change = %+v", ip)
+	}
+	ready := diff.Changed[1]
+	if ready.Key != "node.1.ready" || !ready.TypeChanged() || ready.TypeA.String() != "string" || ready.TypeB.String() != "bool" {
+		t.Errorf("Type change = %+v", ready)
+	}
+
+	summary := diff.String()
+	for _, line := range []string{"- node.2.ip", "+ node.3.ip", "~ node.1.ip: 192.168.1.101 -> 192.168.1.111", "~ node.1.ready: string -> bool"} {
+		if !strings.Contains(summary, line) {
+			t.Errorf("String() lacks %q:\n%s", line, summary)
+		}
+	}
+
+	if diff := Diff(before, before); !diff.Empty() {
+		t.Errorf("Diff() of a store with itself = %+v", diff)
+	}
+	if diff := Diff(before, mustFork(t, before)); !diff.Empty() {
+		t.Errorf("Diff() of a store with its fork = %+v", diff)
+	}
+}
@@ -0,0 +1,92 @@
+package kvstore
+
+import (
+	"reflect"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
+)
+
+// entry is the value and metadata stored under a key, as read through the
+// store's API. The store does not expose when an entry expires.
+type entry struct {
+	Value    interface{}
+	Metadata *store.Metadata
+}
+
+// Type returns the type of the entry's value
+func (e entry) Type() reflect.Type {
+	return reflect.TypeOf(e.Value)
+}
+
+// lookup reads the entry stored under key, its value as stored and a copy of
+// its metadata. Entries without metadata, or with empty metadata, have none.
+func lookup(s *store.KVStore, key string) (entry, error) {
+	value, err := readValue(s, key)
+	if err != nil {
+		return entry{}, err
+	}
+	e := entry{Value: value}
+	// The store hands out the metadata it holds, which its writes update
+	if meta, err := s.GetMetadata(key); err == nil && !emptyMetadata(meta) {
+		e.Metadata = cloneMetadata(meta)
+	}
+	return e, nil
+}
+
+// liveEntries reads the entries of a store that have not expired. Keys
+// deleted while they are read are left out.
+func liveEntries(s *store.KVStore) (map[string]entry, error) {
+	entries := make(map[string]entry)
+	for _, key := range s.ListKeys() {
+		e, err := lookup(s, key)
+		if isMissing(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = e
+	}
+	return entries, nil
+}
+
+// cloneEntry returns a deep copy of an entry, sharing no references with it
+func cloneEntry(e entry) entry {
+	copied := entry{Value: deepcopy.Copy(e.Value)}
+	if e.Metadata != nil {
+		copied.Metadata = cloneMetadata(e.Metadata)
+	}
+	return copied
+}
+
+// putEntry stores an entry under key, without expiry
+func putEntry(s *store.KVStore, key string, e entry) error {
+	meta := e.Metadata
+	if meta == nil {
+		// An overwritten entry keeps its metadata unless new metadata is
+		// given, the entry written replaces it with none
+		meta = store.NewMetadata()
+	}
+	return s.PutWithMetadata(key, e.Value, meta)
+}
+
+// emptyMetadata reports whether metadata holds nothing but timestamps
+func emptyMetadata(meta *store.Metadata) bool {
+	return len(meta.Tags) == 0 && len(meta.Properties) == 0 && meta.Description == ""
+}
+
+// cloneMetadata returns a deep copy of entry metadata
+func cloneMetadata(meta *store.Metadata) *store.Metadata {
+	copied := &store.Metadata{
+		Tags:        append([]string{}, meta.Tags...),
+		Properties:  make(map[string]interface{}, len(meta.Properties)),
+		Description: meta.Description,
+		CreatedAt:   meta.CreatedAt,
+		UpdatedAt:   meta.UpdatedAt,
+	}
+	for k, v := range meta.Properties {
+		copied.Properties[k] = deepcopy.Copy(v)
+	}
+	return copied
+}
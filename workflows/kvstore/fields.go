@@ -0,0 +1,49 @@
+package kvstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// UpdateFieldResetTTL updates a single field of the object stored under key,
+// using the dot notation of store.UpdateField, and restarts its expiry so the
+// key expires ttl from now. Where store.UpdateField keeps the existing expiry,
+// this treats the update as fresh activity, as renewing a lease does.
+func UpdateFieldResetTTL(s *store.KVStore, key, fieldPath string, fieldValue interface{}, ttl time.Duration) error {
+	if fieldPath == "" {
+		return errors.New("fieldPath cannot be empty")
+	}
+	return UpdateFieldsWithTTL(s, key, map[string]interface{}{fieldPath: fieldValue}, ttl)
+}
+
+// UpdateFieldsWithTTL updates several fields of the object stored under key,
+// as store.UpdateFields does, and sets its expiry to ttl from now. A ttl of
+// zero or less removes the expiry. The update and the new expiry are written
+// together, keeping the metadata of the key, and the key is left unchanged
+// when a field cannot be set. With no fields only the expiry is reset. It
+// returns store.ErrNotFound or store.ErrExpired when the key holds no live
+// value.
+func UpdateFieldsWithTTL(s *store.KVStore, key string, fields map[string]interface{}, ttl time.Duration) error {
+	if key == "" {
+		return errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	var updated interface{}
+	var err error
+	if len(fields) > 0 {
+		updated, err = updatedValue(s, key, func(scratch *store.KVStore) error {
+			return scratch.UpdateFields(key, fields)
+		})
+	} else {
+		updated, err = readValue(s, key)
+	}
+	if err != nil {
+		return err
+	}
+	return set(s, key, updated, func() error { return s.PutWithTTLAndMetadata(key, updated, ttl, nil) })
+}
@@ -0,0 +1,122 @@
+package kvstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type leaseConfig struct {
+	Holder   string
+	Settings struct {
+		Replicas int
+	}
+}
+
+func TestUpdateFieldsTTL(t *testing.T) {
+	// liveAfter reports whether key is still live once d has elapsed
+	liveAfter := func(s *store.KVStore, key string, d time.Duration) bool {
+		time.Sleep(d)
+		_, err := store.Get[leaseConfig](s, key)
+		return err == nil
+	}
+
+	newStore := func(t *testing.T, ttl time.Duration) *store.KVStore {
+		t.Helper()
+		s := store.NewKVStore()
+		if err := s.PutWithTTLAndMetadata("lease", leaseConfig{Holder: "node1"}, ttl, store.NewMetadata()); err != nil {
+			t.Fatalf("PutWithTTL() error = %v", err)
+		}
+		return s
+	}
+
+	t.Run("UpdateField keeps the expiry", func(t *testing.T) {
+		s := newStore(t, 50*time.Millisecond)
+		if err := s.UpdateField("lease", "Holder", "node2"); err != nil {
+			t.Fatalf("UpdateField() error = %v", err)
+		}
+		if err := s.UpdateFields("lease", map[string]interface{}{"Settings.Replicas": 3}); err != nil {
+			t.Fatalf("UpdateFields() error = %v", err)
+		}
+		if liveAfter(s, "lease", 100*time.Millisecond) {
+			t.Error("Lease outlived its expiry after UpdateField()")
+		}
+	})
+
+	t.Run("UpdateFieldResetTTL restarts the expiry", func(t *testing.T) {
+		s := newStore(t, 50*time.Millisecond)
+		if err := UpdateFieldResetTTL(s, "lease", "Holder", "node2", time.Hour); err != nil {
+			t.Fatalf("UpdateFieldResetTTL() error = %v", err)
+		}
+		if !liveAfter(s, "lease", 100*time.Millisecond) {
+			t.Fatal("Lease expired, want it renewed for an hour")
+		}
+		lease, err := store.Get[leaseConfig](s, "lease")
+		if err != nil || lease.Holder != "node2" {
+			t.Errorf("Get() = %+v, %v", lease, err)
+		}
+
+		// A shorter ttl shortens the lease
+		if err := UpdateFieldResetTTL(s, "lease", "Holder", "node3", 50*time.Millisecond); err != nil {
+			t.Fatalf("UpdateFieldResetTTL() error = %v", err)
+		}
+		if liveAfter(s, "lease", 100*time.Millisecond) {
+			t.Error("Lease outlived its shortened expiry")
+		}
+	})
+
+	t.Run("UpdateFieldsWithTTL extends the expiry", func(t *testing.T) {
+		s := newStore(t, 50*time.Millisecond)
+		updatedAt := time.Now()
+		fields := map[string]interface{}{"Holder": "node4", "Settings.Replicas": 2}
+		if err := UpdateFieldsWithTTL(s, "lease", fields, time.Minute); err != nil {
+			t.Fatalf("UpdateFieldsWithTTL() error = %v", err)
+		}
+
+		// Still live after the original ttl has elapsed
+		time.Sleep(100 * time.Millisecond)
+		lease, err := store.Get[leaseConfig](s, "lease")
+		if err != nil || lease.Holder != "node4" || lease.Settings.Replicas != 2 {
+			t.Fatalf("Get() = %+v, %v", lease, err)
+		}
+		if meta, _ := s.GetMetadata("lease"); meta.UpdatedAt.Before(updatedAt) {
+			t.Errorf("UpdatedAt = %v, want bumped", meta.UpdatedAt)
+		}
+
+		// No fields renews the lease only, no ttl removes the expiry
+		if err := UpdateFieldsWithTTL(s, "lease", nil, 50*time.Millisecond); err != nil {
+			t.Fatalf("UpdateFieldsWithTTL() error = %v", err)
+		}
+		if err := UpdateFieldsWithTTL(s, "lease", nil, 0); err != nil {
+			t.Fatalf("UpdateFieldsWithTTL() error = %v", err)
+		}
+		if !liveAfter(s, "lease", 100*time.Millisecond) {
+			t.Error("Lease expired, want it to never expire")
+		}
+	})
+
+	t.Run("Failed updates change nothing", func(t *testing.T) {
+		s := newStore(t, 50*time.Millisecond)
+		if err := UpdateFieldResetTTL(s, "lease", "Missing", 1, time.Hour); err == nil {
+			t.Fatal("UpdateFieldResetTTL() expected an error for an unknown field")
+		}
+		if liveAfter(s, "lease", 100*time.Millisecond) {
+			t.Error("Failed update reset the expiry")
+		}
+
+		if err := UpdateFieldsWithTTL(s, "missing", nil, time.Hour); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("UpdateFieldsWithTTL() on a missing key error = %v, want ErrNotFound", err)
+		}
+
+		s.PutWithTTL("expired", leaseConfig{}, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		if err := UpdateFieldResetTTL(s, "expired", "Holder", "node1", time.Hour); !errors.Is(err, store.ErrExpired) {
+			t.Errorf("UpdateFieldResetTTL() on an expired key error = %v, want ErrExpired", err)
+		}
+		if err := UpdateFieldResetTTL(s, "lease", "", 1, time.Hour); err == nil {
+			t.Error("UpdateFieldResetTTL() expected an error for an empty field path")
+		}
+	})
+}
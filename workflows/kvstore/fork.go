@@ -0,0 +1,128 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// ErrMergeConflict is returned by MergeFork with the Error strategy when a key
+// changed in the fork was also changed in the destination since the fork
+var ErrMergeConflict = errors.New("merge conflict")
+
+// Fork returns a deep copy of the store, keeping the type and metadata of
+// every live entry. Writes to the fork are not seen by the store, and writes
+// to the store are not seen by the fork, until MergeFork merges them. It fails
+// when a value cannot be read, see RegisterType.
+func Fork(s *store.KVStore) (*store.KVStore, error) {
+	unlock := lockStores(s)
+	entries, err := liveEntries(s)
+	unlock()
+	if err != nil {
+		return nil, fmt.Errorf("forking the store: %w", err)
+	}
+
+	fork := store.NewKVStore()
+	for key, e := range entries {
+		if err := putEntry(fork, key, cloneEntry(e)); err != nil {
+			return nil, fmt.Errorf("forking key '%s': %w", key, err)
+		}
+	}
+	return fork, nil
+}
+
+// MergeFork merges into dst the keys added, changed or deleted in fork since
+// it was forked from base, and returns the keys in conflict. A key conflicts
+// when dst no longer holds the value it had in base, typically because another
+// fork of base was merged first. The strategy resolves conflicts as in
+// store.Merge: Skip keeps the value of dst, Overwrite applies the fork's, and
+// Error merges nothing and fails with ErrMergeConflict. The three stores must
+// be distinct.
+func MergeFork(dst, base, fork *store.KVStore, strategy store.MergeStrategy) ([]string, error) {
+	if dst == base || dst == fork || base == fork {
+		return nil, errors.New("merging a fork requires distinct stores")
+	}
+
+	unlock := lockStores(dst, base, fork)
+	defer unlock()
+
+	before, err := liveEntries(base)
+	if err != nil {
+		return nil, fmt.Errorf("reading the base of the fork: %w", err)
+	}
+	after, err := liveEntries(fork)
+	if err != nil {
+		return nil, fmt.Errorf("reading the fork: %w", err)
+	}
+	return mergeFork(dst, before, after, strategy)
+}
+
+// mergeFork implements MergeFork with the entries of the base and the fork,
+// holding the lock of the three stores
+func mergeFork(dst *store.KVStore, base, fork map[string]entry, strategy store.MergeStrategy) ([]string, error) {
+	// Keys written or deleted in the fork
+	keys := make(map[string]bool)
+	for key := range base {
+		keys[key] = true
+	}
+	for key := range fork {
+		keys[key] = true
+	}
+
+	var changed, conflicts []string
+	for key := range keys {
+		before, inBase := base[key]
+		after, inFork := fork[key]
+		if sameEntry(before, inBase, after, inFork) {
+			continue
+		}
+		changed = append(changed, key)
+
+		current, err := lookup(dst, key)
+		if err != nil && !isMissing(err) {
+			return nil, fmt.Errorf("reading key '%s': %w", key, err)
+		}
+		inDst := err == nil
+		if !sameEntry(before, inBase, current, inDst) && !sameEntry(after, inFork, current, inDst) {
+			conflicts = append(conflicts, key)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(conflicts)
+
+	if len(conflicts) > 0 && strategy == store.Error {
+		return conflicts, fmt.Errorf("%w on keys: %s", ErrMergeConflict, strings.Join(conflicts, ", "))
+	}
+
+	for _, key := range changed {
+		if strategy == store.Skip && contains(conflicts, key) {
+			continue
+		}
+		if e, ok := fork[key]; ok {
+			if err := setEntry(dst, key, cloneEntry(e)); err != nil {
+				return conflicts, fmt.Errorf("merging key '%s': %w", key, err)
+			}
+		} else if _, err := remove(dst, key); err != nil {
+			return conflicts, fmt.Errorf("merging the deletion of key '%s': %w", key, err)
+		}
+	}
+	return conflicts, nil
+}
+
+// sameEntry reports whether two optional entries hold the same value
+func sameEntry(a entry, aOK bool, b entry, bOK bool) bool {
+	if !aOK || !bOK {
+		return aOK == bOK
+	}
+	return a.Type() == b.Type() && reflect.DeepEqual(a.Value, b.Value)
+}
+
+// contains reports whether a sorted slice holds a key
+func contains(sorted []string, key string) bool {
+	i := sort.SearchStrings(sorted, key)
+	return i < len(sorted) && sorted[i] == key
+}
@@ -0,0 +1,139 @@
+package kvstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// mustFork forks s, failing the test if it cannot be forked
+func mustFork(t *testing.T, s *store.KVStore) *store.KVStore {
+	t.Helper()
+	fork, err := Fork(s)
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	return fork
+}
+
+type forkRole string
+
+func TestForkIsolation(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("nodes", []string{"node1"})
+	s.PutWithMetadata("cluster", "lab", &store.Metadata{Tags: []string{"config"}})
+
+	fork := mustFork(t, s)
+	nodes, _ := store.Get[[]string](fork, "nodes")
+	nodes[0] = "changed"
+	fork.Put("nodes", append(nodes, "node2"))
+	fork.Put("added", true)
+
+	if got, _ := store.Get[[]string](s, "nodes"); len(got) != 1 || got[0] != "node1" {
+		t.Errorf("Writes to the fork reached the store: nodes = %v", got)
+	}
+	if _, err := store.Get[bool](s, "added"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Key added to the fork reached the store: %v", err)
+	}
+	if tagged, _ := fork.HasTag("cluster", "config"); !tagged {
+		t.Error("Fork lost the entry metadata")
+	}
+
+	// Values of unregistered basic types cannot be read back
+	s.Put("role", forkRole("controller"))
+	if _, err := Fork(s); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Fork() error = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestMergeFork(t *testing.T) {
+	// newForks returns a store, its base snapshot and two forks of the base
+	// writing the same "status" key
+	newForks := func(t *testing.T) (s, base, first, second *store.KVStore) {
+		s = store.NewKVStore()
+		s.Put("status", "pending")
+		s.Put("untouched", 1)
+		s.Put("obsolete", "remove me")
+
+		base = mustFork(t, s)
+		first, second = mustFork(t, base), mustFork(t, base)
+
+		first.Put("status", "first")
+		first.Put("first.result", 10)
+		first.Delete("obsolete")
+
+		second.Put("status", "second")
+		second.Put("second.result", 20)
+		return s, base, first, second
+	}
+
+	merge := func(t *testing.T, strategy store.MergeStrategy) (*store.KVStore, []string, error) {
+		t.Helper()
+		s, base, first, second := newForks(t)
+		if conflicts, err := MergeFork(s, base, first, strategy); err != nil || len(conflicts) != 0 {
+			t.Fatalf("MergeFork() of the first fork = %v, %v", conflicts, err)
+		}
+		conflicts, err := MergeFork(s, base, second, strategy)
+		return s, conflicts, err
+	}
+
+	assertMerged := func(t *testing.T, s *store.KVStore, status string) {
+		t.Helper()
+		if got, _ := store.Get[string](s, "status"); got != status {
+			t.Errorf("status = %q, want %q", got, status)
+		}
+		if got, _ := store.Get[int](s, "first.result"); got != 10 {
+			t.Errorf("first.result = %d, want 10", got)
+		}
+		if got, _ := store.Get[int](s, "second.result"); got != 20 {
+			t.Errorf("second.result = %d, want 20", got)
+		}
+		if _, err := store.Get[string](s, "obsolete"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Key deleted in a fork still present: %v", err)
+		}
+	}
+
+	t.Run("Overwrite", func(t *testing.T) {
+		s, conflicts, err := merge(t, store.Overwrite)
+		if err != nil || len(conflicts) != 1 || conflicts[0] != "status" {
+			t.Fatalf("MergeFork() = %v, %v, want status in conflict", conflicts, err)
+		}
+		assertMerged(t, s, "second")
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		s, conflicts, err := merge(t, store.Skip)
+		if err != nil || len(conflicts) != 1 {
+			t.Fatalf("MergeFork() = %v, %v", conflicts, err)
+		}
+		assertMerged(t, s, "first")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		s, conflicts, err := merge(t, store.Error)
+		if !errors.Is(err, ErrMergeConflict) || len(conflicts) != 1 {
+			t.Fatalf("MergeFork() = %v, %v, want ErrMergeConflict", conflicts, err)
+		}
+		// Nothing of the conflicting fork is merged
+		if _, err := store.Get[int](s, "second.result"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Conflicting fork partially merged: %v", err)
+		}
+	})
+
+	t.Run("Identical writes do not conflict", func(t *testing.T) {
+		s, base, first, second := newForks(t)
+		second.Put("status", "first")
+		MergeFork(s, base, first, store.Error)
+		if conflicts, err := MergeFork(s, base, second, store.Error); err != nil || len(conflicts) != 0 {
+			t.Errorf("MergeFork() = %v, %v", conflicts, err)
+		}
+	})
+
+	t.Run("Same store", func(t *testing.T) {
+		s := store.NewKVStore()
+		if _, err := MergeFork(s, s, mustFork(t, s), store.Overwrite); err == nil {
+			t.Error("MergeFork() expected an error when dst is base")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// ErrKeyFrozen is returned when writing a key frozen with FreezableStore.Freeze
+var ErrKeyFrozen = errors.New("key frozen")
+
+// FreezableStore freezes keys of a store so they reject writes, protecting
+// the configuration of a workflow, like its base image path, from being
+// overwritten once it has been loaded. Keys that are not frozen, like results,
+// remain writable. Writes and deletes of frozen keys through the wrapper or
+// the helpers of this package fail with ErrKeyFrozen; those made directly on
+// the wrapped store are undone by Verify, which the workflow engine runs
+// after each action. Clear keeps frozen keys. A frozen key does not expire:
+// Verify cannot tell an expired key from a deleted one and restores it.
+type FreezableStore struct {
+	guardedStore
+	remove func()
+
+	mu sync.RWMutex
+	// frozen holds a copy of the entry of each frozen key when it was
+	// frozen, nil for keys that were missing
+	frozen map[string]*entry
+}
+
+// NewFreezableStore installs a FreezableStore on s, without frozen keys
+func NewFreezableStore(s *store.KVStore) *FreezableStore {
+	f := &FreezableStore{guardedStore: guardedStore{s}, frozen: make(map[string]*entry)}
+	f.remove = install(s, f)
+	return f
+}
+
+// Close stops protecting the frozen keys of the wrapped store
+func (f *FreezableStore) Close() {
+	f.remove()
+}
+
+// Freeze makes keys immutable. Keys do not need to exist yet, a frozen
+// missing key cannot be created. It fails, freezing none of the keys, when
+// the value of one cannot be read, see RegisterType.
+func (f *FreezableStore) Freeze(keys ...string) error {
+	unlock := lockStores(f.KVStore)
+	defer unlock()
+
+	entries := make(map[string]*entry, len(keys))
+	for _, key := range keys {
+		e, err := lookup(f.KVStore, key)
+		if isMissing(err) {
+			entries[key] = nil
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("freezing '%s': %w", key, err)
+		}
+		copied := cloneEntry(e)
+		entries[key] = &copied
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, e := range entries {
+		// Freezing a frozen key keeps the entry it was frozen with
+		if _, ok := f.frozen[key]; !ok {
+			f.frozen[key] = e
+		}
+	}
+	return nil
+}
+
+// Unfreeze makes keys writable again
+func (f *FreezableStore) Unfreeze(keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		delete(f.frozen, key)
+	}
+}
+
+// IsFrozen reports whether key is frozen
+func (f *FreezableStore) IsFrozen(key string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	_, ok := f.frozen[key]
+	return ok
+}
+
+// check returns ErrKeyFrozen if key is frozen
+func (f *FreezableStore) check(key string) error {
+	if f.IsFrozen(key) {
+		return fmt.Errorf("%w: '%s' cannot be written", ErrKeyFrozen, key)
+	}
+	return nil
+}
+
+// beforeSet rejects writes to frozen keys
+func (f *FreezableStore) beforeSet(_ *store.KVStore, key string, _ interface{}) error {
+	return f.check(key)
+}
+
+// beforeDelete rejects deletes of frozen keys
+func (f *FreezableStore) beforeDelete(_ *store.KVStore, key string) error {
+	return f.check(key)
+}
+
+// changed has nothing to record, frozen keys cannot change
+func (f *FreezableStore) changed(*store.KVStore, string, interface{}, bool) {}
+
+// read has nothing to record
+func (f *FreezableStore) read(string) {}
+
+// verify restores the frozen keys written or deleted directly on the store
+// to the entry they were frozen with
+func (f *FreezableStore) verify(s *store.KVStore) error {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.frozen))
+	for key := range f.frozen {
+		keys = append(keys, key)
+	}
+	f.mu.RUnlock()
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		f.mu.RLock()
+		frozen, ok := f.frozen[key]
+		f.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		current, err := lookup(s, key)
+		switch {
+		case frozen == nil && isMissing(err):
+			continue
+		case frozen == nil:
+			s.Delete(key)
+		case err == nil && current.Type() == frozen.Type() && reflect.DeepEqual(current.Value, frozen.Value):
+			continue
+		default:
+			if err := putEntry(s, key, cloneEntry(*frozen)); err != nil {
+				errs = append(errs, fmt.Errorf("restoring frozen key '%s': %w", key, err))
+				continue
+			}
+		}
+		errs = append(errs, f.check(key))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package kvstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type imageConfig struct {
+	Path string
+	Size int
+}
+
+func TestFreezableStore(t *testing.T) {
+	s := NewFreezableStore(store.NewKVStore())
+	s.Put("baseImagePath", "/images/base.img")
+	s.Put("image", imageConfig{Path: "/images/base.img", Size: 8})
+	if err := s.Freeze("baseImagePath", "image", "nodeCount"); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+
+	writes := map[string]func() error{
+		"Put":                   func() error { return s.Put("baseImagePath", "/tmp/other.img") },
+		"PutWithTTL":            func() error { return s.PutWithTTL("baseImagePath", "/tmp/other.img", time.Hour) },
+		"PutWithMetadata":       func() error { return s.PutWithMetadata("baseImagePath", "/tmp/other.img", store.NewMetadata()) },
+		"PutWithTTLAndMetadata": func() error { return s.PutWithTTLAndMetadata("baseImagePath", "", time.Hour, nil) },
+		"UpdateField":           func() error { return s.UpdateField("image", "Size", 16) },
+		"UpdateFields":          func() error { return s.UpdateFields("image", map[string]interface{}{"Path": "/tmp"}) },
+		"Put of a missing key":  func() error { return s.Put("nodeCount", 4) },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrKeyFrozen) {
+			t.Errorf("%s error = %v, want ErrKeyFrozen", name, err)
+		}
+	}
+	if s.Delete("baseImagePath") {
+		t.Error("Delete() removed a frozen key")
+	}
+	if path, _ := store.Get[string](s.KVStore, "baseImagePath"); path != "/images/base.img" {
+		t.Errorf("baseImagePath = %q, want it unchanged", path)
+	}
+	if image, _ := store.Get[imageConfig](s.KVStore, "image"); image.Size != 8 || image.Path != "/images/base.img" {
+		t.Errorf("image = %+v, want it unchanged", image)
+	}
+
+	t.Run("Unrelated keys remain writable", func(t *testing.T) {
+		if err := s.Put("result", "flashed"); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		s.Put("scratch", true)
+		s.Clear()
+		if _, err := store.Get[bool](s.KVStore, "scratch"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Clear() kept an unfrozen key: %v", err)
+		}
+		if s.Count() != 2 {
+			t.Errorf("Clear() left %v, want only the frozen keys", s.ListKeys())
+		}
+	})
+
+	t.Run("Writes bypassing the wrapper", func(t *testing.T) {
+		raw := s.KVStore
+
+		// Direct writes are undone once verified
+		raw.Put("baseImagePath", "/tmp/other.img")
+		raw.Delete("image")
+		raw.Put("nodeCount", 4)
+		if err := Verify(raw); !errors.Is(err, ErrKeyFrozen) {
+			t.Errorf("Verify() error = %v, want ErrKeyFrozen", err)
+		}
+		if path, _ := store.Get[string](raw, "baseImagePath"); path != "/images/base.img" {
+			t.Errorf("baseImagePath = %q, want it restored", path)
+		}
+		if image, err := store.Get[imageConfig](raw, "image"); err != nil || image.Size != 8 {
+			t.Errorf("image = %+v, %v, want it restored", image, err)
+		}
+		if _, err := store.Get[int](raw, "nodeCount"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("nodeCount was created: %v", err)
+		}
+		if err := Verify(raw); err != nil {
+			t.Errorf("Verify() of the restored store error = %v", err)
+		}
+
+		// Helpers fail right away
+		if err := AppendTo(raw, "nodeCount", 4); !errors.Is(err, ErrKeyFrozen) {
+			t.Errorf("AppendTo() error = %v, want ErrKeyFrozen", err)
+		}
+	})
+
+	t.Run("Unfreeze restores writability", func(t *testing.T) {
+		s.Unfreeze("image")
+		if s.IsFrozen("image") || !s.IsFrozen("nodeCount") {
+			t.Fatal("Unfreeze() changed the wrong keys")
+		}
+		if err := s.UpdateField("image", "Size", 16); err != nil {
+			t.Fatalf("UpdateField() error = %v", err)
+		}
+		if image, _ := store.Get[imageConfig](s.KVStore, "image"); image.Size != 16 {
+			t.Errorf("image.Size = %d, want 16", image.Size)
+		}
+	})
+	s.Close()
+	if err := s.Put("nodeCount", 4); err != nil {
+		t.Errorf("Put() once closed error = %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package kvstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
+)
+
+// guard restricts the writes to a store, see FreezableStore, BoundedStore and
+// KeyScheme.Enforce. The helpers of this package check their writes with the
+// guards of the store before making them. gostage gives no way to intercept
+// the writes made with the store's own methods, Verify checks those after
+// the fact. Guards are called with the store locked, see lockStores.
+type guard interface {
+	// beforeSet returns an error if value may not be stored under key
+	beforeSet(s *store.KVStore, key string, value interface{}) error
+	// beforeDelete returns an error if key may not be deleted
+	beforeDelete(s *store.KVStore, key string) error
+	// changed records that a helper stored value under key, or deleted key
+	changed(s *store.KVStore, key string, value interface{}, deleted bool)
+	// read records that key was read with Get
+	read(key string)
+	// verify checks the writes made directly to the store since the last
+	// call, undoes those it rejects and returns why they were rejected
+	verify(s *store.KVStore) error
+}
+
+var (
+	guardsMu sync.RWMutex
+	// guards holds the guards of each store, in installation order. A store
+	// is dropped once its last guard is removed.
+	guards = make(map[*store.KVStore][]guard)
+)
+
+// install adds a guard to a store and returns the function removing it
+func install(s *store.KVStore, g guard) (remove func()) {
+	guardsMu.Lock()
+	defer guardsMu.Unlock()
+	// Copied on write, callers iterate the slice without the lock
+	guards[s] = append(append([]guard(nil), guards[s]...), g)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			guardsMu.Lock()
+			defer guardsMu.Unlock()
+			var kept []guard
+			for _, installed := range guards[s] {
+				if installed != g {
+					kept = append(kept, installed)
+				}
+			}
+			if len(kept) == 0 {
+				delete(guards, s)
+				return
+			}
+			guards[s] = kept
+		})
+	}
+}
+
+// guardsOf returns the guards of a store
+func guardsOf(s *store.KVStore) []guard {
+	guardsMu.RLock()
+	defer guardsMu.RUnlock()
+	return guards[s]
+}
+
+// Verify checks the writes made directly to s since it was last verified
+// against the guards installed on it: frozen keys, see FreezableStore, size
+// limits, see BoundedStore, and key schemes, see KeyScheme.Enforce. The
+// writes made through this package are checked as they happen, but gostage
+// gives no way to intercept those made with the store's own methods, so the
+// workflow engine verifies its store after each action. Rejected writes are
+// undone, and the errors they were rejected with returned joined.
+func Verify(s *store.KVStore) error {
+	installed := guardsOf(s)
+	if len(installed) == 0 {
+		return nil
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	var errs []error
+	for _, g := range installed {
+		if err := g.verify(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// touch tells the guards of a store that key was read
+func touch(s *store.KVStore, key string) {
+	for _, g := range guardsOf(s) {
+		g.read(key)
+	}
+}
+
+// checkSet returns the error of the first guard of the store rejecting value
+// under key
+func checkSet(s *store.KVStore, key string, value interface{}) error {
+	for _, g := range guardsOf(s) {
+		if err := g.beforeSet(s, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDelete returns the error of the first guard of the store refusing to
+// delete key
+func checkDelete(s *store.KVStore, key string) error {
+	for _, g := range guardsOf(s) {
+		if err := g.beforeDelete(s, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set stores value under key with write once the guards of the store accept
+// it. It must be called with the store locked.
+func set(s *store.KVStore, key string, value interface{}, write func() error) error {
+	if err := checkSet(s, key, value); err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	for _, g := range guardsOf(s) {
+		g.changed(s, key, value, false)
+	}
+	return nil
+}
+
+// setEntry stores an entry under key, without expiry, once the guards of the
+// store accept it. It must be called with the store locked.
+func setEntry(s *store.KVStore, key string, e entry) error {
+	return set(s, key, e.Value, func() error { return putEntry(s, key, e) })
+}
+
+// remove deletes key once the guards of the store accept it, and reports
+// whether it was deleted. It must be called with the store locked.
+func remove(s *store.KVStore, key string) (bool, error) {
+	if err := checkDelete(s, key); err != nil {
+		return false, err
+	}
+	if !s.Delete(key) {
+		return false, nil
+	}
+	for _, g := range guardsOf(s) {
+		g.changed(s, key, nil, true)
+	}
+	return true, nil
+}
+
+// updatedValue returns the value stored under key once update has been
+// applied to it. The update is applied by gostage to a copy of the value on a
+// scratch store, so it follows the rules of store.UpdateFields.
+func updatedValue(s *store.KVStore, key string, update func(scratch *store.KVStore) error) (interface{}, error) {
+	value, err := readValue(s, key)
+	if err != nil {
+		return nil, err
+	}
+	scratch := store.NewKVStore()
+	if err := scratch.Put(key, deepcopy.Copy(value)); err != nil {
+		return nil, err
+	}
+	if err := update(scratch); err != nil {
+		return nil, err
+	}
+	return readValue(scratch, key)
+}
+
+// guardedStore is embedded by the wrappers installing a guard on a store, so
+// that writes through a wrapper are checked by every guard of the store as
+// they happen rather than when the store is verified
+type guardedStore struct {
+	*store.KVStore
+}
+
+// Put stores value under key once the guards of the store accept it
+func (g guardedStore) Put(key string, value any) error {
+	return g.put(key, value, func() error { return g.KVStore.Put(key, value) })
+}
+
+// PutWithTTL stores value under key with a time-to-live once the guards of
+// the store accept it
+func (g guardedStore) PutWithTTL(key string, value any, ttl time.Duration) error {
+	return g.put(key, value, func() error { return g.KVStore.PutWithTTL(key, value, ttl) })
+}
+
+// PutWithMetadata stores value under key with metadata once the guards of
+// the store accept it
+func (g guardedStore) PutWithMetadata(key string, value any, metadata *store.Metadata) error {
+	return g.put(key, value, func() error { return g.KVStore.PutWithMetadata(key, value, metadata) })
+}
+
+// PutWithTTLAndMetadata stores value under key with a time-to-live and
+// metadata once the guards of the store accept it
+func (g guardedStore) PutWithTTLAndMetadata(key string, value any, ttl time.Duration, metadata *store.Metadata) error {
+	return g.put(key, value, func() error { return g.KVStore.PutWithTTLAndMetadata(key, value, ttl, metadata) })
+}
+
+// put stores value with write under the store's lock
+func (g guardedStore) put(key string, value any, write func() error) error {
+	unlock := lockStores(g.KVStore)
+	defer unlock()
+	return set(g.KVStore, key, value, write)
+}
+
+// UpdateField updates a field of the value stored under key once the guards
+// of the store accept the updated value
+func (g guardedStore) UpdateField(key string, fieldPath string, fieldValue interface{}) error {
+	return g.update(key, func(s *store.KVStore) error { return s.UpdateField(key, fieldPath, fieldValue) })
+}
+
+// UpdateFields updates fields of the value stored under key once the guards
+// of the store accept the updated value
+func (g guardedStore) UpdateFields(key string, fields map[string]interface{}) error {
+	return g.update(key, func(s *store.KVStore) error { return s.UpdateFields(key, fields) })
+}
+
+// update applies an update to the value stored under key under the store's lock
+func (g guardedStore) update(key string, update func(s *store.KVStore) error) error {
+	unlock := lockStores(g.KVStore)
+	defer unlock()
+
+	updated, err := updatedValue(g.KVStore, key, update)
+	if err != nil {
+		return err
+	}
+	return set(g.KVStore, key, updated, func() error { return update(g.KVStore) })
+}
+
+// Delete removes key once the guards of the store accept it, and reports
+// whether it was removed
+func (g guardedStore) Delete(key string) bool {
+	unlock := lockStores(g.KVStore)
+	defer unlock()
+	deleted, _ := remove(g.KVStore, key)
+	return deleted
+}
+
+// Clear removes every key that has not expired and that the guards of the
+// store let it delete
+func (g guardedStore) Clear() {
+	unlock := lockStores(g.KVStore)
+	defer unlock()
+	for _, key := range g.ListKeys() {
+		_, _ = remove(g.KVStore, key)
+	}
+}
@@ -0,0 +1,183 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// DefaultSeparator separates the segments of hierarchical keys such as "timing.<name>"
+const DefaultSeparator = "."
+
+// ErrInvalidKey is returned when a key does not follow the key scheme
+var ErrInvalidKey = errors.New("invalid key")
+
+// KeyScheme describes how hierarchical keys are built for a store.
+// A workflow configures one scheme and uses it for every key it writes.
+type KeyScheme struct {
+	// Separator between key segments, DefaultSeparator when empty
+	Separator string
+	// Validate makes Put, and a store the scheme is enforced on, reject keys
+	// that do not conform to the scheme
+	Validate bool
+}
+
+// DefaultKeyScheme uses DefaultSeparator without validation
+var DefaultKeyScheme = KeyScheme{Separator: DefaultSeparator}
+
+// JoinKey builds a key from its segments using DefaultKeyScheme
+func JoinKey(parts ...string) string {
+	return DefaultKeyScheme.JoinKey(parts...)
+}
+
+// SplitKey returns the segments of a key using DefaultKeyScheme
+func SplitKey(key string) []string {
+	return DefaultKeyScheme.SplitKey(key)
+}
+
+// separator returns the configured separator or the default one
+func (k KeyScheme) separator() string {
+	if k.Separator == "" {
+		return DefaultSeparator
+	}
+	return k.Separator
+}
+
+// JoinKey builds a key from its segments
+func (k KeyScheme) JoinKey(parts ...string) string {
+	return strings.Join(parts, k.separator())
+}
+
+// SplitKey returns the segments of a key. An empty key has no segments.
+func (k KeyScheme) SplitKey(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, k.separator())
+}
+
+// ValidateKey checks that a key is not empty and has no empty segments,
+// which rules out leading, trailing and doubled separators
+func (k KeyScheme) ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key cannot be empty", ErrInvalidKey)
+	}
+	for i, segment := range k.SplitKey(key) {
+		if strings.TrimSpace(segment) == "" {
+			return fmt.Errorf("%w: %q has an empty segment at position %d", ErrInvalidKey, key, i)
+		}
+	}
+	return nil
+}
+
+// Put stores value under key, validating the key first when the scheme
+// requires it. The write is checked by the guards of the store, see Verify.
+func (k KeyScheme) Put(s *store.KVStore, key string, value any) error {
+	if k.Validate {
+		if err := k.ValidateKey(key); err != nil {
+			return err
+		}
+	}
+	return guardedStore{s}.Put(key, value)
+}
+
+// PutWithTTL stores value under key with a time-to-live, validating the key
+// first when the scheme requires it. The write is checked by the guards of
+// the store, see Verify.
+func (k KeyScheme) PutWithTTL(s *store.KVStore, key string, value any, ttl time.Duration) error {
+	if k.Validate {
+		if err := k.ValidateKey(key); err != nil {
+			return err
+		}
+	}
+	return guardedStore{s}.PutWithTTL(key, value, ttl)
+}
+
+// Enforce makes s reject keys that do not conform to the scheme: writes
+// through the helpers of this package fail, and keys written directly on the
+// store are deleted by Verify, which the workflow engine runs after each
+// action. Keys stored before are left alone. It does nothing unless the
+// scheme validates keys. The returned function stops enforcing it.
+func (k KeyScheme) Enforce(s *store.KVStore) (remove func()) {
+	if !k.Validate {
+		return func() {}
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	g := &schemeGuard{scheme: k, checked: make(map[string]bool)}
+	for _, key := range s.ListKeys() {
+		g.checked[key] = true
+	}
+	return install(s, g)
+}
+
+// schemeGuard enforces a key scheme on a store
+type schemeGuard struct {
+	scheme KeyScheme
+
+	mu sync.Mutex
+	// checked holds the keys already validated, or stored before
+	checked map[string]bool
+}
+
+// beforeSet rejects keys that do not conform to the scheme
+func (g *schemeGuard) beforeSet(_ *store.KVStore, key string, _ interface{}) error {
+	return g.scheme.ValidateKey(key)
+}
+
+// beforeDelete lets every key be deleted
+func (g *schemeGuard) beforeDelete(*store.KVStore, string) error {
+	return nil
+}
+
+// changed records the keys written, which were validated
+func (g *schemeGuard) changed(_ *store.KVStore, key string, _ interface{}, deleted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if deleted {
+		delete(g.checked, key)
+	} else {
+		g.checked[key] = true
+	}
+}
+
+// read has nothing to record
+func (g *schemeGuard) read(string) {}
+
+// verify deletes the keys written directly on the store that do not
+// conform to the scheme
+func (g *schemeGuard) verify(s *store.KVStore) error {
+	keys := s.ListKeys()
+	sort.Strings(keys)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	live := make(map[string]bool, len(keys))
+	var errs []error
+	for _, key := range keys {
+		live[key] = true
+		if g.checked[key] {
+			continue
+		}
+		if err := g.scheme.ValidateKey(key); err != nil {
+			s.Delete(key)
+			errs = append(errs, err)
+			continue
+		}
+		g.checked[key] = true
+	}
+	for key := range g.checked {
+		if !live[key] {
+			delete(g.checked, key)
+		}
+	}
+	return errors.Join(errs...)
+}
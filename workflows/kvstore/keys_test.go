@@ -0,0 +1,149 @@
+package kvstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestJoinAndSplitKey(t *testing.T) {
+	if got := JoinKey("timing", "flash"); got != "timing.flash" {
+		t.Errorf("JoinKey() = %q, want timing.flash", got)
+	}
+	if got := SplitKey("node.1.ip"); !reflect.DeepEqual(got, []string{"node", "1", "ip"}) {
+		t.Errorf("SplitKey() = %q", got)
+	}
+	if got := SplitKey(""); got != nil {
+		t.Errorf("SplitKey(\"\") = %q, want nil", got)
+	}
+
+	scheme := KeyScheme{Separator: "/"}
+	key := scheme.JoinKey("workflow", "node", "2")
+	if key != "workflow/node/2" {
+		t.Errorf("JoinKey() = %q, want workflow/node/2", key)
+	}
+	if got := scheme.SplitKey(key); !reflect.DeepEqual(got, []string{"workflow", "node", "2"}) {
+		t.Errorf("SplitKey() = %q", got)
+	}
+}
+
+func TestKeySchemeValidation(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"timing.flash", true},
+		{"status", true},
+		{"", false},
+		{".timing", false},
+		{"timing.", false},
+		{"timing..flash", false},
+		{"timing. .flash", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := DefaultKeyScheme.ValidateKey(tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateKey(%q) error = %v", tt.key, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("ValidateKey(%q) error = %v, want ErrInvalidKey", tt.key, err)
+			}
+		})
+	}
+
+	t.Run("Put rejects malformed keys when validating", func(t *testing.T) {
+		s := store.NewKVStore()
+		scheme := KeyScheme{Separator: ":", Validate: true}
+
+		if err := scheme.Put(s, "timing::flash", 1); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put() error = %v, want ErrInvalidKey", err)
+		}
+		if err := scheme.PutWithTTL(s, ":timing", 1, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("PutWithTTL() error = %v, want ErrInvalidKey", err)
+		}
+		if s.Count() != 0 {
+			t.Errorf("Store has %d keys, want rejected keys not stored", s.Count())
+		}
+
+		if err := scheme.Put(s, scheme.JoinKey("timing", "flash"), 1); err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+	})
+
+	t.Run("Put accepts any key without validation", func(t *testing.T) {
+		s := store.NewKVStore()
+		if err := DefaultKeyScheme.Put(s, "timing..flash", 1); err != nil {
+			t.Errorf("Put() error = %v", err)
+		}
+	})
+}
+
+func TestKeySchemeEnforce(t *testing.T) {
+	s := store.NewKVStore()
+	scheme := KeyScheme{Validate: true}
+	remove := scheme.Enforce(s)
+
+	// Every way of writing a key is checked, the writes made directly on
+	// the store once it is verified
+	writes := map[string]struct {
+		write  func(key string) error
+		direct bool
+	}{
+		"Put":        {func(key string) error { return s.Put(key, 1) }, true},
+		"PutWithTTL": {func(key string) error { return s.PutWithTTL(key, 1, time.Minute) }, true},
+		"PutWithMetadata": {func(key string) error {
+			return s.PutWithMetadata(key, 1, store.NewMetadata())
+		}, true},
+		"PutWithTTLAndMetadata": {func(key string) error {
+			return s.PutWithTTLAndMetadata(key, 1, time.Minute, store.NewMetadata())
+		}, true},
+		"AppendTo": {func(key string) error { return AppendTo(s, key, 1) }, false},
+		"CopyKey": {func(key string) error {
+			if err := s.Put("source", 1); err != nil {
+				return err
+			}
+			return CopyKey(s, "source", key, true)
+		}, false},
+		"KeyScheme.Put": {func(key string) error { return scheme.Put(s, key, 1) }, false},
+	}
+	for name, tt := range writes {
+		err := tt.write("timing..flash")
+		if tt.direct {
+			if err != nil {
+				t.Errorf("%s() error = %v", name, err)
+			}
+			err = Verify(s)
+		}
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s() error = %v, want ErrInvalidKey", name, err)
+		}
+		if _, err := Get[int](s, "timing..flash"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s() kept the invalid key, Get() error = %v", name, err)
+		}
+
+		if err := tt.write(scheme.JoinKey("timing", name)); err != nil {
+			t.Errorf("%s() error = %v", name, err)
+		}
+		if err := Verify(s); err != nil {
+			t.Errorf("Verify() after %s() error = %v", name, err)
+		}
+	}
+
+	remove()
+	s.Put("timing..flash", 1)
+	if err := Verify(s); err != nil {
+		t.Errorf("Verify() error = %v once the scheme is no longer enforced", err)
+	}
+
+	// A scheme without validation accepts everything
+	DefaultKeyScheme.Enforce(s)
+	s.Put(".timing", 1)
+	if err := Verify(s); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package kvstore provides typed helpers on top of the gostage workflow store.
+//
+// gostage's store.Get returns the stored value itself, so maps, slices and
+// pointers it returns alias the stored data. The accessors of this package
+// return deep copies instead, with two exceptions: unexported struct fields
+// are copied shallowly, so the data they reference is still shared with the
+// store, and handles, pointers to values holding a lock such as a state
+// manager, are shared rather than copied.
+//
+// The store does not expose the expiry of its entries: the helpers that write
+// back an entry they read, such as Rename, Fork or AppendTo, store it without
+// expiry. Helpers that handle values of any type read them without knowing
+// their type, which the store only allows for composite kinds, see
+// RegisterType.
+package kvstore
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
+)
+
+// isMissing reports whether err means the key is absent from the store
+func isMissing(err error) bool {
+	return errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrExpired)
+}
+
+// Get retrieves a deep copy of the value of type T stored under key
+func Get[T any](s *store.KVStore, key string) (T, error) {
+	value, err := store.Get[T](s, key)
+	if err != nil {
+		return value, err
+	}
+	touch(s, key)
+	return deepcopy.Of(value), nil
+}
+
+// GetOrDefault retrieves a deep copy of the value of type T stored under key,
+// or defaultValue if the key is missing or expired
+func GetOrDefault[T any](s *store.KVStore, key string, defaultValue T) (T, error) {
+	value, err := Get[T](s, key)
+	if isMissing(err) {
+		return defaultValue, nil
+	}
+	return value, err
+}
+
+// GetSliceOrEmpty retrieves a []T for the given key.
+// A missing or expired key yields an initialized empty slice instead of nil,
+// while type mismatches are still returned as errors.
+func GetSliceOrEmpty[T any](s *store.KVStore, key string) ([]T, error) {
+	value, err := Get[[]T](s, key)
+	if isMissing(err) {
+		return []T{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		return []T{}, nil
+	}
+	return value, nil
+}
+
+// GetMapOrEmpty retrieves a map[K]V for the given key.
+// A missing or expired key yields an initialized empty map instead of nil,
+// while type mismatches are still returned as errors.
+func GetMapOrEmpty[K comparable, V any](s *store.KVStore, key string) (map[K]V, error) {
+	value, err := Get[map[K]V](s, key)
+	if isMissing(err) {
+		return map[K]V{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		return map[K]V{}, nil
+	}
+	return value, nil
+}
+
+// TypeOf returns the type of the value stored under key, or store.ErrNotFound
+// or store.ErrExpired when the key is missing. It returns ErrUnknownType when
+// the value cannot be read, see RegisterType.
+func TypeOf(s *store.KVStore, key string) (reflect.Type, error) {
+	value, err := readValue(s, key)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.TypeOf(value), nil
+}
@@ -0,0 +1,106 @@
+package kvstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestGetSliceOrEmpty(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("present", []int{1, 2, 3})
+	s.Put("nil-slice", []int(nil))
+	s.Put("wrong-type", "not a slice")
+
+	t.Run("present", func(t *testing.T) {
+		got, err := GetSliceOrEmpty[int](s, "present")
+		if err != nil {
+			t.Fatalf("GetSliceOrEmpty() error = %v", err)
+		}
+		if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+			t.Errorf("GetSliceOrEmpty() = %v, want [1 2 3]", got)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		got, err := GetSliceOrEmpty[int](s, "missing")
+		if err != nil {
+			t.Fatalf("GetSliceOrEmpty() error = %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Errorf("GetSliceOrEmpty() = %#v, want initialized empty slice", got)
+		}
+	})
+
+	t.Run("stored nil", func(t *testing.T) {
+		got, err := GetSliceOrEmpty[int](s, "nil-slice")
+		if err != nil {
+			t.Fatalf("GetSliceOrEmpty() error = %v", err)
+		}
+		if got == nil {
+			t.Errorf("GetSliceOrEmpty() returned nil slice")
+		}
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		_, err := GetSliceOrEmpty[int](s, "wrong-type")
+		if !errors.Is(err, store.ErrTypeMismatch) {
+			t.Errorf("GetSliceOrEmpty() error = %v, want ErrTypeMismatch", err)
+		}
+	})
+}
+
+func TestGetMapOrEmpty(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("present", map[string]int{"a": 1})
+	s.Put("wrong-type", []string{"a"})
+
+	t.Run("present", func(t *testing.T) {
+		got, err := GetMapOrEmpty[string, int](s, "present")
+		if err != nil {
+			t.Fatalf("GetMapOrEmpty() error = %v", err)
+		}
+		if got["a"] != 1 {
+			t.Errorf("GetMapOrEmpty() = %v, want map[a:1]", got)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		got, err := GetMapOrEmpty[string, int](s, "missing")
+		if err != nil {
+			t.Fatalf("GetMapOrEmpty() error = %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Errorf("GetMapOrEmpty() = %#v, want initialized empty map", got)
+		}
+		// The returned map must be writable
+		got["b"] = 2
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		_, err := GetMapOrEmpty[string, int](s, "wrong-type")
+		if !errors.Is(err, store.ErrTypeMismatch) {
+			t.Errorf("GetMapOrEmpty() error = %v, want ErrTypeMismatch", err)
+		}
+	})
+}
+
+func TestTypeOf(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("resources", []string{"eth0"})
+	s.PutWithTTL("stale", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if typ, err := TypeOf(s, "resources"); err != nil || typ != reflect.TypeOf([]string{}) {
+		t.Errorf("TypeOf() = %v, %v, want []string", typ, err)
+	}
+	if _, err := TypeOf(s, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("TypeOf() of a missing key error = %v, want ErrNotFound", err)
+	}
+	if _, err := TypeOf(s, "stale"); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("TypeOf() of an expired key error = %v, want ErrExpired", err)
+	}
+}
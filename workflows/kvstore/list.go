@@ -0,0 +1,70 @@
+package kvstore
+
+import (
+	"errors"
+
+	"github.com/davidroman0O/gostage/store"
+	"github.com/davidroman0O/turingpi/internal/deepcopy"
+)
+
+// AppendTo appends deep copies of items to the []T stored under key, creating
+// the key when it is missing or expired. Concurrent appends through this
+// package are never lost, see lockStores. The metadata of an existing key is
+// kept, not its expiry. It returns store.ErrTypeMismatch when the key holds
+// anything but a []T.
+func AppendTo[T any](s *store.KVStore, key string, items ...T) error {
+	if key == "" {
+		return errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	list, err := store.Get[[]T](s, key)
+	if err != nil && !isMissing(err) {
+		return err
+	}
+
+	// A new backing array, callers may hold the previous one from store.Get
+	updated := make([]T, 0, len(list)+len(items))
+	updated = append(updated, list...)
+	updated = append(updated, deepcopy.Of(items)...)
+	return set(s, key, updated, func() error { return s.Put(key, updated) })
+}
+
+// RemoveFrom removes the elements of the []T stored under key for which pred
+// returns true and returns how many were removed. Concurrent updates through
+// this package are never lost, see lockStores. The metadata of the key is
+// kept, not its expiry. A missing or expired key removes nothing. It returns
+// store.ErrTypeMismatch when the key holds anything but a []T.
+func RemoveFrom[T any](s *store.KVStore, key string, pred func(T) bool) (int, error) {
+	if key == "" {
+		return 0, errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	list, err := store.Get[[]T](s, key)
+	if isMissing(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	kept := make([]T, 0, len(list))
+	for _, item := range list {
+		if !pred(item) {
+			kept = append(kept, item)
+		}
+	}
+	removed := len(list) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := set(s, key, kept, func() error { return s.Put(key, kept) }); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,117 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestAppendTo(t *testing.T) {
+	s := store.NewKVStore()
+
+	if err := AppendTo(s, "nodes", "node1"); err != nil {
+		t.Fatalf("AppendTo() on a missing key error = %v", err)
+	}
+	if err := AppendTo(s, "nodes", "node2", "node3"); err != nil {
+		t.Fatalf("AppendTo() error = %v", err)
+	}
+	got, err := store.Get[[]string](s, "nodes")
+	if err != nil || fmt.Sprint(got) != "[node1 node2 node3]" {
+		t.Errorf("Get() = %v, %v", got, err)
+	}
+
+	// Appended items are copied
+	results := []renameResult{{Node: 1, Items: []string{"flash"}}}
+	if err := AppendTo(s, "results", results...); err != nil {
+		t.Fatalf("AppendTo() error = %v", err)
+	}
+	results[0].Items[0] = "changed"
+	if stored, _ := store.Get[[]renameResult](s, "results"); stored[0].Items[0] != "flash" {
+		t.Errorf("Stored item aliases the appended one: %+v", stored)
+	}
+
+	// Metadata of the key is kept
+	s.PutWithTTLAndMetadata("log", []string{"boot"}, time.Hour, store.NewMetadata())
+	s.AddTag("log", "audit")
+	if err := AppendTo(s, "log", "flash"); err != nil {
+		t.Fatalf("AppendTo() error = %v", err)
+	}
+	if tagged, _ := s.HasTag("log", "audit"); !tagged {
+		t.Error("AppendTo() dropped the key metadata")
+	}
+
+	s.Put("count", 3)
+	if err := AppendTo(s, "count", 4); !errors.Is(err, store.ErrTypeMismatch) {
+		t.Errorf("AppendTo() to a non-slice error = %v, want ErrTypeMismatch", err)
+	}
+	if err := AppendTo(s, "nodes", 4); !errors.Is(err, store.ErrTypeMismatch) {
+		t.Errorf("AppendTo() with the wrong element type error = %v, want ErrTypeMismatch", err)
+	}
+	if got, _ := store.Get[[]string](s, "nodes"); len(got) != 3 {
+		t.Errorf("Failed AppendTo() changed the key: %v", got)
+	}
+}
+
+func TestRemoveFrom(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("ports", []int{22, 80, 443, 8080})
+
+	removed, err := RemoveFrom(s, "ports", func(port int) bool { return port >= 1024 || port == 80 })
+	if err != nil || removed != 2 {
+		t.Fatalf("RemoveFrom() = %d, %v, want 2 removed", removed, err)
+	}
+	if got, _ := store.Get[[]int](s, "ports"); fmt.Sprint(got) != "[22 443]" {
+		t.Errorf("Remaining ports = %v", got)
+	}
+
+	if removed, err := RemoveFrom(s, "missing", func(int) bool { return true }); err != nil || removed != 0 {
+		t.Errorf("RemoveFrom() on a missing key = %d, %v", removed, err)
+	}
+	if s.Count() != 1 {
+		t.Error("RemoveFrom() created a missing key")
+	}
+
+	if _, err := RemoveFrom(s, "ports", func(string) bool { return true }); !errors.Is(err, store.ErrTypeMismatch) {
+		t.Errorf("RemoveFrom() with the wrong element type error = %v, want ErrTypeMismatch", err)
+	}
+}
+
+func TestAppendToConcurrent(t *testing.T) {
+	const workers = 16
+	const perWorker = 100
+
+	s := store.NewKVStore()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if err := AppendTo(s, "results", w*perWorker+i); err != nil {
+					t.Errorf("AppendTo() error = %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	got, err := store.Get[[]int](s, "results")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(got) != workers*perWorker {
+		t.Fatalf("Stored %d elements, want %d", len(got), workers*perWorker)
+	}
+	sort.Ints(got)
+	for i, value := range got {
+		if value != i {
+			t.Fatalf("Element %d = %d, an append was lost or duplicated", i, value)
+		}
+	}
+}
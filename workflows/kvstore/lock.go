@@ -0,0 +1,50 @@
+package kvstore
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// storeLocks serializes the helpers of this package that read a store and
+// write it back, such as Rename or Pop. gostage's store locks itself for the
+// duration of each method only, and does not expose its lock, so these
+// helpers are atomic with respect to one another, not to the store's own
+// methods called directly. Stores are spread over a fixed set of locks so
+// that no store is kept alive once it is no longer used.
+var storeLocks [64]sync.Mutex
+
+// lockStores locks the stores for a helper and returns the function that
+// unlocks them. Stores sharing a lock take it once, and locks are always
+// taken in the same order so helpers locking several stores cannot deadlock.
+func lockStores(stores ...*store.KVStore) (unlock func()) {
+	var indexes []int
+	for _, s := range stores {
+		i := int(reflect.ValueOf(s).Pointer()>>4) % len(storeLocks)
+		if !containsIndex(indexes, i) {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		storeLocks[i].Lock()
+	}
+	return func() {
+		for j := len(indexes) - 1; j >= 0; j-- {
+			storeLocks[indexes[j]].Unlock()
+		}
+	}
+}
+
+// containsIndex reports whether indexes holds i
+func containsIndex(indexes []int, i int) bool {
+	for _, index := range indexes {
+		if index == i {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MigrationFunc converts the JSON encoding of a value from one schema
+// version to the next
+type MigrationFunc func(json.RawMessage) (json.RawMessage, error)
+
+var (
+	migrationsMu sync.RWMutex
+	// migrations maps type names to their migrations, indexed by the version
+	// they migrate from
+	migrations = make(map[string]map[int]MigrationFunc)
+)
+
+// RegisterMigration registers how values of the named type are brought from
+// fromVersion to fromVersion+1 when a snapshot is restored. typeName is the Go
+// type name as recorded in snapshots, such as "config.NodeConfig".
+// Types start at version 1. Registering a migration from version n makes n+1
+// the current version of the type, recorded in the snapshots taken from then on.
+func RegisterMigration(typeName string, fromVersion int, migrate MigrationFunc) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if migrations[typeName] == nil {
+		migrations[typeName] = make(map[int]MigrationFunc)
+	}
+	migrations[typeName][fromVersion] = migrate
+}
+
+// CurrentVersion returns the schema version values of the named type are encoded with
+func CurrentVersion(typeName string) int {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	version := 1
+	for from := range migrations[typeName] {
+		if from+1 > version {
+			version = from + 1
+		}
+	}
+	return version
+}
+
+// migrate brings an encoded value from version up to the current version of its type.
+// Entries without a version predate versioning and are treated as version 1.
+func migrate(typeName string, version int, data json.RawMessage) (json.RawMessage, error) {
+	if version == 0 {
+		version = 1
+	}
+
+	current := CurrentVersion(typeName)
+	if version > current {
+		return nil, fmt.Errorf("%s version %d is newer than the supported version %d", typeName, version, current)
+	}
+
+	for ; version < current; version++ {
+		migrationsMu.RLock()
+		step := migrations[typeName][version]
+		migrationsMu.RUnlock()
+
+		if step == nil {
+			return nil, fmt.Errorf("no migration registered for %s from version %d", typeName, version)
+		}
+
+		var err error
+		if data, err = step(data); err != nil {
+			return nil, fmt.Errorf("migrating %s from version %d: %w", typeName, version, err)
+		}
+	}
+	return data, nil
+}
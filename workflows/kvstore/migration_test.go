@@ -0,0 +1,112 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// migratedNode is version 2 of a node configuration, version 1 called the
+// Hostname field Name
+type migratedNode struct {
+	Hostname string
+	Slot     int
+}
+
+func TestSnapshotMigration(t *testing.T) {
+	typeName := "kvstore.migratedNode"
+	RegisterType[migratedNode]()
+
+	// Snapshot taken by a build where the type was at version 1
+	v1, err := json.Marshal(struct {
+		Name string
+		Slot int
+	}{Name: "node1", Slot: 1})
+	if err != nil {
+		t.Fatalf("Failed to encode v1 value: %v", err)
+	}
+	snapshot := &Snapshot{Entries: map[string]SnapshotEntry{
+		"node": {Type: typeName, Version: 1, Value: v1},
+	}}
+
+	if err := Restore(store.NewKVStore(), snapshot); err != nil {
+		t.Fatalf("Restore() without migrations should decode as is, got %v", err)
+	}
+
+	RegisterMigration(typeName, 1, func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["Hostname"] = fields["Name"]
+		delete(fields, "Name")
+		return json.Marshal(fields)
+	})
+	if version := CurrentVersion(typeName); version != 2 {
+		t.Fatalf("CurrentVersion() = %d, want 2", version)
+	}
+
+	s := store.NewKVStore()
+	if err := Restore(s, snapshot); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	node, err := store.Get[migratedNode](s, "node")
+	if err != nil {
+		t.Fatalf("Failed to get migrated node: %v", err)
+	}
+	if node.Hostname != "node1" || node.Slot != 1 {
+		t.Errorf("Migrated node = %+v, want node1 in slot 1", node)
+	}
+
+	// New snapshots record the current version and are not migrated again
+	current, err := TakeSnapshot(s)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	if entry := current.Entries["node"]; entry.Version != 2 {
+		t.Errorf("Snapshot entry version = %d, want 2", entry.Version)
+	}
+	restored := store.NewKVStore()
+	if err := Restore(restored, current); err != nil {
+		t.Fatalf("Restore() of a current snapshot error = %v", err)
+	}
+	if node, _ := store.Get[migratedNode](restored, "node"); node.Hostname != "node1" {
+		t.Errorf("Restored node = %+v", node)
+	}
+
+	t.Run("Newer versions are rejected", func(t *testing.T) {
+		newer := &Snapshot{Entries: map[string]SnapshotEntry{
+			"node": {Type: typeName, Version: 3, Value: v1},
+		}}
+		if err := Restore(store.NewKVStore(), newer); err == nil {
+			t.Error("Restore() should fail on a version newer than the current one")
+		}
+	})
+
+	t.Run("Failing migrations", func(t *testing.T) {
+		errBroken := errors.New("broken migration")
+		RegisterMigration("kvstore.brokenNode", 1, func(json.RawMessage) (json.RawMessage, error) {
+			return nil, errBroken
+		})
+		broken := &Snapshot{Entries: map[string]SnapshotEntry{
+			"node": {Type: "kvstore.brokenNode", Version: 1, Value: v1},
+		}}
+		if err := Restore(store.NewKVStore(), broken); !errors.Is(err, errBroken) {
+			t.Errorf("Restore() error = %v, want the migration error", err)
+		}
+	})
+
+	t.Run("Missing steps", func(t *testing.T) {
+		RegisterMigration("kvstore.gappedNode", 2, func(data json.RawMessage) (json.RawMessage, error) {
+			return data, nil
+		})
+		gapped := &Snapshot{Entries: map[string]SnapshotEntry{
+			"node": {Type: "kvstore.gappedNode", Version: 1, Value: v1},
+		}}
+		if err := Restore(store.NewKVStore(), gapped); err == nil {
+			t.Error("Restore() should fail without a migration from version 1")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// Pop retrieves the value of type T stored under key and deletes the key in a
+// single operation, so a value is never popped by two callers of this
+// package, see lockStores. It returns store.ErrNotFound or store.ErrExpired
+// when the key is missing, and store.ErrTypeMismatch, leaving the key in
+// place, when the value is not a T. Keys a guard refuses to delete, see
+// FreezableStore, are left in place too.
+func Pop[T any](s *store.KVStore, key string) (T, error) {
+	var zero T
+	if key == "" {
+		return zero, errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	// The same rule as store.Get: interfaces match the types implementing
+	// them, other types must match exactly
+	value, err := store.Get[T](s, key)
+	if err != nil {
+		return zero, err
+	}
+
+	deleted, err := remove(s, key)
+	if err != nil {
+		return zero, err
+	}
+	if !deleted {
+		return zero, fmt.Errorf("key '%s': %w", key, store.ErrNotFound)
+	}
+	// The value no longer belongs to the store, it needs no copy
+	return value, nil
+}
@@ -0,0 +1,93 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+func TestPop(t *testing.T) {
+	s := store.NewKVStore()
+	s.Put("job", renameResult{Node: 1, Items: []string{"flash"}})
+	s.Put("count", 3)
+	s.PutWithTTL("stale", "gone", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	got, err := Pop[renameResult](s, "job")
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if got.Node != 1 || len(got.Items) != 1 || got.Items[0] != "flash" {
+		t.Errorf("Pop() = %+v", got)
+	}
+	if _, err := Pop[renameResult](s, "job"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Second Pop() error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := Pop[string](s, "stale"); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("Pop() of an expired key error = %v, want ErrExpired", err)
+	}
+
+	if _, err := Pop[string](s, "count"); !errors.Is(err, store.ErrTypeMismatch) {
+		t.Errorf("Pop() with the wrong type error = %v, want ErrTypeMismatch", err)
+	}
+	if value, err := store.Get[int](s, "count"); err != nil || value != 3 {
+		t.Errorf("Type mismatch deleted the key: Get() = %v, %v", value, err)
+	}
+
+	s.Put("stringer", time.Unix(0, 0).UTC())
+	if value, err := Pop[fmt.Stringer](s, "stringer"); err != nil || value.String() != "1970-01-01 00:00:00 +0000 UTC" {
+		t.Errorf("Pop() of an interface = %v, %v", value, err)
+	}
+}
+
+func TestPopConcurrent(t *testing.T) {
+	const keys = 100
+	const workers = 16
+
+	s := store.NewKVStore()
+	for i := 0; i < keys; i++ {
+		s.Put(fmt.Sprintf("queue.%d", i), i)
+	}
+
+	var mu sync.Mutex
+	popped := make(map[int]int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				value, err := Pop[int](s, fmt.Sprintf("queue.%d", i))
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					t.Errorf("Pop() error = %v", err)
+					return
+				}
+				mu.Lock()
+				popped[value]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(popped) != keys {
+		t.Errorf("Popped %d keys, want %d", len(popped), keys)
+	}
+	for value, count := range popped {
+		if count != 1 {
+			t.Errorf("Value %d popped %d times", value, count)
+		}
+	}
+	if s.Count() != 0 {
+		t.Errorf("Store still holds %d keys", s.Count())
+	}
+}
@@ -0,0 +1,129 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// ErrKeyExists is returned when a destination key is already present and
+// overwriting was not requested
+var ErrKeyExists = errors.New("key already exists")
+
+// Rename moves the entry stored under oldKey to newKey, keeping its value,
+// type and metadata. It fails with ErrKeyExists if newKey is present and
+// overwrite is false, and without modifying either key if a guard of the
+// store rejects the write of newKey or the delete of oldKey, see
+// FreezableStore. The move is atomic with respect to the other helpers of
+// this package, see lockStores.
+func Rename(s *store.KVStore, oldKey, newKey string, overwrite bool) error {
+	return transfer(s, oldKey, newKey, overwrite, false)
+}
+
+// CopyKey copies the entry stored under srcKey to dstKey, keeping its type
+// and metadata. The value and metadata are deep-copied so both keys can be
+// modified independently. It fails with ErrKeyExists if dstKey is present and
+// overwrite is false. The copy is atomic with respect to the other helpers of
+// this package, see lockStores.
+func CopyKey(s *store.KVStore, srcKey, dstKey string, overwrite bool) error {
+	return transfer(s, srcKey, dstKey, overwrite, true)
+}
+
+// transfer implements Rename and CopyKey
+func transfer(s *store.KVStore, srcKey, dstKey string, overwrite, keepSource bool) error {
+	if srcKey == "" || dstKey == "" {
+		return errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	src, err := lookup(s, srcKey)
+	if err != nil {
+		return fmt.Errorf("source key '%s': %w", srcKey, err)
+	}
+
+	if srcKey == dstKey {
+		return nil
+	}
+
+	previous, previousErr := lookup(s, dstKey)
+	if !overwrite && !isMissing(previousErr) {
+		return fmt.Errorf("destination key '%s': %w", dstKey, ErrKeyExists)
+	}
+
+	if keepSource {
+		return setEntry(s, dstKey, cloneEntry(src))
+	}
+
+	// Both changes are checked before either is made, and the destination is
+	// put back should the source still not be deleted
+	if err := checkSet(s, dstKey, src.Value); err != nil {
+		return err
+	}
+	if err := checkDelete(s, srcKey); err != nil {
+		return err
+	}
+	if err := setEntry(s, dstKey, src); err != nil {
+		return err
+	}
+	if _, err := remove(s, srcKey); err != nil {
+		var restoreErr error
+		switch {
+		case previousErr == nil:
+			restoreErr = setEntry(s, dstKey, previous)
+		case isMissing(previousErr):
+			_, restoreErr = remove(s, dstKey)
+		default:
+			restoreErr = previousErr
+		}
+		if restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("restoring key '%s': %w", dstKey, restoreErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// Swap exchanges the entries stored under keyA and keyB, each key taking the
+// value, type and metadata of the other. It fails without modifying either
+// key if one of them is missing or expired, or if a guard of the store
+// rejects either write, see FreezableStore. The swap is atomic with respect
+// to the other helpers of this package, see lockStores.
+func Swap(s *store.KVStore, keyA, keyB string) error {
+	if keyA == "" || keyB == "" {
+		return errors.New("key cannot be empty")
+	}
+
+	unlock := lockStores(s)
+	defer unlock()
+
+	a, err := lookup(s, keyA)
+	if err != nil {
+		return fmt.Errorf("key '%s': %w", keyA, err)
+	}
+	b, err := lookup(s, keyB)
+	if err != nil {
+		return fmt.Errorf("key '%s': %w", keyB, err)
+	}
+
+	// Both writes are checked before either is made, and the first one is
+	// undone should the second fail all the same
+	if err := checkSet(s, keyA, b.Value); err != nil {
+		return err
+	}
+	if err := checkSet(s, keyB, a.Value); err != nil {
+		return err
+	}
+	if err := setEntry(s, keyA, b); err != nil {
+		return err
+	}
+	if err := setEntry(s, keyB, a); err != nil {
+		if restoreErr := setEntry(s, keyA, a); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("restoring key '%s': %w", keyA, restoreErr))
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package kvstore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type renameResult struct {
+	Node  int
+	Items []string
+}
+
+func TestRename(t *testing.T) {
+	newStore := func() *store.KVStore {
+		s := store.NewKVStore()
+		meta := store.NewMetadata()
+		meta.AddTag("result")
+		meta.SetProperty("node", 1)
+		meta.Description = "temporary result"
+		s.PutWithTTLAndMetadata("tmp.result", renameResult{Node: 1, Items: []string{"a"}}, time.Hour, meta)
+		s.Put("final.result", renameResult{Node: 2})
+		return s
+	}
+
+	t.Run("preserves type and metadata", func(t *testing.T) {
+		s := newStore()
+		s.Delete("final.result")
+
+		if err := Rename(s, "tmp.result", "final.result", false); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+
+		if _, err := store.Get[renameResult](s, "tmp.result"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Old key still present, Get() error = %v", err)
+		}
+
+		got, err := store.Get[renameResult](s, "final.result")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Node != 1 || len(got.Items) != 1 {
+			t.Errorf("Renamed value = %+v", got)
+		}
+
+		meta, err := s.GetMetadata("final.result")
+		if err != nil {
+			t.Fatalf("GetMetadata() error = %v", err)
+		}
+		if !meta.HasTag("result") || meta.Description != "temporary result" || meta.Properties["node"] != 1 {
+			t.Errorf("Metadata not preserved: %+v", meta)
+		}
+	})
+
+	t.Run("overwrite guard", func(t *testing.T) {
+		s := newStore()
+
+		err := Rename(s, "tmp.result", "final.result", false)
+		if !errors.Is(err, ErrKeyExists) {
+			t.Fatalf("Rename() error = %v, want ErrKeyExists", err)
+		}
+
+		// Nothing moved
+		if got, _ := store.Get[renameResult](s, "final.result"); got.Node != 2 {
+			t.Errorf("Destination was modified: %+v", got)
+		}
+		if _, err := store.Get[renameResult](s, "tmp.result"); err != nil {
+			t.Errorf("Source was removed: %v", err)
+		}
+
+		if err := Rename(s, "tmp.result", "final.result", true); err != nil {
+			t.Fatalf("Rename() with overwrite error = %v", err)
+		}
+		if got, _ := store.Get[renameResult](s, "final.result"); got.Node != 1 {
+			t.Errorf("Destination was not overwritten: %+v", got)
+		}
+	})
+
+	t.Run("expired destination is not a conflict", func(t *testing.T) {
+		s := newStore()
+		s.PutWithTTL("final.result", renameResult{Node: 3}, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+
+		if err := Rename(s, "tmp.result", "final.result", false); err != nil {
+			t.Errorf("Rename() error = %v", err)
+		}
+	})
+
+	t.Run("frozen keys abort", func(t *testing.T) {
+		for _, frozenKey := range []string{"tmp.result", "final.result"} {
+			s := newStore()
+			frozen := NewFreezableStore(s)
+			if err := frozen.Freeze(frozenKey); err != nil {
+				t.Fatalf("Freeze() error = %v", err)
+			}
+
+			if err := Rename(s, "tmp.result", "final.result", true); !errors.Is(err, ErrKeyFrozen) {
+				t.Errorf("Rename() with %s frozen error = %v, want ErrKeyFrozen", frozenKey, err)
+			}
+			if got, err := store.Get[renameResult](s, "tmp.result"); err != nil || got.Node != 1 {
+				t.Errorf("tmp.result = %+v, %v after a rename with %s frozen", got, err, frozenKey)
+			}
+			if got, _ := store.Get[renameResult](s, "final.result"); got.Node != 2 {
+				t.Errorf("final.result = %+v after a rename with %s frozen", got, frozenKey)
+			}
+			frozen.Close()
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		s := newStore()
+		if err := Rename(s, "missing", "other", false); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Rename() error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestCopyKey(t *testing.T) {
+	s := store.NewKVStore()
+	meta := store.NewMetadata()
+	meta.AddTag("config")
+	s.PutWithTTLAndMetadata("src", renameResult{Node: 1, Items: []string{"a"}}, time.Hour, meta)
+	s.Put("dst", 7)
+
+	if err := CopyKey(s, "src", "dst", false); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("CopyKey() error = %v, want ErrKeyExists", err)
+	}
+	if err := CopyKey(s, "src", "dst", true); err != nil {
+		t.Fatalf("CopyKey() error = %v", err)
+	}
+
+	src, err := store.Get[renameResult](s, "src")
+	if err != nil {
+		t.Fatalf("Source missing after copy: %v", err)
+	}
+	dst, err := store.Get[renameResult](s, "dst")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	// The copy shares no references with the source
+	dst.Items[0] = "changed"
+	if src.Items[0] != "a" {
+		t.Errorf("Copied value aliases the source: %v", src.Items)
+	}
+
+	if err := s.AddTag("dst", "copy"); err != nil {
+		t.Fatalf("AddTag() error = %v", err)
+	}
+	if has, _ := s.HasTag("src", "copy"); has {
+		t.Error("Copied metadata aliases the source")
+	}
+	if has, _ := s.HasTag("dst", "config"); !has {
+		t.Error("Metadata not copied")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	newStore := func() *store.KVStore {
+		s := store.NewKVStore()
+		meta := store.NewMetadata()
+		meta.AddTag("active")
+		s.PutWithTTLAndMetadata("slot.active", renameResult{Node: 1, Items: []string{"a"}}, time.Hour, meta)
+		s.Put("slot.standby", "node2")
+		return s
+	}
+
+	t.Run("Exchanges entries", func(t *testing.T) {
+		s := newStore()
+		if err := Swap(s, "slot.active", "slot.standby"); err != nil {
+			t.Fatalf("Swap() error = %v", err)
+		}
+
+		if got, err := store.Get[string](s, "slot.active"); err != nil || got != "node2" {
+			t.Errorf("slot.active = %v, %v, want node2", got, err)
+		}
+		got, err := store.Get[renameResult](s, "slot.standby")
+		if err != nil || got.Node != 1 || len(got.Items) != 1 {
+			t.Errorf("slot.standby = %+v, %v", got, err)
+		}
+
+		// Metadata follows the value
+		if tagged, _ := s.HasTag("slot.standby", "active"); !tagged {
+			t.Error("Metadata did not follow the value")
+		}
+		if tagged, _ := s.HasTag("slot.active", "active"); tagged {
+			t.Error("Metadata stayed with the key")
+		}
+	})
+
+	t.Run("Missing key aborts", func(t *testing.T) {
+		s := newStore()
+		s.PutWithTTL("slot.stale", "old", time.Nanosecond)
+		time.Sleep(time.Millisecond)
+
+		for _, key := range []string{"slot.missing", "slot.stale"} {
+			if err := Swap(s, "slot.standby", key); err == nil {
+				t.Errorf("Swap() with %s expected an error", key)
+			}
+			if err := Swap(s, key, "slot.active"); err == nil {
+				t.Errorf("Swap() with %s first expected an error", key)
+			}
+		}
+		if err := Swap(s, "slot.active", "slot.missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Swap() error = %v, want ErrNotFound", err)
+		}
+
+		if got, _ := store.Get[string](s, "slot.standby"); got != "node2" {
+			t.Errorf("slot.standby = %q after failed swaps", got)
+		}
+		if got, _ := store.Get[renameResult](s, "slot.active"); got.Node != 1 {
+			t.Errorf("slot.active = %+v after failed swaps", got)
+		}
+		if _, err := store.Get[string](s, "slot.missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Failed swap created slot.missing: %v", err)
+		}
+	})
+
+	t.Run("Frozen key aborts", func(t *testing.T) {
+		for _, frozenKey := range []string{"slot.active", "slot.standby"} {
+			s := newStore()
+			frozen := NewFreezableStore(s)
+			if err := frozen.Freeze(frozenKey); err != nil {
+				t.Fatalf("Freeze() error = %v", err)
+			}
+
+			if err := Swap(s, "slot.active", "slot.standby"); !errors.Is(err, ErrKeyFrozen) {
+				t.Errorf("Swap() with %s frozen error = %v, want ErrKeyFrozen", frozenKey, err)
+			}
+			if got, _ := store.Get[renameResult](s, "slot.active"); got.Node != 1 {
+				t.Errorf("slot.active = %+v after a swap with %s frozen", got, frozenKey)
+			}
+			if got, _ := store.Get[string](s, "slot.standby"); got != "node2" {
+				t.Errorf("slot.standby = %q after a swap with %s frozen", got, frozenKey)
+			}
+			if err := Verify(s); err != nil {
+				t.Errorf("Verify() error = %v, want the frozen key untouched", err)
+			}
+			frozen.Close()
+		}
+	})
+}
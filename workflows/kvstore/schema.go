@@ -0,0 +1,74 @@
+package kvstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// typeToSchema builds the schema of a type, replaced by tests
+var typeToSchema = store.TypeToSchema
+
+// FindKeysBySchemaCtx is store.KVStore.FindKeysBySchema, checking ctx
+// between entries so a walk over many keys can be cancelled. Keys are
+// returned sorted. It returns ctx.Err() as soon as ctx is done.
+func FindKeysBySchemaCtx(ctx context.Context, s *store.KVStore, pattern interface{}) ([]string, error) {
+	var keys []string
+	err := walkSchemas(ctx, s, func(key string, schema interface{}) {
+		if store.SchemaMatch(schema, pattern) {
+			keys = append(keys, key)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetAllTypeSchemasCtx returns the type schema of every entry that has not
+// expired, by key, see store.KVStore.GetTypeSchema. It checks ctx between
+// entries and returns ctx.Err() as soon as ctx is done.
+func GetAllTypeSchemasCtx(ctx context.Context, s *store.KVStore) (map[string]interface{}, error) {
+	schemas := make(map[string]interface{})
+	err := walkSchemas(ctx, s, func(key string, schema interface{}) {
+		schemas[key] = schema
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// walkSchemas calls fn with the schema of every entry that has not expired,
+// in key order. Schemas, which are expensive to build, are built from the
+// type of each value; the store builds those of values that cannot be read,
+// see RegisterType.
+func walkSchemas(ctx context.Context, s *store.KVStore, fn func(key string, schema interface{})) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	keys := s.ListKeys()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		typ, err := TypeOf(s, key)
+		if isMissing(err) {
+			continue
+		}
+		if err == nil {
+			fn(key, typeToSchema(typ))
+			continue
+		}
+		schema, err := s.GetTypeSchema(key)
+		if err != nil {
+			continue
+		}
+		fn(key, schema)
+	}
+	return nil
+}
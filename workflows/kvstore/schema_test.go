@@ -0,0 +1,116 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type schemaNode struct {
+	ID       int
+	Hostname string
+}
+
+type schemaImage struct {
+	Path string
+	Size int64
+}
+
+// countSchemas replaces typeToSchema for the test, calling onBuild with the
+// number of schemas built so far
+func countSchemas(t *testing.T, onBuild func(built int)) {
+	t.Helper()
+	built := 0
+	typeToSchema = func(typ reflect.Type) interface{} {
+		built++
+		onBuild(built)
+		return store.TypeToSchema(typ)
+	}
+	t.Cleanup(func() { typeToSchema = store.TypeToSchema })
+}
+
+func TestSchemaWalks(t *testing.T) {
+	s := store.NewKVStore()
+	for i := 0; i < 100; i++ {
+		s.Put(fmt.Sprintf("node.%03d", i), schemaNode{ID: i})
+	}
+	s.Put("image", schemaImage{Path: "/images/rk1.img"})
+	s.PutWithTTL("stale", schemaNode{}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	nodePattern := store.TypeToSchema(reflect.TypeOf(schemaNode{}))
+
+	t.Run("Find keys by schema", func(t *testing.T) {
+		keys, err := FindKeysBySchemaCtx(context.Background(), s, nodePattern)
+		if err != nil {
+			t.Fatalf("FindKeysBySchemaCtx() error = %v", err)
+		}
+		if len(keys) != 100 || keys[0] != "node.000" || keys[99] != "node.099" {
+			t.Errorf("FindKeysBySchemaCtx() = %d keys from %v, want the 100 nodes in order", len(keys), keys[:1])
+		}
+	})
+
+	t.Run("All schemas", func(t *testing.T) {
+		schemas, err := GetAllTypeSchemasCtx(context.Background(), s)
+		if err != nil {
+			t.Fatalf("GetAllTypeSchemasCtx() error = %v", err)
+		}
+		if len(schemas) != 101 {
+			t.Errorf("GetAllTypeSchemasCtx() = %d schemas, want 101 without the expired key", len(schemas))
+		}
+		want, _ := s.GetTypeSchema("image")
+		if !reflect.DeepEqual(schemas["image"], want) {
+			t.Errorf("Schema of image = %v, want %v", schemas["image"], want)
+		}
+	})
+
+	walks := map[string]func(ctx context.Context) (interface{}, error){
+		"FindKeysBySchemaCtx": func(ctx context.Context) (interface{}, error) {
+			return FindKeysBySchemaCtx(ctx, s, nodePattern)
+		},
+		"GetAllTypeSchemasCtx": func(ctx context.Context) (interface{}, error) {
+			return GetAllTypeSchemasCtx(ctx, s)
+		},
+	}
+	for name, walk := range walks {
+		t.Run(name+" cancelled mid-walk", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var built int
+			countSchemas(t, func(n int) {
+				built = n
+				if n == 10 {
+					cancel()
+				}
+			})
+
+			result, err := walk(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("%s() error = %v, want context.Canceled", name, err)
+			}
+			if !reflect.ValueOf(result).IsNil() {
+				t.Errorf("%s() returned a partial result %v", name, result)
+			}
+			if built != 10 {
+				t.Errorf("%s() built %d schemas, want to stop after the 10th", name, built)
+			}
+		})
+
+		t.Run(name+" cancelled before", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			var built int
+			countSchemas(t, func(n int) { built = n })
+			if _, err := walk(ctx); !errors.Is(err, context.Canceled) || built != 0 {
+				t.Errorf("%s() error = %v after building %d schemas, want context.Canceled before any", name, err, built)
+			}
+		})
+	}
+}
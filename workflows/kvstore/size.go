@@ -0,0 +1,130 @@
+package kvstore
+
+import (
+	"reflect"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// Count returns the number of entries that have not expired.
+// It applies the same expiry filtering as SizeBytes.
+func Count(s *store.KVStore) int {
+	count, _ := Stats(s)
+	return count
+}
+
+// SizeBytes returns an estimate of the memory held by the entries that have
+// not expired: their keys and values, following pointers, slices, maps and
+// interfaces. It helps decide when a store should be snapshotted or trimmed.
+func SizeBytes(s *store.KVStore) int64 {
+	_, size := Stats(s)
+	return size
+}
+
+// Stats returns the number of entries that have not expired and their
+// estimated size in bytes. The values that cannot be read, see RegisterType,
+// are of basic kinds and only their key is counted.
+func Stats(s *store.KVStore) (count int, sizeBytes int64) {
+	unlock := lockStores(s)
+	defer unlock()
+
+	for _, key := range s.ListKeys() {
+		value, err := readValue(s, key)
+		if isMissing(err) {
+			continue
+		}
+		count++
+		sizeBytes += int64(len(key)) + estimateSize(value)
+	}
+	return count, sizeBytes
+}
+
+// estimateSize returns the approximate number of bytes held by a value
+func estimateSize(value interface{}) int64 {
+	if value == nil {
+		return 0
+	}
+	return estimateValueSize(reflect.ValueOf(value), make(map[uintptr]bool))
+}
+
+// estimateValueSize recursively sizes a reflected value. Pointers already
+// visited are counted once, so shared and cyclic data is not double counted.
+func estimateValueSize(v reflect.Value, visited map[uintptr]bool) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+
+	size := int64(v.Type().Size())
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return size
+		}
+		visited[v.Pointer()] = true
+		size += estimateValueSize(v.Elem(), visited)
+	case reflect.Interface:
+		if !v.IsNil() {
+			size += estimateValueSize(v.Elem(), visited)
+		}
+	case reflect.Slice:
+		if v.IsNil() || visited[v.Pointer()] {
+			return size
+		}
+		visited[v.Pointer()] = true
+		size += contentSize(v, v.Cap(), visited)
+	case reflect.Array:
+		// The elements are part of the array's own size
+		size = contentSize(v, v.Len(), visited)
+	case reflect.Map:
+		if v.IsNil() || visited[v.Pointer()] {
+			return size
+		}
+		visited[v.Pointer()] = true
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateValueSize(iter.Key(), visited) + estimateValueSize(iter.Value(), visited)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			// The field itself is part of the struct's size, count what it references
+			size += estimateValueSize(v.Field(i), visited) - int64(v.Field(i).Type().Size())
+		}
+	}
+	return size
+}
+
+// contentSize sizes the first n elements of a slice or array, allocated or not
+func contentSize(v reflect.Value, n int, visited map[uintptr]bool) int64 {
+	elem := v.Type().Elem()
+	size := int64(n) * int64(elem.Size())
+	if isFlat(elem) {
+		return size
+	}
+	for i := 0; i < v.Len(); i++ {
+		size += estimateValueSize(v.Index(i), visited) - int64(elem.Size())
+	}
+	return size
+}
+
+// isFlat reports whether values of a type reference no other memory
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isFlat(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isFlat(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
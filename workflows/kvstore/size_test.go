@@ -0,0 +1,66 @@
+package kvstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type sizedConfig struct {
+	Name  string
+	Files map[string][]byte
+	Next  *sizedConfig
+}
+
+func TestSizeBytes(t *testing.T) {
+	t.Run("Reflects blob sizes", func(t *testing.T) {
+		s := store.NewKVStore()
+		if size := SizeBytes(s); size != 0 {
+			t.Errorf("SizeBytes() of an empty store = %d, want 0", size)
+		}
+
+		s.Put("image", make([]byte, 10000))
+		afterImage := SizeBytes(s)
+		if afterImage < 10000 || afterImage > 10200 {
+			t.Errorf("SizeBytes() = %d, want about 10000", afterImage)
+		}
+
+		s.Put("log", strings.Repeat("x", 5000))
+		afterLog := SizeBytes(s)
+		if grown := afterLog - afterImage; grown < 5000 || grown > 5200 {
+			t.Errorf("SizeBytes() grew by %d for a 5000 byte string", grown)
+		}
+
+		config := &sizedConfig{
+			Name:  "node1",
+			Files: map[string][]byte{"hostname": make([]byte, 2000), "hosts": make([]byte, 3000)},
+		}
+		config.Next = config // Cycles are counted once
+		s.Put("config", config)
+		if grown := SizeBytes(s) - afterLog; grown < 5000 || grown > 5500 {
+			t.Errorf("SizeBytes() grew by %d for a struct holding 5000 bytes", grown)
+		}
+	})
+
+	t.Run("Drops when keys expire", func(t *testing.T) {
+		s := store.NewKVStore()
+		s.Put("kept", make([]byte, 1000))
+		s.PutWithTTL("expiring", make([]byte, 50000), 20*time.Millisecond)
+
+		count, size := Stats(s)
+		if count != 2 || size < 51000 {
+			t.Errorf("Stats() = %d, %d, want 2 entries of at least 51000 bytes", count, size)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		if count := Count(s); count != 1 || count != s.Count() {
+			t.Errorf("Count() = %d, store Count() = %d, want 1", count, s.Count())
+		}
+		if size := SizeBytes(s); size < 1000 || size > 1200 {
+			t.Errorf("SizeBytes() after expiry = %d, want about 1000", size)
+		}
+	})
+}
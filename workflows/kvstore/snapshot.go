@@ -0,0 +1,147 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// Snapshot is a serializable copy of the entries of a store, used to persist
+// workflow state and load it back later, possibly in another process
+type Snapshot struct {
+	Entries map[string]SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is one store entry encoded as JSON
+type SnapshotEntry struct {
+	// Type is the Go type of the stored value, used to decode it back
+	Type string `json:"type"`
+	// Version is the schema version of the type when the value was encoded,
+	// older values are migrated before being decoded
+	Version     int             `json:"version,omitempty"`
+	Value       json.RawMessage `json:"value"`
+	ExpiresAt   *time.Time      `json:"expiresAt,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// TakeSnapshot encodes every entry of the store that has not expired. The
+// store does not expose the expiry of its entries, the snapshot records none.
+// It fails if a value cannot be read, see RegisterType, or encoded as JSON.
+func TakeSnapshot(s *store.KVStore) (*Snapshot, error) {
+	unlock := lockStores(s)
+	defer unlock()
+
+	snapshot := &Snapshot{Entries: make(map[string]SnapshotEntry)}
+	for _, key := range s.ListKeys() {
+		e, err := lookup(s, key)
+		if isMissing(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key '%s': %w", key, err)
+		}
+
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key '%s': %w", key, err)
+		}
+
+		entry := SnapshotEntry{Value: value}
+		if typ := e.Type(); typ != nil {
+			entry.Type = typ.String()
+			entry.Version = CurrentVersion(entry.Type)
+		}
+		if e.Metadata != nil {
+			entry.Tags = append(entry.Tags, e.Metadata.Tags...)
+			entry.Description = e.Metadata.Description
+		}
+		snapshot.Entries[key] = entry
+	}
+	return snapshot, nil
+}
+
+// Keys returns the keys of the snapshot in sorted order
+func (sn *Snapshot) Keys() []string {
+	keys := make([]string, 0, len(sn.Entries))
+	for key := range sn.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Restore puts the entries of a snapshot into the store, overwriting keys it
+// already holds. Each value is decoded back into its original type when the
+// store already holds a value of that type under its key, or when the type
+// was registered with RegisterType. Values of unknown types are decoded as
+// generic JSON values. Entries that expired since the snapshot was taken are
+// left out.
+func Restore(s *store.KVStore, snapshot *Snapshot) error {
+	unlock := lockStores(s)
+	defer unlock()
+
+	now := time.Now()
+	for _, key := range snapshot.Keys() {
+		entry := snapshot.Entries[key]
+		if entry.ExpiresAt != nil && now.After(*entry.ExpiresAt) {
+			continue
+		}
+
+		value, err := decodeEntry(s, key, entry)
+		if err != nil {
+			return err
+		}
+
+		meta := store.NewMetadata()
+		meta.Description = entry.Description
+		for _, tag := range entry.Tags {
+			meta.AddTag(tag)
+		}
+
+		var ttl time.Duration
+		if entry.ExpiresAt != nil {
+			ttl = entry.ExpiresAt.Sub(now)
+		}
+		err = set(s, key, value, func() error { return s.PutWithTTLAndMetadata(key, value, ttl, meta) })
+		if err != nil {
+			return fmt.Errorf("failed to restore key '%s': %w", key, err)
+		}
+	}
+	return nil
+}
+
+// decodeEntry decodes the value of a snapshot entry into its best known type
+func decodeEntry(s *store.KVStore, key string, entry SnapshotEntry) (interface{}, error) {
+	data, err := migrate(entry.Type, entry.Version, entry.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate key '%s': %w", key, err)
+	}
+	entry.Value = data
+
+	var target reflect.Type
+	if current, err := TypeOf(s, key); err == nil && current != nil && current.String() == entry.Type {
+		target = current
+	}
+
+	if target == nil {
+		target, _ = registeredType(entry.Type)
+	}
+	if target == nil {
+		var value interface{}
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode key '%s': %w", key, err)
+		}
+		return value, nil
+	}
+
+	value := reflect.New(target)
+	if err := json.Unmarshal(entry.Value, value.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to decode key '%s' as %s: %w", key, entry.Type, err)
+	}
+	return value.Elem().Interface(), nil
+}
@@ -0,0 +1,107 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+type snapshotNode struct {
+	ID   int
+	Name string
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	RegisterType[snapshotNode]()
+
+	source := store.NewKVStore()
+	source.Put("count", 3)
+	source.Put("hosts", []string{"node1", "node2"})
+	source.Put("node", snapshotNode{ID: 1, Name: "node1"})
+	source.PutWithTTL("lease", "token", time.Hour)
+	source.PutWithTTL("expired", "token", time.Millisecond)
+	source.AddTag("count", "config")
+	time.Sleep(5 * time.Millisecond)
+
+	snapshot, err := TakeSnapshot(source)
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	if keys := snapshot.Keys(); !reflect.DeepEqual(keys, []string{"count", "hosts", "lease", "node"}) {
+		t.Errorf("Snapshot keys = %v", keys)
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Failed to encode snapshot: %v", err)
+	}
+	var decoded Snapshot
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+
+	target := store.NewKVStore()
+	if err := Restore(target, &decoded); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if count, err := store.Get[int](target, "count"); err != nil || count != 3 {
+		t.Errorf("count = %v, %v, want 3", count, err)
+	}
+	if hosts, err := store.Get[[]string](target, "hosts"); err != nil || len(hosts) != 2 {
+		t.Errorf("hosts = %v, %v", hosts, err)
+	}
+	if node, err := store.Get[snapshotNode](target, "node"); err != nil || node.Name != "node1" {
+		t.Errorf("node = %+v, %v", node, err)
+	}
+	if tagged, _ := target.HasTag("count", "config"); !tagged {
+		t.Error("Restored count lost its tag")
+	}
+
+	if _, err := store.Get[string](target, "lease"); err != nil {
+		t.Errorf("lease should be restored: %v", err)
+	}
+	if _, err := store.Get[string](target, "expired"); err == nil {
+		t.Error("Expired key should not be restored")
+	}
+
+	t.Run("Unknown types decode as JSON values", func(t *testing.T) {
+		type unregistered struct{ Size int }
+
+		source := store.NewKVStore()
+		source.Put("disk", unregistered{Size: 8})
+		snapshot, err := TakeSnapshot(source)
+		if err != nil {
+			t.Fatalf("TakeSnapshot() error = %v", err)
+		}
+
+		// Decodes into the type already held by the store
+		typed := store.NewKVStore()
+		typed.Put("disk", unregistered{})
+		if err := Restore(typed, snapshot); err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if disk, err := store.Get[unregistered](typed, "disk"); err != nil || disk.Size != 8 {
+			t.Errorf("disk = %+v, %v", disk, err)
+		}
+
+		generic := store.NewKVStore()
+		if err := Restore(generic, snapshot); err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if disk, err := store.Get[map[string]interface{}](generic, "disk"); err != nil || disk["Size"] != float64(8) {
+			t.Errorf("disk = %v, %v", disk, err)
+		}
+	})
+
+	t.Run("Values that cannot be encoded", func(t *testing.T) {
+		s := store.NewKVStore()
+		s.Put("callback", func() {})
+		if _, err := TakeSnapshot(s); err == nil {
+			t.Error("TakeSnapshot() should fail on a function value")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package kvstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/davidroman0O/gostage/store"
+)
+
+// ErrUnknownType is returned when a value cannot be read back from a store
+// without knowing its type, see RegisterType
+var ErrUnknownType = errors.New("unknown value type")
+
+// registration is a type registered with RegisterType
+type registration struct {
+	typ reflect.Type
+	// get reads a value of the type from a store
+	get func(s *store.KVStore, key string) (interface{}, error)
+}
+
+var (
+	typesMu sync.RWMutex
+	// types maps type names to their registration
+	types = make(map[string]registration)
+	// basicTypes lists the registered types store.Get cannot return as interfaces
+	basicTypes []registration
+)
+
+func init() {
+	RegisterType[string]()
+	RegisterType[bool]()
+	RegisterType[int]()
+	RegisterType[int8]()
+	RegisterType[int16]()
+	RegisterType[int32]()
+	RegisterType[int64]()
+	RegisterType[uint]()
+	RegisterType[uint8]()
+	RegisterType[uint16]()
+	RegisterType[uint32]()
+	RegisterType[uint64]()
+	RegisterType[float32]()
+	RegisterType[float64]()
+	RegisterType[time.Time]()
+	RegisterType[time.Duration]()
+	RegisterType[[]string]()
+	RegisterType[[]int]()
+	RegisterType[map[string]string]()
+	RegisterType[map[string]interface{}]()
+}
+
+// RegisterType makes values of type T readable by the helpers of this package
+// that handle values of any type, and makes them decode back to T when a
+// snapshot is restored into a store that does not already hold the key.
+// gostage's store only returns values of composite kinds, structs, pointers,
+// maps, slices and arrays, without knowing their type: values of other kinds,
+// such as a named string type, are only readable once registered. The
+// predeclared types are registered.
+func RegisterType[T any]() {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	r := registration{
+		typ: t,
+		get: func(s *store.KVStore, key string) (interface{}, error) {
+			return store.Get[T](s, key)
+		},
+	}
+
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, ok := types[t.String()]; !ok && !isComposite(t.Kind()) {
+		basicTypes = append(basicTypes, r)
+	}
+	types[t.String()] = r
+}
+
+// registeredType returns the type registered under name
+func registeredType(name string) (reflect.Type, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	r, ok := types[name]
+	return r.typ, ok
+}
+
+// isComposite reports whether store.Get returns values of a kind as interfaces
+func isComposite(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Ptr, reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+// readValue returns the value stored under key whatever its type, as stored,
+// or ErrUnknownType when it is of a basic kind that was not registered
+func readValue(s *store.KVStore, key string) (interface{}, error) {
+	value, err := store.Get[interface{}](s, key)
+	if !errors.Is(err, store.ErrTypeMismatch) {
+		return value, err
+	}
+
+	typesMu.RLock()
+	candidates := basicTypes
+	typesMu.RUnlock()
+
+	for _, r := range candidates {
+		value, err := r.get(s, key)
+		if !errors.Is(err, store.ErrTypeMismatch) {
+			return value, err
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s' holds a value of a type that is not registered, see RegisterType", ErrUnknownType, key)
+}
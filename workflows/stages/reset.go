@@ -38,9 +38,8 @@ func CreateHardResetStage() *gostage.Stage {
 
 	// Add actions in sequence
 	stage.AddAction(bmc.NewGetPowerStatusAction()) // Check current status
-	stage.AddAction(bmc.NewResetNodeAction())      // Reset the node
-	stage.AddAction(common.NewWaitAction(10))      // Wait for boot to start
-	stage.AddAction(bmc.NewGetPowerStatusAction()) // Verify node is on
+	stage.AddAction(bmc.NewHardResetNodeAction())  // Power-cycle with off/on confirmation
+	stage.AddAction(bmc.NewGetPowerStatusAction()) // Record the new status
 
 	return stage
 }